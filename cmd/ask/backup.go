@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/scbenet/ask/internal/backup"
+	"github.com/scbenet/ask/internal/config"
+	"github.com/scbenet/ask/internal/session"
+)
+
+// runBackup implements `ask backup create|restore file`
+func runBackup(args []string) error {
+	usage := "usage: ask backup create [-exclude-keys] file.tar.zst\n       ask backup restore [-force] file.tar.zst"
+	if len(args) == 0 {
+		return fmt.Errorf("%s", usage)
+	}
+
+	sources, err := backupSources()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("backup create", flag.ExitOnError)
+		excludeKeys := fs.Bool("exclude-keys", false, "leave API keys and tokens out of the backed up config")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			return fmt.Errorf("%s", usage)
+		}
+
+		n, err := backup.Create(fs.Arg(0), sources, backup.Options{ExcludeKeys: *excludeKeys})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("backed up %d files to %s\n", n, fs.Arg(0))
+
+	case "restore":
+		fs := flag.NewFlagSet("backup restore", flag.ExitOnError)
+		force := fs.Bool("force", false, "overwrite existing config and sessions")
+		fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			return fmt.Errorf("%s", usage)
+		}
+
+		if !*force {
+			for _, src := range sources {
+				if entries, err := os.ReadDir(src.Dir); err == nil && len(entries) > 0 {
+					return fmt.Errorf("%s is not empty, pass -force to overwrite it", src.Dir)
+				}
+			}
+		}
+		n, err := backup.Restore(fs.Arg(0), sources)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("restored %d files from %s\n", n, fs.Arg(0))
+
+	default:
+		return fmt.Errorf("%s", usage)
+	}
+	return nil
+}
+
+// backupSources lists what a backup covers: the config directory and the
+// data directory holding sessions and attachments
+func backupSources() ([]backup.Source, error) {
+	configPath, err := config.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	sessionDir, err := session.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+
+	// the other profiles live inside the default profile's directories,
+	// each profile backs up its own
+	return []backup.Source{
+		{Name: "config", Dir: filepath.Dir(configPath), Skip: []string{"profiles"}, Config: []string{filepath.Base(configPath)}},
+		// migration backups are only useful on the machine that made them
+		{Name: "data", Dir: filepath.Dir(sessionDir), Skip: []string{"backups", "profiles"}},
+	}, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// runGC implements `ask gc`, removing attachments no session uses anymore
+func runGC(args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ExitOnError)
+	dryRun := fs.Bool("n", false, "only report what would be removed")
+	fs.Parse(args)
+
+	store, err := openSessionStore()
+	if err != nil {
+		return err
+	}
+
+	report, err := store.CollectGarbage(*dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "removed"
+	if *dryRun {
+		verb = "would remove"
+	}
+	fmt.Printf("scanned %d blobs, %s %d orphaned blobs and %d temp files, %s reclaimed\n",
+		report.BlobsScanned, verb, report.BlobsRemoved, report.TempFilesRemoved, formatBytes(report.BytesReclaimed))
+	return nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/scbenet/ask/internal/blob"
+	"github.com/scbenet/ask/internal/importer"
+	"github.com/scbenet/ask/internal/session"
+)
+
+// runImport implements `ask import [-format f] file...`
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "", "export format (chatgpt, claude, aichat, sgpt), detected if empty")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ask import [-format f] file...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no files to import")
+	}
+
+	store, err := openSessionStore()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range fs.Args() {
+		sessions, err := importer.ImportFile(path, importer.Format(*format))
+		if err != nil {
+			return err
+		}
+		for _, s := range sessions {
+			if err := store.Save(s); err != nil {
+				return err
+			}
+			fmt.Printf("%s  %s (%d messages)\n", s.ID, s.Title, len(s.Messages))
+		}
+		fmt.Printf("imported %d conversations from %s\n", len(sessions), path)
+	}
+	fmt.Println("continue one with: ask -session <id>")
+	return nil
+}
+
+// openSessionStore opens the session store, migrating it to the current
+// schema first if it was written by an older version of ask
+func openSessionStore() (*session.Store, error) {
+	dir, err := session.DefaultDir()
+	if err != nil {
+		return nil, err
+	}
+	root := filepath.Dir(dir)
+
+	applied, err := session.Migrator(root).Run()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range applied {
+		fmt.Fprintf(os.Stderr, "migrated session store to version %d: %s\n", m.Version, m.Description)
+	}
+
+	// attachments are kept next to the sessions that reference them
+	blobs, err := blob.NewStore(filepath.Join(root, "blobs"))
+	if err != nil {
+		return nil, err
+	}
+	return session.NewStore(dir, blobs)
+}
+
+// rollbackSessionStore restores the backup taken before the last migration
+func rollbackSessionStore() error {
+	dir, err := session.DefaultDir()
+	if err != nil {
+		return err
+	}
+	version, err := session.Migrator(filepath.Dir(dir)).Rollback()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("restored session store backup (schema version %d)\n", version)
+	return nil
+}
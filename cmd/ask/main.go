@@ -1,26 +1,391 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/scbenet/ask/internal/app"
+	"github.com/scbenet/ask/internal/attach"
+	"github.com/scbenet/ask/internal/config"
+	"github.com/scbenet/ask/internal/diagram"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/journal"
+	"github.com/scbenet/ask/internal/logging"
+	"github.com/scbenet/ask/internal/memory"
+	"github.com/scbenet/ask/internal/profile"
+	"github.com/scbenet/ask/internal/spend"
+	"github.com/scbenet/ask/internal/ui"
 )
 
+// subcommands run instead of the TUI when given as the first argument
+var subcommands = map[string]func(args []string) error{
+	"backup":   runBackup,
+	"gc":       runGC,
+	"import":   runImport,
+	"paths":    runPaths,
+	"run":      runWorkflow,
+	"sessions": runSessions,
+	"trash":    runTrash,
+}
+
 func main() {
-	// width/height are placeholders, bubble tea sends a resize msg
-	f, err := tea.LogToFile("debug.log", "debug")
+	started := time.Now()
+	args, err := takeProfile(os.Args[1:])
 	if err != nil {
 		fmt.Println("fatal:", err)
 		os.Exit(1)
 	}
+	if len(args) > 0 {
+		if run, ok := subcommands[args[0]]; ok {
+			if err := run(args[1:]); err != nil {
+				fmt.Println("error:", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	sessionID := flag.String("session", "", "resume a saved session by id (see `ask sessions`)")
+	replayID := flag.String("replay", "", "step through a saved session one message at a time, read-only")
+	configPath := flag.String("config", "", "path to config file (default ~/.config/ask/config.json)")
+	incognito := flag.Bool("incognito", false, "don't save, log or remember anything from this conversation")
+	debug := flag.Bool("debug", false, "log everything, not just errors and requests (see `log` in the config)")
+	rollback := flag.Bool("rollback", false, "restore the session store backup taken before the last migration and exit")
+	logResponses := flag.Bool("log-responses", false, "append every prompt and response to a markdown file per day (see journal in the config)")
+	inline := flag.Bool("inline", false, "keep the conversation in the terminal's scrollback instead of a full screen view")
+	accessible := flag.Bool("accessible", false, "plain linear output for screen readers, implies -inline (or set accessible in the config)")
+	script := flag.String("script", "", "run the UI without a terminal from a script of keys and commands, printing what it asks for (see README)")
+	profileName := flag.String("profile", profile.Name(), "use the config and data of a named profile (or set $"+profile.EnvVar+")")
+	flag.CommandLine.Parse(args)
+	if err := profile.Set(*profileName); err != nil {
+		fmt.Println("fatal:", err)
+		os.Exit(1)
+	}
+
+	if *rollback {
+		if err := rollbackSessionStore(); err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *incognito && *sessionID != "" {
+		fmt.Println("fatal: saved sessions can't be resumed in incognito mode")
+		os.Exit(1)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Println("fatal:", err)
+		os.Exit(1)
+	}
+	if *accessible || cfg.Accessible {
+		// accessible mode is drawn inline, see ui.WithAccessible
+		*accessible, *inline = true, true
+	}
+
+	if *replayID != "" && *sessionID != "" {
+		fmt.Println("fatal: -replay can't be combined with -session")
+		os.Exit(1)
+	}
+
+	var programOpts []tea.ProgramOption
+	opts := app.Options{Incognito: *incognito}
+	switch {
+	case *accessible:
+		opts.ChatOptions = append(opts.ChatOptions, ui.WithAccessible())
+	case *inline:
+		opts.ChatOptions = append(opts.ChatOptions, ui.WithInline())
+	default:
+		programOpts = append(programOpts, tea.WithAltScreen())
+	}
+	if *incognito {
+		// nothing about the conversation may end up on disk
+		log.SetOutput(io.Discard)
+	} else {
+		logFile, err := startLog(cfg.Log, *debug)
+		if err != nil {
+			fmt.Println("fatal:", err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+	}
+	if err := i18n.SetLanguage(i18n.Detect(cfg.Language)); err != nil {
+		if cfg.Language != "" {
+			fmt.Println("fatal:", err)
+			os.Exit(1)
+		}
+		// a locale we have no translation for, English it is
+		log.Printf("%v, using English", err)
+	}
+	opts.Config = cfg
+	if path, err := spend.DefaultPath(); err != nil {
+		logging.Errorf("finding spend ledger: %v", err)
+	} else {
+		opts.Spend = spend.Open(path)
+	}
+	if (cfg.Journal.Enabled || *logResponses) && !*incognito {
+		opts.Journal = openJournal(cfg.Journal.Dir)
+	}
+	// inline the wheel scrolls the terminal
+	if cfg.Mouse && !*inline {
+		programOpts = append(programOpts, tea.WithMouseCellMotion())
+	}
+
+	// the stores don't depend on each other, open them side by side
+	var openMemories sync.WaitGroup
+	if cfg.Memory.Enabled {
+		openMemories.Add(1)
+		go func() {
+			defer openMemories.Done()
+			if memories, err := openMemoryStore(); err != nil {
+				logging.Errorf("opening memory store: %v", err)
+			} else {
+				opts.Memories = memories
+			}
+		}()
+	}
+
+	store, err := openSessionStore()
+	openMemories.Wait()
+	if err != nil {
+		// not fatal, the conversation just won't be saved
+		logging.Errorf("opening session store: %v", err)
+	} else {
+		opts.Store = store
+		if !*incognito {
+			recovered, err := store.Recoverable()
+			if err != nil {
+				logging.Errorf("looking for conversations to recover: %v", err)
+			}
+			opts.Recovered = recovered
+		}
+		if retention := cfg.TrashRetention.Std(); retention > 0 {
+			// nothing waits for this, keep it off the startup path
+			go func() {
+				if n, err := store.PurgeTrash(retention); err != nil {
+					logging.Errorf("purging trash: %v", err)
+				} else if n > 0 {
+					logging.Infof("purged %d old trash entries", n)
+				}
+			}()
+		}
+	}
+	if *sessionID != "" {
+		if store == nil {
+			fmt.Println("fatal: cannot resume session without a session store")
+			os.Exit(1)
+		}
+		sess, err := store.Load(*sessionID)
+		if err != nil {
+			fmt.Println("fatal:", err)
+			os.Exit(1)
+		}
+		opts.Session = sess
+	}
+	if *replayID != "" {
+		if store == nil {
+			fmt.Println("fatal: cannot replay a session without a session store")
+			os.Exit(1)
+		}
+		sess, err := store.Load(*replayID)
+		if err != nil {
+			fmt.Println("fatal:", err)
+			os.Exit(1)
+		}
+		// a replay is read-only, nothing of it may be saved or recovered
+		opts.Session = sess
+		opts.Replay = true
+		opts.Store = nil
+		opts.Recovered = nil
+	}
+
+	// anything piped in (ask < main.go) is attached as initial context.
+	// stdin is no longer a terminal at that point, so read keys from the tty instead
+	if stdinIsPiped() {
+		stdin, err := attach.FromReader("stdin", os.Stdin)
+		if err != nil {
+			fmt.Println("fatal:", err)
+			os.Exit(1)
+		}
+		if stdin.Content != "" {
+			opts.Attachments = append(opts.Attachments, stdin)
+		}
+		programOpts = append(programOpts, tea.WithInputTTY())
+	}
+
+	// windows consoles report keys through their own API, not escape codes
+	var keyboard *ui.KittyKeyboard
+	if cfg.KeyboardProtocol && *script == "" && runtime.GOOS != "windows" {
+		keyboard = ui.NewKittyKeyboard(os.Stdout)
+		keyboard.SetSendKey(cfg.SendKey)
+		opts.Keyboard = keyboard
+		// the keys are read through the keyboard, which needs the terminal
+		// bubble tea would have read them from
+		input := os.Stdin
+		if stdinIsPiped() {
+			tty, err := os.Open("/dev/tty")
+			if err != nil {
+				fmt.Println("fatal: could not open a new TTY:", err)
+				os.Exit(1)
+			}
+			defer tty.Close()
+			input = tty
+		}
+		programOpts = append(programOpts, tea.WithInput(keyboard.Input(input)), tea.WithFilter(keyboard.Filter))
+	}
+
+	rootModel := app.New(opts)
+	logging.Infof("startup: ready after %s", time.Since(started))
+
+	if *script != "" {
+		if err := runScript(rootModel, *script); err != nil {
+			fmt.Println("error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// bubble tea would quit on SIGTERM without the app getting a say, the
+	// app cancels the stream and saves the conversation first
+	programOpts = append(programOpts, tea.WithoutSignalHandler())
+	p := tea.NewProgram(rootModel, programOpts...)
+	go handleSignals(p)
+	defer func() {
+		if err := diagram.Cleanup(); err != nil {
+			logging.Errorf("removing rendered diagrams: %v", err)
+		}
+	}()
+	_, err = p.Run()
+	// killed by a second signal it didn't get to turn it off
+	keyboard.Disable()
+	if err != nil {
+		logging.Errorf("running ui: %v", err)
+		fmt.Println("fatal:", err)
+		os.Exit(1)
+	}
+	if opts.Store != nil {
+		// a clean exit, there's nothing to recover next time
+		if err := store.ClearRecovery(); err != nil {
+			logging.Errorf("%v", err)
+		}
+		if err := store.SetOpen(""); err != nil {
+			logging.Errorf("%v", err)
+		}
+	}
+}
+
+// runScript drives the app from the script at path, see app.Driver
+func runScript(a *app.App, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
 	defer f.Close()
-	rootModel := app.New()
+	return app.NewDriver(a, os.Stdout).Run(f)
+}
 
-	p := tea.NewProgram(rootModel, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
-		log.Fatal(err)
+// handleSignals shuts the app down gracefully on the first signal, a second
+// one kills it without waiting. either way the terminal is restored
+func handleSignals(p *tea.Program) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	s := <-signals
+	logging.Infof("received %s, shutting down", s)
+	p.Send(app.ShutdownMsg{})
+	<-signals
+	p.Kill()
+}
+
+// stdinIsPiped reports whether stdin is a pipe or file rather than a terminal
+func stdinIsPiped() bool {
+	stat, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return stat.Mode()&os.ModeCharDevice == 0
+}
+
+// takeProfile selects the profile given with -profile in front of args,
+// where it also goes before a subcommand, returning the rest of args
+func takeProfile(args []string) ([]string, error) {
+	name := profile.Name()
+	for len(args) > 0 {
+		key, value, ok := strings.Cut(strings.TrimLeft(args[0], "-"), "=")
+		if !strings.HasPrefix(args[0], "-") || key != "profile" {
+			break
+		}
+		if !ok {
+			if len(args) < 2 {
+				return nil, fmt.Errorf("-profile needs a profile name")
+			}
+			value, args = args[1], args[1:]
+		}
+		name, args = value, args[1:]
+	}
+	return args, profile.Set(name)
+}
+
+// openJournal opens the journal in dir, or the default directory if it's
+// empty. nil if there's no default directory
+func openJournal(dir string) *journal.Journal {
+	if dir == "" {
+		var err error
+		if dir, err = journal.DefaultDir(); err != nil {
+			logging.Errorf("finding journal: %v", err)
+			return nil
+		}
+	}
+	return journal.Open(dir)
+}
+
+func openMemoryStore() (*memory.Store, error) {
+	path, err := memory.DefaultPath()
+	if err != nil {
+		return nil, err
+	}
+	return memory.Open(path)
+}
+
+// loadConfig reads the config file, from the default location if path is empty
+func loadConfig(path string) (config.Config, error) {
+	if path == "" {
+		var err error
+		path, err = config.DefaultPath()
+		if err != nil {
+			return config.Default(), err
+		}
+	}
+	return config.Load(path)
+}
+
+// startLog sends the log to the file in the config, at debug level if
+// debug is set
+func startLog(cfg config.LogConfig, debug bool) (*logging.Writer, error) {
+	path := cfg.Path
+	if path == "" {
+		var err error
+		if path, err = logging.DefaultPath(); err != nil {
+			return nil, err
+		}
+	}
+	level, err := logging.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	if debug {
+		level = logging.Debug
 	}
+	return logging.Start(path, level, int64(cfg.MaxSize)<<20, cfg.MaxFiles), nil
 }
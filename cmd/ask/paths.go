@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/scbenet/ask/internal/config"
+	"github.com/scbenet/ask/internal/journal"
+	"github.com/scbenet/ask/internal/logging"
+	"github.com/scbenet/ask/internal/memory"
+	"github.com/scbenet/ask/internal/paths"
+	"github.com/scbenet/ask/internal/profile"
+	"github.com/scbenet/ask/internal/session"
+	"github.com/scbenet/ask/internal/spend"
+)
+
+// runPaths implements `ask paths`, printing where everything ask keeps
+// lives, for the active profile and with the config's overrides
+func runPaths(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("usage: ask paths")
+	}
+	cfg, err := loadConfig("")
+	if err != nil {
+		return err
+	}
+
+	configPath, err := config.DefaultPath()
+	if err != nil {
+		return err
+	}
+	data, err := paths.Data()
+	if err != nil {
+		return err
+	}
+	sessions, err := session.DefaultDir()
+	if err != nil {
+		return err
+	}
+	memories, err := memory.DefaultPath()
+	if err != nil {
+		return err
+	}
+	spent, err := spend.DefaultPath()
+	if err != nil {
+		return err
+	}
+	journalDir := cfg.Journal.Dir
+	if journalDir == "" {
+		if journalDir, err = journal.DefaultDir(); err != nil {
+			return err
+		}
+	}
+	logPath := cfg.Log.Path
+	if logPath == "" {
+		if logPath, err = logging.DefaultPath(); err != nil {
+			return err
+		}
+	}
+	cache, err := paths.Cache()
+	if err != nil {
+		return err
+	}
+
+	if name := profile.Name(); name != "" {
+		fmt.Printf("%-12s %s\n", "profile", name)
+	}
+	for _, p := range [][2]string{
+		{"config", configPath},
+		{"data", data},
+		{"sessions", sessions},
+		{"trash", filepath.Join(sessions, ".trash")},
+		{"attachments", filepath.Join(data, "blobs")},
+		{"backups", filepath.Join(data, "backups")},
+		{"memories", memories},
+		{"spend", spent},
+		{"journal", journalDir},
+		{"log", logPath},
+		{"cache", cache},
+	} {
+		fmt.Printf("%-12s %s\n", p[0], p[1])
+	}
+	return nil
+}
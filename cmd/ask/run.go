@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/scbenet/ask/internal/config"
+	"github.com/scbenet/ask/internal/workflow"
+	"github.com/scbenet/ask/pkg/ask"
+)
+
+// runWorkflow implements `ask run [-q] file`, sending the prompts of a
+// workflow file one after the other
+func runWorkflow(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to config file (default ~/.config/ask/config.json)")
+	quiet := fs.Bool("q", false, "don't print the responses, only write the step outputs")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ask run [-q] [-config path] workflow.yaml")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one workflow file")
+	}
+	w, err := workflow.Load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	path := *configPath
+	if path == "" {
+		if path, err = config.DefaultPath(); err != nil {
+			return err
+		}
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	// requests are logged like in the TUI, not between the responses
+	logFile, err := startLog(cfg.Log, false)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	// the requests go through the same providers, model settings, redaction
+	// and budgets as the TUI's
+	opts := []ask.Option{ask.WithConfig(path), ask.WithSystemPrompt(w.System)}
+	if w.Model != "" {
+		opts = append(opts, ask.WithModel(w.Model))
+	}
+	chat, err := ask.New(opts...)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	var out io.Writer = os.Stdout
+	if *quiet {
+		out = io.Discard
+	}
+	return w.Run(ctx, chat, out, os.Stderr)
+}
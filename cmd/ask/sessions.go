@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/scbenet/ask/internal/session"
+)
+
+// runSessions implements `ask sessions`, listing and searching stored
+// sessions, and its subcommands managing them
+func runSessions(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "rm":
+			return removeSessions(args[1:])
+		case "pin", "unpin", "archive", "unarchive":
+			return markSessions(args[0], args[1:])
+		}
+	}
+
+	fs := flag.NewFlagSet("sessions", flag.ExitOnError)
+	archived := fs.Bool("archived", false, "list the archived sessions instead")
+	all := fs.Bool("all", false, "list archived sessions too")
+	tags := fs.String("tag", "", "only list sessions with these comma separated tags")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: ask sessions [-archived|-all] [-tag t,...] [search]\n       ask sessions pin|unpin|archive|unarchive <id>...\n       ask sessions rm [-f] [-y] [-archived] [-match search] [id...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	store, err := openSessionStore()
+	if err != nil {
+		return err
+	}
+	q := session.Query{Text: strings.Join(fs.Args(), " "), Archived: *archived, All: *all}
+	if *tags != "" {
+		q.Tags = strings.Split(*tags, ",")
+	}
+	sessions, err := store.Find(q)
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		fmt.Println(sessionLine(s))
+	}
+	return nil
+}
+
+// sessionLine is how a session is listed, pinned ones are starred and tags
+// follow the number of messages
+func sessionLine(s *session.Session) string {
+	mark := " "
+	if s.Pinned {
+		mark = "*"
+	}
+	line := fmt.Sprintf("%s %s  %-60s  %d messages", mark, s.ID, s.Title, len(s.Messages))
+	if len(s.Tags) > 0 {
+		line += ", #" + strings.Join(s.Tags, " #")
+	}
+	if s.Archived {
+		line += ", archived"
+	}
+	return line
+}
+
+var done = map[string]string{"pin": "pinned", "unpin": "unpinned", "archive": "archived", "unarchive": "unarchived"}
+
+// markSessions implements `ask sessions pin|unpin|archive|unarchive`
+func markSessions(action string, ids []string) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("usage: ask sessions %s <id>...", action)
+	}
+	store, err := openSessionStore()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		sess, err := store.Load(id)
+		if err != nil {
+			return err
+		}
+		switch action {
+		case "pin", "unpin":
+			sess.Pinned = action == "pin"
+		case "archive", "unarchive":
+			sess.Archived = action == "archive"
+		}
+		if err := store.Save(sess); err != nil {
+			return err
+		}
+		fmt.Printf("%s session %s\n", done[action], id)
+	}
+	return nil
+}
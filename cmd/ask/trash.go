@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/scbenet/ask/internal/session"
+)
+
+// runTrash implements `ask trash`, listing, restoring and emptying deleted
+// sessions and messages
+func runTrash(args []string) error {
+	store, err := openSessionStore()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		return listTrash(store)
+	case "restore":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: ask trash restore <id>... (see ask trash)")
+		}
+		for _, id := range args[1:] {
+			entry, err := store.Restore(id)
+			if err != nil {
+				return err
+			}
+			if entry.WholeSession() {
+				fmt.Printf("restored session %s\n", entry.Session.ID)
+			} else {
+				fmt.Printf("restored %d message(s) to session %s\n", len(entry.Session.Messages), entry.Session.ID)
+			}
+		}
+		return nil
+	case "empty":
+		n, err := store.PurgeTrash(0)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("permanently deleted %d trash entries, run ask gc to free their attachments\n", n)
+		return nil
+	default:
+		return fmt.Errorf("unknown trash command %q, expected list, restore or empty", args[0])
+	}
+}
+
+func listTrash(store *session.Store) error {
+	trash, err := store.Trash()
+	if err != nil {
+		return err
+	}
+	if len(trash) == 0 {
+		fmt.Println("the trash is empty")
+		return nil
+	}
+	retention := trashRetention()
+	for _, entry := range trash {
+		what := "session"
+		if !entry.WholeSession() {
+			what = fmt.Sprintf("%d message(s) from", len(entry.Session.Messages))
+		}
+		expires := ""
+		if retention > 0 {
+			left := time.Until(entry.Deleted.Add(retention))
+			expires = fmt.Sprintf(", purged in %d day(s)", max(int(left.Hours()/24), 0))
+		}
+		fmt.Printf("%s  %s %s %q, deleted %s%s\n", entry.ID, what, entry.Session.ID, entry.Session.Title,
+			entry.Deleted.Format("2006-01-02 15:04"), expires)
+	}
+	return nil
+}
+
+// trashRetention returns how long the config keeps trash for
+func trashRetention() time.Duration {
+	// on errors the defaults are good enough to show when entries expire
+	cfg, _ := loadConfig("")
+	return cfg.TrashRetention.Std()
+}
+
+// removeSessions implements `ask sessions rm`, moving sessions to the trash.
+// -archived and -match delete every session they select, after asking.
+// locked sessions need -f
+func removeSessions(args []string) error {
+	fs := flag.NewFlagSet("sessions rm", flag.ExitOnError)
+	force := fs.Bool("f", false, "also delete locked sessions")
+	yes := fs.Bool("y", false, "don't ask before deleting the sessions -archived or -match select")
+	archived := fs.Bool("archived", false, "delete the archived sessions")
+	match := fs.String("match", "", "delete the sessions this search lists in ask sessions")
+	fs.Parse(args)
+	bulk := *archived || *match != ""
+	if fs.NArg() == 0 && !bulk {
+		return fmt.Errorf("usage: ask sessions rm [-f] [-y] [-archived] [-match search] [id...]")
+	}
+
+	store, err := openSessionStore()
+	if err != nil {
+		return err
+	}
+	var sessions []*session.Session
+	named := map[string]bool{}
+	for _, id := range fs.Args() {
+		sess, err := store.Load(id)
+		if err != nil {
+			return err
+		}
+		if sess.Locked && !*force {
+			return fmt.Errorf("session %s is locked, pass -f to delete it anyway", id)
+		}
+		sessions = append(sessions, sess)
+		named[id] = true
+	}
+	if bulk {
+		found, err := store.Find(session.Query{Text: *match, Archived: *archived})
+		if err != nil {
+			return err
+		}
+		var selected []*session.Session
+		for _, sess := range found {
+			if named[sess.ID] {
+				continue
+			}
+			if sess.Locked && !*force {
+				fmt.Printf("skipping locked session %s, pass -f to delete it too\n", sess.ID)
+				continue
+			}
+			selected = append(selected, sess)
+		}
+		if len(selected) == 0 {
+			fmt.Println("no sessions to delete")
+			return nil
+		}
+		if !*yes {
+			for _, sess := range selected {
+				fmt.Println(sessionLine(sess))
+			}
+			if !confirm(fmt.Sprintf("move these %d session(s) to the trash?", len(selected))) {
+				return nil
+			}
+		}
+		sessions = append(sessions, selected...)
+	}
+
+	for _, sess := range sessions {
+		if err := store.Delete(sess.ID); err != nil {
+			return err
+		}
+		fmt.Printf("moved session %s to the trash, ask trash restore brings it back\n", sess.ID)
+	}
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal, anything but yes is no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/attach"
+	"github.com/scbenet/ask/internal/files"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/ui"
+)
+
+const (
+	// maxDirFiles bounds how many files /add-dir looks at
+	maxDirFiles = 5000
+	// maxDirFileSize leaves out files too big to be useful context, they're
+	// usually generated
+	maxDirFileSize = 100 << 10
+	// maxDirTokens is how much /add-dir attaches in all, the files after
+	// that are left out
+	maxDirTokens = 100_000
+)
+
+// dirReadMsg carries the text files readDir read to attach
+type dirReadMsg struct {
+	dir   string
+	atts  []attach.Attachment
+	items []ui.ContextItem
+	total int
+	// what was left out, and why
+	tooBig, binary, overBudget int
+	errs                       []string
+	err                        error
+}
+
+// addDir attaches the text files under a directory to the next prompt,
+// skipping what .gitignore and .askignore ignore, and shows what was
+// attached and what it costs. the files are read outside Update, big trees
+// take a while
+func (a *App) addDir(args []string) tea.Cmd {
+	if len(args) > 1 {
+		a.chat.AddError(i18n.T("usage: /add-dir [directory]"))
+		return nil
+	}
+	dir := "."
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		a.chat.AddError(i18n.Tf("/add-dir: %s is not a directory", dir))
+		return nil
+	}
+
+	pending := map[string]bool{}
+	for _, p := range a.pendingAttachments {
+		pending[p.Name] = true
+	}
+	return func() tea.Msg { return readDir(dir, pending) }
+}
+
+// readDir reads the text files under dir that aren't pending already, up to
+// maxDirTokens in all
+func readDir(dir string, pending map[string]bool) dirReadMsg {
+	m := dirReadMsg{dir: dir}
+	paths, err := files.List(dir, maxDirFiles)
+	if err != nil {
+		m.err = err
+		return m
+	}
+	for _, rel := range paths {
+		name := filepath.Join(dir, filepath.FromSlash(rel))
+		if pending[name] {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			m.errs = append(m.errs, err.Error())
+			continue
+		}
+		if info.Size() > maxDirFileSize {
+			m.tooBig++
+			continue
+		}
+		att, err := attach.FromFile(name)
+		if errors.Is(err, attach.ErrBinary) {
+			m.binary++
+			continue
+		} else if err != nil {
+			m.errs = append(m.errs, err.Error())
+			continue
+		}
+		tokens := llm.EstimateTokens(att.Format())
+		if m.total+tokens > maxDirTokens {
+			m.overBudget++
+			continue
+		}
+		m.total += tokens
+		m.atts = append(m.atts, att)
+		m.items = append(m.items, ui.ContextItem{Name: name, Tokens: tokens})
+	}
+	return m
+}
+
+// attachDir attaches the files readDir read and shows what they cost
+func (a *App) attachDir(m dirReadMsg) {
+	for _, err := range m.errs {
+		a.chat.AddError(err)
+	}
+	if m.err != nil {
+		a.chat.AddError(i18n.Tf("/add-dir: %v", m.err))
+		return
+	}
+	// files attached while the directory was read aren't attached twice
+	pending := map[string]bool{}
+	for _, p := range a.pendingAttachments {
+		pending[p.Name] = true
+	}
+	var items []ui.ContextItem
+	total := 0
+	for i, att := range m.atts {
+		if pending[att.Name] {
+			continue
+		}
+		a.pendingAttachments = append(a.pendingAttachments, att)
+		items = append(items, m.items[i])
+		total += m.items[i].Tokens
+	}
+	if len(items) == 0 {
+		a.chat.AddError(i18n.Tf("/add-dir: no text files to attach in %s", m.dir))
+		return
+	}
+
+	footer := i18n.Tf("%d file(s), ~%s tokens in all", len(items), formatTokens(total))
+	if cost, ok := a.promptCost(total); ok {
+		footer += i18n.Tf(", ~%s per message", formatCost(cost))
+	}
+	var skipped []string
+	if m.tooBig > 0 {
+		skipped = append(skipped, i18n.Tf("%d over %dKB", m.tooBig, maxDirFileSize>>10))
+	}
+	if m.binary > 0 {
+		skipped = append(skipped, i18n.Tf("%d binary", m.binary))
+	}
+	if m.overBudget > 0 {
+		skipped = append(skipped, i18n.Tf("%d past the %s token limit", m.overBudget, formatTokens(maxDirTokens)))
+	}
+	if len(skipped) > 0 {
+		footer += i18n.Tf("; left out %s", strings.Join(skipped, ", "))
+	}
+	a.chat.AddContextSummary(i18n.Tf("attached from %s, sent with the next message", m.dir), items, footer)
+}
+
+// promptCost is what sending tokens more costs with the current model, if
+// its pricing is known
+func (a *App) promptCost(tokens int) (float64, bool) {
+	info, ok := a.modelInfo[a.selectedModel]
+	if !ok || info.PromptPrice == 0 {
+		return 0, false
+	}
+	return float64(tokens) * info.PromptPrice / 1_000_000, true
+}
@@ -3,15 +3,41 @@ package app
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/attach"
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/config"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/diagram"
+	"github.com/scbenet/ask/internal/edits"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/journal"
+	"github.com/scbenet/ask/internal/jsonschema"
+	"github.com/scbenet/ask/internal/links"
 	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/logging"
+	"github.com/scbenet/ask/internal/memory"
+	"github.com/scbenet/ask/internal/netcheck"
+	"github.com/scbenet/ask/internal/providers"
+	"github.com/scbenet/ask/internal/session"
+	"github.com/scbenet/ask/internal/spend"
+	"github.com/scbenet/ask/internal/tools"
 	"github.com/scbenet/ask/internal/ui"
+	"github.com/scbenet/ask/internal/ui/compare"
+	"github.com/scbenet/ask/internal/ui/contextview"
+	"github.com/scbenet/ask/internal/ui/fanout"
+	"github.com/scbenet/ask/internal/ui/linkpicker"
+	"github.com/scbenet/ask/internal/ui/memoryview"
 	"github.com/scbenet/ask/internal/ui/modelpicker"
+	"github.com/scbenet/ask/internal/ui/personapicker"
 	// "github.com/charmbracelet/bubbles/filepicker"
 )
 
@@ -21,6 +47,12 @@ type viewState int
 const (
 	chatView viewState = iota
 	modelPickerView
+	personaPickerView
+	memoryView
+	compareView
+	linkPickerView
+	contextView
+	fanoutView
 	// filePickerView
 )
 
@@ -31,24 +63,218 @@ type App struct {
 	activeView  viewState
 	chat        *ui.Chat
 	modelPicker *modelpicker.Model
+	personas    *personapicker.Model
+	memoryView  *memoryview.Model
+	contextView *contextview.Model
+	compare     *compare.Model
+	linkPicker  *linkpicker.Model // while picking, see openLink
+	fanoutView  *fanout.Model     // while a fan-out is shown, see startFanout
 	// filePicker filepicker.Model
 	llmClient llm.LLMClient
 	helpF     *help.Model
 
 	// State
-	selectedModel       string
-	conversationHistory []llm.Message
-	streamChan          chan tea.Msg
+	selectedModel string
+	// turnModel answers the current prompt, the selected model unless it
+	// was downgraded
+	turnModel string
+	// turnEffort raises the reasoning effort of the current prompt above
+	// the configured one, see thinkHarder
+	turnEffort   string
+	conversation *conversation.Conversation
+	streamChan   chan tea.Msg
+	streamID     int // see newStream
+	// id of the latest request of the turn, see requestContext
+	requestID string
+	// when the response last showed signs of life, and whether the user
+	// was asked about it going quiet. see watchStall
+	lastActivity time.Time
+	stalled      bool
+	// context of the prompt being answered, see newTurn
+	turnCtx    context.Context
+	cancelTurn context.CancelFunc
+	// the last prompt sent to the compared models, see streamCompare
+	compareRun *compareRun
+	compareID  int
+	// compare runs replaced while some of their responses were still
+	// streaming, read until they're done. see closeCompare
+	closedCompares map[int]*compareRun
+	// the prompt /fanout sent, nil unless its responses are shown
+	fanout   *fanoutRun
+	fanoutID int
+	// fan-outs closed while some of their responses were still streaming,
+	// by id. read until the streams end, see drainFanout
+	closedFanouts map[int]*fanoutRun
+	// context attached to the next prompt (e.g. piped stdin)
+	pendingAttachments []attach.Attachment
+	// patchMode asks for diffs against the attached files, see /patch
+	patchMode bool
+	// attached files that couldn't be read again, pointed out only once
+	unreadableAttachments map[string]bool
+
+	// prompt waiting out the send delay, see holdPrompt
+	pendingSend *pendingSend
+	sendCount   int
+	// prompts sent while a response was streaming, oldest first
+	queuedPrompts []string
+	// turns the next prompt is kept for, 0 unless /ephemeral was used
+	nextEphemeral int
+	// the response /regenerate replaced, for /diff. regenerating while the
+	// new one streams, replaced is put back if it fails or is interrupted
+	previousResponse string
+	regenerating     bool
+	replaced         *conversation.Message
+	replacedAt       int
+
+	// context sizes and pricing by model id, for cost estimates
+	modelInfo map[string]llm.ModelInfo
+	// openrouter is polled for the remaining credits, nil when not checked
+	openRouter *llm.OpenRouterClient
+	// token estimate of the conversation, recomputed when it changes
+	costCache struct {
+		key    costKey
+		valid  bool
+		tokens int
+	}
+
+	// what responses cost per day, for the daily budget. nil if unknown
+	spend *spend.Ledger
+	// record of the prompts and responses, nil unless enabled
+	journal *journal.Journal
+	// the kitty keyboard protocol, nil when it isn't used
+	keyboard *ui.KittyKeyboard
+	// spent in incognito, counted for the day but never written down
+	unrecordedSpend float64
+
+	// the provider couldn't be reached, sending waits until it can
+	offline bool
+	// where each provider's requests go, see providerURL
+	providerURLs map[string]string
+	// providers that leave some settings out, by the same names
+	paramCheckers map[string]llm.ParamChecker
+	// checks a provider can be reached, see checkConnectivity
+	reachable func(ctx context.Context, url string) error
+
+	// yes/no question waiting for an answer, see confirm
+	pendingConfirm *confirmation
+	// responses are asked to match it, nil unless /json set one
+	jsonSchema *jsonschema.Schema
+	// the stream carries on from the last response, see continueResponse
+	continuing bool
+	// bytes of the response being continued that are already in the
+	// journal, see writeJournal
+	journaled int
+	// times the response streaming was continued without asking, see
+	// autoContinue
+	autoContinued int
+	// settings set with /params, over the config's for every model
+	paramOverrides config.ModelConfig
+	// the app only replays a saved session, see Options.Replay
+	replayOnly bool
+
+	config config.Config
+	// listing of provider models, run once at startup
+	modelFetches []tea.Cmd
+
+	// runs tool calls, nil when tools are disabled
+	tools *tools.Executor
+	// tool call rounds since the last prompt, see maxToolRounds
+	toolRounds int
+
+	// persistence, store is nil if sessions can't be saved
+	store   *session.Store
+	session *session.Session
+	// facts remembered across sessions, nil unless memory is enabled
+	memories *memory.Store
+	// memories extracted from a finished conversation, waiting for approval
+	proposedMemories []string
+	// set once quitting waits for memory extraction
+	quitting bool
+	// memory extractions still waiting for the model
+	extracting int
+	// where logs go outside incognito mode
+	logOutput io.Writer
+	// what the last recovery snapshot held, zero when there is none
+	autosaved autosaveKey
+	// snapshots of crashed conversations, offered once at startup
+	recovered []session.Recovery
+	// dates saves and autosaves
+	clock clock.Clock
 
 	// keybindings
 	quitKey        key.Binding
 	modelPickerKey key.Binding
+	personaKey     key.Binding
+	openLinkKey    key.Binding
+	clearKey       key.Binding
+	editorKey      key.Binding
+	thinkKey       key.Binding
+	continueKey    key.Binding
 	lastError      error
 }
 
-func New() *App {
+// Options configures a new App
+type Options struct {
+	// Attachments are added as context to the first prompt
+	Attachments []attach.Attachment
+	// Store is where the conversation is saved, may be nil
+	Store *session.Store
+	// Session resumes a previously saved conversation when set
+	Session *session.Session
+	// Config is the loaded user configuration
+	Config config.Config
+	// Memories are added to every request, nil when memory is disabled
+	Memories *memory.Store
+	// Incognito starts a conversation that is never saved
+	Incognito bool
+	// Recovered are snapshots left by an ask that didn't exit cleanly,
+	// restoring them is offered at startup
+	Recovered []session.Recovery
+	// Clock is the system clock when nil
+	Clock clock.Clock
+	// Spend records what responses cost per day, for the daily budget. may
+	// be nil
+	Spend *spend.Ledger
+	// Journal gets every prompt and response, nil when it's off
+	Journal *journal.Journal
+	// Client answers instead of the configured providers when set, e.g. an
+	// llm.MockLLMClient in tests. the middleware still applies
+	Client llm.LLMClient
+	// ChatOptions configure the chat view, e.g. to render plain text
+	ChatOptions []ui.Option
+	// Keyboard turns on the kitty keyboard protocol, nil leaves the
+	// terminal's keyboard as it is
+	Keyboard *ui.KittyKeyboard
+	// Replay steps through Session a message at a time instead of resuming
+	// it, nothing can be sent and leaving the replay quits
+	Replay bool
+}
+
+func New(opts Options) *App {
+	if opts.Clock == nil {
+		opts.Clock = clock.System
+	}
 	// init chat view
-	chatModel := ui.New(80, 24)
+	chatOpts := []ui.Option{ui.WithClock(opts.Clock), ui.WithMath(opts.Config.Math), ui.WithTimestamps(opts.Config.Timestamps), ui.WithSendKey(opts.Config.SendKey), ui.WithFileMentions(listMentionFiles), messageStyles(opts.Config.Messages)}
+	chatModel := ui.New(80, 24, append(chatOpts, opts.ChatOptions...)...)
+
+	sess := opts.Session
+	if sess == nil {
+		sess = session.New("")
+		sess.Incognito = opts.Incognito
+	}
+	conv := conversation.New(sess.Messages)
+	chatModel.LoadHistory(conv.Messages)
+	chatModel.SetLocked(sess.Locked)
+	if _, ok := opts.Config.Persona(sess.Persona); ok {
+		chatModel.SetPersona(sess.Persona)
+	} else if sess.Persona != "" {
+		logging.Infof("persona %q of session %s is no longer configured", sess.Persona, sess.ID)
+	}
+
+	for _, a := range opts.Attachments {
+		chatModel.AddAttachment(a.Summary())
+	}
 
 	// TODO move this to a config file or something
 	availableModels := []string{
@@ -63,65 +289,166 @@ func New() *App {
 		"anthropic/claude-3.7-sonnet:thinking",
 	}
 
-	mp := modelpicker.New(availableModels)
-
 	// --- File Picker Setup (Keep placeholder) ---
 	//fp := filepicker.New()
 	//fp.CurrentDirectory = "."
 
 	// --- LLM Client Setup ---
-	llmSvc, err := llm.NewOpenRouterClient()
-	if err != nil {
-		log.Printf("Error initializing openrouter client: %v", err)
+	clients := providers.Open(opts.Config, logging.Logger{})
+	var modelFetches []tea.Cmd
+	var creditsClient *llm.OpenRouterClient
+	paramCheckers := map[string]llm.ParamChecker{}
+	if openRouter := clients.OpenRouter; openRouter != nil {
+		modelFetches = append(modelFetches, fetchModelInfo(openRouter))
+		if opts.Config.CreditWarning > 0 {
+			creditsClient = openRouter
+			modelFetches = append(modelFetches, fetchCredits(openRouter))
+		}
+	} else {
+		availableModels = nil
+	}
+	if clients.Gemini != nil {
+		paramCheckers["gemini"] = clients.Gemini
+		availableModels = append(availableModels, "gemini:gemini-2.5-flash", "gemini:gemini-2.5-pro")
+	}
+	for i, p := range opts.Config.Providers {
+		if len(p.Models) > 0 {
+			availableModels = append(availableModels, prefixModels(p.Name, p.Models)...)
+		} else {
+			modelFetches = append(modelFetches, fetchProviderModels(p.Name, clients.Compatible[i]))
+		}
+		paramCheckers[p.Name] = clients.Compatible[i]
+	}
+	providerURLs := clients.URLs
+
+	if len(availableModels) == 0 && !clients.Configured() {
+		fmt.Println("no provider configured, set OPENROUTER_API_KEY or GOOGLE_API_KEY or add providers to the config")
 		os.Exit(1)
 	}
 
-	defaultModel := availableModels[0]
+	mp := modelpicker.New(availableModels)
+	// picked once the provider's models are listed if nothing else is available
+	var defaultModel string
+	if len(availableModels) > 0 {
+		defaultModel = availableModels[0]
+	}
+
+	var base llm.LLMClient = clients.Router
+	if opts.Client != nil {
+		base = opts.Client
+	}
+	client := llm.Chain(base, providers.Middleware(opts.Config, opts.Spend, opts.Clock, logging.Logger{})...)
+
+	var executor *tools.Executor
+	if opts.Config.Tools.Enabled {
+		registry := tools.NewRegistry()
+		tools.RegisterDefaults(registry, opts.Config.Tools)
+		if opts.Memories != nil {
+			tools.RegisterMemory(registry, opts.Memories)
+		}
+		executor = tools.NewExecutor(registry, opts.Config.Tools)
+	}
 
-	return &App{
+	a := &App{
 		activeView:  chatView,
 		chat:        chatModel,
 		modelPicker: mp,
+		personas:    personapicker.New(opts.Config.Personas),
+		memoryView:  memoryview.New(),
+		contextView: contextview.New(),
 		// filePicker:    fp,
-		llmClient:           llmSvc,
-		conversationHistory: []llm.Message{},
-		selectedModel:       defaultModel,
+		llmClient:             client,
+		conversation:          conv,
+		selectedModel:         defaultModel,
+		pendingAttachments:    opts.Attachments,
+		unreadableAttachments: map[string]bool{},
+		store:                 opts.Store,
+		session:               sess,
+		memories:              opts.Memories,
+		config:                opts.Config,
+		modelFetches:          modelFetches,
+		openRouter:            creditsClient,
+		tools:                 executor,
+		recovered:             opts.Recovered,
+		clock:                 opts.Clock,
+		spend:                 opts.Spend,
+		journal:               opts.Journal,
+		keyboard:              opts.Keyboard,
+		providerURLs:          providerURLs,
+		paramCheckers:         paramCheckers,
+		reachable:             netcheck.Dial,
+		turnCtx:               context.Background(),
+		cancelTurn:            func() {},
 		quitKey: key.NewBinding(
 			key.WithKeys("ctrl+c"),
-			key.WithHelp("ctrl+c", "quit"),
+			key.WithHelp("ctrl+c", i18n.T("quit")),
 		),
 		modelPickerKey: key.NewBinding(
 			key.WithKeys("ctrl+k"),
-			key.WithHelp("ctrl+k", "models"),
+			key.WithHelp("ctrl+k", i18n.T("models")),
+		),
+		personaKey: key.NewBinding(
+			key.WithKeys("ctrl+g"),
+			key.WithHelp("ctrl+g", i18n.T("personas")),
+		),
+		clearKey: key.NewBinding(
+			key.WithKeys("ctrl+l"),
+			key.WithHelp("ctrl+l", i18n.T("clear")),
+		),
+		editorKey: key.NewBinding(
+			key.WithKeys("ctrl+e"),
+			key.WithHelp("ctrl+e", i18n.T("editor")),
+		),
+		thinkKey: key.NewBinding(
+			key.WithKeys("alt+t"),
+			key.WithHelp("alt+t", i18n.T("think harder")),
+		),
+		continueKey: key.NewBinding(
+			key.WithKeys("alt+m"),
+			key.WithHelp("alt+m", i18n.T("continue")),
+		),
+		openLinkKey: key.NewBinding(
+			key.WithKeys("alt+1", "alt+2", "alt+3", "alt+4", "alt+5", "alt+6", "alt+7", "alt+8", "alt+9"),
+			key.WithHelp("alt+1-9", i18n.T("open link")),
 		),
 		// filePickerKey: key.NewBinding(
 		// 	key.WithKeys("ctrl+f"),
 		// 	key.WithHelp("ctrl+f", "context"),
 		// ),
 	}
+	a.logOutput = log.Writer()
+	if sess.Incognito {
+		a.applyIncognito()
+	}
+	if opts.Replay {
+		a.replayOnly = true
+		a.chat.StartReplay(conv.Messages)
+	} else {
+		a.markOpen()
+	}
+	return a
 }
 
 func (a *App) Init() tea.Cmd {
-	return a.chat.Init()
+	a.offerRecovery(a.recovered)
+	a.recovered = nil
+	return tea.Batch(a.chat.Init(), a.keyboard.Enable(), tea.Batch(a.modelFetches...), a.scheduleAutosave(), a.checkConnectivity(false))
 	// return tea.Batch(a.chat.Init(), a.filePicker.Init())
 }
 
-// helper function to create a command that listens to our stream channel
-func listenToStream(ch chan tea.Msg) tea.Cmd {
-	return func() tea.Msg {
-		msg, ok := <-ch
-		if !ok {
-			// channel has been closed by the sender
-			// this implies the stream has ended (either with StreamEndMsg or StreamErrorMsg)
-			return nil
-		}
-		return msg
-	}
-}
-
 // Update function handles messages for the entire application
 // delegates messages to the active view or handles global actions
 func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	model, cmd := a.update(msg)
+	// inline, what the update added to the history goes to the scrollback
+	// before anything else happens, quitting included
+	if print := a.chat.PrintHistory(); print != nil {
+		cmd = tea.Sequence(print, cmd)
+	}
+	return model, cmd
+}
+
+func (a *App) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
 	switch m := msg.(type) {
@@ -136,6 +463,26 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		pickerModel, pickerCmd := a.modelPicker.Update(msg)
 		a.modelPicker = pickerModel.(*modelpicker.Model)
 		cmds = append(cmds, pickerCmd)
+		personaModel, personaCmd := a.personas.Update(msg)
+		a.personas = personaModel.(*personapicker.Model)
+		cmds = append(cmds, personaCmd)
+		memoryModel, memoryCmd := a.memoryView.Update(msg)
+		a.memoryView = memoryModel.(*memoryview.Model)
+		cmds = append(cmds, memoryCmd)
+		contextModel, contextCmd := a.contextView.Update(msg)
+		a.contextView = contextModel.(*contextview.Model)
+		cmds = append(cmds, contextCmd)
+		if a.compare != nil {
+			compareModel, compareCmd := a.compare.Update(msg)
+			a.compare = compareModel.(*compare.Model)
+			cmds = append(cmds, compareCmd)
+		}
+		if a.linkPicker != nil {
+			a.linkPicker.Update(msg)
+		}
+		if a.fanoutView != nil {
+			a.fanoutView.Update(msg)
+		}
 
 		// Send resize to file picker
 		// fpModel, fpCmd := a.filePicker.Update(msg)
@@ -146,6 +493,40 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch a.activeView {
 		case chatView:
+			if a.pendingSend != nil && m.String() == "esc" {
+				a.cancelPendingSend()
+				return a, nil
+			}
+			if a.chat.InCopyMode() || a.chat.Replaying() {
+				// copy mode and replays take every key, ctrl+c only leaves them
+				chatModel, chatCmd := a.chat.Update(m)
+				a.chat = chatModel.(*ui.Chat)
+				return a, chatCmd
+			}
+			if key.Matches(m, a.editorKey) && a.pendingConfirm == nil {
+				return a, a.openEditor()
+			}
+			if key.Matches(m, a.thinkKey) && a.pendingConfirm == nil {
+				return a, a.thinkHarder()
+			}
+			if key.Matches(m, a.continueKey) && a.pendingConfirm == nil {
+				return a, a.continueResponse()
+			}
+			if a.pendingConfirm != nil {
+				if a.quitting && key.Matches(m, a.quitKey) {
+					return a, tea.Quit
+				}
+				return a, a.answerConfirm(m)
+			}
+			if a.stalled && a.streamChan != nil {
+				if cmd, answered := a.answerStall(m); answered {
+					return a, cmd
+				}
+			}
+			if a.streamChan != nil && m.String() == "esc" {
+				a.cancelResponse()
+				return a, nil
+			}
 			chatInputContainedText := a.chat.GetInputValue() != ""
 			chatModel, chatCmd := a.chat.Update(m)
 			a.chat = chatModel.(*ui.Chat)
@@ -163,13 +544,31 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					log.Println("model picker key pressed during active stream, ignoring for now")
 				} else {
 					a.activeView = modelPickerView
-					a.modelPicker.SetTitle(fmt.Sprintf("Select a model (current: %s)", a.selectedModel))
+					a.modelPicker.SetTitle(i18n.Tf("Select a model (current: %s)", a.selectedModel))
 					return a, nil
 				}
 
+			} else if key.Matches(m, a.personaKey) {
+				// the persona applies from the next request, but switching
+				// mid stream would make it unclear which one answered
+				if a.streamChan != nil {
+					log.Println("persona key pressed during active stream, ignoring")
+				} else {
+					a.activeView = personaPickerView
+					current := a.session.Persona
+					if current == "" {
+						current = i18n.T("none")
+					}
+					a.personas.SetTitle(i18n.Tf("Select a persona (current: %s)", current))
+					return a, nil
+				}
+			} else if key.Matches(m, a.clearKey) {
+				cmds = append(cmds, a.clearConversation())
+			} else if key.Matches(m, a.openLinkKey) {
+				a.openLink([]string{strings.TrimPrefix(m.String(), "alt+")}, false)
 			} else if isQuit {
 				log.Printf("App.Update: quitting... ")
-				return a, tea.Quit
+				return a, a.quit()
 			}
 
 		case modelPickerView:
@@ -182,6 +581,61 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			pickerModel, pickerCmd := a.modelPicker.Update(msg)
 			a.modelPicker = pickerModel.(*modelpicker.Model)
 			cmds = append(cmds, pickerCmd)
+
+		case personaPickerView:
+			if key.Matches(m, a.quitKey) {
+				a.activeView = chatView
+				return a, nil
+			}
+			personaModel, personaCmd := a.personas.Update(msg)
+			a.personas = personaModel.(*personapicker.Model)
+			cmds = append(cmds, personaCmd)
+
+		case memoryView:
+			if key.Matches(m, a.quitKey) {
+				a.activeView = chatView
+				return a, nil
+			}
+			memoryModel, memoryCmd := a.memoryView.Update(msg)
+			a.memoryView = memoryModel.(*memoryview.Model)
+			cmds = append(cmds, memoryCmd)
+
+		case contextView:
+			if key.Matches(m, a.quitKey) {
+				a.activeView = chatView
+				return a, nil
+			}
+			contextModel, contextCmd := a.contextView.Update(msg)
+			a.contextView = contextModel.(*contextview.Model)
+			cmds = append(cmds, contextCmd)
+
+		case compareView:
+			if key.Matches(m, a.quitKey) {
+				log.Println("App.Update: Ctrl+C in compareView, returning to chat view.")
+				a.activeView = chatView
+				return a, nil
+			}
+			compareModel, compareCmd := a.compare.Update(msg)
+			a.compare = compareModel.(*compare.Model)
+			cmds = append(cmds, compareCmd)
+
+		case linkPickerView:
+			if key.Matches(m, a.quitKey) {
+				a.closeLinkPicker()
+				return a, nil
+			}
+			pickerModel, pickerCmd := a.linkPicker.Update(msg)
+			a.linkPicker = pickerModel.(*linkpicker.Model)
+			cmds = append(cmds, pickerCmd)
+
+		case fanoutView:
+			if key.Matches(m, a.quitKey) {
+				a.closeFanout()
+				return a, nil
+			}
+			fanoutModel, fanoutCmd := a.fanoutView.Update(msg)
+			a.fanoutView = fanoutModel.(*fanout.Model)
+			cmds = append(cmds, fanoutCmd)
 		}
 
 	// --- handle other message types ---
@@ -189,79 +643,341 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		log.Printf("ModelSelectedMsg received: %s", m.Model)
 		a.selectedModel = m.Model
 		a.activeView = chatView
+		if settings := a.describeModelConfig(m.Model); settings != "" {
+			a.chat.AddNotice(i18n.Tf("using %s with %s", m.Model, settings))
+		}
+		a.warnUnsupportedParams(m.Model)
 
 	// TODO send this event from model picker on cancel key press
 	case modelpicker.PickerCancelledMsg:
 		log.Printf("PickerCancelledMsg received")
 		a.activeView = chatView
 
+	case personapicker.PersonaSelectedMsg:
+		a.activeView = chatView
+		a.setPersona(m.Persona.Name)
+
+	case personapicker.PickerCancelledMsg:
+		a.activeView = chatView
+
+	case linkpicker.SelectedMsg:
+		a.closeLinkPicker()
+		a.openURL(m.URL, m.Copy)
+
+	case linkpicker.PickerCancelledMsg:
+		a.closeLinkPicker()
+
+	case memoryview.DeleteMsg:
+		if err := a.memories.Delete(m.ID); err != nil {
+			logging.Errorf("deleting memory %s: %v", m.ID, err)
+		}
+		a.memoryView.SetMemories(a.memories.List())
+
+	case memoryview.EditMsg:
+		if err := a.memories.Update(m.ID, m.Text); err != nil {
+			logging.Errorf("updating memory %s: %v", m.ID, err)
+		}
+		a.memoryView.SetMemories(a.memories.List())
+
+	case memoryview.ClosedMsg:
+		a.activeView = chatView
+
+	case contextview.ToggleMsg:
+		a.toggleContextItem(m.Ref)
+
+	case contextview.RemoveMsg:
+		a.removeContextItem(m.Ref)
+
+	case contextview.MoveMsg:
+		a.moveContextItem(m.Ref, m.Delta)
+
+	case contextview.ClosedMsg:
+		a.activeView = chatView
+
+	case editorClosedMsg:
+		a.handleEditorClosed(m)
+
+	case memoriesExtractedMsg:
+		return a, a.handleExtractedMemories(m)
+
+	case ui.FileMentionedMsg:
+		a.attachMentioned(m.Path)
+
+	case ui.ReplayEndedMsg:
+		if a.replayOnly {
+			return a, tea.Quit
+		}
+
 	case ui.SendPromptMsg:
-		// prevent multiple concurrent streams
-		if a.streamChan != nil {
-			log.Println("SendPromptMsg received while a stream is already active, ignoring...")
+		if a.refuseLocked("send a message") {
+			a.chat.RetractPrompt(m.Prompt)
+			return a, nil
+		}
+		if a.offline {
+			a.chat.RetractPrompt(m.Prompt)
+			a.chat.AddError(i18n.T("offline, the message is kept until the provider can be reached"))
+			return a, nil
+		}
+		// one stream at a time, anything sent meanwhile waits its turn
+		if a.streamChan != nil || a.pendingSend != nil {
+			a.queuePrompt(m.Prompt)
+			return a, nil
+		}
+		if reason := a.overBudget(m.Prompt); reason != "" {
+			prompt := m.Prompt
+			a.confirmOverBudget(reason, func() tea.Cmd { return a.sendOrHold(prompt) }, func() tea.Cmd {
+				a.chat.RetractPrompt(prompt)
+				return nil
+			})
 			return a, nil
 		}
-		a.chat.SetSending(true)
-		log.Printf("SetSending: true")
-		prompt := m.Prompt
-		model := a.selectedModel
-		log.Printf("Prompt: %s\nModel: %s", prompt, model)
+		cmds = append(cmds, a.sendOrHold(m.Prompt))
 
-		a.conversationHistory = append(a.conversationHistory, llm.Message{
-			Role:    "user",
-			Content: prompt,
-		})
-		historyCopy := make([]llm.Message, len(a.conversationHistory))
-		copy(historyCopy, a.conversationHistory)
-		log.Printf("History length for stream: %d", len(historyCopy))
+	case sendDelayElapsedMsg:
+		if a.pendingSend != nil && a.pendingSend.id == m.id {
+			prompt := a.pendingSend.prompt
+			a.pendingSend = nil
+			a.chat.SetPendingSend(0)
+			cmds = append(cmds, a.sendPrompt(prompt))
+		}
 
-		a.streamChan = make(chan tea.Msg) // create new channel for this stream
-		go a.llmClient.StreamGenerate(context.Background(), model, historyCopy, a.streamChan)
-		cmds = append(cmds, listenToStream(a.streamChan)) // start listening
+	case streamMsg:
+		return a.handleStreamMsg(m)
 
-	case llm.StreamChunkMsg:
-		log.Printf("StreamChunkMsg received in app")
-		if a.activeView == chatView {
-			// pass chunk to chat for rendering
-			chatModel, chatCmd := a.chat.Update(m)
-			a.chat = chatModel.(*ui.Chat)
-			cmds = append(cmds, chatCmd)
+	case stallCheckMsg:
+		cmds = append(cmds, a.checkStall(m))
+
+	case llm.StreamKeepaliveMsg:
+		// the provider is working on it, thinking models can be quiet
+		// for a long time
+		a.streamActive()
+
+	case llm.RateLimitedMsg:
+		// waiting its turn, not stalled
+		a.streamActive()
+		if m.Wait > 0 {
+			a.chat.AddNotice(i18n.Tf("rate limited, sending in %s", m.Wait.Round(time.Second)))
+		} else {
+			a.chat.AddNotice(i18n.T("rate limited, sending once another request finishes"))
 		}
-		// continue listening for more chunks
-		if a.streamChan != nil {
-			cmds = append(cmds, listenToStream(a.streamChan))
+
+	case llm.StreamRetryMsg:
+		a.streamActive()
+		logging.Infof("retrying stream (%d/%d, resumed %t): %v", m.Attempt, m.Attempts, m.Resumed, m.Err)
+		if m.Resumed {
+			a.chat.AddNotice(i18n.Tf("the response was cut off (%v), resuming (%d/%d)…", m.Err, m.Attempt, m.Attempts))
+		} else {
+			a.chat.AddNotice(i18n.Tf("the response failed (%v), trying again (%d/%d)…", m.Err, m.Attempt, m.Attempts))
+			// it starts over, what was streamed so far goes
+			cmds = append(cmds, a.chat.SetSending(true))
+			if a.continuing {
+				a.chat.ResumeResponse(a.conversation.Messages[a.conversation.Len()-1].Content)
+			}
+		}
+
+	case downgradedMsg:
+		a.turnModel = m.model
+		a.announceDowngrade(m.model)
+
+	case toolApprovalMsg:
+		a.approveTool(m)
+
+	case toolResultMsg:
+		a.recordSpend(m.summaryModel, m.summaryUsage)
+		a.addToolResult(m.result)
+
+	case toolsDoneMsg:
+		// let the model continue with the results
+		cmds = append(cmds, a.sendToolResults())
+
+	case compare.SendMsg:
+		cmds = append(cmds, a.sendCompare(m.Prompt))
+
+	case compare.PaneMsg:
+		cmds = append(cmds, a.handleComparePane(m))
+
+	case compare.ExitMsg:
+		a.activeView = chatView
+
+	case fanout.PaneMsg:
+		cmds = append(cmds, a.handleFanoutPane(m))
+
+	case fanout.KeepMsg:
+		a.keepFanout(m)
+
+	case fanout.JudgeMsg:
+		cmds = append(cmds, a.judgeFanout())
+
+	case judgedMsg:
+		a.handleJudged(m)
+
+	case fanout.ClosedMsg:
+		a.closeFanout()
+		a.chat.AddNotice(i18n.T("fan-out discarded, nothing was added to the conversation"))
+
+	case connectivityMsg:
+		cmds = append(cmds, a.handleConnectivity(m))
+
+	case connectivityTickMsg:
+		cmds = append(cmds, a.checkConnectivity(true))
+
+	case ui.RetryMsg:
+		cmds = append(cmds, a.retryFailed())
+
+	case ui.CommandMsg:
+		cmds = append(cmds, a.handleCommand(m))
+
+	case providerModelsMsg:
+		a.addProviderModels(m)
+
+	case modelInfoMsg:
+		if m.err != nil {
+			// the picker just shows less, not worth interrupting for
+			logging.Errorf("fetching model metadata: %v", m.err)
+			break
 		}
+		a.modelPicker.SetInfo(m.models)
+		a.addModelInfo(m.models)
+
+	case creditsMsg:
+		cmds = append(cmds, a.handleCredits(m))
+
+	case ShutdownMsg:
+		return a, a.shutdown()
+
+	case autosaveMsg:
+		cmds = append(cmds, a.autosave())
+
+	case creditsTickMsg:
+		cmds = append(cmds, fetchCredits(a.openRouter))
+
+	case dirReadMsg:
+		a.attachDir(m)
+
+	case mermaidRenderedMsg:
+		if m.err != nil {
+			a.chat.AddError(i18n.Tf("failed to render diagram: %v", m.err))
+			break
+		}
+		cmds = append(cmds, a.showImage(m.path))
+
+	case llm.StreamChunkMsg:
+		log.Printf("StreamChunkMsg received in app")
+		a.streamActive()
+		// the chat keeps up with the response even while another view is
+		// open, or it would be missing from the history
+		chatModel, chatCmd := a.chat.Update(m)
+		a.chat = chatModel.(*ui.Chat)
+		cmds = append(cmds, chatCmd)
 
 	case llm.StreamEndMsg:
 		log.Printf("StreamEndMsg received in app, full response length: %d", len(m.FullResponse))
+		// done streaming, won't need this anymore
+		a.endStream()
+		// tool calls are only acted on if tools were offered in the first place
+		callingTools := a.tools != nil && len(m.ToolCalls) > 0
+		usage := m.Usage
+		if a.continuing {
+			m.FullResponse, m.Sources, usage = a.stitchContinuation(m)
+		}
 		// add complete response to conversation history
-		a.conversationHistory = append(a.conversationHistory, llm.Message{
-			Role:    "assistant",
-			Content: m.FullResponse,
-		})
-		if a.activeView == chatView {
-			responseDoneMsg := ui.StreamEndMsg{FullResponse: m.FullResponse}
-			chatModel, chatCmd := a.chat.Update(responseDoneMsg)
+		reply := conversation.Message{
+			Role:       "assistant",
+			Content:    m.FullResponse,
+			Model:      a.turnModel,
+			Effort:     a.turnEffort,
+			Usage:      usage,
+			Sources:    m.Sources,
+			RequestID:  m.RequestID,
+			ProviderID: m.ProviderID,
+			Truncated:  truncation(m.FinishReason),
+		}
+		if callingTools {
+			reply.ToolCalls = m.ToolCalls
+		}
+		a.conversation.Add(reply)
+		a.recordSpend(a.turnModel, m.Usage)
+		a.saveSession()
+		if cmd := a.autoContinue(reply); cmd != nil {
+			cmds = append(cmds, cmd)
+			break
+		}
+		if callingTools {
+			chatModel, chatCmd := a.chat.Update(ui.StreamEndMsg{FullResponse: m.FullResponse, Sources: m.Sources, Model: a.turnModel, Effort: a.turnEffort, Usage: usage})
 			a.chat = chatModel.(*ui.Chat)
-			cmds = append(cmds, chatCmd)
-			a.chat.SetSending(false)
+			cmds = append(cmds, chatCmd, a.runTools(m.ToolCalls))
+			if a.streamChan == nil {
+				// gave up on tools, nothing more is coming
+				a.chat.SetSending(false)
+				cmds = append(cmds, a.sendQueued())
+			}
+			break
 		}
-		// done streaming, won't need this anymore
-		a.streamChan = nil
+		shown := m.FullResponse
+		var problems []string
+		if a.jsonSchema != nil {
+			shown, problems = a.structuredResponse(m.FullResponse)
+		}
+		responseDoneMsg := ui.StreamEndMsg{FullResponse: shown, Sources: m.Sources, Model: a.turnModel, Effort: a.turnEffort, Usage: usage}
+		chatModel, chatCmd := a.chat.Update(responseDoneMsg)
+		a.chat = chatModel.(*ui.Chat)
+		cmds = append(cmds, chatCmd)
+		a.chat.SetSending(false)
+		if a.autoContinued > 0 {
+			a.chat.AddNotice(i18n.Tf("the response ran into the max tokens, continued it %d time(s)", a.autoContinued))
+			a.autoContinued = 0
+		}
+		if reply.Truncated != "" {
+			a.chat.AddWarning(truncationBanner(reply.Truncated))
+		}
+		if a.jsonSchema != nil {
+			a.reportStructured(problems)
+		}
+		if n := len(links.Extract(m.FullResponse)); n > 0 {
+			a.chat.AddNotice(i18n.Tf("%d link(s) in response, /links to list, alt+n to open", n))
+		}
+		if n := len(diagram.ExtractMermaid(m.FullResponse)); n > 0 {
+			a.chat.AddNotice(i18n.Tf("response contains %d mermaid diagram(s), /mermaid [n] to render", n))
+		}
+		if a.patchMode {
+			a.checkPatch(m.FullResponse)
+		} else if n := len(edits.Extract(m.FullResponse)); n > 0 {
+			a.chat.AddNotice(i18n.Tf("response edits %d file(s), /apply to review and write them", n))
+		}
+		a.writeJournal(m.FullResponse, a.turnModel)
+		if a.regenerating {
+			a.regenerating, a.replaced = false, nil
+			if a.config.Judge.Auto {
+				cmds = append(cmds, a.judgeRegenerated())
+			} else {
+				a.chat.AddNotice(i18n.T("/diff shows what changed from the previous response, /judge scores both"))
+			}
+		}
+		cmds = append(cmds, a.sendQueued())
 
 	case llm.StreamErrorMsg:
 		a.lastError = m.Err
-		log.Printf("StreamErrorMsg received in app: %v", m.Err)
-		errMsg := fmt.Sprintf("assistant stream error: %s", m.Err.Error())
+		if a.continuing {
+			// the response stays as it was, cut off
+			cmds = append(cmds, a.endContinuation())
+		}
+		logging.Errorf("[%s] stream failed: %v", m.RequestID, m.Err)
+		// a failed /regenerate leaves the response it was replacing, the
+		// prompt has an answer
+		if !a.restoreReplaced() {
+			a.markLastPromptFailed(m.Err, m.RequestID)
+		}
 		// display error in chat view
-		errorReply := ui.StreamErrorMsg{Err: errMsg}
-		if a.activeView == chatView {
-			chatModel, chatCmd := a.chat.Update(errorReply) // Send error to chat
-			a.chat = chatModel.(*ui.Chat)
-			cmds = append(cmds, chatCmd)
-			a.chat.SetSending(false) // Signal sending is done (due to error)
+		errorReply := ui.StreamErrorMsg{Report: errorReport(m.Err, a.turnModel, m.RequestID)}
+		chatModel, chatCmd := a.chat.Update(errorReply) // Send error to chat
+		a.chat = chatModel.(*ui.Chat)
+		cmds = append(cmds, chatCmd)
+		a.chat.SetSending(false) // Signal sending is done (due to error)
+		a.endStream()
+		a.restoreQueued("of the error")
+		if netcheck.IsNetworkError(m.Err) {
+			cmds = append(cmds, a.checkConnectivity(false))
 		}
-		a.streamChan = nil
 
 	// non-streaming response message
 	case ui.LLMReplyMsg:
@@ -278,14 +994,16 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// non-streaming response error message
 	case llm.GenerationErrorMsg:
 		a.lastError = m.Err
-		// TODO: Display this error nicely, maybe append to chat history
-		log.Printf("LLMError received: %s", a.lastError)
-		errMsg := fmt.Sprintf("Assistant Error: %s", m.Err.Error())
-		errorReply := ui.LLMReplyMsg{Content: errMsg} // Send as a reply
+		logging.Errorf("request failed: %s", a.lastError)
+		a.markLastPromptFailed(m.Err, "")
+		errorReply := ui.StreamErrorMsg{Report: errorReport(m.Err, a.turnModel, "")}
 		chatModel, chatCmd := a.chat.Update(errorReply)
 		a.chat = chatModel.(*ui.Chat)
 		cmds = append(cmds, chatCmd)
 		a.chat.SetSending(false)
+		if netcheck.IsNetworkError(m.Err) {
+			cmds = append(cmds, a.checkConnectivity(false))
+		}
 
 	default:
 		switch a.activeView {
@@ -297,11 +1015,74 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			pickerModel, pickerCmd := a.modelPicker.Update(msg)
 			a.modelPicker = pickerModel.(*modelpicker.Model)
 			cmds = append(cmds, pickerCmd)
+		case personaPickerView:
+			personaModel, personaCmd := a.personas.Update(msg)
+			a.personas = personaModel.(*personapicker.Model)
+			cmds = append(cmds, personaCmd)
+		case memoryView:
+			memoryModel, memoryCmd := a.memoryView.Update(msg)
+			a.memoryView = memoryModel.(*memoryview.Model)
+			cmds = append(cmds, memoryCmd)
+		case contextView:
+			contextModel, contextCmd := a.contextView.Update(msg)
+			a.contextView = contextModel.(*contextview.Model)
+			cmds = append(cmds, contextCmd)
+		case compareView:
+			compareModel, compareCmd := a.compare.Update(msg)
+			a.compare = compareModel.(*compare.Model)
+			cmds = append(cmds, compareCmd)
+		case linkPickerView:
+			pickerModel, pickerCmd := a.linkPicker.Update(msg)
+			a.linkPicker = pickerModel.(*linkpicker.Model)
+			cmds = append(cmds, pickerCmd)
+		case fanoutView:
+			fanoutModel, fanoutCmd := a.fanoutView.Update(msg)
+			a.fanoutView = fanoutModel.(*fanout.Model)
+			cmds = append(cmds, fanoutCmd)
 		}
 	}
+	if a.activeView == chatView {
+		a.updateCostEstimate()
+	}
 	return a, tea.Batch(cmds...)
 }
 
+// messageStyles is the chat option for the messages config
+func messageStyles(cfg config.MessagesConfig) ui.Option {
+	user := ui.MessageStyle{Markdown: cfg.User.Markdown, Prefix: "> ", Label: cfg.User.Label}
+	if cfg.User.Prefix != nil {
+		user.Prefix = *cfg.User.Prefix
+	}
+	return ui.WithMessageStyles(user, ui.MessageStyle{Markdown: cfg.Assistant.Markdown, Label: cfg.Assistant.Label})
+}
+
+// markLastPromptFailed flags the unanswered prompt so it isn't resent as
+// part of the history on the next request
+func (a *App) markLastPromptFailed(err error, requestID string) {
+	n := a.conversation.Len()
+	if n == 0 || a.conversation.Messages[n-1].Role != "user" {
+		return
+	}
+	a.conversation.Messages[n-1].Error = err.Error()
+	a.conversation.Messages[n-1].RequestID = requestID
+	a.saveSession()
+}
+
+// saveSession persists the current conversation, failures are logged but
+// otherwise ignored so a broken store never interrupts chatting
+func (a *App) saveSession() {
+	if a.store == nil || a.session.Incognito {
+		return
+	}
+	a.session.Messages = a.conversation.Messages
+	a.session.Updated = a.clock.Now()
+	if err := a.store.Save(a.session); err != nil {
+		logging.Errorf("saving session %s: %v", a.session.ID, err)
+		return
+	}
+	a.clearRecovery()
+}
+
 // View renders the view for the currently active model.
 func (a *App) View() string {
 	switch a.activeView {
@@ -309,10 +1090,22 @@ func (a *App) View() string {
 		return a.chat.View()
 	case modelPickerView:
 		return a.modelPicker.View()
+	case personaPickerView:
+		return a.personas.View()
+	case memoryView:
+		return a.memoryView.View()
+	case contextView:
+		return a.contextView.View()
+	case compareView:
+		return a.compare.View()
+	case linkPickerView:
+		return a.linkPicker.View()
+	case fanoutView:
+		return a.fanoutView.View()
 	// case contextPickerView:
 	// 	return a.contextPicker.View()
 	default:
-		log.Printf("Error: Unknown view state in View(): %v", a.activeView)
+		logging.Errorf("unknown view state in View(): %v", a.activeView)
 		return "Unknown view state" // Should not happen
 	}
 
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/edits"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// fileChange is an edit worked out against the file as it is now
+type fileChange struct {
+	path     string
+	old, new string
+	delete   bool
+}
+
+// applyEdits writes the files the last response has code for, after
+// showing what changes in them. /apply alone takes the only edit or lists
+// them, /apply n the nth and /apply all every one
+func (a *App) applyEdits(args []string) tea.Cmd {
+	response, _ := a.lastAssistantMessage()
+	found := edits.Extract(response)
+	if len(found) == 0 {
+		a.chat.AddError(i18n.T("no file edits in the last response, code blocks need the file's path in their info string or the line above them"))
+		return nil
+	}
+
+	var selected []edits.Edit
+	switch {
+	case len(args) == 1 && args[0] == "all":
+		selected = found
+	case len(args) == 0 && len(found) > 1:
+		var b strings.Builder
+		b.WriteString(i18n.T("the last response edits these files, /apply n applies one and /apply all every one:"))
+		for i, e := range found {
+			kind := i18n.T("new contents")
+			if e.Diff != nil {
+				kind = i18n.T("diff")
+			}
+			fmt.Fprintf(&b, "\n%d. %s (%s)", i+1, e.Path, kind)
+		}
+		a.chat.AddNotice(b.String())
+		return nil
+	default:
+		idx, err := argIndex(args, len(found))
+		if err != nil {
+			a.chat.AddError(i18n.Tf("/apply: %v", err))
+			return nil
+		}
+		selected = found[idx : idx+1]
+	}
+
+	changes, errs := fileChanges(selected)
+	for _, err := range errs {
+		a.chat.AddError(i18n.Tf("/apply: %v", err))
+	}
+	a.offerChanges(changes)
+	return nil
+}
+
+// fileChanges works out what the edits make of the files, the edits that
+// don't apply are returned as errors
+func fileChanges(selected []edits.Edit) ([]fileChange, []error) {
+	var changes []fileChange
+	var errs []error
+	for _, e := range selected {
+		if err := edits.Confined(e.Path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		data, err := os.ReadFile(e.Path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		old := string(data)
+		new, err := e.Result(old)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if new == old && !e.Deletes() {
+			errs = append(errs, fmt.Errorf("%s is already up to date", e.Path))
+			continue
+		}
+		changes = append(changes, fileChange{path: e.Path, old: old, new: new, delete: e.Deletes()})
+	}
+	return changes, errs
+}
+
+// offerChanges shows the diffs of changes and asks before writing them
+func (a *App) offerChanges(changes []fileChange) {
+	if len(changes) == 0 {
+		return
+	}
+	var writes, deletes []string
+	for _, c := range changes {
+		a.chat.AddFileDiff(c.path, c.old, c.new)
+		if c.delete {
+			deletes = append(deletes, c.path)
+		} else {
+			writes = append(writes, c.path)
+		}
+	}
+	var question []string
+	if len(writes) > 0 {
+		question = append(question, i18n.Tf("write %s", strings.Join(writes, ", ")))
+	}
+	if len(deletes) > 0 {
+		question = append(question, i18n.Tf("delete %s", strings.Join(deletes, ", ")))
+	}
+	a.confirm(strings.Join(question, i18n.T(" and "))+"?", func() tea.Cmd {
+		for _, c := range changes {
+			if err := writeChange(c); err != nil {
+				a.chat.AddError(err.Error())
+				continue
+			}
+			if c.delete {
+				a.chat.AddNotice(i18n.Tf("deleted %s", c.path))
+			} else {
+				a.chat.AddNotice(i18n.Tf("wrote %s", c.path))
+			}
+		}
+		return nil
+	})
+}
+
+// writeChange writes a file, creating its directory, keeping the
+// permissions of a file that's already there. it refuses files outside
+// the working directory
+func writeChange(c fileChange) error {
+	if err := edits.Confined(c.path); err != nil {
+		return err
+	}
+	if c.delete {
+		if err := os.Remove(c.path); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", c.path, err)
+		}
+		return nil
+	}
+	return writeFile(c.path, c.new)
+}
+
+// writeFile writes content to path, creating its directory and keeping the
+// permissions of a file that's already there
+func writeFile(path, content string) error {
+	perm := fs.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create the directory of %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
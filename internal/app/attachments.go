@@ -0,0 +1,45 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// refreshAttachments reads the files attached to the next prompt again just
+// before it's sent, so they go out as they are now rather than as they were
+// when attached. the files that changed are pointed out, the ones that can't
+// be read anymore are sent as they were. messages already sent keep what
+// they were sent with
+func (a *App) refreshAttachments() {
+	if a.session.Locked {
+		return
+	}
+	var changed, unreadable []string
+	for i := range a.pendingAttachments {
+		att := &a.pendingAttachments[i]
+		if att.Excluded {
+			continue
+		}
+		ok, err := att.Refresh()
+		switch {
+		case err != nil:
+			if !a.unreadableAttachments[att.Path] {
+				a.unreadableAttachments[att.Path] = true
+				logging.Errorf("re-reading attachment %s: %v", att.Path, err)
+				unreadable = append(unreadable, att.Name)
+			}
+		case ok:
+			delete(a.unreadableAttachments, att.Path)
+			changed = append(changed, att.Name)
+		}
+	}
+
+	if len(changed) > 0 {
+		a.chat.AddNotice(i18n.Tf("📎 %s changed since attached, sending the current contents", strings.Join(changed, ", ")))
+	}
+	if len(unreadable) > 0 {
+		a.chat.AddNotice(i18n.Tf("📎 can't read %s anymore, sending the contents attached earlier", strings.Join(unreadable, ", ")))
+	}
+}
@@ -0,0 +1,163 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/logging"
+	"github.com/scbenet/ask/internal/session"
+)
+
+// interruptedError marks a response that was cut off by a crash
+const interruptedError = "interrupted, ask exited before the response was finished"
+
+// autosaveMsg snapshots the conversation while a response streams
+type autosaveMsg struct{}
+
+// autosaveKey tells whether the conversation changed since the last snapshot
+type autosaveKey struct {
+	messages int
+	partial  int
+}
+
+func (a *App) scheduleAutosave() tea.Cmd {
+	interval := a.config.Autosave.Std()
+	if interval <= 0 || a.store == nil {
+		return nil
+	}
+	return tea.Tick(interval, func(time.Time) tea.Msg { return autosaveMsg{} })
+}
+
+// autosave writes a recovery snapshot of the conversation, with the response
+// streamed so far, if it changed since the last one. sessions are only saved
+// once a response is done, this covers a crash or kill in the meantime
+func (a *App) autosave() tea.Cmd {
+	if a.streamChan == nil || a.session.Incognito {
+		a.clearRecovery()
+		return a.scheduleAutosave()
+	}
+	partial := a.chat.PartialResponse()
+	key := autosaveKey{messages: a.conversation.Len(), partial: len(partial)}
+	if key == a.autosaved {
+		return a.scheduleAutosave()
+	}
+
+	snapshot := *a.session
+	snapshot.Messages = slices.Clone(a.conversation.Messages)
+	if partial != "" {
+		snapshot.Messages = append(snapshot.Messages, conversation.Message{
+			Role:      "assistant",
+			Content:   partial,
+			Model:     a.turnModel,
+			RequestID: a.requestID,
+			Timestamp: a.clock.Now(),
+			Error:     interruptedError,
+		})
+	}
+	snapshot.Updated = a.clock.Now()
+	if err := a.store.SaveRecovery(&snapshot); err != nil {
+		logging.Errorf("autosaving session %s: %v", a.session.ID, err)
+	} else {
+		a.autosaved = key
+	}
+	return a.scheduleAutosave()
+}
+
+// clearRecovery removes the snapshot once the conversation is saved properly
+func (a *App) clearRecovery() {
+	if a.autosaved == (autosaveKey{}) {
+		return
+	}
+	a.autosaved = autosaveKey{}
+	if err := a.store.ClearRecovery(); err != nil {
+		logging.Errorf("clearing recovery snapshot: %v", err)
+	}
+}
+
+// offerRecovery asks whether to restore conversations that were streaming
+// when an earlier ask crashed or was killed
+func (a *App) offerRecovery(recovered []session.Recovery) {
+	if len(recovered) == 0 {
+		return
+	}
+	latest := recovered[0].Session
+	title := latest.Title
+	if title == "" {
+		title = latest.ID
+	}
+	question := fmt.Sprintf("ask didn't exit cleanly, restore the conversation %q from %s?", title, latest.Updated.Format("Jan 2 15:04"))
+	if len(recovered) > 1 {
+		question = fmt.Sprintf("ask didn't exit cleanly, restore %d conversations? the latest, %q, is opened", len(recovered), title)
+	}
+	a.confirmOr(question, func() tea.Cmd {
+		a.restoreRecovered(recovered)
+		return nil
+	}, func() tea.Cmd {
+		a.discardRecovered(recovered)
+		return nil
+	})
+}
+
+// restoreRecovered saves the snapshots as sessions and opens the latest
+func (a *App) restoreRecovered(recovered []session.Recovery) {
+	for _, r := range recovered {
+		if err := a.store.Save(r.Session); err != nil {
+			a.chat.AddError(i18n.Tf("failed to restore session %s: %v", r.Session.ID, err))
+			return
+		}
+		if err := a.store.DiscardRecovery(r.PID); err != nil {
+			logging.Errorf("discarding restored recovery snapshot: %v", err)
+		}
+	}
+	a.openSession(recovered[0].Session)
+	a.chat.AddNotice(i18n.Tf("restored session %s", a.session.ID))
+	for _, r := range recovered[1:] {
+		a.chat.AddNotice(i18n.Tf("also restored session %s, resume it with ask -session %s", r.Session.ID, r.Session.ID))
+	}
+}
+
+func (a *App) discardRecovered(recovered []session.Recovery) {
+	for _, r := range recovered {
+		if err := a.store.DiscardRecovery(r.PID); err != nil {
+			logging.Errorf("discarding recovery snapshot: %v", err)
+		}
+	}
+}
+
+// openSession replaces the current conversation with sess, the current one
+// is saved first
+func (a *App) openSession(sess *session.Session) {
+	if a.conversation.Len() > 0 {
+		a.saveSession()
+	}
+	a.session = sess
+	a.markOpen()
+	a.conversation = conversation.New(sess.Messages)
+	a.chat.ClearHistory()
+	a.chat.LoadHistory(a.conversation.Messages)
+	a.chat.SetLocked(sess.Locked)
+	if _, ok := a.config.Persona(sess.Persona); ok {
+		a.chat.SetPersona(sess.Persona)
+	} else {
+		a.chat.SetPersona("")
+	}
+}
+
+// markOpen tells other asks which session this one has open, so they don't
+// restore messages from the trash into it, see session.Store.SetOpen
+func (a *App) markOpen() {
+	if a.store == nil {
+		return
+	}
+	id := a.session.ID
+	if a.session.Incognito {
+		id = ""
+	}
+	if err := a.store.SetOpen(id); err != nil {
+		logging.Errorf("marking the session open: %v", err)
+	}
+}
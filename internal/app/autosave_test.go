@@ -0,0 +1,60 @@
+package app
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scbenet/ask/internal/blob"
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/session"
+	"github.com/scbenet/ask/internal/ui"
+	"github.com/scbenet/ask/internal/vfs"
+)
+
+// TestAutosave snapshots a response while it streams and drops the
+// snapshot once the response is saved, without waiting for the timer
+func TestAutosave(t *testing.T) {
+	c := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	fsys := vfs.NewMem(c)
+	blobs, err := blob.NewStore("/data/blobs", blob.WithFS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := session.NewStore("/data/sessions", blobs, session.WithFS(fsys), session.WithClock(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hold := make(chan struct{})
+	client := &fakeClient{hold: map[string]chan struct{}{"one": hold}}
+	l := newLoop(t, store, client)
+	l.app.clock = c
+
+	l.send(ui.SendPromptMsg{Prompt: "one"})
+	l.until("the first chunk", func() bool { return l.app.chat.PartialResponse() == "re: " })
+	c.Advance(5 * time.Second)
+	l.send(autosaveMsg{})
+
+	snapshot := "/data/sessions/.recovery/" + strconv.Itoa(os.Getpid()) + ".json"
+	info, err := fsys.Stat(snapshot)
+	if err != nil {
+		t.Fatalf("no snapshot while streaming: %v", err)
+	}
+	if !info.ModTime().Equal(c.Now()) {
+		t.Fatalf("snapshot written at %s, want %s", info.ModTime(), c.Now())
+	}
+
+	close(hold)
+	l.until("the response", l.idle)
+	if _, err := fsys.Stat(snapshot); err == nil {
+		t.Fatal("snapshot left behind after the response was saved")
+	}
+	saved, err := store.Load(l.app.session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !saved.Updated.Equal(c.Now()) {
+		t.Fatalf("session updated at %s, want %s", saved.Updated, c.Now())
+	}
+}
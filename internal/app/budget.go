@@ -0,0 +1,111 @@
+package app
+
+import (
+	"cmp"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// usageCost is what a response of model with usage cost, as the provider
+// reported it or priced from the model's metadata. the prompt tokens read
+// from or written to the cache are priced as such when the model lists
+// cache prices. 0 when it can't be told
+func (a *App) usageCost(model string, usage *llm.Usage) float64 {
+	if usage == nil {
+		return 0
+	}
+	if usage.Cost > 0 {
+		return usage.Cost
+	}
+	info, ok := a.modelInfo[model]
+	if !ok {
+		return 0
+	}
+	// the cached tokens are part of the prompt tokens
+	uncached := usage.PromptTokens - usage.CacheReadTokens - usage.CacheWriteTokens
+	prompt := float64(uncached)*info.PromptPrice +
+		float64(usage.CacheReadTokens)*cmp.Or(info.CacheReadPrice, info.PromptPrice) +
+		float64(usage.CacheWriteTokens)*cmp.Or(info.CacheWritePrice, info.PromptPrice)
+	return (prompt + float64(usage.CompletionTokens)*info.CompletionPrice) / 1_000_000
+}
+
+// recordSpend adds what a response cost to the session and the day
+func (a *App) recordSpend(model string, usage *llm.Usage) {
+	cost := a.usageCost(model, usage)
+	if cost <= 0 {
+		return
+	}
+	a.session.Spent += cost
+	if a.session.Incognito || a.spend == nil {
+		// not even the amount is written down, it still counts for today
+		a.unrecordedSpend += cost
+		return
+	}
+	if err := a.spend.Add(cost); err != nil {
+		logging.Errorf("recording spend: %v", err)
+	}
+}
+
+// spentToday is what every conversation cost today, as far as is known
+func (a *App) spentToday() float64 {
+	spent := a.unrecordedSpend
+	if a.spend != nil {
+		today, err := a.spend.Today()
+		if err != nil {
+			logging.Errorf("reading spend: %v", err)
+		}
+		spent += today
+	}
+	return spent
+}
+
+// overBudget explains why sending prompt would go over a budget, or
+// returns "" if it fits or its cost is unknown
+func (a *App) overBudget(prompt string) string {
+	return a.overBudgetBy(a.estimateCost(prompt))
+}
+
+// overBudgetBy is overBudget for the requests estimated, e.g. one per model
+// of a fan-out. requests that can't be priced count as free
+func (a *App) overBudgetBy(estimates ...costEstimate) string {
+	budget := a.config.Budget
+	if !budget.Enabled() {
+		return ""
+	}
+	cost := 0.0
+	for _, e := range estimates {
+		if e.priced {
+			cost += e.cost
+		}
+	}
+	if cost == 0 {
+		return ""
+	}
+	if budget.Session > 0 && a.session.Spent+cost > budget.Session {
+		return i18n.Tf("this request (~%s) would take the conversation over its %s budget, %s is spent",
+			formatCost(cost), formatCost(budget.Session), formatCost(a.session.Spent))
+	}
+	if budget.Daily > 0 {
+		if today := a.spentToday(); today+cost > budget.Daily {
+			return i18n.Tf("this request (~%s) would go over the daily %s budget, %s is spent today",
+				formatCost(cost), formatCost(budget.Daily), formatCost(today))
+		}
+	}
+	return ""
+}
+
+// overBudgetNext is overBudget for the next request of the turn, a
+// continuation or the tool results, which adds nothing new to the history
+func (a *App) overBudgetNext() string {
+	return a.overBudgetBy(a.estimateRequest(a.turnModel, a.historyTokens()))
+}
+
+// confirmOverBudget asks before sending a prompt that would go over a
+// budget, send sends it anyway and refuse puts it back
+func (a *App) confirmOverBudget(reason string, send, refuse func() tea.Cmd) {
+	logging.Infof("over budget: %s", reason)
+	a.confirmOr(reason+i18n.T(", send anyway?"), send, refuse)
+}
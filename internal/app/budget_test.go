@@ -0,0 +1,219 @@
+package app
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/ui"
+)
+
+// TestBudget holds back a prompt over the session budget until it's
+// confirmed, and counts what the response cost
+func TestBudget(t *testing.T) {
+	l := newLoop(t, nil, &fakeClient{})
+	a := l.app
+	a.config.Budget.Session = 0.01
+	a.modelInfo = map[string]llm.ModelInfo{"test:m": {ContextLength: 1000, PromptPrice: 10, CompletionPrice: 10}}
+	a.session.Spent = 0.009
+
+	l.send(ui.SendPromptMsg{Prompt: "one"})
+	if a.pendingConfirm == nil || a.streamChan != nil {
+		t.Fatal("a prompt over the budget was sent without asking")
+	}
+	l.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if a.streamChan != nil || a.chat.GetInputValue() != "one" {
+		t.Fatalf("refusing sent the prompt or lost it, the input is %q", a.chat.GetInputValue())
+	}
+
+	l.send(ui.SendPromptMsg{Prompt: "one"})
+	l.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	l.until("the response", l.idle)
+	l.checkConversation("user: one", "assistant: re: one")
+
+	a.recordSpend("test:m", &llm.Usage{PromptTokens: 100, CompletionTokens: 100})
+	if math.Abs(a.session.Spent-0.011) > 1e-9 || math.Abs(a.spentToday()-0.002) > 1e-9 {
+		t.Fatalf("spent %v in the session and %v today", a.session.Spent, a.spentToday())
+	}
+}
+
+// TestUsageCost prices the prompt tokens read from the cache at the cache
+// price, or at the prompt price when the model lists none
+func TestUsageCost(t *testing.T) {
+	a := newLoop(t, nil, &fakeClient{}).app
+	a.modelInfo = map[string]llm.ModelInfo{
+		"test:m": {PromptPrice: 10, CompletionPrice: 20, CacheReadPrice: 1, CacheWritePrice: 12},
+		"test:n": {PromptPrice: 10, CompletionPrice: 20},
+	}
+	usage := &llm.Usage{PromptTokens: 1000, CompletionTokens: 100, CacheReadTokens: 800, CacheWriteTokens: 100}
+	for model, want := range map[string]float64{
+		"test:m": (100*10 + 800*1 + 100*12 + 100*20) / 1e6,
+		"test:n": (1000*10 + 100*20) / 1e6,
+	} {
+		if got := a.usageCost(model, usage); math.Abs(got-want) > 1e-12 {
+			t.Errorf("%s cost %v, want %v", model, got, want)
+		}
+	}
+}
+
+// TestBudgetFanout counts every model of a fan-out against the budget
+func TestBudgetFanout(t *testing.T) {
+	l := newLoop(t, nil, &fakeClient{})
+	a := l.app
+	a.config.Budget.Session = 0.01
+	a.config.Fanout = []string{"test:m", "test:n"}
+	price := llm.ModelInfo{ContextLength: 1000, PromptPrice: 10, CompletionPrice: 10}
+	a.modelInfo = map[string]llm.ModelInfo{"test:m": price, "test:n": price}
+	// one request fits, two don't
+	a.session.Spent = 0.01 - 1.5*a.estimateRequest("test:m", a.historyTokens()+llm.EstimateTokens("one")).cost
+
+	a.startFanout([]string{"one"})
+	if a.pendingConfirm == nil || a.fanout != nil {
+		t.Fatal("a fan-out over the budget was sent without asking")
+	}
+	l.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	if a.fanout == nil {
+		t.Fatal("confirming didn't fan out")
+	}
+	a.closeFanout()
+}
+
+// TestBudgetNextRequest asks before continuing a turn over the budget,
+// with /continue and with the results of tool calls
+func TestBudgetNextRequest(t *testing.T) {
+	l := newLoop(t, nil, &fakeClient{})
+	a := l.app
+	a.config.Budget.Session = 0.01
+	a.modelInfo = map[string]llm.ModelInfo{"test:m": {ContextLength: 1000, PromptPrice: 10, CompletionPrice: 10}}
+	a.session.Spent = 0.0099
+	a.conversation.Add(conversation.Message{Role: "user", Content: "one"})
+	a.conversation.Add(conversation.Message{Role: "assistant", Content: "re: ", Model: "test:m", Truncated: llm.FinishLength})
+
+	l.run(a.continueResponse())
+	if a.pendingConfirm == nil || a.streamChan != nil {
+		t.Fatal("continued over the budget without asking")
+	}
+	l.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if a.streamChan != nil || a.continuing {
+		t.Fatal("refusing continued the response")
+	}
+
+	// as if tool calls had just been answered
+	a.turnModel = "test:m"
+	a.newStream()
+	a.chat.SetSending(true)
+	l.send(streamMsg{id: a.streamID, msg: toolsDoneMsg{}})
+	if a.pendingConfirm == nil {
+		t.Fatal("sent the tool results over the budget without asking")
+	}
+	l.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if a.streamChan != nil {
+		t.Fatal("refusing didn't end the turn")
+	}
+}
+
+// finishingClient answers every stream once released, cancelled or not,
+// like a provider whose response was already done
+type finishingClient struct {
+	fakeClient
+	release chan struct{}
+}
+
+func (f *finishingClient) StreamGenerate(ctx context.Context, req llm.Request, msgChan chan<- tea.Msg) {
+	defer close(msgChan)
+	<-f.release
+	msgChan <- llm.StreamEndMsg{FullResponse: "done", Usage: &llm.Usage{Cost: 0.25}}
+}
+
+// TestBudgetClosedFanout pays for the responses of a discarded fan-out
+// that finished anyway
+func TestBudgetClosedFanout(t *testing.T) {
+	l := newLoop(t, nil, &fakeClient{})
+	a := l.app
+	client := &finishingClient{release: make(chan struct{})}
+	a.llmClient = client
+	a.config.Fanout = []string{"test:m", "test:n"}
+
+	l.run(a.startFanout([]string{"one"}))
+	a.closeFanout()
+	close(client.release)
+	l.until("the streams to end", func() bool { return len(a.closedFanouts) == 0 })
+	if a.session.Spent != 0.5 {
+		t.Fatalf("spent %v, want 0.5", a.session.Spent)
+	}
+}
+
+// TestBudgetClosedCompare pays for the responses of a comparison replaced
+// by one of other models, which don't show them
+func TestBudgetClosedCompare(t *testing.T) {
+	l := newLoop(t, nil, &fakeClient{})
+	a := l.app
+	client := &finishingClient{release: make(chan struct{})}
+	a.llmClient = client
+
+	l.run(a.startCompare([]string{"test:m", "test:n"}))
+	l.run(a.sendCompare("one"))
+	l.run(a.startCompare([]string{"test:m", "test:o"}))
+	close(client.release)
+	l.until("the streams to end", func() bool { return a.compareRun == nil && len(a.closedCompares) == 0 })
+	if a.session.Spent != 0.5 {
+		t.Fatalf("spent %v, want 0.5", a.session.Spent)
+	}
+	if history := a.compare.History(0); len(history) != 0 {
+		t.Fatalf("the new comparison shows %+v", history)
+	}
+}
+
+// TestHistoryTokensCache estimates the conversation again when its last
+// message is rewritten or the model changes, not only when one is added
+func TestHistoryTokensCache(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.conversation.Add(conversation.Message{Role: "user", Content: "hi"})
+	a.conversation.Add(conversation.Message{Role: "assistant", Content: "hello"})
+	before := a.historyTokens()
+
+	// a continuation grows the response in place
+	a.conversation.Messages[1].Content += strings.Repeat(" and more", 100)
+	if after := a.historyTokens(); after <= before {
+		t.Fatalf("estimate stayed at %d after the response grew", after)
+	}
+
+	a.selectedModel = "test:other"
+	a.historyTokens()
+	if a.costCache.key.model != "test:other" {
+		t.Fatalf("estimate is still cached for %s", a.costCache.key.model)
+	}
+}
+
+// TestSummarizeToolOutputSpend counts what summarizing a long tool output
+// cost, and doesn't summarize once the turn is cancelled
+func TestSummarizeToolOutputSpend(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.config.Tools.MaxOutput = 10
+	a.config.Tools.Truncate = "summarize"
+	summary := []tea.Msg{llm.StreamEndMsg{FullResponse: "short", Usage: &llm.Usage{Cost: 0.25}}}
+	a.llmClient = &llm.MockLLMClient{Streams: [][]tea.Msg{summary, summary}}
+	call := llm.ToolCall{ID: "call_1"}
+	call.Function.Name = "run_command"
+	output := llm.Message{Role: "tool", ToolCallID: "call_1", Content: strings.Repeat("long output ", 10)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	msg := a.shortenToolResult(ctx, call, output, "test:m", false)
+	if strings.Contains(msg.result.Content, "summarized") || msg.summaryUsage != nil {
+		t.Fatalf("summarized after the turn was cancelled: %q", msg.result.Content)
+	}
+
+	msg = a.shortenToolResult(context.Background(), call, output, "test:m", false)
+	if !strings.HasSuffix(msg.result.Content, "summarized]\nshort") {
+		t.Fatalf("summarized output is %q", msg.result.Content)
+	}
+	a.Update(msg)
+	if a.session.Spent != 0.25 || a.spentToday() != 0.25 {
+		t.Fatalf("spent %v in the session and %v today, want the summary's 0.25", a.session.Spent, a.spentToday())
+	}
+}
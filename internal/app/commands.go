@@ -0,0 +1,364 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/diagram"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/links"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/logging"
+	"github.com/scbenet/ask/internal/session"
+	"github.com/scbenet/ask/internal/sysopen"
+	"github.com/scbenet/ask/internal/ui"
+	"github.com/scbenet/ask/internal/ui/linkpicker"
+)
+
+// handleCommand dispatches a slash command typed into the chat input
+func (a *App) handleCommand(cmd ui.CommandMsg) tea.Cmd {
+	log.Printf("App.handleCommand: /%s %v", cmd.Name, cmd.Args)
+
+	switch cmd.Name {
+	case "mermaid":
+		return a.renderMermaid(cmd.Args)
+	case "fanout":
+		return a.startFanout(cmd.Args)
+	case "compare":
+		return a.startCompare(cmd.Args)
+	case "clear":
+		return a.clearConversation()
+	case "history":
+		a.listHistory()
+		return nil
+	case "undo":
+		return a.undoTurns(cmd.Args)
+	case "truncate":
+		return a.truncateAt(cmd.Args)
+	case "regenerate", "regen":
+		return a.regenerate()
+	case "think":
+		return a.thinkHarder()
+	case "diff":
+		a.diffRegenerated()
+		return nil
+	case "continue":
+		return a.continueResponse()
+	case "judge":
+		return a.judgeRegenerated()
+	case "links":
+		a.listLinks()
+		return nil
+	case "open":
+		a.openLink(cmd.Args, false)
+		return nil
+	case "copylink":
+		a.openLink(cmd.Args, true)
+		return nil
+	case "cite":
+		a.openCitation(cmd.Args)
+		return nil
+	case "output":
+		return a.showToolOutput(cmd.Args)
+	case "remember":
+		a.remember(cmd.Args)
+		return nil
+	case "memories":
+		a.showMemories()
+		return nil
+	case "ephemeral":
+		a.markEphemeral(cmd.Args)
+		return nil
+	case "timestamps":
+		a.toggleTimestamps()
+		return nil
+	case "lock":
+		a.toggleLock()
+		return nil
+	case "replay":
+		a.startReplay()
+		return nil
+	case "tag":
+		a.tagSession(cmd.Args)
+		return nil
+	case "untag":
+		a.untagSession(cmd.Args)
+		return nil
+	case "incognito":
+		return a.toggleIncognito()
+	case "add-dir":
+		return a.addDir(cmd.Args)
+	case "apply":
+		return a.applyEdits(cmd.Args)
+	case "savefile":
+		a.saveFile(cmd.Args)
+		return nil
+	case "patch":
+		a.togglePatchMode()
+		return nil
+	case "params":
+		a.setParams(cmd.Args)
+		return nil
+	case "json":
+		a.setJSONMode(cmd.Args)
+		return nil
+	case "context":
+		a.showContext()
+		return nil
+	default:
+		a.chat.AddError(i18n.Tf("unknown command /%s (start a message with // to send a literal slash)", cmd.Name))
+		return nil
+	}
+}
+
+// lastAssistantMessage returns the content of the most recent response
+func (a *App) lastAssistantMessage() (string, bool) {
+	if last := a.conversation.Last("assistant"); last != nil {
+		return last.Content, true
+	}
+	return "", false
+}
+
+// argIndex parses an optional 1-based index argument, defaulting to 1
+func argIndex(args []string, count int) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 || n > count {
+		return 0, fmt.Errorf("expected a number between 1 and %d, got %q", count, args[0])
+	}
+	return n - 1, nil
+}
+
+type mermaidRenderedMsg struct {
+	path string
+	err  error
+}
+
+// renderMermaid renders a mermaid diagram from the last response with
+// mermaid-cli, `/mermaid 2` picks the second diagram
+func (a *App) renderMermaid(args []string) tea.Cmd {
+	response, ok := a.lastAssistantMessage()
+	diagrams := diagram.ExtractMermaid(response)
+	if !ok || len(diagrams) == 0 {
+		a.chat.AddError(i18n.T("no mermaid diagram in the last response"))
+		return nil
+	}
+
+	idx, err := argIndex(args, len(diagrams))
+	if err != nil {
+		a.chat.AddError(i18n.Tf("/mermaid: %v", err))
+		return nil
+	}
+
+	a.chat.AddNotice(i18n.Tf("rendering mermaid diagram %d…", idx+1))
+	src := diagrams[idx]
+	return func() tea.Msg {
+		path, err := diagram.RenderMermaid(context.Background(), src)
+		return mermaidRenderedMsg{path: path, err: err}
+	}
+}
+
+// showImage displays a rendered diagram inline on terminals with kitty
+// graphics support, otherwise hands it to the system image viewer
+func (a *App) showImage(path string) tea.Cmd {
+	if diagram.KittyGraphics() {
+		return a.keyboard.ExecProcess(diagram.KittyShowCommand(path), func(err error) tea.Msg {
+			if err != nil {
+				logging.Errorf("showing image with kitty icat: %v", err)
+			}
+			return nil
+		})
+	}
+
+	if err := sysopen.Open(path); err != nil {
+		a.chat.AddError(err.Error())
+		return nil
+	}
+	a.chat.AddNotice(i18n.Tf("opened %s", path))
+	return nil
+}
+
+// lastResponseLinks returns the URLs in the last response
+func (a *App) lastResponseLinks() []string {
+	response, _ := a.lastAssistantMessage()
+	return links.Extract(response)
+}
+
+// listLinks prints the numbered links of the last response
+func (a *App) listLinks() {
+	urls := a.lastResponseLinks()
+	if len(urls) == 0 {
+		a.chat.AddNotice(i18n.T("no links in the last response"))
+		return
+	}
+
+	var b strings.Builder
+	for i, u := range urls {
+		fmt.Fprintf(&b, "[%d] %s\n", i+1, u)
+	}
+	b.WriteString("/open n or alt+n opens a link, /copylink n copies it, /open alone to pick one")
+	a.chat.AddNotice(b.String())
+}
+
+// openLink opens (or copies) the nth link of the last response, without
+// a number it lets the user pick one
+func (a *App) openLink(args []string, copyOnly bool) {
+	urls := a.lastResponseLinks()
+	if len(urls) == 0 {
+		a.chat.AddError(i18n.T("no links in the last response"))
+		return
+	}
+	if len(args) == 0 && len(urls) > 1 {
+		a.linkPicker = linkpicker.New(urls, a.width, a.height, copyOnly)
+		a.activeView = linkPickerView
+		return
+	}
+	idx, err := argIndex(args, len(urls))
+	if err != nil {
+		a.chat.AddError(err.Error())
+		return
+	}
+	a.openURL(urls[idx], copyOnly)
+}
+
+// closeLinkPicker goes back to the chat from the link picker
+func (a *App) closeLinkPicker() {
+	a.linkPicker = nil
+	a.activeView = chatView
+}
+
+// openURL opens u in the browser, or copies it
+func (a *App) openURL(u string, copyOnly bool) {
+	if copyOnly {
+		if err := clipboard.WriteAll(u); err != nil {
+			a.chat.AddError(i18n.Tf("failed to copy link: %v", err))
+			return
+		}
+		a.chat.AddNotice(i18n.T("copied ") + u)
+		return
+	}
+	if err := sysopen.Open(u); err != nil {
+		a.chat.AddError(err.Error())
+		return
+	}
+	a.chat.AddNotice(i18n.T("opened ") + u)
+}
+
+// toggleTimestamps shows or hides the times and models of the messages
+func (a *App) toggleTimestamps() {
+	on := !a.chat.Timestamps()
+	a.chat.SetTimestamps(on)
+	if on {
+		a.chat.AddNotice(i18n.T("showing when messages were sent and which model wrote them"))
+	} else {
+		a.chat.AddNotice(i18n.T("timestamps hidden"))
+	}
+}
+
+// openCitation opens the nth source cited by the last response, without a
+// number it lists them
+func (a *App) openCitation(args []string) {
+	var sources []llm.Source
+	if last := a.conversation.Last("assistant"); last != nil {
+		sources = last.Sources
+	}
+	if len(sources) == 0 {
+		a.chat.AddNotice(i18n.T("the last response didn't cite any sources"))
+		return
+	}
+	if len(args) == 0 {
+		var b strings.Builder
+		for i, s := range sources {
+			fmt.Fprintf(&b, "[%d] %s\n", i+1, s.URL)
+		}
+		b.WriteString("/cite n opens a source")
+		a.chat.AddNotice(b.String())
+		return
+	}
+	idx, err := argIndex(args, len(sources))
+	if err != nil {
+		a.chat.AddError(i18n.Tf("/cite: %v", err))
+		return
+	}
+	u := sources[idx].URL
+	if err := sysopen.Open(u); err != nil {
+		a.chat.AddError(err.Error())
+		return
+	}
+	a.chat.AddNotice(i18n.T("opened ") + u)
+}
+
+// clearConversation starts a fresh conversation. the old one is already saved
+// in the session store, so it stays available through `ask sessions`
+func (a *App) clearConversation() tea.Cmd {
+	if a.streamChan != nil || a.pendingSend != nil {
+		a.chat.AddError(i18n.T("wait for the current response to finish before clearing"))
+		return nil
+	}
+
+	previous := a.session
+	messages := a.conversation.Messages
+	extract := a.shouldExtractMemories(messages)
+	a.saveSession()
+	if previous.Incognito {
+		a.wipe()
+	}
+
+	a.session = session.New("")
+	// the persona and incognito mode stick around for the next conversation
+	a.session.Persona = previous.Persona
+	a.session.Incognito = previous.Incognito
+	a.markOpen()
+	a.conversation = conversation.New(nil)
+	a.pendingAttachments = nil
+	a.chat.ClearHistory()
+	a.chat.SetLocked(false)
+
+	if a.store != nil && len(previous.Messages) > 0 && !previous.Incognito {
+		a.chat.AddNotice(i18n.Tf("started a new conversation, the previous one was saved as %s", previous.ID))
+	}
+	if extract {
+		return a.extractMemories(messages)
+	}
+	return nil
+}
+
+// remember saves a fact to the memory store, /remember I use fish shell
+func (a *App) remember(args []string) {
+	if a.memories == nil {
+		a.chat.AddError(i18n.T(`memory is disabled, set "memory": {"enabled": true} in the config to use it`))
+		return
+	}
+	if len(args) == 0 {
+		a.chat.AddError(i18n.T("usage: /remember <fact>"))
+		return
+	}
+	m, err := a.memories.Add(strings.Join(args, " "), "user")
+	if err != nil {
+		a.chat.AddError(i18n.Tf("/remember: %v", err))
+		return
+	}
+	a.chat.AddNotice(i18n.T("remembered: ") + m.Text)
+}
+
+// showMemories opens the view for editing and deleting memories
+func (a *App) showMemories() {
+	if a.memories == nil {
+		a.chat.AddError(i18n.T(`memory is disabled, set "memory": {"enabled": true} in the config to use it`))
+		return
+	}
+	if len(a.memories.List()) == 0 {
+		a.chat.AddNotice(i18n.T("no memories yet, add one with /remember"))
+		return
+	}
+	a.memoryView.SetMemories(a.memories.List())
+	a.activeView = memoryView
+}
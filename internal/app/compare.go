@@ -0,0 +1,173 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/ui/compare"
+)
+
+// compareRun is a prompt streaming to both compared models
+type compareRun struct {
+	id     int
+	models [2]string
+	chans  [2]chan tea.Msg
+	cancel context.CancelFunc
+}
+
+// startCompare opens the compare view. `/compare b` compares the current
+// model against b, `/compare a b` compares two arbitrary models
+func (a *App) startCompare(args []string) tea.Cmd {
+	var modelA, modelB string
+	switch len(args) {
+	case 1:
+		modelA, modelB = a.selectedModel, args[0]
+	case 2:
+		modelA, modelB = args[0], args[1]
+	default:
+		a.chat.AddError(i18n.T("usage: /compare [model-a] model-b"))
+		return nil
+	}
+
+	if a.streamChan != nil {
+		a.chat.AddError(i18n.T("wait for the current response to finish before comparing"))
+		return nil
+	}
+
+	// keep the previous comparison around if the same models are compared again
+	if a.compare == nil || a.compare.Models() != [2]string{modelA, modelB} {
+		a.closeCompare()
+		a.compare = compare.New(modelA, modelB, a.chat.RenderMarkdown)
+	}
+	a.activeView = compareView
+
+	// the compare view was created after the last resize, size it now
+	compareModel, cmd := a.compare.Update(tea.WindowSizeMsg{Width: a.width, Height: a.height})
+	a.compare = compareModel.(*compare.Model)
+	return tea.Batch(cmd, a.compare.Init())
+}
+
+// sendCompare streams the same prompt to both compared models, asking
+// first if that would go over a budget
+func (a *App) sendCompare(prompt string) tea.Cmd {
+	if a.compare == nil {
+		return nil
+	}
+	var estimates []costEstimate
+	for i, model := range a.compare.Models() {
+		history := append(slices.Clip(a.compare.History(i)), llm.Message{Role: "user", Content: prompt})
+		estimates = append(estimates, a.estimateRequest(model, llm.EstimateMessageTokens(a.newRequest(model, history).Messages)))
+	}
+	reason := a.overBudgetBy(estimates...)
+	if reason == "" {
+		return a.streamCompare(prompt)
+	}
+	// the question is asked in the chat, the answer goes back to comparing
+	a.activeView = chatView
+	a.confirmOverBudget(reason, func() tea.Cmd {
+		a.activeView = compareView
+		return a.streamCompare(prompt)
+	}, func() tea.Cmd {
+		a.activeView = compareView
+		a.compare.SetInput(prompt)
+		return nil
+	})
+	return nil
+}
+
+// streamCompare streams the same prompt to both compared models concurrently
+func (a *App) streamCompare(prompt string) tea.Cmd {
+	a.compare.StartTurn(prompt)
+	ctx, cancel := context.WithCancel(context.Background())
+	a.compareID++
+	run := &compareRun{id: a.compareID, models: a.compare.Models(), cancel: cancel}
+	var cmds []tea.Cmd
+	for i, model := range run.models {
+		history := a.compare.History(i)
+		ch := make(chan tea.Msg)
+		run.chans[i] = ch
+		log.Printf("compare: streaming to pane %d, model %s", i, model)
+		go a.llmClient.StreamGenerate(llm.WithRequestID(ctx, llm.NewRequestID()), a.newRequest(model, history), ch)
+		cmds = append(cmds, listenToPane(ch, run.id, i))
+	}
+	a.compareRun = run
+	return tea.Batch(cmds...)
+}
+
+// handleComparePane passes a stream message on to the compare view,
+// listening for the next one until the stream ends
+func (a *App) handleComparePane(m compare.PaneMsg) tea.Cmd {
+	run := a.compareRun
+	if run == nil || m.ID != run.id {
+		return a.drainCompare(m)
+	}
+	compareModel, cmd := a.compare.Update(m)
+	a.compare = compareModel.(*compare.Model)
+	switch msg := m.Msg.(type) {
+	case llm.StreamEndMsg:
+		run.chans[m.Pane] = nil
+		a.recordSpend(run.models[m.Pane], msg.Usage)
+	case llm.StreamErrorMsg:
+		run.chans[m.Pane] = nil
+	default:
+		return tea.Batch(cmd, listenToPane(run.chans[m.Pane], run.id, m.Pane))
+	}
+	return cmd
+}
+
+// closeCompare cancels the responses of the compared models still
+// streaming, for when other models are compared
+func (a *App) closeCompare() {
+	run := a.compareRun
+	if run == nil {
+		return
+	}
+	run.cancel()
+	a.compareRun = nil
+	// the streams close their channels once they see the cancellation,
+	// until then they're read by drainCompare
+	if run.chans != [2]chan tea.Msg{} {
+		if a.closedCompares == nil {
+			a.closedCompares = map[int]*compareRun{}
+		}
+		a.closedCompares[run.id] = run
+	}
+}
+
+// drainCompare reads what the streams of a closed compare run still send,
+// a response that finished before it saw the cancellation is still paid for
+func (a *App) drainCompare(m compare.PaneMsg) tea.Cmd {
+	run, ok := a.closedCompares[m.ID]
+	if !ok {
+		return nil
+	}
+	switch msg := m.Msg.(type) {
+	case llm.StreamEndMsg:
+		a.recordSpend(run.models[m.Pane], msg.Usage)
+	case llm.StreamErrorMsg:
+	default:
+		return listenToPane(run.chans[m.Pane], run.id, m.Pane)
+	}
+	run.chans[m.Pane] = nil
+	if run.chans == [2]chan tea.Msg{} {
+		delete(a.closedCompares, run.id)
+	}
+	return nil
+}
+
+// listenToPane is listenToStream for a compare pane, wrapping each message so
+// the compare view knows which run and side it belongs to
+func listenToPane(ch chan tea.Msg, id, pane int) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-ch
+		if !ok {
+			return compare.PaneMsg{ID: id, Pane: pane, Msg: llm.StreamErrorMsg{Err: fmt.Errorf("stream closed unexpectedly")}}
+		}
+		return compare.PaneMsg{ID: id, Pane: pane, Msg: msg}
+	}
+}
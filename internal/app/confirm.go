@@ -0,0 +1,54 @@
+package app
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// the answers to a confirmation, for the help
+var (
+	yesKey = key.NewBinding(key.WithKeys("y"), key.WithHelp("y", "yes"))
+	noKey  = key.NewBinding(key.WithHelp("any key", "no"))
+)
+
+// confirmation is a pending yes/no question shown in the chat. the next key
+// press answers it: y confirms, anything else cancels
+type confirmation struct {
+	question string
+	onYes    func() tea.Cmd
+	onNo     func() tea.Cmd // may be nil
+}
+
+// confirm asks the user a yes/no question before running onYes
+func (a *App) confirm(question string, onYes func() tea.Cmd) {
+	a.confirmOr(question, onYes, nil)
+}
+
+// confirmOr is confirm with a callback for when the answer is no
+func (a *App) confirmOr(question string, onYes, onNo func() tea.Cmd) {
+	a.pendingConfirm = &confirmation{question: question, onYes: onYes, onNo: onNo}
+	a.chat.AddNotice(question + " (y/n)")
+	a.chat.SetChoices(yesKey, noKey)
+}
+
+// answerConfirm resolves the pending confirmation with the pressed key
+func (a *App) answerConfirm(msg tea.KeyMsg) tea.Cmd {
+	c := a.pendingConfirm
+	a.pendingConfirm = nil
+	a.chat.SetChoices()
+	var cmd tea.Cmd
+	if msg.String() == "y" || msg.String() == "Y" {
+		cmd = c.onYes()
+	} else {
+		a.chat.AddNotice(i18n.T("cancelled"))
+		if c.onNo != nil {
+			cmd = c.onNo()
+		}
+	}
+	// proposed memories wait for any other question to be answered first
+	if a.pendingConfirm == nil && (len(a.proposedMemories) > 0 || a.quitting) {
+		return tea.Batch(cmd, a.proposeNextMemory())
+	}
+	return cmd
+}
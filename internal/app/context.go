@@ -0,0 +1,106 @@
+package app
+
+import (
+	"github.com/scbenet/ask/internal/attach"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/ui/contextview"
+)
+
+// showContext opens the list of everything attached to the conversation
+func (a *App) showContext() {
+	items := a.contextItems()
+	if len(items) == 0 {
+		a.chat.AddNotice(i18n.T("nothing is attached, type @ to attach a file or /add-dir a directory"))
+		return
+	}
+	a.contextView.SetItems(items, contextview.Ref{})
+	a.activeView = contextView
+}
+
+// contextItems lists the attachments of the conversation in the order they
+// are sent, the next prompt's last
+func (a *App) contextItems() []contextview.Item {
+	var items []contextview.Item
+	add := func(message int, attachments []attach.Attachment) {
+		for i, att := range attachments {
+			items = append(items, contextview.Item{
+				Ref:      contextview.Ref{Message: message, Index: i},
+				Name:     att.Name,
+				Tokens:   llm.EstimateTokens(att.Format()),
+				Excluded: att.Excluded,
+			})
+		}
+	}
+	for i, m := range a.conversation.Messages {
+		add(i, m.Attachments)
+	}
+	add(contextview.Pending, a.pendingAttachments)
+	return items
+}
+
+// contextAttachments returns the attachments ref points into, nil if the
+// conversation changed under it
+func (a *App) contextAttachments(ref contextview.Ref) *[]attach.Attachment {
+	list := &a.pendingAttachments
+	if ref.Message != contextview.Pending {
+		if ref.Message >= a.conversation.Len() {
+			return nil
+		}
+		list = &a.conversation.Messages[ref.Message].Attachments
+	}
+	if ref.Index >= len(*list) {
+		return nil
+	}
+	return list
+}
+
+// changeContext applies a change made in the context view to the
+// attachments ref is in and lists them again, with the attachment change
+// returns selected
+func (a *App) changeContext(ref contextview.Ref, change func(list *[]attach.Attachment) contextview.Ref) {
+	list := a.contextAttachments(ref)
+	if list == nil {
+		return
+	}
+	if ref.Message != contextview.Pending && a.refuseLocked("change what's attached to it") {
+		return
+	}
+	selected := change(list)
+	if ref.Message != contextview.Pending {
+		// what's sent with the history changed, the estimate has to be redone
+		a.costCache.valid = false
+		a.saveSession()
+	}
+	items := a.contextItems()
+	if len(items) == 0 {
+		a.activeView = chatView
+		return
+	}
+	a.contextView.SetItems(items, selected)
+}
+
+func (a *App) toggleContextItem(ref contextview.Ref) {
+	a.changeContext(ref, func(list *[]attach.Attachment) contextview.Ref {
+		(*list)[ref.Index].Excluded = !(*list)[ref.Index].Excluded
+		return ref
+	})
+}
+
+func (a *App) removeContextItem(ref contextview.Ref) {
+	a.changeContext(ref, func(list *[]attach.Attachment) contextview.Ref {
+		*list = append((*list)[:ref.Index], (*list)[ref.Index+1:]...)
+		return ref
+	})
+}
+
+func (a *App) moveContextItem(ref contextview.Ref, delta int) {
+	a.changeContext(ref, func(list *[]attach.Attachment) contextview.Ref {
+		to := ref.Index + delta
+		if to < 0 || to >= len(*list) {
+			return ref
+		}
+		(*list)[ref.Index], (*list)[to] = (*list)[to], (*list)[ref.Index]
+		return contextview.Ref{Message: ref.Message, Index: to}
+	})
+}
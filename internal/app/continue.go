@@ -0,0 +1,132 @@
+package app
+
+import (
+	"cmp"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/logging"
+	"github.com/scbenet/ask/internal/ui"
+)
+
+// continuePrompt asks a model that can't carry on from a partial response
+// by itself for the rest of it
+const continuePrompt = "Your last response was cut off. Continue it exactly where it stopped, without repeating anything or adding an introduction."
+
+// truncation returns why a response was cut off, "" if it wasn't
+func truncation(finishReason string) string {
+	switch finishReason {
+	case llm.FinishLength, llm.FinishStopSequence:
+		return finishReason
+	}
+	return ""
+}
+
+// truncationBanner explains a cut off response and how to get the rest
+func truncationBanner(reason string) string {
+	if reason == llm.FinishStopSequence {
+		return i18n.T("⚠ response truncated (stop sequence), alt+m or /continue asks for the rest")
+	}
+	return i18n.T("⚠ response truncated (max tokens), alt+m or /continue asks for the rest")
+}
+
+// continueResponse asks the model for the rest of the last response when it
+// was cut off, the answer is added to it as if it was written in one go
+func (a *App) continueResponse() tea.Cmd {
+	if a.streamChan != nil {
+		a.chat.AddError(i18n.T("wait for the current response to finish before continuing one"))
+		return nil
+	}
+	if a.refuseLocked("continue the response") {
+		return nil
+	}
+	n := a.conversation.Len()
+	if n == 0 || a.conversation.Messages[n-1].Role != "assistant" || a.conversation.Messages[n-1].Truncated == "" {
+		a.chat.AddError(i18n.T("there's no cut off response to continue"))
+		return nil
+	}
+	last := a.conversation.Messages[n-1]
+	model := cmp.Or(last.Model, a.selectedModel)
+	if reason := a.overBudgetBy(a.estimateRequest(model, a.historyTokens())); reason != "" {
+		a.confirmOverBudget(reason, func() tea.Cmd { return a.resumeTruncated(last) }, nil)
+		return nil
+	}
+	return a.resumeTruncated(last)
+}
+
+// resumeTruncated streams the rest of last, the cut off response ending
+// the conversation
+func (a *App) resumeTruncated(last conversation.Message) tea.Cmd {
+	a.continuing = true
+	// it was journaled when it was cut off, only the rest is added
+	a.journaled = len(last.Content)
+	a.turnModel, a.turnEffort = cmp.Or(last.Model, a.selectedModel), last.Effort
+	logging.Infof("continuing the response of %s", a.turnModel)
+	a.toolRounds = 0
+	a.newTurn()
+	cmd := a.chat.SetSending(true)
+	// the response streams in again below, the rest following it
+	a.chat.ReopenResponse()
+	a.chat.ResumeResponse(last.Content)
+	return tea.Batch(cmd, a.startStream())
+}
+
+// endContinuation gives up on the rest of the response, which is shown
+// again as it is in the conversation
+func (a *App) endContinuation() tea.Cmd {
+	a.continuing, a.autoContinued, a.journaled = false, 0, 0
+	last := a.conversation.Messages[a.conversation.Len()-1]
+	chatModel, cmd := a.chat.Update(ui.StreamEndMsg{FullResponse: last.Content, Sources: last.Sources, Model: last.Model, Effort: last.Effort, Usage: last.Usage})
+	a.chat = chatModel.(*ui.Chat)
+	return cmd
+}
+
+// autoContinue asks for the rest of reply right away when it ran into the
+// max tokens and the config allows continuing it once more. the chat keeps
+// streaming it as if nothing happened. nil if it's done
+func (a *App) autoContinue(reply conversation.Message) tea.Cmd {
+	if reply.Truncated != llm.FinishLength || len(reply.ToolCalls) > 0 || a.autoContinued >= a.config.AutoContinue {
+		return nil
+	}
+	if reason := a.overBudgetNext(); reason != "" {
+		// it's left cut off, /continue asks whether to go over
+		logging.Infof("not continuing the response of %s: %s", a.turnModel, reason)
+		return nil
+	}
+	a.autoContinued++
+	logging.Infof("the response of %s ran into the max tokens, continuing it (%d/%d)", a.turnModel, a.autoContinued, a.config.AutoContinue)
+	a.continuing = true
+	return a.startStream()
+}
+
+// continuationMessages ends the request for the rest of a response with
+// the part written so far. models that don't continue a partial response
+// on their own are asked to
+func continuationMessages(req llm.Request) []llm.Message {
+	if req.Resumable {
+		return req.Messages
+	}
+	return append(req.Messages, llm.Message{Role: "user", Content: continuePrompt})
+}
+
+// stitchContinuation replaces the cut off response with itself followed by
+// the rest in m, returning the whole response, its sources and its usage
+// over both requests
+func (a *App) stitchContinuation(m llm.StreamEndMsg) (string, []llm.Source, *llm.Usage) {
+	a.continuing = false
+	previous := a.conversation.Truncate(a.conversation.Len() - 1)[0]
+	usage := m.Usage
+	if p := previous.Usage; p != nil && usage != nil {
+		usage = &llm.Usage{
+			PromptTokens:     p.PromptTokens + usage.PromptTokens,
+			CompletionTokens: p.CompletionTokens + usage.CompletionTokens,
+			TotalTokens:      p.TotalTokens + usage.TotalTokens,
+			Cost:             p.Cost + usage.Cost,
+			CacheReadTokens:  p.CacheReadTokens + usage.CacheReadTokens,
+			CacheWriteTokens: p.CacheWriteTokens + usage.CacheWriteTokens,
+		}
+	}
+	return previous.Content + m.FullResponse, append(previous.Sources, m.Sources...), usage
+}
@@ -0,0 +1,147 @@
+package app
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/scbenet/ask/internal/attach"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/llm"
+)
+
+// expected length of a response when the conversation has none to go by
+const defaultCompletionTokens = 500
+
+// costEstimate is the estimated size and price of sending the current input
+type costEstimate struct {
+	promptTokens     int
+	completionTokens int
+	cost             float64 // USD, 0 if the model's pricing is unknown
+	priced           bool
+}
+
+func (e costEstimate) String() string {
+	tokens := formatTokens(e.promptTokens)
+	if !e.priced {
+		return fmt.Sprintf("~%s tokens", tokens)
+	}
+	if e.cost == 0 {
+		return fmt.Sprintf("~%s tokens, free", tokens)
+	}
+	return fmt.Sprintf("~%s tokens, ~%s", tokens, formatCost(e.cost))
+}
+
+func formatTokens(n int) string {
+	if n >= 1000 {
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	}
+	return fmt.Sprint(n)
+}
+
+func formatCost(usd float64) string {
+	switch {
+	case usd < 0.001:
+		return "<$0.001"
+	case usd < 0.1:
+		return fmt.Sprintf("$%.3f", usd)
+	default:
+		return fmt.Sprintf("$%.2f", usd)
+	}
+}
+
+// costKey tells whether the conversation changed since its tokens were
+// estimated: a message added or removed, another model, or the last message
+// rewritten, e.g. by a continuation or an edit
+type costKey struct {
+	model    string
+	messages int
+	lastID   string
+	lastHash uint64
+}
+
+func (a *App) costKey() costKey {
+	key := costKey{model: a.selectedModel, messages: a.conversation.Len()}
+	if key.messages > 0 {
+		last := a.conversation.Messages[key.messages-1]
+		h := fnv.New64a()
+		h.Write([]byte(last.Content))
+		h.Write([]byte(last.Error))
+		key.lastID, key.lastHash = last.ID, h.Sum64()
+	}
+	return key
+}
+
+// historyTokens estimates the prompt tokens of the conversation so far plus
+// the system prompt. the usage reported for the last response is exact up to
+// that point, so only what came after it is estimated
+func (a *App) historyTokens() int {
+	system := llm.EstimateMessageTokens(a.newRequest(a.selectedModel, nil).Messages)
+	key := a.costKey()
+	if a.costCache.valid && a.costCache.key == key {
+		return a.costCache.tokens + system
+	}
+
+	messages := a.conversation.Messages
+	tokens := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		if u := messages[i].Usage; messages[i].Role == "assistant" && u != nil && u.PromptTokens > 0 {
+			// the reported prompt included the system prompt, which is added back below
+			tokens = u.PromptTokens + u.CompletionTokens - system
+			messages = messages[i+1:]
+			break
+		}
+	}
+	later := conversation.Conversation{Messages: messages}
+	tokens += llm.EstimateMessageTokens(later.LLMMessages())
+
+	a.costCache.key, a.costCache.valid = key, true
+	a.costCache.tokens = tokens
+	return tokens + system
+}
+
+// expectedCompletionTokens guesses the response length from earlier ones
+func (a *App) expectedCompletionTokens() int {
+	total, n := 0, 0
+	for _, m := range a.conversation.Messages {
+		if m.Role == "assistant" && m.Usage != nil && m.Usage.CompletionTokens > 0 {
+			total += m.Usage.CompletionTokens
+			n++
+		}
+	}
+	if n == 0 {
+		return defaultCompletionTokens
+	}
+	return total / n
+}
+
+// estimateCost estimates what sending input would cost with the current model
+func (a *App) estimateCost(input string) costEstimate {
+	return a.estimateRequest(a.selectedModel, a.historyTokens()+llm.EstimateTokens(attach.Wrap(input, a.pendingAttachments)))
+}
+
+// estimateRequest estimates what a request of promptTokens to model costs,
+// expecting a response as long as the earlier ones
+func (a *App) estimateRequest(model string, promptTokens int) costEstimate {
+	e := costEstimate{
+		promptTokens:     promptTokens,
+		completionTokens: a.expectedCompletionTokens(),
+	}
+	// local models report no pricing at all, like free ones
+	if info, ok := a.modelInfo[model]; ok && (info.ContextLength > 0 || info.PromptPrice > 0) {
+		e.priced = true
+		e.cost = (float64(e.promptTokens)*info.PromptPrice + float64(e.completionTokens)*info.CompletionPrice) / 1_000_000
+	}
+	return e
+}
+
+// updateCostEstimate shows the estimate for the current input next to the
+// send hint
+func (a *App) updateCostEstimate() {
+	input := a.chat.GetInputValue()
+	if strings.TrimSpace(input) == "" {
+		a.chat.SetSendHint("")
+		return
+	}
+	a.chat.SetSendHint(a.estimateCost(input).String())
+}
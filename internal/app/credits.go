@@ -0,0 +1,50 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// how often the openrouter balance is checked
+const creditsInterval = 15 * time.Minute
+
+// creditsMsg carries the openrouter balance
+type creditsMsg struct {
+	credits llm.Credits
+	err     error
+}
+
+// creditsTickMsg starts the next balance check
+type creditsTickMsg struct{}
+
+// fetchCredits asks openrouter for the remaining balance in the background
+func fetchCredits(client *llm.OpenRouterClient) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		credits, err := client.Credits(ctx)
+		return creditsMsg{credits: credits, err: err}
+	}
+}
+
+// handleCredits warns in the status bar when the balance is below the
+// configured threshold and schedules the next check
+func (a *App) handleCredits(m creditsMsg) tea.Cmd {
+	next := tea.Tick(creditsInterval, func(time.Time) tea.Msg { return creditsTickMsg{} })
+	if m.err != nil {
+		// keys without access to the endpoint just don't get the warning
+		logging.Errorf("fetching openrouter credits: %v", m.err)
+		return next
+	}
+	if remaining := m.credits.Remaining(); remaining < a.config.CreditWarning {
+		a.chat.SetWarning(fmt.Sprintf("low balance: $%.2f left", remaining))
+	} else {
+		a.chat.SetWarning("")
+	}
+	return next
+}
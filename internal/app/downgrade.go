@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// keepModelPrefix starts a prompt that must not be downgraded
+const keepModelPrefix = "!"
+
+const classifyPrompt = `Decide whether the following question can be answered well by a small, fast model: a quick fact, a definition, a unit conversion, a simple rewording. Questions needing reasoning, code, math, analysis or long answers are not simple.
+
+Reply with exactly one word, SIMPLE or COMPLEX.
+
+Question: %s`
+
+// classifyTimeout is the longest the classification may hold up a prompt,
+// it's meant to be quicker than the answer
+const classifyTimeout = 10 * time.Second
+
+// downgradedMsg tells the app the stream was sent to the cheaper model
+type downgradedMsg struct {
+	model string
+}
+
+// looksTrivial is the cheap part of the downgrade heuristic: a short single
+// paragraph without code, in a conversation without attached context
+func (a *App) looksTrivial(prompt string) bool {
+	if len([]rune(prompt)) > a.config.Downgrade.MaxLength || strings.Count(prompt, "\n") > 1 {
+		return false
+	}
+	if strings.ContainsAny(prompt, "`{};") {
+		return false
+	}
+	for _, m := range a.conversation.Messages {
+		if len(m.Attachments) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// chooseModel picks the model for a new turn. simple prompts go to the
+// downgrade model, straight away unless it should be asked first, in which
+// case classify is true and the choice is made by startDowngradedStream
+func (a *App) chooseModel(prompt string) (model string, classify bool) {
+	dg := a.config.Downgrade
+	if !dg.Enabled || dg.Model == a.selectedModel || !a.looksTrivial(prompt) {
+		return a.selectedModel, false
+	}
+	if dg.Classify {
+		return a.selectedModel, true
+	}
+	return dg.Model, false
+}
+
+// startDowngradedStream asks the downgrade model whether prompt is simple
+// and streams the answer from whichever model should give it. both requests
+// are built here, the goroutine only picks one
+func (a *App) startDowngradedStream(prompt string) tea.Cmd {
+	full := a.streamRequest(a.turnModel)
+	cheap := a.streamRequest(a.config.Downgrade.Model)
+	ch, listen := a.newStream()
+	ctx := a.requestContext()
+	go func() {
+		req := full
+		if a.classifySimple(ctx, cheap.Model, prompt) {
+			req = cheap
+			ch <- downgradedMsg{model: cheap.Model}
+		}
+		a.llmClient.StreamGenerate(ctx, req, ch)
+	}()
+	return tea.Batch(listen, a.watchStall())
+}
+
+// classifySimple asks model whether prompt is simple enough for it, any
+// doubt, error or taking too long keeps the selected model
+func (a *App) classifySimple(ctx context.Context, model, prompt string) bool {
+	ctx, cancel := context.WithTimeout(ctx, boundedTimeout(a.config.RequestTimeout.Std(), classifyTimeout))
+	defer cancel()
+	reply, err := a.llmClient.Generate(ctx, model, fmt.Sprintf(classifyPrompt, prompt), nil)
+	if err != nil {
+		logging.Errorf("classifying prompt for downgrade: %v", err)
+		return false
+	}
+	log.Printf("downgrade classification: %q", reply)
+	return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(reply)), "SIMPLE")
+}
+
+// announceDowngrade says which model is answering and how to avoid it
+func (a *App) announceDowngrade(model string) {
+	a.chat.AddNotice(i18n.Tf("simple question, answered by %s instead of %s. start a message with %s to keep the selected model",
+		model, a.selectedModel, keepModelPrefix))
+}
+
+// boundedTimeout is the request timeout for a job that should take at most
+// limit, the request timeout may be 0 for none
+func boundedTimeout(requestTimeout, limit time.Duration) time.Duration {
+	if requestTimeout <= 0 {
+		return limit
+	}
+	return min(requestTimeout, limit)
+}
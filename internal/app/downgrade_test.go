@@ -0,0 +1,103 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/attach"
+	"github.com/scbenet/ask/internal/config"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/llm"
+)
+
+func TestChooseModel(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.config.Downgrade = config.DowngradeConfig{Enabled: true, Model: "test:small", MaxLength: 40}
+
+	tests := []struct {
+		prompt string
+		model  string
+	}{
+		{"what's the capital of France?", "test:small"},
+		{"explain in detail how a garbage collector decides what to free", "test:m"},
+		{"why does `x := y` fail?", "test:m"},
+		{"one\ntwo\nthree", "test:m"},
+	}
+	for _, tt := range tests {
+		if model, classify := a.chooseModel(tt.prompt); model != tt.model || classify {
+			t.Errorf("chooseModel(%q) = %s, %v, want %s", tt.prompt, model, classify, tt.model)
+		}
+	}
+
+	a.conversation.Add(conversation.Message{Role: "user", Content: "see this", Attachments: []attach.Attachment{{Name: "a.txt"}}})
+	if model, _ := a.chooseModel("and now?"); model != "test:m" {
+		t.Errorf("downgraded a conversation with attachments to %s", model)
+	}
+
+	a.config.Downgrade.Classify = true
+	a.conversation = conversation.New(nil)
+	if model, classify := a.chooseModel("hi"); model != "test:m" || !classify {
+		t.Errorf("chooseModel = %s, %v, want the selected model and a classification", model, classify)
+	}
+	a.config.Downgrade.Enabled = false
+	if model, classify := a.chooseModel("hi"); model != "test:m" || classify {
+		t.Errorf("downgrading when it's off: %s, %v", model, classify)
+	}
+}
+
+// TestKeepModelPrefix only takes the ! off while downgrading is on
+func TestKeepModelPrefix(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		a := newTestApp(t, nil)
+		a.llmClient = &llm.MockLLMClient{Streams: [][]tea.Msg{{llm.StreamEndMsg{FullResponse: "ok"}}}}
+		a.config.Downgrade = config.DowngradeConfig{Enabled: enabled, Model: "test:small", MaxLength: 40}
+
+		a.sendPrompt("!important")
+		want := "!important"
+		if enabled {
+			want = "important"
+		}
+		if got := a.conversation.Messages[0].Content; got != want || a.turnModel != "test:m" {
+			t.Errorf("downgrade %v: sent %q to %s, want %q to test:m", enabled, got, a.turnModel, want)
+		}
+		a.interruptStream()
+	}
+}
+
+func TestClassifySimple(t *testing.T) {
+	a := newTestApp(t, nil)
+	for reply, want := range map[string]bool{"SIMPLE": true, " simple.\n": true, "COMPLEX": false, "I think it's simple": false} {
+		a.llmClient = &llm.MockLLMClient{Reply: func(string, string, []llm.Message) (string, error) { return reply, nil }}
+		if got := a.classifySimple(context.Background(), "test:small", "hi"); got != want {
+			t.Errorf("reply %q classified as simple: %v", reply, got)
+		}
+	}
+
+	a.llmClient = &llm.MockLLMClient{Reply: func(string, string, []llm.Message) (string, error) { return "", errors.New("overloaded") }}
+	if a.classifySimple(context.Background(), "test:small", "hi") {
+		t.Error("an error downgraded the prompt")
+	}
+
+	// without a request timeout the classification still gives up
+	a.config.RequestTimeout = 0
+	var deadline time.Time
+	a.llmClient = deadlineClient{&llm.MockLLMClient{}, &deadline}
+	a.classifySimple(context.Background(), "test:small", "hi")
+	if deadline.IsZero() || time.Until(deadline) > classifyTimeout {
+		t.Errorf("classification deadline %v", deadline)
+	}
+}
+
+// deadlineClient records the deadline Generate is called with
+type deadlineClient struct {
+	llm.LLMClient
+	deadline *time.Time
+}
+
+func (c deadlineClient) Generate(ctx context.Context, model, prompt string, history []llm.Message) (string, error) {
+	*c.deadline, _ = ctx.Deadline()
+	return c.LLMClient.Generate(ctx, model, prompt, history)
+}
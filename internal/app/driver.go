@@ -0,0 +1,343 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/scbenet/ask/internal/llm"
+)
+
+// Driver runs the app without a terminal, from a script of key presses and
+// commands, one per line:
+//
+//	size 100 30        resize the screen, 80x24 to start with
+//	type some text     type text into whatever has focus
+//	key ctrl+k down    press keys by name, as bubbletea spells them
+//	send a prompt      type a prompt and press enter
+//	wait [2m]          wait for the response and anything queued after it
+//	sleep 1s           let time pass, for timers and animations
+//	view               print the screen without colors
+//	state              print the conversation and app state as JSON
+//	expect text        fail unless the screen shows text
+//	scripted           answer requests from the script instead of a provider:
+//	chunk text         stream text as part of the response, \n for newlines
+//	end                finish the response
+//	fail message       fail the response with an error
+//
+// blank lines and lines starting with # are skipped. messages are handed to
+// the app one at a time like bubbletea does, so what the script sees is
+// what a user would
+type Driver struct {
+	app *App
+	out io.Writer
+	// Timeout bounds every wait
+	Timeout time.Duration
+
+	msgs chan tea.Msg
+	done chan struct{}
+	quit bool
+
+	// requests waiting to be answered by the script, once it's scripted
+	requests chan *scriptedStream
+	stream   *scriptedStream
+}
+
+// settleTime is how long the app has to be quiet before the next line runs
+const settleTime = 20 * time.Millisecond
+
+// NewDriver starts a and returns a driver printing to out
+func NewDriver(a *App, out io.Writer) *Driver {
+	d := &Driver{
+		app:      a,
+		out:      out,
+		Timeout:  2 * time.Minute,
+		msgs:     make(chan tea.Msg),
+		done:     make(chan struct{}),
+		requests: make(chan *scriptedStream, 16),
+	}
+	d.run(a.Init())
+	d.send(tea.WindowSizeMsg{Width: 80, Height: 24})
+	return d
+}
+
+// Run executes script, stopping at the first line that fails or once the
+// app quits
+func (d *Driver) Run(script io.Reader) error {
+	defer close(d.done)
+	scanner := bufio.NewScanner(script)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if d.quit {
+			return fmt.Errorf("line %d: the app has quit", n)
+		}
+		if err := d.exec(line); err != nil {
+			return fmt.Errorf("line %d: %s: %w", n, line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func (d *Driver) exec(line string) error {
+	command, arg, _ := strings.Cut(line, " ")
+	switch command {
+	case "size":
+		var width, height int
+		if _, err := fmt.Sscan(arg, &width, &height); err != nil {
+			return errors.New("expected a width and a height")
+		}
+		d.send(tea.WindowSizeMsg{Width: width, Height: height})
+	case "type":
+		d.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(arg)})
+	case "key":
+		for _, name := range strings.Fields(arg) {
+			key, ok := parseKey(name)
+			if !ok {
+				return fmt.Errorf("unknown key %q", name)
+			}
+			d.send(key)
+			d.settle()
+		}
+	case "send":
+		d.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(arg)})
+		d.send(d.app.chat.SendKeyMsg())
+	case "wait":
+		timeout := d.Timeout
+		if arg != "" {
+			var err error
+			if timeout, err = time.ParseDuration(arg); err != nil {
+				return err
+			}
+		}
+		return d.until(timeout, d.idle)
+	case "sleep":
+		duration, err := time.ParseDuration(arg)
+		if err != nil {
+			return err
+		}
+		deadline := time.Now().Add(duration)
+		return d.until(duration+settleTime, func() bool { return !time.Now().Before(deadline) })
+	case "view":
+		fmt.Fprintln(d.out, ansi.Strip(d.app.View()))
+	case "state":
+		data, err := json.MarshalIndent(d.state(), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(d.out, string(data))
+	case "expect":
+		if !strings.Contains(ansi.Strip(d.app.View()), arg) {
+			return fmt.Errorf("%q is not on the screen", arg)
+		}
+	case "scripted":
+		d.app.llmClient = scriptedClient{requests: d.requests}
+		// the script answers, whether the provider can be reached doesn't matter
+		d.app.reachable = nil
+		d.app.setOffline(false)
+	case "chunk", "end", "fail":
+		return d.script(command, arg)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+	d.settle()
+	return nil
+}
+
+// run executes cmd in the background like bubbletea does, its message is
+// picked up by settle or until
+func (d *Driver) run(cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	go func() {
+		msg := cmd()
+		select {
+		case d.msgs <- msg:
+		case <-d.done:
+		}
+	}()
+}
+
+// send hands msg to the app
+func (d *Driver) send(msg tea.Msg) {
+	switch msg := msg.(type) {
+	case nil:
+	case tea.QuitMsg:
+		d.quit = true
+	case tea.BatchMsg:
+		for _, cmd := range msg {
+			d.run(cmd)
+		}
+	default:
+		_, cmd := d.app.Update(msg)
+		d.run(cmd)
+	}
+}
+
+// settle delivers messages until the app has been quiet for a moment
+func (d *Driver) settle() {
+	for !d.quit {
+		select {
+		case msg := <-d.msgs:
+			d.send(msg)
+		case <-time.After(settleTime):
+			return
+		}
+	}
+}
+
+// until delivers messages until cond holds and the app is quiet
+func (d *Driver) until(timeout time.Duration, cond func() bool) error {
+	deadline := time.After(timeout)
+	for !d.quit {
+		select {
+		case msg := <-d.msgs:
+			d.send(msg)
+		case <-deadline:
+			return fmt.Errorf("still waiting after %s", timeout)
+		case <-time.After(settleTime):
+			if cond() {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// idle is true once nothing is streaming, about to be sent or queued
+func (d *Driver) idle() bool {
+	return d.app.streamChan == nil && d.app.pendingSend == nil && len(d.app.queuedPrompts) == 0
+}
+
+type stateMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	Model   string `json:"model,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type driverState struct {
+	Session   string         `json:"session"`
+	Model     string         `json:"model"`
+	Streaming bool           `json:"streaming"`
+	Queued    []string       `json:"queued,omitempty"`
+	Input     string         `json:"input"`
+	Messages  []stateMessage `json:"messages"`
+}
+
+func (d *Driver) state() driverState {
+	a := d.app
+	state := driverState{
+		Session:   a.session.ID,
+		Model:     a.selectedModel,
+		Streaming: a.streamChan != nil,
+		Queued:    a.queuedPrompts,
+		Input:     a.chat.GetInputValue(),
+		Messages:  []stateMessage{},
+	}
+	for _, m := range a.conversation.Messages {
+		state.Messages = append(state.Messages, stateMessage{Role: m.Role, Content: m.Content, Model: m.Model, Error: m.Error})
+	}
+	return state
+}
+
+// parseKey returns the key press bubbletea names name, e.g. "enter",
+// "ctrl+k", "alt+1" or a single character
+func parseKey(name string) (tea.KeyMsg, bool) {
+	alt := false
+	if rest, ok := strings.CutPrefix(name, "alt+"); ok && rest != "" {
+		alt, name = true, rest
+	}
+	if name == "space" {
+		return tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}, Alt: alt}, true
+	}
+	if r := []rune(name); len(r) == 1 {
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: r, Alt: alt}, true
+	}
+	// bubbletea keeps its table of names to itself, ask every key type
+	for t := tea.KeyType(-100); t <= tea.KeyBackspace; t++ {
+		if t != tea.KeyRunes && (tea.Key{Type: t}).String() == name {
+			return tea.KeyMsg{Type: t, Alt: alt}, true
+		}
+	}
+	return tea.KeyMsg{}, false
+}
+
+// scriptedStream is a response written by chunk, end and fail lines
+type scriptedStream struct {
+	events chan tea.Msg
+	full   strings.Builder
+}
+
+// script feeds the current scripted response, waiting for the app to make
+// a request if none is open
+func (d *Driver) script(command, arg string) error {
+	if d.stream == nil {
+		if err := d.until(d.Timeout, func() bool { return len(d.requests) > 0 }); err != nil {
+			return fmt.Errorf("no request to answer: %w", err)
+		}
+		if d.quit {
+			return nil
+		}
+		d.stream = <-d.requests
+	}
+	switch command {
+	case "chunk":
+		// escapes like \n work, for multi line responses
+		if unquoted, err := strconv.Unquote(`"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`); err == nil {
+			arg = unquoted
+		}
+		d.stream.full.WriteString(arg)
+		d.stream.events <- llm.StreamChunkMsg{Content: arg}
+	case "end":
+		d.stream.events <- llm.StreamEndMsg{FullResponse: d.stream.full.String()}
+		close(d.stream.events)
+		d.stream = nil
+	case "fail":
+		d.stream.events <- llm.StreamErrorMsg{Err: errors.New(arg)}
+		close(d.stream.events)
+		d.stream = nil
+	}
+	d.settle()
+	return nil
+}
+
+// scriptedClient hands every streamed request to the driver
+type scriptedClient struct {
+	requests chan *scriptedStream
+}
+
+func (c scriptedClient) Generate(ctx context.Context, modelName string, prompt string, history []llm.Message) (string, error) {
+	return "", errors.New("the script only answers streamed requests")
+}
+
+func (c scriptedClient) StreamGenerate(ctx context.Context, req llm.Request, msgChan chan<- tea.Msg) {
+	stream := &scriptedStream{events: make(chan tea.Msg, 64)}
+	c.requests <- stream
+	go func() {
+		defer close(msgChan)
+		for {
+			select {
+			case msg, ok := <-stream.events:
+				if !ok {
+					return
+				}
+				msgChan <- msg
+			case <-ctx.Done():
+				msgChan <- llm.StreamErrorMsg{Err: ctx.Err()}
+				return
+			}
+		}
+	}()
+}
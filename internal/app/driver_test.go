@@ -0,0 +1,65 @@
+package app
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestDriverScripted(t *testing.T) {
+	var out strings.Builder
+	d := NewDriver(newTestApp(t, nil), &out)
+	err := d.Run(strings.NewReader(`
+# a prompt answered by the script, then one that fails
+scripted
+send hello
+chunk Hi!\nHow can
+chunk  I help?
+end
+wait
+expect How can I help?
+send again
+fail overloaded
+wait
+state
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var state driverState
+	if err := json.Unmarshal([]byte(out.String()), &state); err != nil {
+		t.Fatalf("state isn't JSON: %v\n%s", err, out.String())
+	}
+	if len(state.Messages) != 3 || state.Messages[1].Content != "Hi!\nHow can I help?" {
+		t.Fatalf("messages are %+v", state.Messages)
+	}
+	if state.Messages[2].Error == "" {
+		t.Fatalf("the failed prompt isn't marked: %+v", state.Messages[2])
+	}
+	if state.Streaming {
+		t.Fatal("still streaming")
+	}
+}
+
+func TestDriverErrors(t *testing.T) {
+	for script, want := range map[string]string{
+		"expect nothing like this": `line 1: expect nothing like this: "nothing like this" is not on the screen`,
+		"key ctrl+nope":            `line 1: key ctrl+nope: unknown key "ctrl+nope"`,
+		"\n\nfrobnicate":           `line 3: frobnicate: unknown command "frobnicate"`,
+	} {
+		d := NewDriver(newTestApp(t, nil), &strings.Builder{})
+		if err := d.Run(strings.NewReader(script)); err == nil || err.Error() != want {
+			t.Errorf("%q failed with %v, want %s", script, err, want)
+		}
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	for _, name := range []string{"enter", "esc", "ctrl+k", "alt+1", "pgup", "shift+tab", "space", "x", "backspace"} {
+		key, ok := parseKey(name)
+		if !ok || key.String() != name && name != "space" {
+			t.Errorf("%s parsed as %q", name, key.String())
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// editorClosedMsg carries the prompt written in the external editor
+type editorClosedMsg struct {
+	text string
+	err  error
+}
+
+// editorCommand returns the user's editor, $VISUAL or $EDITOR, with any
+// arguments they configured. without one it's vi, notepad on windows
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.Fields(os.Getenv(env)); len(editor) > 0 {
+			return editor
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// openEditor suspends the TUI and opens the current input in an external
+// editor, the saved text replaces the input when it exits
+func (a *App) openEditor() tea.Cmd {
+	f, err := os.CreateTemp("", "ask-prompt-*.md")
+	if err != nil {
+		a.chat.AddError(i18n.Tf("failed to create a file for the editor: %v", err))
+		return nil
+	}
+	_, err = f.WriteString(a.chat.GetInputValue())
+	f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		a.chat.AddError(i18n.Tf("failed to write the prompt for the editor: %v", err))
+		return nil
+	}
+
+	editor := editorCommand()
+	cmd := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	return a.keyboard.ExecProcess(cmd, func(err error) tea.Msg {
+		defer os.Remove(f.Name())
+		if err != nil {
+			return editorClosedMsg{err: fmt.Errorf("%s: %w", editor[0], err)}
+		}
+		data, err := os.ReadFile(f.Name())
+		return editorClosedMsg{text: string(data), err: err}
+	})
+}
+
+// handleEditorClosed puts the edited prompt back in the input
+func (a *App) handleEditorClosed(m editorClosedMsg) {
+	if m.err != nil {
+		logging.Errorf("running editor: %v", m.err)
+		a.chat.AddError(i18n.Tf("editor failed: %v", m.err))
+		return
+	}
+	// editors add a final newline, which would end up in the prompt
+	a.chat.SetInputValue(strings.TrimRight(m.text, "\n"))
+}
@@ -0,0 +1,90 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/logging"
+	"github.com/scbenet/ask/internal/ui"
+)
+
+// errorReport describes a failed request for the error panel: a short
+// message up front, the status and whatever the provider said in the details
+func errorReport(err error, model, requestID string) ui.ErrorReport {
+	report := ui.ErrorReport{Message: i18n.T("the request failed: ") + err.Error(), Model: model, RequestID: requestID}
+	var status *llm.StatusError
+	if !errors.As(err, &status) {
+		return report
+	}
+	report.Status = status.Status
+	report.Body = indentJSON(status.Message)
+	report.Message = i18n.Tf("the request failed with %d %s", status.Status, http.StatusText(status.Status))
+	if reason := providerReason(status.Message); reason != "" {
+		report.Message += ": " + reason
+	}
+	return report
+}
+
+// providerReason digs the message out of an error body like OpenRouter's
+// {"error": {"message": "..."}}, "" if it isn't one
+func providerReason(body string) string {
+	var parsed struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal([]byte(body), &parsed) != nil || parsed.Error == nil {
+		return ""
+	}
+	var detailed struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(parsed.Error, &detailed) == nil {
+		return detailed.Message
+	}
+	var plain string
+	if json.Unmarshal(parsed.Error, &plain) == nil {
+		return plain
+	}
+	return ""
+}
+
+// indentJSON makes a JSON body readable, anything else is left alone
+func indentJSON(body string) string {
+	var b bytes.Buffer
+	if json.Indent(&b, []byte(body), "", "  ") != nil {
+		return strings.TrimSpace(body)
+	}
+	return b.String()
+}
+
+// retryFailed sends the prompt whose request failed again, with the model
+// selected now in case it was switched from the error panel
+func (a *App) retryFailed() tea.Cmd {
+	n := a.conversation.Len()
+	if n == 0 || !a.conversation.Messages[n-1].Failed() || a.streamChan != nil {
+		a.chat.AddError(i18n.T("nothing to retry"))
+		return nil
+	}
+	failed := &a.conversation.Messages[n-1]
+	send := func() tea.Cmd {
+		logging.Infof("[%s] retrying failed request with %s", failed.RequestID, a.selectedModel)
+		failed.Error, failed.RequestID = "", ""
+		failed.Model = a.selectedModel
+		a.turnModel, a.turnEffort = a.selectedModel, ""
+		a.toolRounds = 0
+		a.newTurn()
+		cmd := a.chat.SetSending(true)
+		a.chat.AddNotice(i18n.T("sending the request again"))
+		return tea.Batch(cmd, a.startStream())
+	}
+	if reason := a.overBudget(failed.Content); reason != "" {
+		a.confirmOverBudget(reason, send, func() tea.Cmd { return nil })
+		return nil
+	}
+	return send()
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/ui"
+)
+
+func TestErrorReport(t *testing.T) {
+	for _, tt := range []struct {
+		err     error
+		message string
+		status  int
+	}{
+		{errors.New("connection refused"), "the request failed: connection refused", 0},
+		{&llm.StatusError{Status: 429, Message: `{"error": {"message": "slow down", "code": 429}}`},
+			"the request failed with 429 Too Many Requests: slow down", 429},
+		{&llm.StatusError{Status: 400, Message: `{"error": "bad model"}`},
+			"the request failed with 400 Bad Request: bad model", 400},
+		{&llm.StatusError{Status: 502, Message: "<html>bad gateway</html>"},
+			"the request failed with 502 Bad Gateway", 502},
+	} {
+		report := errorReport(tt.err, "test:m", "req_1")
+		if report.Message != tt.message || report.Status != tt.status {
+			t.Errorf("%v: got %q (status %d), want %q (status %d)", tt.err, report.Message, report.Status, tt.message, tt.status)
+		}
+	}
+}
+
+// TestRetryFailed sends a failed prompt again from the error panel, it's
+// answered like any other once it goes through
+func TestRetryFailed(t *testing.T) {
+	client := &fakeClient{fail: map[string]error{"one": &llm.StatusError{Status: 503, Message: "overloaded"}}}
+	l := newLoop(t, nil, client)
+
+	l.send(ui.SendPromptMsg{Prompt: "one"})
+	l.until("the error", l.idle)
+	if !l.app.conversation.Messages[0].Failed() {
+		t.Fatal("the prompt isn't marked failed")
+	}
+
+	l.send(ui.RetryMsg{})
+	l.until("the response", func() bool { return l.idle() && l.app.conversation.Len() == 2 })
+	client.running.Wait()
+	l.checkConversation("user: one", "assistant: re: one")
+	if l.app.conversation.Messages[0].Failed() {
+		t.Error("the retried prompt is still marked failed")
+	}
+}
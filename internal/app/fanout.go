@@ -0,0 +1,199 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/attach"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/session"
+	"github.com/scbenet/ask/internal/ui/fanout"
+)
+
+// fanoutRun is a prompt sent to several models at once, waiting for one of
+// the responses to be kept
+type fanoutRun struct {
+	id     int
+	prompt conversation.Message
+	models []string
+	chans  []chan tea.Msg
+	cancel context.CancelFunc
+	// the panes of the candidates judged, in the order they were sent
+	judged []int
+}
+
+// startFanout sends the prompt to every model in the fanout config at once
+// and shows their responses side by side in tabs, the one picked is kept
+// in the conversation as if it had answered alone
+func (a *App) startFanout(args []string) tea.Cmd {
+	if len(args) == 0 {
+		a.chat.AddError(i18n.T("usage: /fanout prompt"))
+		return nil
+	}
+	models := a.config.Fanout
+	if len(models) < 2 {
+		a.chat.AddError(i18n.T(`/fanout needs at least two models in the config, e.g. "fanout": ["openai/gpt-4.1", "anthropic/claude-3.7-sonnet"]`))
+		return nil
+	}
+	if a.streamChan != nil {
+		a.chat.AddError(i18n.T("wait for the current response to finish before fanning out"))
+		return nil
+	}
+	if a.refuseLocked("send a message") {
+		return nil
+	}
+	if a.offline {
+		a.chat.AddError(i18n.T("offline, fan out again once the provider can be reached"))
+		return nil
+	}
+
+	content := strings.Join(args, " ")
+	a.refreshAttachments()
+	tokens := a.historyTokens() + llm.EstimateTokens(attach.Wrap(content, a.pendingAttachments))
+	var estimates []costEstimate
+	for _, model := range models {
+		estimates = append(estimates, a.estimateRequest(model, tokens))
+	}
+	if reason := a.overBudgetBy(estimates...); reason != "" {
+		a.confirmOverBudget(reason, func() tea.Cmd { return a.sendFanout(models, content) }, nil)
+		return nil
+	}
+	return a.sendFanout(models, content)
+}
+
+// sendFanout streams content to every model and opens the fan-out view
+func (a *App) sendFanout(models []string, content string) tea.Cmd {
+	prompt := conversation.Message{
+		Role:        "user",
+		Content:     content,
+		Attachments: a.pendingAttachments,
+		ExpiresIn:   a.nextEphemeral,
+	}
+	// sent as if the prompt was added, ephemeral messages it outlives left
+	// out. the conversation only counts down once a response is kept
+	sent := conversation.New(append(slices.Clone(a.conversation.Messages), prompt))
+	sent.Expire()
+	history := sent.LLMMessages()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a.fanoutID++
+	run := &fanoutRun{id: a.fanoutID, prompt: prompt, models: models, cancel: cancel}
+	var cmds []tea.Cmd
+	for i, model := range models {
+		ch := make(chan tea.Msg)
+		run.chans = append(run.chans, ch)
+		log.Printf("fanout: streaming to %s", model)
+		go a.llmClient.StreamGenerate(llm.WithRequestID(ctx, llm.NewRequestID()), a.newRequest(model, history), ch)
+		cmds = append(cmds, listenToFanout(ch, run.id, i))
+	}
+	a.fanout = run
+	a.fanoutView = fanout.New(prompt.Content, models, a.chat.RenderMarkdown)
+	a.activeView = fanoutView
+	view, cmd := a.fanoutView.Update(tea.WindowSizeMsg{Width: a.width, Height: a.height})
+	a.fanoutView = view.(*fanout.Model)
+	return tea.Batch(append(cmds, cmd)...)
+}
+
+// handleFanoutPane passes a stream message on to the view, listening for
+// the next one until the stream ends
+func (a *App) handleFanoutPane(m fanout.PaneMsg) tea.Cmd {
+	run := a.fanout
+	if run == nil || m.ID != run.id {
+		return a.drainFanout(m)
+	}
+	view, cmd := a.fanoutView.Update(m)
+	a.fanoutView = view.(*fanout.Model)
+	switch msg := m.Msg.(type) {
+	case llm.StreamEndMsg:
+		run.chans[m.Pane] = nil
+		a.recordSpend(run.models[m.Pane], msg.Usage)
+	case llm.StreamErrorMsg:
+		run.chans[m.Pane] = nil
+	default:
+		return tea.Batch(cmd, listenToFanout(run.chans[m.Pane], run.id, m.Pane))
+	}
+	if a.config.Judge.Auto && !a.fanoutView.Streaming() {
+		return tea.Batch(cmd, a.judgeFanout())
+	}
+	return cmd
+}
+
+// keepFanout adds the prompt and the response picked to the conversation
+func (a *App) keepFanout(m fanout.KeepMsg) {
+	run := a.fanout
+	if run == nil {
+		return
+	}
+	a.closeFanout()
+	a.expireEphemeral()
+	a.pendingAttachments = nil
+	a.nextEphemeral = 0
+	// the response kept isn't another take on the last one
+	a.previousResponse, a.regenerating, a.replaced = "", false, nil
+	user := a.conversation.Add(run.prompt)
+	reply := a.conversation.Add(conversation.Message{Role: "assistant", Content: m.Response, Model: m.Model, Usage: m.Usage})
+	if a.session.Title == "" {
+		a.session.Title = session.TitleFromPrompt(run.prompt.Content)
+	}
+	a.saveSession()
+	a.chat.LoadHistory([]conversation.Message{*user, *reply})
+	a.chat.AddNotice(i18n.Tf("kept %s's response, the others were discarded", m.Model))
+	a.writeJournal(m.Response, m.Model)
+}
+
+// closeFanout cancels the responses still streaming and goes back to the
+// chat, nothing is kept
+func (a *App) closeFanout() {
+	if run := a.fanout; run != nil {
+		run.cancel()
+		// the streams close their channels once they see the cancellation,
+		// until then they're read by drainFanout
+		if slices.ContainsFunc(run.chans, func(ch chan tea.Msg) bool { return ch != nil }) {
+			if a.closedFanouts == nil {
+				a.closedFanouts = map[int]*fanoutRun{}
+			}
+			a.closedFanouts[run.id] = run
+		}
+	}
+	a.fanout = nil
+	a.fanoutView = nil
+	a.activeView = chatView
+}
+
+// drainFanout reads what the streams of a closed fan-out still send. a
+// response that finished before it saw the cancellation is still paid for
+func (a *App) drainFanout(m fanout.PaneMsg) tea.Cmd {
+	run, ok := a.closedFanouts[m.ID]
+	if !ok {
+		return nil
+	}
+	switch msg := m.Msg.(type) {
+	case llm.StreamEndMsg:
+		a.recordSpend(run.models[m.Pane], msg.Usage)
+	case llm.StreamErrorMsg:
+	default:
+		return listenToFanout(run.chans[m.Pane], run.id, m.Pane)
+	}
+	run.chans[m.Pane] = nil
+	if !slices.ContainsFunc(run.chans, func(ch chan tea.Msg) bool { return ch != nil }) {
+		delete(a.closedFanouts, run.id)
+	}
+	return nil
+}
+
+// listenToFanout is listenToStream for a fan-out tab
+func listenToFanout(ch chan tea.Msg, id, pane int) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-ch
+		if !ok {
+			return fanout.PaneMsg{ID: id, Pane: pane, Msg: llm.StreamErrorMsg{Err: fmt.Errorf("stream closed unexpectedly")}}
+		}
+		return fanout.PaneMsg{ID: id, Pane: pane, Msg: msg}
+	}
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// listHistory prints the conversation with message numbers usable by /truncate
+func (a *App) listHistory() {
+	if a.conversation.Len() == 0 {
+		a.chat.AddNotice(i18n.T("conversation is empty"))
+		return
+	}
+
+	var b strings.Builder
+	for i, m := range a.conversation.Messages {
+		preview := strings.Join(strings.Fields(m.Content), " ")
+		if r := []rune(preview); len(r) > 70 {
+			preview = string(r[:69]) + "…"
+		}
+		status := ""
+		if m.Failed() {
+			status = " (failed)"
+		}
+		fmt.Fprintf(&b, "%3d  %-9s %s%s\n", i+1, m.Role, preview, status)
+	}
+	b.WriteString("/truncate n removes message n and everything after it")
+	a.chat.AddNotice(b.String())
+}
+
+// undoTurns deletes the last n turns (default 1) after confirmation
+func (a *App) undoTurns(args []string) tea.Cmd {
+	n := 1
+	if len(args) > 0 {
+		var err error
+		n, err = strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			a.chat.AddError(i18n.Tf("/undo: expected a positive number of turns, got %q", args[0]))
+			return nil
+		}
+	}
+	if a.conversation.Len() == 0 {
+		a.chat.AddError(i18n.T("nothing to undo"))
+		return nil
+	}
+	if a.refuseLocked("delete messages") {
+		return nil
+	}
+
+	start := a.conversation.TurnStart(n)
+	count := a.conversation.Len() - start
+	a.confirmTruncate(start, fmt.Sprintf("delete the last %d turn(s) (%d messages)?", n, count))
+	return nil
+}
+
+// truncateAt deletes message n (1-based, as shown by /history) and everything after it
+func (a *App) truncateAt(args []string) tea.Cmd {
+	if a.conversation.Len() == 0 {
+		a.chat.AddError(i18n.T("conversation is empty"))
+		return nil
+	}
+	if len(args) == 0 {
+		a.chat.AddError(i18n.T("usage: /truncate n (see /history for message numbers)"))
+		return nil
+	}
+	if a.refuseLocked("delete messages") {
+		return nil
+	}
+	idx, err := argIndex(args, a.conversation.Len())
+	if err != nil {
+		a.chat.AddError(i18n.T("/truncate: ") + err.Error())
+		return nil
+	}
+
+	count := a.conversation.Len() - idx
+	a.confirmTruncate(idx, fmt.Sprintf("delete message %d and the %d message(s) after it?", idx+1, count-1))
+	return nil
+}
+
+func (a *App) confirmTruncate(index int, question string) {
+	if a.streamChan != nil {
+		a.chat.AddError(i18n.T("wait for the current response to finish before editing the history"))
+		return
+	}
+
+	a.confirm(question, func() tea.Cmd {
+		removed := a.conversation.Truncate(index)
+		a.saveSession()
+		a.chat.ClearHistory()
+		a.chat.LoadHistory(a.conversation.Messages)
+		a.chat.AddNotice(i18n.Tf("deleted %d message(s)%s", len(removed), a.trashMessages(index, removed)))
+		return nil
+	})
+}
+
+// trashMessages keeps messages deleted from the conversation in the trash,
+// returning how to get them back for the notice. incognito conversations
+// leave nothing behind
+func (a *App) trashMessages(index int, messages []conversation.Message) string {
+	if a.store == nil || a.session.Incognito {
+		return ""
+	}
+	entry, err := a.store.TrashMessages(a.session, index, messages)
+	if err != nil {
+		logging.Errorf("moving deleted messages to the trash: %v", err)
+		return ", they couldn't be kept in the trash"
+	}
+	return fmt.Sprintf(", `ask trash restore %s` brings them back", entry.ID)
+}
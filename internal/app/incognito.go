@@ -0,0 +1,59 @@
+package app
+
+import (
+	"io"
+	"log"
+	"runtime/debug"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// toggleIncognito starts a new conversation with incognito mode switched.
+// the current conversation is saved (or wiped, if it was incognito) first
+func (a *App) toggleIncognito() tea.Cmd {
+	if a.streamChan != nil || a.pendingSend != nil {
+		a.chat.AddError(i18n.T("wait for the current response to finish before switching incognito mode"))
+		return nil
+	}
+	incognito := !a.session.Incognito
+	// memory extraction of the previous conversation, if any
+	cmd := a.clearConversation()
+	a.session.Incognito = incognito
+	if incognito {
+		a.applyIncognito()
+		a.chat.AddNotice(i18n.T("incognito: this conversation won't be saved, logged or remembered. /incognito again to leave"))
+		return cmd
+	}
+	log.SetOutput(a.logOutput)
+	if a.memories != nil {
+		a.memories.Pause(false)
+	}
+	a.chat.SetIncognito(false)
+	a.chat.AddNotice(i18n.T("left incognito mode, the incognito conversation was wiped"))
+	return cmd
+}
+
+// applyIncognito stops everything that would leave a trace of the
+// conversation: the log and new memories. saving is skipped in
+// saveSession
+func (a *App) applyIncognito() {
+	log.SetOutput(io.Discard)
+	if a.memories != nil {
+		a.memories.Pause(true)
+	}
+	a.chat.SetIncognito(true)
+}
+
+// wipe drops everything the app holds of the current conversation and hands
+// the memory back, so it doesn't linger after an incognito session
+func (a *App) wipe() {
+	a.conversation = conversation.New(nil)
+	a.session.Messages = nil
+	a.pendingAttachments = nil
+	a.proposedMemories = nil
+	a.queuedPrompts = nil
+	a.chat.ClearHistory()
+	debug.FreeOSMemory()
+}
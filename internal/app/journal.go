@@ -0,0 +1,26 @@
+package app
+
+import (
+	"github.com/scbenet/ask/internal/journal"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// writeJournal adds the response of model that just finished and its
+// prompt to the journal, only the rest of it if it continues one that was
+// journaled cut off (see App.journaled). incognito conversations and
+// ephemeral prompts are left out
+func (a *App) writeJournal(response, model string) {
+	continued := a.journaled > 0
+	response, a.journaled = response[a.journaled:], 0
+	// an ephemeral prompt and its answer would outlive it in the journal
+	if a.journal == nil || a.session.Incognito || a.conversation.Ephemeral() {
+		return
+	}
+	entry := journal.Entry{Response: response, Model: model, Session: a.session.Title, Continued: continued}
+	if prompt := a.conversation.Last("user"); prompt != nil {
+		entry.Prompt = prompt.Content
+	}
+	if err := a.journal.Append(entry); err != nil {
+		logging.Errorf("writing journal: %v", err)
+	}
+}
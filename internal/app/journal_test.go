@@ -0,0 +1,80 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/journal"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/ui"
+	"github.com/scbenet/ask/internal/ui/fanout"
+	"github.com/scbenet/ask/internal/vfs"
+)
+
+func TestJournal(t *testing.T) {
+	c := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local))
+	fsys := vfs.NewMem(c)
+	j := journal.Open("/journal", journal.WithFS(fsys), journal.WithClock(c))
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{{
+		llm.StreamChunkMsg{Content: "Hi there"},
+		llm.StreamEndMsg{FullResponse: "Hi there"},
+	}, {
+		llm.StreamEndMsg{FullResponse: "Noted"},
+	}}}
+	a := newTestAppWith(t, Options{
+		Client:      client,
+		Clock:       c,
+		Journal:     j,
+		ChatOptions: []ui.Option{ui.WithMarkdownStyle("notty"), ui.WithHyperlinks(false)},
+	})
+	tp := newTestProgram(t, a, 80, 24)
+	tp.typeText("hello")
+	tp.waitFor("Hi there")
+	// ephemeral prompts aren't journaled
+	tp.typeText("/ephemeral")
+	tp.typeText("my password is hunter2")
+	tp.waitFor("Noted")
+	tp.quit()
+
+	data, err := fsys.ReadFile(j.Path(c.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "## 12:00 · test:m · hello\n\n> hello\n\nHi there\n\n"; string(data) != want {
+		t.Errorf("journal is %q, want %q", data, want)
+	}
+}
+
+// TestJournalFanout journals the response kept from a fan-out, unless its
+// prompt was ephemeral
+func TestJournalFanout(t *testing.T) {
+	c := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local))
+	fsys := vfs.NewMem(c)
+	j := journal.Open("/journal", journal.WithFS(fsys), journal.WithClock(c))
+	l := newLoop(t, nil, &fakeClient{})
+	a := l.app
+	a.journal = j
+	a.config.Fanout = []string{"test:m", "test:n"}
+
+	fanOut := func(prompt string) {
+		l.run(a.startFanout([]string{prompt}))
+		l.until("the fan-out", func() bool { return a.fanoutView != nil && !a.fanoutView.Streaming() })
+		l.send(fanout.KeepMsg{Model: "test:n", Response: "re: " + prompt})
+	}
+	fanOut("hello")
+	a.markEphemeral(nil)
+	fanOut("my password is hunter2")
+
+	if prompt := a.conversation.Messages[2]; prompt.ExpiresIn != defaultEphemeralTurns {
+		t.Errorf("the kept prompt expires in %d turns", prompt.ExpiresIn)
+	}
+	data, err := fsys.ReadFile(j.Path(c.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "## 12:00 · test:n · hello\n\n> hello\n\nre: hello\n\n"; string(data) != want {
+		t.Errorf("journal is %q, want %q", data, want)
+	}
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/scbenet/ask/internal/edits"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/jsonschema"
+)
+
+// jsonPrompt puts the schema in the system prompt too, not every provider
+// passes response_format on to the model
+const jsonPrompt = "Answer only with a single JSON value matching this JSON schema, without any prose or code fences:\n\n"
+
+// setJSONMode handles /json: a schema file or an inline schema asks for
+// responses matching it, "off" stops asking and nothing shows the schema
+func (a *App) setJSONMode(args []string) {
+	if len(args) == 0 {
+		if a.jsonSchema == nil {
+			a.chat.AddNotice(i18n.T("structured output is off, /json schema.json or /json {inline schema} asks for JSON responses"))
+			return
+		}
+		a.chat.AddNotice(i18n.T("responses are JSON matching this schema, /json off to stop:\n") + indentJSON(string(a.jsonSchema.Raw())))
+		return
+	}
+	if len(args) == 1 && args[0] == "off" {
+		if a.jsonSchema == nil {
+			a.chat.AddError(i18n.T("structured output is already off"))
+			return
+		}
+		a.jsonSchema = nil
+		a.chat.SetJSONMode(false)
+		a.chat.AddNotice(i18n.T("structured output off, responses are answers again"))
+		return
+	}
+
+	arg := strings.Join(args, " ")
+	var data []byte
+	if strings.HasPrefix(arg, "{") {
+		data = []byte(arg)
+	} else {
+		var err error
+		if data, err = os.ReadFile(arg); err != nil {
+			a.chat.AddError(i18n.Tf("/json: %v", err))
+			return
+		}
+	}
+	schema, err := jsonschema.Compile(data)
+	if err != nil {
+		a.chat.AddError(i18n.Tf("/json: %v", err))
+		return
+	}
+	a.jsonSchema = schema
+	a.chat.SetJSONMode(true)
+	a.chat.AddNotice(i18n.T("structured output: responses are JSON matching the schema, checked as they arrive. /json off to stop"))
+}
+
+// structuredResponse checks a response in JSON mode against the schema. it
+// returns the JSON indented in a code block for the chat, and what's wrong
+// with it if anything
+func (a *App) structuredResponse(response string) (shown string, problems []string) {
+	text := strings.TrimSpace(response)
+	// models asked not to still wrap it in a code block now and then
+	if blocks := edits.CodeBlocks(text); len(blocks) == 1 && strings.HasPrefix(text, "```") {
+		text = blocks[0].Code
+	}
+	var v any
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return response, []string{fmt.Sprintf("the response isn't valid JSON: %v", err)}
+	}
+	for _, err := range a.jsonSchema.Validate(v) {
+		problems = append(problems, err.Error())
+	}
+	return "```json\n" + indentJSON(text) + "\n```", problems
+}
+
+// reportStructured tells whether a response matched the schema
+func (a *App) reportStructured(problems []string) {
+	if len(problems) == 0 {
+		a.chat.AddNotice(i18n.T("✓ response matches the schema"))
+		return
+	}
+	a.chat.AddError(i18n.T("the response doesn't match the schema:\n  - ") + strings.Join(problems, "\n  - ") + "\n/regenerate to ask again")
+}
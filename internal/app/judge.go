@@ -0,0 +1,234 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/logging"
+	"github.com/scbenet/ask/internal/ui/fanout"
+)
+
+// judgePrompt asks for a score per candidate, filled in with the rubric,
+// the question and the candidates
+const judgePrompt = `Judge the candidate answers to the question below. Score each one from 0 to 10 by this rubric: %s.
+
+Question:
+%s
+
+%s
+Reply with a JSON array and nothing else, one object per candidate in order, e.g. [{"candidate": 1, "score": 7, "reason": "one short sentence"}].`
+
+// defaultRubric is what answers are scored by unless the config has one
+const defaultRubric = "correctness first, then how completely it answers the question, then clarity and concision"
+
+// judgeScore is the judge's verdict on one candidate, numbered from 1
+type judgeScore struct {
+	Candidate int     `json:"candidate"`
+	Score     float64 `json:"score"`
+	Reason    string  `json:"reason"`
+}
+
+// judgedMsg carries the scores of a judge step. fanout is the id of the
+// fan-out judged, 0 for a regenerated response
+type judgedMsg struct {
+	fanout int
+	model  string
+	labels []string
+	scores []judgeScore
+	usage  *llm.Usage
+	err    error
+}
+
+// judgeModel returns the model doing the judging
+func (a *App) judgeModel() string {
+	if a.config.Judge.Model != "" {
+		return a.config.Judge.Model
+	}
+	return a.selectedModel
+}
+
+// judgeRequest asks the judge model to score the candidate answers to
+// question
+func (a *App) judgeRequest(question string, candidates []string) llm.Request {
+	rubric := a.config.Judge.Rubric
+	if rubric == "" {
+		rubric = defaultRubric
+	}
+	var b strings.Builder
+	for i, c := range candidates {
+		fmt.Fprintf(&b, "Candidate %d:\n%s\n\n", i+1, strings.TrimSpace(c))
+	}
+	prompt := fmt.Sprintf(judgePrompt, rubric, question, b.String())
+	return llm.Request{Model: a.judgeModel(), Messages: []llm.Message{{Role: "user", Content: prompt}}}
+}
+
+// overBudgetJudging is overBudget for sending req to the judge
+func (a *App) overBudgetJudging(req llm.Request) string {
+	return a.overBudgetBy(a.estimateRequest(req.Model, llm.EstimateMessageTokens(req.Messages)))
+}
+
+// judge sends req from judgeRequest. labels name the candidates in what's
+// shown, they aren't sent. streamed for the usage, which is paid for
+func (a *App) judge(fanoutID int, labels []string, req llm.Request) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), a.config.RequestTimeout.Std())
+		defer cancel()
+		end, err := a.generate(ctx, req)
+		if err != nil {
+			return judgedMsg{fanout: fanoutID, model: req.Model, err: err}
+		}
+		scores, err := parseScores(end.FullResponse, len(labels))
+		return judgedMsg{fanout: fanoutID, model: req.Model, labels: labels, scores: scores, usage: end.Usage, err: err}
+	}
+}
+
+// generate streams req to the end and returns the whole response, for the
+// requests that aren't shown as they arrive but whose usage is paid for
+func (a *App) generate(ctx context.Context, req llm.Request) (*llm.StreamEndMsg, error) {
+	ch := make(chan tea.Msg)
+	go a.llmClient.StreamGenerate(ctx, req, ch)
+	var end *llm.StreamEndMsg
+	var err error
+	for msg := range ch {
+		switch m := msg.(type) {
+		case llm.StreamEndMsg:
+			end = &m
+		case llm.StreamErrorMsg:
+			err = m.Err
+		}
+	}
+	if err == nil && end == nil {
+		err = errors.New("the stream ended without a response")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return end, nil
+}
+
+// parseScores reads the judge's reply for n candidates, ignoring scores
+// for candidates that don't exist
+func parseScores(reply string, n int) ([]judgeScore, error) {
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON array in the judge's reply %q", reply)
+	}
+	var parsed []judgeScore
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse the judge's scores: %w", err)
+	}
+	var scores []judgeScore
+	for _, s := range parsed {
+		if s.Candidate >= 1 && s.Candidate <= n {
+			s.Score = min(max(s.Score, 0), 10)
+			scores = append(scores, s)
+		}
+	}
+	if len(scores) == 0 {
+		return nil, fmt.Errorf("the judge scored none of the candidates: %q", reply)
+	}
+	return scores, nil
+}
+
+// judgeFanout scores the answers of the fan-out shown
+func (a *App) judgeFanout() tea.Cmd {
+	run := a.fanout
+	if run == nil {
+		return nil
+	}
+	panes, responses := a.fanoutView.Answers()
+	if len(panes) < 2 {
+		a.fanoutView.SetJudgeStatus("needs two answers to judge")
+		return nil
+	}
+	labels := make([]string, len(panes))
+	for i, p := range panes {
+		labels[i] = run.models[p]
+	}
+	req := a.judgeRequest(run.prompt.Content, responses)
+	if reason := a.overBudgetJudging(req); reason != "" {
+		a.fanoutView.SetJudgeStatus("not judging, " + reason)
+		return nil
+	}
+	run.judged = panes
+	a.fanoutView.SetJudgeStatus(fmt.Sprintf("%s is judging the answers…", req.Model))
+	return a.judge(run.id, labels, req)
+}
+
+// judgeRegenerated scores the response /regenerate replaced against the
+// one that replaced it
+func (a *App) judgeRegenerated() tea.Cmd {
+	last := a.conversation.Last("assistant")
+	prompt := a.conversation.Last("user")
+	if a.previousResponse == "" || a.regenerating || last == nil || prompt == nil {
+		a.chat.AddError(i18n.T("nothing to judge, /regenerate a response or /fanout a prompt first"))
+		return nil
+	}
+	// an expired prompt isn't sent anywhere anymore, the judge included
+	if prompt.Expired || last.Expired {
+		a.chat.AddError(i18n.T("nothing to judge, the response expired along with its ephemeral prompt"))
+		return nil
+	}
+	req := a.judgeRequest(prompt.Content, []string{a.previousResponse, last.Content})
+	send := func() tea.Cmd {
+		a.chat.AddNotice(i18n.Tf("%s is judging the previous and the new response…", req.Model))
+		return a.judge(0, []string{"previous", "new"}, req)
+	}
+	if reason := a.overBudgetJudging(req); reason != "" {
+		a.confirmOverBudget(reason, send, nil)
+		return nil
+	}
+	return send()
+}
+
+// handleJudged shows the scores next to the candidates they're for
+func (a *App) handleJudged(m judgedMsg) {
+	a.recordSpend(m.model, m.usage)
+	if m.fanout != 0 {
+		run := a.fanout
+		if run == nil || run.id != m.fanout {
+			// the fan-out was closed while it was judged
+			return
+		}
+		if m.err != nil {
+			logging.Errorf("judging fan-out: %v", m.err)
+			a.fanoutView.SetJudgeStatus("judging failed: " + m.err.Error())
+			return
+		}
+		scores := map[int]fanout.Score{}
+		for _, s := range m.scores {
+			scores[run.judged[s.Candidate-1]] = fanout.Score{Value: s.Score, Reason: s.Reason}
+		}
+		a.fanoutView.SetScores(scores)
+		a.fanoutView.SetJudgeStatus("judged by " + m.model)
+		return
+	}
+
+	if m.err != nil {
+		logging.Errorf("judging regenerated response: %v", m.err)
+		a.chat.AddError(i18n.Tf("judging failed: %v", m.err))
+		return
+	}
+	best := m.scores[0]
+	for _, s := range m.scores {
+		if s.Score > best.Score {
+			best = s
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "⚖ judged by %s:", m.model)
+	for _, s := range m.scores {
+		mark := ""
+		if s == best {
+			mark = " ★"
+		}
+		fmt.Fprintf(&b, "\n  %-8s %g/10%s  %s", m.labels[s.Candidate-1], s.Score, mark, s.Reason)
+	}
+	a.chat.AddNotice(b.String())
+}
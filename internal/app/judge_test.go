@@ -0,0 +1,42 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/scbenet/ask/internal/conversation"
+)
+
+func TestParseScores(t *testing.T) {
+	reply := "Here you go:\n```json\n" +
+		`[{"candidate": 1, "score": 12, "reason": "great"}, {"candidate": 2, "score": 4.5, "reason": "wrong"}, {"candidate": 3, "score": 9}]` +
+		"\n```"
+	scores, err := parseScores(reply, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 2 || scores[0].Score != 10 || scores[1].Score != 4.5 || scores[1].Reason != "wrong" {
+		t.Fatalf("parseScores = %+v", scores)
+	}
+
+	for _, reply := range []string{"no scores", `[{"candidate": 5, "score": 1}]`, "[not json]"} {
+		if _, err := parseScores(reply, 2); err == nil {
+			t.Errorf("parseScores(%q) should fail", reply)
+		}
+	}
+}
+
+// TestJudgeExpired doesn't send a response that expired with its ephemeral
+// prompt to the judge
+func TestJudgeExpired(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.conversation.Add(conversation.Message{Role: "user", Content: "secret", ExpiresIn: 1})
+	a.conversation.Add(conversation.Message{Role: "assistant", Content: "re: secret"})
+	a.previousResponse = "the secret"
+	if a.judgeRegenerated() == nil {
+		t.Fatal("didn't judge a response that hasn't expired")
+	}
+	a.conversation.Expire()
+	if a.judgeRegenerated() != nil {
+		t.Fatal("judged an expired response")
+	}
+}
@@ -0,0 +1,31 @@
+package app
+
+import "github.com/scbenet/ask/internal/i18n"
+
+// toggleLock makes a finished conversation read-only, or editable again.
+// a locked conversation takes no new prompts and its messages can't be
+// deleted, /clear still starts a new one
+func (a *App) toggleLock() {
+	if !a.session.Locked && a.conversation.Len() == 0 {
+		a.chat.AddError(i18n.T("nothing to lock, the conversation is empty"))
+		return
+	}
+	a.session.Locked = !a.session.Locked
+	a.chat.SetLocked(a.session.Locked)
+	a.saveSession()
+	if a.session.Locked {
+		a.chat.AddNotice(i18n.T("conversation locked, it is read-only until /lock is run again"))
+	} else {
+		a.chat.AddNotice(i18n.T("conversation unlocked"))
+	}
+}
+
+// refuseLocked reports whether the conversation is locked, explaining why
+// action can't be done if it is
+func (a *App) refuseLocked(action string) bool {
+	if !a.session.Locked {
+		return false
+	}
+	a.chat.AddError(i18n.Tf("can't %s, the conversation is locked. /lock unlocks it, /clear starts a new one", action))
+	return true
+}
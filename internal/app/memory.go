@@ -0,0 +1,202 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// extractMemoriesPrompt asks for lasting facts from a finished conversation
+const extractMemoriesPrompt = `The conversation above is over. List facts about the user worth remembering in future, unrelated conversations: stable preferences, their tools and setup, long running projects. Skip anything only relevant to this conversation's task, anything sensitive, and anything already known:
+%s
+Reply with a JSON array of short sentences and nothing else, e.g. ["Uses Arch Linux", "Prefers tabs over spaces"]. Reply with [] if there is nothing worth remembering.`
+
+// longest conversation sent for extraction, older messages are dropped
+const maxExtractMessages = 40
+
+// extractTimeout is the longest extraction may take, quitting waits for it
+const extractTimeout = time.Minute
+
+type memoriesExtractedMsg struct {
+	facts []string
+	err   error
+}
+
+// shouldExtractMemories reports whether the conversation ending now should
+// be scanned for new memories
+func (a *App) shouldExtractMemories(messages []conversation.Message) bool {
+	if a.memories == nil || !a.config.Memory.Extract || a.session.Incognito {
+		return false
+	}
+	for _, m := range messages {
+		if m.Role == "assistant" && m.Error == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// extractMemories asks a model which facts from messages are worth keeping.
+// the answers are only proposals, each is confirmed before it's stored
+func (a *App) extractMemories(messages []conversation.Message) tea.Cmd {
+	model := a.config.Memory.ExtractModel
+	if model == "" {
+		model = a.selectedModel
+	}
+	history := conversation.New(messages).LLMMessages()
+	if len(history) > maxExtractMessages {
+		history = history[len(history)-maxExtractMessages:]
+	}
+	history = answeredToolCalls(history)
+	known := "(nothing yet)"
+	if memories := a.memories.List(); len(memories) > 0 {
+		var lines []string
+		for _, m := range memories {
+			lines = append(lines, "- "+m.Text)
+		}
+		known = strings.Join(lines, "\n")
+	}
+	prompt := fmt.Sprintf(extractMemoriesPrompt, known)
+
+	a.extracting++
+	timeout := boundedTimeout(a.config.RequestTimeout.Std(), extractTimeout)
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		reply, err := a.llmClient.Generate(ctx, model, prompt, history)
+		if err != nil {
+			return memoriesExtractedMsg{err: err}
+		}
+		facts, err := parseFacts(reply)
+		return memoriesExtractedMsg{facts: facts, err: err}
+	}
+}
+
+// answeredToolCalls leaves out the tool calls that have no result, like
+// those of a turn cut off while its tools ran, and the results whose call
+// was dropped from the start of the history. providers reject both
+func answeredToolCalls(history []llm.Message) []llm.Message {
+	calls, results := map[string]bool{}, map[string]bool{}
+	for _, m := range history {
+		for _, call := range m.ToolCalls {
+			calls[call.ID] = true
+		}
+		if m.Role == "tool" {
+			results[m.ToolCallID] = true
+		}
+	}
+	unanswered := func(call llm.ToolCall) bool { return !results[call.ID] }
+	dropped := map[string]bool{}
+	var kept []llm.Message
+	for _, m := range history {
+		if slices.ContainsFunc(m.ToolCalls, unanswered) {
+			for _, call := range m.ToolCalls {
+				dropped[call.ID] = true
+			}
+			// what the model said before calling the tools still counts
+			m.ToolCalls = nil
+			if m.Content == "" {
+				continue
+			}
+		}
+		if m.Role == "tool" && (!calls[m.ToolCallID] || dropped[m.ToolCallID]) {
+			continue
+		}
+		kept = append(kept, m)
+	}
+	return kept
+}
+
+// parseFacts reads the JSON array out of the reply, models like to wrap it
+// in a code block or a sentence
+func parseFacts(reply string) ([]string, error) {
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON array in reply %q", reply)
+	}
+	var facts []string
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &facts); err != nil {
+		return nil, fmt.Errorf("failed to parse proposed memories: %w", err)
+	}
+	return facts, nil
+}
+
+// handleExtractedMemories queues the proposed memories for confirmation
+func (a *App) handleExtractedMemories(m memoriesExtractedMsg) tea.Cmd {
+	a.extracting--
+	if m.err != nil {
+		logging.Errorf("extracting memories: %v", m.err)
+		if a.quitting && a.extracting == 0 && len(a.proposedMemories) == 0 && a.pendingConfirm == nil {
+			return tea.Quit
+		}
+		return nil
+	}
+	for _, fact := range m.facts {
+		if fact = strings.TrimSpace(fact); fact != "" {
+			a.proposedMemories = append(a.proposedMemories, fact)
+		}
+	}
+	if a.pendingConfirm != nil {
+		// asked once the current question is answered, see answerConfirm
+		return nil
+	}
+	return a.proposeNextMemory()
+}
+
+// proposeNextMemory asks about the next proposed memory, quitting once
+// they're all answered and no extraction is running if that's what quitting
+// waits for
+func (a *App) proposeNextMemory() tea.Cmd {
+	if len(a.proposedMemories) == 0 {
+		if a.quitting && a.extracting == 0 {
+			return tea.Quit
+		}
+		return nil
+	}
+	fact := a.proposedMemories[0]
+	a.proposedMemories = a.proposedMemories[1:]
+	a.confirmOr(i18n.Tf("remember %q?", fact), func() tea.Cmd {
+		if _, err := a.memories.Add(fact, "model"); err != nil {
+			a.chat.AddError(i18n.Tf("failed to save memory: %v", err))
+		}
+		return nil
+	}, nil)
+	return nil
+}
+
+// quit ends the program, scanning the conversation for memories first when
+// extraction is enabled and waiting for the scan of a conversation cleared
+// before. pressing ctrl+c again skips that. a response still streaming is
+// cut off and saved as far as it got
+func (a *App) quit() tea.Cmd {
+	if a.streamChan != nil {
+		a.interruptStream()
+		a.saveSession()
+	}
+	if a.session.Incognito {
+		a.wipe()
+		return tea.Quit
+	}
+	if a.quitting {
+		return tea.Quit
+	}
+	extract := a.shouldExtractMemories(a.conversation.Messages)
+	if !extract && a.extracting == 0 {
+		return tea.Quit
+	}
+	a.quitting = true
+	a.chat.AddNotice(i18n.T("looking for things to remember… press ctrl+c again to quit now"))
+	if !extract {
+		return nil
+	}
+	return a.extractMemories(a.conversation.Messages)
+}
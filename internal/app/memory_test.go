@@ -0,0 +1,70 @@
+package app
+
+import (
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/memory"
+)
+
+func TestAnsweredToolCalls(t *testing.T) {
+	call := func(id string) []llm.ToolCall { return []llm.ToolCall{{ID: id}} }
+	history := []llm.Message{
+		// the call of this result was cut off with the older messages
+		{Role: "tool", ToolCallID: "old", Content: "old output"},
+		{Role: "user", Content: "list the files"},
+		{Role: "assistant", ToolCalls: call("ls")},
+		{Role: "tool", ToolCallID: "ls", Content: "main.go"},
+		{Role: "assistant", Content: "there's main.go"},
+		{Role: "user", Content: "and run the tests"},
+		// the turn was cut off while the tests ran
+		{Role: "assistant", Content: "running them", ToolCalls: []llm.ToolCall{{ID: "vet"}, {ID: "test"}}},
+		{Role: "tool", ToolCallID: "vet", Content: "ok"},
+	}
+	got := answeredToolCalls(history)
+	want := []string{"user: list the files", "assistant: ", "tool: main.go", "assistant: there's main.go", "user: and run the tests", "assistant: running them"}
+	if len(got) != len(want) {
+		t.Fatalf("kept %d messages, want %d: %+v", len(got), len(want), got)
+	}
+	for i, m := range got {
+		if m.Role+": "+m.Content != want[i] {
+			t.Errorf("message %d is %s: %q, want %q", i, m.Role, m.Content, want[i])
+		}
+	}
+	if len(got[1].ToolCalls) != 1 || len(got[5].ToolCalls) != 0 {
+		t.Errorf("tool calls kept: %+v, %+v", got[1].ToolCalls, got[5].ToolCalls)
+	}
+}
+
+// TestQuitWaitsForExtraction doesn't quit while a cleared conversation is
+// still scanned for memories, and asks about them first
+func TestQuitWaitsForExtraction(t *testing.T) {
+	a := newTestApp(t, nil)
+	memories, err := memory.Open(filepath.Join(t.TempDir(), "memories.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.memories = memories
+	a.config.Memory.Extract = true
+	// as left by /clear
+	a.extracting = 1
+
+	if cmd := a.quit(); cmd != nil || !a.quitting {
+		t.Fatalf("quit didn't wait for the extraction")
+	}
+	if cmd := a.handleExtractedMemories(memoriesExtractedMsg{facts: []string{"Uses fish"}}); cmd != nil || a.pendingConfirm == nil {
+		t.Fatalf("the proposed memory wasn't asked about")
+	}
+	cmd := a.answerConfirm(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	if cmd == nil {
+		t.Fatal("didn't quit once the memory was answered")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Fatal("didn't quit once the memory was answered")
+	}
+	if got := a.memories.List(); len(got) != 1 || got[0].Text != "Uses fish" {
+		t.Fatalf("memories are %+v", got)
+	}
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"log"
+
+	"github.com/scbenet/ask/internal/attach"
+	"github.com/scbenet/ask/internal/files"
+)
+
+// maxMentionFiles bounds how many files under the working directory are
+// offered after @, huge trees are cut off rather than walked entirely
+const maxMentionFiles = 20000
+
+// listMentionFiles returns the files @ completes, relative to the working
+// directory
+func listMentionFiles() []string {
+	paths, err := files.List(".", maxMentionFiles)
+	if err != nil {
+		log.Printf("listMentionFiles: %v", err)
+	}
+	return paths
+}
+
+// attachMentioned attaches the file picked after @ to the next prompt
+func (a *App) attachMentioned(path string) {
+	for _, p := range a.pendingAttachments {
+		if p.Name == path {
+			return
+		}
+	}
+	att, err := attach.FromFile(path)
+	if err != nil {
+		a.chat.AddError(err.Error())
+		return
+	}
+	a.pendingAttachments = append(a.pendingAttachments, att)
+	a.chat.AddAttachment(att.Summary())
+}
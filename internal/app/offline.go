@@ -0,0 +1,80 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// how often the provider is checked again while offline
+const offlineCheckInterval = 5 * time.Second
+
+// connectivityMsg tells whether the provider of the selected model could
+// be reached, recheck is set for the checks made while offline
+type connectivityMsg struct {
+	err     error
+	recheck bool
+}
+
+// connectivityTickMsg starts the next check while offline
+type connectivityTickMsg struct{}
+
+// providerURL is where the requests for model go, "" if it's not known
+func (a *App) providerURL(model string) string {
+	if name, _, ok := strings.Cut(model, ":"); ok {
+		if url, ok := a.providerURLs[name]; ok {
+			return url
+		}
+	}
+	// openrouter serves the models without a prefix
+	return a.providerURLs[""]
+}
+
+// checkConnectivity checks in the background whether the selected model's
+// provider can be reached at all
+func (a *App) checkConnectivity(recheck bool) tea.Cmd {
+	url := a.providerURL(a.selectedModel)
+	if url == "" || a.reachable == nil {
+		return nil
+	}
+	reachable := a.reachable
+	return func() tea.Msg {
+		return connectivityMsg{err: reachable(context.Background(), url), recheck: recheck}
+	}
+}
+
+// handleConnectivity goes offline when the provider can't be reached and
+// keeps checking until it can, then sending works again
+func (a *App) handleConnectivity(m connectivityMsg) tea.Cmd {
+	if a.reachable == nil {
+		// stopped checking since
+		return nil
+	}
+	if m.err == nil {
+		if a.offline {
+			logging.Infof("back online")
+			a.setOffline(false)
+			a.chat.AddNotice(i18n.T("back online, messages can be sent again"))
+		}
+		return nil
+	}
+	if a.offline && !m.recheck {
+		// already being checked
+		return nil
+	}
+	if !a.offline {
+		logging.Infof("offline, %s can't be reached: %v", a.providerURL(a.selectedModel), m.err)
+		a.setOffline(true)
+		a.chat.AddError(i18n.T("offline: the provider can't be reached, sending is paused until it can"))
+	}
+	return tea.Tick(offlineCheckInterval, func(time.Time) tea.Msg { return connectivityTickMsg{} })
+}
+
+func (a *App) setOffline(offline bool) {
+	a.offline = offline
+	a.chat.SetOffline(offline)
+}
@@ -0,0 +1,43 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/scbenet/ask/internal/ui"
+)
+
+// TestOffline goes offline after a request fails to connect and the provider
+// can't be reached, messages are kept in the input until it can again
+func TestOffline(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	client := &fakeClient{fail: map[string]error{"one": refused}}
+	l := newLoop(t, nil, client)
+	var checked string
+	l.app.reachable = func(ctx context.Context, url string) error {
+		checked = url
+		return refused
+	}
+
+	l.send(ui.SendPromptMsg{Prompt: "one"})
+	l.until("going offline", func() bool { return l.app.offline })
+	if checked != "http://127.0.0.1:1/chat/completions" {
+		t.Errorf("checked %q, not the test provider", checked)
+	}
+
+	l.send(ui.SendPromptMsg{Prompt: "two"})
+	if l.app.streamChan != nil || l.app.chat.GetInputValue() != "two" {
+		t.Fatalf("sent while offline, input %q", l.app.chat.GetInputValue())
+	}
+
+	l.send(connectivityMsg{recheck: true})
+	if l.app.offline {
+		t.Fatal("still offline once the provider can be reached")
+	}
+	l.send(ui.SendPromptMsg{Prompt: "two"})
+	l.until("the response", l.idle)
+	client.running.Wait()
+	l.checkConversation("user: one", "user: two", "assistant: re: two")
+}
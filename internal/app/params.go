@@ -0,0 +1,215 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/scbenet/ask/internal/config"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// paramNames are the settings /params takes, named as in the config
+var paramNames = []string{"temperature", "maxTokens", "seed", "frequencyPenalty", "presencePenalty", "repetitionPenalty", "minP", "logitBias"}
+
+// modelParams returns the settings for model, the config's with the ones
+// set by /params over them
+func (a *App) modelParams(model string) config.ModelConfig {
+	mc := a.config.ModelConfig(model)
+	o := a.paramOverrides
+	if o.Temperature != nil {
+		mc.Temperature = o.Temperature
+	}
+	if o.MaxTokens > 0 {
+		mc.MaxTokens = o.MaxTokens
+	}
+	if o.Seed != nil {
+		mc.Seed = o.Seed
+	}
+	if o.FrequencyPenalty != nil {
+		mc.FrequencyPenalty = o.FrequencyPenalty
+	}
+	if o.PresencePenalty != nil {
+		mc.PresencePenalty = o.PresencePenalty
+	}
+	if o.RepetitionPenalty != nil {
+		mc.RepetitionPenalty = o.RepetitionPenalty
+	}
+	if o.MinP != nil {
+		mc.MinP = o.MinP
+	}
+	if o.LogitBias != nil {
+		mc.LogitBias = o.LogitBias
+	}
+	return mc
+}
+
+// describeParams lists the sampling settings in mc, e.g. "seed 42"
+func describeParams(mc config.ModelConfig) []string {
+	var parts []string
+	if mc.Temperature != nil {
+		parts = append(parts, fmt.Sprintf("temperature %g", *mc.Temperature))
+	}
+	if mc.MaxTokens > 0 {
+		parts = append(parts, fmt.Sprintf("max tokens %d", mc.MaxTokens))
+	}
+	if mc.Seed != nil {
+		parts = append(parts, fmt.Sprintf("seed %d", *mc.Seed))
+	}
+	for _, p := range []struct {
+		name string
+		v    *float64
+	}{
+		{"frequency penalty", mc.FrequencyPenalty},
+		{"presence penalty", mc.PresencePenalty},
+		{"repetition penalty", mc.RepetitionPenalty},
+		{"min p", mc.MinP},
+	} {
+		if p.v != nil {
+			parts = append(parts, fmt.Sprintf("%s %g", p.name, *p.v))
+		}
+	}
+	if len(mc.Stop) > 0 {
+		parts = append(parts, fmt.Sprintf("%d stop sequence(s)", len(mc.Stop)))
+	}
+	if len(mc.LogitBias) > 0 {
+		parts = append(parts, fmt.Sprintf("logit bias on %d token(s)", len(mc.LogitBias)))
+	}
+	return parts
+}
+
+// setParams handles /params: without arguments it shows the settings for
+// the selected model, "name=value" sets one for the rest of the session,
+// "name=" drops it again and "reset" drops them all
+func (a *App) setParams(args []string) {
+	if len(args) == 0 {
+		if parts := describeParams(a.modelParams(a.selectedModel)); len(parts) > 0 {
+			a.chat.AddNotice(i18n.Tf("parameters for %s: %s", a.selectedModel, strings.Join(parts, ", ")))
+		} else {
+			a.chat.AddNotice(i18n.Tf("no parameters set for %s, the provider's defaults apply", a.selectedModel))
+		}
+		a.warnUnsupportedParams(a.selectedModel)
+		return
+	}
+	if len(args) == 1 && args[0] == "reset" {
+		a.paramOverrides = config.ModelConfig{}
+		a.chat.AddNotice(i18n.T("parameters set with /params dropped, the config's apply again"))
+		return
+	}
+
+	o := a.paramOverrides
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			a.chat.AddError(i18n.T("usage: /params [name=value ...] or /params reset, e.g. /params seed=42 minP=0.05"))
+			return
+		}
+		if err := setParam(&o, name, value); err != nil {
+			a.chat.AddError(err.Error())
+			return
+		}
+	}
+	if err := o.CheckParams(); err != nil {
+		a.chat.AddError(err.Error())
+		return
+	}
+	a.paramOverrides = o
+	if parts := describeParams(o); len(parts) > 0 {
+		a.chat.AddNotice(i18n.Tf("%s for the rest of the session, /params reset drops them", strings.Join(parts, ", ")))
+	} else {
+		a.chat.AddNotice(i18n.T("no parameters set with /params anymore, the config's apply"))
+	}
+	a.warnUnsupportedParams(a.selectedModel)
+}
+
+// setParam sets the named setting of o to value, "" unsets it
+func setParam(o *config.ModelConfig, name, value string) error {
+	floats := map[string]**float64{
+		"temperature":       &o.Temperature,
+		"frequencyPenalty":  &o.FrequencyPenalty,
+		"presencePenalty":   &o.PresencePenalty,
+		"repetitionPenalty": &o.RepetitionPenalty,
+		"minP":              &o.MinP,
+	}
+	if dst, ok := floats[name]; ok {
+		if value == "" {
+			*dst = nil
+			return nil
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s must be a number, not %q", name, value)
+		}
+		*dst = &v
+		return nil
+	}
+
+	switch name {
+	case "maxTokens":
+		if value == "" {
+			o.MaxTokens = 0
+			return nil
+		}
+		v, err := strconv.Atoi(value)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("maxTokens must be a positive whole number, not %q", value)
+		}
+		o.MaxTokens = v
+	case "seed":
+		if value == "" {
+			o.Seed = nil
+			return nil
+		}
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("seed must be a whole number, not %q", value)
+		}
+		o.Seed = &v
+	case "logitBias":
+		if value == "" {
+			o.LogitBias = nil
+			return nil
+		}
+		// a new map, the one replaced may still be in the config
+		bias := map[string]float64{}
+		for _, pair := range strings.Split(value, ",") {
+			token, b, ok := strings.Cut(pair, ":")
+			v, err := strconv.ParseFloat(b, 64)
+			if !ok || err != nil {
+				return errors.New("logitBias is token:bias pairs separated by commas, e.g. logitBias=50256:-100,1234:5")
+			}
+			bias[token] = v
+		}
+		o.LogitBias = bias
+	default:
+		return fmt.Errorf("unknown parameter %q, /params takes %s", name, strings.Join(paramNames, ", "))
+	}
+	return nil
+}
+
+// warnUnsupportedParams says which settings model's provider won't get,
+// and which ones the model doesn't take by openrouter's model list
+func (a *App) warnUnsupportedParams(model string) {
+	name := ""
+	if prefix, _, ok := strings.Cut(model, ":"); ok {
+		if _, ok := a.providerURLs[prefix]; ok {
+			name = prefix
+		}
+	}
+	params := a.newRequest(model, nil).Params
+	var unsupported []string
+	if checker, ok := a.paramCheckers[name]; ok {
+		unsupported = checker.UnsupportedParams(params)
+	}
+	for _, param := range a.modelInfo[model].UnsupportedParams(params) {
+		if !slices.Contains(unsupported, param) {
+			unsupported = append(unsupported, param)
+		}
+	}
+	if len(unsupported) == 0 {
+		return
+	}
+	a.chat.AddNotice(i18n.Tf("%s doesn't take %s, left out of its requests", model, strings.Join(unsupported, ", ")))
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"github.com/scbenet/ask/internal/edits"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// patchPrompt asks for diffs only while /patch is on
+const patchPrompt = "Answer only with unified diffs against the attached files, in a ```diff code block. " +
+	"Start each file with --- a/path and +++ b/path lines naming it exactly as it's attached, and each hunk with an @@ line. " +
+	"Keep three lines of unchanged context around every change, copied exactly from the file. " +
+	"Don't explain the changes or repeat whole files."
+
+// togglePatchMode switches between normal answers and answers that are
+// only diffs, which are checked and offered for writing as they arrive
+func (a *App) togglePatchMode() {
+	a.patchMode = !a.patchMode
+	a.chat.SetPatchMode(a.patchMode)
+	if !a.patchMode {
+		a.chat.AddNotice(i18n.T("patch mode off, responses are answers again"))
+		return
+	}
+	notice := i18n.T("patch mode: the model answers with diffs against the attached files, which are checked and shown before they're written. /patch again to leave")
+	if len(a.pendingAttachments) == 0 && !a.hasAttachments() {
+		notice += i18n.T(". nothing is attached yet, type @ to attach a file or /add-dir a directory")
+	}
+	a.chat.AddNotice(notice)
+}
+
+// hasAttachments reports whether any message has something attached
+func (a *App) hasAttachments() bool {
+	for _, m := range a.conversation.Messages {
+		if len(m.Attachments) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPatch validates the diffs of a response in patch mode and offers to
+// write them if every one applies cleanly, nothing is written otherwise
+func (a *App) checkPatch(response string) {
+	var diffs []edits.Edit
+	for _, e := range edits.Extract(response) {
+		if e.Diff != nil {
+			diffs = append(diffs, e)
+		}
+	}
+	if len(diffs) == 0 {
+		// a diff without a code block around it
+		parsed, err := edits.ParseDiff(response)
+		if err != nil {
+			a.chat.AddError(i18n.T("the response has no unified diff, /regenerate to ask again"))
+			return
+		}
+		for i := range parsed {
+			diffs = append(diffs, edits.Edit{Path: parsed[i].Path(), Diff: &parsed[i]})
+		}
+	}
+
+	changes, errs := fileChanges(diffs)
+	if len(errs) > 0 {
+		for _, err := range errs {
+			a.chat.AddError(i18n.Tf("/patch: %v", err))
+		}
+		a.chat.AddError(i18n.T("the patch doesn't apply cleanly, nothing was written. /regenerate to ask again"))
+		return
+	}
+	a.offerChanges(changes)
+}
@@ -0,0 +1,929 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/config"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/journal"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/session"
+	"github.com/scbenet/ask/internal/ui"
+	"github.com/scbenet/ask/internal/vfs"
+)
+
+// syncBuffer is the program's output, written by its renderer and read by
+// the test
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+// Reset drops what was drawn so far, waitFor only sees what's drawn after
+func (b *syncBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.b.Reset()
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+// testProgram runs the app in a real bubbletea program, like teatest does:
+// keys and resizes are sent to it and what it draws is read back. unlike
+// the loop every message is delivered, ticks and redraws too.
+//
+// teatest itself isn't used: x/exp/teatest has no tagged releases, only
+// pseudo-versions that move with the rest of x/exp, and its golden file
+// helpers would compare whole frames where these tests look for a line
+type testProgram struct {
+	t     *testing.T
+	p     *tea.Program
+	out   *syncBuffer
+	final chan tea.Model
+}
+
+func newTestProgram(t *testing.T, a *App, width, height int) *testProgram {
+	tp := &testProgram{t: t, out: &syncBuffer{}, final: make(chan tea.Model, 1)}
+	tp.p = tea.NewProgram(a, tea.WithInput(&bytes.Buffer{}), tea.WithOutput(tp.out), tea.WithoutSignals())
+	go func() {
+		final, err := tp.p.Run()
+		if err != nil {
+			t.Errorf("program failed: %v", err)
+		}
+		tp.final <- final
+	}()
+	t.Cleanup(tp.p.Kill)
+	tp.p.Send(tea.WindowSizeMsg{Width: width, Height: height})
+	return tp
+}
+
+// typeText types text into the input and presses enter
+func (tp *testProgram) typeText(text string) {
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(text)})
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyEnter})
+}
+
+// waitFor waits until text was drawn
+func (tp *testProgram) waitFor(text string) {
+	tp.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(ansi.Strip(tp.out.String()), text) {
+		if time.Now().After(deadline) {
+			tp.t.Fatalf("%q wasn't drawn, the output is\n%s", text, ansi.Strip(tp.out.String()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// quit ends the program and returns the app as it was left
+func (tp *testProgram) quit() *App {
+	tp.t.Helper()
+	tp.p.Quit()
+	select {
+	case final := <-tp.final:
+		return final.(*App)
+	case <-time.After(5 * time.Second):
+		tp.t.Fatal("the program didn't quit")
+		return nil
+	}
+}
+
+// newProgramApp is an app answering from client, rendering plain text at a
+// fixed time so its output can be compared
+func newProgramApp(t *testing.T, client llm.LLMClient) *App {
+	return newTestAppWith(t, Options{
+		Client:      client,
+		Clock:       clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)),
+		ChatOptions: []ui.Option{ui.WithMarkdownStyle("notty"), ui.WithHyperlinks(false)},
+	})
+}
+
+// TestProgramStream sends a prompt and watches the response stream in
+func TestProgramStream(t *testing.T) {
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{{
+		llm.StreamChunkMsg{Content: "Hi "},
+		llm.StreamChunkMsg{Content: "there"},
+		llm.StreamEndMsg{FullResponse: "Hi there"},
+	}}}
+	tp := newTestProgram(t, newProgramApp(t, client), 80, 24)
+	tp.typeText("hello")
+	tp.waitFor("Hi there")
+	a := tp.quit()
+
+	var got []string
+	for _, m := range a.conversation.Messages {
+		got = append(got, m.Role+": "+m.Content)
+	}
+	if strings.Join(got, "\n") != "user: hello\nassistant: Hi there" {
+		t.Fatalf("conversation is %q", got)
+	}
+	sent := client.Requests()[0].Messages
+	if len(sent) != 1 || sent[0].Content != "hello" {
+		t.Fatalf("sent %+v", sent)
+	}
+}
+
+// TestProgramResize shrinks the window after a response, everything drawn
+// has to fit the new size
+func TestProgramResize(t *testing.T) {
+	long := strings.Repeat("a rather long response that has to be wrapped ", 10)
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{{
+		llm.StreamChunkMsg{Content: long},
+		llm.StreamEndMsg{FullResponse: long},
+	}}}
+	tp := newTestProgram(t, newProgramApp(t, client), 100, 30)
+	tp.typeText("tell me something long")
+	tp.waitFor("has to be wrapped")
+	tp.p.Send(tea.WindowSizeMsg{Width: 50, Height: 20})
+	a := tp.quit()
+
+	view := a.View()
+	if h := lipgloss.Height(view); h > 20 {
+		t.Errorf("drew %d lines in a 20 line window", h)
+	}
+	for _, line := range strings.Split(view, "\n") {
+		if w := ansi.StringWidth(line); w > 50 {
+			t.Fatalf("line %q is %d wide in a 50 column window", ansi.Strip(line), w)
+		}
+	}
+}
+
+// TestProgramInline prints the conversation to the scrollback, the view
+// keeps only the input
+func TestProgramInline(t *testing.T) {
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{{
+		llm.StreamChunkMsg{Content: "Hi there"},
+		llm.StreamEndMsg{FullResponse: "Hi there"},
+	}}}
+	a := newTestAppWith(t, Options{
+		Client:      client,
+		Clock:       clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)),
+		ChatOptions: []ui.Option{ui.WithMarkdownStyle("notty"), ui.WithHyperlinks(false), ui.WithInline()},
+	})
+	tp := newTestProgram(t, a, 80, 24)
+	tp.typeText("hello")
+	tp.waitFor("> hello")
+	tp.waitFor("Hi there")
+	a = tp.quit()
+
+	if view := ansi.Strip(a.View()); strings.Contains(view, "hello") || strings.Contains(view, "Hi there") {
+		t.Errorf("the conversation is still in the view:\n%s", view)
+	}
+	if n := strings.Count(ansi.Strip(tp.out.String()), "> hello"); n != 1 {
+		t.Errorf("the prompt was printed %d times", n)
+	}
+}
+
+// TestProgramAccessible labels every message with its role, announces the
+// wait and prints the response once instead of redrawing it
+func TestProgramAccessible(t *testing.T) {
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{
+		{
+			llm.StreamChunkMsg{Content: "Hi "},
+			llm.StreamChunkMsg{Content: "there"},
+			llm.StreamEndMsg{FullResponse: "Hi there"},
+		},
+		{llm.StreamErrorMsg{Err: fmt.Errorf("connection reset")}},
+	}}
+	a := newTestAppWith(t, Options{
+		Client:      client,
+		Clock:       clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)),
+		ChatOptions: []ui.Option{ui.WithMarkdownStyle("dark"), ui.WithAccessible()},
+	})
+	tp := newTestProgram(t, a, 80, 24)
+	tp.typeText("hello")
+	tp.waitFor("You:")
+	tp.waitFor("hello")
+	tp.waitFor("status: waiting for the response")
+	tp.waitFor("Assistant: test:m")
+	tp.typeText("again")
+	tp.waitFor("error: the request failed: connection reset")
+	a = tp.quit()
+
+	out := ansi.Strip(tp.out.String())
+	if n := strings.Count(out, "Hi there"); n != 1 {
+		t.Errorf("the response was drawn %d times", n)
+	}
+	if strings.Contains(out, "Hi \n") || strings.Contains(out, "> hello") {
+		t.Errorf("the partial response or the prompt prefix was drawn:\n%s", out)
+	}
+	if !a.chat.Accessible() {
+		t.Error("the chat isn't accessible")
+	}
+}
+
+// TestProgramCitations shows the sources of a response as footnotes and
+// lists them with /cite
+func TestProgramCitations(t *testing.T) {
+	sources := []llm.Source{{Title: "Effective Go", URL: "https://go.dev/doc/effective_go"}, {URL: "https://go.dev/blog/pipelines"}}
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{{
+		llm.StreamChunkMsg{Content: "Use channels [1] and pipelines [2]."},
+		llm.StreamEndMsg{FullResponse: "Use channels [1] and pipelines [2].", Sources: sources},
+	}}}
+	tp := newTestProgram(t, newProgramApp(t, client), 100, 30)
+	tp.typeText("how do I do concurrency in go")
+	tp.waitFor("1. Effective Go — https://go.dev/doc/effective_go")
+	tp.waitFor("2. https://go.dev/blog/pipelines")
+	tp.typeText("/cite")
+	tp.waitFor("[2] https://go.dev/blog/pipelines")
+	tp.quit()
+}
+
+// TestProgramLinkPicker picks from the links of a response with /open
+func TestProgramLinkPicker(t *testing.T) {
+	response := "see https://go.dev/doc and https://pkg.go.dev"
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{{
+		llm.StreamChunkMsg{Content: response},
+		llm.StreamEndMsg{FullResponse: response},
+	}}}
+	tp := newTestProgram(t, newProgramApp(t, client), 100, 30)
+	tp.typeText("where are the go docs")
+	tp.waitFor("2 link(s) in response")
+	tp.typeText("/open")
+	tp.waitFor("[2] https://pkg.go.dev")
+	tp.out.Reset()
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyEsc})
+	tp.waitFor("Write a message")
+	a := tp.quit()
+	if a.activeView != chatView || a.linkPicker != nil {
+		t.Error("the picker is still open")
+	}
+}
+
+// TestProgramMessageStyles labels the messages and drops the prompt prefix
+func TestProgramMessageStyles(t *testing.T) {
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{{
+		llm.StreamChunkMsg{Content: "Hi there"},
+		llm.StreamEndMsg{FullResponse: "Hi there"},
+	}}}
+	a := newTestAppWith(t, Options{
+		Client: client,
+		ChatOptions: []ui.Option{
+			ui.WithMarkdownStyle("notty"),
+			ui.WithHyperlinks(false),
+			ui.WithMessageStyles(ui.MessageStyle{Label: "You:"}, ui.MessageStyle{Label: "Model:"}),
+		},
+	})
+	tp := newTestProgram(t, a, 80, 24)
+	tp.typeText("hello")
+	tp.waitFor("Hi there")
+	a = tp.quit()
+
+	var lines []string
+	for _, line := range strings.Split(ansi.Strip(a.View()), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if got := strings.Join(lines[:4], "\n"); got != "You:\nhello\nModel:\nHi there" {
+		t.Errorf("the history starts with\n%s", got)
+	}
+}
+
+// TestProgramTimestamps shows the time and model above the messages until
+// /timestamps turns them off
+func TestProgramTimestamps(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{{
+		llm.StreamChunkMsg{Content: "Hi there"},
+		llm.StreamEndMsg{FullResponse: "Hi there"},
+	}}}
+	a := newTestAppWith(t, Options{
+		Client:      client,
+		Clock:       clock.NewFake(now),
+		ChatOptions: []ui.Option{ui.WithMarkdownStyle("notty"), ui.WithHyperlinks(false), ui.WithTimestamps(true)},
+	})
+	tp := newTestProgram(t, a, 80, 24)
+	tp.typeText("hello")
+	tp.waitFor(now.Local().Format("15:04") + " · test:m")
+	tp.typeText("/timestamps")
+	tp.waitFor("timestamps hidden")
+	a = tp.quit()
+
+	if view := ansi.Strip(a.View()); strings.Contains(view, "test:m") {
+		t.Errorf("the model is still shown:\n%s", view)
+	}
+}
+
+// TestProgramRegenerate replaces a response and diffs the two
+func TestProgramRegenerate(t *testing.T) {
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{
+		{
+			llm.StreamChunkMsg{Content: "The sky is blue"},
+			llm.StreamEndMsg{FullResponse: "The sky is blue"},
+		},
+		{
+			llm.StreamChunkMsg{Content: "The sky is green"},
+			llm.StreamEndMsg{FullResponse: "The sky is green"},
+		},
+	}}
+	tp := newTestProgram(t, newProgramApp(t, client), 80, 24)
+	tp.typeText("what color is the sky?")
+	tp.waitFor("The sky is blue")
+	tp.typeText("/regenerate")
+	tp.waitFor("/diff shows what changed")
+	tp.typeText("/diff")
+	tp.waitFor("1 word(s) removed, 1 added")
+	a := tp.quit()
+
+	var got []string
+	for _, m := range a.conversation.Messages {
+		got = append(got, m.Role+": "+m.Content)
+	}
+	if strings.Join(got, "\n") != "user: what color is the sky?\nassistant: The sky is green" {
+		t.Fatalf("conversation is %q", got)
+	}
+}
+
+// TestProgramSendKey sends with ctrl+enter, enter inserting new lines
+func TestProgramSendKey(t *testing.T) {
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{{
+		llm.StreamEndMsg{FullResponse: "Two lines"},
+	}}}
+	a := newTestAppWith(t, Options{
+		Client:      client,
+		Clock:       clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)),
+		ChatOptions: []ui.Option{ui.WithMarkdownStyle("notty"), ui.WithHyperlinks(false), ui.WithSendKey(ui.SendKeyCtrlEnter)},
+	})
+	tp := newTestProgram(t, a, 80, 24)
+	tp.typeText("first")
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("second")})
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyCtrlJ})
+	tp.waitFor("Two lines")
+	a = tp.quit()
+
+	if got := a.conversation.Messages[0].Content; got != "first\nsecond" {
+		t.Fatalf("sent %q", got)
+	}
+}
+
+// TestProgramFileMention completes a path after @ and attaches the file
+func TestProgramFileMention(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "cmd"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"cmd/main.go": "package main\n", "README.md": "# readme\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{{
+		llm.StreamEndMsg{FullResponse: "It's a main package"},
+	}}}
+	tp := newTestProgram(t, newProgramApp(t, client), 80, 24)
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("what is in @")})
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("mgo")})
+	tp.waitFor("> cmd/main.go")
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyTab})
+	tp.waitFor("📎 cmd/main.go (1 lines)")
+	tp.typeText("?")
+	tp.waitFor("It's a main package")
+	a := tp.quit()
+
+	if got := a.conversation.Messages[0].Content; got != "what is in @cmd/main.go ?" {
+		t.Fatalf("sent %q", got)
+	}
+	if atts := a.conversation.Messages[0].Attachments; len(atts) != 1 || atts[0].Content != "package main\n" {
+		t.Fatalf("attached %+v", atts)
+	}
+}
+
+// TestProgramAddDir attaches a directory, leaving out what's ignored
+func TestProgramAddDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":          "package main\n",
+		"util.go":          "package main\n\nfunc util() {}\n",
+		"debug.log":        "noise\n",
+		"logo.png":         "\x89PNG\r\n\x1a\n\xff\xfe",
+		"fixtures/big.txt": "fixture\n",
+		".gitignore":       "*.log\n",
+		".askignore":       "fixtures/\n",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+
+	tp := newTestProgram(t, newProgramApp(t, &llm.MockLLMClient{}), 100, 30)
+	tp.typeText("/add-dir")
+	tp.waitFor("left out 1 binary")
+	a := tp.quit()
+
+	var names []string
+	for _, att := range a.pendingAttachments {
+		names = append(names, att.Name)
+	}
+	if strings.Join(names, " ") != "main.go util.go" {
+		t.Fatalf("attached %q", names)
+	}
+}
+
+// TestProgramContext excludes, reorders and removes attachments in the
+// context view
+func TestProgramContext(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("package "+name[:1]+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+
+	tp := newTestProgram(t, newProgramApp(t, &llm.MockLLMClient{}), 100, 30)
+	tp.typeText("/add-dir")
+	tp.waitFor("3 file(s)")
+	tp.typeText("/context")
+	tp.waitFor("[x] c.go")
+	tp.p.Send(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	tp.waitFor("[ ] a.go")
+	// the lines redrawn show each change was made
+	tp.out.Reset()
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("J")})
+	tp.waitFor("[x] b.go")
+	tp.out.Reset()
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyDown})
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	tp.waitFor("tokens included")
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyEsc})
+	tp.waitFor("Write a message")
+	a := tp.quit()
+
+	var got []string
+	for _, att := range a.pendingAttachments {
+		got = append(got, fmt.Sprintf("%s %v", att.Name, att.Excluded))
+	}
+	if strings.Join(got, ", ") != "b.go false, a.go true" {
+		t.Fatalf("attachments are %q", got)
+	}
+}
+
+// TestProgramRereadAttachment sends an attached file as it is when the
+// message is sent, and leaves what earlier messages were sent with alone
+func TestProgramRereadAttachment(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main // v1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{
+		{llm.StreamEndMsg{FullResponse: "first answer"}},
+		{llm.StreamEndMsg{FullResponse: "second answer"}},
+	}}
+	tp := newTestProgram(t, newProgramApp(t, client), 100, 30)
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("look at @main")})
+	tp.waitFor("> main.go")
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyTab})
+	tp.waitFor("📎 main.go")
+	if err := os.WriteFile(path, []byte("package main // v2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tp.typeText("")
+	tp.waitFor("main.go changed since attached")
+	tp.waitFor("first answer")
+	if err := os.WriteFile(path, []byte("package main // v3\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tp.typeText("and now?")
+	tp.waitFor("second answer")
+	tp.quit()
+
+	requests := client.Requests()
+	if got := requests[0].Messages[0].Content; !strings.Contains(got, "// v2") {
+		t.Fatalf("the first request sent %q", got)
+	}
+	if got := requests[1].Messages[0].Content; !strings.Contains(got, "// v2") || strings.Contains(got, "// v3") {
+		t.Fatalf("the second request sent %q", got)
+	}
+}
+
+// TestProgramApply writes the files a response edits after showing the
+// changes
+func TestProgramApply(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile("main.go", []byte("package main\n\nfunc main() {\n\tprintln(\"hello\")\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	response := "Add a helper:\n\n```go util/util.go\npackage util\n```\n\nand call it:\n\n```diff\n--- a/main.go\n+++ b/main.go\n@@ -3,3 +3,3 @@\n func main() {\n-\tprintln(\"hello\")\n+\tprintln(\"hi\")\n }\n```\n"
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{{llm.StreamEndMsg{FullResponse: response}}}}
+	tp := newTestProgram(t, newProgramApp(t, client), 100, 40)
+	tp.typeText("change it")
+	tp.waitFor("response edits 2 file(s)")
+	tp.typeText("/apply")
+	tp.waitFor("2. main.go (diff)")
+	tp.typeText("/apply all")
+	tp.waitFor("write util/util.go, main.go? (y/n)")
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	tp.waitFor("wrote main.go")
+	tp.quit()
+
+	for path, want := range map[string]string{
+		"util/util.go": "package util\n",
+		"main.go":      "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n",
+	} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s is %q, want %q", path, data, want)
+		}
+	}
+}
+
+// TestProgramApplyConfined asks before deleting and refuses files outside
+// the working directory
+func TestProgramApplyConfined(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile("old.txt", []byte("bye\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	response := "```diff\n--- a/old.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-bye\n--- /dev/null\n+++ b/../escaped.txt\n@@ -0,0 +1 @@\n+out\n```\n"
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{{llm.StreamEndMsg{FullResponse: response}}}}
+	tp := newTestProgram(t, newProgramApp(t, client), 100, 40)
+	tp.typeText("clean up")
+	tp.waitFor("response edits 2 file(s)")
+	tp.typeText("/apply all")
+	tp.waitFor("../escaped.txt is outside the working directory")
+	tp.waitFor("delete old.txt? (y/n)")
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	tp.waitFor("deleted old.txt")
+	tp.quit()
+
+	if _, err := os.Stat("old.txt"); !os.IsNotExist(err) {
+		t.Errorf("old.txt wasn't deleted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "..", "escaped.txt")); !os.IsNotExist(err) {
+		t.Errorf("a file outside the working directory was written: %v", err)
+	}
+}
+
+// TestProgramPatch asks for diffs and only offers the ones that apply
+func TestProgramPatch(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile("greet.txt", []byte("hello\nworld\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{
+		{llm.StreamEndMsg{FullResponse: "--- a/greet.txt\n+++ b/greet.txt\n@@ -1,2 +1,2 @@\n-hallo\n+hi\n world\n"}},
+		{llm.StreamEndMsg{FullResponse: "```diff\n--- a/greet.txt\n+++ b/greet.txt\n@@ -1,2 +1,2 @@\n-hello\n+hi\n world\n```"}},
+	}}
+	tp := newTestProgram(t, newProgramApp(t, client), 100, 40)
+	tp.typeText("/patch")
+	tp.waitFor("patch mode:")
+	tp.typeText("say hi")
+	tp.waitFor("nothing was written")
+	tp.typeText("/regenerate")
+	tp.waitFor("write greet.txt? (y/n)")
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	tp.waitFor("wrote greet.txt")
+	tp.quit()
+
+	if data, _ := os.ReadFile("greet.txt"); string(data) != "hi\nworld\n" {
+		t.Errorf("greet.txt is %q", data)
+	}
+	if system := client.Requests()[0].Messages[0]; system.Role != "system" || !strings.Contains(system.Content, "unified diffs") {
+		t.Errorf("the request started with %+v", system)
+	}
+}
+
+// TestProgramReplay steps through a saved session and quits when the
+// replay is left
+func TestProgramReplay(t *testing.T) {
+	sess := session.New("replayed")
+	sess.Messages = []conversation.Message{
+		{Role: "user", Content: "first question"},
+		{Role: "assistant", Content: "first answer"},
+		{Role: "user", Content: "second question"},
+		{Role: "assistant", Content: "second answer"},
+	}
+	a := newTestAppWith(t, Options{
+		Session:     sess,
+		Replay:      true,
+		Client:      &llm.MockLLMClient{},
+		Clock:       clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)),
+		ChatOptions: []ui.Option{ui.WithMarkdownStyle("notty"), ui.WithHyperlinks(false)},
+	})
+	tp := newTestProgram(t, a, 80, 24)
+	tp.waitFor("replay 1/4")
+	if strings.Contains(ansi.Strip(tp.out.String()), "first answer") {
+		t.Fatalf("the answer was shown before stepping to it\n%s", ansi.Strip(tp.out.String()))
+	}
+	tp.p.Send(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+	tp.waitFor("first answer")
+	tp.waitFor("replay 2/4")
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("G")})
+	tp.waitFor("replay 4/4 · end")
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	tp.waitFor("replay 3/4")
+
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	select {
+	case final := <-tp.final:
+		if got := final.(*App).conversation.Len(); got != 4 {
+			t.Fatalf("the replayed conversation has %d messages", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("leaving the replay didn't quit")
+	}
+}
+
+// TestProgramReplayAccessible replays a session in accessible mode, where
+// the messages go to the scrollback and there's no stepping back
+func TestProgramReplayAccessible(t *testing.T) {
+	sess := session.New("replayed")
+	sess.Messages = []conversation.Message{
+		{Role: "user", Content: "first question"},
+		{Role: "assistant", Content: "first answer"},
+	}
+	a := newTestAppWith(t, Options{
+		Session:     sess,
+		Replay:      true,
+		Client:      &llm.MockLLMClient{},
+		Clock:       clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)),
+		ChatOptions: []ui.Option{ui.WithAccessible()},
+	})
+	tp := newTestProgram(t, a, 80, 24)
+	tp.waitFor("replay 1/2 · space next · q leave")
+	tp.p.Send(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+	tp.waitFor("first answer")
+	tp.waitFor("replay 2/2 · end")
+	tp.out.Reset()
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	select {
+	case <-tp.final:
+	case <-time.After(5 * time.Second):
+		t.Fatal("leaving the replay didn't quit")
+	}
+	if out := ansi.Strip(tp.out.String()); strings.Contains(out, "replay 1/2") || strings.Contains(out, "first question") {
+		t.Fatalf("b stepped back and printed the replay again\n%s", out)
+	}
+}
+
+// TestProgramReplayCommand replays the current conversation and shows all
+// of it again once the replay is left
+func TestProgramReplayCommand(t *testing.T) {
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{{
+		llm.StreamEndMsg{FullResponse: "Blue"},
+	}}}
+	tp := newTestProgram(t, newProgramApp(t, client), 80, 24)
+	tp.typeText("what color is the sky?")
+	tp.waitFor("Blue")
+	tp.typeText("/replay")
+	tp.waitFor("replay 1/2")
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyEsc})
+	tp.out.Reset()
+	tp.typeText("hello")
+	tp.waitFor("hello")
+	a := tp.quit()
+
+	if a.chat.Replaying() {
+		t.Fatal("still replaying after esc")
+	}
+}
+
+// TestProgramJSON asks for responses matching a schema and checks them
+func TestProgramJSON(t *testing.T) {
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{
+		{llm.StreamEndMsg{FullResponse: `{"n": 1}`}},
+		{llm.StreamEndMsg{FullResponse: "```json\n{\"n\": \"one\"}\n```"}},
+	}}
+	tp := newTestProgram(t, newProgramApp(t, client), 80, 24)
+	tp.typeText(`/json {"type": "object", "properties": {"n": {"type": "integer"}}, "required": ["n"]}`)
+	tp.waitFor("structured output: responses are JSON")
+	tp.typeText("count to one")
+	tp.waitFor("response matches the schema")
+	tp.waitFor(`"n": 1`)
+	tp.typeText("again")
+	tp.waitFor("/n: expected integer, got string")
+	tp.typeText("/json off")
+	tp.waitFor("structured output off")
+	tp.quit()
+
+	reqs := client.Requests()
+	if len(reqs) != 2 || string(reqs[0].JSONSchema) == "" || !strings.Contains(reqs[0].Messages[0].Content, `"required"`) {
+		t.Fatalf("the schema wasn't asked for: %+v", reqs)
+	}
+}
+
+// TestProgramParams sets sampling parameters for the session over the
+// config's and sends them
+func TestProgramParams(t *testing.T) {
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{{llm.StreamEndMsg{FullResponse: "Seeded"}}}}
+	a := newProgramApp(t, client)
+	temperature := 0.5
+	a.config.Models = map[string]config.ModelConfig{"test:*": {Temperature: &temperature}}
+	a.addModelInfo([]llm.ModelInfo{{ID: "test:m", SupportedParameters: []string{"temperature", "seed", "logit_bias"}}})
+	tp := newTestProgram(t, a, 100, 24)
+	tp.typeText("/params seed=42 minP=0.05 logitBias=50256:-100")
+	tp.waitFor("seed 42, min p 0.05, logit bias on 1 token(s) for the rest of the session")
+	tp.waitFor("test:m doesn't take minP, left out of its requests")
+	tp.typeText("/params minP=3")
+	tp.waitFor("minP must be between 0 and 1")
+	tp.typeText("/params top_k=5")
+	tp.waitFor(`unknown parameter "top_k"`)
+	tp.typeText("/params")
+	tp.waitFor("parameters for test:m: temperature 0.5, seed 42, min p 0.05")
+	tp.typeText("roll a die")
+	tp.waitFor("Seeded")
+	tp.quit()
+
+	p := client.Requests()[0].Params
+	if p.Temperature == nil || *p.Temperature != 0.5 || p.Seed == nil || *p.Seed != 42 || p.MinP == nil || *p.MinP != 0.05 || p.LogitBias["50256"] != -100 {
+		t.Fatalf("sent %+v", p)
+	}
+}
+
+// TestProgramContinue asks for the rest of a response cut off by the max
+// tokens and stitches the two together
+func TestProgramContinue(t *testing.T) {
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{
+		{llm.StreamChunkMsg{Content: "Once upon a"}, llm.StreamEndMsg{FullResponse: "Once upon a", FinishReason: llm.FinishLength}},
+		{llm.StreamChunkMsg{Content: " time."}, llm.StreamEndMsg{FullResponse: " time.", FinishReason: llm.FinishStop}},
+	}}
+	c := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local))
+	fsys := vfs.NewMem(c)
+	j := journal.Open("/journal", journal.WithFS(fsys), journal.WithClock(c))
+	a := newProgramApp(t, client)
+	a.journal = j
+	tp := newTestProgram(t, a, 80, 24)
+	tp.typeText("tell a story")
+	tp.waitFor("response truncated (max tokens)")
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("m"), Alt: true})
+	tp.waitFor("Once upon a time.")
+	tp.typeText("/continue")
+	tp.waitFor("there's no cut off response to continue")
+	a = tp.quit()
+
+	// the history isn't loaded again, what was shown around the response stays
+	if view := ansi.Strip(a.chat.View()); !strings.Contains(view, "response truncated (max tokens)") || strings.Count(view, "Once upon a") != 1 {
+		t.Errorf("the history shows\n%s", view)
+	}
+	data, err := fsys.ReadFile(j.Path(c.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "## 12:00 · test:m · tell a story\n\n> tell a story\n\nOnce upon a\n\n## 12:00 · test:m · tell a story · continued\n\ntime.\n\n"; string(data) != want {
+		t.Errorf("journal is %q, want %q", data, want)
+	}
+
+	var got []string
+	for _, m := range a.conversation.Messages {
+		got = append(got, m.Role+": "+m.Content)
+	}
+	if strings.Join(got, "\n") != "user: tell a story\nassistant: Once upon a time." || a.conversation.Messages[1].Truncated != "" {
+		t.Fatalf("conversation is %q", got)
+	}
+	msgs := client.Requests()[1].Messages
+	if n := len(msgs); n < 2 || msgs[n-2].Content != "Once upon a" || msgs[n-1].Content != continuePrompt {
+		t.Fatalf("continued with %+v", msgs)
+	}
+}
+
+// TestProgramAutoContinue continues a response running into the max tokens
+// as many times as the config allows
+func TestProgramAutoContinue(t *testing.T) {
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{
+		{llm.StreamChunkMsg{Content: "One"}, llm.StreamEndMsg{FullResponse: "One", FinishReason: llm.FinishLength}},
+		{llm.StreamChunkMsg{Content: " two"}, llm.StreamEndMsg{FullResponse: " two", FinishReason: llm.FinishLength}},
+		{llm.StreamChunkMsg{Content: " three"}, llm.StreamEndMsg{FullResponse: " three", FinishReason: llm.FinishLength}},
+	}}
+	a := newProgramApp(t, client)
+	a.config.AutoContinue = 2
+	tp := newTestProgram(t, a, 80, 24)
+	tp.typeText("count")
+	tp.waitFor("continued it 2 time(s)")
+	tp.waitFor("response truncated (max tokens)")
+	a = tp.quit()
+
+	if n := len(client.Requests()); n != 3 {
+		t.Fatalf("%d requests, want 3", n)
+	}
+	last := a.conversation.Messages[a.conversation.Len()-1]
+	if a.conversation.Len() != 2 || last.Content != "One two three" || last.Truncated != llm.FinishLength {
+		t.Fatalf("conversation is %+v", a.conversation.Messages)
+	}
+}
+
+// TestProgramThinkHarder regenerates with high reasoning effort and labels
+// the response
+func TestProgramThinkHarder(t *testing.T) {
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{
+		{llm.StreamEndMsg{FullResponse: "Quick answer"}},
+		{llm.StreamEndMsg{FullResponse: "Careful answer"}},
+	}}
+	a := newProgramApp(t, client)
+	a.selectedModel = "openai/o3"
+	tp := newTestProgram(t, a, 80, 24)
+	tp.typeText("is 91 prime?")
+	tp.waitFor("Quick answer")
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t"), Alt: true})
+	tp.waitFor("Careful answer")
+	tp.waitFor("thought harder (high reasoning effort)")
+	a = tp.quit()
+
+	reqs := client.Requests()
+	if len(reqs) != 2 || reqs[0].ReasoningEffort != "" || reqs[1].ReasoningEffort != "high" {
+		t.Fatalf("sent efforts %+v", reqs)
+	}
+	if last := a.conversation.Messages[a.conversation.Len()-1]; last.Content != "Careful answer" || last.Effort != "high" {
+		t.Fatalf("last message is %+v", last)
+	}
+}
+
+// TestProgramFanout sends a prompt to two models and keeps the second
+// one's response
+func TestProgramFanout(t *testing.T) {
+	answers := []string{"Answer one", "Answer two"}
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{
+		{llm.StreamEndMsg{FullResponse: answers[0]}},
+		{llm.StreamEndMsg{FullResponse: answers[1]}},
+	}}
+	a := newProgramApp(t, client)
+	a.config.Fanout = []string{"m1", "m2"}
+	tp := newTestProgram(t, a, 80, 24)
+	tp.typeText("/fanout which is best?")
+	tp.waitFor("2 models answering: which is best?")
+	tp.waitFor("1 m1 ✓")
+	tp.waitFor("2 m2 ✓")
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyTab})
+	tp.p.Send(tea.KeyMsg{Type: tea.KeyEnter})
+	tp.waitFor("kept m2's response, the others were discarded")
+	a = tp.quit()
+
+	want := ""
+	for i, req := range client.Requests() {
+		if req.Model == "m2" {
+			want = answers[i]
+		}
+	}
+	var got []string
+	for _, m := range a.conversation.Messages {
+		got = append(got, m.Role+": "+m.Content)
+	}
+	if strings.Join(got, "\n") != "user: which is best?\nassistant: "+want || a.conversation.Messages[1].Model != "m2" {
+		t.Fatalf("conversation is %q", got)
+	}
+}
+
+// TestProgramFanoutJudge scores the answers of a fan-out once they're in
+func TestProgramFanoutJudge(t *testing.T) {
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{
+		{llm.StreamEndMsg{FullResponse: "Answer one"}},
+		{llm.StreamEndMsg{FullResponse: "Answer two"}},
+		{llm.StreamEndMsg{
+			FullResponse: `[{"candidate": 1, "score": 8, "reason": "right"}, {"candidate": 2, "score": 3, "reason": "vague"}]`,
+			Usage:        &llm.Usage{Cost: 0.01},
+		}},
+	}}
+	a := newProgramApp(t, client)
+	a.config.Fanout = []string{"m1", "m2"}
+	a.config.Judge = config.JudgeConfig{Model: "judge", Auto: true}
+	tp := newTestProgram(t, a, 100, 24)
+	tp.typeText("/fanout which is best?")
+	tp.waitFor("judged by judge")
+	tp.waitFor("1 m1 ✓ 8/10")
+	tp.waitFor("2 m2 ✓ 3/10")
+	tp.waitFor("judge: 8/10 right")
+	if a = tp.quit(); a.session.Spent != 0.01 {
+		t.Errorf("judging cost %v, want 0.01", a.session.Spent)
+	}
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// providerModelsMsg carries the models listed by a configured provider
+type providerModelsMsg struct {
+	provider string
+	models   []llm.ModelInfo
+	err      error
+}
+
+// modelInfoMsg carries openrouter's context sizes and pricing
+type modelInfoMsg struct {
+	models []llm.ModelInfo
+	err    error
+}
+
+// prefixModels turns a provider's model ids into the names used in the picker
+func prefixModels(provider string, models []string) []string {
+	prefixed := make([]string, len(models))
+	for i, m := range models {
+		prefixed[i] = provider + ":" + m
+	}
+	return prefixed
+}
+
+// fetchModelInfo fetches metadata for the model picker in the background
+func fetchModelInfo(client *llm.OpenRouterClient) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		models, err := client.Models(ctx)
+		return modelInfoMsg{models: models, err: err}
+	}
+}
+
+// fetchProviderModels asks a provider which models it serves. local servers
+// are often not running, so this happens in the background after startup
+func fetchProviderModels(provider string, client *llm.OpenRouterClient) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		models, err := client.Models(ctx)
+		return providerModelsMsg{provider: provider, models: models, err: err}
+	}
+}
+
+// addProviderModels makes listed models selectable
+func (a *App) addProviderModels(m providerModelsMsg) {
+	if m.err != nil {
+		logging.Errorf("listing models of %s: %v", m.provider, m.err)
+		a.chat.AddError(i18n.Tf("couldn't list the models of %s: %v", m.provider, m.err))
+		return
+	}
+	infos := make([]llm.ModelInfo, len(m.models))
+	for i, info := range m.models {
+		info.ID = m.provider + ":" + info.ID
+		infos[i] = info
+	}
+	a.modelPicker.AddModels(infos)
+	a.addModelInfo(infos)
+	if a.selectedModel == "" && len(infos) > 0 {
+		a.selectedModel = infos[0].ID
+	}
+}
+
+// addModelInfo remembers model metadata for cost estimates
+func (a *App) addModelInfo(models []llm.ModelInfo) {
+	if a.modelInfo == nil {
+		a.modelInfo = make(map[string]llm.ModelInfo, len(models))
+	}
+	for _, info := range models {
+		a.modelInfo[info.ID] = info
+	}
+}
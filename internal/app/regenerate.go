@@ -0,0 +1,80 @@
+package app
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// regenerate replaces the last response with a new one to the same prompt,
+// keeping the old one for /diff
+func (a *App) regenerate() tea.Cmd {
+	return a.regenerateWith("")
+}
+
+// regenerateWith regenerates the response with a reasoning effort other
+// than the configured one, "" keeps that
+func (a *App) regenerateWith(effort string) tea.Cmd {
+	if a.streamChan != nil {
+		a.chat.AddError(i18n.T("wait for the current response to finish before regenerating it"))
+		return nil
+	}
+	if a.refuseLocked("regenerate the response") {
+		return nil
+	}
+	n := a.conversation.Len()
+	if n == 0 || a.conversation.Messages[n-1].Role != "assistant" || len(a.conversation.Messages[n-1].ToolCalls) > 0 {
+		a.chat.AddError(i18n.T("there's no response to regenerate"))
+		return nil
+	}
+	prompt := a.conversation.Last("user")
+	send := func() tea.Cmd {
+		// the saved session keeps the old response until the new one is done
+		removed := a.conversation.Truncate(n - 1)
+		a.previousResponse, a.regenerating = removed[0].Content, true
+		a.replaced, a.replacedAt = &removed[0], n-1
+		a.chat.ClearHistory()
+		a.chat.LoadHistory(a.conversation.Messages)
+		logging.Infof("regenerating the response with %s", a.selectedModel)
+		a.turnModel, a.turnEffort = a.selectedModel, effort
+		a.toolRounds = 0
+		a.newTurn()
+		cmd := a.chat.SetSending(true)
+		return tea.Batch(cmd, a.startStream())
+	}
+	if prompt != nil {
+		if reason := a.overBudget(prompt.Content); reason != "" {
+			a.confirmOverBudget(reason, send, func() tea.Cmd { return nil })
+			return nil
+		}
+	}
+	return send()
+}
+
+// restoreReplaced puts back the response a /regenerate that failed or was
+// interrupted was replacing, dropping whatever the new attempt added. it
+// reports whether there was one
+func (a *App) restoreReplaced() bool {
+	if !a.regenerating || a.replaced == nil {
+		return false
+	}
+	a.conversation.Truncate(a.replacedAt)
+	a.conversation.Add(*a.replaced)
+	a.previousResponse, a.regenerating, a.replaced = "", false, nil
+	a.saveSession()
+	a.chat.ClearHistory()
+	a.chat.LoadHistory(a.conversation.Messages)
+	a.chat.AddNotice(i18n.T("kept the previous response"))
+	return true
+}
+
+// diffRegenerated shows what changed between the response /regenerate
+// replaced and the one that replaced it
+func (a *App) diffRegenerated() {
+	last := a.conversation.Last("assistant")
+	if a.previousResponse == "" || a.regenerating || last == nil {
+		a.chat.AddError(i18n.T("nothing to compare, /regenerate a response first"))
+		return
+	}
+	a.chat.AddDiff(a.previousResponse, last.Content)
+}
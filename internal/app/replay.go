@@ -0,0 +1,13 @@
+package app
+
+import "github.com/scbenet/ask/internal/i18n"
+
+// startReplay steps through the conversation from its first message, space
+// shows the next one. leaving the replay shows all of it again
+func (a *App) startReplay() {
+	if a.conversation.Len() == 0 {
+		a.chat.AddError(i18n.T("nothing to replay, the conversation is empty"))
+		return
+	}
+	a.chat.StartReplay(a.conversation.Messages)
+}
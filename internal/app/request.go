@@ -0,0 +1,80 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/providers"
+)
+
+// newRequest builds a request for model, applying the settings configured
+// for it. the system prompt (persona, the model's own, memories, then the
+// /patch and /json instructions) goes first and is never saved in the conversation
+func (a *App) newRequest(model string, messages []llm.Message) llm.Request {
+	mc := a.modelParams(model)
+	var system []string
+	if p, ok := a.config.Persona(a.session.Persona); ok && p.Prompt != "" {
+		system = append(system, p.Prompt)
+	}
+	if mc.SystemPrompt != "" {
+		system = append(system, mc.SystemPrompt)
+	}
+	if a.memories != nil {
+		if memories := a.memories.Prompt(); memories != "" {
+			system = append(system, memories)
+		}
+	}
+	if a.patchMode {
+		system = append(system, patchPrompt)
+	}
+	params := providers.Params(mc)
+	if a.jsonSchema != nil {
+		system = append(system, jsonPrompt+string(a.jsonSchema.Raw()))
+		params.JSONSchema = a.jsonSchema.Raw()
+	}
+	if len(system) > 0 {
+		messages = append([]llm.Message{{Role: "system", Content: strings.Join(system, "\n\n")}}, messages...)
+	}
+	return llm.Request{
+		Model:     model,
+		Messages:  messages,
+		Resumable: a.config.ResumeFor(model),
+		Params:    params,
+	}
+}
+
+// describeModelConfig summarizes the settings configured for model, with
+// the ones set by /params, "" if there are none
+func (a *App) describeModelConfig(model string) string {
+	mc := a.modelParams(model)
+	parts := describeParams(mc)
+	if mc.ReasoningEffort != "" {
+		parts = append(parts, "reasoning effort "+mc.ReasoningEffort)
+	}
+	if mc.SystemPrompt != "" {
+		parts = append(parts, "custom system prompt")
+	}
+	if mc.SendDelay != nil {
+		parts = append(parts, fmt.Sprintf("send delay %s", mc.SendDelay.Std()))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// setPersona switches the conversation to the named persona, "" turns it off
+func (a *App) setPersona(name string) {
+	if name == a.session.Persona || a.refuseLocked("change the persona") {
+		return
+	}
+	a.session.Persona = name
+	a.chat.SetPersona(name)
+	if name == "" {
+		a.chat.AddNotice(i18n.T("persona turned off"))
+	} else {
+		a.chat.AddNotice(i18n.Tf("persona set to %s, it applies from the next message", name))
+	}
+	if a.conversation.Len() > 0 {
+		a.saveSession()
+	}
+}
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/edits"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// markdownExts are the files /savefile writes the whole response to, for
+// others it takes the code
+var markdownExts = map[string]bool{".md": true, ".markdown": true, ".txt": true}
+
+// saveFile writes the last response to a file, creating its directory:
+// the markdown as it is for .md and .txt files, the code of the response
+// for anything else. /savefile path n takes the nth code block. unlike
+// /apply the path was typed by the user, so it may be anywhere
+func (a *App) saveFile(args []string) {
+	if len(args) == 0 || len(args) > 2 {
+		a.chat.AddError(i18n.T("usage: /savefile <path> [n]"))
+		return
+	}
+	response, ok := a.lastAssistantMessage()
+	if !ok {
+		a.chat.AddError(i18n.T("no response to save yet"))
+		return
+	}
+	path := args[0]
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, rest)
+		}
+	}
+	content, what, err := savedContent(response, path, args[1:])
+	if err != nil {
+		a.chat.AddError(i18n.Tf("/savefile: %v", err))
+		return
+	}
+
+	write := func() {
+		if err := writeFile(path, content); err != nil {
+			a.chat.AddError(err.Error())
+			return
+		}
+		a.chat.AddNotice(i18n.Tf("saved %s to %s", what, path))
+	}
+	if _, err := os.Stat(path); err == nil {
+		a.confirm(i18n.Tf("%s already exists, overwrite it?", path), func() tea.Cmd {
+			write()
+			return nil
+		})
+		return
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		a.chat.AddError(i18n.Tf("/savefile: %v", err))
+		return
+	}
+	write()
+}
+
+// savedContent picks what of response goes into the file at path, and
+// says what it is
+func savedContent(response, path string, args []string) (string, string, error) {
+	blocks := edits.CodeBlocks(response)
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > len(blocks) {
+			return "", "", fmt.Errorf("expected a code block between 1 and %d, got %q", len(blocks), args[0])
+		}
+		return blocks[n-1].Code, fmt.Sprintf("code block %d", n), nil
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if markdownExts[ext] || len(blocks) == 0 {
+		if !strings.HasSuffix(response, "\n") {
+			response += "\n"
+		}
+		return response, "the response", nil
+	}
+	if len(blocks) == 1 {
+		return blocks[0].Code, "the code", nil
+	}
+	// several blocks, the one in the file's language if there's just one
+	var matching []edits.Block
+	for _, b := range blocks {
+		if langMatches(b.Lang, ext) {
+			matching = append(matching, b)
+		}
+	}
+	if len(matching) == 1 {
+		return matching[0].Code, "the " + matching[0].Lang + " code", nil
+	}
+	return "", "", fmt.Errorf("the response has %d code blocks, /savefile %s n saves the nth", len(blocks), path)
+}
+
+// langAliases are the extensions of languages not named after them
+var langAliases = map[string][]string{
+	"go":         {".go"},
+	"golang":     {".go"},
+	"python":     {".py"},
+	"javascript": {".js", ".mjs", ".cjs"},
+	"typescript": {".ts", ".tsx"},
+	"bash":       {".sh", ".bash"},
+	"shell":      {".sh"},
+	"rust":       {".rs"},
+	"ruby":       {".rb"},
+	"yaml":       {".yml", ".yaml"},
+	"markdown":   {".md"},
+	"dockerfile": {""},
+	"c++":        {".cpp", ".cc", ".hpp"},
+	"csharp":     {".cs"},
+	"kotlin":     {".kt"},
+}
+
+// langMatches reports whether a code block in lang belongs in a file with
+// extension ext
+func langMatches(lang, ext string) bool {
+	lang = strings.ToLower(lang)
+	if lang == "" {
+		return false
+	}
+	if "."+lang == ext {
+		return true
+	}
+	for _, e := range langAliases[lang] {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
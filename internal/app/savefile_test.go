@@ -0,0 +1,77 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/conversation"
+)
+
+func TestSavedContent(t *testing.T) {
+	response := "Run this:\n\n```bash\necho hi\n```\n\nwith this config:\n\n```yaml\nkey: value\n```"
+	tests := []struct {
+		path string
+		args []string
+		want string
+		err  string
+	}{
+		{path: "notes.md", want: response + "\n"},
+		{path: "run.sh", want: "echo hi\n"},
+		{path: "config.yml", want: "key: value\n"},
+		{path: "config.json", err: "has 2 code blocks"},
+		{path: "config.json", args: []string{"2"}, want: "key: value\n"},
+		{path: "notes.md", args: []string{"1"}, want: "echo hi\n"},
+		{path: "x.go", args: []string{"3"}, err: "between 1 and 2"},
+	}
+	for _, tt := range tests {
+		got, _, err := savedContent(response, tt.path, tt.args)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("savedContent(%s, %v) err = %v, want %q", tt.path, tt.args, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("savedContent(%s, %v) = %q, %v, want %q", tt.path, tt.args, got, err, tt.want)
+		}
+	}
+
+	// a response without code blocks is saved whole
+	if got, _, _ := savedContent("plain answer", "answer.py", nil); got != "plain answer\n" {
+		t.Errorf("savedContent without code blocks = %q", got)
+	}
+}
+
+// TestSaveFile writes outside the working directory, creating the
+// directories on the way, and keeps the permissions of a file it overwrites
+// once that's confirmed
+func TestSaveFile(t *testing.T) {
+	l := newLoop(t, nil, &fakeClient{})
+	a := l.app
+	a.conversation.Add(conversation.Message{Role: "assistant", Content: "```bash\necho hi\n```"})
+	path := filepath.Join(t.TempDir(), "a", "b", "run.sh")
+
+	a.saveFile([]string{path})
+	if data, err := os.ReadFile(path); err != nil || string(data) != "echo hi\n" {
+		t.Fatalf("saved %q (%v)", data, err)
+	}
+
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	a.saveFile([]string{path})
+	if a.pendingConfirm == nil {
+		t.Fatal("overwrote a file without asking")
+	}
+	l.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	info, err := os.Stat(path)
+	if data, _ := os.ReadFile(path); err != nil || string(data) != "echo hi\n" || info.Mode().Perm() != 0o755 {
+		t.Fatalf("overwritten file is %q with mode %v (%v)", data, info.Mode(), err)
+	}
+}
@@ -0,0 +1,183 @@
+package app
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/session"
+)
+
+// pendingSend is a prompt held back by the send delay, so a prompt sent by
+// accident can be taken back before it costs anything
+type pendingSend struct {
+	id     int
+	prompt string
+}
+
+// sendDelayElapsedMsg dispatches the pending prompt with the same id
+type sendDelayElapsedMsg struct {
+	id int
+}
+
+// holdPrompt waits delay before sending prompt, esc cancels it in the meantime
+func (a *App) holdPrompt(prompt string, delay time.Duration) tea.Cmd {
+	a.sendCount++
+	id := a.sendCount
+	a.pendingSend = &pendingSend{id: id, prompt: prompt}
+	a.chat.SetPendingSend(delay)
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		return sendDelayElapsedMsg{id: id}
+	})
+}
+
+// cancelPendingSend takes back the held prompt and returns it to the input,
+// along with the prompts queued behind it
+func (a *App) cancelPendingSend() {
+	log.Printf("cancelled sending prompt during the send delay")
+	prompt := a.pendingSend.prompt
+	a.chat.RetractPrompt(prompt)
+	a.pendingSend = nil
+	if len(a.queuedPrompts) > 0 {
+		a.queuedPrompts = append([]string{prompt}, a.queuedPrompts...)
+		a.chat.SetInputValue("")
+		a.restoreQueued("the first was cancelled")
+	}
+}
+
+// sendOrHold sends prompt, after the send delay if the model has one
+func (a *App) sendOrHold(prompt string) tea.Cmd {
+	if delay := a.config.SendDelayFor(a.selectedModel); delay > 0 {
+		return a.holdPrompt(prompt, delay)
+	}
+	return a.sendPrompt(prompt)
+}
+
+// sendPrompt adds prompt to the conversation and streams the response
+func (a *App) sendPrompt(prompt string) tea.Cmd {
+	cmd := a.chat.SetSending(true)
+	log.Printf("SetSending: true")
+	model, classify := a.selectedModel, false
+	// the prefix only means something while downgrading is on, otherwise
+	// the prompt is sent as typed
+	if rest, ok := strings.CutPrefix(prompt, keepModelPrefix); ok && a.config.Downgrade.Enabled {
+		prompt = rest
+	} else {
+		model, classify = a.chooseModel(prompt)
+	}
+	a.turnModel, a.turnEffort = model, ""
+	log.Printf("Prompt: %s\nModel: %s", prompt, model)
+
+	a.expireEphemeral()
+	a.refreshAttachments()
+	a.conversation.Add(conversation.Message{
+		Role:        "user",
+		Content:     prompt,
+		Attachments: a.pendingAttachments,
+		Model:       model,
+		ExpiresIn:   a.nextEphemeral,
+	})
+	a.pendingAttachments = nil
+	a.nextEphemeral = 0
+	// the next response isn't another take on the last one
+	a.previousResponse, a.regenerating, a.replaced = "", false, nil
+	if a.session.Title == "" {
+		a.session.Title = session.TitleFromPrompt(prompt)
+	}
+	a.toolRounds = 0
+	a.newTurn()
+	if classify {
+		return tea.Batch(cmd, a.startDowngradedStream(prompt))
+	}
+	if model != a.selectedModel {
+		a.announceDowngrade(model)
+	}
+	return tea.Batch(cmd, a.startStream())
+}
+
+// expireEphemeral counts down the ephemeral messages as a new prompt is
+// added, redrawing the chat if any of them ran out
+func (a *App) expireEphemeral() {
+	if n := a.conversation.Expire(); n > 0 {
+		// redraw so the expired messages are gone from the screen too
+		a.chat.ClearHistory()
+		a.chat.LoadHistory(a.conversation.Messages)
+		a.chat.AddNotice(i18n.Tf("removed %d expired ephemeral message(s)", n))
+	}
+}
+
+// defaultEphemeralTurns is how many prompts an ephemeral message is sent
+// with when /ephemeral isn't given a number
+const defaultEphemeralTurns = 3
+
+// markEphemeral makes the next prompt ephemeral, /ephemeral [turns]
+func (a *App) markEphemeral(args []string) {
+	if a.refuseLocked("add an ephemeral message") {
+		return
+	}
+	turns := defaultEphemeralTurns
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 {
+			a.chat.AddError(i18n.Tf("/ephemeral: expected a number of turns, got %q", args[0]))
+			return
+		}
+		turns = n
+	}
+	a.nextEphemeral = turns
+	a.chat.AddNotice(i18n.Tf("the next message (and its attachments) is ephemeral: it will be removed from the conversation and saved session after %d turn(s)", turns))
+}
+
+// queuePrompt holds a prompt sent while a response is streaming, it is sent
+// once the response is done
+func (a *App) queuePrompt(prompt string) {
+	a.queuedPrompts = append(a.queuedPrompts, prompt)
+	a.chat.SetQueued(len(a.queuedPrompts))
+	log.Printf("queued prompt, %d waiting", len(a.queuedPrompts))
+}
+
+// sendQueued sends the oldest queued prompt, if any
+func (a *App) sendQueued() tea.Cmd {
+	if len(a.queuedPrompts) == 0 || a.streamChan != nil {
+		return nil
+	}
+	prompt := a.queuedPrompts[0]
+	if reason := a.overBudget(prompt); reason != "" {
+		a.confirmOverBudget(reason, func() tea.Cmd {
+			a.queuedPrompts = a.queuedPrompts[1:]
+			a.chat.SetQueued(len(a.queuedPrompts))
+			a.chat.ShowPrompt(prompt)
+			return a.sendOrHold(prompt)
+		}, func() tea.Cmd {
+			a.restoreQueued("they would go over the budget")
+			return nil
+		})
+		return nil
+	}
+	a.queuedPrompts = a.queuedPrompts[1:]
+	a.chat.SetQueued(len(a.queuedPrompts))
+	a.chat.ShowPrompt(prompt)
+	// queued prompts get the same chance to be taken back as typed ones
+	return a.sendOrHold(prompt)
+}
+
+// restoreQueued puts queued prompts back in the input when they can't be
+// sent, e.g. after a failed response they were probably written expecting
+// to succeed. reason finishes "were not sent because"
+func (a *App) restoreQueued(reason string) {
+	if len(a.queuedPrompts) == 0 {
+		return
+	}
+	prompts := a.queuedPrompts
+	if input := a.chat.GetInputValue(); input != "" {
+		prompts = append(prompts, input)
+	}
+	a.chat.SetInputValue(strings.Join(prompts, "\n\n"))
+	a.chat.AddNotice(i18n.Tf("%d queued prompt(s) were not sent because %s, they are back in the input", len(a.queuedPrompts), reason))
+	a.queuedPrompts = nil
+	a.chat.SetQueued(0)
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
+)
+
+// ShutdownMsg quits right away, main sends it on SIGINT, SIGTERM or SIGHUP
+type ShutdownMsg struct{}
+
+// newTurn gives the prompt being sent a context of its own, cancelling it
+// stops its stream and tool calls. the previous turn is over by now, its
+// context is released
+func (a *App) newTurn() context.Context {
+	a.cancelTurn()
+	a.turnCtx, a.cancelTurn = context.WithCancel(context.Background())
+	return a.turnCtx
+}
+
+// requestContext gives the next request of the turn an id of its own, a
+// turn with tool calls makes several
+func (a *App) requestContext() context.Context {
+	a.requestID = llm.NewRequestID()
+	return llm.WithRequestID(a.turnCtx, a.requestID)
+}
+
+// interruptStream cancels the response being streamed, keeping what
+// arrived of it in the conversation marked as interrupted
+func (a *App) interruptStream() {
+	if a.streamChan == nil {
+		return
+	}
+	a.cancelTurn()
+	// an interrupted /regenerate drops what it got, the response it would
+	// have replaced is put back
+	if a.restoreReplaced() {
+		a.endStream()
+		a.chat.SetSending(false)
+		return
+	}
+	if partial := a.chat.PartialResponse(); a.continuing {
+		// what was there before is part of partial, it's replaced
+		last := &a.conversation.Messages[a.conversation.Len()-1]
+		last.Content, last.Truncated, last.Error = partial, "", interruptedError
+		a.endContinuation()
+	} else if partial != "" {
+		a.conversation.Add(conversation.Message{
+			Role:      "assistant",
+			Content:   partial,
+			Model:     a.turnModel,
+			RequestID: a.requestID,
+			Error:     interruptedError,
+		})
+	}
+	a.endStream()
+	a.chat.SetSending(false)
+}
+
+// cancelResponse stops the response being streamed for good, anything
+// queued after it goes back to the input
+func (a *App) cancelResponse() {
+	a.interruptStream()
+	a.saveSession()
+	a.chat.AddNotice(i18n.T("cancelled the response"))
+	a.restoreQueued("the response was cancelled")
+}
+
+// shutdown quits without asking anything: the stream is cancelled, the
+// conversation saved with whatever was streamed so far and an incognito
+// one wiped
+func (a *App) shutdown() tea.Cmd {
+	a.interruptStream()
+	if a.session.Incognito {
+		a.wipe()
+	} else if a.conversation.Len() > 0 {
+		a.saveSession()
+	}
+	return tea.Quit
+}
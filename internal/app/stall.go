@@ -0,0 +1,133 @@
+package app
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// how often a streaming response is checked for having gone quiet
+const stallCheckInterval = time.Second
+
+// reasoning models think before they answer, often without sending anything
+// for a while. they're given this many times the stall warning
+const reasoningStallFactor = 4
+
+// the answers to a stalled response, for the help
+var (
+	retryKey  = key.NewBinding(key.WithKeys("r"), key.WithHelp("r", "retry"))
+	cancelKey = key.NewBinding(key.WithKeys("c", "esc"), key.WithHelp("c", "cancel"))
+	waitKey   = key.NewBinding(key.WithKeys("w"), key.WithHelp("w", "keep waiting"))
+)
+
+// stallCheckMsg checks whether the response of the given stream stalled
+type stallCheckMsg struct {
+	stream int
+}
+
+// watchStall starts checking the current stream for stalls, once it's been
+// quiet for the stallWarning the user is asked what to do. the stream has
+// to be a response, tool calls wait on the user and aren't stalled
+func (a *App) watchStall() tea.Cmd {
+	a.lastActivity = a.clock.Now()
+	a.setStalled(false)
+	if a.config.StallWarning <= 0 {
+		return nil
+	}
+	return a.scheduleStallCheck()
+}
+
+func (a *App) scheduleStallCheck() tea.Cmd {
+	id := a.streamID
+	return tea.Tick(stallCheckInterval, func(time.Time) tea.Msg { return stallCheckMsg{stream: id} })
+}
+
+// streamActive notes that something arrived, the response isn't stalled
+func (a *App) streamActive() {
+	a.lastActivity = a.clock.Now()
+	if a.stalled {
+		a.setStalled(false)
+		a.chat.AddNotice(i18n.T("the response picked up again"))
+	}
+}
+
+// checkStall updates the stalled indicator, asking the first time the
+// response goes quiet for too long. the checks end with the stream
+func (a *App) checkStall(m stallCheckMsg) tea.Cmd {
+	if m.stream != a.streamID || a.streamChan == nil {
+		return nil
+	}
+	quiet := a.clock.Now().Sub(a.lastActivity)
+	if quiet >= a.stallWarning() {
+		a.chat.SetStalled(quiet)
+		if !a.stalled {
+			a.setStalled(true)
+			logging.Infof("[%s] stream stalled, nothing received for %s", a.requestID, quiet.Round(time.Second))
+			a.chat.AddNotice(i18n.T("the response stalled: r to retry, c to cancel, w to keep waiting"))
+		}
+	}
+	return a.scheduleStallCheck()
+}
+
+// stallWarning is how long the response may be quiet before it counts as
+// stalled, longer for reasoning models
+func (a *App) stallWarning() time.Duration {
+	warning := a.config.StallWarning.Std()
+	if a.supportsReasoning(a.turnModel) {
+		warning *= reasoningStallFactor
+	}
+	return warning
+}
+
+// setStalled switches the question about a stalled response on or off
+func (a *App) setStalled(stalled bool) {
+	if stalled == a.stalled {
+		return
+	}
+	a.stalled = stalled
+	if stalled {
+		a.chat.SetChoices(retryKey, cancelKey, waitKey)
+	} else {
+		a.chat.SetStalled(0)
+		a.chat.SetChoices()
+	}
+}
+
+// answerStall acts on the key pressed while the response is stalled, if
+// it's one of the answers. with something typed in the input the keys are
+// left to it, other keys always are
+func (a *App) answerStall(msg tea.KeyMsg) (tea.Cmd, bool) {
+	typing := a.chat.GetInputValue() != "" && msg.Type == tea.KeyRunes
+	switch {
+	case typing:
+		return nil, false
+	case key.Matches(msg, retryKey):
+		a.setStalled(false)
+		return a.retryStalled(), true
+	case key.Matches(msg, cancelKey):
+		a.setStalled(false)
+		a.cancelResponse()
+		return nil, true
+	case key.Matches(msg, waitKey):
+		a.setStalled(false)
+		// asked again if it stays quiet for another stall warning
+		a.lastActivity = a.clock.Now()
+		a.chat.AddNotice(i18n.T("waiting for the response"))
+		return nil, true
+	}
+	return nil, false
+}
+
+// retryStalled gives up on the response and sends the request again,
+// without keeping what arrived of it
+func (a *App) retryStalled() tea.Cmd {
+	logging.Infof("[%s] retrying stalled stream", a.requestID)
+	a.newTurn()
+	a.endStream()
+	cmd := a.chat.SetSending(true)
+	a.chat.AddNotice(i18n.T("sending the request again"))
+	return tea.Batch(cmd, a.startStream())
+}
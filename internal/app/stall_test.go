@@ -0,0 +1,126 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/ui"
+)
+
+// TestStallRetry asks about a response that went quiet and sends it again
+// on r, without keeping what arrived the first time
+func TestStallRetry(t *testing.T) {
+	hold := make(chan struct{})
+	client := &fakeClient{hold: map[string]chan struct{}{"one": hold}}
+	l := newLoop(t, nil, client)
+	c := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	l.app.clock = c
+
+	l.send(ui.SendPromptMsg{Prompt: "one"})
+	l.until("the first chunk", func() bool { return l.app.chat.PartialResponse() == "re: " })
+	l.send(stallCheckMsg{stream: l.app.streamID})
+	if l.app.stalled {
+		t.Fatal("stalled right away")
+	}
+	c.Advance(l.app.config.StallWarning.Std())
+	l.send(stallCheckMsg{stream: l.app.streamID})
+	if !l.app.stalled {
+		t.Fatal("not stalled after the stall warning")
+	}
+
+	stalled := l.app.streamID
+	l.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	if l.app.streamID == stalled || l.app.stalled {
+		t.Fatal("r didn't send the request again")
+	}
+	close(hold)
+	l.until("the response", l.idle)
+	client.running.Wait()
+	l.checkConversation("user: one", "assistant: re: one")
+}
+
+// TestStallKeepWaiting resets the clock on w, the response is
+// only called stalled again after another stall warning
+func TestStallKeepWaiting(t *testing.T) {
+	hold := make(chan struct{})
+	client := &fakeClient{hold: map[string]chan struct{}{"one": hold}}
+	l := newLoop(t, nil, client)
+	c := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	l.app.clock = c
+
+	l.send(ui.SendPromptMsg{Prompt: "one"})
+	l.until("the first chunk", func() bool { return l.app.chat.PartialResponse() == "re: " })
+	c.Advance(l.app.config.StallWarning.Std())
+	l.send(stallCheckMsg{stream: l.app.streamID})
+	l.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("w")})
+	l.send(stallCheckMsg{stream: l.app.streamID})
+	if l.app.stalled || l.app.chat.GetInputValue() != "" {
+		t.Fatalf("still stalled after choosing to wait, input %q", l.app.chat.GetInputValue())
+	}
+
+	close(hold)
+	l.until("the response", l.idle)
+	client.running.Wait()
+	l.checkConversation("user: one", "assistant: re: one")
+}
+
+// TestStallOtherKeys leaves keys that aren't answers to the input, and a
+// keepalive from the provider counts as the response being alive
+func TestStallOtherKeys(t *testing.T) {
+	hold := make(chan struct{})
+	client := &fakeClient{hold: map[string]chan struct{}{"one": hold}}
+	l := newLoop(t, nil, client)
+	c := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	l.app.clock = c
+
+	l.send(ui.SendPromptMsg{Prompt: "one"})
+	l.until("the first chunk", func() bool { return l.app.chat.PartialResponse() == "re: " })
+	c.Advance(l.app.config.StallWarning.Std() - time.Second)
+	l.send(streamMsg{id: l.app.streamID, msg: llm.StreamKeepaliveMsg{}})
+	c.Advance(time.Second)
+	l.send(stallCheckMsg{stream: l.app.streamID})
+	if l.app.stalled {
+		t.Fatal("stalled right after a keepalive")
+	}
+
+	c.Advance(l.app.config.StallWarning.Std())
+	l.send(stallCheckMsg{stream: l.app.streamID})
+	if !l.app.stalled {
+		t.Fatal("not stalled after the stall warning")
+	}
+	l.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("h")})
+	l.send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("w")})
+	if !l.app.stalled || l.app.chat.GetInputValue() != "hw" {
+		t.Fatalf("typing answered the stall, input %q", l.app.chat.GetInputValue())
+	}
+
+	close(hold)
+	l.until("the response", l.idle)
+	client.running.Wait()
+	l.checkConversation("user: one", "assistant: re: one")
+}
+
+// TestCancelResponse stops a streaming response on esc, keeping what
+// arrived and putting the queued message back in the input
+func TestCancelResponse(t *testing.T) {
+	hold := make(chan struct{})
+	client := &fakeClient{hold: map[string]chan struct{}{"one": hold}}
+	l := newLoop(t, nil, client)
+
+	l.send(ui.SendPromptMsg{Prompt: "one"})
+	l.until("the first chunk", func() bool { return l.app.chat.PartialResponse() == "re: " })
+	l.send(ui.SendPromptMsg{Prompt: "two"})
+	l.send(tea.KeyMsg{Type: tea.KeyEsc})
+	if l.app.streamChan != nil {
+		t.Fatal("esc didn't cancel the response")
+	}
+	if got := l.app.chat.GetInputValue(); got != "two" {
+		t.Errorf("input %q, want the queued message back", got)
+	}
+	close(hold)
+	client.running.Wait()
+	l.checkConversation("user: one", "assistant: re: ")
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"log"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// a stream is a response being generated or a round of tool calls, its
+// goroutine sends messages on streamChan until it closes it. only Update
+// touches streamChan and streamID: newStream replaces the current stream,
+// endStream forgets it. messages are tagged with the id of the stream they
+// came from, so anything still arriving from a stream that was cancelled or
+// replaced is dropped instead of being mistaken for the current one
+
+// streamMsg is a message from the stream with the given id
+type streamMsg struct {
+	id  int
+	msg tea.Msg
+}
+
+// newStream makes a channel for a new stream, which becomes the current
+// one, and returns it along with the command listening to it
+func (a *App) newStream() (chan tea.Msg, tea.Cmd) {
+	a.endStream()
+	a.streamID++
+	a.streamChan = make(chan tea.Msg)
+	return a.streamChan, listenToStream(a.streamID, a.streamChan)
+}
+
+// endStream forgets the current stream. whatever it still sends is read
+// and thrown away so its goroutine isn't left blocked forever
+func (a *App) endStream() {
+	if a.streamChan == nil {
+		return
+	}
+	ch := a.streamChan
+	a.streamChan = nil
+	go func() {
+		for range ch {
+		}
+	}()
+}
+
+// listenToStream waits for the next message of the stream
+func listenToStream(id int, ch chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-ch
+		if !ok {
+			// closed once the stream sent its last message
+			return nil
+		}
+		return streamMsg{id: id, msg: msg}
+	}
+}
+
+// handleStreamMsg passes a message from the current stream on to Update
+// and listens for the next one, as long as the stream is still current
+func (a *App) handleStreamMsg(m streamMsg) (tea.Model, tea.Cmd) {
+	if m.id != a.streamID || a.streamChan == nil {
+		log.Printf("dropping %T from stream %d, the current one is %d", m.msg, m.id, a.streamID)
+		return a, nil
+	}
+	model, cmd := a.Update(m.msg)
+	if a.streamChan != nil && a.streamID == m.id {
+		cmd = tea.Batch(cmd, listenToStream(a.streamID, a.streamChan))
+	}
+	return model, cmd
+}
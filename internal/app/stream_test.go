@@ -0,0 +1,282 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/blob"
+	"github.com/scbenet/ask/internal/config"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/session"
+	"github.com/scbenet/ask/internal/ui"
+	"github.com/scbenet/ask/internal/ui/compare"
+	"github.com/scbenet/ask/internal/ui/fanout"
+)
+
+// fakeClient answers "re: <prompt>" in two chunks. prompts in hold stop
+// after the first chunk until their channel is closed or the turn is
+// cancelled, which ends the stream with an error like the real clients
+type fakeClient struct {
+	hold map[string]chan struct{}
+	// prompts whose first stream fails with the error
+	fail map[string]error
+	// streams still running. only waited on once every stream has sent
+	// something, so none can be added meanwhile
+	running sync.WaitGroup
+}
+
+func (f *fakeClient) Generate(ctx context.Context, modelName string, prompt string, history []llm.Message) (string, error) {
+	return "", nil
+}
+
+func (f *fakeClient) StreamGenerate(ctx context.Context, req llm.Request, msgChan chan<- tea.Msg) {
+	f.running.Add(1)
+	defer f.running.Done()
+	defer close(msgChan)
+	prompt := req.Messages[len(req.Messages)-1].Content
+	if err, ok := f.fail[prompt]; ok {
+		delete(f.fail, prompt)
+		msgChan <- llm.StreamErrorMsg{Err: err}
+		return
+	}
+	msgChan <- llm.StreamChunkMsg{Content: "re: "}
+	if hold, ok := f.hold[prompt]; ok {
+		select {
+		case <-hold:
+		case <-ctx.Done():
+			msgChan <- llm.StreamErrorMsg{Err: ctx.Err()}
+			return
+		}
+	}
+	msgChan <- llm.StreamChunkMsg{Content: prompt}
+	msgChan <- llm.StreamEndMsg{FullResponse: "re: " + prompt}
+}
+
+// loop stands in for the bubbletea program: commands run in goroutines and
+// their messages are handed to Update one at a time by the test goroutine.
+// only stream messages, fan-out panes and connectivity checks are
+// delivered, ticks and redraws are dropped
+type loop struct {
+	t    *testing.T
+	app  *App
+	msgs chan tea.Msg
+	done chan struct{}
+}
+
+func newLoop(t *testing.T, store *session.Store, client *fakeClient) *loop {
+	a := newTestApp(t, store)
+	a.llmClient = client
+	l := &loop{t: t, app: a, msgs: make(chan tea.Msg), done: make(chan struct{})}
+	t.Cleanup(func() { close(l.done) })
+	return l
+}
+
+// newTestApp returns an app with a single provider that's never reached
+func newTestApp(t *testing.T, store *session.Store) *App {
+	return newTestAppWith(t, Options{Store: store})
+}
+
+// newTestAppWith is newTestApp with more options, the config is the test's
+func newTestAppWith(t *testing.T, opts Options) *App {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(out) })
+	for _, env := range []string{"OPENROUTER_API_KEY", "GOOGLE_API_KEY", "GEMINI_API_KEY"} {
+		t.Setenv(env, "")
+	}
+
+	cfg := config.Default()
+	cfg.Providers = []config.ProviderConfig{{Name: "test", BaseURL: "http://127.0.0.1:1", Models: []string{"m"}}}
+	opts.Config = cfg
+	a := New(opts)
+	a.selectedModel = "test:m"
+	// nothing is reached, nothing is checked
+	a.reachable = nil
+	return a
+}
+
+func (l *loop) run(cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+	go func() {
+		msg := cmd()
+		select {
+		case l.msgs <- msg:
+		case <-l.done:
+		}
+	}()
+}
+
+// send hands msg to Update and runs the command it returns
+func (l *loop) send(msg tea.Msg) {
+	_, cmd := l.app.Update(msg)
+	l.run(cmd)
+}
+
+// until delivers messages until cond holds
+func (l *loop) until(what string, cond func() bool) {
+	l.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for !cond() {
+		select {
+		case msg := <-l.msgs:
+			switch msg := msg.(type) {
+			case tea.BatchMsg:
+				for _, cmd := range msg {
+					l.run(cmd)
+				}
+			case streamMsg, connectivityMsg, fanout.PaneMsg, compare.PaneMsg:
+				l.send(msg)
+			}
+		case <-timeout:
+			l.t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// idle is true once nothing is streaming or waiting to be sent
+func (l *loop) idle() bool {
+	return l.app.streamChan == nil && len(l.app.queuedPrompts) == 0
+}
+
+func (l *loop) checkConversation(want ...string) {
+	l.t.Helper()
+	var got []string
+	for _, m := range l.app.conversation.Messages {
+		got = append(got, m.Role+": "+m.Content)
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		l.t.Fatalf("conversation is\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// TestCancelDuringChunk interrupts a response half way and sends another
+// prompt right away: the error the cancelled stream still sends must not be
+// taken for the new response's
+func TestCancelDuringChunk(t *testing.T) {
+	client := &fakeClient{hold: map[string]chan struct{}{"one": make(chan struct{})}}
+	l := newLoop(t, nil, client)
+
+	l.send(ui.SendPromptMsg{Prompt: "one"})
+	l.until("the first chunk", func() bool { return l.app.chat.PartialResponse() == "re: " })
+	l.app.interruptStream()
+	l.send(ui.SendPromptMsg{Prompt: "two"})
+	l.until("the second response", l.idle)
+	client.running.Wait()
+
+	l.checkConversation("user: one", "assistant: re: ", "user: two", "assistant: re: two")
+	if msgs := l.app.conversation.Messages; msgs[1].Error != interruptedError || msgs[3].Error != "" {
+		t.Fatalf("errors are %q and %q, want only the first response interrupted", msgs[1].Error, msgs[3].Error)
+	}
+	if l.app.lastError != nil {
+		t.Fatalf("the cancelled stream's error was shown: %v", l.app.lastError)
+	}
+}
+
+// TestRegenerateKeepsResponse puts the response /regenerate and think
+// harder replace back when the new one fails or is interrupted
+func TestRegenerateKeepsResponse(t *testing.T) {
+	client := &fakeClient{hold: map[string]chan struct{}{}, fail: map[string]error{}}
+	l := newLoop(t, nil, client)
+	l.send(ui.SendPromptMsg{Prompt: "one"})
+	l.until("the response", l.idle)
+
+	client.fail["one"] = errors.New("overloaded")
+	l.run(l.app.regenerateWith(""))
+	l.until("the failed regeneration", l.idle)
+	l.checkConversation("user: one", "assistant: re: one")
+	if msgs := l.app.conversation.Messages; msgs[0].Failed() || l.app.regenerating {
+		t.Fatalf("the prompt is marked failed after a failed regeneration: %+v", msgs[0])
+	}
+
+	client.hold["one"] = make(chan struct{})
+	l.run(l.app.regenerateWith(thinkHarderEffort))
+	l.until("the first chunk", func() bool { return l.app.chat.PartialResponse() == "re: " })
+	l.app.interruptStream()
+	client.running.Wait()
+	l.checkConversation("user: one", "assistant: re: one")
+	if last := l.app.conversation.Messages[1]; last.Error != "" {
+		t.Fatalf("the kept response is marked %q", last.Error)
+	}
+}
+
+// TestQuitDuringStream shuts down while a response streams, which must stop
+// the stream and save the conversation as far as it got
+func TestQuitDuringStream(t *testing.T) {
+	dir := t.TempDir()
+	blobs, err := blob.NewStore(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := session.NewStore(filepath.Join(dir, "sessions"), blobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &fakeClient{hold: map[string]chan struct{}{"one": make(chan struct{})}}
+	l := newLoop(t, store, client)
+
+	l.send(ui.SendPromptMsg{Prompt: "one"})
+	l.until("the first chunk", func() bool { return l.app.chat.PartialResponse() == "re: " })
+	_, cmd := l.app.Update(ShutdownMsg{})
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Fatal("shutting down didn't quit")
+	}
+	// returns once the stream noticed it was cancelled
+	client.running.Wait()
+
+	saved, err := store.Load(l.app.session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Messages) != 2 || saved.Messages[1].Content != "re: " || saved.Messages[1].Error != interruptedError {
+		t.Fatalf("saved %+v, want the prompt and the interrupted response", saved.Messages)
+	}
+}
+
+// TestRapidSends sends prompts faster than they are answered, they are
+// queued and answered in order
+func TestRapidSends(t *testing.T) {
+	client := &fakeClient{}
+	l := newLoop(t, nil, client)
+
+	for _, prompt := range []string{"one", "two", "three"} {
+		l.send(ui.SendPromptMsg{Prompt: prompt})
+	}
+	l.until("every response", func() bool { return l.idle() && l.app.conversation.Len() == 6 })
+	client.running.Wait()
+
+	l.checkConversation("user: one", "assistant: re: one", "user: two", "assistant: re: two", "user: three", "assistant: re: three")
+}
+
+// TestQueuedSendDelay holds queued prompts back by the send delay like
+// typed ones, esc puts the held prompt and the rest of the queue back in
+// the input
+func TestQueuedSendDelay(t *testing.T) {
+	a := newTestApp(t, nil)
+	a.config.SendDelay = config.Duration(time.Minute)
+	a.queuedPrompts = []string{"two", "three"}
+
+	if cmd := a.sendQueued(); cmd == nil || a.pendingSend == nil || a.pendingSend.prompt != "two" {
+		t.Fatalf("the queued prompt isn't held back, pending %+v", a.pendingSend)
+	}
+	if a.conversation.Len() != 0 || a.streamChan != nil {
+		t.Fatal("sent before the delay")
+	}
+
+	a.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if a.pendingSend != nil || len(a.queuedPrompts) != 0 {
+		t.Fatalf("still pending %+v, queued %q", a.pendingSend, a.queuedPrompts)
+	}
+	if got := a.chat.GetInputValue(); got != "two\n\nthree" {
+		t.Fatalf("input is %q, want the prompts in order", got)
+	}
+}
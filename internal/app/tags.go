@@ -0,0 +1,54 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// tagSession adds tags to the conversation for filtering `ask sessions`,
+// /tag alone lists them
+func (a *App) tagSession(tags []string) {
+	if len(tags) == 0 {
+		if len(a.session.Tags) == 0 {
+			a.chat.AddNotice(i18n.T("the conversation has no tags, /tag name adds one"))
+		} else {
+			a.chat.AddNotice(i18n.T("tagged ") + formatTags(a.session.Tags))
+		}
+		return
+	}
+	added := a.session.Tag(tags...)
+	if len(added) == 0 {
+		a.chat.AddNotice(i18n.T("already tagged ") + formatTags(a.session.Tags))
+		return
+	}
+	a.saveTags()
+	a.chat.AddNotice(i18n.Tf("tagged %s, `ask sessions -tag %s` lists the conversations tagged with it", formatTags(added), added[0]))
+}
+
+// untagSession removes tags from the conversation
+func (a *App) untagSession(tags []string) {
+	if len(tags) == 0 {
+		a.chat.AddError(i18n.T("usage: /untag tag..."))
+		return
+	}
+	removed := a.session.Untag(tags...)
+	if len(removed) == 0 {
+		a.chat.AddError(i18n.T("the conversation has no tag ") + strings.Join(tags, " "))
+		return
+	}
+	a.saveTags()
+	a.chat.AddNotice(i18n.T("removed ") + formatTags(removed))
+}
+
+// saveTags saves the session with its new tags, an empty conversation is
+// saved with them once it starts
+func (a *App) saveTags() {
+	if a.conversation.Len() > 0 {
+		a.saveSession()
+	}
+}
+
+func formatTags(tags []string) string {
+	return "#" + strings.Join(tags, " #")
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// thinkHarderEffort is the reasoning effort a response is regenerated with
+// by think harder
+const thinkHarderEffort = "high"
+
+// reasoningModels are parts of the ids of models taking a reasoning
+// effort, or a thinking budget the providers derive from it
+var reasoningModels = []string{
+	"/o1", "/o3", "/o4", "gpt-5", ":thinking", "claude-3.7", "claude-sonnet-4", "claude-opus-4",
+	"gemini-2.5", "deepseek-r1", "grok-3-mini", "grok-4", "qwen3",
+}
+
+// supportsReasoning reports whether model thinks before answering with a
+// configurable effort, any model configured with one is assumed to
+func (a *App) supportsReasoning(model string) bool {
+	if a.config.ModelConfig(model).ReasoningEffort != "" {
+		return true
+	}
+	id := strings.ToLower(model)
+	for _, m := range reasoningModels {
+		if strings.Contains(id, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// thinkHarder regenerates the last response with the highest reasoning
+// effort, for when a reasoning model answered too quickly
+func (a *App) thinkHarder() tea.Cmd {
+	model := a.selectedModel
+	if !a.supportsReasoning(model) {
+		a.chat.AddError(i18n.Tf("%s doesn't take a reasoning effort, think harder needs a reasoning model (o-series, claude thinking, gemini 2.5…)", model))
+		return nil
+	}
+	if a.config.ModelConfig(model).ReasoningEffort == thinkHarderEffort {
+		a.chat.AddError(i18n.Tf("%s already answers with %s reasoning effort, /regenerate asks again", model, thinkHarderEffort))
+		return nil
+	}
+	return a.regenerateWith(thinkHarderEffort)
+}
@@ -0,0 +1,296 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/logging"
+	"github.com/scbenet/ask/internal/tools"
+	"github.com/scbenet/ask/internal/ui"
+)
+
+// stop a model that keeps calling tools instead of answering
+const maxToolRounds = 10
+
+// the most output sent to the model when asking it for a summary
+const maxSummaryInput = 256 * 1024
+
+// toolApprovalMsg asks the user to allow a call outside its tool's policy.
+// the executor goroutine blocks until an answer is sent on reply
+type toolApprovalMsg struct {
+	call   llm.ToolCall
+	reason string
+	reply  chan<- bool
+}
+
+// toolResult is a tool message along with where its full output is kept
+// when the message only carries a shortened version
+type toolResult struct {
+	llm.Message
+	outputHash string
+}
+
+// toolResultMsg carries the result of one call as soon as it finishes,
+// with what summarizing its output cost, if it was summarized
+type toolResultMsg struct {
+	result       toolResult
+	summaryModel string
+	summaryUsage *llm.Usage
+}
+
+// toolsDoneMsg is sent once every call of a round has a result
+type toolsDoneMsg struct{}
+
+// streamRequest builds the request for the next response from model, with
+// the conversation so far and the available tools
+func (a *App) streamRequest(model string) llm.Request {
+	req := a.newRequest(model, a.conversation.LLMMessages())
+	if a.turnEffort != "" {
+		req.ReasoningEffort = a.turnEffort
+	}
+	if a.continuing {
+		req.Messages = continuationMessages(req)
+	}
+	if a.tools != nil {
+		req.Tools = a.tools.Definitions()
+		if a.session.Incognito {
+			req.Tools = slices.DeleteFunc(req.Tools, func(d llm.ToolDefinition) bool { return d.Function.Name == "remember" })
+		}
+	}
+	return req
+}
+
+// startStream sends the conversation to the model answering this turn
+func (a *App) startStream() tea.Cmd {
+	req := a.streamRequest(a.turnModel)
+	log.Printf("History length for stream: %d", len(req.Messages))
+
+	ch, listen := a.newStream()
+	go a.llmClient.StreamGenerate(a.requestContext(), req, ch)
+	return tea.Batch(listen, a.watchStall())
+}
+
+// runTools executes the calls from the last response in the background.
+// approvals and results come back over streamChan, so the app stays in its
+// sending state until the model has finished answering
+func (a *App) runTools(calls []llm.ToolCall) tea.Cmd {
+	for _, call := range calls {
+		a.chat.AddNotice(ui.DescribeToolCall(call))
+	}
+
+	a.toolRounds++
+	if a.toolRounds > maxToolRounds {
+		// every call still needs an answer or the next request is rejected
+		results := make([]toolResult, len(calls))
+		for i, call := range calls {
+			results[i].Message = llm.Message{Role: "tool", ToolCallID: call.ID, Content: "error: tool call limit reached, answer without further tool calls"}
+		}
+		a.addToolResults(results)
+		a.chat.AddError(i18n.Tf("stopped after %d rounds of tool calls", maxToolRounds))
+		return nil
+	}
+
+	ch, listen := a.newStream()
+	model, ctx := a.turnModel, a.turnCtx
+	limit := max(a.config.Tools.Concurrency, 1)
+	// the full output of an ephemeral turn isn't kept, it would outlive it
+	keepOutput := a.store != nil && !a.session.Incognito && !a.conversation.Ephemeral()
+	go func() {
+		defer close(ch)
+		// only one question can be on screen at a time
+		var approving sync.Mutex
+		approve := func(call llm.ToolCall, reason string) bool {
+			approving.Lock()
+			defer approving.Unlock()
+			// buffered, the answer may come after the turn was cancelled
+			reply := make(chan bool, 1)
+			ch <- toolApprovalMsg{call: call, reason: reason, reply: reply}
+			select {
+			case ok := <-reply:
+				return ok
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// calls in one response are independent, run up to limit at once
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, limit)
+		for _, call := range calls {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer func() { <-sem; wg.Done() }()
+				result := a.tools.Execute(ctx, call, approve)
+				ch <- a.shortenToolResult(ctx, call, result, model, keepOutput)
+			}()
+		}
+		wg.Wait()
+		ch <- toolsDoneMsg{}
+	}()
+	return listen
+}
+
+// sendToolResults lets the model continue with the results of its tool
+// calls, asking first if that would go over a budget
+func (a *App) sendToolResults() tea.Cmd {
+	reason := a.overBudgetNext()
+	if reason == "" {
+		return a.startStream()
+	}
+	a.confirmOverBudget(reason, a.startStream, func() tea.Cmd {
+		// the results stay in the conversation, the next prompt sends them along
+		a.endStream()
+		a.chat.SetSending(false)
+		a.saveSession()
+		return a.sendQueued()
+	})
+	return nil
+}
+
+// approveTool asks the user about a call the policy doesn't cover
+func (a *App) approveTool(m toolApprovalMsg) {
+	answer := func(ok bool) func() tea.Cmd {
+		return func() tea.Cmd {
+			m.reply <- ok
+			return nil
+		}
+	}
+	question := i18n.Tf("allow %s? it %s", ui.DescribeToolCall(m.call), m.reason)
+	a.confirmOr(question, answer(true), answer(false))
+}
+
+// shortenToolResult applies the configured truncation to output over the
+// size limit, keeping the full output in the blob store for /output if
+// keepOutput. runs on the executor goroutine, summarizing blocks on a request
+// that is cancelled along with the turn
+func (a *App) shortenToolResult(ctx context.Context, call llm.ToolCall, result llm.Message, model string, keepOutput bool) toolResultMsg {
+	limit := a.config.Tools.MaxOutput
+	if limit <= 0 || len(result.Content) <= limit {
+		return toolResultMsg{result: toolResult{Message: result}}
+	}
+
+	full := result.Content
+	var hash string
+	if keepOutput {
+		var err error
+		if hash, err = a.store.Blobs().Put([]byte(full)); err != nil {
+			logging.Errorf("storing full output of %s: %v", call.Function.Name, err)
+		}
+	}
+
+	if a.config.Tools.Truncate == tools.TruncateSummarize {
+		end, err := a.summarizeToolOutput(ctx, call, full, model)
+		if err == nil {
+			result.Content = fmt.Sprintf("[output was %d bytes, summarized]\n%s", len(full), end.FullResponse)
+			return toolResultMsg{result: toolResult{Message: result, outputHash: hash}, summaryModel: model, summaryUsage: end.Usage}
+		}
+		logging.Errorf("summarizing output of %s, keeping head and tail instead: %v", call.Function.Name, err)
+	}
+	result.Content = tools.HeadTail(full, limit)
+	return toolResultMsg{result: toolResult{Message: result, outputHash: hash}}
+}
+
+// summarizeToolOutput asks model for a summary of output that is too long
+// to add to the context as is. streamed for the usage, which is paid for
+func (a *App) summarizeToolOutput(ctx context.Context, call llm.ToolCall, output, model string) (*llm.StreamEndMsg, error) {
+	prompt := fmt.Sprintf("The %s tool was called with %s and printed the output below. "+
+		"Summarize it for someone who needs to act on it. Quote errors, failing test names, "+
+		"file paths and line numbers verbatim. Reply with the summary only.\n\n%s",
+		call.Function.Name, call.Function.Arguments, tools.HeadTail(output, maxSummaryInput))
+	req := llm.Request{Model: model, Messages: []llm.Message{{Role: "user", Content: prompt}}}
+	return a.generate(llm.WithRequestID(ctx, llm.NewRequestID()), req)
+}
+
+// addToolResults records tool output in the conversation
+func (a *App) addToolResults(results []toolResult) {
+	for _, r := range results {
+		a.addToolResult(r)
+	}
+}
+
+func (a *App) addToolResult(r toolResult) {
+	a.conversation.Add(conversation.Message{
+		Role:       "tool",
+		Content:    r.Content,
+		ToolCallID: r.ToolCallID,
+		OutputHash: r.outputHash,
+	})
+	notice := ui.DescribeToolResult(r.Content)
+	if r.outputHash != "" {
+		notice += i18n.T(" — shortened, /output for all of it")
+	}
+	a.chat.AddNotice(notice)
+	a.saveSession()
+}
+
+// showToolOutput opens the full output of the nth most recent tool call in
+// $PAGER, including anything cut from what the model saw
+func (a *App) showToolOutput(args []string) tea.Cmd {
+	var results []*conversation.Message
+	for i := len(a.conversation.Messages) - 1; i >= 0; i-- {
+		if a.conversation.Messages[i].Role == "tool" {
+			results = append(results, &a.conversation.Messages[i])
+		}
+	}
+	if len(results) == 0 {
+		a.chat.AddNotice(i18n.T("no tool output in this conversation"))
+		return nil
+	}
+	n, err := argIndex(args, len(results))
+	if err != nil {
+		a.chat.AddError(err.Error())
+		return nil
+	}
+	msg := results[n]
+
+	output := []byte(msg.Content)
+	if msg.OutputHash != "" && a.store != nil {
+		if full, err := a.store.Blobs().Get(msg.OutputHash); err == nil {
+			output = full
+		} else {
+			a.chat.AddError(i18n.Tf("full output is gone, showing what the model saw: %v", err))
+		}
+	}
+
+	f, err := os.CreateTemp("", "ask-output-*.txt")
+	if err != nil {
+		a.chat.AddError(err.Error())
+		return nil
+	}
+	_, err = f.Write(output)
+	f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		a.chat.AddError(err.Error())
+		return nil
+	}
+
+	pager := strings.Fields(os.Getenv("PAGER"))
+	switch {
+	case len(pager) > 0:
+	case runtime.GOOS == "windows":
+		pager = []string{"more"}
+	default:
+		pager = []string{"less"}
+	}
+	cmd := exec.Command(pager[0], append(pager[1:], f.Name())...)
+	return a.keyboard.ExecProcess(cmd, func(err error) tea.Msg {
+		os.Remove(f.Name())
+		if err != nil {
+			logging.Errorf("running pager: %v", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,130 @@
+package attach
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Attachment is a blob of text (file contents, piped stdin, ...) that gets
+// sent to the model as context alongside a prompt
+type Attachment struct {
+	Name string `json:"name"`
+	// Content is left out of saved sessions once the attachment has been
+	// written to the blob store, Hash is used to load it back
+	Content string `json:"content,omitempty"`
+	Hash    string `json:"hash,omitempty"`
+	// Excluded attachments stay with their message but aren't sent
+	Excluded bool `json:"excluded,omitempty"`
+	// Path is the absolute path of an attached file, Refresh reads it again
+	Path string `json:"path,omitempty"`
+}
+
+// FromReader reads everything from r into a new attachment
+func FromReader(name string, r io.Reader) (Attachment, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	return Attachment{Name: name, Content: string(data)}, nil
+}
+
+// MaxFileSize is the biggest file FromFile attaches, and the most Refresh
+// reads: a file that grew past it is sent as it was attached
+const MaxFileSize = 1 << 20
+
+// FromFile reads the text file at path into an attachment named path
+func FromFile(path string) (Attachment, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to attach %s: %w", path, err)
+	}
+	content, err := readText(abs)
+	if err != nil {
+		return Attachment{}, fmt.Errorf("failed to attach %s: %w", path, err)
+	}
+	return Attachment{Name: path, Content: content, Path: abs}, nil
+}
+
+// Refresh reads an attached file again, reporting whether it changed since
+// it was read. attachments that aren't files never change, and the last
+// contents are kept if the file can't be read anymore
+func (a *Attachment) Refresh() (bool, error) {
+	if a.Path == "" {
+		return false, nil
+	}
+	content, err := readText(a.Path)
+	if err != nil {
+		return false, err
+	}
+	if content == a.Content {
+		return false, nil
+	}
+	a.Content = content
+	// the blob store has the old contents under the old hash
+	a.Hash = ""
+	return true, nil
+}
+
+func readText(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > MaxFileSize {
+		return "", fmt.Errorf("%w (%dKB, %dKB at most)", ErrTooLarge, info.Size()>>10, MaxFileSize>>10)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) {
+		return "", ErrBinary
+	}
+	return string(data), nil
+}
+
+var (
+	// ErrBinary is returned for files that aren't text
+	ErrBinary = errors.New("not a text file")
+	// ErrTooLarge is returned for files too big to attach
+	ErrTooLarge = errors.New("file too large")
+)
+
+// Lines returns the number of lines in the attachment
+func (a Attachment) Lines() int {
+	trimmed := strings.TrimSuffix(a.Content, "\n")
+	if trimmed == "" {
+		return 0
+	}
+	return strings.Count(trimmed, "\n") + 1
+}
+
+// Summary is the collapsed one-line representation shown in the chat
+func (a Attachment) Summary() string {
+	return fmt.Sprintf("📎 %s (%d lines)", a.Name, a.Lines())
+}
+
+// Format renders the attachment the way it is sent to the model
+func (a Attachment) Format() string {
+	return fmt.Sprintf("<attachment name=%q>\n%s\n</attachment>", a.Name, strings.TrimSuffix(a.Content, "\n"))
+}
+
+// Wrap prepends any attachments to the prompt so the model sees them as
+// context, excluded ones are left out
+func Wrap(prompt string, attachments []Attachment) string {
+	var b strings.Builder
+	for _, a := range attachments {
+		if a.Excluded {
+			continue
+		}
+		b.WriteString(a.Format())
+		b.WriteString("\n\n")
+	}
+	b.WriteString(prompt)
+	return b.String()
+}
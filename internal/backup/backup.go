@@ -0,0 +1,261 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Source is a directory included in a backup under Name
+type Source struct {
+	Name string
+	Dir  string
+	// Skip lists paths relative to Dir that are left out
+	Skip []string
+	// Config lists the JSON files, relative to Dir, that ExcludeKeys strips
+	// credentials from. everything else is backed up as it is
+	Config []string
+}
+
+// Options controls what goes into a backup
+type Options struct {
+	// ExcludeKeys strips API keys and tokens from the sources' Config files
+	ExcludeKeys bool
+}
+
+// Create writes a tar archive of sources to path, compressed according to
+// the extension (.tar.zst, .tar.gz/.tgz or plain .tar)
+func Create(path string, sources []Source, opts Options) (int, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer f.Close()
+
+	compressed, err := compressor(path, f)
+	if err != nil {
+		return 0, err
+	}
+	tw := tar.NewWriter(compressed)
+
+	files := 0
+	for _, src := range sources {
+		n, err := addDir(tw, src, opts)
+		if err != nil {
+			return files, err
+		}
+		files += n
+	}
+
+	if err := tw.Close(); err != nil {
+		return files, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	if err := compressed.Close(); err != nil {
+		return files, fmt.Errorf("failed to finish compression: %w", err)
+	}
+	return files, f.Close()
+}
+
+func addDir(tw *tar.Writer, src Source, opts Options) (int, error) {
+	if _, err := os.Stat(src.Dir); errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+
+	files := 0
+	err := filepath.WalkDir(src.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src.Dir, path)
+		if err != nil {
+			return err
+		}
+		for _, skip := range src.Skip {
+			if rel == skip || strings.HasPrefix(rel, skip+string(filepath.Separator)) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if d.IsDir() || !d.Type().IsRegular() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if opts.ExcludeKeys && slices.Contains(src.Config, rel) {
+			data = stripKeys(data)
+		}
+
+		hdr := &tar.Header{
+			Name: filepath.ToSlash(filepath.Join(src.Name, rel)),
+			Mode: 0o600,
+			Size: int64(len(data)),
+		}
+		if info, err := d.Info(); err == nil {
+			hdr.ModTime = info.ModTime()
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+		files++
+		return nil
+	})
+	if err != nil {
+		return files, fmt.Errorf("failed to back up %s: %w", src.Dir, err)
+	}
+	return files, nil
+}
+
+// Restore extracts an archive created by Create. each top level entry is
+// written into the directory of the source with the same name
+func Restore(path string, sources []Source) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer f.Close()
+
+	r, err := decompressor(path, f)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	dirs := map[string]string{}
+	for _, src := range sources {
+		dirs[src.Name] = src.Dir
+	}
+
+	tr := tar.NewReader(r)
+	files := 0
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return files, fmt.Errorf("failed to read backup: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name, rel, ok := strings.Cut(hdr.Name, "/")
+		dir, known := dirs[name]
+		if !ok || !known {
+			continue
+		}
+		// never write outside the target directory
+		target := filepath.Join(dir, filepath.FromSlash(rel))
+		if !strings.HasPrefix(target, filepath.Clean(dir)+string(filepath.Separator)) {
+			return files, fmt.Errorf("backup contains unsafe path %q", hdr.Name)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+			return files, err
+		}
+		out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return files, err
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			return files, fmt.Errorf("failed to restore %s: %w", target, err)
+		}
+		if err := out.Close(); err != nil {
+			return files, err
+		}
+		files++
+	}
+	return files, nil
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func compressor(path string, w io.Writer) (io.WriteCloser, error) {
+	switch {
+	case strings.HasSuffix(path, ".zst"):
+		return zstd.NewWriter(w)
+	case strings.HasSuffix(path, ".gz"), strings.HasSuffix(path, ".tgz"):
+		return gzip.NewWriter(w), nil
+	case strings.HasSuffix(path, ".tar"):
+		return nopWriteCloser{w}, nil
+	}
+	return nil, fmt.Errorf("unsupported backup format %q, use .tar.zst, .tar.gz or .tar", filepath.Base(path))
+}
+
+func decompressor(path string, r io.Reader) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(path, ".zst"):
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	case strings.HasSuffix(path, ".gz"), strings.HasSuffix(path, ".tgz"):
+		return gzip.NewReader(r)
+	case strings.HasSuffix(path, ".tar"):
+		return io.NopCloser(r), nil
+	}
+	return nil, fmt.Errorf("unsupported backup format %q, use .tar.zst, .tar.gz or .tar", filepath.Base(path))
+}
+
+// stripKeys blanks out any JSON field that looks like a credential. files
+// that aren't valid JSON are returned unchanged
+func stripKeys(data []byte) []byte {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return data
+	}
+	stripped, err := json.MarshalIndent(stripValue(doc), "", "    ")
+	if err != nil {
+		return data
+	}
+	return stripped
+}
+
+func stripValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, child := range val {
+			if isSecretKey(k) {
+				delete(val, k)
+				continue
+			}
+			val[k] = stripValue(child)
+		}
+	case []any:
+		for i, child := range val {
+			val[i] = stripValue(child)
+		}
+	}
+	return v
+}
+
+func isSecretKey(name string) bool {
+	n := strings.ToLower(strings.ReplaceAll(name, "_", ""))
+	// apiKeyEnv only names an environment variable, it holds no secret
+	if strings.HasSuffix(n, "env") {
+		return false
+	}
+	return strings.HasSuffix(n, "apikey") || strings.HasSuffix(n, "token") || strings.HasSuffix(n, "secret") || n == "password"
+}
@@ -0,0 +1,128 @@
+package backup
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files under dir, names are slash separated
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestCreateRestore(t *testing.T) {
+	for _, ext := range []string{".tar.zst", ".tar.gz", ".tar"} {
+		t.Run(ext, func(t *testing.T) {
+			from := t.TempDir()
+			writeFiles(t, from, map[string]string{
+				"config/config.json":          `{"model": "m", "openrouterApiKey": "sk-or-1"}`,
+				"config/profiles/work/x.json": `{}`,
+				"data/sessions/a.json":        `{"id": "a", "apiKey": "pasted by the user"}`,
+				"data/sessions/a.json.tmp":    `{`,
+				"data/backups/old.tar.zst":    "old",
+			})
+			sources := func(root string) []Source {
+				return []Source{
+					{Name: "config", Dir: filepath.Join(root, "config"), Skip: []string{"profiles"}, Config: []string{"config.json"}},
+					{Name: "data", Dir: filepath.Join(root, "data"), Skip: []string{"backups"}},
+				}
+			}
+
+			archive := filepath.Join(t.TempDir(), "ask"+ext)
+			n, err := Create(archive, sources(from), Options{ExcludeKeys: true})
+			if err != nil || n != 2 {
+				t.Fatalf("Create() = %d, %v, want 2 files", n, err)
+			}
+
+			to := t.TempDir()
+			if n, err := Restore(archive, sources(to)); err != nil || n != 2 {
+				t.Fatalf("Restore() = %d, %v, want 2 files", n, err)
+			}
+			if got := readFile(t, filepath.Join(to, "config", "config.json")); strings.Contains(got, "sk-or-1") || !strings.Contains(got, `"model": "m"`) {
+				t.Errorf("restored config is %s", got)
+			}
+			// only the config is stripped, sessions are backed up as they are
+			if got := readFile(t, filepath.Join(to, "data", "sessions", "a.json")); got != `{"id": "a", "apiKey": "pasted by the user"}` {
+				t.Errorf("restored session is %s", got)
+			}
+			for _, skipped := range []string{"config/profiles", "data/backups", "data/sessions/a.json.tmp"} {
+				if _, err := os.Stat(filepath.Join(to, filepath.FromSlash(skipped))); !os.IsNotExist(err) {
+					t.Errorf("%s was backed up", skipped)
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreUnsafePath(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "evil.tar")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tw := tar.NewWriter(f)
+	content := "pwned"
+	if err := tw.WriteHeader(&tar.Header{Name: "data/../../escaped", Mode: 0o600, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "data")
+	if _, err := Restore(archive, []Source{{Name: "data", Dir: dir}}); err == nil || !strings.Contains(err.Error(), "unsafe path") {
+		t.Fatalf("Restore() = %v, want an unsafe path error", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(root), "escaped")); !os.IsNotExist(err) {
+		t.Fatal("the archive wrote outside the target directory")
+	}
+}
+
+func TestIsSecretKey(t *testing.T) {
+	for key, want := range map[string]bool{
+		"apiKey":             true,
+		"openrouter_api_key": true,
+		"githubToken":        true,
+		"clientSecret":       true,
+		"password":           true,
+		"apiKeyEnv":          false,
+		"maxTokens":          false,
+		"model":              false,
+	} {
+		if got := isSecretKey(key); got != want {
+			t.Errorf("isSecretKey(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	if _, err := Create(filepath.Join(t.TempDir(), "ask.zip"), nil, Options{}); err == nil || !strings.Contains(err.Error(), "unsupported backup format") {
+		t.Fatalf("Create() = %v", err)
+	}
+}
@@ -0,0 +1,148 @@
+package blob
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"github.com/scbenet/ask/internal/vfs"
+)
+
+// Store is a content addressable store, every blob is saved once under the
+// sha256 of its contents so identical attachments are never duplicated
+type Store struct {
+	dir string
+	fs  vfs.FS
+}
+
+// Option configures a Store
+type Option func(*Store)
+
+// WithFS keeps the blobs on fsys instead of the disk
+func WithFS(fsys vfs.FS) Option {
+	return func(s *Store) { s.fs = fsys }
+}
+
+// NewStore opens (creating if needed) a blob store in dir
+func NewStore(dir string, opts ...Option) (*Store, error) {
+	s := &Store{dir: dir, fs: vfs.OS}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.fs.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	return s, nil
+}
+
+// Hash returns the key data is stored under
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// blobs are sharded by the first two hex characters to keep directories small
+func (s *Store) path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash[2:])
+}
+
+func validHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// Put stores data and returns its hash, it is a no-op if the blob already exists
+func (s *Store) Put(data []byte) (string, error) {
+	hash := Hash(data)
+	path := s.path(hash)
+	if _, err := s.fs.Stat(path); err == nil {
+		return hash, nil
+	}
+
+	if err := s.fs.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := s.fs.WriteFile(tmp, data, 0o600); err != nil {
+		return "", fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := s.fs.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("failed to store blob: %w", err)
+	}
+	return hash, nil
+}
+
+// Get returns the contents of a blob
+func (s *Store) Get(hash string) ([]byte, error) {
+	if !validHash(hash) {
+		return nil, fmt.Errorf("invalid blob hash %q", hash)
+	}
+	data, err := s.fs.ReadFile(s.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("blob %s not found", hash)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+	return data, nil
+}
+
+// Info describes a stored blob
+type Info struct {
+	Hash    string
+	Size    int64
+	ModTime time.Time
+}
+
+// List returns every blob in the store
+func (s *Store) List() ([]Info, error) {
+	var blobs []Info
+	err := vfs.WalkDir(s.fs, s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		hash := filepath.Dir(rel) + filepath.Base(rel)
+		if !validHash(hash) {
+			return nil // temp files and strays
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		blobs = append(blobs, Info{Hash: hash, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+	return blobs, nil
+}
+
+// Remove deletes a blob, and its shard directory if that leaves it empty
+func (s *Store) Remove(hash string) error {
+	if !validHash(hash) {
+		return fmt.Errorf("invalid blob hash %q", hash)
+	}
+	if err := s.fs.Remove(s.path(hash)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove blob: %w", err)
+	}
+	// fails harmlessly if other blobs share the shard
+	_ = s.fs.Remove(filepath.Dir(s.path(hash)))
+	return nil
+}
+
+// Dir returns the directory the blobs are stored in
+func (s *Store) Dir() string {
+	return s.dir
+}
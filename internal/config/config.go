@@ -0,0 +1,570 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scbenet/ask/internal/paths"
+	"github.com/scbenet/ask/internal/vfs"
+)
+
+// Config is the user configuration read from config.json. every field is
+// optional, anything left out falls back to Default()
+type Config struct {
+	// RequestTimeout bounds the total duration of a single request
+	RequestTimeout Duration `json:"requestTimeout,omitempty"`
+	// StallTimeout aborts a stream when no data arrives for this long
+	StallTimeout Duration `json:"stallTimeout,omitempty"`
+	// StallWarning asks whether to keep waiting, retry or cancel when no
+	// data arrived for this long, 0 turns it off
+	StallWarning Duration `json:"stallWarning,omitempty"`
+	// SendDelay holds a prompt back for this long after enter, esc cancels it
+	SendDelay Duration `json:"sendDelay,omitempty"`
+	// Math is how LaTeX in responses is shown: "unicode" approximates it,
+	// "latex" keeps the source set apart as code and "off" leaves it alone
+	Math string `json:"math,omitempty"`
+	// Messages sets how the messages of each role are shown
+	Messages MessagesConfig `json:"messages"`
+	// Timestamps shows when each message was sent and which model wrote
+	// each response, /timestamps toggles it
+	Timestamps bool `json:"timestamps"`
+	// Mouse enables scrolling and selecting with the mouse. turning it off
+	// leaves the mouse to the terminal, e.g. for its own text selection
+	Mouse bool `json:"mouse"`
+	// Accessible is the screen reader friendly mode, see -accessible
+	Accessible bool `json:"accessible,omitempty"`
+	// KeyboardProtocol asks the terminal for the kitty keyboard protocol,
+	// where it's supported shift+enter can be told apart from enter
+	KeyboardProtocol bool `json:"keyboardProtocol"`
+	// SendKey is the key sending a prompt: "enter", or "ctrl+enter" or
+	// "alt+enter" for enter to insert a new line instead
+	SendKey string `json:"sendKey,omitempty"`
+	// Autosave is how often a response being streamed is saved so a crash
+	// doesn't lose it, 0 turns it off
+	Autosave Duration `json:"autosave,omitempty"`
+	// TrashRetention is how long deleted sessions and messages are kept
+	// before they're gone for good, 0 keeps them until the trash is emptied
+	TrashRetention Duration `json:"trashRetention,omitempty"`
+	// CreditWarning warns when the openrouter balance drops below this many
+	// dollars, 0 turns the check off
+	CreditWarning float64 `json:"creditWarning,omitempty"`
+	// Log configures the log file, see LogConfig
+	Log LogConfig `json:"log"`
+	// Journal appends every prompt and response to a markdown file per day
+	Journal JournalConfig `json:"journal"`
+	// RateLimit keeps requests under the provider's limits
+	RateLimit RateLimitConfig `json:"rateLimit"`
+	// Retry sends a response again when its stream fails part way
+	Retry RetryConfig `json:"retry"`
+	// Budget asks before sending a request that would spend more than this
+	Budget BudgetConfig `json:"budget"`
+	// Redact are regular expressions replaced with "[redacted]" in
+	// everything sent to a provider, see Redactions
+	Redact []string `json:"redact,omitempty"`
+	// Tools configures function calling
+	Tools ToolsConfig `json:"tools"`
+	// Providers are extra OpenAI-compatible endpoints, e.g. LM Studio or vLLM
+	Providers []ProviderConfig `json:"providers,omitempty"`
+	// Memory remembers facts about the user across sessions
+	Memory MemoryConfig `json:"memory"`
+	// Downgrade answers simple prompts with a cheaper model
+	Downgrade DowngradeConfig `json:"downgrade"`
+	// Fanout are the models /fanout sends a prompt to at once
+	Fanout []string `json:"fanout,omitempty"`
+	// Judge scores the candidate answers of /fanout and /regenerate
+	Judge JudgeConfig `json:"judge"`
+	// AutoContinue is how many times in a row a response cut off by the max
+	// tokens is continued without asking, 0 leaves it to /continue
+	AutoContinue int `json:"autoContinue,omitempty"`
+	// Language is the language of the interface, e.g. "de". empty takes it
+	// from the locale (LANG etc.)
+	Language string `json:"language,omitempty"`
+	// Personas are system prompt presets picked with ctrl+g
+	Personas []Persona `json:"personas,omitempty"`
+	// Models holds per-model settings keyed by model id. keys may be glob
+	// patterns like "openai/o*", the longest matching key wins
+	Models map[string]ModelConfig `json:"models,omitempty"`
+}
+
+// MessagesConfig styles the user's messages and the responses
+type MessagesConfig struct {
+	User      UserMessageConfig `json:"user"`
+	Assistant MessageConfig     `json:"assistant"`
+}
+
+// MessageConfig is how the messages of a role are shown
+type MessageConfig struct {
+	// Markdown is one of glamour's styles (dark, light, dracula, tokyo-night,
+	// pink, ascii, notty) or the path of a JSON style file. user messages
+	// are plain text unless it's set
+	Markdown string `json:"markdown,omitempty"`
+	// Label is a line above every message, e.g. "You:"
+	Label string `json:"label,omitempty"`
+}
+
+// UserMessageConfig is MessageConfig with a prefix, "> " if unset
+type UserMessageConfig struct {
+	MessageConfig
+	Prefix *string `json:"prefix,omitempty"`
+}
+
+// MemoryConfig controls the memory store, it is off unless enabled
+type MemoryConfig struct {
+	Enabled bool `json:"enabled"`
+	// Extract looks for new memories when a conversation ends and asks
+	// before saving them
+	Extract bool `json:"extract"`
+	// ExtractModel does the extraction, defaults to the current model
+	ExtractModel string `json:"extractModel,omitempty"`
+}
+
+// LogConfig controls the log file. only errors and a record of requests are
+// logged by default, -debug logs everything
+type LogConfig struct {
+	// Path defaults to $XDG_STATE_HOME/ask/ask.log
+	Path string `json:"path,omitempty"`
+	// Level is off, error, info or debug
+	Level string `json:"level,omitempty"`
+	// MaxSize is how many megabytes the log grows to before it's rotated
+	MaxSize int `json:"maxSize,omitempty"`
+	// MaxFiles is how many rotated logs are kept
+	MaxFiles int `json:"maxFiles,omitempty"`
+}
+
+// JournalConfig controls the journal, it is off unless enabled or ask is
+// started with -log-responses
+type JournalConfig struct {
+	Enabled bool `json:"enabled"`
+	// Dir defaults to $XDG_DATA_HOME/ask/journal
+	Dir string `json:"dir,omitempty"`
+}
+
+// RateLimitConfig spaces out requests, from the chat, compare panes and
+// background jobs alike, instead of letting the provider answer 429. every
+// limit is off when 0
+type RateLimitConfig struct {
+	RequestsPerMinute int `json:"requestsPerMinute,omitempty"`
+	// TokensPerMinute counts prompt tokens as estimated before sending and
+	// the usage the provider reports afterwards
+	TokensPerMinute int `json:"tokensPerMinute,omitempty"`
+	// Concurrent is how many requests may be in flight at once
+	Concurrent int `json:"concurrent,omitempty"`
+}
+
+// Enabled reports whether any limit is set
+func (r RateLimitConfig) Enabled() bool {
+	return r.RequestsPerMinute > 0 || r.TokensPerMinute > 0 || r.Concurrent > 0
+}
+
+// RetryConfig controls retrying streams that fail with a dropped
+// connection, a stall or an overloaded provider
+type RetryConfig struct {
+	// Attempts is how many times a response is retried, 0 turns retries off
+	Attempts int `json:"attempts"`
+	// Backoff is the wait before the first retry, doubling for each next one
+	Backoff Duration `json:"backoff,omitempty"`
+}
+
+// BudgetConfig caps spending in USD, estimated from the model's pricing.
+// a request that would go over a budget is only sent if the user says so.
+// every budget is off when 0
+type BudgetConfig struct {
+	// Session is the most one conversation may cost
+	Session float64 `json:"session,omitempty"`
+	// Daily is the most every conversation on one day may cost together
+	Daily float64 `json:"daily,omitempty"`
+}
+
+// Enabled reports whether any budget is set
+func (b BudgetConfig) Enabled() bool {
+	return b.Session > 0 || b.Daily > 0
+}
+
+// Redactions compiles the Redact patterns
+func (c Config) Redactions() ([]*regexp.Regexp, error) {
+	var patterns []*regexp.Regexp
+	for _, expr := range c.Redact {
+		p, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("redact: %w", err)
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// DowngradeConfig routes prompts that look trivial to a cheaper model, it
+// is off unless enabled
+type DowngradeConfig struct {
+	Enabled bool `json:"enabled"`
+	// Model answers the prompts judged simple
+	Model string `json:"model"`
+	// MaxLength is the longest prompt in characters that may be downgraded
+	MaxLength int `json:"maxLength,omitempty"`
+	// Classify also asks Model whether a prompt is simple, instead of going
+	// by its length and contents alone
+	Classify bool `json:"classify,omitempty"`
+}
+
+// JudgeConfig sets up the model ranking candidate answers
+type JudgeConfig struct {
+	// Model does the judging, defaults to the current model
+	Model string `json:"model,omitempty"`
+	// Rubric is what the answers are scored by, correctness, completeness
+	// and clarity by default
+	Rubric string `json:"rubric,omitempty"`
+	// Auto judges every fan-out once all its answers are in, and every
+	// regenerated response against the one it replaced
+	Auto bool `json:"auto,omitempty"`
+}
+
+// Persona is a named system prompt for a conversation
+type Persona struct {
+	Name   string `json:"name"`
+	Prompt string `json:"prompt"`
+}
+
+// Persona returns the persona with the given name
+func (c Config) Persona(name string) (Persona, bool) {
+	for _, p := range c.Personas {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Persona{}, false
+}
+
+// ModelConfig is applied to every request sent to a model
+type ModelConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	MaxTokens       int      `json:"maxTokens,omitempty"`
+	ReasoningEffort string   `json:"reasoningEffort,omitempty"` // low, medium or high
+	SystemPrompt    string   `json:"systemPrompt,omitempty"`
+	// SendDelay overrides the global send delay, e.g. for expensive models
+	SendDelay *Duration `json:"sendDelay,omitempty"`
+	// Resume says whether the model continues a partial response it's sent,
+	// which lets a retry pick up where the stream failed instead of starting
+	// over. on by default for anthropic models only
+	Resume *bool `json:"resume,omitempty"`
+
+	// Stop ends a response where the model writes one of these
+	Stop []string `json:"stop,omitempty"`
+
+	// sampling settings, left to the provider when unset. see CheckParams
+	// for the ranges
+	Seed              *int     `json:"seed,omitempty"`
+	FrequencyPenalty  *float64 `json:"frequencyPenalty,omitempty"`
+	PresencePenalty   *float64 `json:"presencePenalty,omitempty"`
+	RepetitionPenalty *float64 `json:"repetitionPenalty,omitempty"`
+	MinP              *float64 `json:"minP,omitempty"`
+	// LogitBias maps token ids, as the model's tokenizer numbers them, to a
+	// bias from -100 (never) to 100 (always)
+	LogitBias map[string]float64 `json:"logitBias,omitempty"`
+}
+
+// CheckParams checks the sampling settings are in the ranges providers
+// accept, naming the first one that isn't
+func (mc ModelConfig) CheckParams() error {
+	for name, r := range map[string]struct {
+		v        *float64
+		min, max float64
+	}{
+		"temperature":       {mc.Temperature, 0, 2},
+		"frequencyPenalty":  {mc.FrequencyPenalty, -2, 2},
+		"presencePenalty":   {mc.PresencePenalty, -2, 2},
+		"repetitionPenalty": {mc.RepetitionPenalty, 0, 2},
+		"minP":              {mc.MinP, 0, 1},
+	} {
+		if r.v != nil && (*r.v < r.min || *r.v > r.max) {
+			return fmt.Errorf("%s must be between %g and %g", name, r.min, r.max)
+		}
+	}
+	if mc.RepetitionPenalty != nil && *mc.RepetitionPenalty == 0 {
+		return errors.New("repetitionPenalty must be above 0, 1 leaves it off")
+	}
+	if mc.MaxTokens < 0 {
+		return errors.New("maxTokens can't be negative")
+	}
+	// the most openai takes
+	if len(mc.Stop) > 4 {
+		return errors.New("stop takes at most 4 sequences")
+	}
+	for _, s := range mc.Stop {
+		if s == "" {
+			return errors.New("stop sequences can't be empty")
+		}
+	}
+	for token, bias := range mc.LogitBias {
+		if _, err := strconv.Atoi(token); err != nil {
+			return fmt.Errorf("logitBias keys must be token ids, not %q", token)
+		}
+		if bias < -100 || bias > 100 {
+			return fmt.Errorf("logitBias for token %s must be between -100 and 100", token)
+		}
+	}
+	return nil
+}
+
+// ResumeFor reports whether a failed response from model is resumed
+func (c Config) ResumeFor(model string) bool {
+	if r := c.ModelConfig(model).Resume; r != nil {
+		return *r
+	}
+	return strings.HasPrefix(model, "anthropic/")
+}
+
+// SendDelayFor returns how long prompts to model are held back before sending
+func (c Config) SendDelayFor(model string) time.Duration {
+	if d := c.ModelConfig(model).SendDelay; d != nil {
+		return d.Std()
+	}
+	return c.SendDelay.Std()
+}
+
+// ModelConfig returns the settings for model, zero if none match
+func (c Config) ModelConfig(model string) ModelConfig {
+	if mc, ok := c.Models[model]; ok {
+		return mc
+	}
+	best := ""
+	for pattern := range c.Models {
+		if ok, _ := path.Match(pattern, model); ok && len(pattern) > len(best) {
+			best = pattern
+		}
+	}
+	return c.Models[best]
+}
+
+// ProviderConfig is an OpenAI-compatible server whose models are offered as
+// "name:model" in the model picker
+type ProviderConfig struct {
+	Name    string `json:"name"`
+	BaseURL string `json:"baseURL"` // API root, e.g. http://localhost:1234/v1
+	// APIKey is used as is, APIKeyEnv names an environment variable holding
+	// it instead. both are optional, local servers usually don't need a key
+	APIKey    string `json:"apiKey,omitempty"`
+	APIKeyEnv string `json:"apiKeyEnv,omitempty"`
+	// Models are listed from the server when empty
+	Models []string `json:"models,omitempty"`
+}
+
+// Key returns the provider's API key, reading it from the environment if configured so
+func (p ProviderConfig) Key() string {
+	if p.APIKeyEnv != "" {
+		return os.Getenv(p.APIKeyEnv)
+	}
+	return p.APIKey
+}
+
+// ToolsConfig enables tool calling and sets what tools may do
+type ToolsConfig struct {
+	Enabled bool `json:"enabled"`
+	// MaxOutput is the most bytes of a single tool result added to the context
+	MaxOutput int `json:"maxOutput,omitempty"`
+	// Truncate is how longer results are shortened, "headtail" keeps the
+	// start and end, "summarize" asks the model for a summary
+	Truncate string `json:"truncate,omitempty"`
+	// Concurrency is how many calls from one response may run at once
+	Concurrency int `json:"concurrency,omitempty"`
+	// Policies are keyed by tool name, "*" applies to tools without their own
+	Policies map[string]ToolPolicy `json:"policies,omitempty"`
+}
+
+// ToolPolicy limits what a tool may do without asking. anything outside the
+// policy prompts for approval, except denied commands which are refused
+type ToolPolicy struct {
+	// Disabled hides the tool from the model entirely
+	Disabled bool `json:"disabled,omitempty"`
+	// Allow lists commands that run without prompting, "*" allows everything
+	// not denied
+	Allow []string `json:"allow,omitempty"`
+	// Deny lists commands that are always refused
+	Deny []string `json:"deny,omitempty"`
+	// ReadablePaths are directories the tool may read inside without prompting
+	ReadablePaths []string `json:"readablePaths,omitempty"`
+	// WritablePaths are directories the tool may write inside without prompting
+	WritablePaths []string `json:"writablePaths,omitempty"`
+	// Network allows network access without prompting
+	Network bool `json:"network,omitempty"`
+	// Domains are the hosts network tools may reach at all, "*.example.com"
+	// matches subdomains. requests anywhere else are refused
+	Domains []string `json:"domains,omitempty"`
+	// Timeout bounds a single invocation
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+// ToolPolicy returns the policy for the named tool. a tool's own policy
+// takes its timeout from "*" when it doesn't set one
+func (t ToolsConfig) ToolPolicy(name string) ToolPolicy {
+	def := t.Policies["*"]
+	p, ok := t.Policies[name]
+	if !ok {
+		return def
+	}
+	if p.Timeout == 0 {
+		p.Timeout = def.Timeout
+	}
+	return p
+}
+
+// Default returns the configuration used when no config file exists
+func Default() Config {
+	return Config{
+		RequestTimeout:   Duration(10 * time.Minute),
+		StallTimeout:     Duration(60 * time.Second),
+		StallWarning:     Duration(30 * time.Second),
+		Mouse:            true,
+		KeyboardProtocol: true,
+		SendKey:          "enter",
+		Math:             "unicode",
+		Autosave:         Duration(5 * time.Second),
+		Retry:            RetryConfig{Attempts: 2, Backoff: Duration(time.Second)},
+		CreditWarning:    1,
+		TrashRetention:   Duration(30 * 24 * time.Hour),
+		Log:              LogConfig{Level: "info", MaxSize: 5, MaxFiles: 3},
+		Downgrade:        DowngradeConfig{MaxLength: 200},
+		Personas: []Persona{
+			{Name: "code reviewer", Prompt: "You are a meticulous senior code reviewer. Point out bugs, edge cases, security issues and unclear code, most important first. Suggest concrete fixes and don't praise what is fine."},
+			{Name: "terse", Prompt: "Answer as briefly as possible. No preamble, no restating the question, no closing summary. Use code or a list instead of prose where it works."},
+			{Name: "explain like I'm five", Prompt: "Explain everything in simple words a five year old could follow, using short sentences and everyday comparisons. Avoid jargon, and if a technical term is unavoidable, explain it."},
+		},
+		Tools: ToolsConfig{
+			MaxOutput:   16 * 1024,
+			Truncate:    "headtail",
+			Concurrency: 4,
+			Policies: map[string]ToolPolicy{
+				"*": {Timeout: Duration(30 * time.Second)},
+			},
+		},
+	}
+}
+
+// DefaultPath returns the location of the config file, config.json in
+// paths.Config
+func DefaultPath() (string, error) {
+	dir, err := paths.Config()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// Load reads the config file at path on top of the defaults. a missing file
+// is not an error
+func Load(path string) (Config, error) {
+	return LoadFS(vfs.OS, path)
+}
+
+// LoadFS is Load reading from fsys
+func LoadFS(fsys vfs.FS, path string) (Config, error) {
+	cfg := Default()
+
+	data, err := fsys.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	for _, p := range cfg.Providers {
+		if p.Name == "" || p.BaseURL == "" {
+			return cfg, fmt.Errorf("config %s: providers need a name and a baseURL", path)
+		}
+		if strings.ContainsAny(p.Name, ":/") {
+			return cfg, fmt.Errorf("config %s: provider name %q can't contain : or /", path, p.Name)
+		}
+	}
+	for _, p := range cfg.Personas {
+		if p.Name == "" || p.Prompt == "" {
+			return cfg, fmt.Errorf("config %s: personas need a name and a prompt", path)
+		}
+	}
+	if cfg.Downgrade.Enabled && cfg.Downgrade.Model == "" {
+		return cfg, fmt.Errorf("config %s: downgrade needs a model", path)
+	}
+	switch cfg.Math {
+	case "unicode", "latex", "off":
+	default:
+		return cfg, fmt.Errorf("config %s: math must be unicode, latex or off", path)
+	}
+	switch cfg.SendKey {
+	case "enter", "ctrl+enter", "alt+enter":
+	default:
+		return cfg, fmt.Errorf("config %s: sendKey must be enter, ctrl+enter or alt+enter", path)
+	}
+	switch cfg.Log.Level {
+	case "off", "error", "info", "debug":
+	default:
+		return cfg, fmt.Errorf("config %s: log.level must be off, error, info or debug", path)
+	}
+	if cfg.AutoContinue < 0 {
+		return cfg, fmt.Errorf("config %s: autoContinue can't be negative", path)
+	}
+	if cfg.Retry.Attempts < 0 {
+		return cfg, fmt.Errorf("config %s: retry.attempts can't be negative", path)
+	}
+	if r := cfg.RateLimit; r.RequestsPerMinute < 0 || r.TokensPerMinute < 0 || r.Concurrent < 0 {
+		return cfg, fmt.Errorf("config %s: rateLimit limits can't be negative", path)
+	}
+	if cfg.Budget.Session < 0 || cfg.Budget.Daily < 0 {
+		return cfg, fmt.Errorf("config %s: budget can't be negative", path)
+	}
+	if _, err := cfg.Redactions(); err != nil {
+		return cfg, fmt.Errorf("config %s: %w", path, err)
+	}
+	for pattern, mc := range cfg.Models {
+		switch mc.ReasoningEffort {
+		case "", "low", "medium", "high":
+		default:
+			return cfg, fmt.Errorf("config %s: models.%s: reasoningEffort must be low, medium or high", path, pattern)
+		}
+		if err := mc.CheckParams(); err != nil {
+			return cfg, fmt.Errorf("config %s: models.%s: %w", path, pattern, err)
+		}
+	}
+	return cfg, nil
+}
+
+// Duration is a time.Duration that reads from JSON as either a Go duration
+// string ("90s", "5m") or a number of seconds
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = Duration(seconds * float64(time.Second))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\" or a number of seconds")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q: %w", s, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Std returns the value as a time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
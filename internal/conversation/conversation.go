@@ -0,0 +1,218 @@
+package conversation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/scbenet/ask/internal/attach"
+	"github.com/scbenet/ask/internal/llm"
+)
+
+// Message is a single turn of a conversation along with the metadata needed
+// for editing, exporting and persisting it
+type Message struct {
+	ID          string              `json:"id"`
+	Role        string              `json:"role"`
+	Content     string              `json:"content"`
+	Attachments []attach.Attachment `json:"attachments,omitempty"`
+	Model       string              `json:"model,omitempty"`  // model that produced (or was asked) this message
+	Effort      string              `json:"effort,omitempty"` // reasoning effort a response was raised to, see think harder
+	Timestamp   time.Time           `json:"timestamp"`
+	Usage       *llm.Usage          `json:"usage,omitempty"`
+	Sources     []llm.Source        `json:"sources,omitempty"`    // citations for web/RAG grounded answers
+	Error       string              `json:"error,omitempty"`      // set if the request for this turn failed
+	RequestID   string              `json:"requestId,omitempty"`  // the request that answered or failed, see llm.RequestID
+	ProviderID  string              `json:"providerId,omitempty"` // the id the provider gave the response
+	ToolCalls   []llm.ToolCall      `json:"toolCalls,omitempty"`  // functions an assistant message asked to call
+	ToolCallID  string              `json:"toolCallId,omitempty"` // the call a tool message answers
+	OutputHash  string              `json:"outputHash,omitempty"` // blob holding a tool's full output when Content was shortened
+	ExpiresIn   int                 `json:"expiresIn,omitempty"`  // ephemeral messages are sent with this many more prompts, see Expire
+	Expired     bool                `json:"expired,omitempty"`
+	// Truncated is why a response was cut off, llm.FinishLength or
+	// llm.FinishStopSequence, "" if the model finished it
+	Truncated string `json:"truncated,omitempty"`
+}
+
+// expiredContent replaces the content of ephemeral messages once they expire
+const expiredContent = "[ephemeral message removed]"
+
+// Failed reports whether the request for this message failed
+func (m Message) Failed() bool {
+	return m.Error != ""
+}
+
+// Conversation is an ordered list of messages
+type Conversation struct {
+	Messages []Message
+}
+
+// New wraps existing messages (e.g. from a saved session) in a Conversation,
+// filling in IDs and timestamps missing from older or imported data
+func New(messages []Message) *Conversation {
+	c := &Conversation{Messages: append([]Message{}, messages...)}
+	for i := range c.Messages {
+		if c.Messages[i].ID == "" {
+			c.Messages[i].ID = newID()
+		}
+	}
+	return c
+}
+
+func newID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Add appends a message, assigning it an ID and timestamp, and returns it
+func (c *Conversation) Add(msg Message) *Message {
+	if msg.ID == "" {
+		msg.ID = newID()
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	c.Messages = append(c.Messages, msg)
+	return &c.Messages[len(c.Messages)-1]
+}
+
+// Find returns the message with the given ID, or nil
+func (c *Conversation) Find(id string) *Message {
+	for i := range c.Messages {
+		if c.Messages[i].ID == id {
+			return &c.Messages[i]
+		}
+	}
+	return nil
+}
+
+// Last returns the most recent message with the given role, or nil
+func (c *Conversation) Last(role string) *Message {
+	for i := len(c.Messages) - 1; i >= 0; i-- {
+		if c.Messages[i].Role == role {
+			return &c.Messages[i]
+		}
+	}
+	return nil
+}
+
+// Len returns the number of messages
+func (c *Conversation) Len() int {
+	return len(c.Messages)
+}
+
+// LLMMessages converts the conversation into what gets sent to the model.
+// failed turns are left out and attachments are inlined into their message
+func (c *Conversation) LLMMessages() []llm.Message {
+	msgs := make([]llm.Message, 0, len(c.Messages))
+	for _, m := range c.Messages {
+		if m.Failed() {
+			continue
+		}
+		msgs = append(msgs, llm.Message{
+			Role:       m.Role,
+			Content:    attach.Wrap(m.Content, m.Attachments),
+			ToolCalls:  m.ToolCalls,
+			ToolCallID: m.ToolCallID,
+			// attached files are sent again with every prompt, caching
+			// them saves paying for them in full each time
+			Cache: len(m.Attachments) > 0,
+		})
+	}
+	return msgs
+}
+
+// Expire counts down ephemeral messages when a new prompt is sent. a message
+// that runs out is removed from the conversation along with the responses
+// to it, leaving a placeholder so the turns still line up. returns how many
+// messages expired
+func (c *Conversation) Expire() int {
+	expired := 0
+	expiring := false
+	for i := range c.Messages {
+		m := &c.Messages[i]
+		if m.Role == "user" {
+			expiring = false
+		}
+		if m.ExpiresIn > 0 {
+			m.ExpiresIn--
+			expiring = m.ExpiresIn == 0
+		}
+		if expiring && !m.Expired {
+			m.expire()
+			expired++
+		}
+	}
+	return expired
+}
+
+// expire drops everything of the message but its place in the conversation.
+// tool calls keep their IDs and names, their answers still refer to them
+func (m *Message) expire() {
+	m.Content = expiredContent
+	m.Attachments = nil
+	m.Sources = nil
+	m.OutputHash = ""
+	if len(m.ToolCalls) > 0 {
+		// a copy, the calls may be shared with a message that's kept
+		calls := make([]llm.ToolCall, len(m.ToolCalls))
+		for i, call := range m.ToolCalls {
+			call.Function.Arguments = "{}"
+			calls[i] = call
+		}
+		m.ToolCalls = calls
+	}
+	m.ExpiresIn = 0
+	m.Expired = true
+}
+
+// WithoutEphemeral returns a copy of messages with the ephemeral turns
+// already expired, for copies that outlive them like the trash and
+// recovery snapshots
+func WithoutEphemeral(messages []Message) []Message {
+	kept := make([]Message, len(messages))
+	ephemeral := false
+	for i, m := range messages {
+		if m.Role == "user" {
+			ephemeral = m.ExpiresIn > 0
+		}
+		if ephemeral && !m.Expired {
+			m.expire()
+		}
+		kept[i] = m
+	}
+	return kept
+}
+
+// Ephemeral reports whether the last turn is an ephemeral one that hasn't
+// expired yet
+func (c *Conversation) Ephemeral() bool {
+	last := c.Last("user")
+	return last != nil && last.ExpiresIn > 0
+}
+
+// TurnStart returns the index of the first message of the last n turns, a
+// turn being a user message and everything that follows it
+func (c *Conversation) TurnStart(n int) int {
+	for i := len(c.Messages) - 1; i >= 0; i-- {
+		if c.Messages[i].Role == "user" {
+			n--
+			if n == 0 {
+				return i
+			}
+		}
+	}
+	return 0
+}
+
+// Truncate removes the message at index and everything after it, returning
+// the removed messages
+func (c *Conversation) Truncate(index int) []Message {
+	if index < 0 || index >= len(c.Messages) {
+		return nil
+	}
+	removed := append([]Message{}, c.Messages[index:]...)
+	c.Messages = c.Messages[:index]
+	return removed
+}
@@ -0,0 +1,64 @@
+package conversation
+
+import (
+	"testing"
+
+	"github.com/scbenet/ask/internal/attach"
+	"github.com/scbenet/ask/internal/llm"
+)
+
+func TestExpire(t *testing.T) {
+	c := New([]Message{
+		{Role: "user", Content: "secret", ExpiresIn: 2, Attachments: []attach.Attachment{{Name: "key.txt", Content: "sk-1"}}},
+		{Role: "assistant", Content: "let me look", ToolCalls: []llm.ToolCall{{ID: "c1", Function: llm.FunctionCall{Name: "read_file", Arguments: `{"path":"key.txt"}`}}}},
+		{Role: "tool", Content: "sk-1", ToolCallID: "c1", OutputHash: "abc"},
+		{Role: "assistant", Content: "it's sk-1"},
+		{Role: "user", Content: "kept"},
+		{Role: "assistant", Content: "sure"},
+	})
+
+	if n := c.Expire(); n != 0 {
+		t.Fatalf("%d messages expired after one prompt", n)
+	}
+	if c.Messages[0].Content != "secret" || c.Messages[0].ExpiresIn != 1 {
+		t.Fatalf("the ephemeral message is %+v", c.Messages[0])
+	}
+	if n := c.Expire(); n != 4 {
+		t.Fatalf("%d messages expired, want the ephemeral turn's 4", n)
+	}
+	for _, m := range c.Messages[:4] {
+		if m.Content != expiredContent || !m.Expired || m.Attachments != nil || m.OutputHash != "" {
+			t.Errorf("expired message left %+v", m)
+		}
+	}
+	call := c.Messages[1].ToolCalls[0]
+	if call.ID != "c1" || call.Function.Name != "read_file" || call.Function.Arguments != "{}" {
+		t.Errorf("expired tool call is %+v", call)
+	}
+	if c.Messages[4].Content != "kept" || c.Messages[5].Content != "sure" {
+		t.Errorf("a later turn expired: %+v", c.Messages[4:])
+	}
+	if n := c.Expire(); n != 0 {
+		t.Errorf("%d messages expired again", n)
+	}
+}
+
+func TestWithoutEphemeral(t *testing.T) {
+	calls := []llm.ToolCall{{ID: "c1", Function: llm.FunctionCall{Name: "run", Arguments: `{"secret":1}`}}}
+	messages := []Message{
+		{Role: "user", Content: "kept"},
+		{Role: "assistant", Content: "ok"},
+		{Role: "user", Content: "secret", ExpiresIn: 3},
+		{Role: "assistant", Content: "noted", ToolCalls: calls},
+	}
+	kept := WithoutEphemeral(messages)
+	if kept[0].Content != "kept" || kept[1].Content != "ok" {
+		t.Errorf("a lasting turn was dropped: %+v", kept[:2])
+	}
+	if kept[2].Content != expiredContent || kept[3].Content != expiredContent || kept[3].ToolCalls[0].Function.Arguments != "{}" {
+		t.Errorf("the ephemeral turn is %+v", kept[2:])
+	}
+	if messages[2].Content != "secret" || calls[0].Function.Arguments != `{"secret":1}` {
+		t.Error("the original messages were changed")
+	}
+}
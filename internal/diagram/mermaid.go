@@ -0,0 +1,124 @@
+package diagram
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/scbenet/ask/internal/paths"
+)
+
+var mermaidBlockPattern = regexp.MustCompile("(?s)```mermaid[ \t]*\n(.*?)```")
+
+// ExtractMermaid returns the source of every ```mermaid block in md
+func ExtractMermaid(md string) []string {
+	var diagrams []string
+	for _, m := range mermaidBlockPattern.FindAllStringSubmatch(md, -1) {
+		if src := strings.TrimSpace(m[1]); src != "" {
+			diagrams = append(diagrams, src)
+		}
+	}
+	return diagrams
+}
+
+// ErrNoMermaidCLI is returned when mermaid-cli (mmdc) isn't installed
+var ErrNoMermaidCLI = errors.New("mermaid-cli (mmdc) not found in PATH, install it with `npm install -g @mermaid-js/mermaid-cli`")
+
+// RenderMermaid renders a diagram to a PNG using the local mermaid-cli and
+// returns the image path. images are kept in the cache directory, a diagram
+// that was rendered before isn't rendered again
+func RenderMermaid(ctx context.Context, src string) (string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(src))
+	name := hex.EncodeToString(sum[:8])
+	output := filepath.Join(dir, name+".png")
+	if _, err := os.Stat(output); err == nil {
+		return output, nil
+	}
+
+	mmdc, err := exec.LookPath("mmdc")
+	if err != nil {
+		return "", ErrNoMermaidCLI
+	}
+	input := filepath.Join(dir, name+".mmd")
+	if err := os.WriteFile(input, []byte(src), 0o600); err != nil {
+		return "", fmt.Errorf("failed to write diagram source: %w", err)
+	}
+	defer os.Remove(input)
+
+	cmd := exec.CommandContext(ctx, mmdc, "-i", input, "-o", output, "-b", "white")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("mmdc failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return output, nil
+}
+
+// the temp directory diagrams are rendered to without a cache directory,
+// one per process, see Cleanup
+var (
+	tempMu  sync.Mutex
+	tempDir string
+)
+
+// cacheDir is where rendered diagrams are kept, a temp directory if there's
+// no cache directory
+func cacheDir() (string, error) {
+	if cache, err := paths.Cache(); err == nil {
+		dir := filepath.Join(cache, "diagrams")
+		if err := os.MkdirAll(dir, 0o700); err == nil {
+			return dir, nil
+		}
+	}
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	if tempDir != "" {
+		return tempDir, nil
+	}
+	dir, err := os.MkdirTemp("", "ask-mermaid-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	tempDir = dir
+	return dir, nil
+}
+
+// Cleanup removes the temp directory diagrams were rendered to when there
+// was no cache directory. the images are shown after RenderMermaid returns,
+// so it's left until ask exits
+func Cleanup() error {
+	tempMu.Lock()
+	defer tempMu.Unlock()
+	if tempDir == "" {
+		return nil
+	}
+	err := os.RemoveAll(tempDir)
+	tempDir = ""
+	return err
+}
+
+// KittyGraphics reports whether the terminal supports the kitty graphics
+// protocol, in which case images can be shown inline with `kitty +kitten icat`
+func KittyGraphics() bool {
+	if os.Getenv("KITTY_WINDOW_ID") == "" && os.Getenv("TERM") != "xterm-kitty" {
+		return false
+	}
+	_, err := exec.LookPath("kitty")
+	return err == nil
+}
+
+// KittyShowCommand returns a command that displays an image inline and waits
+// for a key press, meant to be run while the TUI is suspended
+func KittyShowCommand(path string) *exec.Cmd {
+	return exec.Command("kitty", "+kitten", "icat", "--hold", path)
+}
@@ -0,0 +1,248 @@
+// Package edits finds the code blocks of a response and the file edits among
+// them, code blocks naming the file they belong in and unified diffs, and
+// works out what applying them makes of the files
+package edits
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Edit is a change to one file proposed in a response
+type Edit struct {
+	Path string
+	// Content replaces the file entirely, for code blocks
+	Content string
+	// Diff patches the file instead, for unified diffs
+	Diff *FileDiff
+}
+
+// Deletes reports whether the edit removes the file
+func (e Edit) Deletes() bool {
+	return e.Diff != nil && e.Diff.New == ""
+}
+
+// Confined returns an error unless path is inside the working directory:
+// relative, without .. leading out of it and without a symlink on the way
+// that does. an edit is only ever written there
+func Confined(path string) error {
+	if !filepath.IsLocal(path) {
+		return fmt.Errorf("%s is outside the working directory", path)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if wd, err = filepath.EvalSymlinks(wd); err != nil {
+		return err
+	}
+	// the part of the path that exists decides where it leads
+	dir := filepath.Join(wd, filepath.Dir(path))
+	for {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			rel, err := filepath.Rel(wd, resolved)
+			if err != nil || !filepath.IsLocal(rel) {
+				return fmt.Errorf("%s leads outside the working directory", path)
+			}
+			break
+		}
+		if dir == wd {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%s is a symlink", path)
+	}
+	return nil
+}
+
+// Result returns what the file becomes given what it is now, "" for a file
+// that doesn't exist yet
+func (e Edit) Result(current string) (string, error) {
+	if e.Diff != nil {
+		return e.Diff.Apply(current)
+	}
+	return e.Content, nil
+}
+
+// Extract returns the edits in md in the order they appear: fenced code
+// blocks whose info string or the line before them names a file, and the
+// files of unified diffs
+func Extract(md string) []Edit {
+	var edits []Edit
+	for _, b := range CodeBlocks(md) {
+		if isDiff(b.Lang, b.Code) {
+			diffs, err := ParseDiff(b.Code)
+			if err != nil {
+				continue
+			}
+			for _, d := range diffs {
+				edits = append(edits, Edit{Path: d.Path(), Diff: &d})
+			}
+			continue
+		}
+		path := pathFromInfo(b.Info)
+		if path == "" {
+			path = pathFromLine(b.before)
+		}
+		if path != "" {
+			edits = append(edits, Edit{Path: path, Content: b.Code})
+		}
+	}
+	return edits
+}
+
+// Block is a fenced code block
+type Block struct {
+	Lang string // the first word of the info string
+	Info string
+	// Code ends with a line break unless it's empty
+	Code string
+
+	before string // the line leading into the block
+}
+
+// CodeBlocks returns the fenced code blocks of md in the order they appear
+func CodeBlocks(md string) []Block {
+	var blocks []Block
+	lines := strings.Split(md, "\n")
+	for i := 0; i < len(lines); i++ {
+		fence, info, ok := openingFence(lines[i])
+		if !ok {
+			continue
+		}
+		start := i + 1
+		end := start
+		for end < len(lines) && !closesFence(lines[end], fence) {
+			end++
+		}
+		code := strings.Join(lines[start:end], "\n")
+		if code != "" {
+			code += "\n"
+		}
+		lang, _, _ := strings.Cut(info, " ")
+		blocks = append(blocks, Block{Lang: lang, Info: info, Code: code, before: previousLine(lines, i)})
+		i = end
+	}
+	return blocks
+}
+
+// openingFence returns the fence a line opens a code block with and the
+// info string after it
+func openingFence(line string) (fence, info string, ok bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return "", "", false
+	}
+	for _, ch := range []string{"`", "~"} {
+		n := len(trimmed) - len(strings.TrimLeft(trimmed, ch))
+		if n >= 3 {
+			info = strings.TrimSpace(trimmed[n:])
+			if ch == "`" && strings.Contains(info, "`") {
+				return "", "", false
+			}
+			return trimmed[:n], info, true
+		}
+	}
+	return "", "", false
+}
+
+func closesFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, fence) && strings.Trim(trimmed, fence[:1]) == ""
+}
+
+// previousLine returns the last non-blank line before index i, if it's
+// close enough to be about the code block
+func previousLine(lines []string, i int) string {
+	for j := i - 1; j >= 0 && j >= i-2; j-- {
+		if strings.TrimSpace(lines[j]) != "" {
+			return lines[j]
+		}
+	}
+	return ""
+}
+
+func isDiff(lang, body string) bool {
+	if lang == "diff" || lang == "patch" {
+		return true
+	}
+	return strings.HasPrefix(body, "--- ") || strings.HasPrefix(body, "diff --git ")
+}
+
+// pathFromInfo finds a file in an info string, as in "go cmd/main.go",
+// "main.go", "python title=app.py" or "path=app.py"
+func pathFromInfo(info string) string {
+	fields := strings.Fields(info)
+	for _, f := range fields {
+		for _, key := range []string{"path=", "file=", "filename=", "title="} {
+			if v, ok := strings.CutPrefix(f, key); ok {
+				return cleanPath(strings.Trim(v, `"'`))
+			}
+		}
+	}
+	switch len(fields) {
+	case 1:
+		return cleanPath(fields[0])
+	case 2:
+		return cleanPath(fields[1])
+	}
+	return ""
+}
+
+var backticked = regexp.MustCompile("`([^`]+)`")
+
+// pathFromLine finds a file in the line before a code block, as in
+// "`main.go`", "**cmd/main.go**", "### app.py", "File: app.py" or "Here's
+// the new `main.go`:"
+func pathFromLine(line string) string {
+	line = strings.TrimSpace(line)
+	bare := strings.Trim(line, "#*_` ")
+	for _, label := range []string{"File:", "file:", "Filename:", "filename:", "Path:", "path:"} {
+		if rest, ok := strings.CutPrefix(bare, label); ok {
+			bare = rest
+			break
+		}
+	}
+	bare = strings.Trim(bare, "*_`: ")
+	if path := cleanPath(bare); path != "" {
+		return path
+	}
+	// a sentence leading into the block, naming one file
+	if !strings.HasSuffix(line, ":") {
+		return ""
+	}
+	var found string
+	for _, m := range backticked.FindAllStringSubmatch(line, -1) {
+		if path := cleanPath(m[1]); path != "" {
+			if found != "" {
+				return ""
+			}
+			found = path
+		}
+	}
+	return found
+}
+
+// cleanPath returns s if it looks like the path of a file, "" otherwise:
+// one word with an extension or a directory in it, no URL, and inside the
+// working directory
+func cleanPath(s string) string {
+	if s == "" || len(s) > 255 || strings.ContainsAny(s, " \t`*<>|\"'") || strings.Contains(s, "://") {
+		return ""
+	}
+	if !filepath.IsLocal(filepath.FromSlash(s)) {
+		return ""
+	}
+	if strings.HasSuffix(s, "/") || strings.Trim(s, ".") == "" {
+		return ""
+	}
+	if !strings.Contains(s, "/") && filepath.Ext(s) == "" && !strings.HasPrefix(s, ".") {
+		return ""
+	}
+	return s
+}
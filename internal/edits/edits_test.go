@@ -0,0 +1,100 @@
+package edits
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	md := strings.Join([]string{
+		"Put this in place:",
+		"",
+		"```go cmd/main.go",
+		"package main",
+		"```",
+		"",
+		"**util/strings.go**",
+		"```go",
+		"package util",
+		"```",
+		"",
+		"Here's the new `config.yaml`:",
+		"",
+		"```yaml",
+		"debug: true",
+		"```",
+		"",
+		"```python title=\"app.py\"",
+		"print('hi')",
+		"```",
+		"",
+		"Just an example, no file:",
+		"```go",
+		"x := 1",
+		"```",
+		"",
+		"```diff",
+		"--- a/README.md",
+		"+++ b/README.md",
+		"@@ -1,2 +1,2 @@",
+		" # title",
+		"-old",
+		"+new",
+		"```",
+	}, "\n")
+
+	var got []string
+	for _, e := range Extract(md) {
+		kind := "content"
+		if e.Diff != nil {
+			kind = "diff"
+		}
+		got = append(got, e.Path+" "+kind)
+	}
+	want := "cmd/main.go content, util/strings.go content, config.yaml content, app.py content, README.md diff"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("Extract found %q, want %q", strings.Join(got, ", "), want)
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := map[string]string{
+		"main.go":                  "main.go",
+		"cmd/ask":                  "cmd/ask",
+		".gitignore":               ".gitignore",
+		"go":                       "",
+		"https://example.com/a.go": "",
+		"two words.go":             "",
+		"...":                      "",
+		"../main.go":               "",
+		"/etc/hosts.conf":          "",
+	}
+	for in, want := range tests {
+		if got := cleanPath(in); got != want {
+			t.Errorf("cleanPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestConfined(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.Symlink(t.TempDir(), "out"); err != nil {
+		t.Skip("can't create symlinks:", err)
+	}
+	for path, ok := range map[string]bool{
+		"main.go":         true,
+		"new/dir/main.go": true,
+		"../main.go":      false,
+		"a/../../main.go": false,
+		"/etc/hosts":      false,
+		"out/main.go":     false,
+		"out/new/main.go": false,
+		"out":             false,
+	} {
+		if err := Confined(path); (err == nil) != ok {
+			t.Errorf("Confined(%q) = %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package edits
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FileDiff is the part of a unified diff changing one file
+type FileDiff struct {
+	// Old and New are the paths in the --- and +++ lines without their a/
+	// and b/ prefixes, "" for /dev/null
+	Old, New string
+	Hunks    []Hunk
+}
+
+// Hunk is a run of changes, Lines keep their " ", "-" or "+" prefix
+type Hunk struct {
+	// OldStart is the line the hunk starts at in the old file, 0 when the
+	// header leaves it out and the context has to be found
+	OldStart int
+	Lines    []string
+}
+
+// Path is the file the diff changes
+func (d FileDiff) Path() string {
+	if d.New != "" {
+		return d.New
+	}
+	return d.Old
+}
+
+// ErrNoDiff is returned for text without any --- and +++ lines
+var ErrNoDiff = errors.New("no unified diff found")
+
+// ParseDiff parses the unified diffs of one or more files. hunk headers
+// only need the @@, the line numbers and counts models write are often
+// off, so the hunks run until the next header
+func ParseDiff(s string) ([]FileDiff, error) {
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	var diffs []FileDiff
+	var diff *FileDiff
+	var hunk *Hunk
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			diffs = append(diffs, FileDiff{Old: diffPath(line[4:], "a/"), New: diffPath(lines[i+1][4:], "b/")})
+			diff = &diffs[len(diffs)-1]
+			hunk = nil
+			i++
+		case diff == nil:
+			// diff --git, index and mode lines, or text before the diff
+		case strings.HasPrefix(line, "@@"):
+			diff.Hunks = append(diff.Hunks, Hunk{OldStart: hunkStart(line)})
+			hunk = &diff.Hunks[len(diff.Hunks)-1]
+		case hunk == nil:
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file"
+		case line == "":
+			// editors and models drop the space of empty context lines
+			hunk.Lines = append(hunk.Lines, " ")
+		case line[0] == ' ' || line[0] == '-' || line[0] == '+':
+			hunk.Lines = append(hunk.Lines, line)
+		case strings.HasPrefix(line, "diff "):
+			hunk = nil
+		default:
+			return nil, fmt.Errorf("unexpected line in hunk: %q", line)
+		}
+	}
+	if len(diffs) == 0 {
+		return nil, ErrNoDiff
+	}
+	for i := range diffs {
+		for j := range diffs[i].Hunks {
+			// blank lines after a hunk are more likely the end of the
+			// block than context
+			h := &diffs[i].Hunks[j]
+			for len(h.Lines) > 0 && h.Lines[len(h.Lines)-1] == " " {
+				h.Lines = h.Lines[:len(h.Lines)-1]
+			}
+		}
+	}
+	return diffs, nil
+}
+
+// diffPath strips the timestamp some tools add after a tab and the a/ or
+// b/ prefix git adds
+func diffPath(s, prefix string) string {
+	s, _, _ = strings.Cut(s, "\t")
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(s, prefix)
+}
+
+// hunkStart parses the old start line of "@@ -12,5 +12,6 @@"
+func hunkStart(header string) int {
+	fields := strings.Fields(header)
+	if len(fields) < 2 || !strings.HasPrefix(fields[1], "-") {
+		return 0
+	}
+	start, _, _ := strings.Cut(fields[1][1:], ",")
+	n, err := strconv.Atoi(start)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// Apply patches original with the hunks, finding each one's context near
+// where its header says it is or anywhere after the previous hunk. it
+// fails if a hunk's context isn't in the file
+func (d FileDiff) Apply(original string) (string, error) {
+	if d.Old == "" {
+		original = ""
+	}
+	lines := splitLines(original)
+	var out []string
+	pos := 0
+	for i, h := range d.Hunks {
+		var old, new []string
+		for _, l := range h.Lines {
+			if l[0] != '+' {
+				old = append(old, l[1:])
+			}
+			if l[0] != '-' {
+				new = append(new, l[1:])
+			}
+		}
+		at := find(lines, old, pos, h.OldStart-1)
+		if at < 0 {
+			return "", fmt.Errorf("hunk %d doesn't apply to %s, its context isn't in the file", i+1, d.Path())
+		}
+		out = append(out, lines[pos:at]...)
+		out = append(out, new...)
+		pos = at + len(old)
+	}
+	out = append(out, lines[pos:]...)
+	if d.New == "" || len(out) == 0 {
+		return "", nil
+	}
+	return strings.Join(out, "\n") + "\n", nil
+}
+
+// splitLines splits s into lines without their line breaks
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// find returns where block is in lines at or after from, the closest match
+// to hint winning. a hunk with nothing to match goes at hint. trailing
+// whitespace is ignored if nothing matches exactly
+func find(lines, block []string, from, hint int) int {
+	hint = max(hint, from)
+	if len(block) == 0 {
+		return min(hint, len(lines))
+	}
+	for _, equal := range []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return strings.TrimRight(a, " \t\r") == strings.TrimRight(b, " \t\r") },
+	} {
+		matches := func(at int) bool {
+			if at < from || at+len(block) > len(lines) {
+				return false
+			}
+			for i, l := range block {
+				if !equal(lines[at+i], l) {
+					return false
+				}
+			}
+			return true
+		}
+		for offset := 0; hint-offset >= from || hint+offset < len(lines); offset++ {
+			if matches(hint + offset) {
+				return hint + offset
+			}
+			if offset > 0 && matches(hint-offset) {
+				return hint - offset
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,98 @@
+package edits
+
+import (
+	"strings"
+	"testing"
+)
+
+const original = `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println("hello")
+}
+
+func other() {
+	fmt.Println("other")
+}
+`
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want string
+		err  string
+	}{
+		{
+			name: "line numbers",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -5,3 +5,3 @@\n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hi\")\n }\n",
+			want: strings.Replace(original, `"hello"`, `"hi"`, 1),
+		},
+		{
+			name: "line numbers off",
+			diff: "--- main.go\n+++ main.go\n@@ -1,3 +1,4 @@\n func other() {\n \tfmt.Println(\"other\")\n+\treturn\n }\n",
+			want: strings.Replace(original, "\"other\")\n", "\"other\")\n\treturn\n", 1),
+		},
+		{
+			name: "no line numbers, empty context without its space",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ ... @@\n import \"fmt\"\n\n-func main() {\n+func main() { // entry\n",
+			want: strings.Replace(original, "func main() {", "func main() { // entry", 1),
+		},
+		{
+			name: "two hunks",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package main\n+package app\n@@ -9 +9 @@\n-func other() {\n+func Other() {\n",
+			want: strings.Replace(strings.Replace(original, "package main", "package app", 1), "func other", "func Other", 1),
+		},
+		{
+			name: "context not in the file",
+			diff: "--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-package lib\n+package app\n",
+			err:  "hunk 1 doesn't apply",
+		},
+		{
+			name: "new file",
+			diff: "--- /dev/null\n+++ b/new.go\n@@ -0,0 +1,2 @@\n+package new\n+\n",
+			want: "package new\n\n",
+		},
+		{
+			name: "deleted file",
+			diff: "--- a/main.go\n+++ /dev/null\n@@ -1,11 +0,0 @@\n-package main\n",
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diffs, err := ParseDiff(tt.diff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := diffs[0].Apply(original)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Apply err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Apply =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDiffFiles(t *testing.T) {
+	diffs, err := ParseDiff("diff --git a/a.go b/a.go\nindex 1..2 100644\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-a\n+b\ndiff --git a/b.go b/b.go\n--- a/b.go\n+++ b/b.go\n@@ -1 +1 @@\n-c\n+d\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 || diffs[0].Path() != "a.go" || diffs[1].Path() != "b.go" || len(diffs[1].Hunks[0].Lines) != 2 {
+		t.Fatalf("parsed %+v", diffs)
+	}
+	if _, err := ParseDiff("not a diff"); err != ErrNoDiff {
+		t.Errorf("ParseDiff of text = %v, want ErrNoDiff", err)
+	}
+}
@@ -0,0 +1,68 @@
+// Package files lists the files under a directory the way git would see
+// them, for completing paths after @ and attaching whole directories
+package files
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// skipDirs are directories full of files nobody means to attach
+var skipDirs = map[string]bool{
+	"node_modules": true,
+	"__pycache__":  true,
+}
+
+// errLimit stops the walk once enough files were found
+var errLimit = errors.New("too many files")
+
+// List returns the regular files under root, relative to it with slashes
+// and in walk order, at most limit of them. hidden files and directories
+// are left out, and so are dependency directories like node_modules and
+// whatever the IgnoreFiles in root, below it or above it up to the top of
+// its git repository ignore
+func List(root string, limit int) ([]string, error) {
+	var ig ignore
+	ig.loadAbove(root)
+	ig.load(root, "")
+	var paths []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// unreadable directories are skipped, not fatal
+			if d != nil && d.IsDir() && p != root {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if p == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		name := d.Name()
+		if d.IsDir() {
+			if strings.HasPrefix(name, ".") || skipDirs[name] || ig.ignored(rel, true) {
+				return filepath.SkipDir
+			}
+			ig.load(p, rel)
+			return nil
+		}
+		if strings.HasPrefix(name, ".") || !d.Type().IsRegular() || ig.ignored(rel, false) {
+			return nil
+		}
+		if len(paths) == limit {
+			return errLimit
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimit) {
+		return nil, err
+	}
+	return paths, nil
+}
@@ -0,0 +1,83 @@
+package files
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestList(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"main.go", "cmd/ask/main.go", ".git/config", "node_modules/x/index.js", "docs/.draft.md", "docs/guide.md", "app.log", "testdata/big.json"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.log\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".askignore"), []byte("testdata/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := List(root, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(got)
+	want := []string{"cmd/ask/main.go", "docs/guide.md", "main.go"}
+	if !slices.Equal(got, want) {
+		t.Errorf("List = %q, want %q", got, want)
+	}
+
+	if got, err := List(root, 2); err != nil || len(got) != 2 {
+		t.Errorf("List with a limit of 2 = %q, %v", got, err)
+	}
+}
+
+// TestListIgnoreAbove applies the ignore files above the listed directory
+// up to the top of its git repository
+func TestListIgnoreAbove(t *testing.T) {
+	for _, repo := range []bool{true, false} {
+		root := t.TempDir()
+		files := []string{"sub/main.go", "sub/app.log", "sub/debug.log", "sub/gen/out.go", "sub/.gitignore"}
+		if repo {
+			files = append(files, ".git/HEAD")
+		}
+		for _, name := range files {
+			path := filepath.Join(root, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.log\n/sub/gen/\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// the directory's own rules still win
+		if err := os.WriteFile(filepath.Join(root, "sub", ".gitignore"), []byte("!app.log\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := List(filepath.Join(root, "sub"), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(got)
+		want := []string{"app.log", "main.go"}
+		if !repo {
+			// outside a repository what's above doesn't apply
+			want = []string{"app.log", "debug.log", "gen/out.go", "main.go"}
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("in a repository %v: List = %q, want %q", repo, got, want)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package files
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFiles are read in every directory walked, .askignore is for what
+// git tracks but shouldn't be sent to a model, like fixtures or lock files
+var IgnoreFiles = []string{".gitignore", ".askignore"}
+
+// ignoreRule is a line of an ignore file
+type ignoreRule struct {
+	base    string // directory of the ignore file, relative to the root
+	above   string // the root relative to the ignore file, for files above it
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ignore holds the rules of the ignore files read so far, like git the
+// last rule matching a path decides
+type ignore struct {
+	rules []ignoreRule
+}
+
+// load reads the ignore files of dir, rel is dir relative to the root
+func (ig *ignore) load(dir, rel string) {
+	for _, name := range IgnoreFiles {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			ig.add(rel, scanner.Text())
+		}
+		f.Close()
+	}
+}
+
+// loadAbove reads the ignore files of the directories above root up to the
+// top of its git repository, git applies them to root as well. outside a
+// repository only root's own apply
+func (ig *ignore) loadAbove(root string) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return
+	}
+	var dirs []string // innermost first
+	for dir := abs; !repoTop(dir); {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return
+		}
+		dirs = append(dirs, parent)
+		dir = parent
+	}
+	// outer rules come first so the inner ones win, like in git
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(dirs[i], abs)
+		if err != nil {
+			continue
+		}
+		n := len(ig.rules)
+		ig.load(dirs[i], "")
+		for j := n; j < len(ig.rules); j++ {
+			ig.rules[j].above = filepath.ToSlash(rel)
+		}
+	}
+}
+
+// repoTop reports whether dir is the top of a git repository, .git is a
+// file in worktrees and submodules
+func repoTop(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// add parses line of an ignore file in base, blank lines and comments are
+// skipped
+func (ig *ignore) add(base, line string) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	// a slash anywhere but the end ties the pattern to base, without one
+	// it matches at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return
+	}
+	expr := globRegexp(line)
+	if !anchored {
+		expr = "(.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return
+	}
+	rule.re = re
+	ig.rules = append(ig.rules, rule)
+}
+
+// ignored reports whether the slash separated path relative to the root is
+// ignored
+func (ig *ignore) ignored(rel string, dir bool) bool {
+	ignored := false
+	for _, r := range ig.rules {
+		if r.dirOnly && !dir {
+			continue
+		}
+		p := rel
+		if r.above != "" {
+			p = r.above + "/" + rel
+		}
+		if r.base != "" {
+			var ok bool
+			if p, ok = strings.CutPrefix(rel, r.base+"/"); !ok {
+				continue
+			}
+		}
+		if r.re.MatchString(p) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// globRegexp translates a gitignore glob to a regular expression: * and ?
+// stay within a path segment, ** crosses them
+func globRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch ch := glob[i]; ch {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**") {
+				switch {
+				case strings.HasPrefix(glob[i:], "**/"):
+					b.WriteString("(.*/)?")
+					i += 2
+				default:
+					b.WriteString(".*")
+					i++
+				}
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	return b.String()
+}
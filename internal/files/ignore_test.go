@@ -0,0 +1,52 @@
+package files
+
+import "testing"
+
+func TestIgnore(t *testing.T) {
+	var ig ignore
+	for _, line := range []string{
+		"# build output",
+		"*.log",
+		"!keep.log",
+		"/bin",
+		"build/",
+		"docs/**/*.pdf",
+		"**/testdata",
+		"secret?.txt",
+		"[ab].tmp",
+	} {
+		ig.add("", line)
+	}
+	ig.add("web", "dist")
+
+	tests := []struct {
+		path string
+		dir  bool
+		want bool
+	}{
+		{"app.log", false, true},
+		{"logs/app.log", false, true},
+		{"keep.log", false, false},
+		{"bin", true, true},
+		{"cmd/bin", true, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"src/build", true, true},
+		{"docs/a/b/c.pdf", false, true},
+		{"docs/c.pdf", false, true},
+		{"other/c.pdf", false, false},
+		{"pkg/testdata", true, true},
+		{"secret1.txt", false, true},
+		{"secret10.txt", false, false},
+		{"a.tmp", false, true},
+		{"c.tmp", false, false},
+		{"web/dist", true, true},
+		{"dist", true, false},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := ig.ignored(tt.path, tt.dir); got != tt.want {
+			t.Errorf("ignored(%q, dir %v) = %v, want %v", tt.path, tt.dir, got, tt.want)
+		}
+	}
+}
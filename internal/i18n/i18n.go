@@ -0,0 +1,122 @@
+// Package i18n translates the text of the interface. strings are looked up
+// by their English text, so the code reads as it did and anything without a
+// translation is shown in English
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+)
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// the catalog of the language in use, nil for English
+var catalog atomic.Pointer[map[string]string]
+
+// Languages lists the languages there are translations for, English first
+func Languages() []string {
+	langs := []string{"en"}
+	entries, _ := fs.ReadDir(locales, "locales")
+	for _, e := range entries {
+		langs = append(langs, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(langs[1:])
+	return langs
+}
+
+// Detect returns the language to use: the configured one, or else the one
+// of the locale in LC_ALL, LC_MESSAGES or LANG, English if none is set
+func Detect(configured string) string {
+	if configured != "" {
+		return configured
+	}
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			return language(v)
+		}
+	}
+	return "en"
+}
+
+// language turns a locale like de_DE.UTF-8 into its language, de
+func language(locale string) string {
+	lang, _, _ := strings.Cut(locale, ".")
+	lang, _, _ = strings.Cut(lang, "@")
+	lang, _, _ = strings.Cut(lang, "_")
+	lang = strings.ToLower(lang)
+	if lang == "c" || lang == "posix" {
+		return "en"
+	}
+	return lang
+}
+
+// SetLanguage switches the interface to lang, e.g. "de". it fails for a
+// language without a translation, which leaves the current one in use
+func SetLanguage(lang string) error {
+	if lang == "en" {
+		catalog.Store(nil)
+		return nil
+	}
+	data, err := locales.ReadFile(path.Join("locales", lang+".json"))
+	if err != nil {
+		return fmt.Errorf("no translation for language %q, there are %s", lang, strings.Join(Languages(), ", "))
+	}
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return fmt.Errorf("translation for %q is broken: %w", lang, err)
+	}
+	catalog.Store(&messages)
+	return nil
+}
+
+// T translates msg, returning it as it is without a translation
+func T(msg string) string {
+	if c := catalog.Load(); c != nil {
+		if t, ok := (*c)[msg]; ok && t != "" {
+			return t
+		}
+	}
+	return msg
+}
+
+// Tf translates format and formats it with args, the translation keeps the
+// verbs in the same order
+func Tf(format string, args ...any) string {
+	return fmt.Sprintf(T(format), args...)
+}
+
+// Keys returns copies of bindings with their help translated, for keys made
+// before the language was set, e.g. in package variables
+func Keys(bindings ...key.Binding) []key.Binding {
+	translated := make([]key.Binding, len(bindings))
+	for i, b := range bindings {
+		b.SetHelp(b.Help().Key, T(b.Help().Desc))
+		translated[i] = b
+	}
+	return translated
+}
+
+// List translates what a bubbles list shows of its own: the help of its
+// keys and the filter prompt
+func List(l *list.Model) {
+	k := &l.KeyMap
+	for _, b := range []*key.Binding{
+		&k.CursorUp, &k.CursorDown, &k.NextPage, &k.PrevPage, &k.GoToStart, &k.GoToEnd,
+		&k.Filter, &k.ClearFilter, &k.CancelWhileFiltering, &k.AcceptWhileFiltering,
+		&k.ShowFullHelp, &k.CloseFullHelp, &k.Quit, &k.ForceQuit,
+	} {
+		b.SetHelp(b.Help().Key, T(b.Help().Desc))
+	}
+	l.FilterInput.Prompt = T(l.FilterInput.Prompt)
+}
@@ -0,0 +1,90 @@
+package i18n
+
+import (
+	"encoding/json"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestLanguage(t *testing.T) {
+	tests := map[string]string{
+		"de_DE.UTF-8":      "de",
+		"de":               "de",
+		"fr_FR@euro":       "fr",
+		"EN_us":            "en",
+		"C":                "en",
+		"POSIX":            "en",
+		"sr_RS.UTF-8@latn": "sr",
+	}
+	for locale, want := range tests {
+		if got := language(locale); got != want {
+			t.Errorf("language(%q) = %q, want %q", locale, got, want)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "de_AT.UTF-8")
+	if got := Detect(""); got != "de" {
+		t.Errorf("Detect from LANG = %q, want de", got)
+	}
+	if got := Detect("en"); got != "en" {
+		t.Errorf("Detect with a configured language = %q, want en", got)
+	}
+	t.Setenv("LANG", "")
+	if got := Detect(""); got != "en" {
+		t.Errorf("Detect without a locale = %q, want en", got)
+	}
+}
+
+func TestSetLanguage(t *testing.T) {
+	t.Cleanup(func() { SetLanguage("en") })
+	if err := SetLanguage("de"); err != nil {
+		t.Fatal(err)
+	}
+	if got := T("send message"); got != "Nachricht senden" {
+		t.Errorf(`T("send message") = %q`, got)
+	}
+	if got := Tf("replay %d/%d", 2, 5); got != "Wiedergabe 2/5" {
+		t.Errorf("Tf = %q", got)
+	}
+	if got := T("not translated"); got != "not translated" {
+		t.Errorf("T without a translation = %q", got)
+	}
+
+	if err := SetLanguage("xx"); err == nil {
+		t.Error("SetLanguage(xx) succeeded")
+	}
+	if got := T("send message"); got != "Nachricht senden" {
+		t.Errorf("a failed SetLanguage changed the language, T = %q", got)
+	}
+	if err := SetLanguage("en"); err != nil {
+		t.Fatal(err)
+	}
+	if got := T("send message"); got != "send message" {
+		t.Errorf("T in English = %q", got)
+	}
+}
+
+// the translations must take the same arguments as the English text
+func TestCatalogVerbs(t *testing.T) {
+	verb := regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+	for _, lang := range Languages()[1:] {
+		data, err := locales.ReadFile("locales/" + lang + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			t.Fatalf("%s: %v", lang, err)
+		}
+		for msg, translation := range messages {
+			if want, got := verb.FindAllString(msg, -1), verb.FindAllString(translation, -1); !slices.Equal(got, want) {
+				t.Errorf("%s: %q has verbs %q, want %q", lang, translation, got, want)
+			}
+		}
+	}
+}
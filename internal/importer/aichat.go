@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/session"
+	"gopkg.in/yaml.v3"
+)
+
+// aichat saves each session as a YAML file with a flat message list
+type aichatSession struct {
+	Model    string `yaml:"model"`
+	Messages []struct {
+		Role    string `yaml:"role"`
+		Content any    `yaml:"content"`
+	} `yaml:"messages"`
+}
+
+func parseAichat(data []byte) ([]*session.Session, error) {
+	var s aichatSession
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	sess := session.New("")
+	for _, m := range s.Messages {
+		if m.Role != "user" && m.Role != "assistant" {
+			continue
+		}
+		content, err := aichatContent(m.Content)
+		if err != nil {
+			return nil, err
+		}
+		if content != "" {
+			sess.Messages = append(sess.Messages, conversation.Message{Role: m.Role, Content: content})
+		}
+	}
+	return []*session.Session{sess}, nil
+}
+
+// content is usually a plain string but multimodal messages use a list of
+// {type, text} parts like the OpenAI API
+func aichatContent(content any) (string, error) {
+	switch c := content.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return c, nil
+	case []any:
+		var text string
+		for _, part := range c {
+			p, ok := part.(map[string]any)
+			if !ok || p["type"] != "text" {
+				continue
+			}
+			if t, ok := p["text"].(string); ok {
+				if text != "" {
+					text += "\n\n"
+				}
+				text += t
+			}
+		}
+		return text, nil
+	default:
+		return "", fmt.Errorf("unexpected message content type %T", content)
+	}
+}
+
+// shell_gpt (sgpt) caches chats as a JSON array of OpenAI style messages
+func parseSgpt(data []byte) ([]*session.Session, error) {
+	var msgs []conversation.Message
+	if err := json.Unmarshal(data, &msgs); err != nil {
+		return nil, err
+	}
+
+	sess := session.New("")
+	for _, m := range msgs {
+		if (m.Role == "user" || m.Role == "assistant") && m.Content != "" {
+			sess.Messages = append(sess.Messages, m)
+		}
+	}
+	return []*session.Session{sess}, nil
+}
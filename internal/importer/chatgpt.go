@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/session"
+)
+
+// conversations.json from a ChatGPT data export. messages form a tree keyed by
+// node ID, current_node is the leaf of the branch the user last looked at
+type chatGPTConversation struct {
+	Title       string                 `json:"title"`
+	CreateTime  float64                `json:"create_time"`
+	UpdateTime  float64                `json:"update_time"`
+	Mapping     map[string]chatGPTNode `json:"mapping"`
+	CurrentNode string                 `json:"current_node"`
+}
+
+type chatGPTNode struct {
+	Parent  string          `json:"parent"`
+	Message *chatGPTMessage `json:"message"`
+}
+
+type chatGPTMessage struct {
+	CreateTime float64 `json:"create_time"`
+	Author     struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	Content struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+	} `json:"content"`
+}
+
+func parseChatGPT(data []byte) ([]*session.Session, error) {
+	var convs []chatGPTConversation
+	if err := json.Unmarshal(data, &convs); err != nil {
+		return nil, err
+	}
+
+	var sessions []*session.Session
+	for _, c := range convs {
+		sess := session.New(c.Title)
+		sess.Created = unixFloat(c.CreateTime)
+		sess.Updated = unixFloat(c.UpdateTime)
+
+		// walk from the current leaf back to the root, then reverse
+		var path []*chatGPTMessage
+		seen := map[string]bool{}
+		for id := c.CurrentNode; id != "" && !seen[id]; id = c.Mapping[id].Parent {
+			seen[id] = true
+			if msg := c.Mapping[id].Message; msg != nil {
+				path = append(path, msg)
+			}
+		}
+
+		for i := len(path) - 1; i >= 0; i-- {
+			msg := path[i]
+			role := msg.Author.Role
+			if role != "user" && role != "assistant" {
+				continue // skip system and tool messages
+			}
+			content := chatGPTText(msg)
+			if content == "" {
+				continue
+			}
+			sess.Messages = append(sess.Messages, conversation.Message{
+				Role:      role,
+				Content:   content,
+				Timestamp: unixFloat(msg.CreateTime),
+			})
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, nil
+}
+
+// chatGPTText joins the string parts of a message, non-text parts (images etc.) are dropped
+func chatGPTText(msg *chatGPTMessage) string {
+	var parts []string
+	for _, raw := range msg.Content.Parts {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil && strings.TrimSpace(s) != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func unixFloat(ts float64) time.Time {
+	if ts == 0 {
+		return time.Now()
+	}
+	sec := int64(ts)
+	return time.Unix(sec, int64((ts-float64(sec))*1e9))
+}
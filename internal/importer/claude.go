@@ -0,0 +1,75 @@
+package importer
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/session"
+)
+
+// conversations.json from a claude.ai data export
+type claudeConversation struct {
+	Name         string          `json:"name"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	ChatMessages []claudeMessage `json:"chat_messages"`
+}
+
+type claudeMessage struct {
+	Sender    string    `json:"sender"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+	Content   []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+func parseClaude(data []byte) ([]*session.Session, error) {
+	var convs []claudeConversation
+	if err := json.Unmarshal(data, &convs); err != nil {
+		return nil, err
+	}
+
+	var sessions []*session.Session
+	for _, c := range convs {
+		sess := session.New(c.Name)
+		if !c.CreatedAt.IsZero() {
+			sess.Created = c.CreatedAt
+		}
+		if !c.UpdatedAt.IsZero() {
+			sess.Updated = c.UpdatedAt
+		}
+
+		for _, m := range c.ChatMessages {
+			role := "user"
+			if m.Sender == "assistant" {
+				role = "assistant"
+			}
+
+			// newer exports split the text into typed content blocks
+			text := m.Text
+			if text == "" {
+				var parts []string
+				for _, block := range m.Content {
+					if block.Type == "text" && block.Text != "" {
+						parts = append(parts, block.Text)
+					}
+				}
+				text = strings.Join(parts, "\n\n")
+			}
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			sess.Messages = append(sess.Messages, conversation.Message{
+				Role:      role,
+				Content:   text,
+				Timestamp: m.CreatedAt,
+			})
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, nil
+}
@@ -0,0 +1,129 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/scbenet/ask/internal/session"
+)
+
+// Format identifies which tool an export came from
+type Format string
+
+const (
+	FormatChatGPT Format = "chatgpt"
+	FormatClaude  Format = "claude"
+	FormatAichat  Format = "aichat"
+	FormatSgpt    Format = "sgpt"
+)
+
+// Formats lists the supported import formats
+var Formats = []Format{FormatChatGPT, FormatClaude, FormatAichat, FormatSgpt}
+
+type parseFunc func(data []byte) ([]*session.Session, error)
+
+var parsers = map[Format]parseFunc{
+	FormatChatGPT: parseChatGPT,
+	FormatClaude:  parseClaude,
+	FormatAichat:  parseAichat,
+	FormatSgpt:    parseSgpt,
+}
+
+// ImportFile reads an export from path and converts it into sessions. if
+// format is empty it is guessed from the file contents
+func ImportFile(path string, format Format) ([]*session.Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if format == "" {
+		format, err = Detect(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	parse, ok := parsers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+
+	sessions, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s export: %w", format, err)
+	}
+
+	// drop anything that didn't contain a usable message
+	var imported []*session.Session
+	for _, s := range sessions {
+		if len(s.Messages) == 0 {
+			continue
+		}
+		s.Source = string(format)
+		if s.Title == "" {
+			s.Title = session.TitleFromPrompt(s.Messages[0].Content)
+		}
+		imported = append(imported, s)
+	}
+	return imported, nil
+}
+
+// Detect guesses the export format by looking for fields unique to each tool
+func Detect(data []byte) (Format, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return "", fmt.Errorf("file is empty")
+	}
+
+	// aichat sessions are YAML, everything else is JSON
+	if trimmed[0] != '[' && trimmed[0] != '{' {
+		return FormatAichat, nil
+	}
+
+	var probe []map[string]json.RawMessage
+	if trimmed[0] == '{' {
+		var single map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &single); err != nil {
+			return "", fmt.Errorf("file is not valid JSON: %w", err)
+		}
+		if _, ok := single["messages"]; ok {
+			return FormatAichat, nil
+		}
+		probe = append(probe, single)
+	} else if err := json.Unmarshal(trimmed, &probe); err != nil {
+		return "", fmt.Errorf("file is not valid JSON: %w", err)
+	}
+
+	if len(probe) == 0 {
+		return "", fmt.Errorf("export contains no conversations")
+	}
+
+	first := probe[0]
+	switch {
+	case has(first, "mapping"):
+		return FormatChatGPT, nil
+	case has(first, "chat_messages"):
+		return FormatClaude, nil
+	case has(first, "role") && has(first, "content"):
+		return FormatSgpt, nil
+	}
+
+	return "", fmt.Errorf("could not detect export format, pass one of: %s", formatList())
+}
+
+func has(m map[string]json.RawMessage, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+func formatList() string {
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
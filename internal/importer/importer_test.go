@@ -0,0 +1,181 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const chatGPTExport = `[{
+	"title": "Go generics",
+	"create_time": 1700000000.5,
+	"update_time": 1700000100,
+	"current_node": "c",
+	"mapping": {
+		"root": {"parent": "", "message": {"author": {"role": "system"}, "content": {"parts": ["be brief"]}}},
+		"a": {"parent": "root", "message": {"author": {"role": "user"}, "content": {"parts": ["what are generics?"]}}},
+		"old": {"parent": "a", "message": {"author": {"role": "assistant"}, "content": {"parts": ["an answer on another branch"]}}},
+		"c": {"parent": "a", "message": {"author": {"role": "assistant"}, "content": {"parts": ["type parameters", {"asset_pointer": "image"}]}}}
+	}
+}]`
+
+const claudeExport = `[{
+	"name": "Haiku",
+	"created_at": "2025-01-01T12:00:00Z",
+	"updated_at": "2025-01-01T12:05:00Z",
+	"chat_messages": [
+		{"sender": "human", "text": "write a haiku"},
+		{"sender": "assistant", "text": "", "content": [{"type": "text", "text": "old pond"}, {"type": "tool_use"}, {"type": "text", "text": "frog jumps in"}]},
+		{"sender": "human", "text": "  "}
+	]
+}, {
+	"name": "empty",
+	"chat_messages": []
+}]`
+
+const aichatExport = `model: openai:gpt-4o
+messages:
+- role: system
+  content: be brief
+- role: user
+  content:
+  - type: text
+    text: what's in this image?
+  - type: image_url
+    image_url:
+      url: data:image/png;base64,AAAA
+- role: assistant
+  content: a cat
+`
+
+const sgptExport = `[
+	{"role": "system", "content": "you are a shell assistant"},
+	{"role": "user", "content": "list files"},
+	{"role": "assistant", "content": "ls -la"}
+]`
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Format
+		err  string
+	}{
+		{"chatgpt", chatGPTExport, FormatChatGPT, ""},
+		{"claude", claudeExport, FormatClaude, ""},
+		{"aichat yaml", aichatExport, FormatAichat, ""},
+		{"aichat json", `{"model": "x", "messages": []}`, FormatAichat, ""},
+		{"sgpt", sgptExport, FormatSgpt, ""},
+		{"empty", "  \n", "", "file is empty"},
+		{"no conversations", "[]", "", "no conversations"},
+		{"invalid json", "[{", "", "not valid JSON"},
+		{"unknown", `[{"foo": 1}]`, "", "could not detect"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Detect([]byte(tt.data))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Detect() = %q, %v, want an error containing %q", got, err, tt.err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("Detect() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportFile(t *testing.T) {
+	type msg struct{ role, content string }
+	tests := []struct {
+		name  string
+		data  string
+		title string
+		msgs  []msg
+	}{
+		{
+			name:  "chatgpt",
+			data:  chatGPTExport,
+			title: "Go generics",
+			// only the branch leading to current_node, without the system prompt or the image
+			msgs: []msg{{"user", "what are generics?"}, {"assistant", "type parameters"}},
+		},
+		{
+			name:  "claude",
+			data:  claudeExport,
+			title: "Haiku",
+			msgs:  []msg{{"user", "write a haiku"}, {"assistant", "old pond\n\nfrog jumps in"}},
+		},
+		{
+			name:  "aichat",
+			data:  aichatExport,
+			title: "what's in this image?",
+			msgs:  []msg{{"user", "what's in this image?"}, {"assistant", "a cat"}},
+		},
+		{
+			name:  "sgpt",
+			data:  sgptExport,
+			title: "list files",
+			msgs:  []msg{{"user", "list files"}, {"assistant", "ls -la"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "export")
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			sessions, err := ImportFile(path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			// conversations without a usable message are dropped
+			if len(sessions) != 1 {
+				t.Fatalf("imported %d sessions, want 1", len(sessions))
+			}
+			sess := sessions[0]
+			if sess.Title != tt.title || sess.Source != tt.name {
+				t.Errorf("title %q from %q, want %q from %q", sess.Title, sess.Source, tt.title, tt.name)
+			}
+			if len(sess.Messages) != len(tt.msgs) {
+				t.Fatalf("imported %d messages, want %d: %+v", len(sess.Messages), len(tt.msgs), sess.Messages)
+			}
+			for i, m := range tt.msgs {
+				if got := sess.Messages[i]; got.Role != m.role || got.Content != m.content {
+					t.Errorf("message %d = %s %q, want %s %q", i, got.Role, got.Content, m.role, m.content)
+				}
+			}
+		})
+	}
+}
+
+func TestImportFileTimestamps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conversations.json")
+	if err := os.WriteFile(path, []byte(claudeExport), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := ImportFile(path, FormatClaude)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sessions[0].Created.UTC().Format("15:04"); got != "12:00" {
+		t.Errorf("created at %s, want the export's 12:00", got)
+	}
+	if got := sessions[0].Updated.UTC().Format("15:04"); got != "12:05" {
+		t.Errorf("updated at %s, want the export's 12:05", got)
+	}
+}
+
+func TestImportFileWrongFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.json")
+	if err := os.WriteFile(path, []byte(sgptExport), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImportFile(path, "bard"); err == nil || !strings.Contains(err.Error(), "unknown import format") {
+		t.Fatalf("ImportFile() = %v, want an unknown format error", err)
+	}
+}
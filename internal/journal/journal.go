@@ -0,0 +1,116 @@
+// Package journal appends every prompt and its response to a markdown file
+// per day, a record of what was asked that's readable without ask
+package journal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/paths"
+	"github.com/scbenet/ask/internal/vfs"
+)
+
+// Journal is a directory of YYYY-MM-DD.md files, one per local calendar day
+type Journal struct {
+	dir   string
+	fs    vfs.FS
+	clock clock.Clock
+
+	mu sync.Mutex
+}
+
+// Option configures a Journal
+type Option func(*Journal)
+
+// WithFS keeps the journal on fsys instead of the disk
+func WithFS(fsys vfs.FS) Option {
+	return func(j *Journal) { j.fs = fsys }
+}
+
+// WithClock dates the entries with c instead of the system clock
+func WithClock(c clock.Clock) Option {
+	return func(j *Journal) { j.clock = c }
+}
+
+// DefaultDir returns where the journal is kept, journal in paths.Data
+func DefaultDir() (string, error) {
+	dir, err := paths.Data()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "journal"), nil
+}
+
+// Open returns the journal in dir, which is created with the first entry
+func Open(dir string, opts ...Option) *Journal {
+	j := &Journal{dir: dir, fs: vfs.OS, clock: clock.System}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// Entry is a prompt and the response to it
+type Entry struct {
+	Prompt   string
+	Response string
+	Model    string
+	Session  string // title of the conversation
+	// Continued is the rest of a cut off response journaled before, it's
+	// written without the prompt
+	Continued bool
+}
+
+// Path returns the file of the day t is on
+func (j *Journal) Path(t time.Time) string {
+	return filepath.Join(j.dir, t.Local().Format("2006-01-02")+".md")
+}
+
+// Append adds e to today's file, under a heading with the time, model and
+// conversation
+func (j *Journal) Append(e Entry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := j.clock.Now()
+	if err := j.fs.MkdirAll(j.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	f, err := j.fs.OpenFile(j.Path(now), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	if _, err := f.Write([]byte(format(e, now))); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return f.Close()
+}
+
+func format(e Entry, t time.Time) string {
+	var b strings.Builder
+	heading := []string{t.Local().Format("15:04")}
+	if e.Model != "" {
+		heading = append(heading, e.Model)
+	}
+	if e.Session != "" {
+		heading = append(heading, e.Session)
+	}
+	if e.Continued {
+		heading = append(heading, "continued")
+	}
+	fmt.Fprintf(&b, "## %s\n\n", strings.Join(heading, " · "))
+	if !e.Continued {
+		// the prompt is quoted so its own headings don't break up the file
+		for _, line := range strings.Split(strings.TrimSpace(e.Prompt), "\n") {
+			b.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(e.Response))
+	return b.String()
+}
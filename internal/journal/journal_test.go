@@ -0,0 +1,42 @@
+package journal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/vfs"
+)
+
+func TestAppend(t *testing.T) {
+	c := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local))
+	fsys := vfs.NewMem(c)
+	j := Open("/data/journal", WithFS(fsys), WithClock(c))
+
+	if err := j.Append(Entry{Prompt: "hi\n\nthere", Response: "Hello!\n", Model: "test:m", Session: "greetings"}); err != nil {
+		t.Fatal(err)
+	}
+	c.Advance(time.Minute)
+	if err := j.Append(Entry{Prompt: "bye", Response: "Bye."}); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Append(Entry{Prompt: "bye", Response: " See you.", Continued: true}); err != nil {
+		t.Fatal(err)
+	}
+	c.Advance(24 * time.Hour)
+	if err := j.Append(Entry{Prompt: "next day", Response: "ok"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fsys.ReadFile("/data/journal/2025-01-01.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "## 12:00 · test:m · greetings\n\n> hi\n>\n> there\n\nHello!\n\n## 12:01\n\n> bye\n\nBye.\n\n## 12:01 · continued\n\nSee you.\n\n"
+	if string(data) != want {
+		t.Errorf("journal is\n%q\nwant\n%q", data, want)
+	}
+	if _, err := fsys.ReadFile("/data/journal/2025-01-02.md"); err != nil {
+		t.Errorf("the next day has no file of its own: %v", err)
+	}
+}
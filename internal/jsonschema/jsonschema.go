@@ -0,0 +1,403 @@
+// Package jsonschema validates JSON values against the part of JSON Schema
+// structured output schemas use: types, properties, items, enums, ranges,
+// lengths, patterns and the anyOf, allOf, oneOf and not combinators.
+// keywords it doesn't know, like $ref or format, are ignored
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Schema is a compiled schema
+type Schema struct {
+	// set for the true and false schemas, which accept everything or nothing
+	always *bool
+
+	types      []string
+	properties map[string]*Schema
+	required   []string
+	// nil allows any other property
+	additional *Schema
+	items      *Schema
+	enum       []any
+	constant   any
+	hasConst   bool
+
+	minimum, maximum                   *float64
+	exclusiveMinimum, exclusiveMaximum *float64
+	minLength, maxLength               *int
+	minItems, maxItems                 *int
+	pattern                            *regexp.Regexp
+
+	anyOf, allOf, oneOf []*Schema
+	not                 *Schema
+
+	raw json.RawMessage
+}
+
+// ValidationError is a way a value doesn't match the schema
+type ValidationError struct {
+	// Path is the JSON pointer of the value, "" for the whole value
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+var knownTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+// Compile parses a schema, it fails on keywords it knows with values that
+// make no sense
+func Compile(data []byte) (*Schema, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("schema isn't valid JSON: %w", err)
+	}
+	s, err := compile(v, "")
+	if err != nil {
+		return nil, err
+	}
+	s.raw = json.RawMessage(data)
+	return s, nil
+}
+
+// Raw is the schema as it was compiled
+func (s *Schema) Raw() json.RawMessage {
+	return s.raw
+}
+
+func compile(v any, path string) (*Schema, error) {
+	switch v := v.(type) {
+	case bool:
+		return &Schema{always: &v}, nil
+	case map[string]any:
+		return compileObject(v, path)
+	}
+	return nil, fmt.Errorf("schema%s must be an object or a boolean", at(path))
+}
+
+func compileObject(m map[string]any, path string) (*Schema, error) {
+	s := &Schema{}
+	var err error
+	fail := func(keyword, want string) error {
+		return fmt.Errorf("schema%s: %s must be %s", at(path+"/"+keyword), keyword, want)
+	}
+
+	switch t := m["type"].(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []any:
+		for _, v := range t {
+			name, ok := v.(string)
+			if !ok {
+				return nil, fail("type", "a type name or a list of them")
+			}
+			s.types = append(s.types, name)
+		}
+	default:
+		return nil, fail("type", "a type name or a list of them")
+	}
+	for _, t := range s.types {
+		if !knownTypes[t] {
+			return nil, fmt.Errorf("schema%s: unknown type %q", at(path+"/type"), t)
+		}
+	}
+
+	if props, ok := m["properties"]; ok {
+		pm, ok := props.(map[string]any)
+		if !ok {
+			return nil, fail("properties", "an object")
+		}
+		s.properties = map[string]*Schema{}
+		for name, sub := range pm {
+			if s.properties[name], err = compile(sub, path+"/properties/"+escape(name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if req, ok := m["required"]; ok {
+		list, ok := req.([]any)
+		if !ok {
+			return nil, fail("required", "a list of property names")
+		}
+		for _, v := range list {
+			name, ok := v.(string)
+			if !ok {
+				return nil, fail("required", "a list of property names")
+			}
+			s.required = append(s.required, name)
+		}
+	}
+	if add, ok := m["additionalProperties"]; ok {
+		if s.additional, err = compile(add, path+"/additionalProperties"); err != nil {
+			return nil, err
+		}
+	}
+	if items, ok := m["items"]; ok {
+		if s.items, err = compile(items, path+"/items"); err != nil {
+			return nil, err
+		}
+	}
+	if enum, ok := m["enum"]; ok {
+		if s.enum, ok = enum.([]any); !ok {
+			return nil, fail("enum", "a list")
+		}
+	}
+	s.constant, s.hasConst = m["const"]
+
+	for keyword, dst := range map[string]**float64{
+		"minimum": &s.minimum, "maximum": &s.maximum,
+		"exclusiveMinimum": &s.exclusiveMinimum, "exclusiveMaximum": &s.exclusiveMaximum,
+	} {
+		if v, ok := m[keyword]; ok {
+			n, ok := v.(float64)
+			if !ok {
+				return nil, fail(keyword, "a number")
+			}
+			*dst = &n
+		}
+	}
+	for keyword, dst := range map[string]**int{
+		"minLength": &s.minLength, "maxLength": &s.maxLength,
+		"minItems": &s.minItems, "maxItems": &s.maxItems,
+	} {
+		if v, ok := m[keyword]; ok {
+			n, ok := v.(float64)
+			if !ok || n < 0 || n != math.Trunc(n) {
+				return nil, fail(keyword, "a non-negative integer")
+			}
+			i := int(n)
+			*dst = &i
+		}
+	}
+	if p, ok := m["pattern"]; ok {
+		expr, ok := p.(string)
+		if !ok {
+			return nil, fail("pattern", "a regular expression")
+		}
+		if s.pattern, err = regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("schema%s: %w", at(path+"/pattern"), err)
+		}
+	}
+
+	for keyword, dst := range map[string]*[]*Schema{"anyOf": &s.anyOf, "allOf": &s.allOf, "oneOf": &s.oneOf} {
+		v, ok := m[keyword]
+		if !ok {
+			continue
+		}
+		list, ok := v.([]any)
+		if !ok || len(list) == 0 {
+			return nil, fail(keyword, "a non-empty list of schemas")
+		}
+		for i, sub := range list {
+			compiled, err := compile(sub, fmt.Sprintf("%s/%s/%d", path, keyword, i))
+			if err != nil {
+				return nil, err
+			}
+			*dst = append(*dst, compiled)
+		}
+	}
+	if not, ok := m["not"]; ok {
+		if s.not, err = compile(not, path+"/not"); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Validate returns every way v, as decoded by encoding/json into an any,
+// doesn't match the schema. nil means it matches
+func (s *Schema) Validate(v any) []ValidationError {
+	var errs []ValidationError
+	s.validate(v, "", &errs)
+	return errs
+}
+
+func (s *Schema) validate(v any, path string, errs *[]ValidationError) {
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.always != nil {
+		if !*s.always {
+			fail("no value is allowed here")
+		}
+		return
+	}
+
+	if len(s.types) > 0 && !hasType(v, s.types) {
+		fail("expected %s, got %s", strings.Join(s.types, " or "), typeOf(v))
+		// the other keywords would only repeat the mismatch
+		return
+	}
+	if s.enum != nil && !contains(s.enum, v) {
+		fail("must be one of %s", compact(s.enum))
+	}
+	if s.hasConst && !reflect.DeepEqual(s.constant, v) {
+		fail("must be %s", compact(s.constant))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sub := path + "/" + escape(name)
+			if prop, ok := s.properties[name]; ok {
+				prop.validate(v[name], sub, errs)
+			} else if s.additional != nil {
+				if s.additional.always != nil && !*s.additional.always {
+					fail("property %q isn't allowed", name)
+					continue
+				}
+				s.additional.validate(v[name], sub, errs)
+			}
+		}
+	case []any:
+		if s.minItems != nil && len(v) < *s.minItems {
+			fail("must have at least %d item(s), has %d", *s.minItems, len(v))
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			fail("must have at most %d item(s), has %d", *s.maxItems, len(v))
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(item, path+"/"+strconv.Itoa(i), errs)
+			}
+		}
+	case string:
+		n := len([]rune(v))
+		if s.minLength != nil && n < *s.minLength {
+			fail("must be at least %d character(s) long", *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			fail("must be at most %d character(s) long", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match %s", s.pattern)
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			fail("must be at least %g", *s.minimum)
+		}
+		if s.maximum != nil && v > *s.maximum {
+			fail("must be at most %g", *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && v <= *s.exclusiveMinimum {
+			fail("must be greater than %g", *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && v >= *s.exclusiveMaximum {
+			fail("must be less than %g", *s.exclusiveMaximum)
+		}
+	}
+
+	for _, sub := range s.allOf {
+		sub.validate(v, path, errs)
+	}
+	if len(s.anyOf) > 0 && s.matching(s.anyOf, v) == 0 {
+		fail("doesn't match any of the allowed schemas")
+	}
+	if len(s.oneOf) > 0 {
+		if n := s.matching(s.oneOf, v); n != 1 {
+			fail("must match exactly one of the allowed schemas, matches %d", n)
+		}
+	}
+	if s.not != nil && len(s.not.Validate(v)) == 0 {
+		fail("matches a schema it mustn't")
+	}
+}
+
+// matching counts the schemas v matches
+func (s *Schema) matching(schemas []*Schema, v any) int {
+	n := 0
+	for _, sub := range schemas {
+		if len(sub.Validate(v)) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func hasType(v any, types []string) bool {
+	got := typeOf(v)
+	for _, t := range types {
+		if t == got || t == "number" && got == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// typeOf names the JSON type of v, whole numbers are integers
+func typeOf(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func contains(list []any, v any) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func compact(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// escape escapes a property name for a JSON pointer
+func escape(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
+
+func at(path string) string {
+	if path == "" {
+		return ""
+	}
+	return " at " + path
+}
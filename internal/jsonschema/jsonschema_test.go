@@ -0,0 +1,69 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const personSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0},
+		"role": {"enum": ["admin", "user"]},
+		"tags": {"type": "array", "items": {"type": "string", "pattern": "^[a-z]+$"}, "maxItems": 2},
+		"email": {"anyOf": [{"type": "string"}, {"type": "null"}]}
+	},
+	"required": ["name", "age"],
+	"additionalProperties": false
+}`
+
+func TestValidate(t *testing.T) {
+	schema, err := Compile([]byte(personSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{`{"name": "ada", "age": 36, "role": "admin", "tags": ["math"], "email": null}`, nil},
+		{`{"name": "ada", "age": 36.5}`, []string{"/age: expected integer, got number"}},
+		{`{"age": -1}`, []string{`missing required property "name"`, "/age: must be at least 0"}},
+		{`{"name": "", "age": 1, "extra": true}`, []string{`property "extra" isn't allowed`, "/name: must be at least 1 character(s) long"}},
+		{`{"name": "a", "age": 1, "role": "root"}`, []string{`/role: must be one of ["admin","user"]`}},
+		{`{"name": "a", "age": 1, "tags": ["ok", "Bad", "x"]}`, []string{"/tags: must have at most 2 item(s), has 3", "/tags/1: must match ^[a-z]+$"}},
+		{`{"name": "a", "age": 1, "email": 3}`, []string{"/email: doesn't match any of the allowed schemas"}},
+		{`[1, 2]`, []string{"expected object, got array"}},
+	}
+	for _, tt := range tests {
+		var v any
+		if err := json.Unmarshal([]byte(tt.value), &v); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range schema.Validate(v) {
+			got = append(got, e.Error())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("Validate(%s) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := map[string]string{
+		`[]`:               "schema must be an object or a boolean",
+		`{"type": "text"}`: `schema at /type: unknown type "text"`,
+		`{"properties": {"a": {"minLength": -1}}}`: "schema at /properties/a/minLength: minLength must be a non-negative integer",
+		`{"pattern": "("}`:                         "schema at /pattern: error parsing regexp",
+		`{"anyOf": []}`:                            "schema at /anyOf: anyOf must be a non-empty list of schemas",
+	}
+	for schema, want := range tests {
+		_, err := Compile([]byte(schema))
+		if err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("Compile(%s) = %v, want %q", schema, err, want)
+		}
+	}
+}
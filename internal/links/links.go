@@ -0,0 +1,78 @@
+package links
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	markdownLinkPattern = regexp.MustCompile(`\[[^\]]*\]\((https?://[^)\s]+)\)`)
+	bareURLPattern      = regexp.MustCompile(`https?://[^\s<>()\[\]"'` + "`" + `]+`)
+	// URLs in rendered output stop at whitespace or the next escape sequence
+	renderedURLPattern = regexp.MustCompile(`https?://[^\s\x1b]+`)
+)
+
+// Extract returns the unique URLs in a markdown document in order of first appearance
+func Extract(md string) []string {
+	var urls []string
+	seen := map[string]bool{}
+	add := func(u string) {
+		u = strings.TrimRight(u, ".,;:!?*_")
+		if !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+
+	// markdown links first so parentheses inside link targets survive
+	for _, m := range markdownLinkPattern.FindAllStringSubmatch(md, -1) {
+		add(m[1])
+	}
+	stripped := markdownLinkPattern.ReplaceAllString(md, "")
+	for _, u := range bareURLPattern.FindAllString(stripped, -1) {
+		add(u)
+	}
+	return urls
+}
+
+// Hyperlink wraps every URL in already rendered output in an OSC 8 escape
+// sequence so supporting terminals make them clickable
+func Hyperlink(rendered string) string {
+	return renderedURLPattern.ReplaceAllStringFunc(rendered, func(u string) string {
+		target := strings.TrimRight(u, ".,;:!?")
+		return "\x1b]8;;" + target + "\x1b\\" + u + "\x1b]8;;\x1b\\"
+	})
+}
+
+// TerminalSupportsHyperlinks guesses from the environment whether the
+// terminal understands OSC 8. there is no reliable query for this, unknown
+// terminals get plain text
+func TerminalSupportsHyperlinks() bool {
+	if os.Getenv("ASK_HYPERLINKS") == "0" {
+		return false
+	}
+	if os.Getenv("ASK_HYPERLINKS") == "1" {
+		return true
+	}
+
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "ghostty", "Hyper", "Tabby", "rio":
+		return true
+	}
+	if os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("WT_SESSION") != "" || os.Getenv("DOMTERM") != "" {
+		return true
+	}
+	if vte, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && vte >= 5000 {
+		return true
+	}
+
+	term := os.Getenv("TERM")
+	for _, t := range []string{"kitty", "alacritty", "foot", "wezterm", "ghostty"} {
+		if strings.Contains(term, t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sseBody is a streamed response of n small chunks, the way providers send them
+func sseBody(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		chunk := OpenRouterStreamChunk{Choices: []OpenRouterStreamChoice{{Delta: OpenRouterStreamDelta{Content: fmt.Sprintf("word%d ", i)}}}}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(&b, "data: %s\n\n", data)
+		if i%100 == 0 {
+			b.WriteString(": OPENROUTER PROCESSING\n\n")
+		}
+	}
+	b.WriteString(`data: {"choices":[{"delta":{},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":1000,"total_tokens":1010}}` + "\n\n")
+	b.WriteString("data: [DONE]\n\n")
+	return b.String()
+}
+
+// BenchmarkStreamGenerate parses a 1000 chunk SSE response from a local
+// server into chunk messages
+func BenchmarkStreamGenerate(b *testing.B) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	body := sseBody(1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+	client := NewOpenAICompatibleClient(server.URL, "")
+	req := Request{Model: "bench", Messages: []Message{{Role: "user", Content: "hi"}}}
+
+	b.SetBytes(int64(len(body)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		msgs := make(chan tea.Msg, 64)
+		client.StreamGenerate(context.Background(), req, msgs)
+		for msg := range msgs {
+			if m, ok := msg.(StreamErrorMsg); ok {
+				b.Fatal(m.Err)
+			}
+		}
+	}
+}
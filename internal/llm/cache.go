@@ -0,0 +1,35 @@
+package llm
+
+const (
+	// maxCacheBreakpoints is how many cache_control breakpoints Anthropic
+	// allows in a request
+	maxCacheBreakpoints = 4
+	// minCacheTokens is the smallest prompt Anthropic caches, smaller
+	// messages aren't worth a breakpoint
+	minCacheTokens = 1024
+)
+
+// cacheBreakpoints returns messages with Cache left set on the last few
+// large messages asking for it, each caching the prompt up to it. OpenAI
+// style servers cache on their own and may reject the breakpoints, so
+// without enabled none are left. messages isn't modified
+func cacheBreakpoints(messages []Message, enabled bool) []Message {
+	marked := false
+	for _, m := range messages {
+		marked = marked || m.Cache
+	}
+	if !marked {
+		return messages
+	}
+	out := make([]Message, len(messages))
+	copy(out, messages)
+	left := maxCacheBreakpoints
+	for i := len(out) - 1; i >= 0; i-- {
+		if out[i].Cache && enabled && left > 0 && EstimateTokens(out[i].Content) >= minCacheTokens {
+			left--
+			continue
+		}
+		out[i].Cache = false
+	}
+	return out
+}
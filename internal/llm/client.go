@@ -1,52 +1,260 @@
 package llm
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/logging"
 )
 
 // LLMClient defines the interface for interacting with an LLM.
 type LLMClient interface {
 	Generate(ctx context.Context, modelName string, prompt string, history []Message) (string, error)
-	StreamGenerate(ctx context.Context, modelName string, history []Message, msgChan chan<- tea.Msg)
+	StreamGenerate(ctx context.Context, req Request, msgChan chan<- tea.Msg)
+}
+
+// Request is a single streaming chat completion request
+type Request struct {
+	Model    string
+	Messages []Message
+	Tools    []ToolDefinition // functions the model may call, nil disables tool calling
+	// Resumable models continue a partial assistant message ending the
+	// conversation, see RetryingClient
+	Resumable bool
+	Params
+}
+
+// Params are generation settings, zero values leave the provider's default
+type Params struct {
+	Temperature     *float64
+	MaxTokens       int
+	ReasoningEffort string // "low", "medium" or "high" for reasoning models
+	// JSONSchema asks for a JSON response matching the schema
+	JSONSchema json.RawMessage
+	// Stop ends the response where the model writes one of these
+	Stop []string
+
+	Seed              *int // for reproducible responses, as far as the provider can
+	FrequencyPenalty  *float64
+	PresencePenalty   *float64
+	RepetitionPenalty *float64
+	MinP              *float64
+	LogitBias         map[string]float64 // by token id
+}
+
+// ParamChecker is implemented by clients that leave some of the Params out
+// of their requests because the provider has no such setting
+type ParamChecker interface {
+	// UnsupportedParams names the settings in p that aren't sent, by their
+	// config names
+	UnsupportedParams(p Params) []string
 }
 
 type GenerationErrorMsg struct{ Err error }
 
 // Message struct for conversation history
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // calls requested by an assistant message
+	ToolCallID string     `json:"tool_call_id,omitempty"` // the call a tool message answers
+	// Cache asks for the prompt up to this message to be cached, for large
+	// attached context that's sent again with every prompt. only providers
+	// that need to be told get the breakpoint, see cacheBreakpoints
+	Cache bool `json:"-"`
+}
+
+// contentPart is a piece of a message's content, the form that can carry a
+// cache_control breakpoint
+type contentPart struct {
+	Type         string        `json:"type"`
+	Text         string        `json:"text"`
+	CacheControl *cacheControl `json:"cache_control,omitempty"`
+}
+
+type cacheControl struct {
+	Type string `json:"type"`
+}
+
+// MarshalJSON sends a message marked Cache with its content as a text part
+// with a cache_control breakpoint, which OpenRouter passes on to Anthropic
+// and Gemini models
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plain Message
+	if !m.Cache {
+		return json.Marshal(plain(m))
+	}
+	return json.Marshal(struct {
+		plain
+		Content []contentPart `json:"content"`
+	}{plain(m), []contentPart{{Type: "text", Text: m.Content, CacheControl: &cacheControl{Type: "ephemeral"}}}})
 }
 
 type LLMReplyMsg struct{ Content string }
 
+// Usage holds the token accounting reported by the provider for a response
+type Usage struct {
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost,omitempty"` // in USD, only reported by some providers
+	// prompt tokens read from and written to the provider's prompt cache,
+	// part of PromptTokens
+	CacheReadTokens  int `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
+}
+
+// Source is a document the model cited, e.g. a web search result
+type Source struct {
+	Title string `json:"title,omitempty"`
+	URL   string `json:"url"`
+}
+
 type StreamChunkMsg struct{ Content string }
-type StreamEndMsg struct{ FullResponse string }
-type StreamErrorMsg struct{ Err error }
 
+// StreamKeepaliveMsg is sent while a stream shows it's alive without sending
+// content, e.g. the comment lines openrouter sends while a model thinks. at
+// most one is sent every keepaliveInterval
+type StreamKeepaliveMsg struct{}
+
+const keepaliveInterval = time.Second
+
+type StreamEndMsg struct {
+	FullResponse string
+	// RequestID is ours, ProviderID the one the provider gave the response,
+	// as shown on its dashboard. either may be empty
+	RequestID  string
+	ProviderID string
+	Usage      *Usage     // nil if the provider didn't report usage
+	Sources    []Source   // citations returned alongside the response
+	ToolCalls  []ToolCall // functions the model wants called before it continues
+	// FinishReason is why the model stopped, FinishLength and
+	// FinishStopSequence mean the response was cut off. "" if not reported
+	FinishReason string
+}
+
+// finish reasons of a StreamEndMsg besides the provider's own
+const (
+	FinishStop         = "stop"
+	FinishLength       = "length"        // ran into the max tokens
+	FinishStopSequence = "stop_sequence" // wrote one of the request's stop sequences
+)
+
+type StreamErrorMsg struct {
+	Err       error
+	RequestID string // see StreamEndMsg
+}
+
+// OpenRouterClient streams chat completions from OpenRouter. the same API is
+// spoken by most local and self hosted servers, see NewOpenAICompatibleClient
 type OpenRouterClient struct {
+	timeouts
 	apiKey     string
 	httpClient *http.Client
 	baseURL    string
+	// openRouter enables OpenRouter's extensions to the API (usage
+	// accounting, app attribution headers)
+	openRouter bool
+}
+
+// timeouts are the limits shared by every client
+type timeouts struct {
+	requestTimeout time.Duration // total time allowed for one request, 0 for no limit
+	stallTimeout   time.Duration // max gap between stream chunks, 0 for no limit
+}
+
+func defaultTimeouts() timeouts {
+	return timeouts{requestTimeout: 10 * time.Minute, stallTimeout: 60 * time.Second}
+}
+
+// Option configures a client
+type Option func(*timeouts)
+
+// WithRequestTimeout limits how long a single request may take in total
+func WithRequestTimeout(d time.Duration) Option {
+	return func(t *timeouts) { t.requestTimeout = d }
+}
+
+// WithStallTimeout aborts a stream if no data arrives for d
+func WithStallTimeout(d time.Duration) Option {
+	return func(t *timeouts) { t.stallTimeout = d }
+}
+
+// ErrStreamStalled is the cause of a stream aborted by the stall detector
+var ErrStreamStalled = errors.New("stream stalled")
+
+// StatusError is a request the API answered with an error status
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.Status, e.Message)
 }
 
 type OpenRouterRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-	Stream   bool      `json:"stream,omitempty"`
+	Model           string                    `json:"model"`
+	Messages        []Message                 `json:"messages"`
+	Stream          bool                      `json:"stream,omitempty"`
+	Usage           *OpenRouterUsageParam     `json:"usage,omitempty"`
+	StreamOptions   *StreamOptions            `json:"stream_options,omitempty"`
+	Tools           []ToolDefinition          `json:"tools,omitempty"`
+	Temperature     *float64                  `json:"temperature,omitempty"`
+	MaxTokens       int                       `json:"max_tokens,omitempty"`
+	Reasoning       *OpenRouterReasoningParam `json:"reasoning,omitempty"`        // openrouter
+	ReasoningEffort string                    `json:"reasoning_effort,omitempty"` // openai compatible servers
+	ResponseFormat  *ResponseFormat           `json:"response_format,omitempty"`
+	Stop            []string                  `json:"stop,omitempty"`
+
+	Seed              *int               `json:"seed,omitempty"`
+	FrequencyPenalty  *float64           `json:"frequency_penalty,omitempty"`
+	PresencePenalty   *float64           `json:"presence_penalty,omitempty"`
+	RepetitionPenalty *float64           `json:"repetition_penalty,omitempty"` // openrouter only
+	MinP              *float64           `json:"min_p,omitempty"`              // openrouter only
+	LogitBias         map[string]float64 `json:"logit_bias,omitempty"`
+}
+
+// ResponseFormat asks for structured output, Type is "json_schema"
+type ResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaFormat is the schema the response has to match
+type JSONSchemaFormat struct {
+	Name string `json:"name"`
+	// strict mode rejects schemas that leave out additionalProperties or
+	// don't require every property, the response is validated afterwards
+	// anyway
+	Strict bool            `json:"strict"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+// OpenRouter's unified reasoning setting, translated for each provider
+type OpenRouterReasoningParam struct {
+	Effort string `json:"effort"`
+}
+
+// asks OpenRouter to include token usage in the response
+type OpenRouterUsageParam struct {
+	Include bool `json:"include"`
+}
+
+// the standard OpenAI way of asking for usage on the last stream chunk
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // single choice's non-streaming response message content
@@ -71,35 +279,370 @@ type OpenRouterResponse struct {
 
 // holds content difference in a stream chunk
 type OpenRouterStreamDelta struct {
-	Content string `json:"content"`
+	Content     string                    `json:"content"`
+	Annotations []OpenRouterAnnotation    `json:"annotations,omitempty"`
+	ToolCalls   []OpenRouterToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+// annotation attached to a response, the web plugin uses these for citations
+type OpenRouterAnnotation struct {
+	Type        string `json:"type"`
+	URLCitation *struct {
+		URL   string `json:"url"`
+		Title string `json:"title"`
+	} `json:"url_citation,omitempty"`
+}
+
+// sourcesFromAnnotations collects the unique url citations from annotations
+func sourcesFromAnnotations(sources []Source, annotations []OpenRouterAnnotation) []Source {
+	for _, a := range annotations {
+		if a.Type != "url_citation" || a.URLCitation == nil || a.URLCitation.URL == "" {
+			continue
+		}
+		duplicate := false
+		for _, s := range sources {
+			if s.URL == a.URLCitation.URL {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			sources = append(sources, Source{Title: a.URLCitation.Title, URL: a.URLCitation.URL})
+		}
+	}
+	return sources
 }
 
 // holds a choice in a stream chunk
 type OpenRouterStreamChoice struct {
 	Delta        OpenRouterStreamDelta `json:"delta"`
 	FinishReason *string               `json:"finish_reason,omitempty"`
+	// the provider's own reason on openrouter, which tells a stop sequence
+	// apart from the model finishing
+	NativeFinishReason string `json:"native_finish_reason,omitempty"`
 }
 
 // structure of an individual SSE data event
 type OpenRouterStreamChunk struct {
+	ID      string                   `json:"id,omitempty"` // the generation, e.g. "gen-..." on openrouter
 	Choices []OpenRouterStreamChoice `json:"choices"`
 	Error   *OpenRouterResponseError `json:"error,omitempty"` // check for errors in chunks too
+	Usage   *OpenRouterUsage         `json:"usage,omitempty"` // only present on the final chunk
+	// perplexity models cite the search results by their position in these,
+	// "[1]" in the text is the first. every chunk repeats the whole list
+	Citations     []string                 `json:"citations,omitempty"`
+	SearchResults []PerplexitySearchResult `json:"search_results,omitempty"`
+}
+
+// OpenRouterUsage is Usage as OpenAI style APIs report it, with the cached
+// tokens in the details
+type OpenRouterUsage struct {
+	Usage
+	PromptTokensDetails *struct {
+		CachedTokens     int `json:"cached_tokens"`
+		CacheWriteTokens int `json:"cache_write_tokens"`
+	} `json:"prompt_tokens_details,omitempty"`
+}
+
+// usage returns the usage with the cached tokens filled in
+func (u *OpenRouterUsage) usage() *Usage {
+	usage := u.Usage
+	if d := u.PromptTokensDetails; d != nil {
+		usage.CacheReadTokens = d.CachedTokens
+		usage.CacheWriteTokens = d.CacheWriteTokens
+	}
+	return &usage
+}
+
+// PerplexitySearchResult is a page a perplexity model searched, it gives
+// the citation with the same URL a title
+type PerplexitySearchResult struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// sourcesFromCitations numbers the sources the way a perplexity response
+// refers to them, so the footnotes match the markers in the text
+func sourcesFromCitations(citations []string, results []PerplexitySearchResult) []Source {
+	if len(citations) == 0 {
+		for _, r := range results {
+			citations = append(citations, r.URL)
+		}
+	}
+	titles := map[string]string{}
+	for _, r := range results {
+		titles[r.URL] = r.Title
+	}
+	var sources []Source
+	for _, u := range citations {
+		sources = append(sources, Source{Title: titles[u], URL: u})
+	}
+	return sources
 }
 
-func NewOpenRouterClient() (*OpenRouterClient, error) {
+func NewOpenRouterClient(opts ...Option) (*OpenRouterClient, error) {
 	apiKey := os.Getenv("OPENROUTER_API_KEY")
 	if apiKey == "" {
 		return nil, errors.New("OPENROUTER_API_KEY environment variable not set")
 	}
 
-	return &OpenRouterClient{
-		apiKey:     apiKey,
-		httpClient: &http.Client{Timeout: 360 * time.Second},
+	c := &OpenRouterClient{
+		timeouts: defaultTimeouts(),
+		apiKey:   apiKey,
+		// timeouts are applied per request through the context so long
+		// streams aren't cut off by a client wide deadline
+		httpClient: &http.Client{},
 		baseURL:    "https://openrouter.ai/api/v1/chat/completions",
-	}, nil
+		openRouter: true,
+	}
+	for _, opt := range opts {
+		opt(&c.timeouts)
+	}
+	return c, nil
+}
+
+// BaseURL is where the requests go
+func (c *OpenRouterClient) BaseURL() string {
+	return c.baseURL
+}
+
+// NewOpenAICompatibleClient creates a client for a server implementing the
+// OpenAI chat completions API (LM Studio, vLLM, llama.cpp, LiteLLM).
+// baseURL is the API root, e.g. http://localhost:1234/v1, apiKey may be empty
+func NewOpenAICompatibleClient(baseURL, apiKey string, opts ...Option) *OpenRouterClient {
+	c := &OpenRouterClient{
+		timeouts:   defaultTimeouts(),
+		apiKey:     apiKey,
+		httpClient: &http.Client{},
+		baseURL:    strings.TrimSuffix(baseURL, "/") + "/chat/completions",
+	}
+	for _, opt := range opts {
+		opt(&c.timeouts)
+	}
+	return c
+}
+
+// UnsupportedParams names the settings the OpenAI API has no equivalent
+// for, OpenRouter takes all of them (though not every model does, see
+// ModelInfo.SupportedParameters)
+func (c *OpenRouterClient) UnsupportedParams(p Params) []string {
+	if c.openRouter {
+		return nil
+	}
+	var names []string
+	if p.RepetitionPenalty != nil {
+		names = append(names, "repetitionPenalty")
+	}
+	if p.MinP != nil {
+		names = append(names, "minP")
+	}
+	return names
+}
+
+// applyParams sets the generation settings on a request body
+func (c *OpenRouterClient) applyParams(body *OpenRouterRequest, p Params) {
+	body.Temperature = p.Temperature
+	body.MaxTokens = p.MaxTokens
+	body.Stop = p.Stop
+	body.Seed = p.Seed
+	body.FrequencyPenalty = p.FrequencyPenalty
+	body.PresencePenalty = p.PresencePenalty
+	body.LogitBias = p.LogitBias
+	// the OpenAI API has no such settings, servers are apt to reject them
+	if c.openRouter {
+		body.RepetitionPenalty = p.RepetitionPenalty
+		body.MinP = p.MinP
+	}
+	if p.JSONSchema != nil {
+		body.ResponseFormat = &ResponseFormat{Type: "json_schema", JSONSchema: &JSONSchemaFormat{Name: "response", Schema: p.JSONSchema}}
+	}
+	if p.ReasoningEffort == "" {
+		return
+	}
+	if c.openRouter {
+		body.Reasoning = &OpenRouterReasoningParam{Effort: p.ReasoningEffort}
+	} else {
+		body.ReasoningEffort = p.ReasoningEffort
+	}
+}
+
+// setHeaders adds authentication (and attribution for OpenRouter) to req
+func (c *OpenRouterClient) setHeaders(req *http.Request) {
+	req.Header.Set("Content-Type", "application/json")
+	setRequestID(req)
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+	}
+	if c.openRouter {
+		req.Header.Set("HTTP-Referer", "https://github.com/scbenet/ask")
+		req.Header.Set("X-Title", "Ask CLI")
+	}
+}
+
+// ModelInfo describes a model offered by a provider. fields other than ID
+// are zero when the provider doesn't report them
+type ModelInfo struct {
+	ID              string
+	Name            string
+	Description     string
+	ContextLength   int
+	PromptPrice     float64 // USD per million prompt tokens
+	CompletionPrice float64 // USD per million completion tokens
+	// USD per million prompt tokens read from and written to the prompt
+	// cache, 0 if the model doesn't list them
+	CacheReadPrice  float64
+	CacheWritePrice float64
+	// SupportedParameters are the request parameters the model takes, as the
+	// API names them (e.g. "min_p"). only OpenRouter lists them, nil if unknown
+	SupportedParameters []string
+}
+
+// UnsupportedParams names the settings in p the model doesn't list in
+// SupportedParameters, by their config names. nil when the list is unknown
+func (m ModelInfo) UnsupportedParams(p Params) []string {
+	if m.SupportedParameters == nil {
+		return nil
+	}
+	var names []string
+	for _, param := range []struct {
+		name, api string
+		set       bool
+	}{
+		{"temperature", "temperature", p.Temperature != nil},
+		{"maxTokens", "max_tokens", p.MaxTokens > 0},
+		{"stop", "stop", len(p.Stop) > 0},
+		{"seed", "seed", p.Seed != nil},
+		{"frequencyPenalty", "frequency_penalty", p.FrequencyPenalty != nil},
+		{"presencePenalty", "presence_penalty", p.PresencePenalty != nil},
+		{"repetitionPenalty", "repetition_penalty", p.RepetitionPenalty != nil},
+		{"minP", "min_p", p.MinP != nil},
+		{"logitBias", "logit_bias", len(p.LogitBias) > 0},
+	} {
+		if param.set && !slices.Contains(m.SupportedParameters, param.api) {
+			names = append(names, param.name)
+		}
+	}
+	return names
+}
+
+// Models lists the models the server offers. OpenRouter includes context
+// sizes and pricing, most other servers only the ids
+func (c *OpenRouterClient) Models(ctx context.Context) ([]ModelInfo, error) {
+	url := strings.TrimSuffix(c.baseURL, "/chat/completions") + "/models"
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &StatusError{Status: resp.StatusCode, Message: string(body)}
+	}
+
+	var list struct {
+		Data []struct {
+			ID            string `json:"id"`
+			Name          string `json:"name"`
+			Description   string `json:"description"`
+			ContextLength int    `json:"context_length"`
+			Pricing       struct {
+				Prompt          string `json:"prompt"`
+				Completion      string `json:"completion"`
+				InputCacheRead  string `json:"input_cache_read"`
+				InputCacheWrite string `json:"input_cache_write"`
+			} `json:"pricing"`
+			SupportedParameters []string `json:"supported_parameters"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal model list: %w", err)
+	}
+	models := make([]ModelInfo, 0, len(list.Data))
+	for _, m := range list.Data {
+		models = append(models, ModelInfo{
+			ID:                  m.ID,
+			Name:                m.Name,
+			Description:         m.Description,
+			ContextLength:       m.ContextLength,
+			PromptPrice:         perMillion(m.Pricing.Prompt),
+			CompletionPrice:     perMillion(m.Pricing.Completion),
+			CacheReadPrice:      perMillion(m.Pricing.InputCacheRead),
+			CacheWritePrice:     perMillion(m.Pricing.InputCacheWrite),
+			SupportedParameters: m.SupportedParameters,
+		})
+	}
+	return models, nil
+}
+
+// Credits is the balance of an OpenRouter account in USD
+type Credits struct {
+	Total float64 // credits ever purchased
+	Used  float64
+}
+
+// Remaining is what's left to spend
+func (c Credits) Remaining() float64 {
+	return c.Total - c.Used
+}
+
+// Credits fetches the account balance, only OpenRouter has this endpoint
+func (c *OpenRouterClient) Credits(ctx context.Context) (Credits, error) {
+	url := strings.TrimSuffix(c.baseURL, "/chat/completions") + "/credits"
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return Credits{}, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return Credits{}, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return Credits{}, &StatusError{Status: resp.StatusCode, Message: string(body)}
+	}
+
+	var credits struct {
+		Data struct {
+			TotalCredits float64 `json:"total_credits"`
+			TotalUsage   float64 `json:"total_usage"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&credits); err != nil {
+		return Credits{}, fmt.Errorf("failed to unmarshal credits: %w", err)
+	}
+	return Credits{Total: credits.Data.TotalCredits, Used: credits.Data.TotalUsage}, nil
+}
+
+// perMillion converts OpenRouter's per token price string to USD per million tokens
+func perMillion(price string) float64 {
+	p, err := strconv.ParseFloat(price, 64)
+	if err != nil {
+		return 0
+	}
+	return p * 1e6
+}
+
+// withRequestTimeout applies the configured request timeout to ctx
+func (c timeouts) withRequestTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.requestTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.requestTimeout)
 }
 
 func (c *OpenRouterClient) Generate(ctx context.Context, modelName string, prompt string, history []Message) (string, error) {
+	ctx, cancel := c.withRequestTimeout(ctx)
+	defer cancel()
+
 	// create message array with user's prompt
 	var messages []Message
 
@@ -117,7 +660,7 @@ func (c *OpenRouterClient) Generate(ctx context.Context, modelName string, promp
 	// create request body
 	requestBody := OpenRouterRequest{
 		Model:    modelName,
-		Messages: messages,
+		Messages: cacheBreakpoints(messages, c.openRouter),
 		Stream:   false,
 	}
 
@@ -134,13 +677,10 @@ func (c *OpenRouterClient) Generate(ctx context.Context, modelName string, promp
 		return "", fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
-	req.Header.Set("HTTP-Referer", "https://github.com/scbenet/ask")
-	req.Header.Set("X-Title", "Ask CLI")
+	c.setHeaders(req)
 
 	// make http request
-	log.Printf("Sending request to openrouter for model : %s with %d messages", modelName, len(messages))
+	logging.From(ctx).Infof("sending request to openrouter for model %s with %d messages", modelName, len(messages))
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("HTTP request failed: %w", err)
@@ -155,127 +695,112 @@ func (c *OpenRouterClient) Generate(ctx context.Context, modelName string, promp
 
 	// check HTTP status code
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// parse response JSON
-	var openRouterResp OpenRouterResponse
-	if err := json.Unmarshal(body, &openRouterResp); err != nil {
-		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+		return "", &StatusError{Status: resp.StatusCode, Message: string(body)}
 	}
 
-	// check for API error
-	if openRouterResp.Error != nil {
-		return "", fmt.Errorf("API error: %s", openRouterResp.Error.Message)
-	}
-
-	// check if we have valid choices
-	if len(openRouterResp.Choices) == 0 {
-		return "", errors.New("no response choices returned")
-	}
-
-	// return first choice
-	return openRouterResp.Choices[0].Message.Content, nil
+	return decodeResponse(body)
 }
 
-func (c *OpenRouterClient) StreamGenerate(ctx context.Context, modelName string, historyWithLatestPrompt []Message, msgChan chan<- tea.Msg) {
+func (c *OpenRouterClient) StreamGenerate(ctx context.Context, req Request, msgChan chan<- tea.Msg) {
+	modelName, historyWithLatestPrompt := req.Model, req.Messages
 	go func() {
 		defer close(msgChan) // close channel when done to signal end of stream
 
+		ctx, cancelTimeout := c.withRequestTimeout(ctx)
+		defer cancelTimeout()
+		// the stall detector cancels with ErrStreamStalled as the cause so the
+		// resulting read error can be reported as a stall rather than a network error
+		ctx, cancelStall := context.WithCancelCause(ctx)
+		defer cancelStall(nil)
+
 		requestBody := OpenRouterRequest{
 			Model:    modelName,
-			Messages: historyWithLatestPrompt,
+			Messages: cacheBreakpoints(historyWithLatestPrompt, c.openRouter),
 			Stream:   true,
+			Tools:    req.Tools,
+		}
+		c.applyParams(&requestBody, req.Params)
+		if c.openRouter {
+			requestBody.Usage = &OpenRouterUsageParam{Include: true}
+		} else {
+			requestBody.StreamOptions = &StreamOptions{IncludeUsage: true}
 		}
 
 		jsonData, err := json.Marshal(requestBody)
 		if err != nil {
 			msgChan <- StreamErrorMsg{Err: fmt.Errorf("failed to marshal stream request: %w", err)}
+			return
 		}
 
-		req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL, bytes.NewBuffer(jsonData))
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL, bytes.NewBuffer(jsonData))
 		if err != nil {
 			msgChan <- StreamErrorMsg{Err: fmt.Errorf("failed to created stream HTTP request: %w", err)}
+			return
 		}
 
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
-		req.Header.Set("HTTP-Referer", "https://github.com/scbenet/ask")
-		req.Header.Set("X-Title", "Ask CLI")
+		c.setHeaders(httpReq)
 
-		log.Printf("sending streaming request to OpenRouter for model: %s with %d messages", modelName, len(historyWithLatestPrompt))
-		resp, err := c.httpClient.Do(req)
+		logging.From(ctx).Infof("[%s] sending streaming request to %s for model: %s with %d messages", RequestID(ctx), c.baseURL, modelName, len(historyWithLatestPrompt))
+		// the stall timer also covers waiting for the response headers
+		var stallTimer *time.Timer
+		if c.stallTimeout > 0 {
+			stallTimer = time.AfterFunc(c.stallTimeout, func() { cancelStall(ErrStreamStalled) })
+			defer stallTimer.Stop()
+		}
+
+		resp, err := c.httpClient.Do(httpReq)
 		if err != nil {
-			msgChan <- StreamErrorMsg{Err: fmt.Errorf("stream HTTP request failed: %w", err)}
+			msgChan <- StreamErrorMsg{Err: c.streamError(ctx, "stream HTTP request failed", err)}
 			return
 		}
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
 			bodyBytes, _ := io.ReadAll(resp.Body) // read body for error details
-			msgChan <- StreamErrorMsg{Err: fmt.Errorf("API stream request failed with status %d: %s", resp.StatusCode, string(bodyBytes))}
+			msgChan <- StreamErrorMsg{Err: &StatusError{Status: resp.StatusCode, Message: string(bodyBytes)}}
 			return
 		}
 
-		scanner := bufio.NewScanner(resp.Body)
-		var fullResponseContent strings.Builder
-		CHUNK_PREFIX := "data: " // data chunks are prefixed with this, indicates a valid response chunk
-
-		// track if we've seen a response error in a stream chunk so far
-		// this gives us a bit of leeway, will attempt to keep reading after the first bad chunk
-		// but if we encounter a second error, send an ErrorMsg and return
-		responseStreamingErrorSeen := false
-
-		for scanner.Scan() {
-			line := scanner.Text()
-			if line == "" || strings.HasPrefix(line, ":") {
-				continue
-			}
-
-			if strings.HasPrefix(line, CHUNK_PREFIX) {
-				jsonDataStr := strings.TrimPrefix(line, CHUNK_PREFIX)
-				if jsonDataStr == "[DONE]" {
-					log.Println("stream indicated [DONE]")
-					break
-				}
-
-				var chunk OpenRouterStreamChunk
-				if err := json.Unmarshal([]byte(jsonDataStr), &chunk); err != nil {
-					log.Printf("Error unmarshalling stream chunk JSON: '%s', data: %s", err, jsonDataStr)
-					if responseStreamingErrorSeen {
-						msgChan <- StreamErrorMsg{Err: fmt.Errorf("error unmarshalling stream chunk: %w (data: %s)", err, jsonDataStr)}
-						return
-					}
-
-					responseStreamingErrorSeen = true
-					continue
-				}
-
-				if chunk.Error != nil {
-					log.Printf("Error in stream chunk: %s", chunk.Error.Message)
-					msgChan <- StreamErrorMsg{Err: fmt.Errorf("API error in stream chunk: %s", chunk.Error.Message)}
-					return
-				}
-
-				if len(chunk.Choices) > 0 {
-					content := chunk.Choices[0].Delta.Content
-					if content != "" {
-						fullResponseContent.WriteString(content)
-						msgChan <- StreamChunkMsg{Content: content}
-					}
-					if chunk.Choices[0].FinishReason != nil {
-						log.Printf("stream chunk indicates FinishReason: %s", *chunk.Choices[0].FinishReason)
-					}
-				}
+		end, err := parseStream(ctx, resp.Body, func(content string) {
+			msgChan <- StreamChunkMsg{Content: content}
+		}, c.onLine(stallTimer, msgChan))
+		if err != nil {
+			var read readError
+			if errors.As(err, &read) {
+				err = c.streamError(ctx, "error reading stream", read.err)
 			}
+			msgChan <- StreamErrorMsg{Err: err}
+			return
 		}
+		msgChan <- end
+	}()
+}
 
-		if err := scanner.Err(); err != nil {
-			msgChan <- StreamErrorMsg{Err: fmt.Errorf("error reading stream: %w", err)}
-			return
+// onLine is called for every line of a stream: the stall timer is reset
+// and the app told the provider is still there, keepalive lines don't
+// carry anything else it would notice
+func (c timeouts) onLine(stallTimer *time.Timer, msgChan chan<- tea.Msg) func() {
+	var last time.Time
+	return func() {
+		if stallTimer != nil {
+			stallTimer.Reset(c.stallTimeout)
 		}
+		if now := time.Now(); now.Sub(last) >= keepaliveInterval {
+			last = now
+			msgChan <- StreamKeepaliveMsg{}
+		}
+	}
+}
 
-		log.Println("stream processing finished")
-		msgChan <- StreamEndMsg{FullResponse: fullResponseContent.String()}
-	}()
+// streamError explains why a stream failed, turning context cancellation by
+// the stall detector or request timeout into a readable message
+func (c timeouts) streamError(ctx context.Context, what string, err error) error {
+	cause := context.Cause(ctx)
+	switch {
+	case errors.Is(cause, ErrStreamStalled):
+		return fmt.Errorf("%w: no data received for %s", ErrStreamStalled, c.stallTimeout)
+	case errors.Is(cause, context.DeadlineExceeded):
+		return fmt.Errorf("request timed out after %s", c.requestTimeout)
+	}
+	return fmt.Errorf("%s: %w", what, err)
 }
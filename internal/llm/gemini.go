@@ -0,0 +1,326 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// GeminiClient talks to Google AI Studio's Gemini API directly, for people
+// with a GOOGLE_API_KEY rather than an OpenRouter account
+type GeminiClient struct {
+	timeouts
+	apiKey     string
+	httpClient *http.Client
+	baseURL    string
+}
+
+// gemini's request and response schema, only the parts we use
+
+type geminiRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Tools             []geminiTool            `json:"tools,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature     *float64              `json:"temperature,omitempty"`
+	MaxOutputTokens int                   `json:"maxOutputTokens,omitempty"`
+	ThinkingConfig  *geminiThinkingConfig `json:"thinkingConfig,omitempty"`
+	// structured output, the schema needs the mime type set
+	ResponseMimeType   string          `json:"responseMimeType,omitempty"`
+	ResponseJSONSchema json.RawMessage `json:"responseJsonSchema,omitempty"`
+	StopSequences      []string        `json:"stopSequences,omitempty"`
+	Seed               *int            `json:"seed,omitempty"`
+	FrequencyPenalty   *float64        `json:"frequencyPenalty,omitempty"`
+	PresencePenalty    *float64        `json:"presencePenalty,omitempty"`
+}
+
+type geminiThinkingConfig struct {
+	ThinkingBudget int `json:"thinkingBudget"`
+}
+
+// gemini takes a token budget for thinking rather than an effort level
+var geminiThinkingBudgets = map[string]int{
+	"low":    1024,
+	"medium": 8192,
+	"high":   24576,
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"` // "user" or "model"
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"` // reasoning summaries, not part of the answer
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []FunctionDefinition `json:"functionDeclarations"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content           geminiContent `json:"content"`
+		FinishReason      string        `json:"finishReason,omitempty"`
+		GroundingMetadata *struct {
+			GroundingChunks []struct {
+				Web *struct {
+					URI   string `json:"uri"`
+					Title string `json:"title"`
+				} `json:"web,omitempty"`
+			} `json:"groundingChunks"`
+		} `json:"groundingMetadata,omitempty"`
+	} `json:"candidates"`
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+		// gemini caches implicitly, this is how much of the prompt it found
+		CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	} `json:"usageMetadata,omitempty"`
+	Error      *OpenRouterResponseError `json:"error,omitempty"`
+	ResponseID string                   `json:"responseId,omitempty"`
+}
+
+func NewGeminiClient(opts ...Option) (*GeminiClient, error) {
+	apiKey := os.Getenv("GOOGLE_API_KEY")
+	if apiKey == "" {
+		apiKey = os.Getenv("GEMINI_API_KEY")
+	}
+	if apiKey == "" {
+		return nil, errors.New("GOOGLE_API_KEY environment variable not set")
+	}
+
+	c := &GeminiClient{
+		timeouts:   defaultTimeouts(),
+		apiKey:     apiKey,
+		httpClient: &http.Client{},
+		baseURL:    "https://generativelanguage.googleapis.com/v1beta/models/",
+	}
+	for _, opt := range opts {
+		opt(&c.timeouts)
+	}
+	return c, nil
+}
+
+// BaseURL is where the requests go
+func (c *GeminiClient) BaseURL() string {
+	return c.baseURL
+}
+
+func (c *GeminiClient) Generate(ctx context.Context, modelName string, prompt string, history []Message) (string, error) {
+	ctx, cancel := c.withRequestTimeout(ctx)
+	defer cancel()
+
+	messages := append(append([]Message{}, history...), Message{Role: "user", Content: prompt})
+	resp, err := c.do(ctx, modelName+":generateContent", Request{Model: modelName, Messages: messages})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	return decodeGeminiResponse(resp.Body)
+}
+
+func (c *GeminiClient) StreamGenerate(ctx context.Context, req Request, msgChan chan<- tea.Msg) {
+	go func() {
+		defer close(msgChan)
+
+		ctx, cancelTimeout := c.withRequestTimeout(ctx)
+		defer cancelTimeout()
+		ctx, cancelStall := context.WithCancelCause(ctx)
+		defer cancelStall(nil)
+
+		var stallTimer *time.Timer
+		if c.stallTimeout > 0 {
+			stallTimer = time.AfterFunc(c.stallTimeout, func() { cancelStall(ErrStreamStalled) })
+			defer stallTimer.Stop()
+		}
+
+		logging.From(ctx).Infof("[%s] sending streaming request to Gemini for model: %s with %d messages", RequestID(ctx), req.Model, len(req.Messages))
+		resp, err := c.do(ctx, req.Model+":streamGenerateContent?alt=sse", req)
+		if err != nil {
+			msgChan <- StreamErrorMsg{Err: c.streamError(ctx, "stream HTTP request failed", err)}
+			return
+		}
+		defer resp.Body.Close()
+
+		end, err := parseGeminiStream(ctx, resp.Body, func(content string) {
+			msgChan <- StreamChunkMsg{Content: content}
+		}, c.onLine(stallTimer, msgChan))
+		if err != nil {
+			var read readError
+			if errors.As(err, &read) {
+				err = c.streamError(ctx, "error reading stream", read.err)
+			}
+			msgChan <- StreamErrorMsg{Err: err}
+			return
+		}
+		msgChan <- end
+	}()
+}
+
+// UnsupportedParams names the settings gemini has no equivalent for
+func (c *GeminiClient) UnsupportedParams(p Params) []string {
+	var names []string
+	if p.RepetitionPenalty != nil {
+		names = append(names, "repetitionPenalty")
+	}
+	if p.MinP != nil {
+		names = append(names, "minP")
+	}
+	if len(p.LogitBias) > 0 {
+		names = append(names, "logitBias")
+	}
+	return names
+}
+
+// do sends req to the given model method and checks the status code
+func (c *GeminiClient) do(ctx context.Context, method string, req Request) (*http.Response, error) {
+	system, contents := geminiContents(req.Messages)
+	body := geminiRequest{Contents: contents, SystemInstruction: system}
+	if len(req.Tools) > 0 {
+		var decls []FunctionDefinition
+		for _, t := range req.Tools {
+			decls = append(decls, t.Function)
+		}
+		body.Tools = []geminiTool{{FunctionDeclarations: decls}}
+	}
+	if p := req.Params; p.Temperature != nil || p.MaxTokens > 0 || p.ReasoningEffort != "" || p.JSONSchema != nil ||
+		p.Seed != nil || p.FrequencyPenalty != nil || p.PresencePenalty != nil || len(p.Stop) > 0 {
+		body.GenerationConfig = &geminiGenerationConfig{
+			Temperature:      p.Temperature,
+			MaxOutputTokens:  p.MaxTokens,
+			StopSequences:    p.Stop,
+			Seed:             p.Seed,
+			FrequencyPenalty: p.FrequencyPenalty,
+			PresencePenalty:  p.PresencePenalty,
+		}
+		if budget, ok := geminiThinkingBudgets[p.ReasoningEffort]; ok {
+			body.GenerationConfig.ThinkingConfig = &geminiThinkingConfig{ThinkingBudget: budget}
+		}
+		if p.JSONSchema != nil {
+			body.GenerationConfig.ResponseMimeType = "application/json"
+			body.GenerationConfig.ResponseJSONSchema = p.JSONSchema
+		}
+	}
+
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+method, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", c.apiKey)
+	setRequestID(httpReq)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		var apiErr geminiResponse
+		if json.Unmarshal(bodyBytes, &apiErr) == nil && apiErr.Error != nil {
+			return nil, &StatusError{Status: resp.StatusCode, Message: apiErr.Error.Message}
+		}
+		return nil, &StatusError{Status: resp.StatusCode, Message: string(bodyBytes)}
+	}
+	return resp, nil
+}
+
+// geminiContents maps chat messages onto gemini's contents. system messages
+// become the system instruction, assistant turns are the "model" role and
+// tool results are function responses sent back as the user
+func geminiContents(messages []Message) (*geminiContent, []geminiContent) {
+	var system *geminiContent
+	var contents []geminiContent
+	// function responses are matched to calls by name, tool messages only carry the id
+	callNames := map[string]string{}
+
+	for _, m := range messages {
+		switch m.Role {
+		case "system":
+			if system == nil {
+				system = &geminiContent{}
+			}
+			system.Parts = append(system.Parts, geminiPart{Text: m.Content})
+
+		case "assistant":
+			content := geminiContent{Role: "model"}
+			if m.Content != "" {
+				content.Parts = append(content.Parts, geminiPart{Text: m.Content})
+			}
+			for _, call := range m.ToolCalls {
+				callNames[call.ID] = call.Function.Name
+				content.Parts = append(content.Parts, geminiPart{FunctionCall: &geminiFunctionCall{
+					Name: call.Function.Name,
+					Args: json.RawMessage(call.Function.Arguments),
+				}})
+			}
+			contents = append(contents, content)
+
+		case "tool":
+			part := geminiPart{FunctionResponse: &geminiFunctionResponse{
+				Name:     callNames[m.ToolCallID],
+				Response: map[string]any{"content": m.Content},
+			}}
+			// all responses to one round of calls go in a single turn
+			if n := len(contents); n > 0 && contents[n-1].Role == "user" && contents[n-1].Parts[0].FunctionResponse != nil {
+				contents[n-1].Parts = append(contents[n-1].Parts, part)
+			} else {
+				contents = append(contents, geminiContent{Role: "user", Parts: []geminiPart{part}})
+			}
+
+		default:
+			contents = append(contents, geminiContent{Role: "user", Parts: []geminiPart{{Text: m.Content}}})
+		}
+	}
+	return system, contents
+}
+
+// geminiToolCall converts a function call part, making up an id when gemini
+// doesn't send one since tool results are matched to calls by id
+func geminiToolCall(fc geminiFunctionCall, index int) ToolCall {
+	id := fc.ID
+	if id == "" {
+		id = fmt.Sprintf("gemini-call-%d-%d", time.Now().UnixNano(), index)
+	}
+	args := string(fc.Args)
+	if args == "" || args == "null" {
+		args = "{}"
+	}
+	return ToolCall{ID: id, Type: "function", Function: FunctionCall{Name: fc.Name, Arguments: args}}
+}
@@ -0,0 +1,272 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// Middleware wraps a client in behavior that's the same whatever the
+// provider, like logging or retries, so it's written once instead of in
+// every client
+type Middleware func(LLMClient) LLMClient
+
+// Chain wraps client in middleware. the first one is outermost, it sees a
+// request first and its response last
+func Chain(client LLMClient, middleware ...Middleware) LLMClient {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		client = middleware[i](client)
+	}
+	return client
+}
+
+// RateLimit sends every request through limiter, see LimitedClient
+func RateLimit(limiter *Limiter) Middleware {
+	return func(client LLMClient) LLMClient { return NewLimitedClient(client, limiter) }
+}
+
+// Retry retries streams that fail part way, see RetryingClient
+func Retry(attempts int, backoff time.Duration, c clock.Clock) Middleware {
+	return func(client LLMClient) LLMClient { return NewRetryingClient(client, attempts, backoff, c) }
+}
+
+// relay streams req from client to msgChan, handing every message to
+// observe on the way. it returns at once like the clients do
+func relay(ctx context.Context, client LLMClient, req Request, msgChan chan<- tea.Msg, observe func(tea.Msg)) {
+	go func() {
+		defer close(msgChan)
+		inner := make(chan tea.Msg)
+		go client.StreamGenerate(ctx, req, inner)
+		for msg := range inner {
+			observe(msg)
+			msgChan <- msg
+		}
+	}()
+}
+
+// Logging logs how every request to provider went: how long it took, the
+// tokens it used or why it failed
+func Logging(provider string) Middleware {
+	return func(client LLMClient) LLMClient { return loggingClient{client: client, provider: provider} }
+}
+
+type loggingClient struct {
+	client   LLMClient
+	provider string
+}
+
+func (c loggingClient) Generate(ctx context.Context, modelName string, prompt string, history []Message) (string, error) {
+	start := time.Now()
+	reply, err := c.client.Generate(ctx, modelName, prompt, history)
+	if err != nil {
+		logging.From(ctx).Errorf("[%s] %s: %s failed after %s: %v", RequestID(ctx), c.provider, modelName, time.Since(start).Round(time.Millisecond), err)
+	} else {
+		logging.From(ctx).Infof("[%s] %s: %s answered in %s", RequestID(ctx), c.provider, modelName, time.Since(start).Round(time.Millisecond))
+	}
+	return reply, err
+}
+
+func (c loggingClient) StreamGenerate(ctx context.Context, req Request, msgChan chan<- tea.Msg) {
+	start := time.Now()
+	var first time.Duration
+	relay(ctx, c.client, req, msgChan, func(msg tea.Msg) {
+		switch m := msg.(type) {
+		case StreamChunkMsg:
+			if first == 0 {
+				first = time.Since(start)
+			}
+		case StreamEndMsg:
+			details := ""
+			if m.Usage != nil {
+				details = fmt.Sprintf(", %d prompt and %d completion tokens", m.Usage.PromptTokens, m.Usage.CompletionTokens)
+				if m.Usage.CacheReadTokens > 0 || m.Usage.CacheWriteTokens > 0 {
+					details += fmt.Sprintf(" (%d read from and %d written to the prompt cache)", m.Usage.CacheReadTokens, m.Usage.CacheWriteTokens)
+				}
+			}
+			if m.ProviderID != "" {
+				details += ", provider id " + m.ProviderID
+			}
+			logging.From(ctx).Infof("[%s] %s: %s streamed in %s, first chunk after %s%s", RequestID(ctx), c.provider, req.Model,
+				time.Since(start).Round(time.Millisecond), first.Round(time.Millisecond), details)
+		case StreamErrorMsg:
+			logging.From(ctx).Errorf("[%s] %s: %s stream failed after %s: %v", RequestID(ctx), c.provider, req.Model, time.Since(start).Round(time.Millisecond), m.Err)
+		}
+	})
+}
+
+// redacted replaces whatever Redact matches
+const redacted = "[redacted]"
+
+// Redact replaces everything matching patterns in what is sent, e.g. keys
+// or internal hostnames pasted into a prompt, before it leaves the machine
+func Redact(patterns []*regexp.Regexp) Middleware {
+	return func(client LLMClient) LLMClient { return redactingClient{client: client, patterns: patterns} }
+}
+
+type redactingClient struct {
+	client   LLMClient
+	patterns []*regexp.Regexp
+}
+
+func (c redactingClient) redact(s string) string {
+	for _, p := range c.patterns {
+		s = p.ReplaceAllLiteralString(s, redacted)
+	}
+	return s
+}
+
+// redactMessages returns a copy of messages with their contents and the
+// arguments of their tool calls redacted, the caller's are left as they are
+func (c redactingClient) redactMessages(messages []Message) []Message {
+	out := slices.Clone(messages)
+	for i := range out {
+		out[i].Content = c.redact(out[i].Content)
+		if len(out[i].ToolCalls) == 0 {
+			continue
+		}
+		out[i].ToolCalls = slices.Clone(out[i].ToolCalls)
+		for j := range out[i].ToolCalls {
+			args := &out[i].ToolCalls[j].Function.Arguments
+			*args = c.redactArguments(*args)
+		}
+	}
+	return out
+}
+
+// redactArguments redacts the strings in JSON encoded tool call arguments,
+// so a match can't take a quote with it and leave JSON the provider rejects
+func (c redactingClient) redactArguments(args string) string {
+	if c.redact(args) == args {
+		return args
+	}
+	// numbers are kept as they were written
+	dec := json.NewDecoder(strings.NewReader(args))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return c.redact(args)
+	}
+	data, err := json.Marshal(c.redactJSON(v))
+	if err != nil {
+		return c.redact(args)
+	}
+	return string(data)
+}
+
+func (c redactingClient) redactJSON(v any) any {
+	switch v := v.(type) {
+	case string:
+		return c.redact(v)
+	case []any:
+		for i := range v {
+			v[i] = c.redactJSON(v[i])
+		}
+	case map[string]any:
+		redacted := make(map[string]any, len(v))
+		for k, val := range v {
+			redacted[c.redact(k)] = c.redactJSON(val)
+		}
+		return redacted
+	}
+	return v
+}
+
+func (c redactingClient) Generate(ctx context.Context, modelName string, prompt string, history []Message) (string, error) {
+	return c.client.Generate(ctx, modelName, c.redact(prompt), c.redactMessages(history))
+}
+
+func (c redactingClient) StreamGenerate(ctx context.Context, req Request, msgChan chan<- tea.Msg) {
+	req.Messages = c.redactMessages(req.Messages)
+	c.client.StreamGenerate(ctx, req, msgChan)
+}
+
+// Cache answers a Generate request it has seen before from memory, keeping
+// the last size answers. background jobs like classifying prompts often ask
+// the same thing twice. streams are never cached, asking again there means
+// wanting a different answer
+func Cache(size int) Middleware {
+	return func(client LLMClient) LLMClient {
+		return &cachingClient{client: client, size: size, answers: map[string]string{}}
+	}
+}
+
+type cachingClient struct {
+	client LLMClient
+	size   int
+
+	mu      sync.Mutex
+	answers map[string]string
+	order   []string // keys, oldest first
+}
+
+func cacheKey(modelName, prompt string, history []Message) string {
+	var b strings.Builder
+	// separated by a byte that doesn't show up in text
+	for _, m := range history {
+		fmt.Fprintf(&b, "%s\x00%s\x00", m.Role, m.Content)
+	}
+	fmt.Fprintf(&b, "%s\x00%s", modelName, prompt)
+	return b.String()
+}
+
+func (c *cachingClient) Generate(ctx context.Context, modelName string, prompt string, history []Message) (string, error) {
+	key := cacheKey(modelName, prompt, history)
+	c.mu.Lock()
+	answer, ok := c.answers[key]
+	c.mu.Unlock()
+	if ok {
+		return answer, nil
+	}
+
+	answer, err := c.client.Generate(ctx, modelName, prompt, history)
+	if err != nil {
+		return answer, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.answers[key]; !ok {
+		c.answers[key] = answer
+		c.order = append(c.order, key)
+		if len(c.order) > c.size {
+			delete(c.answers, c.order[0])
+			c.order = c.order[1:]
+		}
+	}
+	return answer, nil
+}
+
+func (c *cachingClient) StreamGenerate(ctx context.Context, req Request, msgChan chan<- tea.Msg) {
+	c.client.StreamGenerate(ctx, req, msgChan)
+}
+
+// Budget refuses Generate requests while exceeded returns an error, so
+// background jobs stop once the money is spent. streams aren't checked
+// here, the app asks the user whether to go over for those
+func Budget(exceeded func() error) Middleware {
+	return func(client LLMClient) LLMClient { return budgetClient{client: client, exceeded: exceeded} }
+}
+
+type budgetClient struct {
+	client   LLMClient
+	exceeded func() error
+}
+
+func (c budgetClient) Generate(ctx context.Context, modelName string, prompt string, history []Message) (string, error) {
+	if err := c.exceeded(); err != nil {
+		return "", err
+	}
+	return c.client.Generate(ctx, modelName, prompt, history)
+}
+
+func (c budgetClient) StreamGenerate(ctx context.Context, req Request, msgChan chan<- tea.Msg) {
+	c.client.StreamGenerate(ctx, req, msgChan)
+}
@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestChain runs middleware outermost first
+func TestChain(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(client LLMClient) LLMClient {
+			return Budget(func() error {
+				order = append(order, name)
+				return nil
+			})(client)
+		}
+	}
+	client := Chain(&MockLLMClient{}, mark("outer"), mark("inner"))
+	if _, err := client.Generate(context.Background(), "m", "hi", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 2 || order[0] != "outer" || order[1] != "inner" {
+		t.Fatalf("ran %v", order)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	var sent []Message
+	echo := &MockLLMClient{Reply: func(modelName, prompt string, history []Message) (string, error) {
+		sent = history
+		return prompt, nil
+	}}
+	client := Chain(echo, Redact([]*regexp.Regexp{regexp.MustCompile(`sk-\w+`)}))
+	history := []Message{{Role: "user", Content: "my key is sk-abc123"}}
+	reply, err := client.Generate(context.Background(), "m", "and sk-def456?", history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "and [redacted]?" || sent[0].Content != "my key is [redacted]" {
+		t.Fatalf("sent %q with %q", reply, sent[0].Content)
+	}
+	if history[0].Content != "my key is sk-abc123" {
+		t.Fatal("the caller's history was changed")
+	}
+}
+
+// TestRedactToolCalls redacts the arguments of tool calls sent back to the
+// provider, keeping them valid JSON
+func TestRedactToolCalls(t *testing.T) {
+	mock := &MockLLMClient{Streams: [][]tea.Msg{{StreamEndMsg{}}}}
+	client := Chain(mock, Redact([]*regexp.Regexp{regexp.MustCompile(`sk-\w+`), regexp.MustCompile(`\S+\.internal`)}))
+	call := ToolCall{ID: "call_1", Type: "function"}
+	call.Function.Name = "http_get"
+	call.Function.Arguments = `{"url":"http://db.internal","headers":{"Authorization":"Bearer sk-abc123"},"retries":12345678901234567890}`
+	messages := []Message{{Role: "assistant", ToolCalls: []ToolCall{call}}}
+
+	ch := make(chan tea.Msg)
+	go client.StreamGenerate(context.Background(), Request{Model: "m", Messages: messages}, ch)
+	for range ch {
+	}
+	sent := mock.Requests()[0].Messages[0].ToolCalls[0].Function.Arguments
+	want := `{"headers":{"Authorization":"Bearer [redacted]"},"retries":12345678901234567890,"url":"[redacted]"}`
+	if sent != want {
+		t.Fatalf("sent arguments %s, want %s", sent, want)
+	}
+	if messages[0].ToolCalls[0].Function.Arguments != call.Function.Arguments {
+		t.Fatal("the caller's tool calls were changed")
+	}
+}
+
+func TestCache(t *testing.T) {
+	echo := &MockLLMClient{}
+	client := Chain(echo, Cache(2))
+	for _, prompt := range []string{"a", "b", "a", "c", "a"} {
+		if reply, err := client.Generate(context.Background(), "m", prompt, nil); err != nil || reply != prompt {
+			t.Fatalf("%q answered %q, %v", prompt, reply, err)
+		}
+	}
+	// the second a is cached, c pushes the first a out
+	if n := len(echo.Prompts()); n != 4 {
+		t.Fatalf("%d requests were sent, want 4", n)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	spent := errors.New("spent")
+	echo := &MockLLMClient{}
+	if _, err := Chain(echo, Budget(func() error { return spent })).Generate(context.Background(), "m", "hi", nil); !errors.Is(err, spent) || len(echo.Prompts()) != 0 {
+		t.Fatalf("got %v after %d requests", err, len(echo.Prompts()))
+	}
+}
+
+// TestRequestIDs keeps the id a request was made with and stamps it on the
+// end of the stream
+func TestRequestIDs(t *testing.T) {
+	client := Chain(&MockLLMClient{Streams: [][]tea.Msg{
+		{StreamEndMsg{FullResponse: "hi"}},
+		{StreamErrorMsg{Err: errors.New("down")}},
+	}}, RequestIDs())
+	var ids []string
+	for _, ctx := range []context.Context{WithRequestID(context.Background(), "req_given"), context.Background()} {
+		ch := make(chan tea.Msg)
+		client.StreamGenerate(ctx, Request{}, ch)
+		for msg := range ch {
+			switch m := msg.(type) {
+			case StreamEndMsg:
+				ids = append(ids, m.RequestID)
+			case StreamErrorMsg:
+				ids = append(ids, m.RequestID)
+			}
+		}
+	}
+	if len(ids) != 2 || ids[0] != "req_given" || !strings.HasPrefix(ids[1], "req_") || len(ids[1]) != 20 {
+		t.Fatalf("ids are %q", ids)
+	}
+}
@@ -0,0 +1,72 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// MockLLMClient is an LLMClient answering from a script instead of a
+// provider, for testing code that talks to one. it's safe for concurrent use
+type MockLLMClient struct {
+	// Streams are what the StreamGenerate calls send, the first call gets the
+	// first one and so on. calls past the last one fail
+	Streams [][]tea.Msg
+	// Reply answers Generate, the prompt is echoed back if it's nil
+	Reply func(modelName, prompt string, history []Message) (string, error)
+
+	mu       sync.Mutex
+	requests []Request
+	prompts  []string
+}
+
+func (m *MockLLMClient) Generate(ctx context.Context, modelName string, prompt string, history []Message) (string, error) {
+	m.mu.Lock()
+	m.prompts = append(m.prompts, prompt)
+	m.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if m.Reply == nil {
+		return prompt, nil
+	}
+	return m.Reply(modelName, prompt, history)
+}
+
+func (m *MockLLMClient) StreamGenerate(ctx context.Context, req Request, msgChan chan<- tea.Msg) {
+	m.mu.Lock()
+	n := len(m.requests)
+	m.requests = append(m.requests, req)
+	m.mu.Unlock()
+	go func() {
+		defer close(msgChan)
+		if n >= len(m.Streams) {
+			msgChan <- StreamErrorMsg{Err: fmt.Errorf("mock: no stream for request %d", n+1)}
+			return
+		}
+		for _, msg := range m.Streams[n] {
+			if err := ctx.Err(); err != nil {
+				msgChan <- StreamErrorMsg{Err: err}
+				return
+			}
+			msgChan <- msg
+		}
+	}()
+}
+
+// Requests are the streams asked for so far
+func (m *MockLLMClient) Requests() []Request {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.requests)
+}
+
+// Prompts are the prompts Generate was called with so far
+func (m *MockLLMClient) Prompts() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.prompts)
+}
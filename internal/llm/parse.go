@@ -0,0 +1,280 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// maxEventSize bounds a single SSE line. a chunk can carry a whole function
+// call, the scanner's default of 64KB would fail the stream on those
+const maxEventSize = 4 * 1024 * 1024
+
+// errStreamCut is returned when a stream ends without saying it's finished,
+// which means the connection dropped and the response is incomplete
+var errStreamCut = errors.New("the stream ended before the response was finished")
+
+// readError is a failure to read the stream rather than a bad response,
+// the caller explains it with the request's context, see streamError
+type readError struct{ err error }
+
+func (e readError) Error() string { return e.err.Error() }
+func (e readError) Unwrap() error { return e.err }
+
+// newEventScanner returns a scanner over the lines of an SSE stream
+func newEventScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+	return scanner
+}
+
+// eventData returns the payload of an SSE data line. the space after the
+// colon is optional, comments and other fields are skipped
+func eventData(line string) (string, bool) {
+	data, ok := strings.CutPrefix(line, "data:")
+	if !ok {
+		return "", false
+	}
+	return strings.TrimPrefix(data, " "), true
+}
+
+// parseStream reads an OpenAI style chat completion stream from r. emit is
+// called with each piece of content as it arrives and onLine for every line,
+// e.g. to reset a stall timer. read errors are returned as readError
+func parseStream(ctx context.Context, r io.Reader, emit func(content string), onLine func()) (StreamEndMsg, error) {
+	var fullResponseContent strings.Builder
+	var usage *Usage
+	var providerID string
+	var sources []Source
+	var citations []string
+	var searchResults []PerplexitySearchResult
+	var toolCalls toolCallAccumulator
+	finished := false
+	finishReason := ""
+
+	// track if we've seen a response error in a stream chunk so far
+	// this gives us a bit of leeway, will attempt to keep reading after the first bad chunk
+	// but if we encounter a second error, give up
+	responseStreamingErrorSeen := false
+
+	scanner := newEventScanner(r)
+	for scanner.Scan() {
+		onLine()
+		jsonDataStr, ok := eventData(scanner.Text())
+		if !ok {
+			continue
+		}
+		if jsonDataStr == "[DONE]" {
+			logging.From(ctx).Printf("stream indicated [DONE]")
+			finished = true
+			break
+		}
+
+		var chunk OpenRouterStreamChunk
+		if err := json.Unmarshal([]byte(jsonDataStr), &chunk); err != nil {
+			logging.From(ctx).Errorf("unmarshalling stream chunk JSON: '%s', data: %s", err, jsonDataStr)
+			if responseStreamingErrorSeen {
+				return StreamEndMsg{}, fmt.Errorf("error unmarshalling stream chunk: %w (data: %s)", err, jsonDataStr)
+			}
+			responseStreamingErrorSeen = true
+			continue
+		}
+
+		if chunk.Error != nil {
+			logging.From(ctx).Errorf("in stream chunk: %s", chunk.Error.Message)
+			return StreamEndMsg{}, fmt.Errorf("API error in stream chunk: %s", chunk.Error.Message)
+		}
+
+		if chunk.Usage != nil {
+			usage = chunk.Usage.usage()
+		}
+		if chunk.ID != "" {
+			providerID = chunk.ID
+		}
+		if len(chunk.Citations) > 0 {
+			citations = chunk.Citations
+		}
+		if len(chunk.SearchResults) > 0 {
+			searchResults = chunk.SearchResults
+		}
+
+		if len(chunk.Choices) > 0 {
+			sources = sourcesFromAnnotations(sources, chunk.Choices[0].Delta.Annotations)
+			toolCalls.add(chunk.Choices[0].Delta.ToolCalls)
+			content := chunk.Choices[0].Delta.Content
+			if content != "" {
+				fullResponseContent.WriteString(content)
+				emit(content)
+			}
+			if choice := chunk.Choices[0]; choice.FinishReason != nil {
+				logging.From(ctx).Printf("stream chunk indicates FinishReason: %s", *choice.FinishReason)
+				finished = true
+				finishReason = *choice.FinishReason
+				if choice.NativeFinishReason == "stop_sequence" {
+					finishReason = FinishStopSequence
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return StreamEndMsg{}, readError{err}
+	}
+	if !finished {
+		return StreamEndMsg{}, errStreamCut
+	}
+
+	if len(sources) == 0 {
+		sources = sourcesFromCitations(citations, searchResults)
+	}
+
+	logging.From(ctx).Printf("stream processing finished")
+	return StreamEndMsg{
+		FullResponse: fullResponseContent.String(),
+		ProviderID:   providerID,
+		Usage:        usage,
+		Sources:      sources,
+		ToolCalls:    toolCalls.result(),
+		FinishReason: finishReason,
+	}, nil
+}
+
+// decodeResponse returns the content of a non-streaming chat completion
+func decodeResponse(body []byte) (string, error) {
+	var openRouterResp OpenRouterResponse
+	if err := json.Unmarshal(body, &openRouterResp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	// check for API error
+	if openRouterResp.Error != nil {
+		return "", fmt.Errorf("API error: %s", openRouterResp.Error.Message)
+	}
+
+	// check if we have valid choices
+	if len(openRouterResp.Choices) == 0 {
+		return "", errors.New("no response choices returned")
+	}
+
+	// return first choice
+	return openRouterResp.Choices[0].Message.Content, nil
+}
+
+// geminiFinishReasons maps gemini's reasons to the OpenAI style ones, gemini
+// says STOP for a stop sequence as well
+var geminiFinishReasons = map[string]string{
+	"STOP":       FinishStop,
+	"MAX_TOKENS": FinishLength,
+}
+
+// parseGeminiStream is parseStream for Gemini's streamGenerateContent
+func parseGeminiStream(ctx context.Context, r io.Reader, emit func(content string), onLine func()) (StreamEndMsg, error) {
+	var fullResponseContent strings.Builder
+	var usage *Usage
+	var providerID string
+	var sources []Source
+	var toolCalls []ToolCall
+	finished := false
+	finishReason := ""
+
+	scanner := newEventScanner(r)
+	for scanner.Scan() {
+		onLine()
+		data, ok := eventData(scanner.Text())
+		if !ok {
+			continue
+		}
+
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return StreamEndMsg{}, fmt.Errorf("error unmarshalling stream chunk: %w (data: %s)", err, data)
+		}
+		if chunk.Error != nil {
+			return StreamEndMsg{}, fmt.Errorf("API error in stream chunk: %s", chunk.Error.Message)
+		}
+		if chunk.ResponseID != "" {
+			providerID = chunk.ResponseID
+		}
+		if u := chunk.UsageMetadata; u != nil {
+			usage = &Usage{
+				PromptTokens:     u.PromptTokenCount,
+				CompletionTokens: u.CandidatesTokenCount,
+				TotalTokens:      u.TotalTokenCount,
+				CacheReadTokens:  u.CachedContentTokenCount,
+			}
+		}
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+
+		candidate := chunk.Candidates[0]
+		for _, part := range candidate.Content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				toolCalls = append(toolCalls, geminiToolCall(*part.FunctionCall, len(toolCalls)))
+			case part.Thought:
+				// reasoning summaries aren't shown
+			case part.Text != "":
+				fullResponseContent.WriteString(part.Text)
+				emit(part.Text)
+			}
+		}
+		if g := candidate.GroundingMetadata; g != nil {
+			for _, gc := range g.GroundingChunks {
+				if gc.Web != nil && gc.Web.URI != "" {
+					sources = append(sources, Source{Title: gc.Web.Title, URL: gc.Web.URI})
+				}
+			}
+		}
+		if candidate.FinishReason != "" {
+			logging.From(ctx).Printf("gemini stream finished: %s", candidate.FinishReason)
+			finished = true
+			finishReason = geminiFinishReasons[candidate.FinishReason]
+			if finishReason == "" {
+				finishReason = strings.ToLower(candidate.FinishReason)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return StreamEndMsg{}, readError{err}
+	}
+	if !finished {
+		return StreamEndMsg{}, errStreamCut
+	}
+
+	return StreamEndMsg{
+		FullResponse: fullResponseContent.String(),
+		ProviderID:   providerID,
+		Usage:        usage,
+		Sources:      sources,
+		ToolCalls:    toolCalls,
+		FinishReason: finishReason,
+	}, nil
+}
+
+// decodeGeminiResponse returns the text of a non-streaming Gemini response
+func decodeGeminiResponse(r io.Reader) (string, error) {
+	var geminiResp geminiResponse
+	if err := json.NewDecoder(r).Decode(&geminiResp); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if geminiResp.Error != nil {
+		return "", fmt.Errorf("API error: %s", geminiResp.Error.Message)
+	}
+	if len(geminiResp.Candidates) == 0 {
+		return "", errors.New("no response candidates returned")
+	}
+
+	var text strings.Builder
+	for _, part := range geminiResp.Candidates[0].Content.Parts {
+		if !part.Thought {
+			text.WriteString(part.Text)
+		}
+	}
+	return text.String(), nil
+}
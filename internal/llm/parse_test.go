@@ -0,0 +1,135 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+// provider output the parsers are fuzzed from, including the odd bits real
+// streams contain: keep-alive comments, usage-only chunks, tool call
+// fragments and errors
+var streamSeeds = []string{
+	"data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\" world\"},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n",
+	": OPENROUTER PROCESSING\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: {\"choices\":[],\"usage\":{\"prompt_tokens\":3,\"completion_tokens\":1,\"total_tokens\":4}}\n\ndata: [DONE]\n",
+	"data: {\"choices\":[{\"delta\":{\"tool_calls\":[{\"index\":0,\"id\":\"call_1\",\"function\":{\"name\":\"read_file\",\"arguments\":\"{\\\"pa\"}}]}}]}\n\ndata: {\"choices\":[{\"delta\":{\"tool_calls\":[{\"index\":0,\"function\":{\"arguments\":\"th\\\":\\\"a\\\"}\"}}]},\"finish_reason\":\"tool_calls\"}]}\n\ndata: [DONE]\n",
+	"data: {\"choices\":[{\"delta\":{\"content\":\"see\",\"annotations\":[{\"type\":\"url_citation\",\"url_citation\":{\"url\":\"https://example.com\",\"title\":\"ex\"}}]}}]}\n\ndata: [DONE]\n",
+	"data: {\"error\":{\"message\":\"overloaded\",\"code\":502}}\n\n",
+	"data:{\"choices\":[{\"delta\":{\"content\":\"no space\"},\"finish_reason\":\"stop\"}]}\n",
+	"data: {not json}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"x\"}}]}\n\ndata: [DONE]\n",
+	"data: {\"choices\":[{\"delta\":{\"content\":\"cut off\"}}]}\n\n",
+	"",
+}
+
+var geminiStreamSeeds = []string{
+	"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Hello\"}]}}]}\r\n\r\ndata: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\" world\"}]},\"finishReason\":\"STOP\"}],\"usageMetadata\":{\"promptTokenCount\":2,\"candidatesTokenCount\":2,\"totalTokenCount\":4}}\r\n\r\n",
+	"data: {\"candidates\":[{\"content\":{\"parts\":[{\"thought\":true,\"text\":\"hmm\"},{\"functionCall\":{\"name\":\"read_file\",\"args\":{\"path\":\"a\"}}}]},\"finishReason\":\"STOP\"}]}\n\n",
+	"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"x\"}]},\"groundingMetadata\":{\"groundingChunks\":[{\"web\":{\"uri\":\"https://example.com\",\"title\":\"ex\"}}]},\"finishReason\":\"STOP\"}]}\n\n",
+	"data: {\"error\":{\"message\":\"quota\"}}\n\n",
+	"data: {\"candidates\":[]}\n\n",
+}
+
+// quietLog keeps the parsers' debug logging out of the fuzzer output
+func quietLog(tb testing.TB) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	tb.Cleanup(func() { log.SetOutput(out) })
+}
+
+// checkStream holds for any stream: what was emitted adds up to the full
+// response, and tool calls always carry JSON arguments
+func checkStream(t *testing.T, parse func(context.Context, io.Reader, func(string), func()) (StreamEndMsg, error), data []byte) {
+	var emitted strings.Builder
+	end, err := parse(context.Background(), bytes.NewReader(data), func(content string) {
+		if content == "" {
+			t.Fatal("emitted empty content")
+		}
+		emitted.WriteString(content)
+	}, func() {})
+	if err != nil {
+		return
+	}
+	if emitted.String() != end.FullResponse {
+		t.Fatalf("emitted %q but the full response is %q", emitted.String(), end.FullResponse)
+	}
+	for _, call := range end.ToolCalls {
+		if call.Function.Arguments == "" {
+			t.Fatalf("tool call %+v has no arguments", call)
+		}
+	}
+}
+
+func FuzzParseStream(f *testing.F) {
+	quietLog(f)
+	for _, seed := range streamSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		checkStream(t, parseStream, data)
+	})
+}
+
+func FuzzParseGeminiStream(f *testing.F) {
+	quietLog(f)
+	for _, seed := range geminiStreamSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		checkStream(t, parseGeminiStream, data)
+	})
+}
+
+func FuzzDecodeResponse(f *testing.F) {
+	f.Add([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi"}}]}`))
+	f.Add([]byte(`{"error":{"message":"bad request","code":400}}`))
+	f.Add([]byte(`{"choices":[]}`))
+	f.Add([]byte(`{"choices":[{"message":null}]}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		content, err := decodeResponse(data)
+		if err != nil && content != "" {
+			t.Fatalf("returned %q along with %v", content, err)
+		}
+	})
+}
+
+func FuzzDecodeGeminiResponse(f *testing.F) {
+	f.Add([]byte(`{"candidates":[{"content":{"parts":[{"text":"hi"},{"thought":true,"text":"hmm"}]}}]}`))
+	f.Add([]byte(`{"error":{"message":"quota"}}`))
+	f.Add([]byte(`{"candidates":[{"content":null}]}`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		content, err := decodeGeminiResponse(bytes.NewReader(data))
+		if err != nil && content != "" {
+			t.Fatalf("returned %q along with %v", content, err)
+		}
+	})
+}
+
+// TestParseStreamLongEvent covers a chunk larger than bufio.Scanner's
+// default buffer, which used to fail the whole stream
+func TestParseStreamLongEvent(t *testing.T) {
+	quietLog(t)
+	long := strings.Repeat("a", 200*1024)
+	body := "data: {\"choices\":[{\"delta\":{\"content\":\"" + long + "\"},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n"
+	end, err := parseStream(context.Background(), strings.NewReader(body), func(string) {}, func() {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if end.FullResponse != long {
+		t.Fatalf("got %d bytes, want %d", len(end.FullResponse), len(long))
+	}
+}
+
+// TestParseStreamCut checks a stream that stops without finishing is an
+// error rather than a silently short response
+func TestParseStreamCut(t *testing.T) {
+	quietLog(t)
+	if _, err := parseStream(context.Background(), strings.NewReader(streamSeeds[7]), func(string) {}, func() {}); err != errStreamCut {
+		t.Fatalf("got %v, want errStreamCut", err)
+	}
+	if _, err := parseGeminiStream(context.Background(), strings.NewReader(geminiStreamSeeds[4]), func(string) {}, func() {}); err != errStreamCut {
+		t.Fatalf("gemini: got %v, want errStreamCut", err)
+	}
+}
@@ -0,0 +1,173 @@
+package llm
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/clock"
+)
+
+// RateLimitedMsg is sent on a stream that has to wait for the rate limit
+// before it is sent. Wait is 0 when it waits for other requests to finish
+type RateLimitedMsg struct {
+	Wait time.Duration
+}
+
+// Limiter keeps the requests of every conversation, compare pane and
+// background job under one budget: a number of requests and of tokens per
+// minute and a number in flight at once. requests over the budget wait
+// their turn in the order they were made, instead of failing with a 429
+type Limiter struct {
+	requests int // per minute, 0 for no limit
+	tokens   int // per minute, 0 for no limit
+	clock    clock.Clock
+
+	mu   sync.Mutex
+	sent []*reservation // sent or about to be, within the last minute
+	// one slot per request allowed in flight, nil for no limit
+	inFlight chan struct{}
+}
+
+// reservation is a request's place in the budget
+type reservation struct {
+	at     time.Time
+	tokens int
+}
+
+// NewLimiter returns a limiter allowing requests and tokens per minute and
+// concurrent requests at once, any of them 0 for no limit
+func NewLimiter(requests, tokens, concurrent int, c clock.Clock) *Limiter {
+	l := &Limiter{requests: requests, tokens: tokens, clock: c}
+	if concurrent > 0 {
+		l.inFlight = make(chan struct{}, concurrent)
+	}
+	return l
+}
+
+// reserve books the earliest time a request of tokens fits in the budget,
+// never before one booked earlier
+func (l *Limiter) reserve(tokens int) *reservation {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock.Now()
+	l.sent = slices.DeleteFunc(l.sent, func(r *reservation) bool { return !r.at.After(now.Add(-time.Minute)) })
+
+	at := now
+	if n := len(l.sent); n > 0 && l.sent[n-1].at.After(at) {
+		at = l.sent[n-1].at
+	}
+	for {
+		var window []*reservation
+		total := 0
+		for _, r := range l.sent {
+			if r.at.After(at.Add(-time.Minute)) {
+				window = append(window, r)
+				total += r.tokens
+			}
+		}
+		// a request over the whole token budget still goes, on its own
+		if len(window) == 0 ||
+			(l.requests <= 0 || len(window) < l.requests) && (l.tokens <= 0 || total+tokens <= l.tokens) {
+			break
+		}
+		at = window[0].at.Add(time.Minute)
+	}
+
+	r := &reservation{at: at, tokens: tokens}
+	l.sent = append(l.sent, r)
+	return r
+}
+
+// cancel gives a reservation's place back
+func (l *Limiter) cancel(r *reservation) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sent = slices.DeleteFunc(l.sent, func(s *reservation) bool { return s == r })
+}
+
+// settle corrects a reservation with the tokens the request actually used
+func (l *Limiter) settle(r *reservation, tokens int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r.tokens = tokens
+}
+
+// wait blocks until a request of tokens may be sent, calling waiting first
+// if it has to wait. done must be called once the request is over
+func (l *Limiter) wait(ctx context.Context, tokens int, waiting func(time.Duration)) (r *reservation, done func(), err error) {
+	r = l.reserve(tokens)
+	if delay := r.at.Sub(l.clock.Now()); delay > 0 {
+		waiting(delay)
+		select {
+		case <-l.clock.After(delay):
+		case <-ctx.Done():
+			l.cancel(r)
+			return nil, nil, ctx.Err()
+		}
+	}
+	if l.inFlight == nil {
+		return r, func() {}, nil
+	}
+	select {
+	case l.inFlight <- struct{}{}:
+	default:
+		waiting(0)
+		select {
+		case l.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			l.cancel(r)
+			return nil, nil, ctx.Err()
+		}
+	}
+	return r, func() { <-l.inFlight }, nil
+}
+
+// LimitedClient sends every request through a Limiter
+type LimitedClient struct {
+	client  LLMClient
+	limiter *Limiter
+}
+
+// NewLimitedClient limits the requests client makes with limiter
+func NewLimitedClient(client LLMClient, limiter *Limiter) *LimitedClient {
+	return &LimitedClient{client: client, limiter: limiter}
+}
+
+func (c *LimitedClient) Generate(ctx context.Context, modelName string, prompt string, history []Message) (string, error) {
+	tokens := EstimateMessageTokens(history) + EstimateTokens(prompt)
+	_, done, err := c.limiter.wait(ctx, tokens, func(time.Duration) {})
+	if err != nil {
+		return "", err
+	}
+	defer done()
+	return c.client.Generate(ctx, modelName, prompt, history)
+}
+
+// StreamGenerate waits for the limiter, telling the app with a
+// RateLimitedMsg, then streams from the wrapped client. the reservation is
+// corrected with the usage the provider reports
+func (c *LimitedClient) StreamGenerate(ctx context.Context, req Request, msgChan chan<- tea.Msg) {
+	go func() {
+		defer close(msgChan)
+		r, done, err := c.limiter.wait(ctx, EstimateMessageTokens(req.Messages), func(d time.Duration) {
+			msgChan <- RateLimitedMsg{Wait: d}
+		})
+		if err != nil {
+			msgChan <- StreamErrorMsg{Err: err}
+			return
+		}
+		defer done()
+
+		inner := make(chan tea.Msg)
+		go c.client.StreamGenerate(ctx, req, inner)
+		for msg := range inner {
+			if end, ok := msg.(StreamEndMsg); ok && end.Usage != nil {
+				c.limiter.settle(r, end.Usage.TotalTokens)
+			}
+			msgChan <- msg
+		}
+	}()
+}
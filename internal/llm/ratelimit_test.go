@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/scbenet/ask/internal/clock"
+)
+
+var start = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestLimiterRequests(t *testing.T) {
+	c := clock.NewFake(start)
+	l := NewLimiter(2, 0, 0, c)
+	for i, want := range []time.Duration{0, 0, time.Minute, time.Minute, 2 * time.Minute} {
+		if got := l.reserve(1).at.Sub(start); got != want {
+			t.Fatalf("request %d goes after %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestLimiterTokens(t *testing.T) {
+	c := clock.NewFake(start)
+	l := NewLimiter(0, 100, 0, c)
+	l.reserve(60)
+	c.Advance(10 * time.Second)
+	if got := l.reserve(60).at.Sub(start); got != time.Minute {
+		t.Fatalf("went after %s, want once the first request is a minute old", got)
+	}
+	// larger than the budget, goes once the minute is clear
+	if got := l.reserve(500).at.Sub(start); got != 2*time.Minute {
+		t.Fatalf("went after %s, want 2m", got)
+	}
+}
+
+func TestLimiterSettle(t *testing.T) {
+	c := clock.NewFake(start)
+	l := NewLimiter(0, 100, 0, c)
+	r := l.reserve(90)
+	// the response was shorter than estimated
+	l.settle(r, 20)
+	if got := l.reserve(60).at; !got.Equal(start) {
+		t.Fatalf("waited until %s after the usage was corrected", got)
+	}
+}
+
+// TestLimiterWait waits on the fake clock, and gives the place back when
+// the wait is cancelled
+func TestLimiterWait(t *testing.T) {
+	c := clock.NewFake(start)
+	l := NewLimiter(1, 0, 0, c)
+	if _, _, err := l.wait(context.Background(), 1, func(time.Duration) { t.Fatal("the first request waited") }); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	var waited time.Duration
+	go func() {
+		_, _, err := l.wait(ctx, 1, func(d time.Duration) { waited = d })
+		errs <- err
+	}()
+	for c.Waiting() == 0 {
+		runtime.Gosched()
+	}
+	cancel()
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if waited != time.Minute {
+		t.Fatalf("told to wait %s, want 1m", waited)
+	}
+
+	go func() {
+		_, _, err := l.wait(context.Background(), 1, func(time.Duration) {})
+		errs <- err
+	}()
+	for c.Waiting() < 2 {
+		runtime.Gosched()
+	}
+	c.Advance(time.Minute)
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLimiterConcurrent(t *testing.T) {
+	l := NewLimiter(0, 0, 1, clock.NewFake(start))
+	_, done, err := l.wait(context.Background(), 1, func(time.Duration) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waiting := make(chan time.Duration, 1)
+	errs := make(chan error)
+	go func() {
+		_, done, err := l.wait(context.Background(), 1, func(d time.Duration) { waiting <- d })
+		if err == nil {
+			done()
+		}
+		errs <- err
+	}()
+	if d := <-waiting; d != 0 {
+		t.Fatalf("told to wait %s for a request in flight", d)
+	}
+	done()
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type requestIDKey struct{}
+
+// NewRequestID returns a fresh id for a request, like "req_1f2e3d4c5b6a7988"
+func NewRequestID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "req_" + hex.EncodeToString(b)
+}
+
+// WithRequestID returns ctx carrying id, the request made with it is logged
+// and sent with that id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the id ctx carries, "" if none
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// setRequestID sends the id along, providers that log request headers can
+// then be searched for it
+func setRequestID(req *http.Request) {
+	if id := RequestID(req.Context()); id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+}
+
+// RequestIDs gives every request an id if its context has none, and stamps
+// the end or error of every stream with it
+func RequestIDs() Middleware {
+	return func(client LLMClient) LLMClient { return requestIDClient{client: client} }
+}
+
+type requestIDClient struct {
+	client LLMClient
+}
+
+// withID returns ctx with an id and the id
+func withID(ctx context.Context) (context.Context, string) {
+	if id := RequestID(ctx); id != "" {
+		return ctx, id
+	}
+	id := NewRequestID()
+	return WithRequestID(ctx, id), id
+}
+
+func (c requestIDClient) Generate(ctx context.Context, modelName string, prompt string, history []Message) (string, error) {
+	ctx, _ = withID(ctx)
+	return c.client.Generate(ctx, modelName, prompt, history)
+}
+
+func (c requestIDClient) StreamGenerate(ctx context.Context, req Request, msgChan chan<- tea.Msg) {
+	ctx, id := withID(ctx)
+	go func() {
+		defer close(msgChan)
+		inner := make(chan tea.Msg)
+		go c.client.StreamGenerate(ctx, req, inner)
+		for msg := range inner {
+			switch m := msg.(type) {
+			case StreamEndMsg:
+				m.RequestID = id
+				msg = m
+			case StreamErrorMsg:
+				m.RequestID = id
+				msg = m
+			}
+			msgChan <- msg
+		}
+	}()
+}
@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/clock"
+)
+
+// StreamRetryMsg is sent when a stream failed part way and is sent again.
+// a resumed stream continues the response, otherwise the response starts
+// over and what was streamed so far should be thrown away
+type StreamRetryMsg struct {
+	Attempt  int // 1 for the first retry
+	Attempts int // retries allowed
+	Err      error
+	Resumed  bool
+}
+
+// Retryable reports whether a request that failed with err may succeed if
+// sent again: the connection dropped or stalled, or the provider was
+// overloaded
+func Retryable(err error) bool {
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Status == 429 || status.Status >= 500
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrStreamStalled) || errors.Is(err, errStreamCut) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// RetryingClient sends a stream that failed part way again, up to attempts
+// times, waiting backoff before the first retry and twice as long before
+// each next one. requests marked Resumable continue from what was streamed
+// so far, the partial response is sent along as the start of the answer
+type RetryingClient struct {
+	client   LLMClient
+	attempts int
+	backoff  time.Duration
+	clock    clock.Clock
+}
+
+// NewRetryingClient retries the streams of client
+func NewRetryingClient(client LLMClient, attempts int, backoff time.Duration, c clock.Clock) *RetryingClient {
+	return &RetryingClient{client: client, attempts: attempts, backoff: backoff, clock: c}
+}
+
+// Generate isn't retried, its callers are background jobs that do without
+// an answer
+func (c *RetryingClient) Generate(ctx context.Context, modelName string, prompt string, history []Message) (string, error) {
+	return c.client.Generate(ctx, modelName, prompt, history)
+}
+
+func (c *RetryingClient) StreamGenerate(ctx context.Context, req Request, msgChan chan<- tea.Msg) {
+	go func() {
+		defer close(msgChan)
+		var partial strings.Builder
+		// the part of the response streamed before a resumed attempt
+		prefix := ""
+		for attempt := 0; ; attempt++ {
+			sent := req
+			if prefix != "" {
+				sent.Messages = append(slices.Clip(req.Messages), Message{Role: "assistant", Content: prefix})
+			}
+			inner := make(chan tea.Msg)
+			go c.client.StreamGenerate(ctx, sent, inner)
+
+			var failed error
+			for msg := range inner {
+				switch m := msg.(type) {
+				case StreamChunkMsg:
+					partial.WriteString(m.Content)
+					msgChan <- m
+				case StreamEndMsg:
+					m.FullResponse = prefix + m.FullResponse
+					msgChan <- m
+				case StreamErrorMsg:
+					// held back until it's clear there's no retry
+					failed = m.Err
+				default:
+					msgChan <- msg
+				}
+			}
+			if failed == nil {
+				return
+			}
+			if attempt >= c.attempts || ctx.Err() != nil || !Retryable(failed) {
+				msgChan <- StreamErrorMsg{Err: failed}
+				return
+			}
+
+			resume := req.Resumable && partial.Len() > 0
+			if resume {
+				prefix = partial.String()
+			} else {
+				prefix = ""
+				partial.Reset()
+			}
+			msgChan <- StreamRetryMsg{Attempt: attempt + 1, Attempts: c.attempts, Err: failed, Resumed: resume}
+			select {
+			case <-c.clock.After(c.backoff << attempt):
+			case <-ctx.Done():
+				msgChan <- StreamErrorMsg{Err: ctx.Err()}
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/clock"
+)
+
+// collect runs the request through a retrying client, advancing the clock
+// past every backoff
+func collect(t *testing.T, client *MockLLMClient, req Request) []tea.Msg {
+	t.Helper()
+	c := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	retrying := NewRetryingClient(client, 2, time.Second, c)
+	ch := make(chan tea.Msg)
+	retrying.StreamGenerate(context.Background(), req, ch)
+
+	var msgs []tea.Msg
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return msgs
+			}
+			msgs = append(msgs, msg)
+		default:
+			if c.Waiting() > 0 {
+				c.Advance(time.Minute)
+			}
+			runtime.Gosched()
+		}
+	}
+}
+
+func TestRetryResumes(t *testing.T) {
+	client := &MockLLMClient{Streams: [][]tea.Msg{
+		{StreamChunkMsg{Content: "Hel"}, StreamErrorMsg{Err: errStreamCut}},
+		{StreamChunkMsg{Content: "lo"}, StreamEndMsg{FullResponse: "lo"}},
+	}}
+	req := Request{Model: "m", Messages: []Message{{Role: "user", Content: "hi"}}, Resumable: true}
+	msgs := collect(t, client, req)
+
+	if retry, ok := msgs[1].(StreamRetryMsg); !ok || !retry.Resumed || retry.Attempt != 1 {
+		t.Fatalf("got %#v, want a resumed retry", msgs[1])
+	}
+	if end, ok := msgs[len(msgs)-1].(StreamEndMsg); !ok || end.FullResponse != "Hello" {
+		t.Fatalf("ended with %#v, want the whole response", msgs[len(msgs)-1])
+	}
+	resumed := client.Requests()[1].Messages
+	if last := resumed[len(resumed)-1]; last.Role != "assistant" || last.Content != "Hel" {
+		t.Fatalf("resumed with %+v, want the partial response", last)
+	}
+	if len(req.Messages) != 1 {
+		t.Fatal("the caller's messages were changed")
+	}
+}
+
+func TestRetryStartsOver(t *testing.T) {
+	client := &MockLLMClient{Streams: [][]tea.Msg{
+		{StreamChunkMsg{Content: "Hel"}, StreamErrorMsg{Err: io.ErrUnexpectedEOF}},
+		{StreamErrorMsg{Err: &StatusError{Status: 503, Message: "overloaded"}}},
+		{StreamChunkMsg{Content: "Hello"}, StreamEndMsg{FullResponse: "Hello"}},
+	}}
+	msgs := collect(t, client, Request{Model: "m", Messages: []Message{{Role: "user", Content: "hi"}}})
+
+	retries := 0
+	for _, msg := range msgs {
+		if retry, ok := msg.(StreamRetryMsg); ok {
+			retries++
+			if retry.Resumed {
+				t.Fatal("resumed a request that isn't resumable")
+			}
+		}
+	}
+	if retries != 2 {
+		t.Fatalf("retried %d times, want 2", retries)
+	}
+	if end, ok := msgs[len(msgs)-1].(StreamEndMsg); !ok || end.FullResponse != "Hello" {
+		t.Fatalf("ended with %#v", msgs[len(msgs)-1])
+	}
+	for _, req := range client.Requests() {
+		if len(req.Messages) != 1 {
+			t.Fatalf("sent %+v, want the original messages", req.Messages)
+		}
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	bad := &StatusError{Status: 400, Message: "bad request"}
+	client := &MockLLMClient{Streams: [][]tea.Msg{{StreamErrorMsg{Err: bad}}}}
+	msgs := collect(t, client, Request{Model: "m"})
+	if len(msgs) != 1 || !errors.Is(msgs[0].(StreamErrorMsg).Err, bad) {
+		t.Fatalf("got %#v, want the error without retrying", msgs)
+	}
+
+	cut := &MockLLMClient{Streams: [][]tea.Msg{
+		{StreamErrorMsg{Err: errStreamCut}},
+		{StreamErrorMsg{Err: errStreamCut}},
+		{StreamErrorMsg{Err: errStreamCut}},
+	}}
+	msgs = collect(t, cut, Request{Model: "m"})
+	if len(cut.Requests()) != 3 {
+		t.Fatalf("sent %d times, want the request and 2 retries", len(cut.Requests()))
+	}
+	if _, ok := msgs[len(msgs)-1].(StreamErrorMsg); !ok {
+		t.Fatalf("ended with %#v, want the error", msgs[len(msgs)-1])
+	}
+}
@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Router sends each request to a provider chosen by the model name. models
+// are written "provider:model" (e.g. "gemini:gemini-2.5-flash"), anything
+// without a known provider prefix goes to the default client
+type Router struct {
+	providers map[string]LLMClient
+	fallback  LLMClient
+}
+
+// NewRouter creates a router, fallback may be nil if every model is prefixed
+func NewRouter(fallback LLMClient) *Router {
+	return &Router{providers: map[string]LLMClient{}, fallback: fallback}
+}
+
+// Register adds a provider reachable with the "name:" model prefix
+func (r *Router) Register(name string, client LLMClient) {
+	r.providers[name] = client
+}
+
+// route picks the client for model and strips the provider prefix. openrouter
+// ids can contain a colon too ("...:free"), so only registered names count
+func (r *Router) route(model string) (LLMClient, string, error) {
+	if name, rest, ok := strings.Cut(model, ":"); ok {
+		if client, ok := r.providers[name]; ok {
+			return client, rest, nil
+		}
+	}
+	if r.fallback == nil {
+		return nil, "", fmt.Errorf("no provider configured for model %q", model)
+	}
+	return r.fallback, model, nil
+}
+
+func (r *Router) Generate(ctx context.Context, modelName string, prompt string, history []Message) (string, error) {
+	client, model, err := r.route(modelName)
+	if err != nil {
+		return "", err
+	}
+	return client.Generate(ctx, model, prompt, history)
+}
+
+func (r *Router) StreamGenerate(ctx context.Context, req Request, msgChan chan<- tea.Msg) {
+	client, model, err := r.route(req.Model)
+	if err != nil {
+		go func() {
+			defer close(msgChan)
+			msgChan <- StreamErrorMsg{Err: err}
+		}()
+		return
+	}
+	req.Model = model
+	client.StreamGenerate(ctx, req, msgChan)
+}
@@ -0,0 +1,323 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// fakeResponse is one answer of a fakeProvider
+type fakeResponse struct {
+	status int // 200 if unset
+	body   string
+}
+
+// fakeProvider is a local server answering each request with the next of
+// its responses, so the clients can be tested from the request they send to
+// the messages they emit
+type fakeProvider struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses []fakeResponse
+	bodies    [][]byte // of the requests, in order
+}
+
+func newFakeProvider(t *testing.T, responses ...fakeResponse) *fakeProvider {
+	p := &fakeProvider{responses: responses}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		p.mu.Lock()
+		n := len(p.bodies)
+		p.bodies = append(p.bodies, body)
+		p.mu.Unlock()
+		if n >= len(p.responses) {
+			http.Error(w, "no response left", http.StatusInternalServerError)
+			return
+		}
+		resp := p.responses[n]
+		if resp.status != 0 && resp.status != http.StatusOK {
+			http.Error(w, resp.body, resp.status)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, resp.body)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// fixture reads a recorded stream from testdata/stream
+func fixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "stream", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// streamAll collects what a stream emits, failing if it doesn't end
+func streamAll(t *testing.T, client LLMClient, req Request) []tea.Msg {
+	t.Helper()
+	ch := make(chan tea.Msg)
+	client.StreamGenerate(context.Background(), req, ch)
+	var msgs []tea.Msg
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-ch:
+			if !ok {
+				return msgs
+			}
+			msgs = append(msgs, msg)
+		case <-timeout:
+			t.Fatalf("the stream didn't end, got %#v so far", msgs)
+		}
+	}
+}
+
+// chunks joins the content of the chunk messages
+func chunks(msgs []tea.Msg) string {
+	var b strings.Builder
+	for _, msg := range msgs {
+		if m, ok := msg.(StreamChunkMsg); ok {
+			b.WriteString(m.Content)
+		}
+	}
+	return b.String()
+}
+
+var hello = Request{Model: "m", Messages: []Message{{Role: "user", Content: "hi"}}}
+
+// TestStreamGenerate streams the recorded responses through a client
+// talking to a local server: every stream ends in a single end or error
+// message after the chunks that made it
+func TestStreamGenerate(t *testing.T) {
+	quietLog(t)
+	for _, tt := range []struct {
+		fixture string
+		chunks  string
+		err     string // in the error the stream ends with, "" if it ends fine
+		check   func(t *testing.T, end StreamEndMsg)
+	}{
+		{fixture: "done.sse", chunks: "Hello world", check: func(t *testing.T, end StreamEndMsg) {
+			if end.Usage == nil || end.Usage.PromptTokens != 3 || end.Usage.CompletionTokens != 2 {
+				t.Errorf("usage %+v", end.Usage)
+			}
+			if end.ProviderID != "gen-1" {
+				t.Errorf("provider id %q", end.ProviderID)
+			}
+			if end.FinishReason != FinishStop {
+				t.Errorf("finish reason %q", end.FinishReason)
+			}
+		}},
+		{fixture: "done_only.sse", chunks: "no finish reason"},
+		{fixture: "malformed_once.sse", chunks: "one two"},
+		{fixture: "malformed_twice.sse", chunks: "one two", err: "error unmarshalling stream chunk"},
+		{fixture: "error_midstream.sse", chunks: "half an", err: "API error in stream chunk: provider overloaded"},
+		{fixture: "cut.sse", chunks: "cut off", err: errStreamCut.Error()},
+		{fixture: "perplexity_citations.sse", chunks: "Use channels [1] and pipelines [2].", check: func(t *testing.T, end StreamEndMsg) {
+			want := []Source{{Title: "Effective Go", URL: "https://go.dev/doc/effective_go"}, {URL: "https://go.dev/blog/pipelines"}}
+			if !slices.Equal(end.Sources, want) {
+				t.Errorf("sources %+v", end.Sources)
+			}
+		}},
+		{fixture: "tool_calls.sse", check: func(t *testing.T, end StreamEndMsg) {
+			if len(end.ToolCalls) != 1 || end.ToolCalls[0].Function.Name != "read_file" || end.ToolCalls[0].Function.Arguments != `{"path":"notes.txt"}` {
+				t.Errorf("tool calls %+v", end.ToolCalls)
+			}
+		}},
+	} {
+		t.Run(tt.fixture, func(t *testing.T) {
+			provider := newFakeProvider(t, fakeResponse{body: fixture(t, tt.fixture)})
+			msgs := streamAll(t, NewOpenAICompatibleClient(provider.URL, "key"), hello)
+			if got := chunks(msgs); got != tt.chunks {
+				t.Errorf("chunks %q, want %q", got, tt.chunks)
+			}
+			last := msgs[len(msgs)-1]
+			switch m := last.(type) {
+			case StreamEndMsg:
+				if tt.err != "" {
+					t.Fatalf("ended fine, want %q", tt.err)
+				}
+				if m.FullResponse != tt.chunks {
+					t.Errorf("full response %q, want %q", m.FullResponse, tt.chunks)
+				}
+				if tt.check != nil {
+					tt.check(t, m)
+				}
+			case StreamErrorMsg:
+				if tt.err == "" || !strings.Contains(m.Err.Error(), tt.err) {
+					t.Fatalf("failed with %v, want %q", m.Err, tt.err)
+				}
+			default:
+				t.Fatalf("ended with %#v", last)
+			}
+		})
+	}
+}
+
+// TestStreamGenerateRequest checks what's sent: the model, the messages
+// and asking for the usage
+func TestStreamGenerateRequest(t *testing.T) {
+	quietLog(t)
+	provider := newFakeProvider(t, fakeResponse{body: fixture(t, "done.sse")})
+	streamAll(t, NewOpenAICompatibleClient(provider.URL, "key"), hello)
+
+	var sent OpenRouterRequest
+	if err := json.Unmarshal(provider.bodies[0], &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Model != "m" || !sent.Stream || len(sent.Messages) != 1 || sent.Messages[0].Content != "hi" {
+		t.Errorf("sent %+v", sent)
+	}
+	if sent.StreamOptions == nil || !sent.StreamOptions.IncludeUsage {
+		t.Error("didn't ask for the usage")
+	}
+}
+
+// TestStreamGenerateResponseFormat asks for JSON matching a schema
+func TestStreamGenerateResponseFormat(t *testing.T) {
+	quietLog(t)
+	provider := newFakeProvider(t, fakeResponse{body: fixture(t, "done.sse")})
+	req := hello
+	req.JSONSchema = json.RawMessage(`{"type":"object"}`)
+	streamAll(t, NewOpenAICompatibleClient(provider.URL, "key"), req)
+
+	var sent OpenRouterRequest
+	if err := json.Unmarshal(provider.bodies[0], &sent); err != nil {
+		t.Fatal(err)
+	}
+	if f := sent.ResponseFormat; f == nil || f.Type != "json_schema" || f.JSONSchema == nil || string(f.JSONSchema.Schema) != `{"type":"object"}` {
+		t.Errorf("sent response format %+v", sent.ResponseFormat)
+	}
+}
+
+// TestStreamGenerateParams sends min_p and repetition_penalty to OpenRouter
+// only, the OpenAI API has no such settings
+func TestStreamGenerateParams(t *testing.T) {
+	quietLog(t)
+	minP, penalty := 0.05, 1.1
+	req := hello
+	req.Params = Params{MinP: &minP, RepetitionPenalty: &penalty, LogitBias: map[string]float64{"50256": -100}}
+	for _, openRouter := range []bool{true, false} {
+		provider := newFakeProvider(t, fakeResponse{body: fixture(t, "done.sse")})
+		client := NewOpenAICompatibleClient(provider.URL, "key")
+		client.openRouter = openRouter
+		streamAll(t, client, req)
+
+		var sent OpenRouterRequest
+		if err := json.Unmarshal(provider.bodies[0], &sent); err != nil {
+			t.Fatal(err)
+		}
+		if (sent.MinP != nil) != openRouter || (sent.RepetitionPenalty != nil) != openRouter || sent.LogitBias["50256"] != -100 {
+			t.Errorf("openRouter %v: sent %+v", openRouter, sent)
+		}
+		if unsupported := client.UnsupportedParams(req.Params); len(unsupported) > 0 == openRouter {
+			t.Errorf("openRouter %v: unsupported %v", openRouter, unsupported)
+		}
+	}
+}
+
+// TestStreamGeneratePromptCache sends cache breakpoints to OpenRouter on
+// the large messages asking for them, and reads back the cached tokens
+func TestStreamGeneratePromptCache(t *testing.T) {
+	quietLog(t)
+	large := strings.Repeat("context ", 1000)
+	req := Request{Model: "m", Messages: []Message{
+		{Role: "user", Content: large, Cache: true},
+		{Role: "assistant", Content: "ok"},
+		{Role: "user", Content: "small", Cache: true},
+	}}
+	for _, openRouter := range []bool{true, false} {
+		provider := newFakeProvider(t, fakeResponse{body: fixture(t, "cached.sse")})
+		client := NewOpenAICompatibleClient(provider.URL, "key")
+		client.openRouter = openRouter
+		msgs := streamAll(t, client, req)
+
+		var sent struct {
+			Messages []struct {
+				Content json.RawMessage `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.Unmarshal(provider.bodies[0], &sent); err != nil {
+			t.Fatal(err)
+		}
+		var parts []contentPart
+		cached := json.Unmarshal(sent.Messages[0].Content, &parts) == nil
+		if cached != openRouter || cached && (len(parts) != 1 || parts[0].Text != large || parts[0].CacheControl == nil || parts[0].CacheControl.Type != "ephemeral") {
+			t.Errorf("openRouter %v: sent %.100s", openRouter, sent.Messages[0].Content)
+		}
+		if string(sent.Messages[2].Content) != `"small"` {
+			t.Errorf("openRouter %v: a breakpoint on a small message: %s", openRouter, sent.Messages[2].Content)
+		}
+
+		end, ok := msgs[len(msgs)-1].(StreamEndMsg)
+		if !ok || end.Usage == nil || end.Usage.CacheReadTokens != 1800 || end.Usage.CacheWriteTokens != 150 || end.Usage.PromptTokens != 2000 {
+			t.Errorf("usage %+v", msgs[len(msgs)-1])
+		}
+	}
+	if !req.Messages[2].Cache {
+		t.Error("the request's messages were modified")
+	}
+}
+
+func TestStreamGenerateStatus(t *testing.T) {
+	quietLog(t)
+	provider := newFakeProvider(t, fakeResponse{status: http.StatusTooManyRequests, body: `{"error":{"message":"slow down"}}`})
+	msgs := streamAll(t, NewOpenAICompatibleClient(provider.URL, "key"), hello)
+	var status *StatusError
+	if len(msgs) != 1 || !errors.As(msgs[0].(StreamErrorMsg).Err, &status) || status.Status != http.StatusTooManyRequests {
+		t.Fatalf("got %#v, want a 429", msgs)
+	}
+	if !strings.Contains(status.Message, "slow down") {
+		t.Errorf("the provider's message is lost: %q", status.Message)
+	}
+}
+
+func TestGeminiStreamGenerate(t *testing.T) {
+	quietLog(t)
+	for _, tt := range []struct {
+		fixture string
+		chunks  string
+		err     string
+	}{
+		{fixture: "gemini_done.sse", chunks: "Hello world"},
+		{fixture: "gemini_error_midstream.sse", chunks: "half", err: "API error in stream chunk: quota exceeded"},
+	} {
+		t.Run(tt.fixture, func(t *testing.T) {
+			provider := newFakeProvider(t, fakeResponse{body: fixture(t, tt.fixture)})
+			client := &GeminiClient{timeouts: defaultTimeouts(), apiKey: "key", httpClient: provider.Client(), baseURL: provider.URL + "/"}
+			msgs := streamAll(t, client, hello)
+			if got := chunks(msgs); got != tt.chunks {
+				t.Errorf("chunks %q, want %q", got, tt.chunks)
+			}
+			switch m := msgs[len(msgs)-1].(type) {
+			case StreamEndMsg:
+				if tt.err != "" {
+					t.Fatalf("ended fine, want %q", tt.err)
+				}
+				if m.FullResponse != tt.chunks || m.ProviderID != "resp-1" || m.Usage == nil || m.Usage.CompletionTokens != 2 {
+					t.Errorf("ended with %+v", m)
+				}
+			case StreamErrorMsg:
+				if tt.err == "" || !strings.Contains(m.Err.Error(), tt.err) {
+					t.Fatalf("failed with %v, want %q", m.Err, tt.err)
+				}
+			}
+		})
+	}
+}
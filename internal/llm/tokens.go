@@ -0,0 +1,39 @@
+package llm
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// EstimateTokens approximates how many tokens s is for a typical BPE
+// tokenizer: about 4 characters per token for latin text, while CJK and
+// similar scripts come close to a token per character. good enough for
+// cost previews, providers report the real count afterwards
+func EstimateTokens(s string) int {
+	if s == "" {
+		return 0
+	}
+	latin, other := 0, 0
+	for _, r := range s {
+		if r < utf8.RuneSelf || unicode.Is(unicode.Latin, r) {
+			latin++
+		} else {
+			other++
+		}
+	}
+	return (latin+3)/4 + other
+}
+
+// EstimateMessageTokens approximates the prompt size of messages, including
+// a few tokens of overhead each for the role and formatting
+func EstimateMessageTokens(messages []Message) int {
+	const perMessage = 4
+	total := 0
+	for _, m := range messages {
+		total += perMessage + EstimateTokens(m.Content)
+		for _, call := range m.ToolCalls {
+			total += EstimateTokens(call.Function.Name) + EstimateTokens(call.Function.Arguments)
+		}
+	}
+	return total
+}
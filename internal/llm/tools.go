@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"sort"
+	"strings"
+)
+
+// ToolDefinition describes a function the model may call, in the OpenAI
+// tools format OpenRouter accepts
+type ToolDefinition struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+type FunctionDefinition struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+// ToolCall is a function call requested by the model
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON encoded arguments
+}
+
+// streamed tool calls arrive in fragments keyed by index, the first fragment
+// carries the id and name and later ones append to the arguments
+type OpenRouterToolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments,omitempty"`
+	} `json:"function"`
+}
+
+// toolCallAccumulator stitches streamed tool call fragments back together
+type toolCallAccumulator struct {
+	calls map[int]*ToolCall
+	args  map[int]*strings.Builder
+}
+
+func (a *toolCallAccumulator) add(deltas []OpenRouterToolCallDelta) {
+	if a.calls == nil {
+		a.calls = map[int]*ToolCall{}
+		a.args = map[int]*strings.Builder{}
+	}
+	for _, d := range deltas {
+		call, ok := a.calls[d.Index]
+		if !ok {
+			call = &ToolCall{Type: "function"}
+			a.calls[d.Index] = call
+			a.args[d.Index] = &strings.Builder{}
+		}
+		if d.ID != "" {
+			call.ID = d.ID
+		}
+		if d.Function.Name != "" {
+			call.Function.Name = d.Function.Name
+		}
+		a.args[d.Index].WriteString(d.Function.Arguments)
+	}
+}
+
+// result returns the completed calls in index order
+func (a *toolCallAccumulator) result() []ToolCall {
+	if len(a.calls) == 0 {
+		return nil
+	}
+	indexes := make([]int, 0, len(a.calls))
+	for i := range a.calls {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	calls := make([]ToolCall, 0, len(indexes))
+	for _, i := range indexes {
+		call := *a.calls[i]
+		call.Function.Arguments = a.args[i].String()
+		if call.Function.Arguments == "" {
+			call.Function.Arguments = "{}"
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
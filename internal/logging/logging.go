@@ -0,0 +1,274 @@
+// Package logging writes the standard logger to a rotated log file under the
+// XDG state directory, filtered by level.
+//
+// plain log.Printf calls are debug output and only written with -debug.
+// Errorf and Infof tag their lines so the writer can tell them apart, which
+// keeps every package on the standard logger and lets incognito mode silence
+// all of it with log.SetOutput. code that takes a context logs through
+// From(ctx) instead, so a program embedding ask can hand it a logger of
+// its own with WithLogger
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/paths"
+	"github.com/scbenet/ask/internal/vfs"
+)
+
+// Level is how much gets logged, each level includes the ones before it
+type Level int
+
+const (
+	Off Level = iota
+	Error
+	Info
+	Debug
+)
+
+var levelNames = map[Level]string{Error: "error", Info: "info", Debug: "debug"}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return "off"
+}
+
+// ParseLevel reads a level name as used in the config
+func ParseLevel(s string) (Level, error) {
+	switch s {
+	case "off":
+		return Off, nil
+	case "error":
+		return Error, nil
+	case "info":
+		return Info, nil
+	case "debug":
+		return Debug, nil
+	}
+	return Off, fmt.Errorf("unknown log level %q, use off, error, info or debug", s)
+}
+
+// tags mark the level of a line on its way through the standard logger
+var tags = map[Level][]byte{Error: []byte("\x00error "), Info: []byte("\x00info ")}
+
+// Errorf logs something that went wrong
+func Errorf(format string, args ...any) {
+	output(log.Default(), Error, format, args...)
+}
+
+// Infof logs something worth keeping a record of, like requests sent to a
+// provider, but not every step of the way
+func Infof(format string, args ...any) {
+	output(log.Default(), Info, format, args...)
+}
+
+func output(l *log.Logger, level Level, format string, args ...any) {
+	prefix := string(tags[level])
+	if _, ok := l.Writer().(*Writer); !ok {
+		// e.g. subcommands logging to stderr, keep it readable
+		prefix = level.String() + ": "
+	}
+	// the caller of Errorf or Infof, in case flags ask for file names
+	l.Output(3, prefix+fmt.Sprintf(format, args...))
+}
+
+// Logger logs like the package's functions, to a logger of its own
+type Logger struct{ l *log.Logger }
+
+// New logs to l, nil discards everything
+func New(l *log.Logger) Logger {
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+	return Logger{l}
+}
+
+// Errorf logs something that went wrong
+func (lg Logger) Errorf(format string, args ...any) {
+	output(lg.logger(), Error, format, args...)
+}
+
+// Infof logs something worth keeping a record of
+func (lg Logger) Infof(format string, args ...any) {
+	output(lg.logger(), Info, format, args...)
+}
+
+// Printf logs debug output, like log.Printf
+func (lg Logger) Printf(format string, args ...any) {
+	lg.logger().Output(2, fmt.Sprintf(format, args...))
+}
+
+// logger is the standard logger for the zero Logger
+func (lg Logger) logger() *log.Logger {
+	if lg.l == nil {
+		return log.Default()
+	}
+	return lg.l
+}
+
+type loggerKey struct{}
+
+// WithLogger has the code given ctx log to lg instead of the standard logger
+func WithLogger(ctx context.Context, lg Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, lg)
+}
+
+// From returns the logger set on ctx with WithLogger, the standard logger
+// if there's none
+func From(ctx context.Context) Logger {
+	lg, _ := ctx.Value(loggerKey{}).(Logger)
+	return lg
+}
+
+// DefaultPath returns where the log is written, ask.log in paths.State
+func DefaultPath() (string, error) {
+	dir, err := paths.State()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ask.log"), nil
+}
+
+// Writer appends the lines at or above its level to a log file. the file is
+// only created once there's something to write, and rotated to path.1,
+// path.2... when it grows past maxSize
+type Writer struct {
+	path     string
+	level    Level
+	maxSize  int64
+	maxFiles int
+
+	fs    vfs.FS
+	clock clock.Clock
+
+	mu   sync.Mutex
+	file vfs.File
+	size int64
+}
+
+// Option configures a Writer
+type Option func(*Writer)
+
+// WithFS writes the log to fsys instead of the disk
+func WithFS(fsys vfs.FS) Option {
+	return func(w *Writer) { w.fs = fsys }
+}
+
+// WithClock timestamps lines with c
+func WithClock(c clock.Clock) Option {
+	return func(w *Writer) { w.clock = c }
+}
+
+// NewWriter returns a writer for the log at path. maxSize 0 never rotates,
+// maxFiles is how many rotated logs are kept
+func NewWriter(path string, level Level, maxSize int64, maxFiles int, opts ...Option) *Writer {
+	w := &Writer{path: path, level: level, maxSize: maxSize, maxFiles: maxFiles, fs: vfs.OS, clock: clock.System}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Start sends the standard logger to a new Writer. the writer adds the time
+// itself, after reading the level tag at the start of each line
+func Start(path string, level Level, maxSize int64, maxFiles int) *Writer {
+	w := NewWriter(path, level, maxSize, maxFiles)
+	log.SetFlags(0)
+	log.SetOutput(w)
+	return w
+}
+
+// Write logs one line from the standard logger
+func (w *Writer) Write(p []byte) (int, error) {
+	level, msg := Debug, p
+	for l, tag := range tags {
+		if bytes.HasPrefix(p, tag) {
+			level, msg = l, p[len(tag):]
+			break
+		}
+	}
+	if level > w.level {
+		return len(p), nil
+	}
+
+	line := fmt.Sprintf("%s %-5s %s", w.clock.Now().Format("2006-01-02 15:04:05.000"), level, msg)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(line)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := io.WriteString(w.file, line)
+	w.size += int64(n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write log: %w", err)
+	}
+	return len(p), nil
+}
+
+func (w *Writer) open() error {
+	if err := w.fs.MkdirAll(filepath.Dir(w.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := w.fs.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	w.file, w.size = f, info.Size()
+	return nil
+}
+
+// rotate shifts path to path.1, path.1 to path.2 and so on, dropping the
+// oldest, and starts a new file
+func (w *Writer) rotate() error {
+	w.file.Close()
+	w.file = nil
+	rotated := func(n int) string { return w.path + "." + strconv.Itoa(n) }
+	if w.maxFiles <= 0 {
+		w.fs.Remove(w.path)
+		return w.open()
+	}
+	w.fs.Remove(rotated(w.maxFiles))
+	for n := w.maxFiles - 1; n > 0; n-- {
+		// most of these don't exist until the log has rotated a few times
+		w.fs.Rename(rotated(n), rotated(n+1))
+	}
+	if err := w.fs.Rename(w.path, rotated(1)); err != nil {
+		return fmt.Errorf("failed to rotate log: %w", err)
+	}
+	return w.open()
+}
+
+// Close closes the log file, if it was ever opened
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/vfs"
+)
+
+func TestWriterLevels(t *testing.T) {
+	fsys := vfs.NewMem(nil)
+	c := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local))
+	w := NewWriter("/state/ask.log", Info, 0, 0, WithFS(fsys), WithClock(c))
+	defer w.Close()
+
+	w.Write([]byte("debug line\n"))
+	w.Write([]byte(string(tags[Info]) + "info line\n"))
+	w.Write([]byte(string(tags[Error]) + "error line\n"))
+
+	data, err := fsys.ReadFile("/state/ask.log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2025-01-01 12:00:00.000 info  info line\n2025-01-01 12:00:00.000 error error line\n"
+	if string(data) != want {
+		t.Fatalf("log is\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestWriterRotation(t *testing.T) {
+	fsys := vfs.NewMem(nil)
+	c := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local))
+	// every line is 40 bytes, two fit in a file
+	w := NewWriter("/state/ask.log", Debug, 80, 2, WithFS(fsys), WithClock(c))
+	defer w.Close()
+
+	for i := range 7 {
+		w.Write([]byte(strings.Repeat(string(rune('a'+i)), 9) + "\n"))
+	}
+
+	for path, want := range map[string]string{
+		"/state/ask.log":   "g",
+		"/state/ask.log.1": "ef",
+		"/state/ask.log.2": "cd",
+	} {
+		data, err := fsys.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got strings.Builder
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			got.WriteByte(line[len(line)-1])
+		}
+		if got.String() != want {
+			t.Errorf("%s has lines %q, want %q", path, got.String(), want)
+		}
+	}
+	if _, err := fsys.Stat("/state/ask.log.3"); err == nil {
+		t.Error("kept more than 2 rotated logs")
+	}
+}
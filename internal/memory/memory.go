@@ -0,0 +1,201 @@
+package memory
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scbenet/ask/internal/paths"
+)
+
+// Memory is a short fact about the user, remembered across sessions
+type Memory struct {
+	ID      string    `json:"id"`
+	Text    string    `json:"text"`
+	Source  string    `json:"source"` // "user" for /remember, "model" when saved by a tool call
+	Created time.Time `json:"created"`
+}
+
+// maxLength keeps memories to a sentence or two, they are sent with every request
+const maxLength = 500
+
+// Store keeps all memories in a single JSON file. it is safe for concurrent
+// use since tool calls run in their own goroutines
+type Store struct {
+	path     string
+	mu       sync.Mutex
+	memories []Memory
+	paused   bool // no new memories are added while paused, see Pause
+}
+
+// DefaultPath returns where memories are stored, memories.json in
+// paths.Data
+func DefaultPath() (string, error) {
+	dir, err := paths.Data()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "memories.json"), nil
+}
+
+// Open loads the memories stored at path, a missing file is an empty store
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memories: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.memories); err != nil {
+		return nil, fmt.Errorf("failed to parse memories %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// List returns a copy of all memories, oldest first
+func (s *Store) List() []Memory {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Memory(nil), s.memories...)
+}
+
+// Add saves a new memory
+func (s *Store) Add(text, source string) (Memory, error) {
+	text, err := clean(text)
+	if err != nil {
+		return Memory{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paused {
+		return Memory{}, errors.New("memories are paused in incognito mode")
+	}
+	for _, m := range s.memories {
+		if strings.EqualFold(m.Text, text) {
+			return m, nil
+		}
+	}
+	m := Memory{ID: newID(), Text: text, Source: source, Created: time.Now()}
+	s.memories = append(s.memories, m)
+	if err := s.save(); err != nil {
+		s.memories = s.memories[:len(s.memories)-1]
+		return Memory{}, err
+	}
+	return m, nil
+}
+
+// Pause stops new memories from being added, existing ones are still used
+// and can be edited
+func (s *Store) Pause(paused bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = paused
+}
+
+// Update replaces the text of the memory with the given id
+func (s *Store) Update(id, text string) error {
+	text, err := clean(text)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(id)
+	if i < 0 {
+		return fmt.Errorf("memory %s not found", id)
+	}
+	previous := s.memories[i].Text
+	s.memories[i].Text = text
+	if err := s.save(); err != nil {
+		s.memories[i].Text = previous
+		return err
+	}
+	return nil
+}
+
+// Delete forgets the memory with the given id
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.index(id)
+	if i < 0 {
+		return fmt.Errorf("memory %s not found", id)
+	}
+	previous := s.memories
+	s.memories = append(append([]Memory(nil), s.memories[:i]...), s.memories[i+1:]...)
+	if err := s.save(); err != nil {
+		s.memories = previous
+		return err
+	}
+	return nil
+}
+
+// Prompt renders the memories as a system prompt section, "" when there are none
+func (s *Store) Prompt() string {
+	memories := s.List()
+	if len(memories) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Things you know about the user from earlier conversations, take them into account where relevant:\n")
+	for _, m := range memories {
+		b.WriteString("- " + m.Text + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func (s *Store) index(id string) int {
+	for i, m := range s.memories {
+		if m.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save writes all memories, callers hold mu
+func (s *Store) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("failed to create memory directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s.memories, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal memories: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write memories: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to save memories: %w", err)
+	}
+	return nil
+}
+
+func clean(text string) (string, error) {
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return "", errors.New("memory is empty")
+	}
+	if len([]rune(text)) > maxLength {
+		return "", fmt.Errorf("memory is too long, keep it under %d characters", maxLength)
+	}
+	return text, nil
+}
+
+func newID() string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
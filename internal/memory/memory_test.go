@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ask", "memories.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Prompt() != "" {
+		t.Fatal("an empty store has a prompt")
+	}
+
+	m, err := s.Add("  Uses   Arch\nLinux ", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Text != "Uses Arch Linux" || m.Source != "user" || m.ID == "" {
+		t.Fatalf("added %+v", m)
+	}
+	if again, err := s.Add("uses arch linux", "model"); err != nil || again.ID != m.ID {
+		t.Fatalf("a duplicate was added as %+v (%v)", again, err)
+	}
+	other, err := s.Add("Prefers tabs", "model")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Update(m.ID, "Uses NixOS"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update("missing", "x"); err == nil {
+		t.Error("updated a memory that doesn't exist")
+	}
+	if err := s.Delete(other.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(other.ID); err == nil {
+		t.Error("deleted a memory twice")
+	}
+
+	// everything was saved as it happened
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.List(); len(got) != 1 || got[0].Text != "Uses NixOS" {
+		t.Fatalf("reopened store has %+v", got)
+	}
+	if want := "- Uses NixOS"; !strings.HasSuffix(reopened.Prompt(), want) {
+		t.Errorf("prompt is %q", reopened.Prompt())
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("memories file is %v (%v)", info.Mode(), err)
+	}
+}
+
+func TestClean(t *testing.T) {
+	tests := map[string]string{
+		"fact":                           "fact",
+		" two\twords\n":                  "two words",
+		"":                               "",
+		"   ":                            "",
+		strings.Repeat("a", maxLength):   strings.Repeat("a", maxLength),
+		strings.Repeat("é", maxLength+1): "",
+	}
+	for in, want := range tests {
+		got, err := clean(in)
+		if (err != nil) != (want == "") || got != want {
+			t.Errorf("clean(%.20q) = %q, %v", in, got, err)
+		}
+	}
+}
+
+func TestPause(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "memories.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Pause(true)
+	if _, err := s.Add("a fact", "user"); err == nil {
+		t.Fatal("added a memory while paused")
+	}
+	s.Pause(false)
+	if _, err := s.Add("a fact", "user"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSaveFailureKeepsMemories(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(filepath.Join(dir, "memories.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.Add("kept", "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the temporary file can't be written where a directory is in the way
+	if err := os.Mkdir(filepath.Join(dir, "memories.json.tmp"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Add("lost", "user"); err == nil {
+		t.Fatal("saving into a directory succeeded")
+	}
+	if err := s.Update(m.ID, "changed"); err == nil {
+		t.Fatal("saving into a directory succeeded")
+	}
+	if err := s.Delete(m.ID); err == nil {
+		t.Fatal("saving into a directory succeeded")
+	}
+	if got := s.List(); len(got) != 1 || got[0].Text != "kept" {
+		t.Errorf("failed saves left %+v", got)
+	}
+}
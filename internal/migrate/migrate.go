@@ -0,0 +1,195 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// Migration upgrades on-disk data from Version-1 to Version
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(root string) error
+}
+
+// Migrator applies migrations to the data under Root. before anything is
+// changed the directories in Backup are copied aside so a failed or unwanted
+// upgrade can be rolled back
+type Migrator struct {
+	Root       string
+	Migrations []Migration
+	// Backup lists the directories (relative to Root) the migrations touch
+	Backup []string
+	// Fresh reports whether Root holds no data yet, in which case it is
+	// stamped with the latest version instead of being migrated
+	Fresh func() bool
+}
+
+const versionFile = "schema_version"
+
+func (m *Migrator) latest() int {
+	latest := 0
+	for _, mig := range m.Migrations {
+		latest = max(latest, mig.Version)
+	}
+	return latest
+}
+
+func (m *Migrator) backupDir() string {
+	return filepath.Join(m.Root, "backups")
+}
+
+// Current returns the schema version of the data on disk
+func (m *Migrator) Current() (int, error) {
+	data, err := os.ReadFile(filepath.Join(m.Root, versionFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		if m.Fresh != nil && m.Fresh() {
+			return m.latest(), nil
+		}
+		return 1, nil // data written before versioning existed
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q: %w", data, err)
+	}
+	return v, nil
+}
+
+func (m *Migrator) setVersion(v int) error {
+	if err := os.MkdirAll(m.Root, 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.Root, versionFile), []byte(strconv.Itoa(v)+"\n"), 0o600)
+}
+
+// Run brings the data up to the latest version, returning the migrations
+// that were applied
+func (m *Migrator) Run() ([]Migration, error) {
+	current, err := m.Current()
+	if err != nil {
+		return nil, err
+	}
+	latest := m.latest()
+	if current > latest {
+		return nil, fmt.Errorf("data in %s is schema version %d but this version of ask only knows up to %d, upgrade ask or run with -rollback", m.Root, current, latest)
+	}
+	if current == latest {
+		// stamp fresh installs so later upgrades know where they started
+		return nil, m.setVersion(latest)
+	}
+
+	pending := make([]Migration, 0, len(m.Migrations))
+	for _, mig := range m.Migrations {
+		if mig.Version > current {
+			pending = append(pending, mig)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Version < pending[j].Version })
+
+	backup, err := m.backup(current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up data before migrating, nothing was changed: %w", err)
+	}
+	logging.Infof("migrate: backed up schema version %d to %s", current, backup)
+
+	var applied []Migration
+	for _, mig := range pending {
+		if err := mig.Up(m.Root); err != nil {
+			return applied, fmt.Errorf("migration %d (%s) failed, restore the backup with -rollback: %w", mig.Version, mig.Description, err)
+		}
+		if err := m.setVersion(mig.Version); err != nil {
+			return applied, err
+		}
+		applied = append(applied, mig)
+	}
+	return applied, nil
+}
+
+// backup copies the backed up directories to backups/<time>-v<version>
+func (m *Migrator) backup(version int) (string, error) {
+	dest := filepath.Join(m.backupDir(), fmt.Sprintf("%s-v%d", time.Now().Format("20060102-150405"), version))
+	for _, dir := range m.Backup {
+		src := filepath.Join(m.Root, dir)
+		if _, err := os.Stat(src); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err := copyDir(src, filepath.Join(dest, dir)); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(dest, 0o700); err != nil {
+		return "", err
+	}
+	return dest, os.WriteFile(filepath.Join(dest, versionFile), []byte(strconv.Itoa(version)+"\n"), 0o600)
+}
+
+// Rollback restores the most recent backup, returning the version restored
+func (m *Migrator) Rollback() (int, error) {
+	entries, err := os.ReadDir(m.backupDir())
+	if err != nil || len(entries) == 0 {
+		return 0, fmt.Errorf("no backups found in %s", m.backupDir())
+	}
+	// backup names start with a timestamp so the last one is the newest
+	latest := filepath.Join(m.backupDir(), entries[len(entries)-1].Name())
+
+	data, err := os.ReadFile(filepath.Join(latest, versionFile))
+	if err != nil {
+		return 0, fmt.Errorf("backup %s is incomplete: %w", latest, err)
+	}
+	version, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("backup %s has an invalid version: %w", latest, err)
+	}
+
+	for _, dir := range m.Backup {
+		target := filepath.Join(m.Root, dir)
+		if err := os.RemoveAll(target); err != nil {
+			return 0, err
+		}
+		src := filepath.Join(latest, dir)
+		if _, err := os.Stat(src); errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err := copyDir(src, target); err != nil {
+			return 0, err
+		}
+	}
+	if err := m.setVersion(version); err != nil {
+		return 0, err
+	}
+	// keep the backup around, rolling back twice should be harmless
+	return version, nil
+}
+
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o700)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0o600)
+	})
+}
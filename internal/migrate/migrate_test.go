@@ -0,0 +1,159 @@
+package migrate
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestMigrator returns a migrator for a version 1 sessions directory
+// holding one file, with migrations to version 2 and 3 that rewrite it and
+// add another. fail3 makes the last migration fail
+func newTestMigrator(t *testing.T, fail3 bool) *Migrator {
+	t.Helper()
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "sessions", "a.json"), "v1")
+	return &Migrator{
+		Root:   root,
+		Backup: []string{"sessions"},
+		Migrations: []Migration{
+			{Version: 3, Description: "add b", Up: func(root string) error {
+				if fail3 {
+					return errors.New("disk on fire")
+				}
+				return os.WriteFile(filepath.Join(root, "sessions", "b.json"), []byte("v3"), 0o600)
+			}},
+			{Version: 2, Description: "rewrite a", Up: func(root string) error {
+				return os.WriteFile(filepath.Join(root, "sessions", "a.json"), []byte("v2"), 0o600)
+			}},
+		},
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func checkVersion(t *testing.T, m *Migrator, want int) {
+	t.Helper()
+	if v, err := m.Current(); err != nil || v != want {
+		t.Fatalf("version is %d (%v), want %d", v, err, want)
+	}
+}
+
+// TestRun applies the migrations in order to unversioned data, after
+// backing it up as version 1
+func TestRun(t *testing.T) {
+	m := newTestMigrator(t, false)
+	checkVersion(t, m, 1)
+
+	applied, err := m.Run()
+	if err != nil || len(applied) != 2 || applied[0].Version != 2 || applied[1].Version != 3 {
+		t.Fatalf("applied %v (%v), want 2 then 3", applied, err)
+	}
+	checkVersion(t, m, 3)
+	if got := readFile(t, filepath.Join(m.Root, "sessions", "a.json")); got != "v2" {
+		t.Fatalf("a.json is %q after migrating", got)
+	}
+
+	backups, err := os.ReadDir(m.backupDir())
+	if err != nil || len(backups) != 1 || !strings.HasSuffix(backups[0].Name(), "-v1") {
+		t.Fatalf("backups are %v (%v), want one of version 1", backups, err)
+	}
+	backup := filepath.Join(m.backupDir(), backups[0].Name())
+	if got := readFile(t, filepath.Join(backup, "sessions", "a.json")); got != "v1" {
+		t.Fatalf("backed up a.json is %q", got)
+	}
+	if got := readFile(t, filepath.Join(backup, versionFile)); got != "1\n" {
+		t.Fatalf("backup is of version %q", got)
+	}
+
+	if applied, err := m.Run(); err != nil || len(applied) != 0 {
+		t.Fatalf("running again applied %v (%v)", applied, err)
+	}
+}
+
+// TestRunFailing stops at the failing migration, with the version at the
+// last one that was applied
+func TestRunFailing(t *testing.T) {
+	m := newTestMigrator(t, true)
+	applied, err := m.Run()
+	if err == nil || !strings.Contains(err.Error(), "migration 3 (add b) failed") || !strings.Contains(err.Error(), "disk on fire") {
+		t.Fatalf("error is %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != 2 {
+		t.Fatalf("applied %v, want only 2", applied)
+	}
+	checkVersion(t, m, 2)
+}
+
+// TestRollback restores the data and the version from before the upgrade,
+// and can be done twice
+func TestRollback(t *testing.T) {
+	m := newTestMigrator(t, false)
+	if _, err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if v, err := m.Rollback(); err != nil || v != 1 {
+			t.Fatalf("rolled back to %d (%v), want 1", v, err)
+		}
+		checkVersion(t, m, 1)
+		if got := readFile(t, filepath.Join(m.Root, "sessions", "a.json")); got != "v1" {
+			t.Fatalf("a.json is %q after rolling back", got)
+		}
+		if _, err := os.Stat(filepath.Join(m.Root, "sessions", "b.json")); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("b.json added by the migration is still there: %v", err)
+		}
+	}
+}
+
+func TestRollbackWithoutBackup(t *testing.T) {
+	m := newTestMigrator(t, false)
+	if _, err := m.Rollback(); err == nil || !strings.Contains(err.Error(), "no backups") {
+		t.Fatalf("error is %v", err)
+	}
+	if got := readFile(t, filepath.Join(m.Root, "sessions", "a.json")); got != "v1" {
+		t.Fatalf("a.json is %q", got)
+	}
+}
+
+// TestRunNewer refuses data written by a newer ask without touching it
+func TestRunNewer(t *testing.T) {
+	m := newTestMigrator(t, false)
+	writeFile(t, filepath.Join(m.Root, versionFile), "4\n")
+	if _, err := m.Run(); err == nil || !strings.Contains(err.Error(), "only knows up to 3") {
+		t.Fatalf("error is %v", err)
+	}
+	if _, err := os.Stat(m.backupDir()); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("backed up data it can't migrate: %v", err)
+	}
+}
+
+// TestRunFresh stamps a fresh install with the latest version
+func TestRunFresh(t *testing.T) {
+	m := newTestMigrator(t, false)
+	m.Fresh = func() bool { return true }
+	if applied, err := m.Run(); err != nil || len(applied) != 0 {
+		t.Fatalf("applied %v (%v) to a fresh install", applied, err)
+	}
+	m.Fresh = nil
+	checkVersion(t, m, 3)
+}
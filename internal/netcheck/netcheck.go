@@ -0,0 +1,59 @@
+package netcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// how long a connection may take before the host counts as unreachable
+const dialTimeout = 3 * time.Second
+
+// proxyFor is the proxy the requests to a URL go through, nil for none.
+// the same as the clients' transport, tests replace it
+var proxyFor = func(u *url.URL) (*url.URL, error) {
+	return http.ProxyFromEnvironment(&http.Request{URL: u})
+}
+
+// Dial connects to the host serving rawURL, or to the proxy the requests
+// to it go through (HTTPS_PROXY and the rest), and hangs up again. it's
+// much quicker than a request timing out, nil means the host can be reached
+func Dial(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("parsing %q: %w", rawURL, err)
+	}
+	proxy, err := proxyFor(u)
+	if err != nil {
+		return fmt.Errorf("finding the proxy for %q: %w", rawURL, err)
+	}
+	if proxy != nil {
+		// behind a proxy the host itself usually can't be reached directly
+		u = proxy
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+		if u.Scheme == "http" {
+			port = "80"
+		}
+	}
+	d := net.Dialer{Timeout: dialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// IsNetworkError reports whether err came from the network failing, not
+// from a server answering with an error or the request being cancelled
+func IsNetworkError(err error) bool {
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	return errors.As(err, &opErr) || errors.As(err, &dnsErr)
+}
@@ -0,0 +1,55 @@
+package netcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestDial(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	if err := Dial(context.Background(), "http://"+addr+"/v1"); err != nil {
+		t.Fatalf("listening host unreachable: %v", err)
+	}
+
+	l.Close()
+	err = Dial(context.Background(), "http://"+addr+"/v1")
+	if err == nil {
+		t.Fatal("closed port reachable")
+	}
+	if !IsNetworkError(fmt.Errorf("stream HTTP request failed: %w", err)) {
+		t.Errorf("%v isn't a network error", err)
+	}
+	if IsNetworkError(errors.New("API request failed with status 500")) || IsNetworkError(context.Canceled) {
+		t.Error("not a network error")
+	}
+}
+
+func TestDialProxy(t *testing.T) {
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := "http://" + closed.Addr().String() + "/v1"
+	closed.Close()
+
+	defer func(old func(*url.URL) (*url.URL, error)) { proxyFor = old }(proxyFor)
+	proxyFor = func(*url.URL) (*url.URL, error) {
+		return &url.URL{Scheme: "http", Host: proxy.Addr().String()}, nil
+	}
+	if err := Dial(context.Background(), target); err != nil {
+		t.Fatalf("the proxy was not dialed: %v", err)
+	}
+}
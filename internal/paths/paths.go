@@ -0,0 +1,108 @@
+// Package paths decides where ask keeps its files: the XDG base directories
+// on linux and the BSDs, their usual equivalents on macOS and Windows. the
+// XDG variables are honored everywhere when they're set. every directory is
+// the active profile's, see profile.Dir
+package paths
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/scbenet/ask/internal/profile"
+)
+
+// Config returns the directory of the config file: $XDG_CONFIG_HOME/ask,
+// ~/Library/Application Support/ask or %AppData%\ask
+func Config() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config directory: %w", err)
+	}
+	return profile.Dir(filepath.Join(dir, "ask")), nil
+}
+
+// Data returns the directory of the sessions, attachments, memories and the
+// rest of what ask keeps: $XDG_DATA_HOME/ask or ~/.local/share/ask,
+// ~/Library/Application Support/ask or %LocalAppData%\ask
+func Data() (string, error) {
+	return base("XDG_DATA_HOME", filepath.Join(".local", "share"), func(home string) string {
+		switch runtime.GOOS {
+		case "darwin":
+			return filepath.Join(home, "Library", "Application Support", "ask")
+		case "windows":
+			return filepath.Join(localAppData(home), "ask")
+		}
+		return ""
+	})
+}
+
+// State returns the directory of the log: $XDG_STATE_HOME/ask or
+// ~/.local/state/ask, ~/Library/Logs/ask or %LocalAppData%\ask\state
+func State() (string, error) {
+	return base("XDG_STATE_HOME", filepath.Join(".local", "state"), func(home string) string {
+		switch runtime.GOOS {
+		case "darwin":
+			return filepath.Join(home, "Library", "Logs", "ask")
+		case "windows":
+			return filepath.Join(localAppData(home), "ask", "state")
+		}
+		return ""
+	})
+}
+
+// Cache returns the directory of files that can be made again, like
+// rendered diagrams: $XDG_CACHE_HOME/ask or ~/.cache/ask,
+// ~/Library/Caches/ask or %LocalAppData%\ask\cache
+func Cache() (string, error) {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return profile.Dir(filepath.Join(dir, "ask")), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find cache directory: %w", err)
+	}
+	if runtime.GOOS == "windows" {
+		// %LocalAppData%, the data directory is there too
+		return profile.Dir(filepath.Join(dir, "ask", "cache")), nil
+	}
+	return profile.Dir(filepath.Join(dir, "ask")), nil
+}
+
+// base returns the ask directory in $env, or native(home) on macOS and
+// Windows, or else the one in ~/xdgDefault. older versions of ask used
+// ~/xdgDefault everywhere, it's still used as long as it exists. the native
+// directory existing says nothing: on macOS the config is in the data's
+// native directory, so it exists as soon as there's a config.json
+func base(env, xdgDefault string, native func(home string) string) (string, error) {
+	if dir := os.Getenv(env); dir != "" {
+		return profile.Dir(filepath.Join(dir, "ask")), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	xdg := profile.Dir(filepath.Join(home, xdgDefault, "ask"))
+	nativeDir := native(home)
+	if nativeDir == "" {
+		return xdg, nil
+	}
+	nativeDir = profile.Dir(nativeDir)
+	if exists(xdg) {
+		return xdg, nil
+	}
+	return nativeDir, nil
+}
+
+func localAppData(home string) string {
+	if dir := os.Getenv("LocalAppData"); dir != "" {
+		return dir
+	}
+	return filepath.Join(home, "AppData", "Local")
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,61 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/scbenet/ask/internal/profile"
+)
+
+func TestXDG(t *testing.T) {
+	t.Setenv(profile.EnvVar, "")
+	t.Setenv("XDG_DATA_HOME", "/xdg/data")
+	t.Setenv("XDG_STATE_HOME", "/xdg/state")
+	t.Setenv("XDG_CACHE_HOME", "/xdg/cache")
+	for name, f := range map[string]func() (string, error){"data": Data, "state": State, "cache": Cache} {
+		got, err := f()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join("/xdg", name, "ask"); got != want {
+			t.Errorf("%s directory is %s, want %s", name, got, want)
+		}
+	}
+
+	t.Setenv(profile.EnvVar, "work")
+	if got, _ := Data(); got != filepath.Join("/xdg/data", "ask", "profiles", "work") {
+		t.Errorf("the work profile's data directory is %s", got)
+	}
+}
+
+func TestNativeFallsBackToOldDirectory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("XDG_DATA_HOME", "")
+	t.Setenv(profile.EnvVar, "")
+	native := func(home string) string { return filepath.Join(home, "Library", "ask") }
+	old := filepath.Join(home, ".local", "share", "ask")
+
+	if got, _ := base("XDG_DATA_HOME", filepath.Join(".local", "share"), native); got != native(home) {
+		t.Errorf("new install uses %s, want %s", got, native(home))
+	}
+	if err := os.MkdirAll(old, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := base("XDG_DATA_HOME", filepath.Join(".local", "share"), native); got != old {
+		t.Errorf("existing install uses %s, want %s", got, old)
+	}
+	// on macOS the config makes the native directory exist, the data in the
+	// old one isn't left behind for it
+	if err := os.MkdirAll(native(home), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(native(home), "config.json"), []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := base("XDG_DATA_HOME", filepath.Join(".local", "share"), native); got != old {
+		t.Errorf("with both directories %s is used, want %s", got, old)
+	}
+}
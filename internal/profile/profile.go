@@ -0,0 +1,50 @@
+// Package profile keeps the config and data of named profiles apart, e.g.
+// work and personal, each with its own config file, keys, sessions,
+// memories, spending and log. the profile is selected with -profile or
+// $ASK_PROFILE, without one ask uses the default profile. keys read from
+// the environment, like OPENROUTER_API_KEY, are the same in every profile
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// EnvVar selects the profile, -profile sets it for the processes ask starts
+// too
+const EnvVar = "ASK_PROFILE"
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Name returns the active profile, "" for the default one
+func Name() string {
+	return os.Getenv(EnvVar)
+}
+
+// Set makes name the active profile
+func Set(name string) error {
+	if err := Validate(name); err != nil {
+		return err
+	}
+	return os.Setenv(EnvVar, name)
+}
+
+// Validate checks name can be used as a directory name, "" is the default
+// profile
+func Validate(name string) error {
+	if name != "" && !validName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q, use letters, digits, '-', '_' and '.'", name)
+	}
+	return nil
+}
+
+// Dir returns where the active profile keeps what the default profile
+// keeps in dir: dir/profiles/name
+func Dir(dir string) string {
+	if name := Name(); name != "" {
+		return filepath.Join(dir, "profiles", name)
+	}
+	return dir
+}
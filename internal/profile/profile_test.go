@@ -0,0 +1,28 @@
+package profile
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDir(t *testing.T) {
+	dir := filepath.Join("data", "ask")
+	t.Setenv(EnvVar, "")
+	if got := Dir(dir); got != dir {
+		t.Errorf("default profile Dir = %s, want %s", got, dir)
+	}
+	if err := Set("work"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := Dir(dir), filepath.Join(dir, "profiles", "work"); got != want {
+		t.Errorf("Dir = %s, want %s", got, want)
+	}
+	for _, name := range []string{"..", "../x", "a/b", "-x", "with space"} {
+		if err := Set(name); err == nil {
+			t.Errorf("Set(%q) accepted it", name)
+		}
+	}
+	if Name() != "work" {
+		t.Errorf("an invalid name changed the profile to %q", Name())
+	}
+}
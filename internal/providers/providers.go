@@ -0,0 +1,132 @@
+// Package providers opens the clients of the providers in the config and
+// the middleware every request goes through. the TUI, ask run and pkg/ask
+// all build their client here, so none of them skips redaction, budgets,
+// retries or rate limits
+package providers
+
+import (
+	"fmt"
+
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/config"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/logging"
+	"github.com/scbenet/ask/internal/spend"
+)
+
+// answers of background jobs kept, see llm.Cache
+const generateCacheSize = 64
+
+// Providers are the clients of the configured providers behind one router
+type Providers struct {
+	// Router sends a model to the provider of its prefix, the rest to
+	// openrouter
+	Router *llm.Router
+	// OpenRouter is nil without OPENROUTER_API_KEY
+	OpenRouter *llm.OpenRouterClient
+	// Gemini is nil without GOOGLE_API_KEY
+	Gemini *llm.GeminiClient
+	// Compatible are the OpenAI-compatible providers, in the config's order
+	Compatible []*llm.OpenRouterClient
+	// URLs are where each provider's requests go, "" for openrouter
+	URLs map[string]string
+}
+
+// Open creates a client for every provider that's set up, each logging its
+// requests. lg logs the providers that can't be set up
+func Open(cfg config.Config, lg logging.Logger) Providers {
+	clientOpts := []llm.Option{
+		llm.WithRequestTimeout(cfg.RequestTimeout.Std()),
+		llm.WithStallTimeout(cfg.StallTimeout.Std()),
+	}
+	p := Providers{URLs: map[string]string{}}
+
+	// openrouter serves every model without a provider prefix
+	var fallback llm.LLMClient
+	if openRouter, err := llm.NewOpenRouterClient(clientOpts...); err == nil {
+		p.OpenRouter = openRouter
+		fallback = llm.Chain(openRouter, llm.Logging("openrouter"))
+		p.URLs[""] = openRouter.BaseURL()
+	} else {
+		lg.Errorf("initializing openrouter client: %v", err)
+	}
+	p.Router = llm.NewRouter(fallback)
+
+	// gemini models can also be reached directly with an AI Studio key
+	if gemini, err := llm.NewGeminiClient(clientOpts...); err == nil {
+		p.Gemini = gemini
+		p.Router.Register("gemini", llm.Chain(gemini, llm.Logging("gemini")))
+		p.URLs["gemini"] = gemini.BaseURL()
+	} else {
+		lg.Infof("gemini client not available: %v", err)
+	}
+
+	// any OpenAI-compatible servers from the config
+	for _, pc := range cfg.Providers {
+		client := llm.NewOpenAICompatibleClient(pc.BaseURL, pc.Key(), clientOpts...)
+		p.Compatible = append(p.Compatible, client)
+		p.Router.Register(pc.Name, llm.Chain(client, llm.Logging(pc.Name)))
+		p.URLs[pc.Name] = client.BaseURL()
+	}
+	return p
+}
+
+// Configured reports whether any provider can be reached
+func (p Providers) Configured() bool {
+	return p.OpenRouter != nil || p.Gemini != nil || len(p.Compatible) > 0
+}
+
+// Middleware is what every request goes through whichever provider answers
+// it, outermost first: a cached answer or a refused request never reaches
+// the rate limit, and every retry goes through it. ledger may be nil
+func Middleware(cfg config.Config, ledger *spend.Ledger, c clock.Clock, lg logging.Logger) []llm.Middleware {
+	middleware := []llm.Middleware{llm.RequestIDs(), llm.Cache(generateCacheSize)}
+	if cfg.Budget.Daily > 0 && ledger != nil {
+		middleware = append(middleware, llm.Budget(DailyBudgetSpent(ledger, cfg.Budget.Daily, lg)))
+	}
+	if patterns, err := cfg.Redactions(); err != nil {
+		lg.Errorf("%v", err)
+	} else if len(patterns) > 0 {
+		middleware = append(middleware, llm.Redact(patterns))
+	}
+	if r := cfg.Retry; r.Attempts > 0 {
+		middleware = append(middleware, llm.Retry(r.Attempts, r.Backoff.Std(), c))
+	}
+	if r := cfg.RateLimit; r.Enabled() {
+		middleware = append(middleware, llm.RateLimit(llm.NewLimiter(r.RequestsPerMinute, r.TokensPerMinute, r.Concurrent, c)))
+	}
+	return middleware
+}
+
+// DailyBudgetSpent tells llm.Budget when the day's budget is gone, it is
+// called from the background jobs' goroutines so it only reads the ledger
+func DailyBudgetSpent(ledger *spend.Ledger, daily float64, lg logging.Logger) func() error {
+	return func() error {
+		today, err := ledger.Today()
+		if err != nil {
+			// not knowing isn't a reason to stop working
+			lg.Errorf("reading spend: %v", err)
+			return nil
+		}
+		if today >= daily {
+			return fmt.Errorf("the daily budget of $%.2f is spent", daily)
+		}
+		return nil
+	}
+}
+
+// Params are the request parameters of a model's settings
+func Params(mc config.ModelConfig) llm.Params {
+	return llm.Params{
+		Temperature:       mc.Temperature,
+		MaxTokens:         mc.MaxTokens,
+		ReasoningEffort:   mc.ReasoningEffort,
+		Seed:              mc.Seed,
+		FrequencyPenalty:  mc.FrequencyPenalty,
+		PresencePenalty:   mc.PresencePenalty,
+		RepetitionPenalty: mc.RepetitionPenalty,
+		MinP:              mc.MinP,
+		LogitBias:         mc.LogitBias,
+		Stop:              mc.Stop,
+	}
+}
@@ -0,0 +1,123 @@
+package session
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Query selects sessions for Find
+type Query struct {
+	// Text is fuzzy matched against the titles and messages, "" matches
+	// everything
+	Text string
+	// Tags the sessions must all have
+	Tags []string
+	// Archived finds the archived sessions instead of the others, All both
+	Archived bool
+	All      bool
+}
+
+// Find returns the sessions matching q, pinned ones first, then the best
+// matches, then the most recently updated
+func (s *Store) Find(q Query) ([]*Session, error) {
+	sessions, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	type found struct {
+		sess  *Session
+		score int
+	}
+	var matches []found
+	for _, sess := range sessions {
+		if !q.All && sess.Archived != q.Archived {
+			continue
+		}
+		if !hasTags(sess, q.Tags) {
+			continue
+		}
+		score, ok := Match(sess, q.Text)
+		if !ok {
+			continue
+		}
+		matches = append(matches, found{sess, score})
+	}
+	// List sorted them by update already
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].sess.Pinned != matches[j].sess.Pinned {
+			return matches[i].sess.Pinned
+		}
+		return matches[i].score > matches[j].score
+	})
+
+	result := make([]*Session, len(matches))
+	for i, m := range matches {
+		result[i] = m.sess
+	}
+	return result, nil
+}
+
+// Match reports whether sess matches text and how well: the letters of text
+// in order in the title (closer together scoring higher), or every word of
+// it somewhere in the messages, which scores below any title match
+func Match(sess *Session, text string) (int, bool) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if text == "" {
+		return 0, true
+	}
+	if score, ok := fuzzy(strings.ToLower(sess.Title), text); ok {
+		return 1000 + score, true
+	}
+	for _, word := range strings.Fields(text) {
+		if !mentions(sess, word) {
+			return 0, false
+		}
+	}
+	return 0, true
+}
+
+func hasTags(sess *Session, tags []string) bool {
+	for _, tag := range tags {
+		if !sess.HasTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func mentions(sess *Session, word string) bool {
+	for _, m := range sess.Messages {
+		if strings.Contains(strings.ToLower(m.Content), word) {
+			return true
+		}
+	}
+	return false
+}
+
+// fuzzy finds the runes of pattern in order in s, ignoring spaces in the
+// pattern. the score is higher the fewer runes are skipped between them
+func fuzzy(s, pattern string) (int, bool) {
+	runes := []rune(s)
+	score, i, last := 0, 0, -1
+	for _, r := range pattern {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		for i < len(runes) && runes[i] != r {
+			i++
+		}
+		if i == len(runes) {
+			return 0, false
+		}
+		if last >= 0 && i == last+1 {
+			score += 2
+		} else if last >= 0 {
+			score -= min(i-last-1, 10)
+		}
+		last = i
+		i++
+	}
+	return score, true
+}
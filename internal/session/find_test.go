@@ -0,0 +1,97 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scbenet/ask/internal/conversation"
+)
+
+func TestFind(t *testing.T) {
+	store, c := newTestStore(t)
+	save := func(title, content string, mark func(*Session)) {
+		c.Advance(time.Minute)
+		sess := New(title)
+		sess.Updated = c.Now()
+		sess.Messages = []conversation.Message{{Role: "user", Content: content}}
+		if mark != nil {
+			mark(sess)
+		}
+		if err := store.Save(sess); err != nil {
+			t.Fatal(err)
+		}
+	}
+	save("golang generics", "how do type parameters work", nil)
+	save("pinned notes", "shopping list", func(s *Session) { s.Pinned = true })
+	save("old trip plans", "flights to lisbon", func(s *Session) { s.Archived = true })
+	save("sourdough", "my starter smells of golang", nil)
+
+	tests := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"everything but archived", Query{}, []string{"pinned notes", "sourdough", "golang generics"}},
+		{"archived", Query{Archived: true}, []string{"old trip plans"}},
+		{"all", Query{All: true}, []string{"pinned notes", "sourdough", "old trip plans", "golang generics"}},
+		{"title before content", Query{Text: "golang"}, []string{"golang generics", "sourdough"}},
+		{"fuzzy title", Query{Text: "gogen"}, []string{"golang generics"}},
+		{"content words", Query{Text: "type work"}, []string{"golang generics"}},
+		{"archived content", Query{Text: "lisbon", Archived: true}, []string{"old trip plans"}},
+		{"no match", Query{Text: "xyzzy"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := store.Find(tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, s := range found {
+				got = append(got, s.Title)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Find(%+v) = %q, want %q", tt.q, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Find(%+v) = %q, want %q", tt.q, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFindTags(t *testing.T) {
+	store, _ := newTestStore(t)
+	// commas separate tags like they do for -tag
+	for _, tc := range []struct {
+		tags  []string
+		added int
+	}{{[]string{"work", "golang"}, 2}, {[]string{"#Work"}, 1}, {[]string{"baking,bread"}, 2}, {nil, 0}} {
+		sess := New(strings.Join(tc.tags, " "))
+		if added := sess.Tag(tc.tags...); len(added) != tc.added {
+			t.Fatalf("Tag(%q) added %q", tc.tags, added)
+		}
+		if err := store.Save(sess); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for tags, want := range map[string]int{"work": 2, "work,golang": 1, "GOLANG": 1, "baking": 1, "baking,bread": 1, "baking,work": 0} {
+		found, err := store.Find(Query{Tags: strings.Split(tags, ",")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != want {
+			t.Errorf("found %d sessions tagged %s, want %d", len(found), tags, want)
+		}
+	}
+
+	sess := New("")
+	sess.Tag("b", "a", "b")
+	if removed := sess.Untag("#B", "c"); len(removed) != 1 || strings.Join(sess.Tags, ",") != "a" {
+		t.Errorf("Untag removed %q, left %q", removed, sess.Tags)
+	}
+}
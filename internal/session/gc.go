@@ -0,0 +1,129 @@
+package session
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/scbenet/ask/internal/vfs"
+)
+
+// blobs younger than this are never collected, a running ask may have just
+// written an attachment it hasn't saved the session for yet
+const gcGracePeriod = time.Hour
+
+// GCReport summarises a garbage collection run
+type GCReport struct {
+	BlobsScanned     int
+	BlobsRemoved     int
+	TempFilesRemoved int
+	BytesReclaimed   int64
+}
+
+// CollectGarbage removes blobs no session references and temp files left
+// behind by interrupted writes. with dryRun nothing is deleted, the report
+// shows what would have been
+func (s *Store) CollectGarbage(dryRun bool) (GCReport, error) {
+	var report GCReport
+
+	referenced, err := s.referencedBlobs()
+	if err != nil {
+		return report, err
+	}
+
+	blobs, err := s.blobs.List()
+	if err != nil {
+		return report, err
+	}
+	report.BlobsScanned = len(blobs)
+
+	cutoff := s.clock.Now().Add(-gcGracePeriod)
+	for _, b := range blobs {
+		if referenced[b.Hash] || b.ModTime.After(cutoff) {
+			continue
+		}
+		if !dryRun {
+			if err := s.blobs.Remove(b.Hash); err != nil {
+				return report, err
+			}
+		}
+		report.BlobsRemoved++
+		report.BytesReclaimed += b.Size
+	}
+
+	// stale .tmp files from crashes mid-write, in both the session and blob dirs
+	for _, dir := range []string{s.dir, s.blobs.Dir()} {
+		err := vfs.WalkDir(s.fs, dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, ".tmp") {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				return err
+			}
+			if !dryRun {
+				if err := s.fs.Remove(path); err != nil {
+					return err
+				}
+			}
+			report.TempFilesRemoved++
+			report.BytesReclaimed += info.Size()
+			return nil
+		})
+		if err != nil {
+			return report, fmt.Errorf("failed to clean temp files: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+// referencedBlobs returns the hashes of all attachments and stored tool
+// output used by any session, including those in the trash and recovery
+// snapshots
+func (s *Store) referencedBlobs() (map[string]bool, error) {
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+	var sessions []*Session
+	for _, id := range ids {
+		sess, err := s.read(id)
+		if err != nil {
+			// refuse to collect anything if a session can't be read, its
+			// blobs would look orphaned
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	trash, err := s.Trash()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range trash {
+		sessions = append(sessions, entry.Session)
+	}
+	recoveries, err := s.recoveries()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range recoveries {
+		sessions = append(sessions, r.Session)
+	}
+
+	referenced := map[string]bool{}
+	for _, sess := range sessions {
+		for _, m := range sess.Messages {
+			for _, a := range m.Attachments {
+				if a.Hash != "" {
+					referenced[a.Hash] = true
+				}
+			}
+			if m.OutputHash != "" {
+				referenced[m.OutputHash] = true
+			}
+		}
+	}
+	return referenced, nil
+}
@@ -0,0 +1,69 @@
+package session
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/scbenet/ask/internal/blob"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/migrate"
+)
+
+// Migrator returns the migrator for the data directory root, which holds
+// the sessions and blobs directories
+func Migrator(root string) *migrate.Migrator {
+	return &migrate.Migrator{
+		Root:       root,
+		Migrations: migrations,
+		Backup:     []string{"sessions"},
+		Fresh: func() bool {
+			// a directory that can't be read isn't known to be empty, it's
+			// migrated and the error comes up there
+			entries, err := os.ReadDir(filepath.Join(root, "sessions"))
+			if errors.Is(err, fs.ErrNotExist) {
+				return true
+			}
+			return err == nil && len(entries) == 0
+		},
+	}
+}
+
+var migrations = []migrate.Migration{
+	{
+		Version:     2,
+		Description: "give every message an id and move inline attachments to the blob store",
+		Up:          migrateMessageIDsAndBlobs,
+	},
+}
+
+// version 1 sessions stored plain role/content messages with attachments
+// inlined, version 2 messages carry ids and reference attachments by hash
+func migrateMessageIDsAndBlobs(root string) error {
+	blobs, err := blob.NewStore(filepath.Join(root, "blobs"))
+	if err != nil {
+		return err
+	}
+	store, err := NewStore(filepath.Join(root, "sessions"), blobs)
+	if err != nil {
+		return err
+	}
+
+	ids, err := store.ids()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		sess, err := store.read(id)
+		if err != nil {
+			return err
+		}
+		sess.Messages = conversation.New(sess.Messages).Messages
+		// Save moves any inline attachment content into the blob store
+		if err := store.Save(sess); err != nil {
+			return err
+		}
+	}
+	return nil
+}
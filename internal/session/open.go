@@ -0,0 +1,81 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// while an ask has a session open there's an empty file <id>.<pid> in
+// .open, so ask trash restore can tell it would be overwritten
+
+func (s *Store) openDir() string {
+	return filepath.Join(s.dir, ".open")
+}
+
+// SetOpen marks id as the session open in this process, replacing the one
+// marked before. "" marks none
+func (s *Store) SetOpen(id string) error {
+	if id != "" {
+		if err := checkID(id); err != nil {
+			return err
+		}
+	}
+	entries, err := s.fs.ReadDir(s.openDir())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read open sessions: %w", err)
+	}
+	own := "." + strconv.Itoa(os.Getpid())
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), own) {
+			if err := s.fs.Remove(filepath.Join(s.openDir(), e.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to unmark open session: %w", err)
+			}
+		}
+	}
+	if id == "" {
+		return nil
+	}
+	if err := s.fs.MkdirAll(s.openDir(), 0o700); err != nil {
+		return fmt.Errorf("failed to create open sessions directory: %w", err)
+	}
+	if err := s.fs.WriteFile(filepath.Join(s.openDir(), id+own), nil, 0o600); err != nil {
+		return fmt.Errorf("failed to mark session open: %w", err)
+	}
+	return nil
+}
+
+// OpenElsewhere returns the PID of another running ask that has session id
+// open, 0 if there's none. marks left by an ask that didn't exit cleanly
+// are removed on the way
+func (s *Store) OpenElsewhere(id string) int {
+	entries, err := s.fs.ReadDir(s.openDir())
+	if err != nil {
+		return 0
+	}
+	for _, e := range entries {
+		name, pidText, ok := cutLast(e.Name(), ".")
+		if !ok || name != id {
+			continue
+		}
+		pid, err := strconv.Atoi(pidText)
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		if running(pid) {
+			return pid
+		}
+		_ = s.fs.Remove(filepath.Join(s.openDir(), e.Name()))
+	}
+	return 0
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,17 @@
+package session
+
+import (
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// processStart returns when pid started, "" if it can't be looked up
+func processStart(pid int) string {
+	info, err := unix.SysctlKinfoProc("kern.proc.pid", pid)
+	if err != nil || info.Proc.P_pid != int32(pid) {
+		return ""
+	}
+	start := info.Proc.P_starttime
+	return strconv.FormatInt(int64(start.Sec), 10) + "." + strconv.FormatInt(int64(start.Usec), 10)
+}
@@ -0,0 +1,27 @@
+package session
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processStart returns when pid started, in clock ticks since boot as the
+// kernel has it in /proc. "" if it can't be read
+func processStart(pid int) string {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return ""
+	}
+	// the command in parentheses may contain spaces, the fields after it
+	// don't. starttime is the 22nd field, the 20th after the command
+	_, rest, ok := strings.Cut(string(data), ") ")
+	if !ok {
+		return ""
+	}
+	fields := strings.Fields(rest)
+	if len(fields) < 20 {
+		return ""
+	}
+	return fields[19]
+}
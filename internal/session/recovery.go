@@ -0,0 +1,151 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// Recovery is a snapshot of a conversation taken while a response was
+// streaming, left behind when ask didn't exit cleanly
+type Recovery struct {
+	// PID of the ask that saved it, each running ask has its own snapshot
+	PID     int
+	Session *Session
+	// when the process with PID started, "" if that isn't known. a process
+	// that started at another time only reused the PID
+	started string
+}
+
+// storedRecovery is how a Recovery is stored
+type storedRecovery struct {
+	*Session
+	ProcessStart string `json:"processStart,omitempty"`
+}
+
+func (s *Store) recoveryDir() string {
+	return filepath.Join(s.dir, ".recovery")
+}
+
+func (s *Store) recoveryPath(pid int) string {
+	return filepath.Join(s.recoveryDir(), strconv.Itoa(pid)+".json")
+}
+
+// SaveRecovery replaces the snapshot of this process with sess. ephemeral
+// messages are left out, a snapshot can outlive their expiry
+func (s *Store) SaveRecovery(sess *Session) error {
+	snapshot := *sess
+	snapshot.Messages = conversation.WithoutEphemeral(sess.Messages)
+	stored, err := s.withoutAttachmentContent(&snapshot)
+	if err != nil {
+		return err
+	}
+	if err := s.fs.MkdirAll(s.recoveryDir(), 0o700); err != nil {
+		return fmt.Errorf("failed to create recovery directory: %w", err)
+	}
+	data, err := json.Marshal(storedRecovery{Session: stored, ProcessStart: processStart(os.Getpid())})
+	if err != nil {
+		return fmt.Errorf("failed to marshal recovery snapshot: %w", err)
+	}
+	path := s.recoveryPath(os.Getpid())
+	if err := s.fs.WriteFile(path+".tmp", data, 0o600); err != nil {
+		return fmt.Errorf("failed to write recovery snapshot: %w", err)
+	}
+	if err := s.fs.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("failed to save recovery snapshot: %w", err)
+	}
+	return nil
+}
+
+// ClearRecovery removes the snapshot of this process, if there is one
+func (s *Store) ClearRecovery() error {
+	return s.DiscardRecovery(os.Getpid())
+}
+
+// DiscardRecovery removes the snapshot left by pid
+func (s *Store) DiscardRecovery(pid int) error {
+	err := s.fs.Remove(s.recoveryPath(pid))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove recovery snapshot: %w", err)
+	}
+	return nil
+}
+
+// Recoverable returns the snapshots left by processes that are no longer
+// running, most recently updated first. snapshots that can't be loaded are
+// logged and left out
+func (s *Store) Recoverable() ([]Recovery, error) {
+	all, err := s.recoveries()
+	if err != nil {
+		return nil, err
+	}
+	var recoveries []Recovery
+	for _, r := range all {
+		if r.PID == os.Getpid() || r.running() {
+			continue
+		}
+		if err := s.loadAttachmentContent(r.Session); err != nil {
+			logging.Errorf("skipping recovery snapshot %d, failed to load its attachments: %v", r.PID, err)
+			continue
+		}
+		recoveries = append(recoveries, r)
+	}
+	sort.Slice(recoveries, func(i, j int) bool {
+		return recoveries[i].Session.Updated.After(recoveries[j].Session.Updated)
+	})
+	return recoveries, nil
+}
+
+// recoveries reads every snapshot as stored, including those of running
+// processes. one that can't be read is logged and left out, it shouldn't
+// keep the others from being recovered
+func (s *Store) recoveries() ([]Recovery, error) {
+	entries, err := s.fs.ReadDir(s.recoveryDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recovery directory: %w", err)
+	}
+
+	var recoveries []Recovery
+	for _, e := range entries {
+		pid, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		data, err := s.fs.ReadFile(s.recoveryPath(pid))
+		if err != nil {
+			logging.Errorf("skipping recovery snapshot %s: %v", e.Name(), err)
+			continue
+		}
+		snap := storedRecovery{Session: &Session{}}
+		if err := json.Unmarshal(data, &snap); err != nil {
+			logging.Errorf("skipping recovery snapshot %s: %v", e.Name(), err)
+			continue
+		}
+		recoveries = append(recoveries, Recovery{PID: pid, Session: snap.Session, started: snap.ProcessStart})
+	}
+	return recoveries, nil
+}
+
+// running reports whether the ask that saved r is still running, and not
+// just another process that got its PID
+func (r Recovery) running() bool {
+	if !running(r.PID) {
+		return false
+	}
+	if r.started == "" {
+		return true
+	}
+	started := processStart(r.PID)
+	return started == "" || started == r.started
+}
@@ -0,0 +1,110 @@
+package session
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/scbenet/ask/internal/conversation"
+)
+
+// notRunning is a PID no process has, above the largest linux hands out
+const notRunning = 1 << 23
+
+func TestRecoverable(t *testing.T) {
+	store, _ := newTestStore(t)
+	sess := New("crashed")
+	sess.Messages = []conversation.Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hel"}}
+	if err := store.SaveRecovery(sess); err != nil {
+		t.Fatal(err)
+	}
+	// snapshots of running processes aren't recoverable, this one's own
+	// included
+	if got, err := store.Recoverable(); err != nil || len(got) != 0 {
+		t.Fatalf("Recoverable() = %v, %v while the process runs", got, err)
+	}
+
+	// as if this process had crashed and its PID were free again
+	data, err := store.fs.ReadFile(store.recoveryPath(os.Getpid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.fs.WriteFile(store.recoveryPath(notRunning), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// a corrupt snapshot doesn't hide the others
+	if err := store.fs.WriteFile(store.recoveryPath(notRunning+1), []byte(`{"id": `), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.Recoverable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].PID != notRunning || got[0].Session.Messages[1].Content != "hel" {
+		t.Fatalf("Recoverable() = %+v", got)
+	}
+	if _, err := store.CollectGarbage(true); err != nil {
+		t.Fatalf("collecting garbage with a corrupt snapshot: %v", err)
+	}
+
+	if err := store.DiscardRecovery(notRunning); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := store.Recoverable(); len(got) != 0 {
+		t.Fatalf("%d snapshots left after discarding", len(got))
+	}
+}
+
+// TestRecoverableReusedPID recovers a snapshot whose PID now belongs to
+// another process
+func TestRecoverableReusedPID(t *testing.T) {
+	if processStart(os.Getpid()) == "" {
+		t.Skip("process start times aren't looked up here")
+	}
+	store, _ := newTestStore(t)
+	sess := New("crashed")
+	sess.Messages = []conversation.Message{{Role: "user", Content: "hi"}}
+	if err := store.SaveRecovery(sess); err != nil {
+		t.Fatal(err)
+	}
+	data, err := store.fs.ReadFile(store.recoveryPath(os.Getpid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the parent runs, but it isn't the process that saved the snapshot
+	if err := store.fs.WriteFile(store.recoveryPath(os.Getppid()), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.Recoverable()
+	if err != nil || len(got) != 1 || got[0].PID != os.Getppid() {
+		t.Fatalf("Recoverable() = %+v, %v", got, err)
+	}
+}
+
+func TestRunning(t *testing.T) {
+	if !running(os.Getpid()) {
+		t.Error("this process isn't running")
+	}
+	if running(notRunning) {
+		t.Errorf("PID %d is running", notRunning)
+	}
+
+	// a process that exited isn't running, on windows too where finding it
+	// alone doesn't tell
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	pid := cmd.Process.Pid
+	if !running(pid) {
+		t.Errorf("the child %d isn't running", pid)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if running(pid) {
+		t.Errorf("the child %d still runs after exiting", pid)
+	}
+}
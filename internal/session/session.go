@@ -0,0 +1,285 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scbenet/ask/internal/attach"
+	"github.com/scbenet/ask/internal/blob"
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/logging"
+	"github.com/scbenet/ask/internal/paths"
+	"github.com/scbenet/ask/internal/vfs"
+)
+
+// Session is a persisted conversation
+type Session struct {
+	ID       string                 `json:"id"`
+	Title    string                 `json:"title"`
+	Source   string                 `json:"source,omitempty"` // where the session came from if it was imported
+	Created  time.Time              `json:"created"`
+	Updated  time.Time              `json:"updated"`
+	Persona  string                 `json:"persona,omitempty"`  // name of the persona used for this conversation
+	Locked   bool                   `json:"locked,omitempty"`   // read-only, see /lock
+	Pinned   bool                   `json:"pinned,omitempty"`   // listed before the others
+	Archived bool                   `json:"archived,omitempty"` // hidden from the list, see Find
+	Tags     []string               `json:"tags,omitempty"`     // sorted, see Tag
+	Spent    float64                `json:"spent,omitempty"`    // USD the responses cost, for the session budget
+	Messages []conversation.Message `json:"messages"`
+
+	// Incognito sessions are never written to disk
+	Incognito bool `json:"-"`
+}
+
+// New creates an empty session with a fresh, time-sortable ID
+func New(title string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:      newID(now),
+		Title:   title,
+		Created: now,
+		Updated: now,
+	}
+}
+
+func newID(t time.Time) string {
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+	return t.Format("20060102-150405") + "-" + hex.EncodeToString(suffix)
+}
+
+// validID matches the IDs ask gives sessions and trash entries, and nothing
+// that could lead out of their directory
+var validID = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z_-]*$`)
+
+// checkID returns an error unless id could be the ID of a session or a
+// trash entry. IDs come from the command line, they're never used in a path
+// without checking
+func checkID(id string) error {
+	if !validID.MatchString(id) {
+		return fmt.Errorf("%q is not a valid ID", id)
+	}
+	return nil
+}
+
+// TitleFromPrompt derives a short session title from the first prompt
+func TitleFromPrompt(prompt string) string {
+	title := strings.Join(strings.Fields(prompt), " ")
+	const maxLen = 60
+	if r := []rune(title); len(r) > maxLen {
+		title = string(r[:maxLen-1]) + "…"
+	}
+	return title
+}
+
+// Store keeps sessions as one JSON file each in a directory. attachment
+// contents live in a separate blob store and are referenced by hash
+type Store struct {
+	dir   string
+	blobs *blob.Store
+	fs    vfs.FS
+	clock clock.Clock
+}
+
+// DefaultDir returns the directory sessions are stored in, sessions in
+// paths.Data
+func DefaultDir() (string, error) {
+	dir, err := paths.Data()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sessions"), nil
+}
+
+// Option configures a Store
+type Option func(*Store)
+
+// WithFS keeps the sessions on fsys instead of the disk
+func WithFS(fsys vfs.FS) Option {
+	return func(s *Store) { s.fs = fsys }
+}
+
+// WithClock dates trash entries and ages the trash and blobs by c
+func WithClock(c clock.Clock) Option {
+	return func(s *Store) { s.clock = c }
+}
+
+// NewStore opens (creating if needed) a session store in dir, with
+// attachments stored in blobs
+func NewStore(dir string, blobs *blob.Store, opts ...Option) (*Store, error) {
+	s := &Store{dir: dir, blobs: blobs, fs: vfs.OS, clock: clock.System}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.fs.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	return s, nil
+}
+
+// Blobs returns the store holding attachment contents
+func (s *Store) Blobs() *blob.Store {
+	return s.blobs
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// Save writes the session to disk, replacing any previous version
+func (s *Store) Save(sess *Session) error {
+	stored, err := s.withoutAttachmentContent(sess)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+
+	// write to a temp file and rename so a crash never leaves a half written session
+	tmp := s.path(sess.ID) + ".tmp"
+	if err := s.fs.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := s.fs.Rename(tmp, s.path(sess.ID)); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// Load reads a single session by ID
+func (s *Store) Load(id string) (*Session, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	sess, err := s.read(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadAttachmentContent(sess); err != nil {
+		return nil, fmt.Errorf("failed to load attachments for session %s: %w", id, err)
+	}
+	return sess, nil
+}
+
+// read parses a session file as stored, without loading attachment contents
+func (s *Store) read(id string) (*Session, error) {
+	data, err := s.fs.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("session %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session: %w", err)
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", id, err)
+	}
+	return &sess, nil
+}
+
+// ids returns the IDs of all stored sessions
+func (s *Store) ids() ([]string, error) {
+	entries, err := s.fs.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session directory: %w", err)
+	}
+
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	return ids, nil
+}
+
+// withoutAttachmentContent moves attachment contents into the blob store and
+// returns a copy of the session referencing them by hash only
+func (s *Store) withoutAttachmentContent(sess *Session) (*Session, error) {
+	stored := *sess
+	stored.Messages = make([]conversation.Message, len(sess.Messages))
+	ephemeral := false
+	for i, m := range sess.Messages {
+		if m.Role == "user" {
+			ephemeral = m.ExpiresIn > 0
+		}
+		// an ephemeral message's attachments stay in the session file, which
+		// is written again without them once it expires. a blob would stay
+		if len(m.Attachments) > 0 && !ephemeral {
+			attachments := make([]attach.Attachment, len(m.Attachments))
+			for j, a := range m.Attachments {
+				if a.Content != "" || a.Hash == "" {
+					hash, err := s.blobs.Put([]byte(a.Content))
+					if err != nil {
+						return nil, err
+					}
+					// remember the hash on the live session too so it isn't rehashed every save
+					sess.Messages[i].Attachments[j].Hash = hash
+					a.Hash = hash
+				}
+				a.Content = ""
+				attachments[j] = a
+			}
+			m.Attachments = attachments
+		}
+		stored.Messages[i] = m
+	}
+	return &stored, nil
+}
+
+// loadAttachmentContent fills attachment contents back in from the blob store
+func (s *Store) loadAttachmentContent(sess *Session) error {
+	for i := range sess.Messages {
+		for j := range sess.Messages[i].Attachments {
+			a := &sess.Messages[i].Attachments[j]
+			if a.Hash == "" || a.Content != "" {
+				continue
+			}
+			data, err := s.blobs.Get(a.Hash)
+			if err != nil {
+				return err
+			}
+			a.Content = string(data)
+		}
+	}
+	return nil
+}
+
+// List returns all stored sessions, most recently updated first. sessions
+// that fail to load are logged and left out
+func (s *Store) List() ([]*Session, error) {
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []*Session
+	for _, id := range ids {
+		sess, err := s.Load(id)
+		if err != nil {
+			// one corrupt file shouldn't hide every other session
+			logging.Errorf("skipping session %s: %v", id, err)
+			continue
+		}
+		sessions = append(sessions, sess)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Updated.After(sessions[j].Updated)
+	})
+	return sessions, nil
+}
@@ -0,0 +1,55 @@
+package session
+
+import (
+	"slices"
+	"strings"
+)
+
+// NormalizeTag is how a tag is stored: lowercase, without a leading #
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// splitTags splits tags at commas, the way -tag lists them, so a tag can
+// never hold one and go unmatched by the filter
+func splitTags(tags []string) []string {
+	var split []string
+	for _, tag := range tags {
+		split = append(split, strings.Split(tag, ",")...)
+	}
+	return split
+}
+
+// Tag adds tags to the session, returning the ones it didn't have yet.
+// "a,b" adds a and b
+func (s *Session) Tag(tags ...string) []string {
+	var added []string
+	for _, tag := range splitTags(tags) {
+		tag = NormalizeTag(tag)
+		if tag == "" || s.HasTag(tag) {
+			continue
+		}
+		s.Tags = append(s.Tags, tag)
+		added = append(added, tag)
+	}
+	slices.Sort(s.Tags)
+	return added
+}
+
+// Untag removes tags from the session, returning the ones it had
+func (s *Session) Untag(tags ...string) []string {
+	var removed []string
+	for _, tag := range splitTags(tags) {
+		tag = NormalizeTag(tag)
+		if i := slices.Index(s.Tags, tag); i >= 0 {
+			s.Tags = slices.Delete(s.Tags, i, i+1)
+			removed = append(removed, tag)
+		}
+	}
+	return removed
+}
+
+// HasTag reports whether the session is tagged with tag
+func (s *Session) HasTag(tag string) bool {
+	return slices.Contains(s.Tags, NormalizeTag(tag))
+}
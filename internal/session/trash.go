@@ -0,0 +1,213 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// TrashEntry is a deleted session, or messages deleted from one, kept until
+// the trash is purged so the deletion can be undone
+type TrashEntry struct {
+	ID      string    `json:"id"`
+	Deleted time.Time `json:"deleted"`
+	// Index is where the messages were cut from the session, -1 when the
+	// whole session was deleted
+	Index int `json:"index"`
+	// Session holds the deleted messages only, unless the whole session was deleted
+	Session *Session `json:"session"`
+}
+
+// WholeSession reports whether the entry is a deleted session rather than
+// some of its messages
+func (e TrashEntry) WholeSession() bool {
+	return e.Index < 0
+}
+
+func (s *Store) trashDir() string {
+	return filepath.Join(s.dir, ".trash")
+}
+
+func (s *Store) trashPath(id string) string {
+	return filepath.Join(s.trashDir(), id+".json")
+}
+
+// Delete moves a session to the trash
+func (s *Store) Delete(id string) error {
+	sess, err := s.read(id)
+	if err != nil {
+		return err
+	}
+	if _, err := s.putTrash(TrashEntry{Index: -1, Session: sess}); err != nil {
+		return err
+	}
+	if err := s.fs.Remove(s.path(id)); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// TrashMessages keeps messages deleted from sess at index in the trash
+func (s *Store) TrashMessages(sess *Session, index int, messages []conversation.Message) (TrashEntry, error) {
+	deleted := *sess
+	deleted.Messages = messages
+	return s.putTrash(TrashEntry{Index: index, Session: &deleted})
+}
+
+// putTrash writes a new entry to the trash and returns it with its ID
+func (s *Store) putTrash(entry TrashEntry) (TrashEntry, error) {
+	now := s.clock.Now()
+	entry.ID = newID(now)
+	entry.Deleted = now
+	// ephemeral messages would outlive their expiry in the trash
+	deleted := *entry.Session
+	deleted.Messages = conversation.WithoutEphemeral(deleted.Messages)
+	// attachments stay in the blob store, referenced from the trash
+	stored, err := s.withoutAttachmentContent(&deleted)
+	if err != nil {
+		return entry, err
+	}
+	entry.Session = stored
+
+	if err := s.fs.MkdirAll(s.trashDir(), 0o700); err != nil {
+		return entry, fmt.Errorf("failed to create trash directory: %w", err)
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return entry, fmt.Errorf("failed to marshal trash entry: %w", err)
+	}
+	tmp := s.trashPath(entry.ID) + ".tmp"
+	if err := s.fs.WriteFile(tmp, data, 0o600); err != nil {
+		return entry, fmt.Errorf("failed to write trash entry: %w", err)
+	}
+	if err := s.fs.Rename(tmp, s.trashPath(entry.ID)); err != nil {
+		return entry, fmt.Errorf("failed to save trash entry: %w", err)
+	}
+	return entry, nil
+}
+
+// Trash lists the trash, most recently deleted first. entries that fail to
+// load are logged and left out
+func (s *Store) Trash() ([]TrashEntry, error) {
+	entries, err := s.fs.ReadDir(s.trashDir())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trash: %w", err)
+	}
+
+	var trash []TrashEntry
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		entry, err := s.readTrash(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			// one corrupt entry shouldn't hide the others or stop the purge
+			logging.Errorf("skipping trash entry %s: %v", e.Name(), err)
+			continue
+		}
+		trash = append(trash, entry)
+	}
+	sort.Slice(trash, func(i, j int) bool {
+		return trash[i].Deleted.After(trash[j].Deleted)
+	})
+	return trash, nil
+}
+
+func (s *Store) readTrash(id string) (TrashEntry, error) {
+	var entry TrashEntry
+	if err := checkID(id); err != nil {
+		return entry, err
+	}
+	data, err := s.fs.ReadFile(s.trashPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return entry, fmt.Errorf("%s is not in the trash", id)
+	}
+	if err != nil {
+		return entry, fmt.Errorf("failed to read trash entry: %w", err)
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, fmt.Errorf("failed to parse trash entry %s: %w", id, err)
+	}
+	if entry.Session == nil {
+		return entry, fmt.Errorf("trash entry %s has no session", id)
+	}
+	// the file name is the ID, what's in the file is only trusted as far as
+	// it's checked
+	entry.ID = id
+	if err := checkID(entry.Session.ID); err != nil {
+		return entry, fmt.Errorf("trash entry %s: %w", id, err)
+	}
+	return entry, nil
+}
+
+// Restore puts a trash entry back. a deleted session is saved again, it
+// fails if one with the same ID exists by now. deleted messages are put back
+// where they were cut from their session, or at its end if it got shorter.
+// it fails while another ask has the session open, that ask would write
+// over the restored messages the next time it saves
+func (s *Store) Restore(id string) (TrashEntry, error) {
+	entry, err := s.readTrash(id)
+	if err != nil {
+		return entry, err
+	}
+	if pid := s.OpenElsewhere(entry.Session.ID); pid != 0 {
+		return entry, fmt.Errorf("session %s is open in another ask (pid %d), switch it to another session or quit it first", entry.Session.ID, pid)
+	}
+
+	if entry.WholeSession() {
+		if _, err := s.fs.Stat(s.path(entry.Session.ID)); err == nil {
+			return entry, fmt.Errorf("session %s exists, delete it first", entry.Session.ID)
+		}
+		if err := s.Save(entry.Session); err != nil {
+			return entry, err
+		}
+	} else {
+		sess, err := s.read(entry.Session.ID)
+		if err != nil {
+			return entry, fmt.Errorf("can't restore messages: %w", err)
+		}
+		at := min(entry.Index, len(sess.Messages))
+		sess.Messages = append(sess.Messages[:at], append(entry.Session.Messages, sess.Messages[at:]...)...)
+		sess.Updated = s.clock.Now()
+		if err := s.Save(sess); err != nil {
+			return entry, err
+		}
+	}
+
+	if err := s.fs.Remove(s.trashPath(id)); err != nil {
+		return entry, fmt.Errorf("restored, but failed to remove it from the trash: %w", err)
+	}
+	return entry, nil
+}
+
+// PurgeTrash permanently deletes entries trashed more than olderThan ago,
+// returning how many were removed. their attachments are left to gc
+func (s *Store) PurgeTrash(olderThan time.Duration) (int, error) {
+	trash, err := s.Trash()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := s.clock.Now().Add(-olderThan)
+	purged := 0
+	for _, entry := range trash {
+		if entry.Deleted.After(cutoff) {
+			continue
+		}
+		if err := s.fs.Remove(s.trashPath(entry.ID)); err != nil {
+			return purged, fmt.Errorf("failed to purge trash: %w", err)
+		}
+		purged++
+	}
+	return purged, nil
+}
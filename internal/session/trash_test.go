@@ -0,0 +1,250 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scbenet/ask/internal/attach"
+	"github.com/scbenet/ask/internal/blob"
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/vfs"
+)
+
+// newTestStore returns a store in memory whose time only moves when told to
+func newTestStore(t *testing.T) (*Store, *clock.Fake) {
+	t.Helper()
+	c := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	fsys := vfs.NewMem(c)
+	blobs, err := blob.NewStore("/data/blobs", blob.WithFS(fsys))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore("/data/sessions", blobs, WithFS(fsys), WithClock(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, c
+}
+
+func TestPurgeTrash(t *testing.T) {
+	store, c := newTestStore(t)
+	sess := New("one")
+	sess.Messages = []conversation.Message{{Role: "user", Content: "hi"}}
+	if err := store.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(sess.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Advance(29 * 24 * time.Hour)
+	if n, err := store.PurgeTrash(30 * 24 * time.Hour); err != nil || n != 0 {
+		t.Fatalf("purged %d (%v) before the retention was up", n, err)
+	}
+	c.Advance(2 * 24 * time.Hour)
+	if n, err := store.PurgeTrash(30 * 24 * time.Hour); err != nil || n != 1 {
+		t.Fatalf("purged %d (%v), want 1", n, err)
+	}
+	if trash, _ := store.Trash(); len(trash) != 0 {
+		t.Fatalf("%d entries left in the trash", len(trash))
+	}
+}
+
+// TestTrashSkipsCorrupt lists and purges the entries that load even when
+// one doesn't
+func TestTrashSkipsCorrupt(t *testing.T) {
+	store, c := newTestStore(t)
+	sess := New("one")
+	if err := store.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.fs.WriteFile(store.trashPath("20250101-120000-abcdef"), []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	trash, err := store.Trash()
+	if err != nil || len(trash) != 1 || trash[0].Session.ID != sess.ID {
+		t.Fatalf("trash is %v (%v), want only %s", trash, err, sess.ID)
+	}
+	c.Advance(31 * 24 * time.Hour)
+	if n, err := store.PurgeTrash(30 * 24 * time.Hour); err != nil || n != 1 {
+		t.Fatalf("purged %d (%v), want 1", n, err)
+	}
+}
+
+func TestRestoreFromTrash(t *testing.T) {
+	store, _ := newTestStore(t)
+	sess := New("one")
+	sess.Messages = []conversation.Message{{Role: "user", Content: "hi", Attachments: []attach.Attachment{{Name: "a.txt", Content: "contents"}}}}
+	if err := store.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	trash, err := store.Trash()
+	if err != nil || len(trash) != 1 {
+		t.Fatalf("trash is %v (%v), want the session", trash, err)
+	}
+	if _, err := store.Restore(trash[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := store.Load(sess.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.Messages[0].Attachments[0].Content; got != "contents" {
+		t.Fatalf("attachment restored as %q", got)
+	}
+}
+
+func TestTrashIDs(t *testing.T) {
+	store, _ := newTestStore(t)
+	for _, id := range []string{"", "..", "../sessions/x", "/etc/passwd", "a/b"} {
+		if _, err := store.Restore(id); err == nil || !strings.Contains(err.Error(), "not a valid ID") {
+			t.Errorf("Restore(%q) = %v", id, err)
+		}
+	}
+}
+
+func TestLoadIDs(t *testing.T) {
+	store, _ := newTestStore(t)
+	for _, id := range []string{"", "..", "../trash/x", "/etc/passwd", "a/b"} {
+		if _, err := store.Load(id); err == nil || !strings.Contains(err.Error(), "not a valid ID") {
+			t.Errorf("Load(%q) = %v", id, err)
+		}
+	}
+}
+
+// TestListSkipsCorrupt lists the sessions that load even when one doesn't
+func TestListSkipsCorrupt(t *testing.T) {
+	store, _ := newTestStore(t)
+	sess := New("one")
+	if err := store.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.fs.WriteFile(store.path("20250101-120000-abcdef"), []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != sess.ID {
+		t.Fatalf("listed %v, want only %s", sessions, sess.ID)
+	}
+}
+
+// TestRestoreIntoOpenSession refuses to restore messages into a session
+// another running ask would overwrite them in
+func TestRestoreIntoOpenSession(t *testing.T) {
+	store, _ := newTestStore(t)
+	sess := New("one")
+	sess.Messages = []conversation.Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}}
+	if err := store.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := store.TrashMessages(sess, 1, sess.Messages[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// marked open by this process: restoring from here is fine
+	if err := store.SetOpen(sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	if store.OpenElsewhere(sess.ID) != 0 {
+		t.Fatal("this process counts as another ask")
+	}
+	if err := store.SetOpen(""); err != nil {
+		t.Fatal(err)
+	}
+
+	// the parent process is running, the test binary's go test
+	other := fmt.Sprintf("%s.%d", sess.ID, os.Getppid())
+	if err := store.fs.WriteFile(filepath.Join(store.openDir(), other), nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Restore(entry.ID); err == nil || !strings.Contains(err.Error(), "open in another ask") {
+		t.Fatalf("restored into an open session: %v", err)
+	}
+	if err := store.fs.Remove(filepath.Join(store.openDir(), other)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Restore(entry.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestEphemeralOutlivesNothing keeps ephemeral messages out of the trash,
+// recovery snapshots and the blob store
+func TestEphemeralOutlivesNothing(t *testing.T) {
+	store, _ := newTestStore(t)
+	sess := New("one")
+	sess.Messages = []conversation.Message{
+		{Role: "user", Content: "secret", ExpiresIn: 2, Attachments: []attach.Attachment{{Name: "key.txt", Content: "sk-1"}}},
+		{Role: "assistant", Content: "noted"},
+	}
+	if err := store.Save(sess); err != nil {
+		t.Fatal(err)
+	}
+	if blobs, _ := store.Blobs().List(); len(blobs) != 0 {
+		t.Errorf("the ephemeral attachment went to the blob store")
+	}
+	loaded, err := store.Load(sess.ID)
+	if err != nil || loaded.Messages[0].Attachments[0].Content != "sk-1" {
+		t.Fatalf("the ephemeral attachment wasn't kept with the session: %v", err)
+	}
+
+	entry, err := store.TrashMessages(sess, 0, sess.Messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trashed, _ := store.readTrash(entry.ID); trashed.Session.Messages[0].Content == "secret" || trashed.Session.Messages[1].Content == "noted" {
+		t.Errorf("the trash kept %+v", trashed.Session.Messages)
+	}
+
+	if err := store.SaveRecovery(sess); err != nil {
+		t.Fatal(err)
+	}
+	all, err := store.recoveries()
+	if err != nil || len(all) != 1 {
+		t.Fatalf("recoveries are %v (%v)", all, err)
+	}
+	if got := all[0].Session.Messages[0]; got.Content == "secret" || len(got.Attachments) != 0 {
+		t.Errorf("the recovery snapshot kept %+v", got)
+	}
+	if sess.Messages[0].Content != "secret" {
+		t.Error("the live session was changed")
+	}
+}
+
+// TestCollectGarbageGracePeriod checks blobs nothing references yet are
+// only collected once they are older than the grace period
+func TestCollectGarbageGracePeriod(t *testing.T) {
+	store, c := newTestStore(t)
+	if _, err := store.Blobs().Put([]byte("orphan")); err != nil {
+		t.Fatal(err)
+	}
+
+	if report, err := store.CollectGarbage(false); err != nil || report.BlobsRemoved != 0 {
+		t.Fatalf("removed %d blobs (%v) within the grace period", report.BlobsRemoved, err)
+	}
+	c.Advance(gcGracePeriod + time.Minute)
+	report, err := store.CollectGarbage(false)
+	if err != nil || report.BlobsRemoved != 1 {
+		t.Fatalf("removed %d blobs (%v), want 1", report.BlobsRemoved, err)
+	}
+	if blobs, _ := store.Blobs().List(); len(blobs) != 0 {
+		t.Fatalf("%d blobs left", len(blobs))
+	}
+}
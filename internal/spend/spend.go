@@ -0,0 +1,170 @@
+// Package spend keeps a running total of what requests cost per day, across
+// every ask that's running, for the daily budget
+package spend
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/paths"
+	"github.com/scbenet/ask/internal/vfs"
+)
+
+// days older than this are dropped from the file
+const keepDays = 31
+
+const dayLayout = "2006-01-02"
+
+const (
+	// how long to wait for another ask to finish adding to the file
+	lockTimeout = 2 * time.Second
+	// a lock older than this was left by an ask that died holding it
+	staleLock = 10 * time.Second
+)
+
+// Ledger is a JSON file of USD spent per local calendar day. it is read
+// again before every use since other asks add to it too, and locked while
+// it's added to so two asks don't both write their total
+type Ledger struct {
+	path  string
+	fs    vfs.FS
+	clock clock.Clock
+
+	mu sync.Mutex
+}
+
+// Option configures a Ledger
+type Option func(*Ledger)
+
+// WithFS keeps the ledger on fsys instead of the disk
+func WithFS(fsys vfs.FS) Option {
+	return func(l *Ledger) { l.fs = fsys }
+}
+
+// WithClock decides what day it is with c instead of the system clock
+func WithClock(c clock.Clock) Option {
+	return func(l *Ledger) { l.clock = c }
+}
+
+// DefaultPath returns where spending is recorded, spend.json in
+// paths.Data
+func DefaultPath() (string, error) {
+	dir, err := paths.Data()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "spend.json"), nil
+}
+
+// Open returns the ledger at path, the file is only created once something
+// is spent
+func Open(path string, opts ...Option) *Ledger {
+	l := &Ledger{path: path, fs: vfs.OS, clock: clock.System}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Today returns what was spent today
+func (l *Ledger) Today() (float64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	days, err := l.read()
+	if err != nil {
+		return 0, err
+	}
+	return days[l.clock.Now().Format(dayLayout)], nil
+}
+
+// Add records usd spent just now
+func (l *Ledger) Add(usd float64) error {
+	if usd <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.fs.MkdirAll(filepath.Dir(l.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create spend directory: %w", err)
+	}
+	unlock, err := l.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	days, err := l.read()
+	if err != nil {
+		return err
+	}
+	now := l.clock.Now()
+	days[now.Format(dayLayout)] += usd
+	oldest := now.AddDate(0, 0, -keepDays).Format(dayLayout)
+	for day := range days {
+		// the layout sorts like the dates do
+		if day < oldest {
+			delete(days, day)
+		}
+	}
+
+	data, err := json.MarshalIndent(days, "", "  ")
+	if err != nil {
+		return err
+	}
+	// written aside and renamed, so another ask never reads half a file
+	tmp := fmt.Sprintf("%s.%d.tmp", l.path, os.Getpid())
+	if err := l.fs.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write spend: %w", err)
+	}
+	if err := l.fs.Rename(tmp, l.path); err != nil {
+		return fmt.Errorf("failed to write spend: %w", err)
+	}
+	return nil
+}
+
+// lock creates the lock file next to the ledger, waiting for another ask
+// to remove it. callers hold mu
+func (l *Ledger) lock() (func(), error) {
+	path := l.path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := l.fs.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			f.Close()
+			return func() { _ = l.fs.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("failed to lock spend: %w", err)
+		}
+		if info, err := l.fs.Stat(path); err == nil && l.clock.Now().Sub(info.ModTime()) > staleLock {
+			_ = l.fs.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to lock spend: %s is held by another ask", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// read loads the file, callers hold mu
+func (l *Ledger) read() (map[string]float64, error) {
+	days := map[string]float64{}
+	data, err := l.fs.ReadFile(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return days, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spend: %w", err)
+	}
+	if err := json.Unmarshal(data, &days); err != nil {
+		return nil, fmt.Errorf("failed to parse spend %s: %w", l.path, err)
+	}
+	return days, nil
+}
@@ -0,0 +1,82 @@
+package spend
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/vfs"
+)
+
+// TestLedger adds up what's spent per day, across ledgers on the same file
+func TestLedger(t *testing.T) {
+	c := clock.NewFake(time.Date(2025, 1, 1, 23, 0, 0, 0, time.Local))
+	fsys := vfs.NewMem(c)
+	one := Open("/data/spend.json", WithFS(fsys), WithClock(c))
+	two := Open("/data/spend.json", WithFS(fsys), WithClock(c))
+
+	if today, err := one.Today(); err != nil || today != 0 {
+		t.Fatalf("today is %v, %v before anything was spent", today, err)
+	}
+	for _, l := range []*Ledger{one, two, one} {
+		if err := l.Add(0.25); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if today, err := two.Today(); err != nil || today != 0.75 {
+		t.Fatalf("today is %v, %v, want 0.75", today, err)
+	}
+
+	c.Advance(2 * time.Hour)
+	if today, _ := one.Today(); today != 0 {
+		t.Fatalf("the next day starts at %v", today)
+	}
+
+	// old days are dropped once something is added
+	c.Advance(keepDays * 24 * time.Hour)
+	if err := one.Add(1); err != nil {
+		t.Fatal(err)
+	}
+	days, err := one.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 1 {
+		t.Fatalf("kept %v", days)
+	}
+}
+
+// TestLedgerLock keeps concurrent adds from separate ledgers, as from
+// separate asks, from losing each other's spend
+func TestLedgerLock(t *testing.T) {
+	c := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.Local))
+	fsys := vfs.NewMem(c)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := Open("/data/spend.json", WithFS(fsys), WithClock(c)).Add(0.5); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if today, err := Open("/data/spend.json", WithFS(fsys), WithClock(c)).Today(); err != nil || today != 4 {
+		t.Fatalf("today is %v, %v, want 4", today, err)
+	}
+
+	// left behind by an ask that died holding it
+	if err := fsys.WriteFile("/data/spend.json.lock", nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c.Advance(staleLock + time.Second)
+	if err := Open("/data/spend.json", WithFS(fsys), WithClock(c)).Add(1); err != nil {
+		t.Fatalf("a stale lock wasn't taken over: %v", err)
+	}
+	if _, err := fsys.Stat("/data/spend.json.lock"); err == nil {
+		t.Fatal("the lock was left behind")
+	}
+}
@@ -0,0 +1,27 @@
+package sysopen
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// Open opens a file or URL with the platform's default handler
+func Open(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to open %s: %w", target, err)
+	}
+	// don't leave a zombie behind, the opener usually exits right away
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,161 @@
+// Package textdiff compares two texts word by word, for showing what a
+// regenerated response changed
+package textdiff
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Kind says whether a span of text is in both texts or only one
+type Kind int
+
+const (
+	Equal  Kind = iota
+	Delete      // only in the old text
+	Insert      // only in the new text
+)
+
+// Span is a run of words with the same Kind
+type Span struct {
+	Kind Kind
+	Text string
+}
+
+// maxCells bounds the table of the comparison, texts that differ in more
+// words than fit are shown as replaced entirely
+const maxCells = 4 << 20
+
+// Words returns the spans turning old into new, changes are whole words
+// and the whitespace between them
+func Words(old, new string) []Span {
+	return compare(tokens(old), tokens(new))
+}
+
+// Lines returns the spans turning old into new line by line, every span
+// is one or more whole lines with their line breaks
+func Lines(old, new string) []Span {
+	return compare(lines(old), lines(new))
+}
+
+// compare returns the spans turning the tokens of a into those of b
+func compare(a, b []string) []Span {
+
+	// what's the same at either end needs no comparing
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var spans []Span
+	add := func(kind Kind, text string) {
+		if text == "" {
+			return
+		}
+		if n := len(spans); n > 0 && spans[n-1].Kind == kind {
+			spans[n-1].Text += text
+			return
+		}
+		spans = append(spans, Span{kind, text})
+	}
+	for _, t := range a[:prefix] {
+		add(Equal, t)
+	}
+	middle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], add)
+	for _, t := range a[len(a)-suffix:] {
+		add(Equal, t)
+	}
+	return spans
+}
+
+// middle adds the longest common subsequence of a and b as Equal spans,
+// the rest as deleted or inserted
+func middle(a, b []string, add func(Kind, string)) {
+	if len(a)*len(b) > maxCells || len(a) == 0 || len(b) == 0 {
+		for _, t := range a {
+			add(Delete, t)
+		}
+		for _, t := range b {
+			add(Insert, t)
+		}
+		return
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	width := len(b) + 1
+	lcs := make([]int32, (len(a)+1)*width)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i+1)*width+j], lcs[i*width+j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			add(Equal, a[i])
+			i++
+			j++
+		case lcs[(i+1)*width+j] >= lcs[i*width+j+1]:
+			add(Delete, a[i])
+			i++
+		default:
+			add(Insert, b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		add(Delete, a[i])
+	}
+	for ; j < len(b); j++ {
+		add(Insert, b[j])
+	}
+}
+
+// tokens splits s into words and the whitespace between them, joining
+// them gives s back
+func tokens(s string) []string {
+	var out []string
+	start := 0
+	for i, r := range s {
+		if i > start && unicode.IsSpace(r) != unicode.IsSpace(rune(s[start])) {
+			out = append(out, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		out = append(out, s[start:])
+	}
+	return out
+}
+
+// lines splits s after every line break
+func lines(s string) []string {
+	out := strings.SplitAfter(s, "\n")
+	if out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	return out
+}
+
+// Changed counts the words removed and added
+func Changed(spans []Span) (removed, added int) {
+	for _, s := range spans {
+		switch s.Kind {
+		case Delete:
+			removed += len(strings.Fields(s.Text))
+		case Insert:
+			added += len(strings.Fields(s.Text))
+		}
+	}
+	return removed, added
+}
@@ -0,0 +1,65 @@
+package textdiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []Span
+	}{
+		{"same", "a b", "a b", []Span{{Equal, "a b"}}},
+		{"empty", "", "", nil},
+		{"added", "", "hi there", []Span{{Insert, "hi there"}}},
+		{"replaced word", "the sky is blue today", "the sky is green today", []Span{
+			{Equal, "the sky is "}, {Delete, "blue"}, {Insert, "green"}, {Equal, " today"},
+		}},
+		{"moved words", "one two three", "two three one", []Span{
+			{Delete, "one "}, {Equal, "two three"}, {Insert, " one"},
+		}},
+		{"newlines", "a\nb c", "a\nb d\ne", []Span{
+			{Equal, "a\nb "}, {Delete, "c"}, {Insert, "d\ne"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Words(tt.old, tt.new)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Words(%q, %q) = %q, want %q", tt.old, tt.new, got, tt.want)
+			}
+			var old, new string
+			for _, s := range got {
+				if s.Kind != Insert {
+					old += s.Text
+				}
+				if s.Kind != Delete {
+					new += s.Text
+				}
+			}
+			if old != tt.old || new != tt.new {
+				t.Errorf("spans give back %q and %q", old, new)
+			}
+		})
+	}
+}
+
+func TestChanged(t *testing.T) {
+	removed, added := Changed(Words("a b c", "a x y c"))
+	if removed != 1 || added != 2 {
+		t.Errorf("Changed = %d removed, %d added, want 1 and 2", removed, added)
+	}
+}
+
+func TestLines(t *testing.T) {
+	spans := Lines("a\nb\nc\n", "a\nB\nc\nd\n")
+	want := []Span{{Equal, "a\n"}, {Delete, "b\n"}, {Insert, "B\n"}, {Equal, "c\n"}, {Insert, "d\n"}}
+	if !reflect.DeepEqual(spans, want) {
+		t.Errorf("Lines = %q, want %q", spans, want)
+	}
+	if spans := Lines("", "x\n"); !reflect.DeepEqual(spans, []Span{{Insert, "x\n"}}) {
+		t.Errorf("Lines of a new file = %q", spans)
+	}
+}
@@ -0,0 +1,264 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/scbenet/ask/internal/config"
+)
+
+// output beyond this is dropped outright, the executor's configured
+// truncation decides how much of the rest reaches the model
+const maxOutputBytes = 1024 * 1024
+
+// RegisterDefaults adds the built in tools to r. cfg supplies the domains
+// the http tools may reach
+func RegisterDefaults(r *Registry, cfg config.ToolsConfig) {
+	r.Register(runCommand{})
+	r.Register(readFile{})
+	r.Register(writeFile{})
+	r.Register(calculator{})
+	r.Register(dateTime{})
+	r.Register(unitConverter{})
+	r.Register(searchCode{})
+	// the http tools are only offered once the user has allowed some domains
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		t := httpRequest{method: method}
+		if t.domains = cfg.ToolPolicy(t.Name()).Domains; len(t.domains) > 0 {
+			r.Register(t)
+		}
+	}
+}
+
+func schema(required []string, props map[string]any) map[string]any {
+	return map[string]any{
+		"type":       "object",
+		"properties": props,
+		"required":   required,
+	}
+}
+
+func stringProp(description string) map[string]any {
+	return map[string]any{"type": "string", "description": description}
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
+
+func absPath(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("path is required")
+	}
+	return filepath.Abs(expandHome(path))
+}
+
+// runCommand executes a program directly, without a shell, so the program
+// being run is always the one the policy was checked against
+type runCommand struct{}
+
+type runCommandArgs struct {
+	Command string `json:"command"`
+}
+
+func (runCommand) Name() string { return "run_command" }
+func (runCommand) Description() string {
+	return "Run a program in the current directory and return its combined output. The command is split into arguments like a shell would, but no shell features (pipes, redirects, globs, &&) are available."
+}
+func (runCommand) Parameters() map[string]any {
+	return schema([]string{"command"}, map[string]any{
+		"command": stringProp("the command line to run, e.g. `go test ./...`"),
+	})
+}
+
+func (runCommand) parse(raw json.RawMessage) ([]string, error) {
+	var args runCommandArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, err
+	}
+	argv, err := splitCommand(args.Command)
+	if err != nil {
+		return nil, err
+	}
+	if len(argv) == 0 {
+		return nil, errors.New("command is empty")
+	}
+	return argv, nil
+}
+
+func (t runCommand) Access(raw json.RawMessage) (Access, error) {
+	argv, err := t.parse(raw)
+	if err != nil {
+		return Access{}, err
+	}
+	// the program may do anything, the policy can only refuse it by name
+	return Access{Command: argv[0], Unchecked: true}, nil
+}
+
+func (t runCommand) Run(ctx context.Context, raw json.RawMessage) (string, error) {
+	argv, err := t.parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	out, err := cmd.CombinedOutput()
+	output := truncate(string(out))
+	if ctx.Err() != nil {
+		return output, fmt.Errorf("command timed out: %w", ctx.Err())
+	}
+	if err != nil {
+		// a failing command is still a useful result, e.g. failing tests
+		return fmt.Sprintf("%s\n(exit status: %v)", output, err), nil
+	}
+	return output, nil
+}
+
+// splitCommand splits a command line into arguments, honouring single and
+// double quotes and backslash escapes
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg, escaped := false, false
+	var quote rune
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			cur.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote in command")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+type readFile struct{}
+
+type pathArgs struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+func (readFile) Name() string        { return "read_file" }
+func (readFile) Description() string { return "Read a text file and return its contents." }
+func (readFile) Parameters() map[string]any {
+	return schema([]string{"path"}, map[string]any{
+		"path": stringProp("path of the file, relative to the current directory"),
+	})
+}
+func (readFile) Access(raw json.RawMessage) (Access, error) {
+	var args pathArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return Access{}, err
+	}
+	path, err := absPath(args.Path)
+	if err != nil {
+		return Access{}, err
+	}
+	return Access{Reads: []string{path}}, nil
+}
+func (readFile) Run(ctx context.Context, raw json.RawMessage) (string, error) {
+	var args pathArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	path, err := absPath(args.Path)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", fmt.Errorf("%s looks like a binary file", args.Path)
+	}
+	return truncate(string(data)), nil
+}
+
+type writeFile struct{}
+
+func (writeFile) Name() string { return "write_file" }
+func (writeFile) Description() string {
+	return "Create or overwrite a text file with the given content."
+}
+func (writeFile) Parameters() map[string]any {
+	return schema([]string{"path", "content"}, map[string]any{
+		"path":    stringProp("path of the file, relative to the current directory"),
+		"content": stringProp("the full new contents of the file"),
+	})
+}
+func (writeFile) Access(raw json.RawMessage) (Access, error) {
+	var args pathArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return Access{}, err
+	}
+	path, err := absPath(args.Path)
+	if err != nil {
+		return Access{}, err
+	}
+	return Access{Writes: []string{path}}, nil
+}
+func (writeFile) Run(ctx context.Context, raw json.RawMessage) (string, error) {
+	var args pathArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	path, err := absPath(args.Path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(args.Content), 0o644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("wrote %d bytes to %s", len(args.Content), args.Path), nil
+}
+
+func truncate(s string) string {
+	if len(s) <= maxOutputBytes {
+		return s
+	}
+	return s[:maxOutputBytes] + fmt.Sprintf("\n… (%d more bytes dropped)", len(s)-maxOutputBytes)
+}
@@ -0,0 +1,26 @@
+package tools
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := map[string][]string{
+		`go test ./...`:           {"go", "test", "./..."},
+		`git commit -m "a b"`:     {"git", "commit", "-m", "a b"},
+		`echo 'it''s' "\"q\""`:    {"echo", "its", `"q"`},
+		`grep a\ b  file`:         {"grep", "a b", "file"},
+		`echo ''`:                 {"echo", ""},
+		`echo 'no $HOME \n here'`: {"echo", `no $HOME \n here`},
+	}
+	for line, want := range tests {
+		got, err := splitCommand(line)
+		if err != nil || !slices.Equal(got, want) {
+			t.Errorf("splitCommand(%s) = %q, %v, want %q", line, got, err, want)
+		}
+	}
+	if _, err := splitCommand(`echo "open`); err == nil {
+		t.Error("an unterminated quote was accepted")
+	}
+}
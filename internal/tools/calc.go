@@ -0,0 +1,321 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// calculator evaluates arithmetic exactly as written so models don't have
+// to do it in their heads
+type calculator struct{}
+
+type calculatorArgs struct {
+	Expression string `json:"expression"`
+}
+
+func (calculator) Name() string { return "calculate" }
+func (calculator) Description() string {
+	return "Evaluate an arithmetic expression. Supports + - * / % ^, parentheses, the constants pi and e, and the functions sqrt, abs, round, floor, ceil, exp, ln, log10, log2, sin, cos, tan, asin, acos, atan, min and max. Trigonometry uses radians."
+}
+func (calculator) Parameters() map[string]any {
+	return schema([]string{"expression"}, map[string]any{
+		"expression": stringProp("the expression, e.g. `(1.5e3 + 2^10) * 0.07`"),
+	})
+}
+func (calculator) Access(raw json.RawMessage) (Access, error) {
+	var args calculatorArgs
+	return Access{}, json.Unmarshal(raw, &args)
+}
+func (calculator) Run(ctx context.Context, raw json.RawMessage) (string, error) {
+	var args calculatorArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	v, err := Evaluate(args.Expression)
+	if err != nil {
+		return "", err
+	}
+	return formatNumber(v), nil
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', 15, 64)
+}
+
+// Evaluate computes the value of an arithmetic expression
+func Evaluate(expr string) (float64, error) {
+	p := &exprParser{input: expr}
+	p.next()
+	v, err := p.expression()
+	if err != nil {
+		return 0, err
+	}
+	if p.tok.kind != tokEOF {
+		return 0, fmt.Errorf("unexpected %q at position %d", p.tok.text, p.tok.pos+1)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+	return v, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+// exprParser is a recursive descent parser, lowest precedence first:
+// expression = term {(+|-) term}, term = unary {(*|/|%) unary},
+// unary = -unary | power, power = primary [^ unary]
+type exprParser struct {
+	input string
+	pos   int
+	tok   token
+	err   error
+}
+
+func (p *exprParser) next() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.input) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+
+	c := p.input[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.input) && (isDigit(p.input[p.pos]) || p.input[p.pos] == '.' || p.input[p.pos] == '_') {
+			p.pos++
+		}
+		// exponent, e.g. 1.5e3 or 2E-4
+		if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+			end := p.pos + 1
+			if end < len(p.input) && (p.input[end] == '+' || p.input[end] == '-') {
+				end++
+			}
+			if end < len(p.input) && isDigit(p.input[end]) {
+				for end < len(p.input) && isDigit(p.input[end]) {
+					end++
+				}
+				p.pos = end
+			}
+		}
+		text := p.input[start:p.pos]
+		num, err := strconv.ParseFloat(strings.ReplaceAll(text, "_", ""), 64)
+		if err != nil && p.err == nil {
+			p.err = fmt.Errorf("invalid number %q", text)
+		}
+		p.tok = token{kind: tokNumber, text: text, num: num, pos: start}
+	case unicode.IsLetter(rune(c)):
+		for p.pos < len(p.input) && (unicode.IsLetter(rune(p.input[p.pos])) || isDigit(p.input[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokIdent, text: strings.ToLower(p.input[start:p.pos]), pos: start}
+	default:
+		p.pos++
+		text := string(c)
+		// ** is a common spelling of ^
+		if c == '*' && p.pos < len(p.input) && p.input[p.pos] == '*' {
+			p.pos++
+			text = "^"
+		}
+		p.tok = token{kind: tokOp, text: text, pos: start}
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *exprParser) expression() (float64, error) {
+	v, err := p.term()
+	for err == nil && p.tok.kind == tokOp && (p.tok.text == "+" || p.tok.text == "-") {
+		op := p.tok.text
+		p.next()
+		var rhs float64
+		if rhs, err = p.term(); err == nil {
+			if op == "+" {
+				v += rhs
+			} else {
+				v -= rhs
+			}
+		}
+	}
+	return v, err
+}
+
+func (p *exprParser) term() (float64, error) {
+	v, err := p.unary()
+	for err == nil && p.tok.kind == tokOp && strings.Contains("*/%", p.tok.text) {
+		op := p.tok.text
+		p.next()
+		var rhs float64
+		if rhs, err = p.unary(); err != nil {
+			break
+		}
+		switch op {
+		case "*":
+			v *= rhs
+		case "/":
+			if rhs == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			v /= rhs
+		case "%":
+			if rhs == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			v = math.Mod(v, rhs)
+		}
+	}
+	return v, err
+}
+
+func (p *exprParser) unary() (float64, error) {
+	if p.tok.kind == tokOp && (p.tok.text == "-" || p.tok.text == "+") {
+		neg := p.tok.text == "-"
+		p.next()
+		v, err := p.unary()
+		if neg {
+			v = -v
+		}
+		return v, err
+	}
+	return p.power()
+}
+
+func (p *exprParser) power() (float64, error) {
+	base, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	if p.tok.kind == tokOp && p.tok.text == "^" {
+		p.next()
+		// right associative, and binds tighter than unary minus on the left: -2^2 = -4
+		exp, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		return math.Pow(base, exp), nil
+	}
+	return base, nil
+}
+
+func (p *exprParser) primary() (float64, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		p.next()
+		return tok.num, nil
+	case tokIdent:
+		p.next()
+		if p.tok.kind == tokOp && p.tok.text == "(" {
+			return p.call(tok.text)
+		}
+		switch tok.text {
+		case "pi":
+			return math.Pi, nil
+		case "e":
+			return math.E, nil
+		}
+		return 0, fmt.Errorf("unknown name %q", tok.text)
+	case tokOp:
+		if tok.text == "(" {
+			p.next()
+			v, err := p.expression()
+			if err != nil {
+				return 0, err
+			}
+			if p.tok.kind != tokOp || p.tok.text != ")" {
+				return 0, fmt.Errorf("missing ) at position %d", p.tok.pos+1)
+			}
+			p.next()
+			return v, nil
+		}
+		return 0, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos+1)
+	}
+	return 0, fmt.Errorf("unexpected end of expression")
+}
+
+var unaryFuncs = map[string]func(float64) float64{
+	"sqrt":  math.Sqrt,
+	"abs":   math.Abs,
+	"round": math.Round,
+	"floor": math.Floor,
+	"ceil":  math.Ceil,
+	"exp":   math.Exp,
+	"ln":    math.Log,
+	"log":   math.Log10,
+	"log10": math.Log10,
+	"log2":  math.Log2,
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"asin":  math.Asin,
+	"acos":  math.Acos,
+	"atan":  math.Atan,
+}
+
+// call parses the arguments of a function call, the ( is the current token
+func (p *exprParser) call(name string) (float64, error) {
+	p.next()
+	var args []float64
+	for !(p.tok.kind == tokOp && p.tok.text == ")") {
+		v, err := p.expression()
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, v)
+		if p.tok.kind == tokOp && p.tok.text == "," {
+			p.next()
+		} else if !(p.tok.kind == tokOp && p.tok.text == ")") {
+			return 0, fmt.Errorf("missing ) after arguments to %s", name)
+		}
+	}
+	p.next()
+
+	if f, ok := unaryFuncs[name]; ok {
+		if len(args) != 1 {
+			return 0, fmt.Errorf("%s takes 1 argument, got %d", name, len(args))
+		}
+		return f(args[0]), nil
+	}
+	switch name {
+	case "min", "max":
+		if len(args) == 0 {
+			return 0, fmt.Errorf("%s needs at least 1 argument", name)
+		}
+		v := args[0]
+		for _, a := range args[1:] {
+			if name == "min" {
+				v = math.Min(v, a)
+			} else {
+				v = math.Max(v, a)
+			}
+		}
+		return v, nil
+	}
+	return 0, fmt.Errorf("unknown function %q", name)
+}
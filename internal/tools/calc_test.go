@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := map[string]float64{
+		"1 + 2 * 3":                7,
+		"(1 + 2) * 3":              9,
+		"2^3^2":                    512,
+		"2 ** 10":                  1024,
+		"-2^2":                     -4,
+		"10 % 4":                   2,
+		"1.5e3 + 1_000":            2500,
+		"sqrt(16) + abs(-3)":       7,
+		"max(1, 5, 3) - min(2)":    3,
+		"round(2.5) + floor(-1.5)": 1,
+		"log10(1000) + log2(8)":    6,
+		"ln(e)":                    1,
+		"cos(pi)":                  -1,
+		"+-+3":                     -3,
+	}
+	for expr, want := range tests {
+		got, err := Evaluate(expr)
+		if err != nil || math.Abs(got-want) > 1e-9 {
+			t.Errorf("Evaluate(%q) = %v, %v, want %v", expr, got, err, want)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := map[string]string{
+		"1 / 0":      "division by zero",
+		"5 % 0":      "division by zero",
+		"(1 + 2":     "missing )",
+		"1 +":        "unexpected end",
+		"2 3":        `unexpected "3"`,
+		"foo + 1":    `unknown name "foo"`,
+		"bar(1)":     `unknown function "bar"`,
+		"sqrt(1, 2)": "takes 1 argument",
+		"max()":      "at least 1 argument",
+		"1..2":       "invalid number",
+		"sqrt(-1)":   "not a finite number",
+	}
+	for expr, want := range tests {
+		if _, err := Evaluate(expr); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Evaluate(%q) = %v, want an error containing %q", expr, err, want)
+		}
+	}
+}
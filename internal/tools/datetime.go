@@ -0,0 +1,196 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dateTime answers questions about the current time, timezones and date
+// arithmetic, which models otherwise guess at
+type dateTime struct {
+	now func() time.Time
+}
+
+type dateTimeArgs struct {
+	Operation string `json:"operation"`
+	Time      string `json:"time"`
+	Other     string `json:"other"`
+	Timezone  string `json:"timezone"`
+	To        string `json:"to_timezone"`
+	Duration  string `json:"duration"`
+}
+
+// layouts accepted for times, tried in order
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"15:04:05",
+	"15:04",
+}
+
+const timeOutputLayout = "Monday 2006-01-02 15:04:05 MST (-07:00)"
+
+func (dateTime) Name() string { return "datetime" }
+func (dateTime) Description() string {
+	return "Date and time calculations. Operations: `now` (current time in `timezone`), " +
+		"`convert` (`time` in `timezone` to `to_timezone`), `add` (`time` plus `duration`, negative to subtract) " +
+		"and `diff` (duration from `time` to `other`). Times are RFC 3339 or `YYYY-MM-DD [HH:MM[:SS]]`, " +
+		"timezones are IANA names like Europe/Berlin (default local time), durations look like `1d12h30m` or `-2w`."
+}
+func (dateTime) Parameters() map[string]any {
+	return schema([]string{"operation"}, map[string]any{
+		"operation": map[string]any{
+			"type": "string",
+			"enum": []string{"now", "convert", "add", "diff"},
+		},
+		"time":        stringProp("the time to work with, defaults to now"),
+		"other":       stringProp("second time for diff"),
+		"timezone":    stringProp("IANA timezone `time` is in and results are shown in"),
+		"to_timezone": stringProp("IANA timezone to convert to"),
+		"duration":    stringProp("duration for add, units w d h m s"),
+	})
+}
+func (dateTime) Access(raw json.RawMessage) (Access, error) {
+	var args dateTimeArgs
+	return Access{}, json.Unmarshal(raw, &args)
+}
+
+func (t dateTime) Run(ctx context.Context, raw json.RawMessage) (string, error) {
+	var args dateTimeArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+
+	loc, err := loadLocation(args.Timezone)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now
+	if t.now != nil {
+		now = t.now
+	}
+	at, err := parseTime(args.Time, loc, now())
+	if err != nil {
+		return "", err
+	}
+
+	switch args.Operation {
+	case "now", "":
+		return at.Format(timeOutputLayout), nil
+	case "convert":
+		to, err := loadLocation(args.To)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s = %s", at.Format(timeOutputLayout), at.In(to).Format(timeOutputLayout)), nil
+	case "add":
+		d, err := parseLongDuration(args.Duration)
+		if err != nil {
+			return "", err
+		}
+		return at.Add(d).Format(timeOutputLayout), nil
+	case "diff":
+		if args.Other == "" {
+			return "", fmt.Errorf("diff needs other")
+		}
+		other, err := parseTime(args.Other, loc, now())
+		if err != nil {
+			return "", err
+		}
+		d := other.Sub(at)
+		return fmt.Sprintf("%s (%s days, %s hours)", formatLongDuration(d),
+			strconv.FormatFloat(d.Hours()/24, 'f', -1, 64),
+			strconv.FormatFloat(d.Hours(), 'f', -1, 64)), nil
+	}
+	return "", fmt.Errorf("unknown operation %q", args.Operation)
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	switch strings.ToLower(name) {
+	case "", "local":
+		return time.Local, nil
+	case "utc", "z", "gmt":
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q, use an IANA name like America/New_York", name)
+	}
+	return loc, nil
+}
+
+// parseTime parses s in loc, empty means now. times without a date are today
+func parseTime(s string, loc *time.Location, now time.Time) (time.Time, error) {
+	now = now.In(loc)
+	if s == "" || strings.EqualFold(s, "now") {
+		return now, nil
+	}
+	for _, layout := range timeLayouts {
+		t, err := time.ParseInLocation(layout, s, loc)
+		if err != nil {
+			continue
+		}
+		if !strings.Contains(layout, "2006") {
+			t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("can't parse time %q, use RFC 3339 or YYYY-MM-DD HH:MM", s)
+}
+
+// parseLongDuration is time.ParseDuration with days and weeks
+func parseLongDuration(s string) (time.Duration, error) {
+	s = strings.ReplaceAll(s, " ", "")
+	if s == "" {
+		return 0, fmt.Errorf("duration is required")
+	}
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+
+	var total time.Duration
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}} {
+		if i := strings.Index(s, unit.suffix); i >= 0 {
+			n, err := strconv.ParseFloat(s[:i], 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			total += time.Duration(n * float64(unit.size))
+			s = s[i+1:]
+		}
+	}
+	if s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		total += d
+	}
+	if neg {
+		total = -total
+	}
+	return total, nil
+}
+
+func formatLongDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	days := d / (24 * time.Hour)
+	rest := d % (24 * time.Hour)
+	if days == 0 {
+		return sign + rest.String()
+	}
+	return fmt.Sprintf("%s%dd%s", sign, days, rest)
+}
@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDateTime(t *testing.T) {
+	tool := dateTime{now: func() time.Time { return time.Date(2025, 3, 30, 0, 30, 0, 0, time.UTC) }}
+	tests := []struct {
+		args dateTimeArgs
+		want string
+	}{
+		{dateTimeArgs{Operation: "now", Timezone: "UTC"}, "Sunday 2025-03-30 00:30:00 UTC (+00:00)"},
+		{dateTimeArgs{Operation: "now", Timezone: "Asia/Tokyo"}, "Sunday 2025-03-30 09:30:00 JST (+09:00)"},
+		// times without a date are today in the timezone
+		{dateTimeArgs{Operation: "now", Time: "14:15", Timezone: "utc"}, "Sunday 2025-03-30 14:15:00 UTC (+00:00)"},
+		{
+			dateTimeArgs{Operation: "convert", Time: "2025-03-30 12:00", Timezone: "UTC", To: "America/New_York"},
+			"Sunday 2025-03-30 12:00:00 UTC (+00:00) = Sunday 2025-03-30 08:00:00 EDT (-04:00)",
+		},
+		{dateTimeArgs{Operation: "add", Time: "2025-01-31", Timezone: "UTC", Duration: "1w2d"}, "Sunday 2025-02-09 00:00:00 UTC (+00:00)"},
+		{dateTimeArgs{Operation: "add", Time: "2025-01-31T10:00:00Z", Timezone: "UTC", Duration: "-1d 1h30m"}, "Thursday 2025-01-30 08:30:00 UTC (+00:00)"},
+		{dateTimeArgs{Operation: "diff", Time: "2025-01-01", Other: "2025-01-02 12:00", Timezone: "UTC"}, "1d12h0m0s (1.5 days, 36 hours)"},
+		{dateTimeArgs{Operation: "diff", Time: "2025-01-02", Other: "2025-01-01", Timezone: "UTC"}, "-1d0s (-1 days, -24 hours)"},
+	}
+	for _, tt := range tests {
+		raw, _ := json.Marshal(tt.args)
+		got, err := tool.Run(context.Background(), raw)
+		if err != nil || got != tt.want {
+			t.Errorf("%+v: got %q, %v, want %q", tt.args, got, err, tt.want)
+		}
+	}
+}
+
+func TestDateTimeErrors(t *testing.T) {
+	tool := dateTime{now: time.Now}
+	tests := []struct {
+		args dateTimeArgs
+		want string
+	}{
+		{dateTimeArgs{Operation: "now", Timezone: "Mars/Olympus"}, "unknown timezone"},
+		{dateTimeArgs{Operation: "now", Time: "yesterday"}, "can't parse time"},
+		{dateTimeArgs{Operation: "add"}, "duration is required"},
+		{dateTimeArgs{Operation: "add", Duration: "3 fortnights"}, "invalid duration"},
+		{dateTimeArgs{Operation: "diff"}, "diff needs other"},
+		{dateTimeArgs{Operation: "sleep"}, "unknown operation"},
+	}
+	for _, tt := range tests {
+		raw, _ := json.Marshal(tt.args)
+		if _, err := tool.Run(context.Background(), raw); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: got %v, want an error containing %q", tt.args, err, tt.want)
+		}
+	}
+}
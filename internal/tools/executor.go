@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/scbenet/ask/internal/config"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// Approver is asked whether a call that falls outside its tool's policy may
+// run anyway. it blocks until the user answers
+type Approver func(call llm.ToolCall, reason string) bool
+
+// Executor runs tool calls, enforcing each tool's policy
+type Executor struct {
+	registry *Registry
+	config   config.ToolsConfig
+}
+
+func NewExecutor(registry *Registry, cfg config.ToolsConfig) *Executor {
+	return &Executor{registry: registry, config: cfg}
+}
+
+// Definitions returns the definitions of every tool not disabled by policy
+func (e *Executor) Definitions() []llm.ToolDefinition {
+	var defs []llm.ToolDefinition
+	for _, def := range e.registry.Definitions() {
+		if !e.config.ToolPolicy(def.Function.Name).Disabled {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+// Execute runs a single call and returns the tool message answering it.
+// failures are reported to the model as the tool's output rather than
+// aborting the conversation. approve may be nil, in which case anything
+// outside policy is refused
+func (e *Executor) Execute(ctx context.Context, call llm.ToolCall, approve Approver) llm.Message {
+	result := llm.Message{Role: "tool", ToolCallID: call.ID}
+
+	output, err := e.run(ctx, call, approve)
+	if err != nil {
+		logging.Errorf("tool %s failed: %v", call.Function.Name, err)
+		result.Content = "error: " + err.Error()
+		return result
+	}
+	result.Content = output
+	return result
+}
+
+func (e *Executor) run(ctx context.Context, call llm.ToolCall, approve Approver) (string, error) {
+	tool, ok := e.registry.Get(call.Function.Name)
+	policy := e.config.ToolPolicy(call.Function.Name)
+	if !ok || policy.Disabled {
+		return "", fmt.Errorf("unknown tool %q", call.Function.Name)
+	}
+
+	args := json.RawMessage(call.Function.Arguments)
+	access, err := tool.Access(args)
+	if err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	if reason := denied(policy, access); reason != "" {
+		return "", fmt.Errorf("refused by policy: %s", reason)
+	}
+	if reason := outsidePolicy(policy, access); reason != "" {
+		if approve == nil || !approve(call, reason) {
+			return "", fmt.Errorf("the user did not allow this call (%s)", reason)
+		}
+	}
+
+	if timeout := policy.Timeout.Std(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return tool.Run(ctx, args)
+}
+
+// denied returns why a call is refused outright, or "" if it isn't
+func denied(policy config.ToolPolicy, access Access) string {
+	if access.Command != "" && slices.Contains(policy.Deny, filepath.Base(access.Command)) {
+		return fmt.Sprintf("%s is on the deny list", access.Command)
+	}
+	for _, host := range access.Hosts {
+		if !HostAllowed(host, policy.Domains) {
+			return fmt.Sprintf("%s is not one of the allowed domains", host)
+		}
+	}
+	return ""
+}
+
+// HostAllowed reports whether host matches one of domains, where
+// "*.example.com" matches any subdomain of example.com
+func HostAllowed(host string, domains []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, d := range domains {
+		d = strings.ToLower(d)
+		if suffix, ok := strings.CutPrefix(d, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == d {
+			return true
+		}
+	}
+	return false
+}
+
+// outsidePolicy returns why a call needs approval, or "" if the policy
+// already allows everything it does
+func outsidePolicy(policy config.ToolPolicy, access Access) string {
+	var reasons []string
+
+	if access.Unchecked && !commandAllowed(policy, access.Command) {
+		reasons = append(reasons, fmt.Sprintf("runs %s, which can read, write and connect to anything you can", access.Command))
+	}
+	if access.Remembers != "" {
+		reasons = append(reasons, fmt.Sprintf("saves %q to memory for future conversations", access.Remembers))
+	}
+	for _, path := range access.Reads {
+		if !insideAny(path, policy.ReadablePaths) {
+			reasons = append(reasons, fmt.Sprintf("reads %s, outside the readable paths", path))
+		}
+	}
+	for _, path := range access.Writes {
+		if !insideAny(path, policy.WritablePaths) {
+			reasons = append(reasons, fmt.Sprintf("writes %s, outside the writable paths", path))
+		}
+	}
+	if access.Network && !policy.Network {
+		reasons = append(reasons, "needs network access")
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// commandAllowed reports whether the policy lets command run without asking
+func commandAllowed(policy config.ToolPolicy, command string) bool {
+	return slices.Contains(policy.Allow, "*") || slices.Contains(policy.Allow, filepath.Base(command))
+}
+
+func insideAny(path string, dirs []string) bool {
+	path = resolveLinks(path)
+	for _, dir := range dirs {
+		abs, err := filepath.Abs(expandHome(dir))
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(resolveLinks(abs), path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveLinks follows the symlinks in path as far as it exists, so a link
+// inside an allowed directory can't lead out of it
+func resolveLinks(path string) string {
+	var rest []string
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		if filepath.Dir(dir) == dir {
+			return path
+		}
+		rest = append([]string{filepath.Base(dir)}, rest...)
+	}
+}
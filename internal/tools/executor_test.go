@@ -0,0 +1,153 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scbenet/ask/internal/config"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/memory"
+)
+
+func newTestExecutor(policies map[string]config.ToolPolicy) *Executor {
+	r := NewRegistry()
+	RegisterDefaults(r, config.ToolsConfig{})
+	return NewExecutor(r, config.ToolsConfig{Policies: policies})
+}
+
+func toolCall(name string, args any) llm.ToolCall {
+	raw, _ := json.Marshal(args)
+	call := llm.ToolCall{ID: "call_1", Type: "function"}
+	call.Function.Name = name
+	call.Function.Arguments = string(raw)
+	return call
+}
+
+// approver records the reasons it was asked with and answers allow
+func approver(allow bool, reasons *[]string) Approver {
+	return func(_ llm.ToolCall, reason string) bool {
+		*reasons = append(*reasons, reason)
+		return allow
+	}
+}
+
+func TestExecuteReadPolicy(t *testing.T) {
+	dir, outside := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("inside"), 0o644)
+	os.WriteFile(filepath.Join(outside, "secret"), []byte("outside"), 0o644)
+	if err := os.Symlink(filepath.Join(outside, "secret"), filepath.Join(dir, "link")); err != nil {
+		t.Skip("no symlinks:", err)
+	}
+	e := newTestExecutor(map[string]config.ToolPolicy{"read_file": {ReadablePaths: []string{dir}}})
+
+	got := e.Execute(context.Background(), toolCall("read_file", pathArgs{Path: filepath.Join(dir, "notes.txt")}), nil)
+	if got.Content != "inside" {
+		t.Errorf("reading inside the readable paths = %q", got.Content)
+	}
+	for _, path := range []string{filepath.Join(outside, "secret"), filepath.Join(dir, "link")} {
+		var reasons []string
+		got := e.Execute(context.Background(), toolCall("read_file", pathArgs{Path: path}), approver(false, &reasons))
+		if strings.Contains(got.Content, "outside") && !strings.Contains(got.Content, "did not allow") {
+			t.Errorf("read %s without approval: %q", path, got.Content)
+		}
+		if len(reasons) != 1 || !strings.Contains(reasons[0], "outside the readable paths") {
+			t.Errorf("reading %s asked with %q", path, reasons)
+		}
+	}
+}
+
+func TestExecuteWritePolicy(t *testing.T) {
+	dir, outside := t.TempDir(), t.TempDir()
+	e := newTestExecutor(map[string]config.ToolPolicy{"write_file": {WritablePaths: []string{dir}}})
+
+	e.Execute(context.Background(), toolCall("write_file", pathArgs{Path: filepath.Join(dir, "a.txt"), Content: "a"}), nil)
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "a" {
+		t.Errorf("writing inside the writable paths left %q", data)
+	}
+	var reasons []string
+	e.Execute(context.Background(), toolCall("write_file", pathArgs{Path: filepath.Join(outside, "b.txt"), Content: "b"}), approver(false, &reasons))
+	if _, err := os.Stat(filepath.Join(outside, "b.txt")); err == nil {
+		t.Error("wrote outside the writable paths without approval")
+	}
+	if len(reasons) != 1 || !strings.Contains(reasons[0], "outside the writable paths") {
+		t.Errorf("writing outside asked with %q", reasons)
+	}
+}
+
+// commands not on the allow list ask, what they touch can't be checked,
+// and denied ones are refused even when "*" allows everything
+func TestExecuteCommandPolicy(t *testing.T) {
+	e := newTestExecutor(map[string]config.ToolPolicy{"run_command": {Allow: []string{"echo"}, Deny: []string{"rm"}}})
+
+	var reasons []string
+	got := e.Execute(context.Background(), toolCall("run_command", runCommandArgs{Command: "echo hi"}), approver(false, &reasons))
+	if strings.TrimSpace(got.Content) != "hi" || len(reasons) > 0 {
+		t.Errorf("allowed command = %q, asked %q", got.Content, reasons)
+	}
+
+	got = e.Execute(context.Background(), toolCall("run_command", runCommandArgs{Command: "printf hi"}), approver(true, &reasons))
+	if got.Content != "hi" {
+		t.Errorf("approved command returned %q", got.Content)
+	}
+	if len(reasons) != 1 || !strings.Contains(reasons[0], "runs printf") {
+		t.Errorf("the command asked with %q", reasons)
+	}
+	if got := e.Execute(context.Background(), toolCall("run_command", runCommandArgs{Command: "printf hi"}), nil); !strings.Contains(got.Content, "did not allow") {
+		t.Errorf("command ran without an approver: %q", got.Content)
+	}
+
+	e = newTestExecutor(map[string]config.ToolPolicy{"run_command": {Allow: []string{"*"}, Deny: []string{"rm"}}})
+	reasons = nil
+	if got := e.Execute(context.Background(), toolCall("run_command", runCommandArgs{Command: "printf hi"}), approver(false, &reasons)); got.Content != "hi" || len(reasons) > 0 {
+		t.Errorf(`command allowed by "*" = %q, asked %q`, got.Content, reasons)
+	}
+	got = e.Execute(context.Background(), toolCall("run_command", runCommandArgs{Command: "rm -rf /nonexistent"}), approver(true, &reasons))
+	if !strings.Contains(got.Content, "refused by policy") || len(reasons) > 0 {
+		t.Errorf("denied command = %q, asked %q", got.Content, reasons)
+	}
+}
+
+func TestExecuteRememberAsks(t *testing.T) {
+	store, err := memory.Open(filepath.Join(t.TempDir(), "memories.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRegistry()
+	RegisterMemory(r, store)
+	e := NewExecutor(r, config.ToolsConfig{})
+
+	var reasons []string
+	got := e.Execute(context.Background(), toolCall("remember", rememberArgs{Fact: "Uses Go"}), approver(false, &reasons))
+	if !strings.Contains(got.Content, "did not allow") || len(store.List()) != 0 {
+		t.Fatalf("refused fact was saved: %q", got.Content)
+	}
+	if len(reasons) != 1 || !strings.Contains(reasons[0], `saves "Uses Go" to memory`) {
+		t.Errorf("asked with %q", reasons)
+	}
+	e.Execute(context.Background(), toolCall("remember", rememberArgs{Fact: "Uses Go"}), approver(true, &reasons))
+	if got := store.List(); len(got) != 1 || got[0].Source != "model" {
+		t.Errorf("allowed fact saved as %+v", got)
+	}
+}
+
+func TestHostAllowed(t *testing.T) {
+	domains := []string{"example.com", "*.github.com"}
+	tests := map[string]bool{
+		"example.com":         true,
+		"EXAMPLE.com.":        true,
+		"www.example.com":     false,
+		"api.github.com":      true,
+		"github.com":          false,
+		"evilgithub.com":      false,
+		"github.com.evil.net": false,
+	}
+	for host, want := range tests {
+		if got := HostAllowed(host, domains); got != want {
+			t.Errorf("HostAllowed(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
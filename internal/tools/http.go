@@ -0,0 +1,151 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// httpTimeout bounds a request even when the policy sets no timeout
+const httpTimeout = 2 * time.Minute
+
+// httpRequest lets the model call APIs and read docs on the domains the
+// user has allowed in the tool's policy. it is registered twice, as
+// http_get and http_post
+type httpRequest struct {
+	method  string
+	domains []string // from the policy, also enforced on redirects
+}
+
+type httpArgs struct {
+	URL         string            `json:"url"`
+	Headers     map[string]string `json:"headers"`
+	Body        string            `json:"body"`
+	ContentType string            `json:"content_type"`
+}
+
+func (t httpRequest) Name() string { return "http_" + strings.ToLower(t.method) }
+func (t httpRequest) Description() string {
+	return fmt.Sprintf("Send an HTTP %s request and return the status, content type and response body. "+
+		"Only these domains may be reached: %s.", t.method, strings.Join(t.domains, ", "))
+}
+func (t httpRequest) Parameters() map[string]any {
+	props := map[string]any{
+		"url": stringProp("absolute http or https URL"),
+		"headers": map[string]any{
+			"type":                 "object",
+			"description":          "extra request headers",
+			"additionalProperties": map[string]any{"type": "string"},
+		},
+	}
+	if t.method == http.MethodPost {
+		props["body"] = stringProp("request body")
+		props["content_type"] = stringProp("content type of body, defaults to application/json")
+	}
+	return schema([]string{"url"}, props)
+}
+
+func (t httpRequest) parse(raw json.RawMessage) (httpArgs, *url.URL, error) {
+	var args httpArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return args, nil, err
+	}
+	u, err := url.Parse(args.URL)
+	if err != nil {
+		return args, nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return args, nil, errors.New("only http and https URLs are supported")
+	}
+	if u.Hostname() == "" {
+		return args, nil, errors.New("url has no host")
+	}
+	return args, u, nil
+}
+
+func (t httpRequest) Access(raw json.RawMessage) (Access, error) {
+	_, u, err := t.parse(raw)
+	if err != nil {
+		return Access{}, err
+	}
+	return Access{Network: true, Hosts: []string{u.Hostname()}}, nil
+}
+
+func (t httpRequest) Run(ctx context.Context, raw json.RawMessage) (string, error) {
+	args, u, err := t.parse(raw)
+	if err != nil {
+		return "", err
+	}
+
+	var body io.Reader
+	if t.method == http.MethodPost {
+		body = strings.NewReader(args.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, t.method, u.String(), body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "ask-cli")
+	if t.method == http.MethodPost {
+		contentType := args.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range args.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := &http.Client{
+		Timeout: httpTimeout,
+		// a redirect must not leave the allowed domains
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("too many redirects")
+			}
+			if !HostAllowed(req.URL.Hostname(), t.domains) {
+				return fmt.Errorf("redirected to %s, which is not an allowed domain", req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxOutputBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n", resp.Proto, resp.Status)
+	for _, k := range interestingHeaders(resp.Header) {
+		fmt.Fprintf(&b, "%s: %s\n", k, resp.Header.Get(k))
+	}
+	b.WriteString("\n")
+	b.WriteString(truncate(string(data)))
+	return b.String(), nil
+}
+
+// interestingHeaders picks the response headers worth showing the model
+func interestingHeaders(h http.Header) []string {
+	var keys []string
+	for _, k := range []string{"Content-Type", "Location", "Link", "Retry-After"} {
+		if h.Get(k) != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func httpRaw(args httpArgs) json.RawMessage {
+	raw, _ := json.Marshal(args)
+	return raw
+}
+
+// TestHTTPRedirect follows redirects within the allowed domains only
+func TestHTTPRedirect(t *testing.T) {
+	// reached as localhost, which isn't allowed
+	elsewhere := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "secret")
+	}))
+	defer elsewhere.Close()
+	elsewhereURL := strings.Replace(elsewhere.URL, "127.0.0.1", "localhost", 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/away":
+			http.Redirect(w, r, elsewhereURL, http.StatusFound)
+		case "/here":
+			http.Redirect(w, r, "/docs", http.StatusFound)
+		default:
+			io.WriteString(w, "docs")
+		}
+	}))
+	defer srv.Close()
+	get := httpRequest{method: http.MethodGet, domains: []string{"127.0.0.1"}}
+
+	out, err := get.Run(context.Background(), httpRaw(httpArgs{URL: srv.URL + "/here"}))
+	if err != nil || !strings.HasSuffix(out, "\n\ndocs") {
+		t.Fatalf("redirect within the domain returned %q (%v)", out, err)
+	}
+	out, err = get.Run(context.Background(), httpRaw(httpArgs{URL: srv.URL + "/away"}))
+	if err == nil || !strings.Contains(err.Error(), "redirected to localhost, which is not an allowed domain") || strings.Contains(out, "secret") {
+		t.Fatalf("redirect to another domain returned %q (%v)", out, err)
+	}
+}
+
+func TestHTTPSchemes(t *testing.T) {
+	get := httpRequest{method: http.MethodGet, domains: []string{"*"}}
+	for _, url := range []string{"file:///etc/passwd", "ftp://example.com/x", "gopher://example.com", "example.com/path"} {
+		if _, err := get.Access(httpRaw(httpArgs{URL: url})); err == nil {
+			t.Errorf("%s was accepted", url)
+		}
+		if out, err := get.Run(context.Background(), httpRaw(httpArgs{URL: url})); err == nil {
+			t.Errorf("%s was fetched: %q", url, out)
+		}
+	}
+}
+
+// TestHTTPPostContentType sends JSON unless told otherwise
+func TestHTTPPostContentType(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		io.WriteString(w, r.Method+" "+r.Header.Get("Content-Type")+" "+string(body))
+	}))
+	defer srv.Close()
+	post := httpRequest{method: http.MethodPost, domains: []string{"127.0.0.1"}}
+
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"", `POST application/json {"q":1}`},
+		{"text/plain", `POST text/plain {"q":1}`},
+	}
+	for _, tt := range tests {
+		out, err := post.Run(context.Background(), httpRaw(httpArgs{URL: srv.URL, Body: `{"q":1}`, ContentType: tt.contentType}))
+		if err != nil || !strings.HasSuffix(out, "\n\n"+tt.want) {
+			t.Errorf("content type %q sent %q (%v), want %q", tt.contentType, out, err, tt.want)
+		}
+	}
+}
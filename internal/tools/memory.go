@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/scbenet/ask/internal/memory"
+)
+
+// remember lets the model save facts about the user for future sessions,
+// each one after the user allows it. it is only registered when memory is
+// enabled
+type remember struct {
+	store *memory.Store
+}
+
+// RegisterMemory offers the remember tool, saving to store
+func RegisterMemory(r *Registry, store *memory.Store) {
+	r.Register(remember{store: store})
+}
+
+type rememberArgs struct {
+	Fact string `json:"fact"`
+}
+
+func (remember) Name() string { return "remember" }
+func (remember) Description() string {
+	return "Save a short fact about the user (a preference, their setup, an ongoing project) so it is known in future conversations. " +
+		"Only use it for lasting facts the user would want remembered, not for details of the current task."
+}
+func (remember) Parameters() map[string]any {
+	return schema([]string{"fact"}, map[string]any{
+		"fact": stringProp("the fact as one short sentence, e.g. `Prefers Go over Python for scripts`"),
+	})
+}
+func (remember) Access(raw json.RawMessage) (Access, error) {
+	var args rememberArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return Access{}, err
+	}
+	return Access{Remembers: args.Fact}, nil
+}
+func (t remember) Run(ctx context.Context, raw json.RawMessage) (string, error) {
+	var args rememberArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	m, err := t.store.Add(args.Fact, "model")
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("remembered: %s", m.Text), nil
+}
@@ -0,0 +1,182 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// most matching lines returned from a single search
+	maxSearchMatches = 200
+	// long lines (minified files) are cut to this many bytes by ripgrep
+	maxSearchColumns = 300
+)
+
+// searchCode greps the project with ripgrep, so models can find where
+// something is defined without the whole repo being attached
+type searchCode struct{}
+
+type searchArgs struct {
+	Pattern    string `json:"pattern"`
+	Path       string `json:"path"`
+	Glob       string `json:"glob"`
+	Literal    bool   `json:"literal"`
+	IgnoreCase bool   `json:"ignore_case"`
+}
+
+func (searchCode) Name() string { return "search_code" }
+func (searchCode) Description() string {
+	return fmt.Sprintf("Search the files of the current project with ripgrep and return matching lines as path:line:text. "+
+		"Respects .gitignore. Returns at most %d matches, narrow the search with path or glob if there are more.", maxSearchMatches)
+}
+func (searchCode) Parameters() map[string]any {
+	return schema([]string{"pattern"}, map[string]any{
+		"pattern":     stringProp("regular expression (Rust regex syntax) to search for, e.g. `func\\s+NewStore`"),
+		"path":        stringProp("file or directory to search, relative to the project root, defaults to all of it"),
+		"glob":        stringProp("only search files matching this glob, e.g. `*.go` or `!*_test.go`"),
+		"literal":     map[string]any{"type": "boolean", "description": "treat pattern as a plain string instead of a regex"},
+		"ignore_case": map[string]any{"type": "boolean"},
+	})
+}
+
+func (t searchCode) Access(raw json.RawMessage) (Access, error) {
+	_, target, err := t.parse(raw)
+	if err != nil {
+		return Access{}, err
+	}
+	return Access{Reads: []string{target}}, nil
+}
+
+// parse validates the arguments and resolves the directory to search in
+func (searchCode) parse(raw json.RawMessage) (searchArgs, string, error) {
+	var args searchArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return args, "", err
+	}
+	if args.Pattern == "" {
+		return args, "", errors.New("pattern is required")
+	}
+	if strings.ContainsAny(args.Pattern, "\x00\n") || strings.Contains(args.Glob, "\x00") {
+		return args, "", errors.New("pattern and glob must be a single line")
+	}
+
+	root, err := ProjectRoot()
+	if err != nil {
+		return args, "", err
+	}
+	target := root
+	if args.Path != "" {
+		target = filepath.Join(root, args.Path)
+		if filepath.IsAbs(args.Path) {
+			target = filepath.Clean(args.Path)
+		}
+		if !insideAny(target, []string{root}) {
+			return args, "", fmt.Errorf("%s is outside the project root %s", args.Path, root)
+		}
+	}
+	return args, target, nil
+}
+
+func (t searchCode) Run(ctx context.Context, raw json.RawMessage) (string, error) {
+	args, target, err := t.parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if _, err := exec.LookPath("rg"); err != nil {
+		return "", errors.New("ripgrep (rg) is not installed")
+	}
+	root, _ := ProjectRoot()
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		rel = target
+	}
+
+	cmd := exec.CommandContext(ctx, "rg", rgArgs(args, rel)...)
+	cmd.Dir = root
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	matches := 0
+	truncated := false
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if matches == maxSearchMatches {
+			truncated = true
+			// no need to let rg finish
+			cmd.Process.Kill()
+			break
+		}
+		out.WriteString(scanner.Text())
+		out.WriteByte('\n')
+		matches++
+	}
+	err = cmd.Wait()
+
+	switch {
+	case ctx.Err() != nil:
+		return out.String(), fmt.Errorf("search timed out: %w", ctx.Err())
+	case truncated:
+		fmt.Fprintf(&out, "… stopped after %d matches, narrow the search to see more\n", maxSearchMatches)
+	case err != nil:
+		// rg exits 1 when nothing matched, 2 on errors
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "no matches", nil
+		}
+		return "", fmt.Errorf("rg failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	return out.String(), nil
+}
+
+// rgArgs are the arguments ripgrep is run with to search path. the pattern
+// is passed with -e and the path after --, so neither can be mistaken for a
+// flag
+func rgArgs(args searchArgs, path string) []string {
+	rg := []string{"--line-number", "--no-heading", "--color=never", "--with-filename",
+		fmt.Sprintf("--max-columns=%d", maxSearchColumns), "--max-columns-preview"}
+	if args.Literal {
+		rg = append(rg, "--fixed-strings")
+	}
+	if args.IgnoreCase {
+		rg = append(rg, "--ignore-case")
+	}
+	if args.Glob != "" {
+		rg = append(rg, "--glob", args.Glob)
+	}
+	return append(rg, "-e", args.Pattern, "--", path)
+}
+
+// ProjectRoot returns the root of the git repository containing the current
+// directory, or the current directory if it isn't in one
+func ProjectRoot() (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	for dir := wd; ; {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return wd, nil
+		}
+		dir = parent
+	}
+}
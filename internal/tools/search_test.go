@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newProject makes a git repository holding files and changes into it
+func newProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(root)
+	return root
+}
+
+func searchRaw(args searchArgs) json.RawMessage {
+	raw, _ := json.Marshal(args)
+	return raw
+}
+
+func TestSearchParse(t *testing.T) {
+	root := newProject(t, map[string]string{"sub/a.go": "package sub\n"})
+	t.Chdir(filepath.Join(root, "sub"))
+
+	tests := []struct {
+		path string
+		want string // "" if refused
+	}{
+		{path: "", want: root},
+		{path: "sub", want: filepath.Join(root, "sub")},
+		{path: filepath.Join(root, "sub", "a.go"), want: filepath.Join(root, "sub", "a.go")},
+		{path: ".."},
+		{path: "../outside"},
+		{path: "sub/../../outside"},
+		{path: "/etc"},
+		{path: filepath.Dir(root)},
+	}
+	for _, tt := range tests {
+		_, target, err := searchCode{}.parse(searchRaw(searchArgs{Pattern: "x", Path: tt.path}))
+		switch {
+		case tt.want == "" && (err == nil || !strings.Contains(err.Error(), "outside the project root")):
+			t.Errorf("path %q was searched in %s (%v)", tt.path, target, err)
+		case tt.want != "" && (err != nil || target != tt.want):
+			t.Errorf("path %q searches %s (%v), want %s", tt.path, target, err, tt.want)
+		}
+	}
+
+	if _, _, err := (searchCode{}).parse(searchRaw(searchArgs{Pattern: "a\nb"})); err == nil {
+		t.Error("a pattern over several lines was accepted")
+	}
+}
+
+func TestRgArgs(t *testing.T) {
+	got := rgArgs(searchArgs{Pattern: "--files", Glob: "*.go", Literal: true, IgnoreCase: true}, "-dir")
+	if n := len(got); n < 4 || !slices.Equal(got[n-4:], []string{"-e", "--files", "--", "-dir"}) {
+		t.Fatalf("pattern and path aren't passed after -e and --: %q", got)
+	}
+	for _, flag := range []string{"--fixed-strings", "--ignore-case", "--glob"} {
+		if i := slices.Index(got, flag); i < 0 || i > len(got)-4 {
+			t.Errorf("%s is missing or after the pattern: %q", flag, got)
+		}
+	}
+	if got := rgArgs(searchArgs{Pattern: "x"}, "."); slices.Contains(got, "--fixed-strings") || slices.Contains(got, "--glob") {
+		t.Errorf("options that weren't asked for: %q", got)
+	}
+}
+
+func TestSearchRun(t *testing.T) {
+	if _, err := exec.LookPath("rg"); err != nil {
+		t.Skip("rg is not installed")
+	}
+	var many strings.Builder
+	for i := range maxSearchMatches + 50 {
+		fmt.Fprintf(&many, "needle %d\n", i)
+	}
+	newProject(t, map[string]string{
+		"a.go":     "package a\n\nfunc NewStore() {}\n",
+		"many.txt": many.String(),
+	})
+	search := func(args searchArgs) string {
+		t.Helper()
+		out, err := searchCode{}.Run(context.Background(), searchRaw(args))
+		if err != nil {
+			t.Fatalf("searching for %q: %v", args.Pattern, err)
+		}
+		return out
+	}
+
+	if got := search(searchArgs{Pattern: `func\s+NewStore`}); got != "a.go:3:func NewStore() {}\n" {
+		t.Errorf("search found %q", got)
+	}
+	if got := search(searchArgs{Pattern: "nothing like this"}); got != "no matches" {
+		t.Errorf("search without matches = %q", got)
+	}
+	got := search(searchArgs{Pattern: "needle", Path: "many.txt"})
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != maxSearchMatches+1 || !strings.Contains(lines[maxSearchMatches], "stopped after") {
+		t.Errorf("search with too many matches returned %d lines ending in %q", len(lines), lines[len(lines)-1])
+	}
+}
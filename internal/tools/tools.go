@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/scbenet/ask/internal/llm"
+)
+
+// Tool is a function the model can call
+type Tool interface {
+	Name() string
+	Description() string
+	// Parameters is the JSON schema of the arguments object
+	Parameters() map[string]any
+	// Access describes what a call with args would touch, so the executor
+	// can check it against the tool's policy before running anything
+	Access(args json.RawMessage) (Access, error)
+	Run(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+// Access is what a single tool invocation needs to do its job
+type Access struct {
+	Command string   // program that will be executed, if any
+	Reads   []string // absolute paths that will be read
+	Writes  []string // absolute paths that will be written
+	Network bool     // whether the call makes network requests
+	Hosts   []string // hosts the call will connect to
+	// Unchecked is set when what the call touches can't be known before it
+	// runs, e.g. a program. such calls always ask
+	Unchecked bool
+	// Remembers is a fact the call saves for future conversations, it
+	// always asks
+	Remembers string
+}
+
+// Registry holds the tools offered to the model
+type Registry struct {
+	tools map[string]Tool
+	order []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{tools: map[string]Tool{}}
+}
+
+// Register adds a tool, replacing any existing tool with the same name
+func (r *Registry) Register(t Tool) {
+	if _, exists := r.tools[t.Name()]; !exists {
+		r.order = append(r.order, t.Name())
+	}
+	r.tools[t.Name()] = t
+}
+
+// Get looks up a tool by name
+func (r *Registry) Get(name string) (Tool, bool) {
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// Definitions returns the tool definitions sent with each request
+func (r *Registry) Definitions() []llm.ToolDefinition {
+	defs := make([]llm.ToolDefinition, 0, len(r.order))
+	for _, name := range r.order {
+		t := r.tools[name]
+		defs = append(defs, llm.ToolDefinition{
+			Type: "function",
+			Function: llm.FunctionDefinition{
+				Name:        t.Name(),
+				Description: t.Description(),
+				Parameters:  t.Parameters(),
+			},
+		})
+	}
+	return defs
+}
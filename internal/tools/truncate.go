@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// truncation strategies for results longer than the configured limit
+const (
+	TruncateHeadTail  = "headtail"
+	TruncateSummarize = "summarize"
+)
+
+// HeadTail shortens s to about max bytes by keeping its beginning and end,
+// which is where commands print what they're doing and how it ended, with a
+// marker noting how much was cut from the middle
+func HeadTail(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+
+	head := cutAtLine(s[:max/2], true)
+	tail := cutAtLine(s[len(s)-max/2:], false)
+	omitted := len(s) - len(head) - len(tail)
+	return fmt.Sprintf("%s\n… [%d bytes truncated] …\n%s", head, omitted, tail)
+}
+
+// cutAtLine trims a partial line off the end of a head (or the start of a
+// tail) so the kept parts don't start or stop mid line. if that would throw
+// most of it away, it only makes sure not to split a utf-8 sequence
+func cutAtLine(s string, head bool) string {
+	if head {
+		if i := strings.LastIndexByte(s, '\n'); i > len(s)/2 {
+			return s[:i]
+		}
+		for i := 0; i < utf8.UTFMax-1 && len(s) > 0; i++ {
+			if r, size := utf8.DecodeLastRuneInString(s); r != utf8.RuneError || size > 1 {
+				break
+			}
+			s = s[:len(s)-1]
+		}
+		return s
+	}
+
+	if i := strings.IndexByte(s, '\n'); i >= 0 && i < len(s)/2 {
+		return s[i+1:]
+	}
+	for i := 0; i < utf8.UTFMax-1 && len(s) > 0 && !utf8.RuneStart(s[0]); i++ {
+		s = s[1:]
+	}
+	return s
+}
@@ -0,0 +1,220 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// unitConverter converts between units of the same dimension
+type unitConverter struct{}
+
+type unitArgs struct {
+	Value float64 `json:"value"`
+	From  string  `json:"from"`
+	To    string  `json:"to"`
+}
+
+type unit struct {
+	dimension string
+	factor    float64 // size in the dimension's base unit
+}
+
+// units maps every accepted spelling, lowercased, to its size in the base
+// unit of its dimension: metres, kilograms, litres, seconds, bytes, metres
+// per second, square metres and joules. temperatures aren't linear and are
+// handled apart
+var units = map[string]unit{}
+
+// dataSymbols are the data size symbols whose case is their meaning, MB is a
+// megabyte and Mb a megabit. they only match as written, in bytes
+var dataSymbols = map[string]float64{
+	"B": 1, "b": 0.125,
+	"kB": 1e3, "KB": 1e3, "kb": 125, "Kb": 125,
+	"MB": 1e6, "Mb": 1.25e5,
+	"GB": 1e9, "Gb": 1.25e8,
+	"TB": 1e12, "Tb": 1.25e11,
+	"KiB": 1 << 10, "MiB": 1 << 20, "GiB": 1 << 30, "TiB": 1 << 40,
+	"Kib": 1 << 7, "Mib": 1 << 17, "Gib": 1 << 27, "Tib": 1 << 37,
+}
+
+func init() {
+	add := func(dimension string, factor float64, names ...string) {
+		for _, n := range names {
+			units[n] = unit{dimension, factor}
+		}
+	}
+
+	add("length", 1, "m", "meter", "meters", "metre", "metres")
+	add("length", 1e-3, "mm", "millimeter", "millimeters", "millimetre", "millimetres")
+	add("length", 1e-2, "cm", "centimeter", "centimeters", "centimetre", "centimetres")
+	add("length", 1e3, "km", "kilometer", "kilometers", "kilometre", "kilometres")
+	add("length", 0.0254, "in", "inch", "inches")
+	add("length", 0.3048, "ft", "foot", "feet")
+	add("length", 0.9144, "yd", "yard", "yards")
+	add("length", 1609.344, "mi", "mile", "miles")
+	add("length", 1852, "nmi", "nautical mile", "nautical miles")
+
+	add("mass", 1, "kg", "kilogram", "kilograms")
+	add("mass", 1e-3, "g", "gram", "grams")
+	add("mass", 1e-6, "mg", "milligram", "milligrams")
+	add("mass", 1e3, "t", "tonne", "tonnes", "metric ton")
+	add("mass", 0.45359237, "lb", "lbs", "pound", "pounds")
+	add("mass", 0.028349523125, "oz", "ounce", "ounces")
+	add("mass", 6.35029318, "st", "stone", "stones")
+
+	add("volume", 1, "l", "liter", "liters", "litre", "litres")
+	add("volume", 1e-3, "ml", "milliliter", "milliliters", "millilitre", "millilitres")
+	add("volume", 1e3, "m3", "cubic meter", "cubic meters")
+	add("volume", 3.785411784, "gal", "gallon", "gallons", "us gallon")
+	add("volume", 4.54609, "imperial gallon", "imperial gallons")
+	add("volume", 0.946352946, "qt", "quart", "quarts")
+	add("volume", 0.473176473, "pt", "pint", "pints")
+	add("volume", 0.2365882365, "cup", "cups")
+	add("volume", 0.0295735295625, "fl oz", "fluid ounce", "fluid ounces")
+	add("volume", 0.01478676478125, "tbsp", "tablespoon", "tablespoons")
+	add("volume", 0.00492892159375, "tsp", "teaspoon", "teaspoons")
+
+	add("time", 1, "s", "sec", "second", "seconds")
+	add("time", 1e-3, "ms", "millisecond", "milliseconds")
+	add("time", 60, "min", "minute", "minutes")
+	add("time", 3600, "h", "hr", "hour", "hours")
+	add("time", 86400, "d", "day", "days")
+	add("time", 604800, "wk", "week", "weeks")
+	add("time", 31557600, "yr", "year", "years") // julian year
+
+	add("data", 1, "byte", "bytes")
+	add("data", 0.125, "bit", "bits")
+	add("data", 1e3, "kilobyte", "kilobytes")
+	add("data", 125, "kbit", "kilobit", "kilobits")
+	add("data", 1e6, "megabyte", "megabytes")
+	add("data", 1.25e5, "mbit", "megabit", "megabits")
+	add("data", 1e9, "gigabyte", "gigabytes")
+	add("data", 1.25e8, "gbit", "gigabit", "gigabits")
+	add("data", 1e12, "terabyte", "terabytes")
+	add("data", 1<<10, "kibibyte", "kibibytes")
+	add("data", 1<<20, "mebibyte", "mebibytes")
+	add("data", 1<<30, "gibibyte", "gibibytes")
+	add("data", 1<<40, "tebibyte", "tebibytes")
+
+	add("speed", 1, "m/s", "meters per second")
+	add("speed", 1/3.6, "km/h", "kph", "kmh", "kilometers per hour")
+	add("speed", 0.44704, "mph", "miles per hour")
+	add("speed", 1852.0/3600, "kn", "knot", "knots")
+
+	add("area", 1, "m2", "square meter", "square meters")
+	add("area", 1e6, "km2", "square kilometer", "square kilometers")
+	add("area", 0.09290304, "ft2", "sq ft", "square foot", "square feet")
+	add("area", 4046.8564224, "acre", "acres")
+	add("area", 1e4, "ha", "hectare", "hectares")
+
+	add("energy", 1, "j", "joule", "joules")
+	add("energy", 1e3, "kj", "kilojoule", "kilojoules")
+	add("energy", 4.184, "cal", "calorie", "calories")
+	add("energy", 4184, "kcal", "kilocalorie", "kilocalories")
+	add("energy", 3.6e6, "kwh", "kilowatt hour", "kilowatt hours")
+}
+
+var temperatures = map[string]string{
+	"c": "c", "°c": "c", "celsius": "c",
+	"f": "f", "°f": "f", "fahrenheit": "f",
+	"k": "k", "kelvin": "k",
+}
+
+func (unitConverter) Name() string { return "convert_units" }
+func (unitConverter) Description() string {
+	return "Convert a value between units of length, mass, volume, time, data size, speed, area, energy or temperature, e.g. 5 mi to km, 350 F to C, 2 GiB to MB."
+}
+func (unitConverter) Parameters() map[string]any {
+	return schema([]string{"value", "from", "to"}, map[string]any{
+		"value": map[string]any{"type": "number"},
+		"from":  stringProp("unit of value, e.g. `mi`, `lb`, `°F`, `GiB`. data sizes are case sensitive, `MB` is megabytes and `Mb` megabits"),
+		"to":    stringProp("unit to convert to"),
+	})
+}
+func (unitConverter) Access(raw json.RawMessage) (Access, error) {
+	var args unitArgs
+	return Access{}, json.Unmarshal(raw, &args)
+}
+func (unitConverter) Run(ctx context.Context, raw json.RawMessage) (string, error) {
+	var args unitArgs
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return "", err
+	}
+	v, err := ConvertUnits(args.Value, args.From, args.To)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s = %s %s", formatNumber(args.Value), args.From, formatNumber(v), args.To), nil
+}
+
+// ConvertUnits converts value from one unit to another
+func ConvertUnits(value float64, from, to string) (float64, error) {
+	from, to = normalizeUnit(from), normalizeUnit(to)
+
+	if tf, ok := temperatures[strings.ToLower(from)]; ok {
+		tt, ok := temperatures[strings.ToLower(to)]
+		if !ok {
+			return 0, fmt.Errorf("can't convert temperature to %q", to)
+		}
+		return fromKelvin(toKelvin(value, tf), tt), nil
+	}
+
+	uf, err := lookupUnit(from)
+	if err != nil {
+		return 0, err
+	}
+	ut, err := lookupUnit(to)
+	if err != nil {
+		return 0, err
+	}
+	if uf.dimension != ut.dimension {
+		return 0, fmt.Errorf("can't convert %s (%s) to %s (%s)", from, uf.dimension, to, ut.dimension)
+	}
+	return value * uf.factor / ut.factor, nil
+}
+
+// lookupUnit finds u, data size symbols as written and everything else in
+// any case
+func lookupUnit(u string) (unit, error) {
+	if factor, ok := dataSymbols[u]; ok {
+		return unit{"data", factor}, nil
+	}
+	if un, ok := units[strings.ToLower(u)]; ok {
+		return un, nil
+	}
+	for symbol := range dataSymbols {
+		if strings.EqualFold(symbol, u) {
+			return unit{}, fmt.Errorf("unknown unit %q, data sizes are case sensitive: MB is megabytes, Mb megabits", u)
+		}
+	}
+	return unit{}, fmt.Errorf("unknown unit %q", u)
+}
+
+// normalizeUnit tidies the spacing and spelling of u, keeping its case
+func normalizeUnit(u string) string {
+	u = strings.Join(strings.Fields(u), " ")
+	u = strings.NewReplacer("²", "2", "³", "3", "^2", "2", "^3", "3", "degrees ", "", "degree ", "", "Degrees ", "", "Degree ", "").Replace(u)
+	return u
+}
+
+func toKelvin(v float64, scale string) float64 {
+	switch scale {
+	case "c":
+		return v + 273.15
+	case "f":
+		return (v-32)*5/9 + 273.15
+	}
+	return v
+}
+
+func fromKelvin(v float64, scale string) float64 {
+	switch scale {
+	case "c":
+		return v - 273.15
+	case "f":
+		return (v-273.15)*9/5 + 32
+	}
+	return v
+}
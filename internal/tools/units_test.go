@@ -0,0 +1,56 @@
+package tools
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestConvertUnits(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{5, "mi", "km", 8.04672},
+		{1, "Foot", "inches", 12},
+		{2, "lb", "kg", 0.90718474},
+		{1, "gal", "l", 3.785411784},
+		{90, "min", "h", 1.5},
+		{100, "km/h", "m/s", 27.7777777778},
+		{1, "km²", "ha", 100},
+		{1, "kcal", "kJ", 4.184},
+		{350, "°F", "C", 176.6666666667},
+		{0, "degrees Celsius", "kelvin", 273.15},
+		// the case of data size symbols is their meaning
+		{2, "GiB", "MB", 2147.483648},
+		{1, "MB", "Mb", 8},
+		{100, "Mb", "MB", 12.5},
+		{1, "kB", "b", 8000},
+		{1, "B", "bits", 8},
+		{1, "megabyte", "kilobytes", 1000},
+	}
+	for _, tt := range tests {
+		got, err := ConvertUnits(tt.value, tt.from, tt.to)
+		if err != nil || math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("ConvertUnits(%v, %q, %q) = %v, %v, want %v", tt.value, tt.from, tt.to, got, err, tt.want)
+		}
+	}
+}
+
+func TestConvertUnitsErrors(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     string
+	}{
+		{"km", "kg", "can't convert km (length) to kg (mass)"},
+		{"parsec", "km", `unknown unit "parsec"`},
+		{"C", "km", "can't convert temperature"},
+		{"mb", "kB", "data sizes are case sensitive"},
+	}
+	for _, tt := range tests {
+		if _, err := ConvertUnits(1, tt.from, tt.to); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ConvertUnits(1, %q, %q) = %v, want an error containing %q", tt.from, tt.to, err, tt.want)
+		}
+	}
+}
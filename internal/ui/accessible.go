@@ -0,0 +1,65 @@
+package ui
+
+import (
+	"github.com/charmbracelet/lipgloss"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// WithAccessible makes the chat usable with a screen reader. it is drawn
+// inline (see WithInline) in plain text without colors or borders, every
+// message starts with its role, a response is printed once it is complete
+// instead of being redrawn as it streams, nothing animates and changes of
+// state (waiting, stalled, offline) are announced as lines of their own
+func WithAccessible() Option {
+	return func(c *Chat) {
+		c.accessible = true
+		c.inline = true
+	}
+}
+
+// makeAccessible drops what a screen reader can't convey or would read out
+// over and over, after the other options so it wins over them
+func (c *Chat) makeAccessible() {
+	plain := lipgloss.NewStyle()
+	c.userStyle, c.assistantStyle, c.labelStyle = plain, plain, plain
+	c.noticeStyle, c.errorStyle, c.warningStyle = plain, plain, plain
+	c.borderStyle = plain
+	c.statusStyle = plain.Padding(0, 1)
+	// the input's border would be read as a character on every line
+	c.input.Prompt = ""
+	c.markdownStyle = "notty"
+	c.hyperlinks = false
+	if c.userMessages.Label == "" {
+		c.userMessages.Label = i18n.T("You:")
+	}
+	if c.userMessages.Prefix == "> " {
+		c.userMessages.Prefix = ""
+	}
+	if c.assistantMessages.Label == "" {
+		c.assistantMessages.Label = i18n.T("Assistant:")
+	}
+}
+
+// Accessible reports whether the chat is in accessible mode
+func (c *Chat) Accessible() bool {
+	return c.accessible
+}
+
+// spoken puts what kind of line text is in front of it in accessible mode,
+// where color doesn't tell errors from notices. kind is e.g. "error"
+func (c *Chat) spoken(kind, text string) string {
+	if !c.accessible {
+		return text
+	}
+	return i18n.T(kind) + ": " + text
+}
+
+// announce adds a line saying what changed, only in accessible mode. the
+// other modes show it in the status bar or with the spinner
+func (c *Chat) announce(text string) {
+	if !c.accessible {
+		return
+	}
+	c.appendStyled(c.noticeStyle, c.spoken("status", text))
+	c.refreshHistory()
+}
@@ -0,0 +1,124 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/llm"
+)
+
+// benchResponse is a typical markdown answer: prose, a list, code and a table
+var benchResponse = strings.Repeat(`Here's how the pieces fit together. The store keeps one file per session
+and the index is rebuilt on startup, so a crash never leaves it out of date.
+
+- **sessions** are written to a temp file and renamed into place
+- attachments live in a content addressed blob store
+- the trash keeps deleted messages around for `+"`trashRetention`"+`
+
+`+"```go"+`
+func (s *Store) Save(sess *Session) error {
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+	return os.WriteFile(s.path(sess.ID), data, 0o600)
+}
+`+"```"+`
+
+| setting | default | notes |
+|---------|---------|-------|
+| requestTimeout | 10m | the whole request |
+| stallTimeout | 60s | between chunks |
+
+`, 3)
+
+// quietLog keeps the chat's debug logging out of the benchmark output
+func quietLog(b *testing.B) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(out) })
+}
+
+// benchChat returns a sized chat holding a conversation of n exchanges
+func benchChat(b *testing.B, n int) *Chat {
+	b.Helper()
+	c := New(120, 40)
+	c.Update(tea.WindowSizeMsg{Width: 120, Height: 40})
+	var messages []conversation.Message
+	for i := 0; i < n; i++ {
+		messages = append(messages,
+			conversation.Message{Role: "user", Content: fmt.Sprintf("question %d: how does the session store stay consistent?", i)},
+			conversation.Message{Role: "assistant", Content: benchResponse},
+		)
+	}
+	c.LoadHistory(messages)
+	return c
+}
+
+// BenchmarkResize re-renders a long transcript for a new width each time,
+// nothing is cached for it
+func BenchmarkResize(b *testing.B) {
+	quietLog(b)
+	c := benchChat(b, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Update(tea.WindowSizeMsg{Width: 100 + i%50, Height: 40})
+	}
+}
+
+// BenchmarkView renders a frame of a long transcript, which happens after
+// every message
+func BenchmarkView(b *testing.B) {
+	quietLog(b)
+	c := benchChat(b, 100)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.View()
+	}
+}
+
+// BenchmarkStreamChunks feeds a response in the small chunks fast models
+// send, with a frame rendered after each like bubble tea does
+func BenchmarkStreamChunks(b *testing.B) {
+	quietLog(b)
+	c := benchChat(b, 20)
+	chunks := strings.SplitAfter(benchResponse, " ")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.SetSending(true)
+		for _, chunk := range chunks {
+			c.Update(llm.StreamChunkMsg{Content: chunk})
+			c.View()
+		}
+		c.Update(renderLiveMsg{})
+		c.SetSending(false)
+		c.assistantResponse.Reset()
+		c.history.SetLive("")
+	}
+}
+
+// BenchmarkRenderMarkdown renders and wraps a response without the cache
+func BenchmarkRenderMarkdown(b *testing.B) {
+	quietLog(b)
+	c := New(120, 40)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.renderMarkdown(benchResponse, 100)
+		clear(c.markdownCache)
+	}
+}
+
+// BenchmarkWrapStyled wraps a long prompt, as done for every user message
+// and the response being streamed
+func BenchmarkWrapStyled(b *testing.B) {
+	c := New(120, 40)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.assistantStyle.Width(100).Render(benchResponse)
+	}
+}
@@ -3,38 +3,66 @@ package ui
 import (
 	"fmt"
 	"log"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
-	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/links"
 	"github.com/scbenet/ask/internal/llm"
 )
 
 // LLMReplyMsg is emitted when a response arrives from the LLM.
 type LLMReplyMsg struct{ Content string }
 
-type StreamEndMsg struct{ FullResponse string }
+type StreamEndMsg struct {
+	FullResponse string
+	Sources      []llm.Source
+	Model        string // that wrote the response
+	Effort       string // reasoning effort it was raised to, "" for the configured one
+	Usage        *llm.Usage
+}
 
-type StreamErrorMsg struct{ Err string }
+// StreamErrorMsg shows a failed request in the error panel
+type StreamErrorMsg struct{ Report ErrorReport }
 
 // Message to send to API
 type SendPromptMsg struct{ Prompt string }
 
+// CommandMsg is emitted instead of SendPromptMsg when the input starts with a
+// slash, e.g. "/mermaid 2" becomes {Name: "mermaid", Args: ["2"]}
+type CommandMsg struct {
+	Name string
+	Args []string
+}
+
 type keyMap struct {
 	SendPrompt   key.Binding
 	NewLine      key.Binding
 	ModelPicker  key.Binding
+	Persona      key.Binding
+	Clear        key.Binding
+	Editor       key.Binding
+	ThinkHarder  key.Binding // handled by the app
+	Continue     key.Binding // handled by the app
 	PageDown     key.Binding
 	PageUp       key.Binding
 	HalfPageUp   key.Binding
 	HalfPageDown key.Binding
 	Up           key.Binding
 	Down         key.Binding
+	CopyMode     key.Binding
+	Cancel       key.Binding // the response or a held prompt, handled by the app
+	Follow       key.Binding
 	Help         key.Binding
 	Quit         key.Binding
 }
@@ -50,119 +78,261 @@ func (k keyMap) ShortHelp() []key.Binding {
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.PageUp, k.PageDown, k.HalfPageUp, k.HalfPageDown}, // first column
-		{k.Up, k.Down, k.SendPrompt, k.NewLine},              // second column
-		{k.ModelPicker, k.Help, k.Quit},
-	}
-}
-
-var keys = keyMap{
-	PageDown: key.NewBinding(
-		key.WithKeys("pgdown", "ctrl+f"),
-		key.WithHelp("ctrl+f/pgdn", "page down"),
-	),
-	PageUp: key.NewBinding(
-		key.WithKeys("pgup", "ctrl+b"),
-		key.WithHelp("ctrl+b/pgup", "page up"),
-	),
-	HalfPageUp: key.NewBinding(
-		key.WithKeys("ctrl+u"),
-		key.WithHelp("ctrl+u", "½ page up"),
-	),
-	HalfPageDown: key.NewBinding(
-		key.WithKeys("ctrl+d"),
-		key.WithHelp("ctrl+d", "½ page down"),
-	),
-	Up: key.NewBinding(
-		key.WithKeys("up", "ctrl+o"),
-		key.WithHelp("↑/ctrl+o", "up"),
-	),
-	Down: key.NewBinding(
-		key.WithKeys("down", "ctrl+p"),
-		key.WithHelp("↓/ctrl+p", "down"),
-	),
-	SendPrompt: key.NewBinding(
-		key.WithKeys("enter"),
-		key.WithHelp("enter", "send message"),
-	),
-	NewLine: key.NewBinding(
-		key.WithKeys("shift+enter", "ctrl+j"),
-		key.WithHelp("⇧enter/ctrl-j", "new line"),
-	),
-	ModelPicker: key.NewBinding(
-		key.WithKeys("ctrl-k"),
-		key.WithHelp("ctrl-k", "model picker"),
-	),
-	Help: key.NewBinding(
-		key.WithKeys("ctrl-q"),
-		key.WithHelp("ctrl-q", "more help"),
-	),
-	Quit: key.NewBinding(
-		key.WithKeys("ctrl+c"),
-		key.WithHelp("ctrl-c", "clear input/quit"),
-	),
+		{k.Up, k.Down, k.CopyMode, k.SendPrompt, k.NewLine},  // second column
+		{k.ModelPicker, k.Persona, k.Clear, k.Editor, k.ThinkHarder, k.Continue, k.Help, k.Quit},
+	}
+}
+
+// newLineKeyHelp names the keys inserting a new line. windows consoles
+// send the same enter with or without shift, but ctrl+enter arrives as
+// ctrl+j in Windows Terminal and conhost
+func newLineKeyHelp() string {
+	if runtime.GOOS == "windows" {
+		return "ctrl+enter/ctrl-j"
+	}
+	return "⇧enter/ctrl-j"
+}
+
+// defaultKeyMap returns the chat key bindings, every chat gets its own
+// since their help text changes with the state
+func defaultKeyMap() keyMap {
+	return keyMap{
+		PageDown: key.NewBinding(
+			key.WithKeys("pgdown", "ctrl+f"),
+			key.WithHelp("ctrl+f/pgdn", i18n.T("page down")),
+		),
+		PageUp: key.NewBinding(
+			key.WithKeys("pgup", "ctrl+b"),
+			key.WithHelp("ctrl+b/pgup", i18n.T("page up")),
+		),
+		HalfPageUp: key.NewBinding(
+			key.WithKeys("ctrl+u"),
+			key.WithHelp("ctrl+u", i18n.T("½ page up")),
+		),
+		HalfPageDown: key.NewBinding(
+			key.WithKeys("ctrl+d"),
+			key.WithHelp("ctrl+d", i18n.T("½ page down")),
+		),
+		Up: key.NewBinding(
+			key.WithKeys("up", "ctrl+o"),
+			key.WithHelp("↑/ctrl+o", i18n.T("up")),
+		),
+		Down: key.NewBinding(
+			key.WithKeys("down", "ctrl+p"),
+			key.WithHelp("↓/ctrl+p", i18n.T("down")),
+		),
+		SendPrompt: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", i18n.T("send message")),
+		),
+		NewLine: key.NewBinding(
+			key.WithKeys("shift+enter", "ctrl+j"),
+			key.WithHelp(newLineKeyHelp(), i18n.T("new line")),
+		),
+		ModelPicker: key.NewBinding(
+			key.WithKeys("ctrl-k"),
+			key.WithHelp("ctrl-k", i18n.T("model picker")),
+		),
+		Persona: key.NewBinding(
+			key.WithKeys("ctrl+g"),
+			key.WithHelp("ctrl+g", i18n.T("persona")),
+		),
+		Clear: key.NewBinding(
+			key.WithKeys("ctrl+l"),
+			key.WithHelp("ctrl+l", i18n.T("new conversation")),
+		),
+		Editor: key.NewBinding(
+			key.WithKeys("ctrl+e"),
+			key.WithHelp("ctrl+e", i18n.T("open in $EDITOR")),
+		),
+		ThinkHarder: key.NewBinding(
+			key.WithKeys("alt+t"),
+			key.WithHelp("alt+t", i18n.T("think harder")),
+		),
+		Continue: key.NewBinding(
+			key.WithKeys("alt+m"),
+			key.WithHelp("alt+m", i18n.T("continue a cut off response")),
+		),
+		CopyMode: key.NewBinding(
+			key.WithKeys("ctrl+y"),
+			key.WithHelp("ctrl+y", i18n.T("copy mode")),
+		),
+		Cancel: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", i18n.T("cancel")),
+		),
+		Follow: key.NewBinding(
+			key.WithKeys("ctrl+s"),
+			key.WithHelp("ctrl+s", i18n.T("stop following")),
+		),
+		Help: key.NewBinding(
+			key.WithKeys("ctrl-q"),
+			key.WithHelp("ctrl-q", i18n.T("more help")),
+		),
+		Quit: key.NewBinding(
+			key.WithKeys("ctrl+c"),
+			key.WithHelp("ctrl-c", i18n.T("clear input/quit")),
+		),
+	}
 }
 
 // Chat is the main chat view (history + input field).
 type Chat struct {
-	history viewport.Model
+	history historyView
 	input   textarea.Model
 	keys    keyMap
 	help    help.Model
 
-	sending           bool // true while waiting for the model response to finish
-	historyBuf        strings.Builder
+	sending           bool          // true while waiting for the model response to finish
+	persona           string        // active persona, shown in the status bar
+	incognito         bool          // shown in the status bar so it's never forgotten
+	warning           string        // e.g. a low balance, shown in the status bar
+	locked            bool          // the conversation is read-only
+	patchMode         bool          // responses are expected to be diffs, see SetPatchMode
+	jsonMode          bool          // responses are expected to be JSON, see SetJSONMode
+	offline           bool          // the provider can't be reached, see SetOffline
+	queued            int           // prompts waiting for the current response to finish
+	unrenderedChunks  int           // stream chunks received since the live response was last rendered
+	renderScheduled   bool          // a renderLiveMsg is on its way
+	pendingSend       time.Duration // grace period of a prompt not sent yet, see SetPendingSend
+	stalled           time.Duration // how long the response has been quiet, see SetStalled
+	following         bool          // the history scrolls along with the response
+	choices           keyList       // answers to a question, shown in the help instead, see SetChoices
+	errorPanel        *errorPanel   // the last failed request, see ShowError
+	errorKeys         errorKeyMap
+	lastPromptBlock   int // history block of the last prompt, see RetractPrompt
+	lastResponseBlock int // history block of the last response, -1 if none, see ReopenResponse
+	status            streamStatus
 	assistantResponse strings.Builder // builds current assistant message during streaming
 
-	sendKey key.Binding
+	sendKey     key.Binding
+	sendKeyName string // one of the SendKey constants
+	mention     mentionCompleter
+	replay      *replay // nil unless a conversation is replayed, see StartReplay
+	replayKeys  replayKeyMap
 
 	// style handles
 	userStyle        lipgloss.Style
 	assistantStyle   lipgloss.Style
+	labelStyle       lipgloss.Style
+	noticeStyle      lipgloss.Style
 	errorStyle       lipgloss.Style
+	warningStyle     lipgloss.Style
+	statusStyle      lipgloss.Style
 	borderStyle      lipgloss.Style
 	historyViewStyle lipgloss.Style
 
-	glamourRenderer      *glamour.TermRenderer
-	lastGlamourWrapWidth int
+	// glamour renderers by style and word wrap width, creating one is expensive
+	renderers map[rendererKey]*glamour.TermRenderer
+	// rendered responses by content and width, so re-wrapping the history
+	// after a resize only renders what changed
+	markdownCache map[markdownKey]string
+
+	hyperlinks    bool   // wrap URLs in OSC 8 escapes
+	markdownStyle string // glamour's, see WithMarkdownStyle
+	math          string // how LaTeX is shown, see WithMath
+	inline        bool   // the history goes to the scrollback, see WithInline
+	accessible    bool   // plain text for screen readers, see WithAccessible
+	sized         bool   // a WindowSizeMsg arrived
+	timestamps    bool   // messages show when they were sent, see WithTimestamps
+
+	// prefixes, labels and the user's markdown style, see WithMessageStyles
+	userMessages      MessageStyle
+	assistantMessages MessageStyle
+}
+
+// Option configures a Chat
+type Option func(*Chat)
+
+// WithClock times the responses with c instead of the system clock
+func WithClock(c clock.Clock) Option {
+	return func(chat *Chat) { chat.status.clock = c }
+}
+
+// WithMarkdownStyle renders responses in one of glamour's standard styles,
+// "dark" by default. "notty" renders plain text, which is easier to test
+func WithMarkdownStyle(style string) Option {
+	return func(c *Chat) { c.markdownStyle = style }
+}
+
+// WithMath sets how LaTeX math in responses is shown: MathUnicode
+// approximates it with unicode characters, MathLaTeX keeps the source but
+// sets it apart as code and MathOff leaves it alone
+func WithMath(mode string) Option {
+	return func(c *Chat) { c.math = mode }
+}
+
+// MessageStyle is how the messages of a role are shown
+type MessageStyle struct {
+	// Markdown is a glamour style name or JSON style file. user messages are
+	// plain text without one, responses use the WithMarkdownStyle style
+	Markdown string
+	// Prefix starts every user message, responses don't have one
+	Prefix string
+	// Label is a line above every message, e.g. "You:"
+	Label string
+}
+
+// WithMessageStyles sets how the user's messages and the responses are shown
+func WithMessageStyles(user, assistant MessageStyle) Option {
+	return func(c *Chat) {
+		c.userMessages, c.assistantMessages = user, assistant
+		if assistant.Markdown != "" {
+			c.markdownStyle = assistant.Markdown
+		}
+	}
+}
+
+// WithHyperlinks turns the OSC 8 links on or off instead of asking the
+// terminal whether it supports them
+func WithHyperlinks(hyperlinks bool) Option {
+	return func(c *Chat) { c.hyperlinks = hyperlinks }
 }
 
 func (c *Chat) GetInputValue() string {
 	return c.input.Value()
 }
 
-func (c *Chat) SetSending(sending bool) {
+func (c *Chat) SetInputValue(value string) {
+	c.input.SetValue(value)
+}
+
+// SetSending toggles the waiting-for-response state, the returned command
+// drives the progress spinner
+func (c *Chat) SetSending(sending bool) tea.Cmd {
 	c.sending = sending
+	c.stalled = 0
+	c.following = true
+	var cmd tea.Cmd
 	if sending {
-		c.input.Placeholder = "Assistant is thinking..."
+		c.errorPanel = nil
 		c.assistantResponse.Reset() // ensure the buffer for the current response is clean
-	} else {
-		c.input.Placeholder = "Write a message…"
+		c.unrenderedChunks, c.renderScheduled = 0, false
+		cmd = c.status.start()
+		if c.accessible {
+			// a spinner would be read out on every frame
+			cmd = nil
+			c.announce(i18n.T("waiting for the response"))
+		}
 	}
+	c.input.Placeholder = i18n.T("Write a message…")
 
-	c.history.SetContent(c.historyBuf.String())
+	c.history.SetLive("")
 	c.history.GotoBottom()
+	return cmd
 }
 
 // returns an initialized Chat with sane defaults.
-func New(width, height int) *Chat {
+func New(width, height int, opts ...Option) *Chat {
 	// textarea (user input)
 	ti := textarea.New()
-	ti.Placeholder = "Write a message…"
+	ti.Placeholder = i18n.T("Write a message…")
 	ti.Focus()
 	ti.CharLimit = 0
 	ti.ShowLineNumbers = false
 
-	// remap keys: shift+Enter (and Ctrl+J as a fallback) inserts newline
-	// TODO shift+enter doesn't work yet, need to update to new bubbletea version to get kitty protocol support
-	ti.KeyMap.InsertNewline = key.NewBinding(
-		key.WithKeys("shift+enter", "ctrl+j"),
-		key.WithHelp("⇧enter/ctrl-j", "new line"),
-	)
-
-	// viewport (scrollable chat history)
-	vp := viewport.New(width, 0)
-	vp.KeyMap = CustomKeyMap()
-	vp.SetContent("")
+	// scrollable chat history
+	vp := newHistoryView(width, 0)
 
 	helpModel := help.New()
 
@@ -172,29 +342,41 @@ func New(width, height int) *Chat {
 	hPadding := chatHistoryViewStyle.GetPaddingLeft() + chatHistoryViewStyle.GetPaddingRight()
 	initialContentWidth := max(width-hPadding, 80)
 
-	renderer, err := glamour.NewTermRenderer(
-		glamour.WithStandardStyle("dark"),
-		glamour.WithWordWrap(initialContentWidth),
-	)
-
-	if err != nil {
-		log.Printf("error initializing glamour renderer: %v. markdown rendering will fallback to plain text", err)
-		renderer = nil
-	}
-
 	c := &Chat{
-		history:              vp,
-		input:                ti,
-		keys:                 keys,
-		help:                 helpModel,
-		sendKey:              key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", "send")),
-		userStyle:            lipgloss.NewStyle().Italic(true).Foreground(lipgloss.Color("#707070")),
-		assistantStyle:       lipgloss.NewStyle(),
-		errorStyle:           lipgloss.NewStyle().Foreground(lipgloss.Color("9")), // red for errors
-		borderStyle:          lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("#777")),
-		historyViewStyle:     lipgloss.NewStyle().Padding(0, 1),
-		glamourRenderer:      renderer,
-		lastGlamourWrapWidth: initialContentWidth,
+		history:          vp,
+		input:            ti,
+		keys:             defaultKeyMap(),
+		help:             helpModel,
+		errorKeys:        defaultErrorKeyMap(),
+		replayKeys:       defaultReplayKeyMap(),
+		userStyle:        lipgloss.NewStyle().Italic(true).Foreground(lipgloss.Color("#707070")),
+		assistantStyle:   lipgloss.NewStyle(),
+		labelStyle:       lipgloss.NewStyle().Bold(true),
+		userMessages:     MessageStyle{Prefix: "> "},
+		noticeStyle:      lipgloss.NewStyle().Faint(true).Italic(true),
+		errorStyle:       lipgloss.NewStyle().Foreground(lipgloss.Color("9")), // red for errors
+		warningStyle:     lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("11")),
+		statusStyle:      lipgloss.NewStyle().Faint(true).Padding(0, 1),
+		status:           newStreamStatus(),
+		borderStyle:      lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("#777")),
+		historyViewStyle: lipgloss.NewStyle().Padding(0, 1),
+		renderers:        map[rendererKey]*glamour.TermRenderer{},
+		markdownCache:    map[markdownKey]string{},
+		hyperlinks:       links.TerminalSupportsHyperlinks(),
+		markdownStyle:    "dark",
+		math:             MathUnicode,
+	}
+	c.lastResponseBlock = -1
+	// the keys inserting new lines, WithSendKey changes them
+	c.setSendKey(SendKeyEnter)
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.accessible {
+		c.makeAccessible()
+	}
+	if c.inline {
+		c.keys.disableScrolling()
 	}
 	// set initial history width based on input width, will be refined by WindowSizeMsg
 	c.history.Width = initialContentWidth
@@ -212,12 +394,27 @@ func (c *Chat) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	var cmds []tea.Cmd
 
-	// ensure history width is positive for wrapping, default to a minimum if not.
-	lipglossWrapWidth := max(c.history.Width, 80)
-
 	switch m := msg.(type) {
 	case tea.KeyMsg:
+		if c.history.InCopyMode() {
+			return c, c.updateCopyMode(m)
+		}
+		if c.replay != nil {
+			return c, c.updateReplay(m)
+		}
+		if c.errorPanel != nil && !c.sending {
+			if cmd, ok := c.errorPanelKey(m); ok {
+				return c, cmd
+			}
+		}
+		if cmd, ok := c.mentionKey(m); ok {
+			return c, cmd
+		}
 		switch {
+		case key.Matches(m, c.keys.CopyMode):
+			c.history.EnterCopyMode()
+			return c, nil
+
 		case key.Matches(m, c.keys.Quit):
 			if c.input.Value() != "" {
 				log.Println("Chat.Update: ctrl-c matched, input not empty. clearing input")
@@ -226,7 +423,7 @@ func (c *Chat) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			log.Println("Chat.Update: ctrl-c matched, input empty, letting app handle quit")
 
-		case key.Matches(m, c.sendKey) && !c.sending: // send prompt
+		case key.Matches(m, c.sendKey) && c.pendingSend == 0: // send prompt
 			log.Println("Chat.Update: Send key matched")
 			prompt := strings.TrimSpace(c.input.Value())
 
@@ -235,151 +432,155 @@ func (c *Chat) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				break
 			}
 
-			// append user message to history
-			rawUserMessage := fmt.Sprintf("> %s", prompt)
-			styledAndWrappedUserMessage := c.userStyle.Width(lipglossWrapWidth).Render(rawUserMessage)
-			fmt.Fprintf(&c.historyBuf, "%s\n\n", styledAndWrappedUserMessage)
-
-			c.history.SetContent(c.historyBuf.String())
-			c.history.GotoBottom()
+			// slash commands are handled by the app and never reach the model,
+			// a leading double slash sends a literal slash
+			if strings.HasPrefix(prompt, "/") && !strings.HasPrefix(prompt, "//") {
+				if c.sending {
+					c.AddError(i18n.T("commands can't run while a response is streaming"))
+					break
+				}
+				fields := strings.Fields(strings.TrimPrefix(prompt, "/"))
+				c.input.Reset()
+				if len(fields) == 0 {
+					break
+				}
+				cmd = func() tea.Msg { return CommandMsg{Name: fields[0], Args: fields[1:]} }
+				cmds = append(cmds, cmd)
+				break
+			}
+			prompt = strings.TrimPrefix(prompt, "/")
 			c.input.Reset()
 
+			// prompts sent during a response are queued by the app and
+			// only shown once they're sent, see ShowPrompt
+			if !c.sending {
+				c.appendUserMessage(prompt, messageMeta{time: c.status.clock.Now()})
+				c.history.GotoBottom()
+			}
+
 			cmd = func() tea.Msg { return SendPromptMsg{Prompt: prompt} }
 			cmds = append(cmds, cmd)
 
+		case key.Matches(m, c.keys.Follow) && c.sending:
+			c.following = !c.following
+			if c.following {
+				c.history.GotoBottom()
+			}
+			return c, nil
+
 		case key.Matches(m, c.keys.Help):
 			log.Println("Chat.Update: help key triggered")
 			c.help.ShowAll = !c.help.ShowAll
 			return c, nil // No command, just state change
 
 		default:
+			// typing after clicking the history goes to the input again
+			if !c.input.Focused() && m.Type == tea.KeyRunes {
+				cmds = append(cmds, c.input.Focus())
+			}
 			// pass messages to nested models
 			var tiCmd, vpCmd, helpCmd tea.Cmd
 			c.input, tiCmd = c.input.Update(msg)
 			c.history, vpCmd = c.history.Update(msg)
 			c.help, helpCmd = c.help.Update(msg)
-			cmds = append(cmds, tiCmd, vpCmd, helpCmd)
+			cmds = append(cmds, tiCmd, vpCmd, helpCmd, c.updateMention())
+			if c.sending {
+				// scrolling up to read stops following the response, back
+				// down picks it up again
+				c.following = c.history.AtBottom()
+			}
+		}
+
+	case tea.MouseMsg:
+		cmds = append(cmds, c.handleMouse(m))
+
+	case spinner.TickMsg:
+		if c.sending {
+			c.status.spinner, cmd = c.status.spinner.Update(m)
+			cmds = append(cmds, cmd)
 		}
 
 	case llm.StreamChunkMsg:
 		log.Printf("Chat.Update: StreamChunkMsg received: '%s'", m.Content)
+		c.status.chunk(m.Content)
 		c.assistantResponse.WriteString(m.Content) // add to temporary buffer for current response
 
-		rawCurrentResponse := c.assistantResponse.String()
-		styledAndWrappedResponse := c.assistantStyle.Width(lipglossWrapWidth).Render(rawCurrentResponse)
+		// fast models send hundreds of tiny chunks a second, re-rendering
+		// for each one is wasted work. render at most every renderInterval,
+		// or right away once enough chunks piled up
+		c.unrenderedChunks++
+		if c.unrenderedChunks >= maxUnrenderedChunks {
+			c.renderLive()
+		} else if !c.renderScheduled {
+			c.renderScheduled = true
+			cmds = append(cmds, tea.Tick(renderInterval, func(time.Time) tea.Msg { return renderLiveMsg{} }))
+		}
 
-		// combine finalized history with currently streaming message
-		c.history.SetContent(c.historyBuf.String() + styledAndWrappedResponse)
-		c.history.GotoBottom()
+	case mentionFilesMsg:
+		c.setMentionFiles(m.files)
+
+	case renderLiveMsg:
+		c.renderScheduled = false
+		if c.unrenderedChunks > 0 && c.sending {
+			c.renderLive()
+		}
 
 	case StreamEndMsg:
 		log.Printf("Chat.Update: StreamEndMsg received. Full response was: %s", m.FullResponse)
-
-		var finalRendereredResponse string
-		if c.glamourRenderer != nil {
-			renderedMarkdown, err := c.glamourRenderer.Render(m.FullResponse)
-			if err != nil {
-				log.Printf("error rendering markdown with glamour: %v", err)
-				finalRendereredResponse = c.assistantStyle.Width(lipglossWrapWidth).Render(m.FullResponse)
-			} else {
-				finalRendereredResponse = strings.TrimSuffix(renderedMarkdown, "\n")
-			}
-		} else {
-			log.Println("glamour renderer not initalized, falling back to plain text")
-			finalRendereredResponse = c.assistantStyle.Width(lipglossWrapWidth).Render(m.FullResponse)
+		// responses that only call tools have no text to show
+		if m.FullResponse == "" && len(m.Sources) == 0 {
+			c.assistantResponse.Reset()
+			c.unrenderedChunks = 0
+			c.refreshHistory()
+			break
 		}
 
-		// append the final rendered and formatted response to historyBuf
-		fmt.Fprintf(&c.historyBuf, "%s\n\n", finalRendereredResponse)
+		// the final rendered and formatted response replaces the live one
+		c.appendMarkdown(withSources(m.FullResponse, m.Sources), messageMeta{time: c.status.clock.Now(), model: m.Model, effort: m.Effort, usage: m.Usage})
 
 		c.assistantResponse.Reset()
-		c.history.SetContent(c.historyBuf.String())
+		c.unrenderedChunks = 0
+		c.history.SetLive("")
 		c.history.GotoBottom()
 
 	case StreamErrorMsg:
-		log.Printf("Chat.Update: StreamErrorMsg received: %s", m.Err)
-		styledAndWrappedError := c.errorStyle.Width(lipglossWrapWidth).Render(m.Err)
-		fmt.Fprintf(&c.historyBuf, "%s\n\n", styledAndWrappedError)
-
+		log.Printf("Chat.Update: StreamErrorMsg received: %s", m.Report.Message)
 		c.assistantResponse.Reset() // Clear any partial streaming response
-		c.history.SetContent(c.historyBuf.String())
-		c.history.GotoBottom()
+		c.unrenderedChunks = 0
+		c.ShowError(m.Report)
 
 	// primarily for non-streaming or error messages
 	case LLMReplyMsg:
 		log.Printf("Chat.Update: LLMReplyMsg received: '%s'", m.Content)
-		var renderedResponse string
-		if c.glamourRenderer != nil {
-			renderedMarkdown, err := c.glamourRenderer.Render(m.Content)
-			if err != nil {
-				log.Printf("error rendering Markdown with glamour: %v.", err)
-				renderedResponse = c.assistantStyle.Width(lipglossWrapWidth).Render(m.Content)
-			} else {
-				renderedResponse = strings.TrimSuffix(renderedMarkdown, "\n")
-			}
-		} else {
-			log.Println("glamour renderer not initialized, falling back to plaintext")
-			renderedResponse = c.assistantStyle.Width(lipglossWrapWidth).Render(m.Content)
-		}
-
-		fmt.Fprintf(&c.historyBuf, "%s\n\n", renderedResponse)
-
-		c.history.SetContent(c.historyBuf.String())
+		c.appendMarkdown(m.Content, messageMeta{time: c.status.clock.Now()})
 		c.history.GotoBottom()
 		c.assistantResponse.Reset() // Good practice, though not strictly for streaming here
 		log.Println("Chat.Update: Appended LLMReplyMsg")
 
 	case tea.WindowSizeMsg:
+		c.sized = true
 		inputHeight := lipgloss.Height(c.borderStyle.Render(c.input.View()))
 		helpHeight := lipgloss.Height(c.help.View(c.keys))
+		statusHeight := lipgloss.Height(c.statusView())
 
 		// adjust history viewport size for padding
 		hPadding := c.historyViewStyle.GetPaddingLeft() + c.historyViewStyle.GetPaddingRight()
 		vPadding := c.historyViewStyle.GetPaddingTop() + c.historyViewStyle.GetPaddingBottom()
 
 		newContentWidth := max(m.Width-hPadding, 1)
-		c.history.Width = newContentWidth
-		c.history.Height = m.Height - inputHeight - vPadding - helpHeight
+		c.history.Height = m.Height - inputHeight - vPadding - helpHeight - statusHeight
 
 		c.input.SetWidth(m.Width - 2) // -2 for border
 		c.help.Width = m.Width - hPadding
 
-		// update glamour renderer if width changed
-		if newContentWidth != c.lastGlamourWrapWidth {
-			log.Printf("window resized, attempting to update glamour renderer with width %d", newContentWidth)
-			updatedRenderer, err := glamour.NewTermRenderer(
-				glamour.WithStandardStyle("dark"),
-				glamour.WithWordWrap(newContentWidth),
-			)
-			if err != nil {
-				log.Printf("error updating glamour renderer on resize: %v. old renderer (if any) will be kept", err)
-			} else {
-				c.glamourRenderer = updatedRenderer
-				c.lastGlamourWrapWidth = newContentWidth
-			}
-		} else if c.glamourRenderer == nil { // try to initalize renderer if it failed initially
-			log.Printf("attempting to initialize glamour renderer on resize with width: %d", newContentWidth)
-			renderer, err := glamour.NewTermRenderer(
-				glamour.WithStandardStyle("dark"),
-				glamour.WithWordWrap(newContentWidth),
-			)
-			if err != nil {
-				log.Printf("error initialized glamour renderer on resize: %v", err)
-			} else {
-				c.glamourRenderer = renderer
-				c.lastGlamourWrapWidth = newContentWidth
-			}
-		}
+		// markdown renders for other widths won't be needed again soon
+		c.pruneMarkdownCache(c.history.Width, newContentWidth)
 
-		// after a resize, re-set content to allow existing history to re-wrap if needed
-		// history contains pre-warpped strings, so old messages will not re-wrap, but
-		// new messages will be wrapped correctly
+		// the history is re-wrapped for the new width, reusing cached
+		// markdown renders where there are any
+		c.history.SetWidth(newContentWidth)
 		if c.sending && c.assistantResponse.Len() > 0 {
-			rawCurrentResponse := c.assistantResponse.String()
-			styledAndWrappedResponse := c.assistantStyle.Width(c.history.Width).Render(rawCurrentResponse)
-			c.history.SetContent(c.historyBuf.String() + styledAndWrappedResponse)
-		} else {
-			c.history.SetContent(c.historyBuf.String())
+			c.history.SetLive(c.liveResponse(c.history.Width))
 		}
 		// ensure view is scrolled properly after resize
 		c.history.GotoBottom()
@@ -388,16 +589,331 @@ func (c *Chat) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return c, tea.Batch(cmds...)
 }
 
+const (
+	// how often the streaming response is re-rendered
+	renderInterval = 50 * time.Millisecond
+	// render early once this many chunks are waiting
+	maxUnrenderedChunks = 64
+)
+
+// renderLiveMsg re-renders the streaming response, see StreamChunkMsg
+type renderLiveMsg struct{}
+
+// renderLive renders the response being streamed. only this message is
+// re-rendered, the history stays as is
+func (c *Chat) renderLive() {
+	c.unrenderedChunks = 0
+	c.history.SetLive(c.liveResponse(max(c.history.Width, 80)))
+	if c.following {
+		c.history.GotoBottom()
+	}
+}
+
+// liveResponse renders the response being streamed at width. in accessible
+// mode it isn't shown until it is complete, a screen reader would read the
+// whole of it again on every redraw
+func (c *Chat) liveResponse(width int) string {
+	if c.accessible {
+		return ""
+	}
+	return c.assistantStyle.Width(width).Render(c.assistantResponse.String())
+}
+
+// appendUserMessage adds a styled user prompt to the history
+func (c *Chat) appendUserMessage(prompt string, meta messageMeta) {
+	c.lastPromptBlock = c.history.Len()
+	text := c.userMessages.Prefix + prompt
+	c.history.Append(func(width int) string {
+		var rendered string
+		if style := c.userMessages.Markdown; style != "" {
+			rendered = c.renderMarkdownStyle(style, text, width)
+		} else {
+			rendered = c.userStyle.Width(max(width, 80)).Render(text)
+		}
+		return c.withHeader(c.userMessages.Label, meta, rendered)
+	})
+}
+
+// SetSendHint shows hint (e.g. a cost estimate) next to the send key in
+// the help, "" removes it
+func (c *Chat) SetSendHint(hint string) {
+	desc := i18n.T("send message")
+	if hint != "" {
+		desc += " (" + hint + ")"
+	}
+	c.keys.SendPrompt.SetHelp(c.sendKeyName, desc)
+}
+
+// SetQueued shows how many prompts are waiting to be sent
+func (c *Chat) SetQueued(n int) {
+	c.queued = n
+}
+
+// ShowPrompt adds a prompt that was queued to the history, now that it's sent
+func (c *Chat) ShowPrompt(prompt string) {
+	c.appendUserMessage(prompt, messageMeta{time: c.status.clock.Now()})
+	c.refreshHistory()
+}
+
+// SetPendingSend shows that the last prompt goes out after delay unless
+// cancelled, 0 clears it once it's sent
+func (c *Chat) SetPendingSend(delay time.Duration) {
+	c.pendingSend = delay
+}
+
+// RetractPrompt removes the last prompt from the history and puts it back
+// in the input, for when sending it was cancelled
+func (c *Chat) RetractPrompt(prompt string) {
+	c.pendingSend = 0
+	c.history.Truncate(c.lastPromptBlock)
+	c.refreshHistory()
+	c.input.SetValue(prompt)
+}
+
+// LoadHistory renders an existing conversation (e.g. a resumed session) into
+// the history view
+func (c *Chat) LoadHistory(messages []conversation.Message) {
+	// long sessions are only rendered as far as they're scrolled
+	c.history.BeginLoad()
+	defer c.history.EndLoad()
+	for _, m := range messages {
+		switch m.Role {
+		case "user":
+			for _, a := range m.Attachments {
+				c.appendStyled(c.userStyle, a.Summary())
+			}
+			c.appendUserMessage(m.Content, messageMeta{time: m.Timestamp})
+		case "assistant":
+			if m.Content != "" || len(m.Sources) > 0 {
+				c.appendMarkdown(withSources(m.Content, m.Sources), messageMeta{time: m.Timestamp, model: m.Model, effort: m.Effort, usage: m.Usage})
+			}
+			for _, call := range m.ToolCalls {
+				c.appendStyled(c.noticeStyle, DescribeToolCall(call))
+			}
+		case "tool":
+			c.appendStyled(c.noticeStyle, DescribeToolResult(m.Content))
+		}
+		if m.Failed() {
+			c.appendStyled(c.errorStyle, c.spoken("error", m.Error))
+		}
+	}
+}
+
+// withSources appends a numbered footnote list of cited sources to a
+// response, numbered like the [n] markers of search grounded models
+func withSources(content string, sources []llm.Source) string {
+	if len(sources) == 0 {
+		return content
+	}
+
+	var b strings.Builder
+	b.WriteString(content)
+	b.WriteString("\n\n---\n\n**Sources**\n\n")
+	for i, s := range sources {
+		// the URL is spelled out, /cite opens it
+		if s.Title == "" {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, s.URL)
+		} else {
+			fmt.Fprintf(&b, "%d. %s — %s\n", i+1, s.Title, s.URL)
+		}
+	}
+	return b.String()
+}
+
 // View implements tea.Model.
 func (c *Chat) View() string {
+	if c.inline {
+		return c.inlineView()
+	}
 	inputView := c.borderStyle.Render(c.input.View())
 	historyView := c.historyViewStyle.Render(c.history.View())
-	helpView := c.historyViewStyle.Render(c.help.View(c.keys))
-	return lipgloss.JoinVertical(lipgloss.Left, historyView, inputView, helpView)
+	if popup := c.mentionView(); popup != "" {
+		historyView = overlayBottom(historyView, popup)
+	}
+	helpView := c.historyViewStyle.Render(c.helpView())
+	return lipgloss.JoinVertical(lipgloss.Left, historyView, c.statusView(), inputView, helpView)
+}
+
+// statusView renders the single line status bar between history and input.
+// it is always one line tall so the layout doesn't jump when sending starts
+func (c *Chat) statusView() string {
+	status := " "
+	switch {
+	case c.history.InCopyMode():
+		status = i18n.T("copy mode: move with hjkl/arrows, v to select, y to copy, esc to leave")
+	case c.history.Selecting():
+		status = i18n.T("selecting, release to copy")
+	case c.replay != nil:
+		status = c.replayStatus()
+	case c.offline && !c.sending:
+		status = c.errorStyle.Render(i18n.T("offline: can't reach the provider, sending resumes once it's back"))
+	case c.sending && c.stalled > 0:
+		status = i18n.Tf("%s stalled, nothing received for %s · r retry · c cancel · w wait", c.status.spinner.View(), c.stalled.Truncate(time.Second))
+	case c.sending && c.accessible:
+		// no spinner or counters, they'd be read out as they change
+		status = i18n.T("responding…")
+		if c.queued > 0 {
+			status += " · " + i18n.Tf("%d queued", c.queued)
+		}
+	case c.sending && c.queued > 0:
+		status = i18n.Tf("%s · queued (%d)", c.status.view(), c.queued)
+	case c.sending:
+		status = c.status.view()
+	case c.pendingSend > 0:
+		status = i18n.Tf("sending in %s, esc to cancel", c.pendingSend)
+	}
+	var labels []string
+	if c.warning != "" {
+		labels = append(labels, c.errorStyle.Render(c.warning))
+	}
+	if c.incognito {
+		labels = append(labels, i18n.T("incognito"))
+	}
+	if c.locked {
+		labels = append(labels, i18n.T("locked"))
+	}
+	if c.patchMode {
+		labels = append(labels, i18n.T("patch"))
+	}
+	if c.jsonMode {
+		labels = append(labels, i18n.T("json"))
+	}
+	if c.persona != "" {
+		labels = append(labels, i18n.T("persona: ")+c.persona)
+	}
+	if len(labels) > 0 {
+		// labels go on the right, the spinner keeps the left
+		label := strings.Join(labels, " · ")
+		gap := c.history.Width - 2 - lipgloss.Width(status) - lipgloss.Width(label)
+		if gap > 0 {
+			status += strings.Repeat(" ", gap) + label
+		}
+	}
+	return c.statusStyle.MaxHeight(1).Render(status)
+}
+
+// PartialResponse returns what has been streamed of the current response
+func (c *Chat) PartialResponse() string {
+	if !c.sending {
+		return ""
+	}
+	return c.assistantResponse.String()
+}
+
+// SetStalled shows that nothing arrived of the response for d, 0 clears it
+func (c *Chat) SetStalled(d time.Duration) {
+	if d > 0 && c.stalled == 0 {
+		c.announce(i18n.T("the response stalled, r retries, c cancels, w keeps waiting"))
+	}
+	c.stalled = d
+}
+
+// SetWarning shows a warning in the status bar until it is set to ""
+func (c *Chat) SetWarning(warning string) {
+	c.warning = warning
+}
+
+// SetLocked marks the conversation as read-only in the status bar
+func (c *Chat) SetLocked(locked bool) {
+	c.locked = locked
+}
+
+// SetOffline shows the offline banner in the status bar, or removes it
+func (c *Chat) SetOffline(offline bool) {
+	if offline != c.offline {
+		if offline {
+			c.announce(i18n.T("offline: can't reach the provider, sending resumes once it's back"))
+		} else {
+			c.announce(i18n.T("back online"))
+		}
+	}
+	c.offline = offline
+}
+
+// SetIncognito marks the conversation as incognito in the status bar
+func (c *Chat) SetIncognito(incognito bool) {
+	c.incognito = incognito
+}
+
+// SetPatchMode shows that responses are expected to be diffs
+func (c *Chat) SetPatchMode(on bool) {
+	c.patchMode = on
+}
+
+// SetJSONMode shows that responses are expected to match a JSON schema
+func (c *Chat) SetJSONMode(on bool) {
+	c.jsonMode = on
+}
+
+// SetPersona shows the active persona in the status bar, "" hides it
+func (c *Chat) SetPersona(name string) {
+	c.persona = name
+}
+
+// AddNotice shows an informational line (command output, hints) in the
+// history. notices are not part of the conversation sent to the model
+func (c *Chat) AddNotice(text string) {
+	c.appendStyled(c.noticeStyle, text)
+	c.refreshHistory()
+}
+
+// AddError shows an error line in the history
+func (c *Chat) AddError(text string) {
+	c.appendStyled(c.errorStyle, c.spoken("error", text))
+	c.refreshHistory()
+}
+
+// AddWarning shows a line that needs attention but isn't an error, e.g.
+// that a response was cut off
+func (c *Chat) AddWarning(text string) {
+	c.appendStyled(c.warningStyle, c.spoken("warning", text))
+	c.refreshHistory()
+}
+
+// ResumeResponse shows partial as the start of the response that's about
+// to stream, which carries on from it. call it after SetSending
+func (c *Chat) ResumeResponse(partial string) {
+	c.assistantResponse.WriteString(partial)
+	c.renderLive()
+}
+
+// ReopenResponse takes the last response out of the history, it streams
+// again with the rest of it, see ResumeResponse. the lines after it stay
+func (c *Chat) ReopenResponse() {
+	if c.lastResponseBlock < 0 || c.lastResponseBlock >= c.history.Len() {
+		return
+	}
+	c.history.Remove(c.lastResponseBlock)
+	if c.lastPromptBlock > c.lastResponseBlock {
+		c.lastPromptBlock--
+	}
+	c.lastResponseBlock = -1
+	c.refreshHistory()
+}
+
+// refreshHistory re-sets the viewport content, keeping any response that is
+// currently streaming at the bottom
+func (c *Chat) refreshHistory() {
+	if c.sending && c.assistantResponse.Len() > 0 {
+		c.history.SetLive(c.liveResponse(c.history.Width))
+	} else {
+		c.history.SetLive("")
+	}
+	c.history.GotoBottom()
+}
+
+// AddAttachment shows a collapsed attachment line in the history and points
+// the input placeholder at it
+func (c *Chat) AddAttachment(summary string) {
+	c.appendStyled(c.userStyle, summary)
+	c.history.GotoBottom()
+	c.input.Placeholder = i18n.T("Ask about the attached content…")
 }
 
 func (c *Chat) ClearHistory() {
-	c.historyBuf.Reset()
+	c.history.Reset()
+	c.lastResponseBlock = -1
+	c.errorPanel = nil
 	c.assistantResponse.Reset()
-	c.history.SetContent("")
+	clear(c.markdownCache)
 }
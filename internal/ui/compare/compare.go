@@ -0,0 +1,243 @@
+package compare
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/logging"
+	"github.com/scbenet/ask/internal/ui"
+)
+
+// PaneMsg wraps a stream message (llm.StreamChunkMsg etc.) for one pane.
+// ID tells the prompts sent apart, the view shows whichever it's given
+type PaneMsg struct {
+	ID   int
+	Pane int
+	Msg  tea.Msg
+}
+
+// SendMsg is emitted when a prompt should be sent to both models
+type SendMsg struct{ Prompt string }
+
+// ExitMsg is emitted when the user leaves the compare view
+type ExitMsg struct{}
+
+type pane struct {
+	model     string
+	viewport  viewport.Model
+	history   []llm.Message
+	rendered  strings.Builder // finished turns
+	response  strings.Builder // response currently streaming
+	streaming bool
+}
+
+// Model shows two models' responses to the same prompt side by side
+type Model struct {
+	panes  [2]*pane
+	render ui.MarkdownRenderer
+	input  textarea.Model
+	width  int
+	height int
+
+	sendKey   key.Binding
+	exitKey   key.Binding
+	scrollKey key.Binding
+
+	headerStyle lipgloss.Style
+	paneStyle   lipgloss.Style
+	promptStyle lipgloss.Style
+	errorStyle  lipgloss.Style
+	inputStyle  lipgloss.Style
+}
+
+// New creates a compare view for two models, rendering their responses
+// with render
+func New(modelA, modelB string, render ui.MarkdownRenderer) *Model {
+	ti := textarea.New()
+	ti.Placeholder = i18n.T("Ask both models…")
+	ti.ShowLineNumbers = false
+	ti.CharLimit = 0
+	ti.SetHeight(3)
+	ti.Focus()
+
+	m := &Model{
+		render:      render,
+		input:       ti,
+		sendKey:     key.NewBinding(key.WithKeys("enter"), key.WithHelp("enter", i18n.T("send to both"))),
+		exitKey:     key.NewBinding(key.WithKeys("esc"), key.WithHelp("esc", i18n.T("back to chat"))),
+		scrollKey:   key.NewBinding(key.WithKeys("pgup", "pgdown", "ctrl+u", "ctrl+d"), key.WithHelp("pgup/pgdn", i18n.T("scroll"))),
+		headerStyle: lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FFFDF5")).Background(lipgloss.Color("#7D56F4")).Padding(0, 1),
+		paneStyle:   lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("#777")).Padding(0, 1),
+		promptStyle: lipgloss.NewStyle().Italic(true).Foreground(lipgloss.Color("#707070")),
+		errorStyle:  lipgloss.NewStyle().Foreground(lipgloss.Color("9")),
+		inputStyle:  lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("#777")),
+	}
+	for i, name := range []string{modelA, modelB} {
+		m.panes[i] = &pane{model: name, viewport: viewport.New(40, 10)}
+	}
+	return m
+}
+
+// Models returns the two models being compared
+func (m *Model) Models() [2]string {
+	return [2]string{m.panes[0].model, m.panes[1].model}
+}
+
+// History returns the conversation so far for one pane
+func (m *Model) History(i int) []llm.Message {
+	return append([]llm.Message{}, m.panes[i].history...)
+}
+
+// Streaming reports whether either pane is still receiving a response
+func (m *Model) Streaming() bool {
+	return m.panes[0].streaming || m.panes[1].streaming
+}
+
+// SetInput puts prompt back in the input, e.g. when it wasn't sent after all
+func (m *Model) SetInput(prompt string) {
+	m.input.SetValue(prompt)
+}
+
+// StartTurn records the prompt in both panes and marks them as streaming
+func (m *Model) StartTurn(prompt string) {
+	for _, p := range m.panes {
+		p.history = append(p.history, llm.Message{Role: "user", Content: prompt})
+		fmt.Fprintf(&p.rendered, "%s\n\n", m.promptStyle.Width(p.viewport.Width).Render("> "+prompt))
+		p.response.Reset()
+		p.streaming = true
+		m.refresh(p)
+	}
+}
+
+func (m *Model) Init() tea.Cmd {
+	return textarea.Blink
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.resize()
+		return m, nil
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, m.exitKey):
+			return m, func() tea.Msg { return ExitMsg{} }
+
+		case key.Matches(msg, m.sendKey):
+			prompt := strings.TrimSpace(m.input.Value())
+			if prompt == "" || m.Streaming() {
+				return m, nil
+			}
+			m.input.Reset()
+			return m, func() tea.Msg { return SendMsg{Prompt: prompt} }
+
+		case key.Matches(msg, m.scrollKey):
+			// scroll both panes together so the answers stay aligned
+			var cmds []tea.Cmd
+			for _, p := range m.panes {
+				var cmd tea.Cmd
+				p.viewport, cmd = p.viewport.Update(msg)
+				cmds = append(cmds, cmd)
+			}
+			return m, tea.Batch(cmds...)
+		}
+
+		var cmd tea.Cmd
+		m.input, cmd = m.input.Update(msg)
+		return m, cmd
+
+	case PaneMsg:
+		m.handlePaneMsg(msg)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m *Model) handlePaneMsg(msg PaneMsg) {
+	if msg.Pane < 0 || msg.Pane >= len(m.panes) {
+		return
+	}
+	p := m.panes[msg.Pane]
+
+	switch sm := msg.Msg.(type) {
+	case llm.StreamChunkMsg:
+		p.response.WriteString(sm.Content)
+
+	case llm.StreamEndMsg:
+		p.history = append(p.history, llm.Message{Role: "assistant", Content: sm.FullResponse})
+		fmt.Fprintf(&p.rendered, "%s\n\n", m.render(sm.FullResponse, p.viewport.Width))
+		p.response.Reset()
+		p.streaming = false
+
+	case llm.StreamErrorMsg:
+		logging.Errorf("compare pane %d stream: %v", msg.Pane, sm.Err)
+		fmt.Fprintf(&p.rendered, "%s\n\n", m.errorStyle.Width(p.viewport.Width).Render(i18n.T("error: ")+sm.Err.Error()))
+		// drop the unanswered prompt so follow ups stay consistent
+		if n := len(p.history); n > 0 && p.history[n-1].Role == "user" {
+			p.history = p.history[:n-1]
+		}
+		p.response.Reset()
+		p.streaming = false
+	}
+	m.refresh(p)
+}
+
+func (m *Model) refresh(p *pane) {
+	content := p.rendered.String()
+	if p.response.Len() > 0 {
+		content += lipgloss.NewStyle().Width(p.viewport.Width).Render(p.response.String())
+	}
+	p.viewport.SetContent(content)
+	p.viewport.GotoBottom()
+}
+
+func (m *Model) resize() {
+	inputHeight := lipgloss.Height(m.inputStyle.Render(m.input.View()))
+	headerHeight := 1
+	frameW, frameH := m.paneStyle.GetFrameSize()
+
+	paneWidth := max(m.width/2-frameW, 10)
+	paneHeight := max(m.height-inputHeight-headerHeight-frameH-1, 3)
+	for _, p := range m.panes {
+		p.viewport.Width = paneWidth
+		p.viewport.Height = paneHeight
+		m.refresh(p)
+	}
+	m.input.SetWidth(m.width - 2)
+}
+
+func (m *Model) View() string {
+	var columns []string
+	for _, p := range m.panes {
+		title := p.model
+		if p.streaming {
+			title += " …"
+		}
+		header := m.headerStyle.MaxWidth(p.viewport.Width).Render(title)
+		columns = append(columns, m.paneStyle.Render(lipgloss.JoinVertical(lipgloss.Left, header, p.viewport.View())))
+	}
+
+	help := lipgloss.NewStyle().Faint(true).Render(
+		fmt.Sprintf("%s %s • %s %s • %s %s",
+			m.sendKey.Help().Key, m.sendKey.Help().Desc,
+			m.scrollKey.Help().Key, m.scrollKey.Help().Desc,
+			m.exitKey.Help().Key, m.exitKey.Help().Desc),
+	)
+	return lipgloss.JoinVertical(lipgloss.Left,
+		lipgloss.JoinHorizontal(lipgloss.Top, columns...),
+		m.inputStyle.Render(m.input.View()),
+		help,
+	)
+}
@@ -0,0 +1,55 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ContextItem is an attachment listed in a context summary
+type ContextItem struct {
+	Name   string
+	Tokens int // estimated
+}
+
+// maxContextItems is how many items a summary lists before leaving the
+// rest out
+const maxContextItems = 20
+
+var contextPanelStyle = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("#777")).Padding(0, 1)
+
+// AddContextSummary shows a panel listing attached items with the tokens
+// each adds to the prompt, title above them and footer (e.g. the total)
+// below
+func (c *Chat) AddContextSummary(title string, items []ContextItem, footer string) {
+	c.history.Append(func(width int) string {
+		shown := items[:min(len(items), maxContextItems)]
+		tokens := make([]string, len(shown))
+		nameWidth, tokensWidth := 0, 0
+		for i, item := range shown {
+			tokens[i] = fmt.Sprintf("~%d tokens", item.Tokens)
+			nameWidth = max(nameWidth, lipgloss.Width(item.Name))
+			tokensWidth = max(tokensWidth, len(tokens[i]))
+		}
+		// long paths are cut short rather than wrapped
+		nameWidth = min(nameWidth, max(width-tokensWidth-8, 10))
+
+		lines := []string{c.labelStyle.Render(title)}
+		for i, item := range shown {
+			name := item.Name
+			if lipgloss.Width(name) > nameWidth {
+				name = "…" + string([]rune(name)[len([]rune(name))-nameWidth+1:])
+			}
+			lines = append(lines, fmt.Sprintf("%-*s  %*s", nameWidth, name, tokensWidth, tokens[i]))
+		}
+		if rest := len(items) - len(shown); rest > 0 {
+			lines = append(lines, c.noticeStyle.Render(fmt.Sprintf("… and %d more", rest)))
+		}
+		if footer != "" {
+			lines = append(lines, c.noticeStyle.Render(footer))
+		}
+		return contextPanelStyle.Render(strings.Join(lines, "\n"))
+	})
+	c.refreshHistory()
+}
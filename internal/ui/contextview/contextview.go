@@ -0,0 +1,198 @@
+package contextview
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// Model lists everything attached to the conversation: space includes or
+// excludes the highlighted item, d removes it and K/J move it up or down
+// among the attachments of its message. changes are sent to the app as
+// messages, it owns the attachments
+type Model struct {
+	list list.Model
+}
+
+// Ref points at an attachment: the index of its message in the
+// conversation, Pending for the next prompt's, and its index there
+type Ref struct {
+	Message int
+	Index   int
+}
+
+// Pending is the Message of attachments waiting for the next prompt
+const Pending = -1
+
+// Item is an attachment in the list
+type Item struct {
+	Ref      Ref
+	Name     string
+	Tokens   int // estimated
+	Excluded bool
+}
+
+func (i Item) FilterValue() string { return i.Name }
+
+// ToggleMsg asks for an attachment to be excluded from or included in what
+// is sent
+type ToggleMsg struct{ Ref Ref }
+
+// RemoveMsg asks for an attachment to be removed
+type RemoveMsg struct{ Ref Ref }
+
+// MoveMsg asks for an attachment to be moved by Delta places within its
+// message
+type MoveMsg struct {
+	Ref   Ref
+	Delta int
+}
+
+// ClosedMsg is sent when the view is closed
+type ClosedMsg struct{}
+
+var (
+	toggleKey = key.NewBinding(
+		key.WithKeys(" ", "x"),
+		key.WithHelp("space", "include/exclude"),
+	)
+	removeKey = key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "remove"),
+	)
+	moveUpKey = key.NewBinding(
+		key.WithKeys("K", "shift+up"),
+		key.WithHelp("K", "move up"),
+	)
+	moveDownKey = key.NewBinding(
+		key.WithKeys("J", "shift+down"),
+		key.WithHelp("J", "move down"),
+	)
+)
+
+type itemDelegate struct{}
+
+func (d itemDelegate) Height() int                               { return 1 }
+func (d itemDelegate) Spacing() int                              { return 0 }
+func (d itemDelegate) Update(msg tea.Msg, m *list.Model) tea.Cmd { return nil }
+
+func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	i, ok := listItem.(Item)
+	if !ok {
+		return
+	}
+
+	where := i18n.T("next message")
+	if i.Ref.Message != Pending {
+		where = i18n.Tf("message %d", i.Ref.Message+1)
+	}
+	check := "[x]"
+	if i.Excluded {
+		check = "[ ]"
+	}
+	str := i18n.Tf("%s %s  ~%d tokens · %s", check, i.Name, i.Tokens, where)
+	if maxWidth := m.Width() - 6; maxWidth > 1 && lipgloss.Width(str) > maxWidth {
+		r := []rune(str)
+		for len(r) > 0 && lipgloss.Width(string(r)) > maxWidth-1 {
+			r = r[:len(r)-1]
+		}
+		str = string(r) + "…"
+	}
+
+	style := lipgloss.NewStyle().PaddingLeft(4)
+	if i.Excluded {
+		style = style.Faint(true)
+	}
+	if index == m.Index() {
+		fmt.Fprint(w, style.PaddingLeft(2).Foreground(lipgloss.Color("#7D56F4")).Render("> "+str))
+		return
+	}
+	fmt.Fprint(w, style.Render(str))
+}
+
+func New() *Model {
+	l := list.New(nil, itemDelegate{}, 40, 14)
+	l.Title = i18n.T("Context")
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(true)
+	l.SetStatusBarItemName("attachment", "attachments")
+	l.Styles.Title = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FFFDF5")).
+		Background(lipgloss.Color("#7D56F4")).
+		Padding(0, 1)
+	l.Styles.PaginationStyle = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
+	l.Styles.HelpStyle = list.DefaultStyles().HelpStyle.PaddingLeft(4).PaddingBottom(1)
+	l.AdditionalShortHelpKeys = func() []key.Binding { return i18n.Keys(toggleKey, removeKey, moveUpKey, moveDownKey) }
+	i18n.List(&l)
+
+	return &Model{list: l}
+}
+
+// SetItems replaces the listed attachments, keeping selected highlighted
+// if it's still there and the selection in place otherwise. the title
+// shows the tokens of what's included
+func (m *Model) SetItems(items []Item, selected Ref) {
+	listItems := make([]list.Item, len(items))
+	index := m.list.Index()
+	total := 0
+	for i, item := range items {
+		listItems[i] = item
+		if item.Ref == selected {
+			index = i
+		}
+		if !item.Excluded {
+			total += item.Tokens
+		}
+	}
+	m.list.SetItems(listItems)
+	if index >= len(items) {
+		index = len(items) - 1
+	}
+	m.list.Select(max(index, 0))
+	m.list.Title = i18n.Tf("Context (~%d tokens included)", total)
+}
+
+func (m *Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.list.SetSize(msg.Width, msg.Height-2)
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+		selected, haveSelection := m.list.SelectedItem().(Item)
+		switch {
+		case msg.String() == "esc" || msg.String() == "q":
+			if m.list.FilterState() == list.Unfiltered {
+				return m, func() tea.Msg { return ClosedMsg{} }
+			}
+		case key.Matches(msg, toggleKey) && haveSelection:
+			return m, func() tea.Msg { return ToggleMsg{Ref: selected.Ref} }
+		case key.Matches(msg, removeKey) && haveSelection:
+			return m, func() tea.Msg { return RemoveMsg{Ref: selected.Ref} }
+		case key.Matches(msg, moveUpKey) && haveSelection:
+			return m, func() tea.Msg { return MoveMsg{Ref: selected.Ref, Delta: -1} }
+		case key.Matches(msg, moveDownKey) && haveSelection:
+			return m, func() tea.Msg { return MoveMsg{Ref: selected.Ref, Delta: 1} }
+		}
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m *Model) View() string {
+	return "\n" + m.list.View()
+}
@@ -0,0 +1,139 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// copy mode works like tmux's: a cursor is moved over the history with vi
+// or arrow keys, v starts selecting at the cursor and y copies the selection
+// as plain text. it shares the selection with mouse dragging
+type copyKeyMap struct {
+	Left, Right, Up, Down    key.Binding
+	LineStart, LineEnd       key.Binding
+	Top, Bottom              key.Binding
+	HalfPageUp, HalfPageDown key.Binding
+	Select, Copy, Exit       key.Binding
+}
+
+func defaultCopyKeyMap() copyKeyMap {
+	return copyKeyMap{
+		Left:         key.NewBinding(key.WithKeys("h", "left"), key.WithHelp("h/←", i18n.T("left"))),
+		Right:        key.NewBinding(key.WithKeys("l", "right"), key.WithHelp("l/→", i18n.T("right"))),
+		Up:           key.NewBinding(key.WithKeys("k", "up"), key.WithHelp("k/↑", i18n.T("up"))),
+		Down:         key.NewBinding(key.WithKeys("j", "down"), key.WithHelp("j/↓", i18n.T("down"))),
+		LineStart:    key.NewBinding(key.WithKeys("0", "home"), key.WithHelp("0", i18n.T("line start"))),
+		LineEnd:      key.NewBinding(key.WithKeys("$", "end"), key.WithHelp("$", i18n.T("line end"))),
+		Top:          key.NewBinding(key.WithKeys("g"), key.WithHelp("g", i18n.T("top"))),
+		Bottom:       key.NewBinding(key.WithKeys("G"), key.WithHelp("G", i18n.T("bottom"))),
+		HalfPageUp:   key.NewBinding(key.WithKeys("ctrl+u", "pgup"), key.WithHelp("ctrl+u", i18n.T("½ page up"))),
+		HalfPageDown: key.NewBinding(key.WithKeys("ctrl+d", "pgdown"), key.WithHelp("ctrl+d", i18n.T("½ page down"))),
+		Select:       key.NewBinding(key.WithKeys("v", " "), key.WithHelp("v", i18n.T("select"))),
+		Copy:         key.NewBinding(key.WithKeys("y", "enter"), key.WithHelp("y", i18n.T("copy"))),
+		Exit:         key.NewBinding(key.WithKeys("esc", "q", "ctrl+c"), key.WithHelp("esc", i18n.T("leave"))),
+	}
+}
+
+// EnterCopyMode puts the cursor at the start of the last visible line
+func (h *historyView) EnterCopyMode() {
+	h.copying = true
+	h.cursor = position{line: max(min(h.offset+h.Height, h.lineCount())-1, 0)}
+	h.selection = selection{}
+}
+
+// ExitCopyMode leaves copy mode, dropping any selection
+func (h *historyView) ExitCopyMode() {
+	h.copying = false
+	h.selection = selection{}
+}
+
+// InCopyMode reports whether the cursor is shown
+func (h *historyView) InCopyMode() bool {
+	return h.copying
+}
+
+// moveCursor moves the copy mode cursor to p, scrolling to keep it visible
+// and dragging the end of the selection along
+func (h *historyView) moveCursor(p position) {
+	p.line = min(max(p.line, 0), max(h.lineCount()-1, 0))
+	p.col = min(max(p.col, 0), max(ansi.StringWidth(ansi.Strip(h.lineAt(p.line)))-1, 0))
+	h.cursor = p
+	if p.line < h.offset {
+		h.offset = p.line
+	} else if p.line >= h.offset+h.Height {
+		h.offset = p.line - h.Height + 1
+	}
+	h.clampOffset()
+	if h.selection.active {
+		h.selection.cursor = p
+	}
+}
+
+// updateCopyMode handles a key in copy mode, it reports whether the key
+// asked for the selection to be copied
+func (h *historyView) updateCopyMode(msg tea.KeyMsg) (copy bool) {
+	p := h.cursor
+	switch {
+	case key.Matches(msg, h.copyKeys.Left):
+		p.col--
+	case key.Matches(msg, h.copyKeys.Right):
+		p.col++
+	case key.Matches(msg, h.copyKeys.Up):
+		p.line--
+	case key.Matches(msg, h.copyKeys.Down):
+		p.line++
+	case key.Matches(msg, h.copyKeys.LineStart):
+		p.col = 0
+	case key.Matches(msg, h.copyKeys.LineEnd):
+		p.col = ansi.StringWidth(strings.TrimRight(ansi.Strip(h.lineAt(p.line)), " ")) - 1
+	case key.Matches(msg, h.copyKeys.Top):
+		p = position{}
+	case key.Matches(msg, h.copyKeys.Bottom):
+		p = position{line: h.lineCount() - 1}
+	case key.Matches(msg, h.copyKeys.HalfPageUp):
+		p.line -= h.Height / 2
+	case key.Matches(msg, h.copyKeys.HalfPageDown):
+		p.line += h.Height / 2
+	case key.Matches(msg, h.copyKeys.Select):
+		if h.selection.active {
+			h.selection = selection{}
+		} else {
+			h.selection = selection{active: true, anchor: h.cursor, cursor: h.cursor}
+		}
+		return false
+	case key.Matches(msg, h.copyKeys.Copy):
+		return true
+	}
+	h.moveCursor(p)
+	return false
+}
+
+// InCopyMode reports whether the history is in copy mode, which takes all keys
+func (c *Chat) InCopyMode() bool {
+	return c.history.InCopyMode()
+}
+
+// updateCopyMode handles keys in copy mode. copying without a selection
+// copies the line under the cursor
+func (c *Chat) updateCopyMode(msg tea.KeyMsg) tea.Cmd {
+	if key.Matches(msg, c.history.copyKeys.Exit) {
+		c.history.ExitCopyMode()
+		return c.input.Focus()
+	}
+	if !c.history.updateCopyMode(msg) {
+		return nil
+	}
+	if !c.history.selection.active {
+		line := c.history.cursor.line
+		width := ansi.StringWidth(strings.TrimRight(ansi.Strip(c.history.lineAt(line)), " "))
+		c.history.selection = selection{active: true, anchor: position{line: line}, cursor: position{line: line, col: max(width-1, 0)}}
+	}
+	text := c.history.SelectedText()
+	c.history.ExitCopyMode()
+	c.copyText(text)
+	return c.input.Focus()
+}
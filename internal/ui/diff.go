@@ -0,0 +1,128 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/scbenet/ask/internal/textdiff"
+)
+
+var (
+	removedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Strikethrough(true)
+	addedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+)
+
+// AddDiff shows what changed between two versions of a response, word by
+// word: removed words struck through in red, added ones in green
+func (c *Chat) AddDiff(old, new string) {
+	spans := textdiff.Words(old, new)
+	removed, added := textdiff.Changed(spans)
+	summary := fmt.Sprintf("%d word(s) removed, %d added", removed, added)
+	if removed == 0 && added == 0 {
+		summary = "the responses are the same"
+	}
+	c.history.Append(func(width int) string {
+		var b strings.Builder
+		for _, s := range spans {
+			switch s.Kind {
+			case textdiff.Delete:
+				b.WriteString(styleLines(removedStyle, s.Text))
+			case textdiff.Insert:
+				b.WriteString(styleLines(addedStyle, s.Text))
+			default:
+				b.WriteString(s.Text)
+			}
+		}
+		text := lipgloss.NewStyle().Width(max(width, 80)).Render(b.String())
+		return c.noticeStyle.Render(summary) + "\n" + text
+	})
+	c.refreshHistory()
+}
+
+// styleLines styles each line of text on its own, so wrapping and line
+// breaks don't carry the style into the text around it
+func styleLines(style lipgloss.Style, text string) string {
+	lines := strings.Split(text, "\n")
+	for i, l := range lines {
+		if l != "" {
+			lines[i] = style.Render(l)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// diffContext is how many unchanged lines are shown around changes
+const diffContext = 3
+
+var removedLineStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+
+// AddFileDiff shows what writing new over a file with old in it changes,
+// line by line with a few unchanged lines around each change
+func (c *Chat) AddFileDiff(path, old, new string) {
+	type line struct {
+		kind textdiff.Kind
+		text string
+	}
+	var lines []line
+	removed, added := 0, 0
+	for _, s := range textdiff.Lines(old, new) {
+		for _, l := range strings.SplitAfter(strings.TrimSuffix(s.Text, "\n"), "\n") {
+			lines = append(lines, line{s.Kind, strings.TrimSuffix(l, "\n")})
+			switch s.Kind {
+			case textdiff.Delete:
+				removed++
+			case textdiff.Insert:
+				added++
+			}
+		}
+	}
+	// unchanged lines further than diffContext from any change are skipped
+	show := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.kind == textdiff.Equal {
+			continue
+		}
+		for j := max(i-diffContext, 0); j <= min(i+diffContext, len(lines)-1); j++ {
+			show[j] = true
+		}
+	}
+
+	header := fmt.Sprintf("%s: %d line(s) removed, %d added", path, removed, added)
+	switch {
+	case old == "":
+		header = fmt.Sprintf("%s: new file, %d line(s)", path, added)
+	case new == "":
+		header = fmt.Sprintf("%s: deleted, %d line(s)", path, removed)
+	}
+	c.history.Append(func(width int) string {
+		var b strings.Builder
+		b.WriteString(c.labelStyle.Render(header))
+		skipped := false
+		for i, l := range lines {
+			if !show[i] {
+				skipped = true
+				continue
+			}
+			b.WriteString("\n")
+			if skipped {
+				b.WriteString(c.noticeStyle.Render("…") + "\n")
+				skipped = false
+			}
+			text := lipgloss.NewStyle().MaxWidth(max(width-2, 10)).Render(l.text)
+			switch l.kind {
+			case textdiff.Delete:
+				b.WriteString(removedLineStyle.Render("- " + text))
+			case textdiff.Insert:
+				b.WriteString(addedStyle.Render("+ " + text))
+			default:
+				b.WriteString("  " + text)
+			}
+		}
+		if skipped {
+			b.WriteString("\n" + c.noticeStyle.Render("…"))
+		}
+		return b.String()
+	})
+	c.refreshHistory()
+}
@@ -0,0 +1,129 @@
+package ui
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// ErrorReport is a failed request as the error panel shows it
+type ErrorReport struct {
+	Message   string // short, the first line of the panel
+	Status    int    // HTTP status the provider answered with, 0 if none
+	Body      string // what the provider said, shown with the details
+	RequestID string
+	Model     string
+}
+
+// String is the whole report as plain text, for the clipboard
+func (r ErrorReport) String() string {
+	var b strings.Builder
+	b.WriteString(r.Message)
+	for _, d := range r.details() {
+		b.WriteString("\n" + d)
+	}
+	return b.String()
+}
+
+// details are the lines shown once the panel is expanded
+func (r ErrorReport) details() []string {
+	var lines []string
+	if r.Status != 0 {
+		lines = append(lines, i18n.Tf("status: %d %s", r.Status, http.StatusText(r.Status)))
+	}
+	if r.Model != "" {
+		lines = append(lines, i18n.T("model: ")+r.Model)
+	}
+	if r.RequestID != "" {
+		lines = append(lines, i18n.T("request: ")+r.RequestID)
+	}
+	if r.Body != "" {
+		lines = append(lines, i18n.T("response:"), r.Body)
+	}
+	return lines
+}
+
+// RetryMsg asks the app to send the failed prompt again
+type RetryMsg struct{}
+
+// errorPanel is the last error in the history, its keys work until the
+// next prompt is sent
+type errorPanel struct {
+	report   ErrorReport
+	expanded bool
+	block    int // in the history
+}
+
+type errorKeyMap struct {
+	Retry   key.Binding
+	Model   key.Binding // handled by the app, like everywhere else
+	Details key.Binding
+	Copy    key.Binding
+}
+
+func defaultErrorKeyMap() errorKeyMap {
+	return errorKeyMap{
+		Retry: key.NewBinding(
+			key.WithKeys("ctrl+r"),
+			key.WithHelp("ctrl+r", i18n.T("retry")),
+		),
+		Model: key.NewBinding(
+			key.WithKeys("ctrl+k"),
+			key.WithHelp("ctrl+k", i18n.T("switch model")),
+		),
+		Details: key.NewBinding(
+			key.WithKeys("ctrl+t"),
+			key.WithHelp("ctrl+t", i18n.T("details")),
+		),
+		Copy: key.NewBinding(
+			key.WithKeys("alt+c"),
+			key.WithHelp("alt+c", i18n.T("copy error")),
+		),
+	}
+}
+
+// ShowError adds an error panel for a failed request to the history
+func (c *Chat) ShowError(report ErrorReport) {
+	panel := &errorPanel{report: report, block: c.history.Len()}
+	c.history.Append(func(width int) string {
+		text := c.spoken("error", report.Message)
+		if panel.expanded {
+			text += "\n" + c.noticeStyle.Render(strings.Join(report.details(), "\n"))
+		}
+		return c.errorStyle.Width(max(width, 80)).Render(text)
+	})
+	c.errorPanel = panel
+	c.refreshHistory()
+}
+
+// errorPanelKey handles the keys of the error panel, false if msg isn't one
+func (c *Chat) errorPanelKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	panel := c.errorPanel
+	switch {
+	case key.Matches(msg, c.errorKeys.Retry):
+		c.errorPanel = nil
+		return func() tea.Msg { return RetryMsg{} }, true
+	case key.Matches(msg, c.errorKeys.Details):
+		if len(panel.report.details()) > 0 {
+			panel.expanded = !panel.expanded
+			c.history.Rerender(panel.block)
+		}
+		return nil, true
+	case key.Matches(msg, c.errorKeys.Copy):
+		c.copyText(panel.report.String())
+		return nil, true
+	}
+	return nil, false
+}
+
+// errorHelp lists the error panel's keys with the usual ones to go on
+func (c *Chat) errorHelp() keyList {
+	details := c.errorKeys.Details
+	if c.errorPanel.expanded {
+		details.SetHelp(details.Help().Key, i18n.T("hide details"))
+	}
+	return keyList{c.errorKeys.Retry, c.errorKeys.Model, details, c.errorKeys.Copy, c.keys.SendPrompt, c.keys.Quit}
+}
@@ -0,0 +1,328 @@
+package fanout
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/logging"
+	"github.com/scbenet/ask/internal/ui"
+)
+
+// PaneMsg wraps a stream message (llm.StreamChunkMsg etc.) for one model's
+// tab. ID tells the fan-outs apart, messages of one that was closed can
+// still arrive
+type PaneMsg struct {
+	ID   int
+	Pane int
+	Msg  tea.Msg
+}
+
+// KeepMsg asks for a response to be kept in the conversation
+type KeepMsg struct {
+	Pane     int
+	Model    string
+	Response string
+	Usage    *llm.Usage
+}
+
+// ClosedMsg is sent when the view is left without keeping a response
+type ClosedMsg struct{}
+
+// JudgeMsg asks for the answers to be scored, see SetScores
+type JudgeMsg struct{}
+
+// Score is a judge's verdict on an answer, Value out of 10
+type Score struct {
+	Value  float64
+	Reason string
+}
+
+type tab struct {
+	model    string
+	response strings.Builder
+	usage    *llm.Usage
+	rendered string // once the response is complete
+	err      error
+	done     bool
+	score    *Score
+}
+
+// Model shows the responses of several models to the same prompt in tabs,
+// tab switches between them and enter keeps the one shown
+type Model struct {
+	prompt   string
+	render   ui.MarkdownRenderer
+	tabs     []*tab
+	selected int
+	viewport viewport.Model
+	width    int
+	height   int
+	// how judging went, shown in place of the help while it's set
+	judgeStatus string
+
+	titleStyle    lipgloss.Style
+	tabStyle      lipgloss.Style
+	activeStyle   lipgloss.Style
+	errorStyle    lipgloss.Style
+	viewportStyle lipgloss.Style
+	helpStyle     lipgloss.Style
+}
+
+var (
+	nextKey = key.NewBinding(
+		key.WithKeys("tab", "right", "l"),
+		key.WithHelp("tab", "next"),
+	)
+	previousKey = key.NewBinding(
+		key.WithKeys("shift+tab", "left", "h"),
+		key.WithHelp("shift+tab", "previous"),
+	)
+	jumpKey = key.NewBinding(
+		key.WithKeys("1", "2", "3", "4", "5", "6", "7", "8", "9"),
+		key.WithHelp("1-9", "jump"),
+	)
+	judgeKey = key.NewBinding(
+		key.WithKeys("J"),
+		key.WithHelp("J", "judge"),
+	)
+	keepKey = key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("enter", "keep this one"),
+	)
+	closeKey = key.NewBinding(
+		key.WithKeys("esc"),
+		key.WithHelp("esc", "discard all"),
+	)
+	scrollKey = key.NewBinding(
+		key.WithKeys("up", "down", "k", "j", "pgup", "pgdown", "ctrl+u", "ctrl+d"),
+		key.WithHelp("↑/↓", "scroll"),
+	)
+)
+
+// New creates the view for the responses of models to prompt, rendered
+// with render
+func New(prompt string, models []string, render ui.MarkdownRenderer) *Model {
+	m := &Model{
+		prompt:        prompt,
+		render:        render,
+		viewport:      viewport.New(80, 10),
+		titleStyle:    lipgloss.NewStyle().Bold(true),
+		tabStyle:      lipgloss.NewStyle().Padding(0, 1).Foreground(lipgloss.Color("#777")),
+		activeStyle:   lipgloss.NewStyle().Padding(0, 1).Bold(true).Foreground(lipgloss.Color("#FFFDF5")).Background(lipgloss.Color("#7D56F4")),
+		errorStyle:    lipgloss.NewStyle().Foreground(lipgloss.Color("9")),
+		viewportStyle: lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("#777")).Padding(0, 1),
+		helpStyle:     lipgloss.NewStyle().Faint(true),
+	}
+	for _, model := range models {
+		m.tabs = append(m.tabs, &tab{model: model})
+	}
+	return m
+}
+
+// Streaming reports whether any model is still answering
+func (m *Model) Streaming() bool {
+	for _, t := range m.tabs {
+		if !t.done {
+			return true
+		}
+	}
+	return false
+}
+
+// Answers returns the tabs that answered and their responses
+func (m *Model) Answers() (panes []int, responses []string) {
+	for i, t := range m.tabs {
+		if t.done && t.err == nil {
+			panes = append(panes, i)
+			responses = append(responses, t.response.String())
+		}
+	}
+	return panes, responses
+}
+
+// SetScores shows the judge's scores by tab, next to the models
+func (m *Model) SetScores(scores map[int]Score) {
+	for i, t := range m.tabs {
+		if s, ok := scores[i]; ok {
+			t.score = &s
+		} else {
+			t.score = nil
+		}
+	}
+}
+
+// SetJudgeStatus shows how judging is going, "" shows the help again
+func (m *Model) SetJudgeStatus(status string) {
+	m.judgeStatus = status
+}
+
+func (m *Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.resize()
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, closeKey):
+			return m, func() tea.Msg { return ClosedMsg{} }
+		case key.Matches(msg, nextKey):
+			m.selectTab((m.selected + 1) % len(m.tabs))
+		case key.Matches(msg, previousKey):
+			m.selectTab((m.selected + len(m.tabs) - 1) % len(m.tabs))
+		case key.Matches(msg, jumpKey):
+			if i := int(msg.Runes[0] - '1'); i < len(m.tabs) {
+				m.selectTab(i)
+			}
+		case key.Matches(msg, judgeKey):
+			if m.Streaming() {
+				m.judgeStatus = i18n.T("wait for every answer before judging")
+				return m, nil
+			}
+			return m, func() tea.Msg { return JudgeMsg{} }
+		case key.Matches(msg, keepKey):
+			t := m.tabs[m.selected]
+			if !t.done || t.err != nil {
+				return m, nil
+			}
+			keep := KeepMsg{Pane: m.selected, Model: t.model, Response: t.response.String(), Usage: t.usage}
+			return m, func() tea.Msg { return keep }
+		case key.Matches(msg, scrollKey):
+			var cmd tea.Cmd
+			m.viewport, cmd = m.viewport.Update(msg)
+			return m, cmd
+		}
+
+	case PaneMsg:
+		m.handlePaneMsg(msg)
+	}
+	return m, nil
+}
+
+func (m *Model) handlePaneMsg(msg PaneMsg) {
+	if msg.Pane < 0 || msg.Pane >= len(m.tabs) {
+		return
+	}
+	t := m.tabs[msg.Pane]
+	switch sm := msg.Msg.(type) {
+	case llm.StreamChunkMsg:
+		t.response.WriteString(sm.Content)
+	case llm.StreamEndMsg:
+		t.response.Reset()
+		t.response.WriteString(sm.FullResponse)
+		t.usage = sm.Usage
+		t.done = true
+		t.rendered = m.render(sm.FullResponse, m.viewport.Width)
+	case llm.StreamErrorMsg:
+		logging.Errorf("fanout to %s: %v", t.model, sm.Err)
+		t.err = sm.Err
+		t.done = true
+	default:
+		return
+	}
+	if msg.Pane == m.selected {
+		m.refresh(false)
+	}
+}
+
+func (m *Model) selectTab(i int) {
+	m.selected = i
+	m.refresh(true)
+}
+
+// refresh shows the selected tab's response, from the top when the tab
+// was switched to and following the stream otherwise
+func (m *Model) refresh(switched bool) {
+	t := m.tabs[m.selected]
+	var content string
+	switch {
+	case t.err != nil:
+		content = m.errorStyle.Width(m.viewport.Width).Render(i18n.T("error: ") + t.err.Error())
+	case t.rendered != "":
+		content = t.rendered
+	case t.response.Len() == 0:
+		content = m.helpStyle.Render(i18n.Tf("waiting for %s…", t.model))
+	default:
+		content = lipgloss.NewStyle().Width(m.viewport.Width).Render(t.response.String())
+	}
+	m.viewport.SetContent(content)
+	if switched {
+		m.viewport.GotoTop()
+	} else if !t.done {
+		m.viewport.GotoBottom()
+	}
+}
+
+func (m *Model) resize() {
+	frameW, frameH := m.viewportStyle.GetFrameSize()
+	width := max(m.width-frameW, 20)
+	// title, tabs, the judge's reason and help take a line each
+	m.viewport.Width = width
+	m.viewport.Height = max(m.height-frameH-4, 3)
+	for _, t := range m.tabs {
+		if t.done && t.err == nil {
+			t.rendered = m.render(t.response.String(), width)
+		}
+	}
+	m.refresh(false)
+}
+
+func (m *Model) View() string {
+	prompt := strings.Join(strings.Fields(m.prompt), " ")
+	if r := []rune(prompt); len(r) > 60 {
+		prompt = string(r[:59]) + "…"
+	}
+	title := m.titleStyle.Render(i18n.Tf("%d models answering: ", len(m.tabs))) + prompt
+
+	var tabs []string
+	for i, t := range m.tabs {
+		status := " …"
+		switch {
+		case t.err != nil:
+			status = " ✗"
+		case t.done:
+			status = " ✓"
+		}
+		label := fmt.Sprintf("%d %s%s", i+1, t.model, status)
+		if t.score != nil {
+			label += fmt.Sprintf(" %g/10", t.score.Value)
+		}
+		if i == m.selected {
+			tabs = append(tabs, m.activeStyle.Render(label))
+		} else {
+			tabs = append(tabs, m.tabStyle.Render(label))
+		}
+	}
+
+	// always a line, so the layout doesn't jump once scores arrive
+	reason := " "
+	if s := m.tabs[m.selected].score; s != nil {
+		reason = i18n.Tf("judge: %g/10 %s", s.Value, s.Reason)
+	}
+
+	var help []string
+	for _, b := range i18n.Keys(nextKey, jumpKey, scrollKey, judgeKey, keepKey, closeKey) {
+		help = append(help, b.Help().Key+" "+b.Help().Desc)
+	}
+	status := strings.Join(help, " • ")
+	if m.judgeStatus != "" {
+		status = m.judgeStatus + " • " + status
+	}
+	return lipgloss.JoinVertical(lipgloss.Left,
+		lipgloss.NewStyle().MaxWidth(m.width).Render(title),
+		lipgloss.NewStyle().MaxWidth(m.width).Render(lipgloss.JoinHorizontal(lipgloss.Top, tabs...)),
+		m.helpStyle.MaxWidth(m.width).Render(reason),
+		m.viewportStyle.Render(m.viewport.View()),
+		m.helpStyle.MaxWidth(m.width).Render(status),
+	)
+}
@@ -0,0 +1,107 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/scbenet/ask/internal/llm"
+)
+
+// messageMeta is what a message's header shows besides the role's label
+type messageMeta struct {
+	time   time.Time
+	model  string // that wrote a response
+	effort string // reasoning effort a response was raised to
+	usage  *llm.Usage
+}
+
+// WithTimestamps shows when each message was sent, and which model wrote
+// each response, above it
+func WithTimestamps(timestamps bool) Option {
+	return func(c *Chat) { c.timestamps = timestamps }
+}
+
+// SetTimestamps shows or hides the times and models of the messages
+func (c *Chat) SetTimestamps(timestamps bool) {
+	c.timestamps = timestamps
+	// every block's header changed
+	c.history.RerenderAll()
+}
+
+// Timestamps reports whether the messages show their times
+func (c *Chat) Timestamps() bool {
+	return c.timestamps
+}
+
+// withHeader puts the header of a message above it: the role's label, how
+// much of its prompt was cached and, with timestamps on, when it was sent
+// and by which model
+func (c *Chat) withHeader(label string, meta messageMeta, rendered string) string {
+	var parts []string
+	if label != "" {
+		parts = append(parts, c.labelStyle.Render(label))
+	}
+	var info []string
+	if c.timestamps && !meta.time.IsZero() {
+		info = append(info, formatTimestamp(meta.time, c.status.clock.Now()))
+		if meta.model != "" {
+			info = append(info, meta.model)
+		}
+	} else if c.accessible && meta.model != "" {
+		// color and layout don't say who wrote it
+		info = append(info, meta.model)
+	}
+	// shown either way, it's why the response differs from the others
+	if meta.effort != "" {
+		info = append(info, "thought harder ("+meta.effort+" reasoning effort)")
+	}
+	// as is what of the prompt was cached, the cost comes down with it
+	if cache := cacheUsage(meta.usage); cache != "" {
+		info = append(info, cache)
+	}
+	if len(info) > 0 {
+		parts = append(parts, c.noticeStyle.Render(strings.Join(info, " · ")))
+	}
+	if len(parts) == 0 {
+		return rendered
+	}
+	return strings.Join(parts, " ") + "\n" + rendered
+}
+
+// cacheUsage describes how much of the prompt came from the provider's
+// cache, e.g. "9.1k of 9.8k prompt tokens cached", "" when none did
+func cacheUsage(u *llm.Usage) string {
+	if u == nil || u.CacheReadTokens == 0 && u.CacheWriteTokens == 0 {
+		return ""
+	}
+	var parts []string
+	if u.CacheReadTokens > 0 {
+		parts = append(parts, fmt.Sprintf("%s of %s prompt tokens cached", shortTokens(u.CacheReadTokens), shortTokens(u.PromptTokens)))
+	}
+	if u.CacheWriteTokens > 0 {
+		parts = append(parts, fmt.Sprintf("%s written to cache", shortTokens(u.CacheWriteTokens)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func shortTokens(n int) string {
+	if n >= 1000 {
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	}
+	return fmt.Sprint(n)
+}
+
+// formatTimestamp leaves out the date for today and the year for this year
+func formatTimestamp(t, now time.Time) string {
+	t = t.Local()
+	now = now.Local()
+	switch {
+	case t.YearDay() == now.YearDay() && t.Year() == now.Year():
+		return t.Format("15:04")
+	case t.Year() == now.Year():
+		return t.Format("Jan 2 15:04")
+	default:
+		return t.Format("Jan 2 2006 15:04")
+	}
+}
@@ -0,0 +1,78 @@
+package ui
+
+import (
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// keyList is a help.KeyMap of a few bindings, shown on one line either way
+type keyList []key.Binding
+
+func (k keyList) ShortHelp() []key.Binding  { return k }
+func (k keyList) FullHelp() [][]key.Binding { return [][]key.Binding{k} }
+
+// copyHelp shows the copy mode keys, fewer once a selection is started
+type copyHelp struct {
+	keys      copyKeyMap
+	selecting bool
+}
+
+func (h copyHelp) ShortHelp() []key.Binding {
+	if h.selecting {
+		return []key.Binding{h.keys.Copy, h.keys.Select, h.keys.Exit}
+	}
+	return []key.Binding{h.keys.Up, h.keys.Down, h.keys.Select, h.keys.Copy, h.keys.Exit}
+}
+
+func (h copyHelp) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{h.keys.Left, h.keys.Right, h.keys.Up, h.keys.Down},
+		{h.keys.LineStart, h.keys.LineEnd, h.keys.Top, h.keys.Bottom},
+		{h.keys.HalfPageUp, h.keys.HalfPageDown, h.keys.Select, h.keys.Copy, h.keys.Exit},
+	}
+}
+
+// helpKeys returns the keys that do something right now: the answers to a
+// question, copy mode's, the error panel's, the ones for a streaming
+// response or the usual
+func (c *Chat) helpKeys() help.KeyMap {
+	switch {
+	case len(c.choices) > 0:
+		return c.choices
+	case c.history.InCopyMode():
+		return copyHelp{keys: c.history.copyKeys, selecting: c.history.selection.active}
+	case c.history.Selecting():
+		return keyList{key.NewBinding(key.WithHelp("release", i18n.T("copy selection")))}
+	case c.errorPanel != nil && !c.sending:
+		return c.errorHelp()
+	case c.pendingSend > 0:
+		return keyList{c.keys.Cancel, c.keys.Quit}
+	case c.sending:
+		follow := c.keys.Follow
+		if c.following {
+			follow.SetHelp(follow.Help().Key, i18n.T("stop following"))
+		} else {
+			follow.SetHelp(follow.Help().Key, i18n.T("follow response"))
+		}
+		queue := c.keys.SendPrompt
+		queue.SetHelp(queue.Help().Key, i18n.T("queue message"))
+		return keyList{c.keys.Cancel, follow, queue, c.keys.CopyMode, c.keys.Quit}
+	default:
+		return c.keys
+	}
+}
+
+// helpView renders the help for the current state, as tall as the layout
+// made room for whatever is shown
+func (c *Chat) helpView() string {
+	height := lipgloss.Height(c.help.View(c.keys))
+	return lipgloss.NewStyle().Height(height).Render(c.help.View(c.helpKeys()))
+}
+
+// SetChoices shows the keys answering a question in the help instead of
+// the usual ones, none to go back
+func (c *Chat) SetChoices(choices ...key.Binding) {
+	c.choices = i18n.Keys(choices...)
+}
@@ -0,0 +1,431 @@
+package ui
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// historyView is the scrollable chat history. it replaces a viewport holding
+// the whole conversation as one string: messages are kept as separate
+// pre-rendered blocks and only the lines in the visible window are joined on
+// each render, so long sessions don't slow down every streamed chunk.
+//
+// blocks far above the window aren't rendered at all until they're scrolled
+// towards. the ones before laid have no lines yet and are filled in from the
+// bottom up by fill, so resuming or resizing a long session only renders
+// about a screen of it
+type historyView struct {
+	Width  int // set with SetWidth, which re-renders the blocks
+	Height int
+	KeyMap viewport.KeyMap
+	// of copy mode
+	copyKeys copyKeyMap
+
+	blocks  []historyBlock
+	starts  []int    // first line of each block, 0 for blocks not laid out
+	total   int      // lines in all blocks
+	live    []string // the response being streamed, below the blocks
+	offset  int      // first visible line
+	laid    int      // first laid out block, the ones before it have no lines yet
+	loading bool     // between BeginLoad and EndLoad
+
+	selection selection // being dragged with the mouse, see StartSelection
+	copying   bool      // in copy mode, see EnterCopyMode
+	cursor    position  // of copy mode
+
+	printed int   // blocks printed to the scrollback, see Chat.PrintHistory
+	reprint []int // printed blocks rendered again since
+}
+
+func newHistoryView(width, height int) historyView {
+	return historyView{Width: width, Height: height, KeyMap: CustomKeyMap(), copyKeys: defaultCopyKeyMap()}
+}
+
+// historyBlock is a message in the history, rendered for the current width
+type historyBlock struct {
+	render func(width int) string
+	lines  []string // followed by a blank line
+}
+
+func (b *historyBlock) layout(width int) {
+	b.lines = append(strings.Split(b.render(width), "\n"), "")
+}
+
+// Append adds a message at the end, render is called again with the new
+// width whenever it changes
+func (h *historyView) Append(render func(width int) string) {
+	b := historyBlock{render: render}
+	h.starts = append(h.starts, h.total)
+	h.blocks = append(h.blocks, b)
+	if h.loading {
+		// rendered by EndLoad, if it's ever in view
+		h.laid = len(h.blocks)
+		return
+	}
+	h.blocks[len(h.blocks)-1].layout(h.Width)
+	h.total += len(h.blocks[len(h.blocks)-1].lines)
+}
+
+// BeginLoad defers rendering of the blocks appended until EndLoad, which
+// only renders those in view. blocks already in the history are treated
+// the same way
+func (h *historyView) BeginLoad() {
+	for i := range h.blocks {
+		h.blocks[i].lines = nil
+		h.starts[i] = 0
+	}
+	h.total, h.offset = 0, 0
+	h.laid = len(h.blocks)
+	h.selection = selection{}
+	h.loading = true
+}
+
+// EndLoad renders the loaded blocks at the bottom of the history
+func (h *historyView) EndLoad() {
+	h.loading = false
+	h.GotoBottom()
+}
+
+// SetWidth re-renders the blocks from the top of the view down for the new
+// width, keeping the view at the bottom if it was there. blocks above it
+// are rendered again when scrolled to
+func (h *historyView) SetWidth(width int) {
+	if width == h.Width {
+		h.fill()
+		return
+	}
+	atBottom := h.AtBottom()
+	top := h.blockAt(h.offset)
+	h.Width = width
+	h.total = 0
+	for i := range h.blocks {
+		if i < top {
+			h.blocks[i].lines = nil
+			h.starts[i] = 0
+			continue
+		}
+		h.blocks[i].layout(width)
+		h.starts[i] = h.total
+		h.total += len(h.blocks[i].lines)
+	}
+	h.laid = top
+	// lines moved, the selection can't follow
+	h.selection = selection{}
+	h.offset = 0
+	if atBottom {
+		h.GotoBottom()
+	} else {
+		h.fill()
+	}
+	h.clampOffset()
+	if h.copying {
+		// lines moved, keep the cursor on one
+		h.moveCursor(h.cursor)
+	}
+}
+
+// Len is the number of blocks, see Truncate
+func (h *historyView) Len() int {
+	return len(h.blocks)
+}
+
+// Truncate drops all blocks from the nth on
+func (h *historyView) Truncate(n int) {
+	if n < 0 || n >= len(h.blocks) {
+		return
+	}
+	h.total = h.starts[n]
+	// already printed blocks stay in the scrollback, the ones replacing
+	// them are printed below
+	h.printed = min(h.printed, n)
+	h.reprint = slices.DeleteFunc(h.reprint, func(b int) bool { return b >= n })
+	h.blocks = h.blocks[:n]
+	h.starts = h.starts[:n]
+	h.laid = min(h.laid, n)
+	h.clampOffset()
+	h.fill()
+}
+
+// Remove drops the nth block, the ones after it move up. inline it stays
+// in the scrollback if it was printed
+func (h *historyView) Remove(n int) {
+	if n < 0 || n >= len(h.blocks) {
+		return
+	}
+	atBottom := h.AtBottom()
+	if n < h.laid {
+		h.laid--
+	} else {
+		start, removed := h.starts[n], len(h.blocks[n].lines)
+		for i := n + 1; i < len(h.blocks); i++ {
+			h.starts[i] -= removed
+		}
+		h.total -= removed
+		if start < h.offset {
+			// the lines in view move up with the rest
+			h.offset -= min(removed, h.offset-start)
+		}
+	}
+	if n < h.printed {
+		h.printed--
+	}
+	h.reprint = slices.DeleteFunc(h.reprint, func(b int) bool { return b == n })
+	for i, b := range h.reprint {
+		if b > n {
+			h.reprint[i] = b - 1
+		}
+	}
+	h.blocks = slices.Delete(h.blocks, n, n+1)
+	h.starts = slices.Delete(h.starts, n, n+1)
+	h.selection = selection{}
+	if atBottom {
+		h.GotoBottom()
+	}
+	h.clampOffset()
+	h.fill()
+}
+
+// Rerender renders the nth block again, for one whose content changed
+func (h *historyView) Rerender(n int) {
+	if n < h.printed && !slices.Contains(h.reprint, n) {
+		h.reprint = append(h.reprint, n)
+	}
+	if n < 0 || n >= len(h.blocks) || h.blocks[n].lines == nil {
+		// not laid out yet, it's rendered when scrolled to
+		return
+	}
+	atBottom := h.AtBottom()
+	before := len(h.blocks[n].lines)
+	h.blocks[n].layout(h.Width)
+	delta := len(h.blocks[n].lines) - before
+	// the blocks after a laid out one are laid out too
+	for i := n + 1; i < len(h.blocks); i++ {
+		h.starts[i] += delta
+	}
+	h.total += delta
+	h.selection = selection{}
+	if atBottom {
+		h.GotoBottom()
+	}
+	h.clampOffset()
+}
+
+// RerenderAll renders the laid out blocks again, for a change to all of
+// them like their headers. the view, the selection and the copy mode cursor
+// stay on the lines they were on, counted from the end of their block since
+// a header grows or shrinks at its top. the blocks not laid out yet pick up
+// the change when scrolled to
+func (h *historyView) RerenderAll() {
+	atBottom := h.AtBottom()
+	type mark struct{ block, fromEnd int }
+	markOf := func(line int) mark {
+		b := h.blockAt(line)
+		if b == len(h.blocks) {
+			// in the live response, below the blocks
+			return mark{b, h.total - line}
+		}
+		return mark{b, h.starts[b] + len(h.blocks[b].lines) - line}
+	}
+	lineOf := func(m mark) int {
+		if m.block == len(h.blocks) {
+			return h.total - m.fromEnd
+		}
+		start, n := h.starts[m.block], len(h.blocks[m.block].lines)
+		return max(start+n-m.fromEnd, start)
+	}
+	view, anchor, cursor, copyCursor := markOf(h.offset), markOf(h.selection.anchor.line), markOf(h.selection.cursor.line), markOf(h.cursor.line)
+
+	h.total = 0
+	for i := range h.blocks {
+		if i >= h.laid {
+			h.blocks[i].layout(h.Width)
+		}
+		h.starts[i] = h.total
+		h.total += len(h.blocks[i].lines)
+	}
+
+	h.offset = lineOf(view)
+	h.selection.anchor.line, h.selection.cursor.line = lineOf(anchor), lineOf(cursor)
+	h.cursor.line = lineOf(copyCursor)
+	if atBottom {
+		h.GotoBottom()
+	}
+	h.clampOffset()
+	h.fill()
+}
+
+// SetLive shows the response currently being streamed, "" removes it
+func (h *historyView) SetLive(rendered string) {
+	if rendered == "" {
+		h.live = nil
+	} else {
+		h.live = strings.Split(rendered, "\n")
+	}
+	h.clampOffset()
+}
+
+// Reset removes everything
+func (h *historyView) Reset() {
+	h.blocks, h.starts, h.live = nil, nil, nil
+	h.total, h.offset, h.laid = 0, 0, 0
+	h.printed, h.reprint = 0, nil
+	h.loading = false
+	h.selection = selection{}
+	h.copying = false
+}
+
+// blockAt returns the index of the block holding line n, len(blocks) if
+// it's below them
+func (h *historyView) blockAt(n int) int {
+	if n >= h.total {
+		return len(h.blocks)
+	}
+	// ties go to the last block, blocks not laid out share the first's start
+	return max(sort.Search(len(h.starts), func(i int) bool { return h.starts[i] > n })-1, 0)
+}
+
+// fill renders blocks above the laid out ones until there's a screen of
+// lines above the view, or none are left. the view stays where it was
+func (h *historyView) fill() {
+	if h.laid == 0 || h.loading || h.Height <= 0 {
+		return
+	}
+	atBottom := h.AtBottom()
+	added := 0
+	for h.laid > 0 {
+		above := h.offset + added
+		if atBottom {
+			above = h.lineCount() + added - h.Height
+		}
+		if above >= h.Height {
+			break
+		}
+		h.laid--
+		h.blocks[h.laid].layout(h.Width)
+		added += len(h.blocks[h.laid].lines)
+	}
+	if added == 0 {
+		return
+	}
+	h.total = 0
+	for i := range h.blocks {
+		h.starts[i] = h.total
+		h.total += len(h.blocks[i].lines)
+	}
+	h.offset += added
+	h.selection.anchor.line += added
+	h.selection.cursor.line += added
+	h.cursor.line += added
+	if atBottom {
+		h.offset = h.maxOffset()
+	}
+}
+
+func (h *historyView) lineCount() int {
+	return h.total + len(h.live)
+}
+
+func (h *historyView) maxOffset() int {
+	return max(h.lineCount()-h.Height, 0)
+}
+
+func (h *historyView) clampOffset() {
+	h.offset = min(max(h.offset, 0), h.maxOffset())
+}
+
+// GotoBottom scrolls to the end, except in copy mode which keeps the view
+// where the cursor is
+func (h *historyView) GotoBottom() {
+	if h.copying {
+		return
+	}
+	h.offset = h.maxOffset()
+	h.fill()
+}
+
+func (h *historyView) AtBottom() bool {
+	return h.offset >= h.maxOffset()
+}
+
+func (h *historyView) scroll(lines int) {
+	h.offset += lines
+	h.clampOffset()
+	h.fill()
+}
+
+// Update scrolls with the history key bindings and mouse wheel
+func (h *historyView) Update(msg tea.Msg) (historyView, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, h.KeyMap.PageDown):
+			h.scroll(h.Height)
+		case key.Matches(msg, h.KeyMap.PageUp):
+			h.scroll(-h.Height)
+		case key.Matches(msg, h.KeyMap.HalfPageDown):
+			h.scroll(h.Height / 2)
+		case key.Matches(msg, h.KeyMap.HalfPageUp):
+			h.scroll(-h.Height / 2)
+		case key.Matches(msg, h.KeyMap.Down):
+			h.scroll(1)
+		case key.Matches(msg, h.KeyMap.Up):
+			h.scroll(-1)
+		}
+	case tea.MouseMsg:
+		switch msg.Button {
+		case tea.MouseButtonWheelDown:
+			h.scroll(3)
+		case tea.MouseButtonWheelUp:
+			h.scroll(-3)
+		}
+	}
+	return *h, nil
+}
+
+// visibleLines collects the lines in the window starting at offset, without
+// touching the blocks above or below it
+func (h *historyView) visibleLines() []string {
+	lines := make([]string, 0, h.Height)
+	// last block starting at or before offset
+	b := sort.Search(len(h.starts), func(i int) bool { return h.starts[i] > h.offset }) - 1
+	if b >= 0 {
+		for skip := h.offset - h.starts[b]; b < len(h.blocks) && len(lines) < h.Height; b++ {
+			block := h.blocks[b].lines
+			if skip > 0 {
+				block = block[min(skip, len(block)):]
+				skip = 0
+			}
+			lines = append(lines, block[:min(len(block), h.Height-len(lines))]...)
+		}
+	}
+	if len(lines) < h.Height && len(h.live) > 0 {
+		live := h.live[max(h.offset-h.total, 0):]
+		lines = append(lines, live[:min(len(live), h.Height-len(lines))]...)
+	}
+	return lines
+}
+
+// highlightedLines is visibleLines with the selection marked
+func (h *historyView) highlightedLines() []string {
+	lines := h.visibleLines()
+	h.highlightSelection(lines)
+	return lines
+}
+
+func (h *historyView) View() string {
+	if h.Width <= 0 || h.Height <= 0 {
+		return ""
+	}
+	return lipgloss.NewStyle().
+		Width(h.Width).
+		Height(h.Height).
+		MaxWidth(h.Width).
+		MaxHeight(h.Height).
+		Render(strings.Join(h.highlightedLines(), "\n"))
+}
@@ -0,0 +1,179 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// lazyHistory returns a history of n blocks loaded the way LoadHistory
+// loads a session, and how often each block was rendered. every block is
+// three lines and the blank one after it
+func lazyHistory(n, width, height int) (*historyView, []int) {
+	h := newHistoryView(width, height)
+	rendered := make([]int, n)
+	h.BeginLoad()
+	for i := range n {
+		h.Append(func(width int) string {
+			rendered[i]++
+			return fmt.Sprintf("block %d\nat %d\n.", i, width)
+		})
+	}
+	h.EndLoad()
+	return &h, rendered
+}
+
+// viewText is what's in view, without the padding View adds
+func viewText(h *historyView) string {
+	return strings.Join(h.visibleLines(), "\n")
+}
+
+// laidOut counts the blocks that were rendered at least once
+func laidOut(rendered []int) int {
+	n := 0
+	for _, r := range rendered {
+		if r > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// TestHistoryLazyLoad only renders about two screens of a long history
+// and the rest as it's scrolled to
+func TestHistoryLazyLoad(t *testing.T) {
+	h, rendered := lazyHistory(100, 40, 10)
+	if n := laidOut(rendered); n == 0 || n > 6 {
+		t.Fatalf("rendered %d blocks on load, want the few near the bottom", n)
+	}
+	if view := viewText(h); !strings.Contains(view, "block 99") {
+		t.Fatalf("view isn't at the bottom:\n%s", view)
+	}
+
+	// scrolling a page up keeps the same lines at the top of the view
+	// while the blocks above are filled in
+	h.scroll(-h.Height)
+	top := h.visibleLines()[0]
+	h.scroll(-1)
+	h.scroll(1)
+	if got := h.visibleLines()[0]; got != top {
+		t.Fatalf("top line is %q after scrolling up and down, was %q", got, top)
+	}
+
+	for range 100 {
+		h.scroll(-h.Height)
+	}
+	if view := viewText(h); !strings.HasPrefix(view, "block 0") {
+		t.Fatalf("view at the top is:\n%s", view)
+	}
+	for i, r := range rendered {
+		if r != 1 {
+			t.Fatalf("block %d was rendered %d times, want once", i, r)
+		}
+	}
+	if h.total != 400 {
+		t.Fatalf("history has %d lines, want 400", h.total)
+	}
+}
+
+// TestHistoryLazyResize renders the blocks again from the view down and
+// the ones above once they're scrolled to
+func TestHistoryLazyResize(t *testing.T) {
+	h, rendered := lazyHistory(100, 40, 10)
+	before := laidOut(rendered)
+
+	h.SetWidth(60)
+	again := 0
+	for _, r := range rendered {
+		if r > 1 {
+			again++
+		}
+	}
+	if again == 0 || again > before {
+		t.Fatalf("rendered %d blocks again for the new width, %d were laid out", again, before)
+	}
+	if view := viewText(h); !strings.Contains(view, "block 99\nat 60") {
+		t.Fatalf("view after the resize:\n%s", view)
+	}
+
+	for range 100 {
+		h.scroll(-h.Height)
+	}
+	if view := viewText(h); !strings.HasPrefix(view, "block 0\nat 60") {
+		t.Fatalf("view at the top after the resize:\n%s", view)
+	}
+}
+
+// TestHistoryLazyEdits removes, truncates and re-renders blocks that
+// haven't been laid out yet
+func TestHistoryLazyEdits(t *testing.T) {
+	h, rendered := lazyHistory(100, 40, 10)
+
+	// not in view, it's rendered when scrolled to
+	h.Rerender(0)
+	if rendered[0] != 0 {
+		t.Fatal("rendered a block out of view")
+	}
+	h.Remove(1)
+	h.Truncate(50)
+	if h.Len() != 50 {
+		t.Fatalf("history has %d blocks, want 50", h.Len())
+	}
+	if view := viewText(h); !strings.Contains(view, "block 50") {
+		t.Fatalf("view after truncating:\n%s", view)
+	}
+
+	for range 100 {
+		h.scroll(-h.Height)
+	}
+	if view := viewText(h); !strings.HasPrefix(view, "block 0\nat 40\n.\n\nblock 2") {
+		t.Fatalf("view at the top:\n%s", view)
+	}
+	if h.total != 200 {
+		t.Fatalf("history has %d lines, want 200", h.total)
+	}
+}
+
+// TestHistoryRerenderAll adds a header to every block without moving the
+// view or the selection off the lines they were on
+func TestHistoryRerenderAll(t *testing.T) {
+	h := newHistoryView(40, 10)
+	header := false
+	h.BeginLoad()
+	for i := range 100 {
+		h.Append(func(int) string {
+			text := fmt.Sprintf("block %d\nline %d\n.", i, i)
+			if header {
+				return "header\n" + text
+			}
+			return text
+		})
+	}
+	h.EndLoad()
+
+	for range 5 {
+		h.scroll(-h.Height)
+	}
+	h.scroll(1)
+	top := h.visibleLines()[0]
+	selected := h.offset + 2
+	h.selection = selection{active: true, anchor: position{selected, 0}, cursor: position{selected + 1, 3}}
+	lineAt := func(n int) string { return h.blocks[h.blockAt(n)].lines[n-h.starts[h.blockAt(n)]] }
+	anchorText, cursorText := lineAt(selected), lineAt(selected+1)
+
+	header = true
+	h.RerenderAll()
+	if got := h.visibleLines()[0]; got != top {
+		t.Fatalf("top line is %q, was %q", got, top)
+	}
+	if !h.selection.active || lineAt(h.selection.anchor.line) != anchorText || lineAt(h.selection.cursor.line) != cursorText {
+		t.Fatalf("selection moved to %q-%q, was on %q-%q", lineAt(h.selection.anchor.line), lineAt(h.selection.cursor.line), anchorText, cursorText)
+	}
+
+	h.GotoBottom()
+	header = false
+	h.RerenderAll()
+	if !h.AtBottom() || !strings.Contains(viewText(&h), "block 99") {
+		t.Fatalf("view left the bottom:\n%s", viewText(&h))
+	}
+}
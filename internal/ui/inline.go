@@ -0,0 +1,66 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// WithInline draws the chat below the shell prompt instead of taking over
+// the screen. finished messages are printed to the terminal's scrollback,
+// where they stay after ask exits, and only the response being streamed,
+// the status and the input are redrawn
+func WithInline() Option {
+	return func(c *Chat) { c.inline = true }
+}
+
+// disableScrolling turns off the keys of the history view, inline there's
+// nothing to scroll and the terminal's own selection replaces copy mode
+func (k *keyMap) disableScrolling() {
+	for _, b := range []*key.Binding{&k.PageDown, &k.PageUp, &k.HalfPageDown, &k.HalfPageUp, &k.Up, &k.Down, &k.CopyMode, &k.Follow} {
+		b.SetEnabled(false)
+	}
+}
+
+// PrintHistory returns a command printing what was added to the history
+// since it was last called to the scrollback, nil unless the chat is inline.
+// the app calls it after every update
+func (c *Chat) PrintHistory() tea.Cmd {
+	h := &c.history
+	if !c.inline || !c.sized {
+		// wrapped for the window once its size is known
+		return nil
+	}
+	var blocks []string
+	for _, n := range h.reprint {
+		blocks = append(blocks, c.printedBlock(n))
+	}
+	for n := h.printed; n < len(h.blocks); n++ {
+		blocks = append(blocks, c.printedBlock(n))
+	}
+	h.printed, h.reprint = len(h.blocks), nil
+	if len(blocks) == 0 {
+		return nil
+	}
+	// a blank line after each, like in the history view
+	return tea.Println(strings.Join(blocks, "\n\n") + "\n")
+}
+
+func (c *Chat) printedBlock(n int) string {
+	return c.historyViewStyle.Render(c.history.blocks[n].render(c.history.Width))
+}
+
+// inlineView is View without the history, which is in the scrollback
+func (c *Chat) inlineView() string {
+	var parts []string
+	if len(c.history.live) > 0 {
+		parts = append(parts, c.historyViewStyle.Render(strings.Join(c.history.live, "\n")))
+	}
+	if popup := c.mentionView(); popup != "" {
+		parts = append(parts, popup)
+	}
+	parts = append(parts, c.statusView(), c.borderStyle.Render(c.input.View()), c.historyViewStyle.Render(c.helpView()))
+	return lipgloss.JoinVertical(lipgloss.Left, parts...)
+}
@@ -0,0 +1,304 @@
+package ui
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// the kitty keyboard protocol's progressive enhancement with only the
+// disambiguate flag, which is what tells shift+enter from enter. terminals
+// without the protocol ignore it and keep sending legacy keys
+const (
+	kittyPush = "\x1b[>1u"
+	kittyPop  = "\x1b[<u"
+)
+
+// KittyKeyboard asks the terminal for the kitty keyboard protocol and turns
+// the keys it reports back into the ones bubble tea knows. bubble tea
+// doesn't speak the protocol itself, so Input rewrites the sequences into
+// what a terminal without it sends before the program reads them. a nil
+// KittyKeyboard leaves the keyboard alone
+type KittyKeyboard struct {
+	out io.Writer
+
+	// shiftEnter is what shift+enter is reported as, see SetSendKey
+	shiftEnter tea.KeyType
+
+	mu     sync.Mutex
+	pushed bool
+}
+
+// NewKittyKeyboard returns a KittyKeyboard writing to the terminal at out,
+// pass its Input to the program with tea.WithInput and its Filter with
+// tea.WithFilter
+func NewKittyKeyboard(out io.Writer) *KittyKeyboard {
+	return &KittyKeyboard{out: out, shiftEnter: tea.KeyCtrlJ}
+}
+
+// SetSendKey tells the keyboard which key sends prompts, with
+// SendKeyCtrlEnter shift+enter has to stay a new line like enter instead
+// of becoming ctrl+j with ctrl+enter
+func (k *KittyKeyboard) SetSendKey(sendKey string) {
+	if k == nil {
+		return
+	}
+	k.shiftEnter = tea.KeyCtrlJ
+	if sendKey == SendKeyCtrlEnter {
+		k.shiftEnter = tea.KeyEnter
+	}
+}
+
+// Enable returns a command turning the protocol on. it's run from Init,
+// after the program switched to the alternate screen, which has its own
+// keyboard modes
+func (k *KittyKeyboard) Enable() tea.Cmd {
+	if k == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		k.set(true)
+		return nil
+	}
+}
+
+// Disable turns the protocol off again, it's called on quit
+func (k *KittyKeyboard) Disable() {
+	if k != nil {
+		k.set(false)
+	}
+}
+
+func (k *KittyKeyboard) set(on bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.pushed == on {
+		return
+	}
+	seq := kittyPop
+	if on {
+		seq = kittyPush
+	}
+	if _, err := io.WriteString(k.out, seq); err == nil {
+		k.pushed = on
+	}
+}
+
+// ExecProcess is tea.ExecProcess with the protocol off while cmd runs,
+// editors and pagers expect legacy keys
+func (k *KittyKeyboard) ExecProcess(cmd *exec.Cmd, fn tea.ExecCallback) tea.Cmd {
+	if k == nil {
+		return tea.ExecProcess(cmd, fn)
+	}
+	k.set(false)
+	return tea.ExecProcess(cmd, func(err error) tea.Msg {
+		// the program has the terminal back by now
+		k.set(true)
+		if fn == nil {
+			return nil
+		}
+		return fn(err)
+	})
+}
+
+// Filter turns the protocol off before the program quits
+func (k *KittyKeyboard) Filter(_ tea.Model, msg tea.Msg) tea.Msg {
+	switch msg.(type) {
+	case tea.QuitMsg, tea.InterruptMsg:
+		k.Disable()
+	}
+	return msg
+}
+
+// Input reads the keys from in with the protocol's sequences rewritten.
+// a terminal's file stays one, bubble tea only puts files in raw mode and
+// can only cancel reads from them
+func (k *KittyKeyboard) Input(in io.Reader) io.Reader {
+	if f, ok := in.(*os.File); ok {
+		return &kittyFile{File: f, input: &kittyInput{r: f, k: k}}
+	}
+	return &kittyInput{r: in, k: k}
+}
+
+// kittyFile is a terminal read through a kittyInput
+type kittyFile struct {
+	*os.File
+	input *kittyInput
+}
+
+func (f *kittyFile) Read(p []byte) (int, error) {
+	return f.input.Read(p)
+}
+
+// maxKittySeq bounds what's held back of a sequence cut off at the end of
+// a read, anything longer isn't a key
+const maxKittySeq = 32
+
+// kittyInput rewrites the protocol's keys in what's read from r
+type kittyInput struct {
+	r io.Reader
+	k *KittyKeyboard
+	// the start of a sequence the last read ended in
+	pending []byte
+}
+
+func (in *kittyInput) Read(p []byte) (int, error) {
+	if len(p) <= len(in.pending) {
+		n := copy(p, in.pending)
+		in.pending = in.pending[n:]
+		return n, nil
+	}
+	for {
+		// the keys only get shorter, what's read fits in p rewritten
+		buf := make([]byte, len(p))
+		n := copy(buf, in.pending)
+		m, err := in.r.Read(buf[n:])
+		out, rest := in.k.translate(buf[:n+m])
+		in.pending = append(in.pending[:0], rest...)
+		if err != nil {
+			out = append(out, in.pending...)
+			in.pending = nil
+		}
+		if len(out) > 0 || err != nil {
+			return copy(p, out), err
+		}
+	}
+}
+
+// translate replaces the protocol's keys in data with what a terminal
+// without it sends for them, other sequences are left alone. rest is the
+// start of a sequence data ends in, the next read has the remainder
+func (k *KittyKeyboard) translate(data []byte) (out, rest []byte) {
+	out = make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		if data[i] != 0x1b || i+1 >= len(data) || data[i+1] != '[' {
+			out = append(out, data[i])
+			i++
+			continue
+		}
+		j := i + 2
+		for j < len(data) && (data[j] >= '0' && data[j] <= '9' || data[j] == ';' || data[j] == ':') {
+			j++
+		}
+		if j == len(data) {
+			if j > i+2 && j-i <= maxKittySeq {
+				return out, data[i:]
+			}
+			return append(out, data[i:]...), nil
+		}
+		if data[j] == 'u' {
+			if key, ok := parseKittyKey(data[i:j+1], k.shiftEnter); ok {
+				out = append(out, legacyKey(key)...)
+				i = j + 1
+				continue
+			}
+		}
+		out = append(out, data[i])
+		i++
+	}
+	return out, nil
+}
+
+// legacyKey is what a terminal without the protocol sends for key. alt is
+// an escape in front, enter, tab, backspace and the ctrl keys are the
+// control characters their key types are numbered after
+func legacyKey(key tea.KeyMsg) []byte {
+	var b []byte
+	if key.Alt {
+		b = append(b, 0x1b)
+	}
+	switch key.Type {
+	case tea.KeyRunes:
+		return append(b, string(key.Runes)...)
+	case tea.KeySpace:
+		return append(b, ' ')
+	case tea.KeyShiftTab:
+		return append(b, "\x1b[Z"...)
+	}
+	return append(b, byte(key.Type))
+}
+
+// modifier bits of a kitty key, one less than the number sent
+const (
+	kittyShift = 1
+	kittyAlt   = 2
+	kittyCtrl  = 4
+)
+
+// parseKittyKey turns CSI code;modifiers u into the key bubble tea would
+// have reported for it without the protocol. ctrl+enter becomes ctrl+j, the
+// key the input already inserts a new line on, and shift+enter shiftEnter
+func parseKittyKey(seq []byte, shiftEnter tea.KeyType) (tea.KeyMsg, bool) {
+	s, ok := strings.CutPrefix(string(seq), "\x1b[")
+	if !ok {
+		return tea.KeyMsg{}, false
+	}
+	s, ok = strings.CutSuffix(s, "u")
+	if !ok {
+		return tea.KeyMsg{}, false
+	}
+	codeField, modField, _ := strings.Cut(s, ";")
+	// alternate keys after a colon only come with flags not asked for
+	codeField, _, _ = strings.Cut(codeField, ":")
+	code, err := strconv.Atoi(codeField)
+	if err != nil {
+		return tea.KeyMsg{}, false
+	}
+	mods := 0
+	if modField != "" {
+		modField, _, _ = strings.Cut(modField, ":")
+		n, err := strconv.Atoi(modField)
+		if err != nil || n < 1 {
+			return tea.KeyMsg{}, false
+		}
+		// caps and num lock don't change what the key means here
+		mods = (n - 1) &^ (64 | 128)
+	}
+	alt := mods&kittyAlt != 0
+	shift := mods&kittyShift != 0
+	ctrl := mods&kittyCtrl != 0
+
+	switch code {
+	case 13:
+		switch {
+		case ctrl:
+			return tea.KeyMsg{Type: tea.KeyCtrlJ, Alt: alt}, true
+		case shift:
+			return tea.KeyMsg{Type: shiftEnter, Alt: alt}, true
+		}
+		return tea.KeyMsg{Type: tea.KeyEnter, Alt: alt}, true
+	case 27:
+		return tea.KeyMsg{Type: tea.KeyEsc, Alt: alt}, true
+	case 9:
+		if shift {
+			return tea.KeyMsg{Type: tea.KeyShiftTab, Alt: alt}, true
+		}
+		return tea.KeyMsg{Type: tea.KeyTab, Alt: alt}, true
+	case 127:
+		return tea.KeyMsg{Type: tea.KeyBackspace, Alt: alt}, true
+	case 32:
+		return tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}, Alt: alt}, true
+	}
+
+	r := rune(code)
+	switch {
+	case ctrl && r >= 'a' && r <= 'z':
+		return tea.KeyMsg{Type: tea.KeyCtrlA + tea.KeyType(r-'a'), Alt: alt}, true
+	case ctrl:
+		// nothing legacy to map other ctrl combinations to
+		return tea.KeyMsg{}, false
+	case unicode.IsPrint(r) && code < 57344:
+		// below the private use area kitty reports function keys in
+		if shift {
+			r = unicode.ToUpper(r)
+		}
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}, Alt: alt}, true
+	}
+	return tea.KeyMsg{}, false
+}
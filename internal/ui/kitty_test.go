@@ -0,0 +1,155 @@
+package ui
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestParseKittyKey(t *testing.T) {
+	tests := map[string]string{
+		"\x1b[13;2u":  "ctrl+j",
+		"\x1b[13;5u":  "ctrl+j",
+		"\x1b[13u":    "enter",
+		"\x1b[13;3u":  "alt+enter",
+		"\x1b[99;5u":  "ctrl+c",
+		"\x1b[99;69u": "ctrl+c", // with caps lock
+		"\x1b[27u":    "esc",
+		"\x1b[97;3u":  "alt+a",
+		"\x1b[97;4u":  "alt+A",
+		"\x1b[9;2u":   "shift+tab",
+		"\x1b[127;3u": "alt+backspace",
+		"\x1b[57399u": "",
+		"\x1b[49;5u":  "",
+		"\x1b[1;5A":   "",
+	}
+	for seq, want := range tests {
+		key, ok := parseKittyKey([]byte(seq), tea.KeyCtrlJ)
+		got := ""
+		if ok {
+			got = key.String()
+		}
+		if got != want {
+			t.Errorf("parseKittyKey(%q) = %q, want %q", seq, got, want)
+		}
+	}
+
+	// sending with ctrl+enter, shift+enter stays a new line
+	if key, _ := parseKittyKey([]byte("\x1b[13;2u"), tea.KeyEnter); key.String() != "enter" {
+		t.Errorf("shift+enter = %q, want enter", key.String())
+	}
+}
+
+type lockedBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+// keyRecorder keeps the keys it gets and quits on ctrl+c
+type keyRecorder struct {
+	keyboard *KittyKeyboard
+	keys     []string
+}
+
+func (m *keyRecorder) Init() tea.Cmd { return m.keyboard.Enable() }
+func (m *keyRecorder) View() string  { return "" }
+
+func (m *keyRecorder) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if key, ok := msg.(tea.KeyMsg); ok {
+		m.keys = append(m.keys, key.String())
+		if key.Type == tea.KeyCtrlC {
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+// TestKittyKeyboardProgram sends protocol keys through a real program's
+// input, they arrive as the keys the chat knows
+func TestKittyKeyboardProgram(t *testing.T) {
+	terminal := &lockedBuffer{}
+	kb := NewKittyKeyboard(terminal)
+	m := &keyRecorder{keyboard: kb}
+	// the program quits itself on ctrl+c
+	input := strings.NewReader("\x1b[13;2u" + "a" + "\x1b[99;5u")
+	p := tea.NewProgram(m, tea.WithInput(kb.Input(&slowReader{input})), tea.WithOutput(&lockedBuffer{}), tea.WithoutSignals(), tea.WithFilter(kb.Filter))
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Run()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		p.Kill()
+		t.Fatal("the program didn't quit on ctrl+c")
+	}
+
+	if got := strings.Join(m.keys, " "); got != "ctrl+j a ctrl+c" {
+		t.Errorf("keys are %q", got)
+	}
+	if got := terminal.String(); got != kittyPush+kittyPop {
+		t.Errorf("wrote %q to the terminal, want the protocol pushed and popped", got)
+	}
+}
+
+// slowReader pauses before every read, like keys typed after the program
+// started
+type slowReader struct {
+	r *strings.Reader
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(20 * time.Millisecond)
+	return s.r.Read(p)
+}
+
+// TestKittyInput rewrites the protocol's keys, also when a read ends in
+// the middle of one, and leaves other input alone
+func TestKittyInput(t *testing.T) {
+	kb := NewKittyKeyboard(io.Discard)
+	reads := []string{"a\x1b[13;2u\x1b[1", "3u\x1b[A\x1b[97;3u", "\x1b[99;5u\x1b[57399u", "\x1b[9;2u\x1b["}
+	in := kb.Input(&chunkReader{chunks: reads})
+	got, err := io.ReadAll(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the private use key isn't one bubble tea knows, it's passed on
+	if want := "a\n\r\x1b[A\x1ba\x03\x1b[57399u\x1b[Z\x1b["; string(got) != want {
+		t.Fatalf("read %q, want %q", got, want)
+	}
+}
+
+// chunkReader returns one chunk per read
+type chunkReader struct {
+	chunks []string
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if len(c.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, c.chunks[0])
+	c.chunks = c.chunks[1:]
+	return n, nil
+}
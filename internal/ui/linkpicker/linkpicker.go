@@ -0,0 +1,121 @@
+package linkpicker
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// Model is the list of links in the last response, shown by /open and
+// /copylink
+type Model struct {
+	list list.Model
+	// copyOnly picks links for the clipboard whichever key picks them
+	copyOnly bool
+}
+
+// Item is a link in the list, numbered like /links numbers it
+type Item struct {
+	N   int
+	URL string
+}
+
+// SelectedMsg is emitted when a link is picked, Copy when it should go to
+// the clipboard instead of the browser
+type SelectedMsg struct {
+	URL  string
+	Copy bool
+}
+
+type PickerCancelledMsg struct{}
+
+func (i Item) FilterValue() string {
+	return i.URL
+}
+
+type itemDelegate struct{}
+
+func (d itemDelegate) Height() int                               { return 1 }
+func (d itemDelegate) Spacing() int                              { return 0 }
+func (d itemDelegate) Update(msg tea.Msg, m *list.Model) tea.Cmd { return nil }
+
+func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	i, ok := listItem.(Item)
+	if !ok {
+		return
+	}
+
+	line := fmt.Sprintf("[%d] %s", i.N, i.URL)
+	style := lipgloss.NewStyle().PaddingLeft(4)
+	if index == m.Index() {
+		style = lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color("#7D56F4"))
+		line = "> " + line
+	}
+	fmt.Fprint(w, style.MaxWidth(m.Width()).Render(line))
+}
+
+// New creates a picker for urls, sized for a width x height window. with
+// copyOnly the picked link is copied rather than opened
+func New(urls []string, width, height int, copyOnly bool) *Model {
+	items := make([]list.Item, len(urls))
+	for i, u := range urls {
+		items[i] = Item{N: i + 1, URL: u}
+	}
+
+	l := list.New(items, itemDelegate{}, width, height)
+	l.Title = i18n.T("Open a link (y copies it)")
+	if copyOnly {
+		l.Title = i18n.T("Copy a link")
+	}
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(true)
+	l.Styles.Title = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FFFDF5")).
+		Background(lipgloss.Color("#7D56F4")).
+		Padding(0, 1)
+	l.Styles.PaginationStyle = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
+	l.Styles.HelpStyle = list.DefaultStyles().HelpStyle.PaddingLeft(4).PaddingBottom(1)
+	i18n.List(&l)
+
+	return &Model{list: l, copyOnly: copyOnly}
+}
+
+func (m *Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.list.SetSize(msg.Width, msg.Height)
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+		switch msg.String() {
+		case "q", "esc":
+			if m.list.FilterState() == list.Unfiltered {
+				return m, func() tea.Msg { return PickerCancelledMsg{} }
+			}
+		case "enter", "y":
+			if selected, ok := m.list.SelectedItem().(Item); ok {
+				copy := m.copyOnly || msg.String() == "y"
+				return m, func() tea.Msg { return SelectedMsg{URL: selected.URL, Copy: copy} }
+			}
+		}
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m *Model) View() string {
+	return "\n" + m.list.View()
+}
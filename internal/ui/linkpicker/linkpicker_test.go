@@ -0,0 +1,36 @@
+package linkpicker
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// TestSelect opens the link picked with enter and copies the one picked
+// with y, or any link when the picker was opened by /copylink
+func TestSelect(t *testing.T) {
+	urls := []string{"https://go.dev/doc", "https://pkg.go.dev"}
+	tests := []struct {
+		name     string
+		copyOnly bool
+		key      tea.KeyMsg
+		copy     bool
+	}{
+		{"open", false, tea.KeyMsg{Type: tea.KeyEnter}, false},
+		{"copy with y", false, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}}, true},
+		{"copylink", true, tea.KeyMsg{Type: tea.KeyEnter}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(urls, 80, 20, tt.copyOnly)
+			_, cmd := m.Update(tt.key)
+			if cmd == nil {
+				t.Fatal("nothing picked")
+			}
+			msg, ok := cmd().(SelectedMsg)
+			if !ok || msg.URL != urls[0] || msg.Copy != tt.copy {
+				t.Fatalf("picked %+v, want %s with copy %v", msg, urls[0], tt.copy)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package ui
+
+import (
+	"hash/fnv"
+	"strings"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/scbenet/ask/internal/links"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// markdownKey identifies a message rendered in a style at a width
+type markdownKey struct {
+	hash  uint64
+	width int
+}
+
+func newMarkdownKey(style, content string, width int) markdownKey {
+	h := fnv.New64a()
+	h.Write([]byte(style))
+	h.Write([]byte{0})
+	h.Write([]byte(content))
+	return markdownKey{hash: h.Sum64(), width: width}
+}
+
+// MarkdownRenderer renders markdown wrapped at width
+type MarkdownRenderer func(content string, width int) string
+
+// rendererKey identifies a glamour renderer
+type rendererKey struct {
+	style string
+	width int
+}
+
+// appendStyled adds text to the history in style, wrapped to the history width
+func (c *Chat) appendStyled(style lipgloss.Style, text string) {
+	c.history.Append(func(width int) string {
+		return style.Width(max(width, 80)).Render(text)
+	})
+}
+
+// appendMarkdown adds a response to the history, rendered as markdown
+func (c *Chat) appendMarkdown(content string, meta messageMeta) {
+	c.lastResponseBlock = c.history.Len()
+	c.history.Append(func(width int) string {
+		return c.withHeader(c.assistantMessages.Label, meta, c.renderMarkdown(content, width))
+	})
+}
+
+// renderer returns the glamour renderer for style wrapping at width, nil if
+// it can't be created. renderers are kept around, resizing back and forth
+// reuses them
+func (c *Chat) renderer(style string, width int) *glamour.TermRenderer {
+	key := rendererKey{style, width}
+	if r, ok := c.renderers[key]; ok {
+		return r
+	}
+	// a standard style's name or a JSON style file
+	r, err := glamour.NewTermRenderer(
+		glamour.WithStylePath(style),
+		glamour.WithWordWrap(width),
+	)
+	if err != nil {
+		logging.Errorf("creating glamour renderer for style %s and width %d: %v. markdown rendering will fall back to plain text", style, width, err)
+		r = nil
+	}
+	c.renderers[key] = r
+	return r
+}
+
+// renderMarkdown renders a response for the given content width, cached so
+// the history can be re-wrapped cheaply
+func (c *Chat) renderMarkdown(content string, width int) string {
+	return c.renderMarkdownStyle(c.markdownStyle, content, width)
+}
+
+// RenderMarkdown renders a response the way the chat does, for views that
+// show responses next to each other like compare and fan-out. it shares the
+// chat's renderers and cache
+func (c *Chat) RenderMarkdown(content string, width int) string {
+	return c.renderMarkdown(content, width)
+}
+
+// renderMarkdownStyle is renderMarkdown in another glamour style
+func (c *Chat) renderMarkdownStyle(style, content string, width int) string {
+	key := newMarkdownKey(style, content, width)
+	if rendered, ok := c.markdownCache[key]; ok {
+		return rendered
+	}
+
+	renderer := c.renderer(style, width)
+	if renderer == nil {
+		return c.assistantStyle.Width(max(width, 80)).Render(content)
+	}
+
+	prepared := renderMath(ReflowWideTables(content, width), c.math)
+	renderedMarkdown, err := renderer.Render(prepared)
+	if err != nil {
+		logging.Errorf("rendering markdown with glamour: %v", err)
+		return c.assistantStyle.Width(max(width, 80)).Render(content)
+	}
+	renderedMarkdown = strings.TrimSuffix(renderedMarkdown, "\n")
+	if c.hyperlinks {
+		renderedMarkdown = links.Hyperlink(renderedMarkdown)
+	}
+	c.markdownCache[key] = renderedMarkdown
+	return renderedMarkdown
+}
+
+// pruneMarkdownCache drops renders and renderers for widths other than the
+// ones given, resizing a window passes through lots of widths
+func (c *Chat) pruneMarkdownCache(keep ...int) {
+	kept := func(width int) bool {
+		for _, w := range keep {
+			if w == width {
+				return true
+			}
+		}
+		return false
+	}
+	for key := range c.markdownCache {
+		if !kept(key.width) {
+			delete(c.markdownCache, key)
+		}
+	}
+	for key := range c.renderers {
+		if !kept(key.width) {
+			delete(c.renderers, key)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package ui
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// best effort LaTeX to unicode conversion. this is nowhere near a real TeX
+// renderer, it just makes the common cases (greek letters, operators, simple
+// fractions, sub/superscripts) readable in a terminal
+
+var latexSymbols = map[string]string{
+	// greek
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ε", "varepsilon": "ε",
+	"zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ",
+	"lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π", "rho": "ρ", "sigma": "σ",
+	"tau": "τ", "upsilon": "υ", "phi": "φ", "varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π",
+	"Sigma": "Σ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	// operators and relations
+	"sum": "∑", "prod": "∏", "int": "∫", "iint": "∬", "oint": "∮", "infty": "∞",
+	"pm": "±", "mp": "∓", "times": "×", "div": "÷", "cdot": "·", "ast": "∗",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠", "approx": "≈",
+	"equiv": "≡", "sim": "∼", "simeq": "≃", "propto": "∝", "ll": "≪", "gg": "≫",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "Rightarrow": "⇒", "Leftarrow": "⇐",
+	"leftrightarrow": "↔", "Leftrightarrow": "⇔", "iff": "⇔", "implies": "⇒", "mapsto": "↦",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "subseteq": "⊆", "supset": "⊃",
+	"supseteq": "⊇", "cup": "∪", "cap": "∩", "setminus": "∖", "emptyset": "∅", "varnothing": "∅",
+	"forall": "∀", "exists": "∃", "neg": "¬", "land": "∧", "wedge": "∧", "lor": "∨", "vee": "∨",
+	"partial": "∂", "nabla": "∇", "circ": "∘", "bullet": "•", "oplus": "⊕", "otimes": "⊗",
+	"perp": "⊥", "parallel": "∥", "angle": "∠", "degree": "°", "prime": "′",
+	"ldots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱", "dots": "…",
+	"langle": "⟨", "rangle": "⟩", "lceil": "⌈", "rceil": "⌉", "lfloor": "⌊", "rfloor": "⌋",
+	"hbar": "ℏ", "ell": "ℓ", "Re": "ℜ", "Im": "ℑ", "aleph": "ℵ",
+	"log": "log", "ln": "ln", "exp": "exp", "sin": "sin", "cos": "cos", "tan": "tan",
+	"lim": "lim", "max": "max", "min": "min", "det": "det",
+	// spacing
+	"quad": "  ", "qquad": "    ", ",": " ", ";": " ", ":": " ", "!": "", " ": " ",
+	// escaped literals
+	"{": "{", "}": "}", "%": "%", "$": "$", "&": "&", "_": "_", "#": "#", "\\": "\n",
+}
+
+var blackboard = map[string]string{
+	"R": "ℝ", "N": "ℕ", "Z": "ℤ", "Q": "ℚ", "C": "ℂ", "P": "ℙ", "E": "𝔼",
+}
+
+var superscripts = map[rune]rune{
+	'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴', '5': '⁵', '6': '⁶', '7': '⁷', '8': '⁸', '9': '⁹',
+	'+': '⁺', '-': '⁻', '=': '⁼', '(': '⁽', ')': '⁾', 'n': 'ⁿ', 'i': 'ⁱ', 'T': 'ᵀ',
+	'a': 'ᵃ', 'b': 'ᵇ', 'c': 'ᶜ', 'd': 'ᵈ', 'e': 'ᵉ', 'k': 'ᵏ', 'm': 'ᵐ', 'x': 'ˣ', 'y': 'ʸ', '′': '′',
+}
+
+var subscripts = map[rune]rune{
+	'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄', '5': '₅', '6': '₆', '7': '₇', '8': '₈', '9': '₉',
+	'+': '₊', '-': '₋', '=': '₌', '(': '₍', ')': '₎', 'a': 'ₐ', 'e': 'ₑ', 'i': 'ᵢ', 'j': 'ⱼ',
+	'k': 'ₖ', 'n': 'ₙ', 'm': 'ₘ', 'o': 'ₒ', 'x': 'ₓ', 't': 'ₜ', 'p': 'ₚ', 's': 'ₛ', 'r': 'ᵣ',
+}
+
+// commands whose argument is rendered as plain text
+var textCommands = map[string]bool{
+	"text": true, "mathrm": true, "mathit": true, "mathbf": true, "textbf": true,
+	"mathsf": true, "mathtt": true, "operatorname": true, "boldsymbol": true, "mathcal": true,
+}
+
+// commands that are dropped entirely (sizing and delimiter hints)
+var ignoredCommands = map[string]bool{
+	"left": true, "right": true, "big": true, "Big": true, "bigg": true, "Bigg": true,
+	"displaystyle": true, "limits": true, "nolimits": true,
+}
+
+type latexConverter struct {
+	src []rune
+	pos int
+}
+
+// latexToUnicode converts a LaTeX math expression to an approximate unicode rendering
+func latexToUnicode(src string) string {
+	c := &latexConverter{src: []rune(src)}
+	return strings.TrimSpace(c.convert(len(c.src)))
+}
+
+func (c *latexConverter) convert(end int) string {
+	var b strings.Builder
+	for c.pos < end {
+		r := c.src[c.pos]
+		switch r {
+		case '\\':
+			b.WriteString(c.command())
+		case '^':
+			c.pos++
+			b.WriteString(script(c.argument(), superscripts, "^"))
+		case '_':
+			c.pos++
+			b.WriteString(script(c.argument(), subscripts, "_"))
+		case '{':
+			b.WriteString(c.argument())
+		case '}', '&':
+			c.pos++
+		default:
+			b.WriteRune(r)
+			c.pos++
+		}
+	}
+	return b.String()
+}
+
+// command consumes a \command (and its arguments) and returns its rendering
+func (c *latexConverter) command() string {
+	c.pos++ // skip backslash
+	if c.pos >= len(c.src) {
+		return "\\"
+	}
+
+	start := c.pos
+	for c.pos < len(c.src) && unicode.IsLetter(c.src[c.pos]) {
+		c.pos++
+	}
+	if c.pos == start {
+		c.pos++ // single symbol command like \{ or \,
+	}
+	name := string(c.src[start:c.pos])
+
+	switch {
+	case name == "frac" || name == "dfrac" || name == "tfrac":
+		num, den := c.argument(), c.argument()
+		return wrapTerm(num) + "/" + wrapTerm(den)
+	case name == "sqrt":
+		return "√" + wrapTerm(c.argument())
+	case name == "mathbb":
+		arg := c.argument()
+		if s, ok := blackboard[arg]; ok {
+			return s
+		}
+		return arg
+	case name == "vec":
+		return c.argument() + "\u20d7"
+	case name == "hat":
+		return c.argument() + "\u0302"
+	case name == "bar" || name == "overline":
+		return c.argument() + "\u0305"
+	case name == "dot":
+		return c.argument() + "\u0307"
+	case name == "begin" || name == "end":
+		c.argument() // environment name, e.g. aligned
+		return ""
+	case textCommands[name]:
+		return c.argument()
+	case ignoredCommands[name]:
+		return ""
+	}
+
+	if s, ok := latexSymbols[name]; ok {
+		return s
+	}
+	return "\\" + name
+}
+
+// argument reads a {group} or a single token and returns it converted
+func (c *latexConverter) argument() string {
+	for c.pos < len(c.src) && c.src[c.pos] == ' ' {
+		c.pos++
+	}
+	if c.pos >= len(c.src) {
+		return ""
+	}
+
+	switch c.src[c.pos] {
+	case '{':
+		depth := 0
+		for end := c.pos; end < len(c.src); end++ {
+			switch c.src[end] {
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+			if depth == 0 {
+				c.pos++ // skip opening brace
+				inner := c.convert(end)
+				c.pos = end + 1
+				return inner
+			}
+		}
+		// unbalanced, treat the rest as the group
+		c.pos++
+		return c.convert(len(c.src))
+	case '\\':
+		return c.command()
+	default:
+		r := c.src[c.pos]
+		c.pos++
+		return string(r)
+	}
+}
+
+// script maps s into super/subscript characters, falling back to marker(s)
+// when some character has no unicode equivalent
+func script(s string, table map[rune]rune, marker string) string {
+	var b strings.Builder
+	for _, r := range s {
+		mapped, ok := table[r]
+		if !ok {
+			if len([]rune(s)) == 1 {
+				return marker + s
+			}
+			return marker + "(" + s + ")"
+		}
+		b.WriteRune(mapped)
+	}
+	return b.String()
+}
+
+var simpleTermPattern = regexp.MustCompile(`^[\p{L}\p{N}.]+$`)
+
+// wrapTerm parenthesizes compound terms so a/b stays unambiguous
+func wrapTerm(s string) string {
+	if len([]rune(s)) <= 1 || simpleTermPattern.MatchString(s) {
+		return s
+	}
+	return "(" + s + ")"
+}
+
+var displayMathPattern = regexp.MustCompile(`(?s)\$\$(.+?)\$\$|\\\[(.+?)\\\]`)
+
+// math modes, see WithMath
+const (
+	MathUnicode = "unicode" // approximated with unicode characters
+	MathLaTeX   = "latex"   // the LaTeX source, set apart as code
+	MathOff     = "off"     // left alone
+)
+
+// renderMath rewrites the LaTeX math of a response for the terminal: display
+// math becomes a block of its own and inline $...$ or \(...\) math is
+// converted in place
+func renderMath(md, mode string) string {
+	if mode == MathOff {
+		return md
+	}
+	return renderInlineMath(renderDisplayMath(md, mode), mode)
+}
+
+// renderDisplayMath replaces $$...$$ and \[...\] blocks outside of code
+// fences with a literal block containing the expression
+func renderDisplayMath(md, mode string) string {
+	if !strings.Contains(md, "$$") && !strings.Contains(md, `\[`) {
+		return md
+	}
+
+	return replaceOutsideFences(md, func(text string) string {
+		return displayMathPattern.ReplaceAllStringFunc(text, func(match string) string {
+			groups := displayMathPattern.FindStringSubmatch(match)
+			expr := groups[1]
+			if expr == "" {
+				expr = groups[2]
+			}
+			if mode == MathLaTeX {
+				return "\n```latex\n" + strings.TrimSpace(expr) + "\n```\n"
+			}
+			return "\n```math\n" + latexToUnicode(expr) + "\n```\n"
+		})
+	})
+}
+
+// renderInlineMath converts the inline math outside of code fences and
+// code spans, one line at a time since inline math doesn't span lines
+func renderInlineMath(md, mode string) string {
+	if !strings.Contains(md, "$") && !strings.Contains(md, `\(`) {
+		return md
+	}
+	convert := func(expr string) string {
+		if mode == MathLaTeX {
+			return "`" + expr + "`"
+		}
+		return escapeMarkdown(latexToUnicode(expr))
+	}
+	return replaceOutsideFences(md, func(text string) string {
+		lines := strings.SplitAfter(text, "\n")
+		for i, line := range lines {
+			lines[i] = replaceInlineMath(line, convert)
+		}
+		return strings.Join(lines, "")
+	})
+}
+
+// replaceInlineMath replaces the math in a line with convert(expr). a $
+// only opens math followed by a non-space and closes it after a non-space
+// when no digit follows, the way pandoc tells math from prices: "$5 and $10"
+// stays as is
+func replaceInlineMath(line string, convert func(string) string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		switch {
+		case line[i] == '`':
+			// code spans are copied as is, up to the same run of backticks
+			run := i
+			for run < len(line) && line[run] == '`' {
+				run++
+			}
+			fence := line[i:run]
+			end := strings.Index(line[run:], fence)
+			if end < 0 {
+				b.WriteString(line[i:])
+				return b.String()
+			}
+			b.WriteString(line[i : run+end+len(fence)])
+			i = run + end + len(fence)
+		case line[i] == '\\' && strings.HasPrefix(line[i:], `\(`):
+			end := strings.Index(line[i+2:], `\)`)
+			if end < 0 {
+				b.WriteString(line[i:])
+				return b.String()
+			}
+			b.WriteString(convert(line[i+2 : i+2+end]))
+			i += 2 + end + 2
+		case line[i] == '\\' && i+1 < len(line):
+			// an escaped character, e.g. \$
+			b.WriteString(line[i : i+2])
+			i += 2
+		case line[i] == '$' && opensMath(line, i):
+			end := closingDollar(line, i+1)
+			if end < 0 {
+				b.WriteByte('$')
+				i++
+				continue
+			}
+			b.WriteString(convert(line[i+1 : end]))
+			i = end + 1
+		default:
+			b.WriteByte(line[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+func opensMath(line string, i int) bool {
+	return i+1 < len(line) && line[i+1] != ' ' && line[i+1] != '\t' && line[i+1] != '\n' && line[i+1] != '$'
+}
+
+// closingDollar returns the index of the $ closing math opened before from,
+// -1 if there's none
+func closingDollar(line string, from int) int {
+	for j := from + 1; j < len(line); j++ {
+		switch {
+		case line[j] == '\\':
+			j++
+		case line[j] == '$':
+			before := line[j-1]
+			if before == ' ' || before == '\t' {
+				continue
+			}
+			if j+1 < len(line) && line[j+1] >= '0' && line[j+1] <= '9' {
+				continue
+			}
+			return j
+		}
+	}
+	return -1
+}
+
+// escapeMarkdown keeps the characters markdown would act on from doing so
+func escapeMarkdown(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("\\`*_[]<>", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// replaceOutsideFences applies fn to every span of md that isn't inside a
+// fenced code block
+func replaceOutsideFences(md string, fn func(string) string) string {
+	lines := strings.SplitAfter(md, "\n")
+	var out, span strings.Builder
+	inFence := false
+
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		isFence := strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+		if isFence && !inFence {
+			out.WriteString(fn(span.String()))
+			span.Reset()
+			inFence = true
+			out.WriteString(line)
+			continue
+		}
+		if inFence {
+			if isFence {
+				inFence = false
+			}
+			out.WriteString(line)
+			continue
+		}
+		span.WriteString(line)
+	}
+	out.WriteString(fn(span.String()))
+	return out.String()
+}
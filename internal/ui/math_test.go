@@ -0,0 +1,24 @@
+package ui
+
+import "testing"
+
+func TestRenderMath(t *testing.T) {
+	for _, tt := range []struct {
+		mode, in, want string
+	}{
+		{MathUnicode, `where $\alpha \leq \beta$ holds`, `where α ≤ β holds`},
+		{MathUnicode, `so \(x^2 + y_1\) is`, `so x² + y₁ is`},
+		{MathUnicode, `it costs $5 and $10`, `it costs $5 and $10`},
+		{MathUnicode, "keep `$x$` and\n```\n$y$\n```\n", "keep `$x$` and\n```\n$y$\n```\n"},
+		{MathUnicode, `escaped \$x$ stays`, `escaped \$x$ stays`},
+		{MathUnicode, `$a_{long}$`, `a\_(long)`},
+		{MathUnicode, "$$\\frac{a}{b}$$", "\n```math\na/b\n```\n"},
+		{MathLaTeX, `where $\alpha$ holds`, "where `\\alpha` holds"},
+		{MathLaTeX, "$$ \\sum_i x_i $$", "\n```latex\n\\sum_i x_i\n```\n"},
+		{MathOff, `where $\alpha$ holds`, `where $\alpha$ holds`},
+	} {
+		if got := renderMath(tt.in, tt.mode); got != tt.want {
+			t.Errorf("%s %q: got %q, want %q", tt.mode, tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package memoryview
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/memory"
+)
+
+// Model lists the saved memories, e edits the highlighted one and d deletes it.
+// changes are sent to the app as messages, it owns the store
+type Model struct {
+	list    list.Model
+	editing string // id of the memory being edited, "" when not editing
+	input   textinput.Model
+	width   int
+}
+
+type item struct {
+	memory.Memory
+}
+
+func (i item) FilterValue() string { return i.Text }
+
+// DeleteMsg asks for a memory to be forgotten
+type DeleteMsg struct {
+	ID string
+}
+
+// EditMsg asks for a memory's text to be replaced
+type EditMsg struct {
+	ID   string
+	Text string
+}
+
+// ClosedMsg is sent when the view is closed
+type ClosedMsg struct{}
+
+var (
+	editKey = key.NewBinding(
+		key.WithKeys("e"),
+		key.WithHelp("e", "edit"),
+	)
+	deleteKey = key.NewBinding(
+		key.WithKeys("d"),
+		key.WithHelp("d", "delete"),
+	)
+)
+
+type itemDelegate struct{}
+
+func (d itemDelegate) Height() int                               { return 1 }
+func (d itemDelegate) Spacing() int                              { return 0 }
+func (d itemDelegate) Update(msg tea.Msg, m *list.Model) tea.Cmd { return nil }
+
+func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	i, ok := listItem.(item)
+	if !ok {
+		return
+	}
+
+	source := ""
+	if i.Source == "model" {
+		source = i18n.T(" (saved by the model)")
+	}
+	str := fmt.Sprintf("%d. %s%s", index+1, i.Text, source)
+	if maxWidth := m.Width() - 6; maxWidth > 1 && lipgloss.Width(str) > maxWidth {
+		r := []rune(str)
+		for len(r) > 0 && lipgloss.Width(string(r)) > maxWidth-1 {
+			r = r[:len(r)-1]
+		}
+		str = string(r) + "…"
+	}
+
+	if index == m.Index() {
+		fmt.Fprint(w, lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color("#7D56F4")).Render("> "+str))
+		return
+	}
+	fmt.Fprint(w, lipgloss.NewStyle().PaddingLeft(4).Render(str))
+}
+
+func New() *Model {
+	l := list.New(nil, itemDelegate{}, 40, 14)
+	l.Title = i18n.T("Memories")
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(true)
+	l.SetStatusBarItemName("memory", "memories")
+	l.Styles.Title = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FFFDF5")).
+		Background(lipgloss.Color("#7D56F4")).
+		Padding(0, 1)
+	l.Styles.PaginationStyle = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
+	l.Styles.HelpStyle = list.DefaultStyles().HelpStyle.PaddingLeft(4).PaddingBottom(1)
+	l.AdditionalShortHelpKeys = func() []key.Binding { return i18n.Keys(editKey, deleteKey) }
+	i18n.List(&l)
+
+	input := textinput.New()
+	input.Prompt = "  " + i18n.T("edit: ")
+	input.CharLimit = 500
+
+	return &Model{list: l, input: input}
+}
+
+// SetMemories replaces the listed memories, keeping the selection in place
+func (m *Model) SetMemories(memories []memory.Memory) {
+	items := make([]list.Item, len(memories))
+	for i, mem := range memories {
+		items[i] = item{mem}
+	}
+	index := m.list.Index()
+	m.list.SetItems(items)
+	if index >= len(items) {
+		index = len(items) - 1
+	}
+	m.list.Select(max(index, 0))
+}
+
+func (m *Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.list.SetSize(msg.Width, msg.Height-2)
+		m.input.Width = msg.Width - 12
+		return m, nil
+
+	case tea.KeyMsg:
+		if m.editing != "" {
+			return m, m.updateEdit(msg)
+		}
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+		selected, haveSelection := m.list.SelectedItem().(item)
+		switch {
+		case msg.String() == "esc" || msg.String() == "q":
+			if m.list.FilterState() == list.Unfiltered {
+				return m, func() tea.Msg { return ClosedMsg{} }
+			}
+		case key.Matches(msg, editKey) && haveSelection:
+			m.editing = selected.ID
+			m.input.SetValue(selected.Text)
+			m.input.CursorEnd()
+			return m, m.input.Focus()
+		case key.Matches(msg, deleteKey) && haveSelection:
+			return m, func() tea.Msg { return DeleteMsg{ID: selected.ID} }
+		}
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m *Model) updateEdit(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		m.editing = ""
+		m.input.Blur()
+		return nil
+	case "enter":
+		edit := EditMsg{ID: m.editing, Text: m.input.Value()}
+		m.editing = ""
+		m.input.Blur()
+		return func() tea.Msg { return edit }
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return cmd
+}
+
+func (m *Model) View() string {
+	view := "\n" + m.list.View()
+	if m.editing != "" {
+		view += "\n" + m.input.View() + "\n" + lipgloss.NewStyle().PaddingLeft(4).Faint(true).Render(i18n.T("enter to save, esc to cancel"))
+	}
+	return view
+}
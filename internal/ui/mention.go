@@ -0,0 +1,181 @@
+package ui
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// FileMentionedMsg is emitted when a file is picked from the paths
+// completed after @ in the input, the app attaches it to the next prompt
+type FileMentionedMsg struct{ Path string }
+
+// maxMentions is how many completions are shown at once
+const maxMentions = 8
+
+// mentionFilesTTL is how long the listed files are completed from before
+// they're listed again, in the background while the old list is shown
+const mentionFilesTTL = 30 * time.Second
+
+var (
+	mentionStyle         = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("#777")).Padding(0, 1)
+	mentionSelectedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#7D56F4"))
+)
+
+// WithFileMentions completes the paths typed after @ in the input from the
+// files listFiles returns. it runs outside Update since walking a big tree
+// takes a while, and again when a completion starts once the last list is
+// older than mentionFilesTTL, so new files show up
+func WithFileMentions(listFiles func() []string) Option {
+	return func(c *Chat) { c.mention.listFiles = listFiles }
+}
+
+// mentionFilesMsg carries the files listFiles returned
+type mentionFilesMsg struct {
+	files []string
+}
+
+// mentionCompleter is the popup completing a path after @
+type mentionCompleter struct {
+	listFiles func() []string
+
+	active   bool
+	files    []string // the last listed files, kept between completions
+	listed   time.Time
+	listing  bool
+	matches  []string
+	selected int
+	// dismissed is the input esc closed the popup for, it stays closed
+	// until the input changes
+	dismissed string
+}
+
+// mentionQuery returns what follows the @ the input ends with, an @ only
+// starts a mention at the beginning of a word so addresses don't
+func mentionQuery(value string) (string, bool) {
+	at := strings.LastIndex(value, "@")
+	if at < 0 {
+		return "", false
+	}
+	if at > 0 && !unicode.IsSpace(rune(value[at-1])) {
+		return "", false
+	}
+	query := value[at+1:]
+	if strings.IndexFunc(query, unicode.IsSpace) >= 0 {
+		return "", false
+	}
+	return query, true
+}
+
+// updateMention opens, filters or closes the popup after the input changed,
+// listing the files again if the last list is too old
+func (c *Chat) updateMention() tea.Cmd {
+	m := &c.mention
+	if m.listFiles == nil {
+		return nil
+	}
+	value := c.input.Value()
+	query, ok := mentionQuery(value)
+	if !ok || value == m.dismissed {
+		// a list that never arrives, the app showing another view when it
+		// did, doesn't keep the next completion from listing again
+		m.active, m.listing = false, false
+		return nil
+	}
+	m.dismissed = ""
+	var cmd tea.Cmd
+	if !m.active {
+		m.active = true
+		if !m.listing && (m.files == nil || c.status.clock.Now().Sub(m.listed) > mentionFilesTTL) {
+			m.listing = true
+			listFiles := m.listFiles
+			cmd = func() tea.Msg { return mentionFilesMsg{files: listFiles()} }
+		}
+	}
+	m.filter(query)
+	return cmd
+}
+
+// setMentionFiles completes from the files just listed
+func (c *Chat) setMentionFiles(files []string) {
+	m := &c.mention
+	m.files, m.listed, m.listing = files, c.status.clock.Now(), false
+	if query, ok := mentionQuery(c.input.Value()); ok && m.active {
+		m.filter(query)
+	}
+}
+
+// filter picks the files matching query
+func (m *mentionCompleter) filter(query string) {
+	m.selected = 0
+	m.matches = m.matches[:0]
+	if query == "" {
+		m.matches = append(m.matches, m.files[:min(len(m.files), maxMentions)]...)
+		return
+	}
+	for _, rank := range list.DefaultFilter(query, m.files) {
+		if len(m.matches) == maxMentions {
+			break
+		}
+		m.matches = append(m.matches, m.files[rank.Index])
+	}
+}
+
+// mentionKey handles the keys of the popup while it shows completions:
+// up and down move, tab or enter pick the path and esc closes it
+func (c *Chat) mentionKey(msg tea.KeyMsg) (tea.Cmd, bool) {
+	m := &c.mention
+	if !m.active || len(m.matches) == 0 {
+		return nil, false
+	}
+	switch msg.String() {
+	case "up", "ctrl+p":
+		m.selected = (m.selected + len(m.matches) - 1) % len(m.matches)
+	case "down", "ctrl+n":
+		m.selected = (m.selected + 1) % len(m.matches)
+	case "esc":
+		m.active = false
+		m.dismissed = c.input.Value()
+	case "tab", "enter":
+		path := m.matches[m.selected]
+		value := c.input.Value()
+		c.input.SetValue(value[:strings.LastIndex(value, "@")] + "@" + path + " ")
+		m.active = false
+		return func() tea.Msg { return FileMentionedMsg{Path: path} }, true
+	default:
+		return nil, false
+	}
+	return nil, true
+}
+
+// mentionView renders the popup, "" when it isn't shown
+func (c *Chat) mentionView() string {
+	m := &c.mention
+	if !m.active || len(m.matches) == 0 {
+		return ""
+	}
+	lines := make([]string, len(m.matches))
+	for i, path := range m.matches {
+		if i == m.selected {
+			lines[i] = mentionSelectedStyle.Render("> " + path)
+		} else {
+			lines[i] = "  " + path
+		}
+	}
+	return mentionStyle.MaxWidth(c.history.Width).Render(strings.Join(lines, "\n"))
+}
+
+// overlayBottom puts popup over the last lines of view
+func overlayBottom(view, popup string) string {
+	lines := strings.Split(view, "\n")
+	popupLines := strings.Split(popup, "\n")
+	if len(popupLines) > len(lines) {
+		popupLines = popupLines[len(popupLines)-len(lines):]
+	}
+	copy(lines[len(lines)-len(popupLines):], popupLines)
+	return strings.Join(lines, "\n")
+}
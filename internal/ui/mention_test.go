@@ -0,0 +1,56 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scbenet/ask/internal/clock"
+)
+
+// TestMentionListing lists the files outside Update and only lists them
+// again once the last list is older than mentionFilesTTL
+func TestMentionListing(t *testing.T) {
+	c := clock.NewFake(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	listed := 0
+	chat := New(80, 24, WithClock(c), WithFileMentions(func() []string {
+		listed++
+		return []string{"main.go", "README.md"}
+	}))
+
+	// typeText types s into the input and delivers the files it lists
+	typeText := func(s string) {
+		chat.input.SetValue(chat.input.Value() + s)
+		if cmd := chat.updateMention(); cmd != nil {
+			chat.Update(cmd())
+		}
+	}
+	clear := func() {
+		chat.input.SetValue("")
+		chat.updateMention()
+	}
+
+	typeText("look at @ma")
+	if listed != 1 {
+		t.Fatalf("listed the files %d times, want 1", listed)
+	}
+	if view := chat.mentionView(); !strings.Contains(view, "main.go") || strings.Contains(view, "README.md") {
+		t.Fatalf("popup shows %q", view)
+	}
+
+	clear()
+	typeText("@")
+	if listed != 1 {
+		t.Fatalf("listed the files again within the TTL")
+	}
+	if view := chat.mentionView(); !strings.Contains(view, "README.md") {
+		t.Fatalf("popup shows %q from the cached list", view)
+	}
+
+	clear()
+	c.Advance(mentionFilesTTL + time.Second)
+	typeText("@")
+	if listed != 2 {
+		t.Fatalf("listed the files %d times after the TTL, want 2", listed)
+	}
+}
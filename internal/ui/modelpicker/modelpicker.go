@@ -3,18 +3,33 @@ package modelpicker
 import (
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/llm"
 )
 
 type Model struct {
 	list         list.Model
 	selectedItem string // store the selected item temporarily?
+	title        string
+	sortBy       sortKey
+	width        int
+	height       int
+	detailsStyle lipgloss.Style
 }
 
-type Item string
+// Item is a model in the list, Info is filled in once metadata arrives
+type Item struct {
+	ID    string
+	Info  *llm.ModelInfo
+	order int // position in the configured list, for the default sort
+}
 
 // ModelSelectedMsg is emitted when a new model is selected
 type ModelSelectedMsg struct {
@@ -24,9 +39,30 @@ type ModelSelectedMsg struct {
 type PickerCancelledMsg struct{}
 
 func (i Item) FilterValue() string {
-	return string(i)
+	return i.ID
+}
+
+// sortKey orders the list, s cycles through them
+type sortKey int
+
+const (
+	sortDefault sortKey = iota
+	sortPrice
+	sortContext
+	sortName
+)
+
+func (k sortKey) String() string {
+	return [...]string{"default", "price", "context", "name"}[k]
 }
 
+// details pane is shown to the right of the list when there's room for both
+const (
+	detailsWidth    = 44
+	minListWidth    = 60
+	defaultListSize = 14
+)
+
 type itemDelegate struct{}
 
 func (d itemDelegate) Height() int {
@@ -46,7 +82,17 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 		return
 	}
 
-	str := fmt.Sprintf("%d. %s", index+1, i)
+	// name on the left, metadata columns right aligned
+	meta := fmt.Sprintf("%8s  %17s  ", "", "")
+	if i.Info != nil {
+		meta = fmt.Sprintf("%8s  %17s  ", formatContext(i.Info.ContextLength), formatPricing(*i.Info))
+	}
+	name := fmt.Sprintf("%d. %s %s", index+1, providerIcon(i.ID), i.ID)
+	nameWidth := max(m.Width()-lipgloss.Width(meta)-6, 10)
+	if lipgloss.Width(name) > nameWidth {
+		name = truncate(name, nameWidth)
+	}
+	str := name + strings.Repeat(" ", max(nameWidth-lipgloss.Width(name), 1)) + meta
 
 	fn := lipgloss.NewStyle().PaddingLeft(4).Render
 	if index == m.Index() {
@@ -62,18 +108,87 @@ func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list
 	fmt.Fprint(w, fn(str))
 }
 
+// providerIcon marks where a model comes from, at a glance
+func providerIcon(id string) string {
+	if provider, _, ok := strings.Cut(id, ":"); ok && !strings.Contains(provider, "/") {
+		if provider == "gemini" {
+			return "♊"
+		}
+		// openai compatible servers, usually local
+		return "🖥"
+	}
+	vendor, _, _ := strings.Cut(id, "/")
+	switch vendor {
+	case "openai":
+		return "⚪"
+	case "anthropic":
+		return "🟠"
+	case "google":
+		return "🔷"
+	case "deepseek":
+		return "🐋"
+	case "meta-llama":
+		return "🦙"
+	case "mistralai":
+		return "🌀"
+	case "microsoft":
+		return "🟦"
+	case "x-ai":
+		return "✖"
+	case "qwen":
+		return "🐉"
+	}
+	return "•"
+}
+
+func formatContext(tokens int) string {
+	switch {
+	case tokens <= 0:
+		return "-"
+	case tokens >= 1_000_000:
+		return fmt.Sprintf("%gM ctx", float64(tokens/100_000)/10)
+	default:
+		return fmt.Sprintf("%dk ctx", tokens/1000)
+	}
+}
+
+// formatPricing shows prompt/completion price per million tokens
+func formatPricing(info llm.ModelInfo) string {
+	if info.PromptPrice == 0 && info.CompletionPrice == 0 {
+		if info.ContextLength > 0 {
+			return i18n.T("free")
+		}
+		return "-"
+	}
+	return fmt.Sprintf("$%s/$%s", formatPrice(info.PromptPrice), formatPrice(info.CompletionPrice))
+}
+
+func formatPrice(p float64) string {
+	if p < 10 {
+		return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", p), "0"), ".")
+	}
+	return fmt.Sprintf("%.0f", p)
+}
+
+func truncate(s string, width int) string {
+	r := []rune(s)
+	for len(r) > 0 && lipgloss.Width(string(r)) > width-1 {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "…"
+}
+
 // creates new model picker component
 func New(modelNames []string) *Model {
 	items := make([]list.Item, len(modelNames))
 	for i, name := range modelNames {
-		items[i] = Item(name)
+		items[i] = Item{ID: name, order: i}
 	}
 
 	const defaultWidth = 40
-	const listHeight = 14
 
-	l := list.New(items, itemDelegate{}, defaultWidth, listHeight)
-	l.Title = "Select your model"
+	l := list.New(items, itemDelegate{}, defaultWidth, defaultListSize)
+	l.Title = i18n.T("Select your model")
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(true)
 	l.Styles.Title = lipgloss.NewStyle().
@@ -82,8 +197,86 @@ func New(modelNames []string) *Model {
 		Padding(0, 1)
 	l.Styles.PaginationStyle = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
 	l.Styles.HelpStyle = list.DefaultStyles().HelpStyle.PaddingLeft(4).PaddingBottom(1)
+	l.AdditionalShortHelpKeys = func() []key.Binding { return i18n.Keys(sortKeyBinding) }
+	i18n.List(&l)
+
+	return &Model{
+		list:  l,
+		title: l.Title,
+		detailsStyle: lipgloss.NewStyle().
+			Border(lipgloss.RoundedBorder()).
+			BorderForeground(lipgloss.Color("#7D56F4")).
+			Padding(0, 1),
+	}
+}
+
+var sortKeyBinding = key.NewBinding(
+	key.WithKeys("s"),
+	key.WithHelp("s", "sort"),
+)
+
+// AddModels appends models discovered after startup, e.g. from a local server
+func (m *Model) AddModels(models []llm.ModelInfo) {
+	n := len(m.list.Items())
+	for i, info := range models {
+		m.list.InsertItem(n+i, Item{ID: info.ID, Info: &info, order: n + i})
+	}
+	m.sort()
+}
+
+// SetInfo attaches metadata to the listed models with matching ids
+func (m *Model) SetInfo(models []llm.ModelInfo) {
+	byID := make(map[string]llm.ModelInfo, len(models))
+	for _, info := range models {
+		byID[info.ID] = info
+	}
+	items := m.list.Items()
+	for i, it := range items {
+		item := it.(Item)
+		if info, ok := byID[item.ID]; ok {
+			item.Info = &info
+			items[i] = item
+		}
+	}
+	m.list.SetItems(items)
+	m.sort()
+}
 
-	return &Model{list: l}
+// sort reorders the items by the current sort key. models without the
+// metadata a key needs sort last
+func (m *Model) sort() {
+	items := m.list.Items()
+	less := func(a, b Item) bool { return a.order < b.order }
+	switch m.sortBy {
+	case sortPrice:
+		less = func(a, b Item) bool {
+			if (a.Info == nil) != (b.Info == nil) {
+				return a.Info != nil
+			}
+			if a.Info == nil || a.Info.PromptPrice+a.Info.CompletionPrice == b.Info.PromptPrice+b.Info.CompletionPrice {
+				return a.order < b.order
+			}
+			return a.Info.PromptPrice+a.Info.CompletionPrice < b.Info.PromptPrice+b.Info.CompletionPrice
+		}
+	case sortContext:
+		less = func(a, b Item) bool {
+			ac, bc := 0, 0
+			if a.Info != nil {
+				ac = a.Info.ContextLength
+			}
+			if b.Info != nil {
+				bc = b.Info.ContextLength
+			}
+			if ac == bc {
+				return a.order < b.order
+			}
+			return ac > bc
+		}
+	case sortName:
+		less = func(a, b Item) bool { return a.ID < b.ID }
+	}
+	sort.SliceStable(items, func(i, j int) bool { return less(items[i].(Item), items[j].(Item)) })
+	m.list.SetItems(items)
 }
 
 // initializes model picker, currently does nothing
@@ -97,9 +290,8 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
-
-		m.list.SetWidth(msg.Width)
-		m.list.SetHeight(msg.Height)
+		m.width, m.height = msg.Width, msg.Height
+		m.resize()
 		return m, nil
 
 	case tea.KeyMsg:
@@ -112,11 +304,20 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 			}
 
+		case "s":
+			if m.list.FilterState() == list.Unfiltered {
+				m.sortBy = (m.sortBy + 1) % (sortName + 1)
+				m.sort()
+				m.updateTitle()
+				m.list.Select(0)
+				return m, nil
+			}
+
 		case "enter":
 			if m.list.FilterState() != 1 {
 				selected, ok := m.list.SelectedItem().(Item)
 				if ok {
-					m.selectedItem = string(selected)
+					m.selectedItem = selected.ID
 					return m, func() tea.Msg {
 						return ModelSelectedMsg{Model: m.selectedItem}
 					}
@@ -131,10 +332,73 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// showDetailsBeside reports whether the details pane fits next to the list
+func (m *Model) showDetailsBeside() bool {
+	return m.width >= minListWidth+detailsWidth
+}
+
+func (m *Model) resize() {
+	if m.showDetailsBeside() {
+		m.list.SetSize(m.width-detailsWidth, m.height)
+		return
+	}
+	// details go underneath instead
+	m.list.SetSize(m.width, max(m.height-8, defaultListSize/2))
+}
+
 func (m *Model) View() string {
-	return "\n" + m.list.View()
+	listView := m.list.View()
+	if m.showDetailsBeside() {
+		return "\n" + lipgloss.JoinHorizontal(lipgloss.Top, listView, m.detailsView(detailsWidth-2))
+	}
+	return "\n" + lipgloss.JoinVertical(lipgloss.Left, listView, m.detailsView(max(m.width-2, 20)))
+}
+
+// detailsView describes the highlighted model
+func (m *Model) detailsView(width int) string {
+	item, ok := m.list.SelectedItem().(Item)
+	if !ok {
+		return ""
+	}
+	style := m.detailsStyle.Width(width - 2)
+
+	var b strings.Builder
+	b.WriteString(lipgloss.NewStyle().Bold(true).Render(item.ID))
+	if item.Info == nil {
+		b.WriteString("\n\n" + i18n.T("no details available"))
+		return style.Render(b.String())
+	}
+
+	info := item.Info
+	if info.Name != "" && info.Name != info.ID {
+		b.WriteString("\n" + info.Name)
+	}
+	if info.ContextLength > 0 {
+		b.WriteString("\n\n" + i18n.Tf("context     %d tokens", info.ContextLength))
+	}
+	if info.PromptPrice > 0 || info.CompletionPrice > 0 {
+		b.WriteString("\n" + i18n.Tf("prompt      $%s / M tokens", formatPrice(info.PromptPrice)))
+		b.WriteString("\n" + i18n.Tf("completion  $%s / M tokens", formatPrice(info.CompletionPrice)))
+	}
+	if info.Description != "" {
+		desc := info.Description
+		// keep the pane from pushing the list off screen
+		if r := []rune(desc); len(r) > 400 {
+			desc = string(r[:400]) + "…"
+		}
+		b.WriteString("\n\n" + desc)
+	}
+	return style.Render(b.String())
 }
 
 func (m *Model) SetTitle(title string) {
-	m.list.Title = title
+	m.title = title
+	m.updateTitle()
+}
+
+func (m *Model) updateTitle() {
+	m.list.Title = m.title
+	if m.sortBy != sortDefault {
+		m.list.Title = i18n.Tf("%s · sorted by %s", m.title, i18n.T(m.sortBy.String()))
+	}
 }
@@ -0,0 +1,66 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/atotto/clipboard"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/i18n"
+	"github.com/scbenet/ask/internal/logging"
+)
+
+// handleMouse scrolls the history with the wheel, selects and copies text
+// dragged over in it, and focuses the input when it's clicked. clicking the
+// history takes focus away from the input, so arrow keys only scroll
+func (c *Chat) handleMouse(m tea.MouseMsg) tea.Cmd {
+	row := m.Y - c.historyViewStyle.GetPaddingTop()
+	col := m.X - c.historyViewStyle.GetPaddingLeft()
+	// the status bar sits between the history and the input
+	inputTop := c.history.Height + c.historyViewStyle.GetVerticalPadding() + 1
+
+	switch {
+	case tea.MouseEvent(m).IsWheel():
+		c.history, _ = c.history.Update(m)
+		if c.sending {
+			c.following = c.history.AtBottom()
+		}
+
+	case m.Action == tea.MouseActionPress && m.Button == tea.MouseButtonLeft:
+		if m.Y >= inputTop {
+			return c.input.Focus()
+		}
+		if row >= 0 && row < c.history.Height {
+			c.input.Blur()
+			c.history.StartSelection(row, col)
+		}
+
+	case m.Action == tea.MouseActionMotion && c.history.Selecting():
+		c.history.ExtendSelection(row, col)
+
+	case m.Action == tea.MouseActionRelease && c.history.Selecting():
+		c.history.ExtendSelection(row, col)
+		c.copySelection()
+	}
+	return nil
+}
+
+// copySelection copies the text selected with the mouse and ends the
+// selection. a click without dragging selects nothing
+func (c *Chat) copySelection() {
+	text := c.history.SelectedText()
+	c.history.ClearSelection()
+	c.copyText(text)
+}
+
+// copyText puts text on the clipboard, saying how much was copied
+func (c *Chat) copyText(text string) {
+	if text == "" {
+		return
+	}
+	if err := clipboard.WriteAll(text); err != nil {
+		logging.Errorf("copying selection: %v", err)
+		c.AddError(i18n.Tf("failed to copy: %v", err))
+		return
+	}
+	c.AddNotice(i18n.Tf("copied %d line(s)", strings.Count(text, "\n")+1))
+}
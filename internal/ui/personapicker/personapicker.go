@@ -0,0 +1,125 @@
+package personapicker
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/scbenet/ask/internal/config"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// Model is the list of personas shown with ctrl+g
+type Model struct {
+	list list.Model
+}
+
+// Item is a persona in the list, the zero Item turns the persona off
+type Item struct {
+	config.Persona
+}
+
+// PersonaSelectedMsg is emitted when a persona is picked, Name is empty
+// when the persona was turned off
+type PersonaSelectedMsg struct {
+	Persona config.Persona
+}
+
+type PickerCancelledMsg struct{}
+
+func (i Item) FilterValue() string {
+	return i.Name
+}
+
+type itemDelegate struct{}
+
+func (d itemDelegate) Height() int                               { return 2 }
+func (d itemDelegate) Spacing() int                              { return 0 }
+func (d itemDelegate) Update(msg tea.Msg, m *list.Model) tea.Cmd { return nil }
+
+func (d itemDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	i, ok := listItem.(Item)
+	if !ok {
+		return
+	}
+
+	name, prompt := i.Name, i.Prompt
+	if name == "" {
+		name, prompt = i18n.T("none"), i18n.T("no persona, just the model's defaults")
+	}
+	// the prompt is previewed on one line under the name
+	if maxWidth := m.Width() - 8; maxWidth > 0 && lipgloss.Width(prompt) > maxWidth {
+		prompt = string([]rune(prompt)[:maxWidth-1]) + "…"
+	}
+
+	nameStyle := lipgloss.NewStyle().PaddingLeft(4)
+	promptStyle := lipgloss.NewStyle().PaddingLeft(4).Faint(true)
+	if index == m.Index() {
+		nameStyle = lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color("#7D56F4"))
+		name = "> " + name
+		promptStyle = promptStyle.PaddingLeft(4).Foreground(lipgloss.Color("#7D56F4"))
+	}
+	fmt.Fprintf(w, "%s\n%s", nameStyle.Render(name), promptStyle.Render(prompt))
+}
+
+// New creates a picker for personas, with an entry for turning them off first
+func New(personas []config.Persona) *Model {
+	items := []list.Item{Item{}}
+	for _, p := range personas {
+		items = append(items, Item{p})
+	}
+
+	l := list.New(items, itemDelegate{}, 40, 14)
+	l.Title = i18n.T("Select a persona")
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(true)
+	l.Styles.Title = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FFFDF5")).
+		Background(lipgloss.Color("#7D56F4")).
+		Padding(0, 1)
+	l.Styles.PaginationStyle = list.DefaultStyles().PaginationStyle.PaddingLeft(4)
+	l.Styles.HelpStyle = list.DefaultStyles().HelpStyle.PaddingLeft(4).PaddingBottom(1)
+	i18n.List(&l)
+
+	return &Model{list: l}
+}
+
+func (m *Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.list.SetSize(msg.Width, msg.Height)
+		return m, nil
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "esc":
+			if m.list.FilterState() == list.Unfiltered {
+				return m, func() tea.Msg { return PickerCancelledMsg{} }
+			}
+		case "enter":
+			if m.list.FilterState() != list.Filtering {
+				if selected, ok := m.list.SelectedItem().(Item); ok {
+					return m, func() tea.Msg { return PersonaSelectedMsg{Persona: selected.Persona} }
+				}
+			}
+		}
+	}
+
+	var cmd tea.Cmd
+	m.list, cmd = m.list.Update(msg)
+	return m, cmd
+}
+
+func (m *Model) View() string {
+	return "\n" + m.list.View()
+}
+
+func (m *Model) SetTitle(title string) {
+	m.list.Title = title
+}
@@ -0,0 +1,110 @@
+package ui
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// ReplayEndedMsg is emitted when the replay is left, the whole
+// conversation is shown again by then
+type ReplayEndedMsg struct{}
+
+// replay steps through a conversation one message at a time, for demos and
+// for going over how a long session unfolded. the history only shows the
+// messages stepped through so far and the input takes no prompts
+type replay struct {
+	messages []conversation.Message
+	shown    int
+}
+
+type replayKeyMap struct {
+	Next, Previous, First, Last, Exit key.Binding
+}
+
+func defaultReplayKeyMap() replayKeyMap {
+	return replayKeyMap{
+		Next:     key.NewBinding(key.WithKeys(" ", "right", "n"), key.WithHelp("space", i18n.T("next"))),
+		Previous: key.NewBinding(key.WithKeys("backspace", "left", "b"), key.WithHelp("b", i18n.T("back"))),
+		First:    key.NewBinding(key.WithKeys("home", "g"), key.WithHelp("g", i18n.T("first"))),
+		Last:     key.NewBinding(key.WithKeys("end", "G"), key.WithHelp("G", i18n.T("last"))),
+		Exit:     key.NewBinding(key.WithKeys("esc", "q", "ctrl+c"), key.WithHelp("q", i18n.T("leave"))),
+	}
+}
+
+// StartReplay clears the history and shows the first of messages, space
+// steps to the next one until the replay is left. inline (and in accessible
+// mode) the messages are printed to the scrollback as they are stepped
+// through, there's no taking them back so the replay only goes forward
+func (c *Chat) StartReplay(messages []conversation.Message) {
+	c.replay = &replay{messages: messages}
+	c.replayKeys.Previous.SetEnabled(!c.inline)
+	c.replayKeys.First.SetEnabled(!c.inline)
+	c.ClearHistory()
+	c.replayTo(min(1, len(messages)))
+}
+
+// Replaying reports whether a replay is shown, it takes every key
+func (c *Chat) Replaying() bool {
+	return c.replay != nil
+}
+
+// replayTo shows the first n messages
+func (c *Chat) replayTo(n int) {
+	r := c.replay
+	n = min(max(n, 0), len(r.messages))
+	if n == r.shown {
+		return
+	}
+	if n > r.shown {
+		c.LoadHistory(r.messages[r.shown:n])
+	} else {
+		c.ClearHistory()
+		c.LoadHistory(r.messages[:n])
+	}
+	r.shown = n
+	c.refreshHistory()
+}
+
+// updateReplay handles a key during a replay, scrolling keys still scroll
+func (c *Chat) updateReplay(msg tea.KeyMsg) tea.Cmd {
+	r := c.replay
+	switch {
+	case key.Matches(msg, c.replayKeys.Next):
+		c.replayTo(r.shown + 1)
+	case key.Matches(msg, c.replayKeys.Previous):
+		c.replayTo(r.shown - 1)
+	case key.Matches(msg, c.replayKeys.First):
+		c.replayTo(1)
+	case key.Matches(msg, c.replayKeys.Last):
+		c.replayTo(len(r.messages))
+	case key.Matches(msg, c.replayKeys.Exit):
+		c.replayTo(len(r.messages))
+		c.replay = nil
+		return func() tea.Msg { return ReplayEndedMsg{} }
+	default:
+		var cmd tea.Cmd
+		c.history, cmd = c.history.Update(msg)
+		return cmd
+	}
+	return nil
+}
+
+// replayStatus renders e.g. "replay 3/12 · space next · b back · q leave"
+func (c *Chat) replayStatus() string {
+	r := c.replay
+	status := i18n.Tf("replay %d/%d", r.shown, len(r.messages))
+	if r.shown == len(r.messages) {
+		status += " · " + i18n.T("end")
+	}
+	for _, b := range []key.Binding{c.replayKeys.Next, c.replayKeys.Previous, c.replayKeys.Exit} {
+		if !b.Enabled() {
+			continue
+		}
+		status += fmt.Sprintf(" · %s %s", b.Help().Key, b.Help().Desc)
+	}
+	return status
+}
@@ -0,0 +1,178 @@
+package ui
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// position is a cell in the history, line counts from the top of the whole
+// history rather than the visible window
+type position struct {
+	line, col int
+}
+
+func (p position) before(q position) bool {
+	return p.line < q.line || (p.line == q.line && p.col < q.col)
+}
+
+// selection is a region of the history being selected for copying. it
+// covers anchor to cursor, whichever way round they are
+type selection struct {
+	active bool
+	anchor position
+	cursor position
+}
+
+// bounds returns the first and last selected cells
+func (s selection) bounds() (start, end position) {
+	if s.cursor.before(s.anchor) {
+		return s.cursor, s.anchor
+	}
+	return s.anchor, s.cursor
+}
+
+var (
+	selectionStyle = lipgloss.NewStyle().Reverse(true)
+	cursorStyle    = lipgloss.NewStyle().Background(lipgloss.Color("#7D56F4")).Foreground(lipgloss.Color("#FFFDF5"))
+)
+
+// StartSelection begins selecting at row and col of the visible window
+func (h *historyView) StartSelection(row, col int) {
+	p := position{line: h.offset + row, col: max(col, 0)}
+	h.selection = selection{active: true, anchor: p, cursor: p}
+}
+
+// ExtendSelection moves the end of the selection to row and col of the
+// visible window. rows above or below it scroll the history
+func (h *historyView) ExtendSelection(row, col int) {
+	if !h.selection.active {
+		return
+	}
+	switch {
+	case row < 0:
+		h.scroll(-1)
+		row = 0
+	case row >= h.Height:
+		h.scroll(1)
+		row = h.Height - 1
+	}
+	h.selection.cursor = position{line: min(h.offset+row, max(h.lineCount()-1, 0)), col: max(col, 0)}
+}
+
+// Selecting reports whether a selection is in progress
+func (h *historyView) Selecting() bool {
+	return h.selection.active
+}
+
+// ClearSelection ends the selection without copying anything
+func (h *historyView) ClearSelection() {
+	h.selection = selection{}
+}
+
+// SelectedText returns the selected text without styling. lines are
+// trimmed, rendered messages are padded to the full width
+func (h *historyView) SelectedText() string {
+	// a click selects nothing, a single cell can only be selected in copy mode
+	if !h.selection.active || (h.selection.anchor == h.selection.cursor && !h.copying) {
+		return ""
+	}
+	start, end := h.selection.bounds()
+	var lines []string
+	for n := start.line; n <= end.line; n++ {
+		plain := ansi.Strip(h.lineAt(n))
+		from, to := h.selectedColumns(n, plain)
+		lines = append(lines, strings.TrimRight(ansi.Cut(plain, from, to), " "))
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// selectedColumns returns the selected cells [from, to) of line n
+func (h *historyView) selectedColumns(n int, plain string) (from, to int) {
+	start, end := h.selection.bounds()
+	from, to = 0, ansi.StringWidth(plain)
+	if n == start.line {
+		from = start.col
+	}
+	if n == end.line {
+		to = min(end.col+1, to)
+	}
+	return from, max(from, to)
+}
+
+// lineAt returns line n of the history, counting the streamed response
+func (h *historyView) lineAt(n int) string {
+	if n >= h.total {
+		if i := n - h.total; i < len(h.live) {
+			return h.live[i]
+		}
+		return ""
+	}
+	b := sort.Search(len(h.starts), func(i int) bool { return h.starts[i] > n }) - 1
+	if b < 0 {
+		return ""
+	}
+	return h.blocks[b].lines[n-h.starts[b]]
+}
+
+// highlightSelection marks the selected part of the visible lines and the
+// copy mode cursor. the marked lines lose their styling, which is also what
+// gets copied
+func (h *historyView) highlightSelection(lines []string) {
+	if !h.selection.active && !h.copying {
+		return
+	}
+	for i := range lines {
+		lines[i] = h.highlightLine(h.offset+i, lines[i])
+	}
+}
+
+func (h *historyView) highlightLine(n int, line string) string {
+	selFrom, selTo, cursor := -1, -1, -1
+	if h.selection.active {
+		if start, end := h.selection.bounds(); n >= start.line && n <= end.line {
+			selFrom, selTo = h.selectedColumns(n, ansi.Strip(line))
+		}
+	}
+	if h.copying && n == h.cursor.line {
+		cursor = h.cursor.col
+	}
+	if selFrom < 0 && cursor < 0 {
+		return line
+	}
+
+	plain := ansi.Strip(line)
+	width := ansi.StringWidth(plain)
+	if cursor >= width {
+		// the cursor can sit on an empty line
+		plain += strings.Repeat(" ", cursor-width+1)
+		width = cursor + 1
+	}
+	// cut the line where the styling changes
+	cuts := []int{0, width}
+	for _, c := range []int{selFrom, selTo, cursor, cursor + 1} {
+		if c > 0 && c < width {
+			cuts = append(cuts, c)
+		}
+	}
+	sort.Ints(cuts)
+	var b strings.Builder
+	for i := 0; i+1 < len(cuts); i++ {
+		from, to := cuts[i], cuts[i+1]
+		if from == to {
+			continue
+		}
+		segment := ansi.Cut(plain, from, to)
+		switch {
+		case from == cursor:
+			b.WriteString(cursorStyle.Render(segment))
+		case from >= selFrom && to <= selTo:
+			b.WriteString(selectionStyle.Render(segment))
+		default:
+			b.WriteString(segment)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// the keys a prompt can be sent with, see WithSendKey
+const (
+	SendKeyEnter     = "enter"      // enter sends, shift+enter inserts a new line
+	SendKeyCtrlEnter = "ctrl+enter" // enter inserts a new line, ctrl+enter sends
+	SendKeyAltEnter  = "alt+enter"  // enter inserts a new line, alt+enter sends
+)
+
+// WithSendKey sets the key sending a prompt, one of the SendKey constants.
+// with ctrl+enter or alt+enter, enter inserts a new line like in most chat
+// apps' multiline mode
+func WithSendKey(sendKey string) Option {
+	return func(c *Chat) { c.setSendKey(sendKey) }
+}
+
+// setSendKey rebinds sending and inserting new lines for sendKey, the help
+// shows whichever key sends
+func (c *Chat) setSendKey(sendKey string) {
+	var send, newLine []string
+	var newLineHelp string
+	switch sendKey {
+	case SendKeyCtrlEnter:
+		// terminals without the kitty protocol send ctrl+j for ctrl+enter
+		// if they tell it apart at all, alt+enter works everywhere
+		send = []string{"ctrl+j", "alt+enter"}
+		newLine = []string{"enter", "shift+enter"}
+		newLineHelp = "enter"
+	case SendKeyAltEnter:
+		send = []string{"alt+enter"}
+		newLine = []string{"enter", "shift+enter", "ctrl+j"}
+		newLineHelp = "enter"
+	default:
+		// terminals only send shift+enter apart from enter with the kitty
+		// keyboard protocol, KittyKeyboard turns it into ctrl+j
+		sendKey = SendKeyEnter
+		send = []string{"enter"}
+		newLine = []string{"shift+enter", "ctrl+j"}
+		newLineHelp = newLineKeyHelp()
+	}
+	c.sendKeyName = sendKey
+	c.sendKey = key.NewBinding(key.WithKeys(send...), key.WithHelp(sendKey, i18n.T("send")))
+	c.keys.SendPrompt = key.NewBinding(key.WithKeys(send...), key.WithHelp(sendKey, i18n.T("send message")))
+	c.keys.NewLine = key.NewBinding(key.WithKeys(newLine...), key.WithHelp(newLineHelp, i18n.T("new line")))
+	c.input.KeyMap.InsertNewline = key.NewBinding(key.WithKeys(newLine...), key.WithHelp(newLineHelp, i18n.T("new line")))
+}
+
+// SendKeyMsg returns a press of the key sending a prompt, for scripts
+func (c *Chat) SendKeyMsg() tea.KeyMsg {
+	switch c.sendKeyName {
+	case SendKeyCtrlEnter:
+		return tea.KeyMsg{Type: tea.KeyCtrlJ}
+	case SendKeyAltEnter:
+		return tea.KeyMsg{Type: tea.KeyEnter, Alt: true}
+	}
+	return tea.KeyMsg{Type: tea.KeyEnter}
+}
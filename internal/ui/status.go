@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/i18n"
+)
+
+// rough chars per token for English text, only used until the provider
+// reports real usage
+const charsPerToken = 4
+
+// streamStatus tracks progress of the response currently being generated
+type streamStatus struct {
+	spinner      spinner.Model
+	clock        clock.Clock
+	started      time.Time
+	firstChunkAt time.Time
+	chars        int
+}
+
+func newStreamStatus() streamStatus {
+	return streamStatus{
+		spinner: spinner.New(spinner.WithSpinner(spinner.Dot)),
+		clock:   clock.System,
+	}
+}
+
+// start resets the counters and kicks off the spinner
+func (s *streamStatus) start() tea.Cmd {
+	s.started = s.clock.Now()
+	s.firstChunkAt = time.Time{}
+	s.chars = 0
+	return s.spinner.Tick
+}
+
+// chunk records streamed content for the tokens/sec estimate
+func (s *streamStatus) chunk(content string) {
+	if s.firstChunkAt.IsZero() {
+		s.firstChunkAt = s.clock.Now()
+	}
+	s.chars += len(content)
+}
+
+// view renders e.g. "⣾ 12s · ~48 tok/s"
+func (s *streamStatus) view() string {
+	elapsed := s.clock.Now().Sub(s.started).Truncate(time.Second)
+	if s.firstChunkAt.IsZero() {
+		return i18n.Tf("%s waiting for response… %s", s.spinner.View(), elapsed)
+	}
+
+	tokens := s.chars / charsPerToken
+	rate := 0.0
+	if streaming := s.clock.Now().Sub(s.firstChunkAt).Seconds(); streaming > 0.5 {
+		rate = float64(tokens) / streaming
+	}
+	return i18n.Tf("%s %s · ~%d tokens · ~%.0f tok/s", s.spinner.View(), elapsed, tokens, rate)
+}
@@ -0,0 +1,158 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// glamour's dark style indents the document and pads table cells, leave room
+// for that when deciding whether a table fits
+const tableRenderOverhead = 4
+
+// ReflowWideTables rewrites markdown tables that would be wider than width
+// into a key: value list, since glamour squashes wide tables into an
+// unreadable mess at narrow widths. tables that fit are left untouched.
+// width is the one glamour wraps at
+func ReflowWideTables(md string, width int) string {
+	lines := strings.Split(md, "\n")
+	out := make([]string, 0, len(lines))
+	inFence := false
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+		}
+		if inFence || !isTableRow(trimmed) || i+1 >= len(lines) || !isTableDelimiter(strings.TrimSpace(lines[i+1])) {
+			out = append(out, line)
+			continue
+		}
+
+		// collect the whole table: header, delimiter, then rows until a non-table line
+		end := i + 2
+		for end < len(lines) && isTableRow(strings.TrimSpace(lines[end])) {
+			end++
+		}
+		table := lines[i:end]
+
+		if tableWidth(table) > width {
+			out = append(out, reflowTable(table)...)
+		} else {
+			out = append(out, table...)
+		}
+		i = end - 1
+	}
+
+	return strings.Join(out, "\n")
+}
+
+func isTableRow(line string) bool {
+	return strings.HasPrefix(line, "|") && strings.Count(line, "|") >= 2
+}
+
+func isTableDelimiter(line string) bool {
+	if !isTableRow(line) {
+		return false
+	}
+	for _, cell := range splitTableRow(line) {
+		cell = strings.Trim(cell, ": ")
+		if cell == "" || strings.Trim(cell, "-") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// splitTableRow splits a table row into trimmed cells, honouring escaped pipes
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+
+	var cells []string
+	var cell strings.Builder
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			cell.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			cell.WriteRune(r)
+			escaped = true
+		case r == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteRune(r)
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// tableWidth estimates the rendered width of a table from its widest cells
+func tableWidth(table []string) int {
+	var colWidths []int
+	for i, row := range table {
+		if i == 1 { // delimiter row
+			continue
+		}
+		for col, cell := range splitTableRow(row) {
+			if col >= len(colWidths) {
+				colWidths = append(colWidths, 0)
+			}
+			colWidths[col] = max(colWidths[col], lipgloss.Width(cell))
+		}
+	}
+
+	width := tableRenderOverhead + 1
+	for _, w := range colWidths {
+		width += w + 3 // cell padding plus separator
+	}
+	return width
+}
+
+// reflowTable turns each row into a bullet titled by its first cell with the
+// remaining cells listed underneath as "header: value"
+func reflowTable(table []string) []string {
+	headers := unescapePipes(splitTableRow(table[0]))
+	var out []string
+
+	for _, row := range table[2:] {
+		cells := unescapePipes(splitTableRow(row))
+		if len(cells) == 0 {
+			continue
+		}
+
+		title := cells[0]
+		if title == "" {
+			title = "–"
+		}
+		out = append(out, "- **"+title+"**")
+		for col := 1; col < len(cells); col++ {
+			header := ""
+			if col < len(headers) {
+				header = headers[col]
+			}
+			if header == "" {
+				out = append(out, "  - "+cells[col])
+			} else {
+				out = append(out, "  - "+header+": "+cells[col])
+			}
+		}
+	}
+
+	return out
+}
+
+// unescapePipes undoes the \| a pipe in a table cell needs, outside the
+// table one in a code span would be shown with its backslash
+func unescapePipes(cells []string) []string {
+	for i, cell := range cells {
+		cells[i] = strings.ReplaceAll(cell, `\|`, "|")
+	}
+	return cells
+}
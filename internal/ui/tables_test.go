@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const wideTable = `prices:
+
+| model | prompt | completion | context |
+|-------|--------|------------|---------|
+| gpt-4.1 | $2.00 per million tokens | $8.00 per million tokens | 1M |
+| claude-3.7-sonnet | $3.00 per million tokens | $15.00 per million tokens | 200k |
+
+that's all`
+
+func TestReflowWideTables(t *testing.T) {
+	tests := []struct {
+		name  string
+		md    string
+		width int
+		want  string
+	}{
+		{
+			name:  "fits",
+			md:    "| a | b |\n|---|---|\n| 1 | 2 |",
+			width: 80,
+			want:  "| a | b |\n|---|---|\n| 1 | 2 |",
+		},
+		{
+			name:  "too wide",
+			md:    wideTable,
+			width: 60,
+			want: `prices:
+
+- **gpt-4.1**
+  - prompt: $2.00 per million tokens
+  - completion: $8.00 per million tokens
+  - context: 1M
+- **claude-3.7-sonnet**
+  - prompt: $3.00 per million tokens
+  - completion: $15.00 per million tokens
+  - context: 200k
+
+that's all`,
+		},
+		{
+			name:  "in a code block",
+			md:    "```\n| a | b |\n|---|---|\n| 1 | 2 |\n```",
+			width: 5,
+			want:  "```\n| a | b |\n|---|---|\n| 1 | 2 |\n```",
+		},
+		{
+			name:  "escaped pipe and empty first cell",
+			md:    "| cmd | what |\n|---|---|\n|  | `a \\| b` pipes a into b |",
+			width: 10,
+			want:  "- **–**\n  - what: `a | b` pipes a into b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReflowWideTables(tt.md, tt.width); got != tt.want {
+				t.Fatalf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRenderMarkdownTables reflows a table at the width the response is
+// wrapped at, and keeps it a table where it fits
+func TestRenderMarkdownTables(t *testing.T) {
+	c := New(120, 40)
+	narrow := ansi.Strip(c.renderMarkdown(wideTable, 50))
+	for _, line := range strings.Split(narrow, "\n") {
+		if w := lipgloss.Width(line); w > 50 {
+			t.Fatalf("line is %d wide at a width of 50: %q", w, line)
+		}
+	}
+	if !strings.Contains(narrow, "completion: $15.00 per million tokens") {
+		t.Fatalf("table wasn't reflowed at 50:\n%s", narrow)
+	}
+
+	if wide := ansi.Strip(c.renderMarkdown(wideTable, 160)); strings.Contains(wide, "completion: $15.00") {
+		t.Fatalf("table was reflowed at 160:\n%s", wide)
+	}
+}
@@ -0,0 +1,30 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/scbenet/ask/internal/llm"
+)
+
+// longest tool argument or result preview shown in the history
+const toolPreviewLen = 120
+
+// DescribeToolCall is the notice shown when the model calls a tool
+func DescribeToolCall(call llm.ToolCall) string {
+	return fmt.Sprintf("🔧 %s %s", call.Function.Name, preview(call.Function.Arguments))
+}
+
+// DescribeToolResult is the notice shown when a tool call finishes
+func DescribeToolResult(content string) string {
+	lines := strings.Count(strings.TrimRight(content, "\n"), "\n") + 1
+	return fmt.Sprintf("   ↳ %s (%d lines)", preview(content), lines)
+}
+
+func preview(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > toolPreviewLen {
+		return string(r[:toolPreviewLen]) + "…"
+	}
+	return s
+}
@@ -3,6 +3,7 @@ package ui
 import (
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/scbenet/ask/internal/i18n"
 )
 
 // Define a custom keymap so the viewport isn't jumping around while users
@@ -22,27 +23,27 @@ func CustomKeyMap() viewport.KeyMap {
 	return viewport.KeyMap{
 		PageDown: key.NewBinding(
 			key.WithKeys("pgdown", "ctrl+f"),
-			key.WithHelp("ctrl+f/pgdn", "page down"),
+			key.WithHelp("ctrl+f/pgdn", i18n.T("page down")),
 		),
 		PageUp: key.NewBinding(
 			key.WithKeys("pgup", "ctrl+b"),
-			key.WithHelp("ctrl+b/pgup", "page up"),
+			key.WithHelp("ctrl+b/pgup", i18n.T("page up")),
 		),
 		HalfPageUp: key.NewBinding(
 			key.WithKeys("ctrl+u"),
-			key.WithHelp("ctrl+u", "½ page up"),
+			key.WithHelp("ctrl+u", i18n.T("½ page up")),
 		),
 		HalfPageDown: key.NewBinding(
 			key.WithKeys("ctrl+d"),
-			key.WithHelp("ctrl+d", "½ page down"),
+			key.WithHelp("ctrl+d", i18n.T("½ page down")),
 		),
 		Up: key.NewBinding(
 			key.WithKeys("up", "ctrl+o"),
-			key.WithHelp("↑/ctrl+o", "up"),
+			key.WithHelp("↑/ctrl+o", i18n.T("up")),
 		),
 		Down: key.NewBinding(
 			key.WithKeys("down", "ctrl+p"),
-			key.WithHelp("↓/ctrl+p", "down"),
+			key.WithHelp("↓/ctrl+p", i18n.T("down")),
 		),
 	}
 }
@@ -0,0 +1,262 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scbenet/ask/internal/clock"
+)
+
+// Mem is a file system in memory, for tests. paths are cleaned but
+// otherwise taken as given, modification times come from its clock
+type Mem struct {
+	clock clock.Clock
+
+	mu    sync.Mutex
+	files map[string]*memFile
+}
+
+type memFile struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// NewMem returns an empty file system, c may be nil for the system clock
+func NewMem(c clock.Clock) *Mem {
+	if c == nil {
+		c = clock.System
+	}
+	return &Mem{clock: c, files: map[string]*memFile{}}
+}
+
+var errNotEmpty = errors.New("directory not empty")
+
+// isDir reports whether name is a directory, the root always is. callers
+// hold mu
+func (m *Mem) isDir(name string) bool {
+	if filepath.Dir(name) == name {
+		return true
+	}
+	f, ok := m.files[name]
+	return ok && f.mode.IsDir()
+}
+
+// create checks name can be created, its parent has to exist
+func (m *Mem) create(op, name string) error {
+	if !m.isDir(filepath.Dir(name)) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if m.isDir(name) {
+		return &fs.PathError{Op: op, Path: name, Err: errors.New("is a directory")}
+	}
+	return nil
+}
+
+func (m *Mem) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	f, ok := m.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if f.mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	return append([]byte(nil), f.data...), nil
+}
+
+func (m *Mem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if err := m.create("open", name); err != nil {
+		return err
+	}
+	m.files[name] = &memFile{data: append([]byte(nil), data...), mode: perm, modTime: m.clock.Now()}
+	return nil
+}
+
+func (m *Mem) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	f, ok := m.files[name]
+	switch {
+	case !ok && flag&os.O_CREATE == 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	case ok && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case !ok:
+		if err := m.create("open", name); err != nil {
+			return nil, err
+		}
+		f = &memFile{mode: perm, modTime: m.clock.Now()}
+		m.files[name] = f
+	case f.mode.IsDir():
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	case flag&os.O_TRUNC != 0:
+		f.data = nil
+	}
+	return &memHandle{m: m, name: name, file: f, append: flag&os.O_APPEND != 0}, nil
+}
+
+func (m *Mem) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if !m.isDir(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	var entries []fs.DirEntry
+	for path, f := range m.files {
+		if path != name && filepath.Dir(path) == name {
+			entries = append(entries, fs.FileInfoToDirEntry(f.info(path)))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *Mem) Stat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if f, ok := m.files[name]; ok {
+		return f.info(name), nil
+	}
+	if m.isDir(name) {
+		return memInfo{name: filepath.Base(name), mode: fs.ModeDir | 0o755}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+func (m *Mem) MkdirAll(path string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	path = filepath.Clean(path)
+	for dir := path; !m.isDir(dir); dir = filepath.Dir(dir) {
+		if _, ok := m.files[dir]; ok {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: errors.New("not a directory")}
+		}
+		m.files[dir] = &memFile{mode: fs.ModeDir | perm, modTime: m.clock.Now()}
+	}
+	return nil
+}
+
+func (m *Mem) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	f, ok := m.files[name]
+	if !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if f.mode.IsDir() {
+		for path := range m.files {
+			if filepath.Dir(path) == name {
+				return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+			}
+		}
+	}
+	delete(m.files, name)
+	return nil
+}
+
+func (m *Mem) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	oldpath, newpath = filepath.Clean(oldpath), filepath.Clean(newpath)
+	f, ok := m.files[oldpath]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if !m.isDir(filepath.Dir(newpath)) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: fs.ErrNotExist}
+	}
+	if oldpath == newpath {
+		return nil
+	}
+	delete(m.files, oldpath)
+	m.files[newpath] = f
+	if f.mode.IsDir() {
+		prefix := oldpath + string(filepath.Separator)
+		for path, child := range m.files {
+			if rest, ok := strings.CutPrefix(path, prefix); ok {
+				delete(m.files, path)
+				m.files[filepath.Join(newpath, rest)] = child
+			}
+		}
+	}
+	return nil
+}
+
+func (f *memFile) info(path string) memInfo {
+	return memInfo{name: filepath.Base(path), size: int64(len(f.data)), mode: f.mode, modTime: f.modTime}
+}
+
+// memHandle writes to a file that stays the same file if it's renamed,
+// like a file descriptor
+type memHandle struct {
+	m      *Mem
+	name   string
+	file   *memFile
+	append bool
+	offset int
+	closed bool
+}
+
+func (h *memHandle) Write(p []byte) (int, error) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	if h.closed {
+		return 0, &fs.PathError{Op: "write", Path: h.name, Err: fs.ErrClosed}
+	}
+	if h.append {
+		h.offset = len(h.file.data)
+	}
+	if end := h.offset + len(p); end > len(h.file.data) {
+		h.file.data = append(h.file.data, make([]byte, end-len(h.file.data))...)
+	}
+	copy(h.file.data[h.offset:], p)
+	h.offset += len(p)
+	h.file.modTime = h.m.clock.Now()
+	return len(p), nil
+}
+
+func (h *memHandle) Stat() (fs.FileInfo, error) {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	return h.file.info(h.name), nil
+}
+
+func (h *memHandle) Close() error {
+	h.m.mu.Lock()
+	defer h.m.mu.Unlock()
+	if h.closed {
+		return &fs.PathError{Op: "close", Path: h.name, Err: fs.ErrClosed}
+	}
+	h.closed = true
+	return nil
+}
+
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() fs.FileMode  { return i.mode }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memInfo) Sys() any           { return nil }
@@ -0,0 +1,118 @@
+// Package workflow runs a fixed sequence of prompts from a file, for `ask run`
+package workflow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/scbenet/ask/pkg/ask"
+	"gopkg.in/yaml.v3"
+)
+
+// Workflow is a conversation written down ahead of time, every step is sent
+// with the ones before it and their responses
+type Workflow struct {
+	// Model answers the steps that don't name one
+	Model  string `yaml:"model"`
+	System string `yaml:"system"`
+	Steps  []Step `yaml:"steps"`
+}
+
+// Step is one prompt of a workflow
+type Step struct {
+	Prompt string `yaml:"prompt"`
+	// Model answers this step only, the workflow's model otherwise
+	Model string `yaml:"model"`
+	// Output is a file the response is written to, if set
+	Output string `yaml:"output"`
+}
+
+// Chat is what runs the steps, an *ask.Chat
+type Chat interface {
+	Model() string
+	SetModel(model string)
+	Stream(ctx context.Context, prompt string, onChunk func(chunk string)) (ask.Response, error)
+}
+
+// Load reads a workflow from a YAML or JSON file
+func Load(path string) (Workflow, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Workflow{}, err
+	}
+	w, err := Parse(data)
+	if err != nil {
+		return Workflow{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return w, nil
+}
+
+// Parse reads a workflow, JSON is read as the YAML it also is. unknown
+// fields are an error so a typo doesn't silently drop a setting
+func Parse(data []byte) (Workflow, error) {
+	var w Workflow
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&w); err != nil {
+		if errors.Is(err, io.EOF) {
+			return w, errors.New("no steps")
+		}
+		return w, err
+	}
+	if len(w.Steps) == 0 {
+		return w, errors.New("no steps")
+	}
+	for i, s := range w.Steps {
+		if strings.TrimSpace(s.Prompt) == "" {
+			return w, fmt.Errorf("step %d has no prompt", i+1)
+		}
+	}
+	return w, nil
+}
+
+// Run sends the steps in order, streaming the responses to out and noting
+// each step on progress. it stops at the first step that fails
+func (w Workflow) Run(ctx context.Context, chat Chat, out, progress io.Writer) error {
+	defaultModel := w.Model
+	if defaultModel == "" {
+		defaultModel = chat.Model()
+	}
+	for i, step := range w.Steps {
+		model := step.Model
+		if model == "" {
+			model = defaultModel
+		}
+		chat.SetModel(model)
+		fmt.Fprintf(progress, "==> step %d/%d (%s)\n", i+1, len(w.Steps), model)
+
+		resp, err := chat.Stream(ctx, step.Prompt, func(chunk string) {
+			io.WriteString(out, chunk)
+		})
+		if err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+		if !strings.HasSuffix(resp.Content, "\n") {
+			io.WriteString(out, "\n")
+		}
+		if step.Output != "" {
+			if err := writeOutput(step.Output, resp.Content); err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+			fmt.Fprintf(progress, "wrote %s\n", step.Output)
+		}
+	}
+	return nil
+}
+
+// writeOutput saves a response, ending it with a newline like any text file
+func writeOutput(path, content string) error {
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return os.WriteFile(path, []byte(content), 0o644)
+}
@@ -0,0 +1,99 @@
+package workflow
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/scbenet/ask/pkg/ask"
+)
+
+// fakeChat answers every prompt with the model and the prompt, failing the
+// prompts in fail
+type fakeChat struct {
+	model string
+	fail  map[string]bool
+	sent  []string // "model: prompt"
+}
+
+func (c *fakeChat) Model() string         { return c.model }
+func (c *fakeChat) SetModel(model string) { c.model = model }
+
+func (c *fakeChat) Stream(ctx context.Context, prompt string, onChunk func(string)) (ask.Response, error) {
+	c.sent = append(c.sent, c.model+": "+prompt)
+	if c.fail[prompt] {
+		return ask.Response{}, errors.New("provider down")
+	}
+	answer := "re " + prompt
+	onChunk("re ")
+	onChunk(prompt)
+	return ask.Response{Content: answer, Model: c.model}, nil
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		name, data, err string
+	}{
+		{name: "yaml", data: "model: m\nsteps:\n  - prompt: one\n  - prompt: two\n    model: other\n"},
+		{name: "json", data: `{"steps": [{"prompt": "one", "output": "out.md"}]}`},
+		{name: "empty", data: "", err: "no steps"},
+		{name: "no steps", data: "model: m\n", err: "no steps"},
+		{name: "no prompt", data: "steps:\n  - model: m\n", err: "step 1 has no prompt"},
+		{name: "typo", data: "steps:\n  - promt: one\n", err: "field promt not found"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data))
+			if tt.err == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("got %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// TestRun runs a workflow switching models for one step and capturing
+// another's response
+func TestRun(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "second.md")
+	w := Workflow{Model: "main", Steps: []Step{
+		{Prompt: "first"},
+		{Prompt: "second", Model: "other", Output: out},
+		{Prompt: "third"},
+	}}
+	chat := &fakeChat{model: "default"}
+	var stdout, progress strings.Builder
+	if err := w.Run(context.Background(), chat, &stdout, &progress); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.Join(chat.sent, "\n"); got != "main: first\nother: second\nmain: third" {
+		t.Errorf("sent\n%s", got)
+	}
+	if got := stdout.String(); got != "re first\nre second\nre third\n" {
+		t.Errorf("printed %q", got)
+	}
+	if data, err := os.ReadFile(out); err != nil || string(data) != "re second\n" {
+		t.Errorf("output file %q, %v", data, err)
+	}
+	if !strings.Contains(progress.String(), "==> step 2/3 (other)") {
+		t.Errorf("progress %q", progress.String())
+	}
+}
+
+func TestRunStopsAtFailure(t *testing.T) {
+	w := Workflow{Steps: []Step{{Prompt: "first"}, {Prompt: "second"}, {Prompt: "third"}}}
+	chat := &fakeChat{model: "default", fail: map[string]bool{"second": true}}
+	var stdout, progress strings.Builder
+	err := w.Run(context.Background(), chat, &stdout, &progress)
+	if err == nil || err.Error() != "step 2: provider down" {
+		t.Fatalf("got %v", err)
+	}
+	if len(chat.sent) != 2 {
+		t.Errorf("kept going after the failure: %q", chat.sent)
+	}
+}
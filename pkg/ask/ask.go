@@ -0,0 +1,380 @@
+// Package ask is the conversation engine of the ask CLI without its
+// terminal UI, for other Go programs to embed. a Chat keeps a conversation
+// and sends it to a model through the providers ask supports:
+//
+//	chat, err := ask.New(ask.WithModel("openai/gpt-4.1"))
+//	if err != nil {
+//		return err
+//	}
+//	resp, err := chat.Stream(ctx, "why is the sky blue?", func(chunk string) {
+//		fmt.Print(chunk)
+//	})
+//
+// OpenRouter is used when OPENROUTER_API_KEY is set and Gemini models can be
+// reached directly as "gemini:<model>" when GOOGLE_API_KEY is, the same as
+// in the CLI. WithProvider adds OpenAI compatible servers. WithConfig uses
+// the CLI's config file instead: its providers, per-model settings,
+// redaction, retries, rate limits and budgets.
+//
+// nothing is logged unless WithLogger hands the Chat a logger, requests
+// are logged to it the way the CLI logs them
+package ask
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/clock"
+	"github.com/scbenet/ask/internal/config"
+	"github.com/scbenet/ask/internal/conversation"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/logging"
+	"github.com/scbenet/ask/internal/providers"
+	"github.com/scbenet/ask/internal/spend"
+)
+
+// DefaultModel answers when WithModel isn't given
+const DefaultModel = "google/gemini-2.5-flash-preview"
+
+// Message is a message of the conversation
+type Message struct {
+	Role    string // "system", "user" or "assistant"
+	Content string
+}
+
+// Usage is what a response used, as far as the provider reported it
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64 // in USD, only reported by some providers
+	// prompt tokens read from and written to the provider's prompt cache
+	CacheReadTokens  int
+	CacheWriteTokens int
+}
+
+// Response is a model's answer to a prompt
+type Response struct {
+	Content string
+	Model   string
+	// RequestID is ours, also sent as the X-Request-ID header. ProviderID
+	// is the one the provider gave the response, either may be empty
+	RequestID  string
+	ProviderID string
+	Usage      *Usage // nil if the provider didn't report it
+}
+
+type provider struct {
+	name, baseURL, apiKey string
+}
+
+// Chat is a conversation with a model. it isn't safe for concurrent use,
+// a prompt is answered before the next is sent
+type Chat struct {
+	client       llm.LLMClient
+	model        string
+	systemPrompt string
+	params       llm.Params
+	conversation *conversation.Conversation
+
+	providers []provider
+	history   []Message
+
+	// set by WithConfig
+	config     *config.Config
+	configPath string
+	ledger     *spend.Ledger
+	spent      float64
+	err        error
+
+	logger logging.Logger
+}
+
+// Option configures a Chat
+type Option func(*Chat)
+
+// WithModel sets the model answering, e.g. "openai/gpt-4.1" on OpenRouter
+// or "name:model" for a provider added with WithProvider
+func WithModel(model string) Option {
+	return func(c *Chat) { c.model = model }
+}
+
+// WithSystemPrompt is sent ahead of the conversation with every request
+func WithSystemPrompt(prompt string) Option {
+	return func(c *Chat) { c.systemPrompt = prompt }
+}
+
+// WithProvider adds an OpenAI compatible server, its models are reached as
+// "name:model". baseURL is the API root, e.g. http://localhost:1234/v1, and
+// apiKey may be empty
+func WithProvider(name, baseURL, apiKey string) Option {
+	return func(c *Chat) { c.providers = append(c.providers, provider{name, baseURL, apiKey}) }
+}
+
+// WithTemperature sets the sampling temperature, the provider's default is
+// used otherwise
+func WithTemperature(temperature float64) Option {
+	return func(c *Chat) { c.params.Temperature = &temperature }
+}
+
+// WithMaxTokens caps the length of the responses
+func WithMaxTokens(n int) Option {
+	return func(c *Chat) { c.params.MaxTokens = n }
+}
+
+// WithHistory continues an earlier conversation
+func WithHistory(messages []Message) Option {
+	return func(c *Chat) { c.history = append(c.history, messages...) }
+}
+
+// WithConfig reads the CLI's config file, "" for the default one, and
+// sends requests the way the CLI does: to its providers, with the settings
+// of the model and through its redaction, retries and rate limits. the
+// responses count against its budgets and the daily spend the CLI keeps
+func WithConfig(path string) Option {
+	return func(c *Chat) {
+		if path == "" {
+			if path, c.err = config.DefaultPath(); c.err != nil {
+				return
+			}
+		}
+		cfg, err := config.Load(path)
+		if err != nil {
+			c.err = err
+			return
+		}
+		c.config, c.configPath = &cfg, path
+	}
+}
+
+// WithLogger logs the requests and what goes wrong with them to l, in the
+// format of the CLI's log. nothing is logged without it
+func WithLogger(l *log.Logger) Option {
+	return func(c *Chat) { c.logger = logging.New(l) }
+}
+
+// withLedger keeps the daily spend in ledger instead of the CLI's, for tests
+func withLedger(ledger *spend.Ledger) Option {
+	return func(c *Chat) { c.ledger = ledger }
+}
+
+// withClient answers from client instead of the providers, for tests
+func withClient(client llm.LLMClient) Option {
+	return func(c *Chat) { c.client = client }
+}
+
+// New starts a conversation. it fails if no provider is configured
+func New(opts ...Option) (*Chat, error) {
+	c := &Chat{model: DefaultModel, logger: logging.New(nil)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.config != nil {
+		client, err := c.configClient()
+		if err != nil {
+			return nil, err
+		}
+		c.client = client
+	} else if c.client == nil {
+		client, err := c.newClient()
+		if err != nil {
+			return nil, err
+		}
+		c.client = client
+	}
+	c.Reset()
+	return c, nil
+}
+
+// newClient routes to the providers the way the CLI does
+func (c *Chat) newClient() (llm.LLMClient, error) {
+	var fallback llm.LLMClient
+	if openRouter, err := llm.NewOpenRouterClient(); err == nil {
+		fallback = openRouter
+	}
+	router := llm.NewRouter(fallback)
+	configured := fallback != nil
+	if gemini, err := llm.NewGeminiClient(); err == nil {
+		router.Register("gemini", gemini)
+		configured = true
+	}
+	for _, p := range c.providers {
+		router.Register(p.name, llm.NewOpenAICompatibleClient(p.baseURL, p.apiKey))
+		configured = true
+	}
+	if !configured {
+		return nil, errors.New("no provider configured, set OPENROUTER_API_KEY or GOOGLE_API_KEY or use WithProvider")
+	}
+	return llm.Chain(router, llm.RequestIDs()), nil
+}
+
+// configClient sends through the providers and middleware of the config,
+// the ones the CLI uses
+func (c *Chat) configClient() (llm.LLMClient, error) {
+	cfg := *c.config
+	for _, p := range c.providers {
+		cfg.Providers = append(cfg.Providers, config.ProviderConfig{Name: p.name, BaseURL: p.baseURL, APIKey: p.apiKey})
+	}
+	base := c.client
+	if base == nil {
+		opened := providers.Open(cfg, c.logger)
+		if !opened.Configured() {
+			return nil, fmt.Errorf("no provider configured, set OPENROUTER_API_KEY or GOOGLE_API_KEY or add providers to %s", c.configPath)
+		}
+		base = opened.Router
+	}
+	if c.ledger == nil {
+		if path, err := spend.DefaultPath(); err == nil {
+			c.ledger = spend.Open(path)
+		} else {
+			c.logger.Errorf("opening the spend ledger: %v", err)
+		}
+	}
+	return llm.Chain(base, providers.Middleware(cfg, c.ledger, clock.System, c.logger)...), nil
+}
+
+// SetModel switches the model answering the next prompts
+func (c *Chat) SetModel(model string) {
+	c.model = model
+}
+
+// Model is the model answering
+func (c *Chat) Model() string {
+	return c.model
+}
+
+// Reset starts the conversation over, from the WithHistory messages if any
+func (c *Chat) Reset() {
+	messages := make([]conversation.Message, 0, len(c.history))
+	for _, m := range c.history {
+		messages = append(messages, conversation.Message{Role: m.Role, Content: m.Content})
+	}
+	c.conversation = conversation.New(messages)
+}
+
+// Messages returns the conversation so far, without the system prompt and
+// the prompts that failed
+func (c *Chat) Messages() []Message {
+	var messages []Message
+	for _, m := range c.conversation.Messages {
+		if !m.Failed() {
+			messages = append(messages, Message{Role: m.Role, Content: m.Content})
+		}
+	}
+	return messages
+}
+
+// Send sends prompt and waits for the whole response
+func (c *Chat) Send(ctx context.Context, prompt string) (Response, error) {
+	return c.Stream(ctx, prompt, nil)
+}
+
+// Stream sends prompt and hands the response to onChunk as it arrives,
+// onChunk may be nil. the response is added to the conversation once it's
+// complete. a prompt that fails is left out of the next requests
+func (c *Chat) Stream(ctx context.Context, prompt string, onChunk func(chunk string)) (Response, error) {
+	if c.config != nil {
+		if session := c.config.Budget.Session; session > 0 && c.spent >= session {
+			return Response{Model: c.model}, fmt.Errorf("the budget of $%.2f is spent", session)
+		}
+	}
+	c.conversation.Add(conversation.Message{Role: "user", Content: prompt, Model: c.model})
+	failed := func(err error, requestID string) (Response, error) {
+		last := &c.conversation.Messages[c.conversation.Len()-1]
+		last.Error, last.RequestID = err.Error(), requestID
+		return Response{Model: c.model, RequestID: requestID}, err
+	}
+
+	requestID := llm.NewRequestID()
+	ch := make(chan tea.Msg)
+	ctx = logging.WithLogger(llm.WithRequestID(ctx, requestID), c.logger)
+	c.client.StreamGenerate(ctx, c.request(), ch)
+	var end *llm.StreamEndMsg
+	var err error
+	for msg := range ch {
+		switch m := msg.(type) {
+		case llm.StreamChunkMsg:
+			if onChunk != nil {
+				onChunk(m.Content)
+			}
+		case llm.StreamEndMsg:
+			end = &m
+		case llm.StreamErrorMsg:
+			err = m.Err
+		}
+	}
+	if err != nil {
+		return failed(err, requestID)
+	}
+	if end == nil {
+		return failed(errors.New("the stream ended without a response"), requestID)
+	}
+
+	c.conversation.Add(conversation.Message{
+		Role:       "assistant",
+		Content:    end.FullResponse,
+		Model:      c.model,
+		RequestID:  requestID,
+		ProviderID: end.ProviderID,
+	})
+	resp := Response{Content: end.FullResponse, Model: c.model, RequestID: requestID, ProviderID: end.ProviderID}
+	if u := end.Usage; u != nil {
+		resp.Usage = &Usage{
+			PromptTokens:     u.PromptTokens,
+			CompletionTokens: u.CompletionTokens,
+			Cost:             u.Cost,
+			CacheReadTokens:  u.CacheReadTokens,
+			CacheWriteTokens: u.CacheWriteTokens,
+		}
+		c.recordSpend(u.Cost)
+	}
+	return resp, nil
+}
+
+// recordSpend counts what a response cost against the budgets
+func (c *Chat) recordSpend(usd float64) {
+	if c.config == nil || usd <= 0 {
+		return
+	}
+	c.spent += usd
+	if c.ledger != nil {
+		if err := c.ledger.Add(usd); err != nil {
+			c.logger.Errorf("recording spend: %v", err)
+		}
+	}
+}
+
+// request is the conversation as sent to the model, with the settings the
+// config has for it where the options didn't set them
+func (c *Chat) request() llm.Request {
+	messages := c.conversation.LLMMessages()
+	var system []string
+	if strings.TrimSpace(c.systemPrompt) != "" {
+		system = append(system, c.systemPrompt)
+	}
+	params := c.params
+	if c.config != nil {
+		mc := c.config.ModelConfig(c.model)
+		if mc.SystemPrompt != "" {
+			system = append(system, mc.SystemPrompt)
+		}
+		configured := providers.Params(mc)
+		if params.Temperature != nil {
+			configured.Temperature = params.Temperature
+		}
+		if params.MaxTokens != 0 {
+			configured.MaxTokens = params.MaxTokens
+		}
+		params = configured
+	}
+	if len(system) > 0 {
+		messages = append([]llm.Message{{Role: "system", Content: strings.Join(system, "\n\n")}}, messages...)
+	}
+	return llm.Request{Model: c.model, Messages: messages, Params: params}
+}
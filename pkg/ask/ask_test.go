@@ -0,0 +1,153 @@
+package ask
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/scbenet/ask/internal/llm"
+	"github.com/scbenet/ask/internal/spend"
+)
+
+func TestChat(t *testing.T) {
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{
+		{llm.StreamChunkMsg{Content: "Hi"}, llm.StreamChunkMsg{Content: "!"}, llm.StreamEndMsg{FullResponse: "Hi!", Usage: &llm.Usage{PromptTokens: 5, CompletionTokens: 1}}},
+		{llm.StreamErrorMsg{Err: errors.New("overloaded")}},
+		{llm.StreamEndMsg{FullResponse: "Fine."}},
+	}}
+	chat, err := New(withClient(client), WithModel("m"), WithSystemPrompt("be brief"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var streamed strings.Builder
+	resp, err := chat.Stream(context.Background(), "hello", func(chunk string) { streamed.WriteString(chunk) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Hi!" || streamed.String() != "Hi!" || resp.Usage.PromptTokens != 5 || !strings.HasPrefix(resp.RequestID, "req_") {
+		t.Fatalf("got %+v, streamed %q", resp, streamed.String())
+	}
+
+	if _, err := chat.Send(context.Background(), "still there?"); err == nil || err.Error() != "overloaded" {
+		t.Fatalf("got %v, want the stream's error", err)
+	}
+	if _, err := chat.Send(context.Background(), "how are you?"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed prompt isn't sent again, the system prompt always is
+	var sent []string
+	for _, m := range client.Requests()[2].Messages {
+		sent = append(sent, m.Role+": "+m.Content)
+	}
+	if want := "system: be brief\nuser: hello\nassistant: Hi!\nuser: how are you?"; strings.Join(sent, "\n") != want {
+		t.Fatalf("sent\n%s\nwant\n%s", strings.Join(sent, "\n"), want)
+	}
+	if got := chat.Messages(); len(got) != 4 || got[3].Content != "Fine." {
+		t.Fatalf("messages are %+v", got)
+	}
+
+	chat.Reset()
+	if len(chat.Messages()) != 0 {
+		t.Fatal("reset kept the conversation")
+	}
+}
+
+func TestNoProvider(t *testing.T) {
+	for _, env := range []string{"OPENROUTER_API_KEY", "GOOGLE_API_KEY", "GEMINI_API_KEY"} {
+		t.Setenv(env, "")
+	}
+	if _, err := New(); err == nil {
+		t.Fatal("no error without a provider")
+	}
+	if _, err := New(WithProvider("local", "http://localhost:1234/v1", "")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	cfg := `{
+		"redact": ["sk-[a-z]+"],
+		"budget": {"session": 0.01},
+		"models": {"m": {"systemPrompt": "answer in French", "maxTokens": 100}}
+	}`
+	if err := os.WriteFile(path, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client := &llm.MockLLMClient{Streams: [][]tea.Msg{
+		{llm.StreamEndMsg{FullResponse: "Oui.", Usage: &llm.Usage{Cost: 0.02}}},
+	}}
+	ledger := spend.Open(filepath.Join(dir, "spend.json"))
+	chat, err := New(WithConfig(path), withClient(client), withLedger(ledger), WithModel("m"), WithSystemPrompt("be brief"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := chat.Send(context.Background(), "my key is sk-abc"); err != nil {
+		t.Fatal(err)
+	}
+	req := client.Requests()[0]
+	if req.Messages[0].Content != "be brief\n\nanswer in French" || req.Params.MaxTokens != 100 {
+		t.Fatalf("the model's settings weren't applied: %+v", req)
+	}
+	if got := req.Messages[1].Content; strings.Contains(got, "sk-abc") {
+		t.Fatalf("sent %q unredacted", got)
+	}
+	if today, err := ledger.Today(); err != nil || today != 0.02 {
+		t.Fatalf("spent today %v, %v", today, err)
+	}
+
+	// the response took the conversation over its budget
+	if _, err := chat.Send(context.Background(), "encore"); err == nil {
+		t.Fatal("sent over the budget")
+	}
+	if len(client.Requests()) != 1 {
+		t.Fatal("the request over the budget reached the provider")
+	}
+}
+
+// TestLogger logs the requests to the WithLogger logger, and nowhere
+// without one, the standard logger is the embedding program's
+func TestLogger(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	var std strings.Builder
+	out := log.Writer()
+	log.SetOutput(&std)
+	defer log.SetOutput(out)
+
+	send := func(opts ...Option) {
+		chat, err := New(append(opts, WithProvider("local", srv.URL, ""), WithModel("local:m"))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := chat.Send(context.Background(), "hello"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send()
+	var logged strings.Builder
+	send(WithLogger(log.New(&logged, "", 0)))
+	if !strings.Contains(logged.String(), "sending streaming request") {
+		t.Errorf("logged %q, want the request", logged.String())
+	}
+	if std.Len() > 0 {
+		t.Errorf("logged %q to the standard logger", std.String())
+	}
+}